@@ -1,20 +1,33 @@
 package commands
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"runtime"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/fatih/color"
 	"github.com/mad01/ralph/internal/config"
+	"github.com/mad01/ralph/internal/cron"
 	"github.com/mad01/ralph/internal/dotfile"
+	"github.com/mad01/ralph/internal/exitcode"
 	"github.com/mad01/ralph/internal/hooks"
+	"github.com/mad01/ralph/internal/inventory"
+	"github.com/mad01/ralph/internal/lock"
+	"github.com/mad01/ralph/internal/macos"
+	"github.com/mad01/ralph/internal/plan"
 	"github.com/mad01/ralph/internal/repo"
 	"github.com/mad01/ralph/internal/report"
+	"github.com/mad01/ralph/internal/services"
 	"github.com/mad01/ralph/internal/shell"
 	"github.com/mad01/ralph/internal/tool"
+	"github.com/mad01/ralph/internal/vscode"
 	"github.com/spf13/cobra"
 )
 
@@ -24,6 +37,10 @@ var (
 	forceBuilds       bool
 	specificBuild     string
 	resetBuilds       bool
+	keepGoing         bool
+	refreshTools      bool
+	applyOutput       string
+	applyByRecipe     bool
 )
 
 var applyCmd = &cobra.Command{
@@ -31,349 +48,1317 @@ var applyCmd = &cobra.Command{
 	Short: "Apply ralph configurations",
 	Long:  `Applies the configurations defined in your ralph config file. This includes symlinking dotfiles, setting up shell environments, etc.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		// Per-item output: visible only with --verbose, otherwise discarded
-		var w io.Writer = io.Discard
-		if verbose {
-			w = os.Stdout
-		}
+		ctx, stop := interruptContext(cmd.Context())
+		code := runApply(ctx)
+		stop()
+		os.Exit(code)
+	},
+}
 
-		// Auto-migrate from legacy dotter config
-		if err := config.MigrateFromLegacy(); err != nil {
-			fmt.Fprintln(os.Stderr, color.YellowString("Warning: legacy migration failed: %v", err))
+// applyLockFileName is the state file that keeps two applies from running
+// at the same time.
+const applyLockFileName = "apply.lock"
+
+// runApply applies the configuration and returns the process exit code.
+// Cancelling ctx stops the commands apply runs (hooks, builds, git), and
+// apply then stops after the current phase: state gathered so far (manifest,
+// build and directory state) is saved, the summary printed and
+// exitcode.Interrupted returned.
+func runApply(ctx context.Context) int {
+	switch applyOutput {
+	case "text":
+	case "json":
+		if !dryRun {
+			fmt.Fprintln(os.Stderr, color.RedString("Error: --output json requires --dry-run"))
+			return exitcode.Failure
 		}
+		return printPlanJSON()
+	default:
+		fmt.Fprintln(os.Stderr, color.RedString("Error: unknown output '%s' (use text or json)", applyOutput))
+		return exitcode.Failure
+	}
 
-		fmt.Println("Applying ralph configurations...")
+	// Per-item output: visible only with --verbose, otherwise discarded
+	var w io.Writer = io.Discard
+	if verbose {
+		w = os.Stdout
+	}
 
-		if dryRun {
-			color.Cyan("\n*** DRY RUN MODE ENABLED ***")
-			color.Cyan("No actual changes will be made.")
-			color.Cyan("****************************\n")
+	if !dryRun {
+		applyLock, code := acquireApplyLock()
+		if applyLock == nil {
+			return code
 		}
+		defer applyLock.Release()
+	}
 
-		rpt := &report.Report{Command: "apply"}
-		bold := color.New(color.Bold).SprintFunc()
-		dim := color.New(color.Faint).SprintFunc()
+	// Auto-migrate from legacy dotter config
+	if err := config.MigrateFromLegacy(); err != nil {
+		fmt.Fprintln(os.Stderr, color.YellowString("Warning: legacy migration failed: %v", err))
+	}
 
-		// Handle --reset-builds flag
-		if resetBuilds {
-			if dryRun {
-				fmt.Fprintln(w, "[DRY RUN] Would reset all build state.")
-			} else {
-				if err := hooks.ResetBuildState(); err != nil {
-					fmt.Fprintln(os.Stderr, color.RedString("Error resetting build state: %v", err))
-					os.Exit(1)
-				}
-			}
+	fmt.Println("Applying ralph configurations...")
+
+	if dryRun {
+		color.Cyan("\n*** DRY RUN MODE ENABLED ***")
+		color.Cyan("No actual changes will be made.")
+		color.Cyan("****************************\n")
+	}
+
+	rpt := &report.Report{Command: "apply", Strict: strict}
+	spinner := newSpinner()
+	if ask := config.Prompter; ask != nil {
+		// Templates may prompt while the dotfiles spinner is running
+		config.Prompter = func(name, description string) (string, error) {
+			spinner.Stop()
+			defer spinner.Start("Dotfiles")
+			return ask(name, description)
 		}
+		defer func() { config.Prompter = ask }()
+	}
+	bold := color.New(color.Bold).SprintFunc()
+	dim := color.New(color.Faint).SprintFunc()
 
-		cfg, err := config.LoadConfig()
-		if err != nil {
-			fmt.Fprintln(os.Stderr, color.RedString("Error loading configuration: %v", err))
-			cfgPhase := rpt.AddPhase("Configuration")
-			cfgPhase.AddFail("config", "failed to load", err)
-			rpt.PrintSummary(os.Stdout, summaryVerbosity())
-			os.Exit(1)
-		}
-
-		// Get current hostname for host filtering
-		currentHost := config.GetCurrentHost()
-
-		symlinkAction := dotfile.SymlinkActionBackup // Default action
-		if overwriteExisting {
-			symlinkAction = dotfile.SymlinkActionOverwrite
-			fmt.Fprintln(w, "Symlink action: Overwrite existing files.")
-		} else if skipExisting {
-			symlinkAction = dotfile.SymlinkActionSkip
-			fmt.Fprintln(w, "Symlink action: Skip existing files.")
+	// Handle --reset-builds flag
+	if resetBuilds {
+		if dryRun {
+			fmt.Fprintln(w, "[DRY RUN] Would reset all build state.")
 		} else {
-			fmt.Fprintln(w, "Symlink action: Backup existing files.")
+			if err := hooks.ResetBuildState(); err != nil {
+				fmt.Fprintln(os.Stderr, color.RedString("Error resetting build state: %v", err))
+				if !keepGoing {
+					return exitcode.Failure
+				}
+				rpt.AddPhase("Build state").AddFail("reset-builds", err.Error(), err)
+			}
 		}
+	}
 
-		// Execute pre-apply hooks
-		if len(cfg.Hooks.PreApply) > 0 {
-			prePhase := rpt.AddPhase("Pre-apply hooks")
-			preContext := &hooks.HookContext{
-				DryRun: dryRun,
-			}
-			if err := hooks.RunHooks(w, cfg.Hooks.PreApply, hooks.PreApply, preContext, dryRun); err != nil {
-				fmt.Fprintln(os.Stderr, color.RedString("Error executing pre-apply hooks: %v", err))
-				prePhase.AddFail("pre-apply", err.Error(), err)
-				rpt.PrintSummary(os.Stdout, summaryVerbosity())
-				os.Exit(1)
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, color.RedString("Error loading configuration: %v", err))
+		cfgPhase := rpt.AddPhase("Configuration")
+		cfgPhase.AddFail("config", "failed to load", err)
+		rpt.PrintSummary(os.Stdout, summaryVerbosity())
+		return exitcode.Failure
+	}
+
+	// Move dotfiles from a flat config.toml into recipes, if opted in
+	if cfg.RecipesConfig.AutoMigrate {
+		cfg, _ = migrateConfig(w, cfg, rpt)
+	}
+	// Hashed as loaded, before templates add prompted variables, so doctor
+	// can tell whether the config changed since
+	configHash, err := config.Hash(cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, color.YellowString("Warning: %v", err))
+	}
+
+	// Get current hostname for host filtering
+	currentHost := config.GetCurrentHost()
+	keepGoing = keepGoing || cfg.KeepGoing
+	rpt.Strict = rpt.Strict || cfg.Strict
+
+	// Renamed dotfiles and builds keep the state of their former names
+	carryOverAliases(w, cfg, rpt)
+
+	// A dry run reports what the plan says would change
+	var pl *plan.Plan
+	if dryRun {
+		pl = plan.Build(cfg, currentHost, planOptions())
+	}
+
+	symlinkAction := dotfile.SymlinkActionBackup // Default action
+	if overwriteExisting {
+		symlinkAction = dotfile.SymlinkActionOverwrite
+		fmt.Fprintln(w, "Symlink action: Overwrite existing files.")
+	} else if skipExisting {
+		symlinkAction = dotfile.SymlinkActionSkip
+		fmt.Fprintln(w, "Symlink action: Skip existing files.")
+	} else {
+		fmt.Fprintln(w, "Symlink action: Backup existing files.")
+	}
+
+	// Execute pre-apply hooks
+	if len(cfg.Hooks.PreApply) > 0 {
+		prePhase := rpt.AddPhase("Pre-apply hooks")
+		preContext := &hooks.HookContext{
+			DryRun: dryRun,
+		}
+		if err := hooks.RunGroups(ctx, w, cfg.Hooks.PreApply, hooks.PreApply, preContext, dryRun); err != nil {
+			fmt.Fprintln(os.Stderr, color.RedString("Error executing pre-apply hooks: %v", err))
+			prePhase.AddFail("pre-apply", err.Error(), err)
+			if !keepGoing && ctx.Err() == nil {
+				printApplySummary(rpt, cfg)
+				return exitcode.Failure
 			}
+		} else {
 			prePhase.AddOK("pre-apply", "completed")
 		}
+		printPhaseLine(prePhase)
+	}
+	if ctx.Err() != nil {
+		return stopInterrupted(rpt, cfg)
+	}
 
-		// Process directories
-		dirPhase := rpt.AddPhase("Directories")
-		if len(cfg.Directories) > 0 {
-			fmt.Fprintln(w, "\nProcessing directories...")
-			for name, dir := range cfg.Directories {
-				if !config.IsEnabled(dir.Enable) {
-					fmt.Fprintf(w, "  %s %s\n", color.CyanString("skip"), dim(name+" (disabled)"))
-					dirPhase.AddSkip(name, "disabled")
-					continue
-				}
-				if !config.ShouldApplyForHost(dir.Hosts, currentHost) {
-					fmt.Fprintf(w, "  %s %s\n", color.CyanString("skip"), dim(name+" (host filter)"))
-					dirPhase.AddSkip(name, "host filter")
-					continue
+	// Process directories
+	dirPhase := rpt.AddPhase("Directories")
+	dirState, dirStateErr := dotfile.LoadDirectoryState()
+	dirStateChanged := false
+	if dirStateErr != nil {
+		fmt.Fprintln(os.Stderr, color.YellowString("Warning: could not load directory state: %v", dirStateErr))
+	}
+	if len(cfg.Directories) > 0 {
+		fmt.Fprintln(w, "\nProcessing directories...")
+		for name, dir := range cfg.Directories {
+			if !config.IsEnabled(dir.Enable) {
+				fmt.Fprintf(w, "  %s %s\n", color.CyanString("skip"), dim(name+" (disabled)"))
+				dirPhase.AddSkip(name, "disabled")
+				continue
+			}
+			if !config.ShouldApplyForHost(dir.Hosts, currentHost) {
+				fmt.Fprintf(w, "  %s %s\n", color.CyanString("skip"), dim(name+" (host filter)"))
+				dirPhase.AddSkip(name, "host filter")
+				continue
+			}
+			fmt.Fprintf(w, "  %s\n", bold(name))
+			fmt.Fprintf(w, "    %s\n", dim(dir.Target))
+			inSync := true
+			change := ""
+			if dryRun {
+				inSync = !planned(pl, config.KindDirectory, name)
+				if inSync {
+					change = plan.ChangeNone
 				}
-				fmt.Fprintf(w, "  %s\n", bold(name))
-				fmt.Fprintf(w, "    %s\n", dim(dir.Target))
-				if err := dotfile.CreateDirectory(w, dir, dryRun); err != nil {
-					fmt.Fprintln(os.Stderr, color.RedString("    error: %s: %v", name, err))
-					dirPhase.AddFail(name, err.Error(), err)
-				} else {
-					dirPhase.AddOK(name, "")
+			} else {
+				change = plan.DirectoryChange(dir)
+			}
+			created, err := dotfile.EnsureDirectory(w, dir, dryRun)
+			if dirStateErr == nil {
+				if _, tracked := dirState.Directories[name]; tracked && !dir.RemoveOnDisable {
+					delete(dirState.Directories, name)
+					dirStateChanged = true
+				} else if dir.RemoveOnDisable && len(created) > 0 {
+					dirState.Directories[name] = dotfile.DirectoryRecord{Target: dir.Target, Created: created}
+					dirStateChanged = true
 				}
 			}
+			if err != nil {
+				fmt.Fprintln(os.Stderr, color.RedString("    error: %s: %v", name, err))
+				dirPhase.AddFail(name, err.Error(), err)
+			} else if !inSync {
+				dirPhase.AddPending(name, "would create or fix mode")
+			} else {
+				dirPhase.AddChanged(name, appliedChange(change), "")
+			}
 		}
+	}
 
-		// Process repositories
-		if len(cfg.Repos) > 0 {
-			repoPhase := rpt.AddPhase("Repositories")
-			if err := repo.ProcessRepos(w, cfg.Repos, currentHost, dryRun); err != nil {
-				fmt.Fprintln(os.Stderr, color.RedString("Error processing repositories: %v", err))
-				repoPhase.AddFail("repos", err.Error(), err)
+	// Clean up directories created with remove_on_disable whose entry is
+	// now disabled, filtered out for this host, or gone from the config.
+	if dirStateErr == nil {
+		for name, record := range dirState.Directories {
+			if dir, exists := cfg.Directories[name]; exists && config.IsEnabled(dir.Enable) && config.ShouldApplyForHost(dir.Hosts, currentHost) {
+				continue
+			}
+			fmt.Fprintf(w, "  %s %s\n", bold(name), dim("(removed on disable)"))
+			gone, err := dotfile.RemoveCreatedDirectories(w, record, dryRun)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, color.RedString("    error: %s: %v", name, err))
+				dirPhase.AddFail(name, err.Error(), err)
+				continue
+			}
+			if gone && dryRun {
+				dirPhase.AddPending(name, "would remove")
+			} else if gone {
+				dirPhase.AddChanged(name, report.ChangeUpdated, "removed")
+				delete(dirState.Directories, name)
+				dirStateChanged = true
 			} else {
-				repoPhase.AddOK("repos", "processed")
+				dirPhase.AddWarn(name, "not removed: directory is not empty")
+			}
+		}
+		if dirStateChanged && !dryRun {
+			if err := dotfile.SaveDirectoryState(dirState); err != nil {
+				fmt.Fprintln(os.Stderr, color.YellowString("Warning: could not save directory state: %v", err))
+			}
+		}
+	}
+	if len(dirPhase.Steps) > 0 {
+		printPhaseLine(dirPhase)
+	}
+	if ctx.Err() != nil {
+		return stopInterrupted(rpt, cfg)
+	}
+
+	// Process repositories
+	if len(cfg.Repos) > 0 {
+		repoPhase := rpt.AddPhase("Repositories")
+		spinner.Start(fmt.Sprintf("Repositories (%d)", len(cfg.Repos)))
+		// Clone progress drives the spinner; verbose output shows git's own
+		var progress repo.Progress
+		if !verbose {
+			progress = func(name, status string) {
+				spinner.Update(fmt.Sprintf("Repositories: cloning %s  %s", name, status))
+			}
+		}
+		clones, err := repo.ProcessRepos(ctx, w, cfg.Repos, currentHost, dryRun, keepGoing, progress)
+		spinner.Stop()
+		for _, c := range clones {
+			repoPhase.AddChanged(c.Name, report.ChangeCreated, "cloned, "+repo.FormatSize(c.Size))
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, color.RedString("Error processing repositories: %v", err))
+			addFailures(repoPhase, "repos", err)
+		} else {
+			repoPhase.AddOK("repos", "processed")
+			if dryRun {
+				for _, op := range pl.Pending() {
+					if op.Kind == config.KindRepo {
+						repoPhase.AddPending(op.Name, "would clone or check out")
+					}
+				}
 			}
 		}
+		printPhaseLine(repoPhase)
+	}
+	if ctx.Err() != nil {
+		return stopInterrupted(rpt, cfg)
+	}
 
-		fmt.Fprintln(w, "\nProcessing dotfiles...")
-		dotfilesApplied := 0
-		dotfilesSkippedOrFailed := 0
-		dfPhase := rpt.AddPhase("Dotfiles")
+	fmt.Fprintln(w, "\nProcessing dotfiles...")
+	dotfilesApplied := 0
+	dotfilesSkippedOrFailed := 0
+	dfPhase := rpt.AddPhase("Dotfiles")
+	manifest, manifestErr := dotfile.LoadManifest()
+	if manifestErr != nil {
+		fmt.Fprintln(os.Stderr, color.YellowString("Warning: could not load manifest: %v", manifestErr))
+	}
+	spinner.Start("Dotfiles")
+	dotfileIndex := 0
 
-		for name, df := range cfg.Dotfiles {
-			if !config.IsEnabled(df.Enable) {
-				fmt.Fprintf(w, "  %s %s\n", color.CyanString("skip"), dim(name+" (disabled)"))
-				dfPhase.AddSkip(name, "disabled")
-				continue
+	for name, df := range cfg.Dotfiles {
+		dotfileIndex++
+		if ctx.Err() != nil {
+			// Stop here, but keep the manifest entries of those applied
+			dfPhase.AddSkip(name, "interrupted")
+			continue
+		}
+		spinner.Update(fmt.Sprintf("Dotfiles [%d/%d] %s  %s", dotfileIndex, len(cfg.Dotfiles), name, dfPhase.CountsString()))
+		if !config.IsEnabled(df.Enable) {
+			fmt.Fprintf(w, "  %s %s\n", color.CyanString("skip"), dim(name+" (disabled)"))
+			dfPhase.AddSkip(name, "disabled")
+			continue
+		}
+		if !config.ShouldApplyForHost(df.Hosts, currentHost) {
+			fmt.Fprintf(w, "  %s %s\n", color.CyanString("skip"), dim(name+" (host filter)"))
+			dfPhase.AddSkip(name, "host filter")
+			continue
+		}
+		fmt.Fprintf(w, "  %s\n", bold(name))
+		if df.URL != "" {
+			fmt.Fprintf(w, "    %s → %s\n", dim(df.Target), dim(df.URL))
+		} else {
+			fmt.Fprintf(w, "    %s → %s\n", dim(df.Target), dim(df.Source))
+		}
+
+		// Execute pre-link hooks for this specific dotfile
+		if preHooks, exists := cfg.Hooks.PreLink[name]; exists && len(preHooks) > 0 {
+			linkContext := &hooks.HookContext{
+				DotfileName: name,
+				SourcePath:  filepath.Join(cfg.DotfilesRepoPath, df.Source),
+				TargetPath:  df.Target,
+				DryRun:      dryRun,
 			}
-			if !config.ShouldApplyForHost(df.Hosts, currentHost) {
-				fmt.Fprintf(w, "  %s %s\n", color.CyanString("skip"), dim(name+" (host filter)"))
-				dfPhase.AddSkip(name, "host filter")
+			if err := hooks.RunHooks(ctx, w, preHooks, hooks.PreLink, linkContext, dryRun); err != nil {
+				fmt.Fprintln(os.Stderr, color.RedString("Error executing pre-link hooks for %s: %v", name, err))
+				dotfilesSkippedOrFailed++
+				dfPhase.AddFail(name, fmt.Sprintf("pre-link hook: %v", err), err)
 				continue
 			}
-			fmt.Fprintf(w, "  %s\n", bold(name))
-			fmt.Fprintf(w, "    %s → %s\n", dim(df.Target), dim(df.Source))
+		}
 
-			// Execute pre-link hooks for this specific dotfile
-			if preHooks, exists := cfg.Hooks.PreLink[name]; exists && len(preHooks) > 0 {
+		inSync := true
+		change := ""
+		if dryRun {
+			inSync = !planned(pl, config.KindDotfile, name)
+			if inSync {
+				change = plan.ChangeNone
+			}
+		} else {
+			change = plan.DotfileChange(df, cfg)
+		}
+
+		templateErr, symlinkErr := deployDotfile(w, cfg, name, df, symlinkAction, nil)
+		if templateErr != nil {
+			fmt.Fprintln(os.Stderr, color.YellowString("    - Warning: Error processing template for %s: %v", name, templateErr))
+			dotfilesSkippedOrFailed++
+			dfPhase.AddWarn(name, fmt.Sprintf("template error: %v", templateErr))
+			continue
+		}
+
+		if symlinkErr != nil {
+			fmt.Fprintln(os.Stderr, color.RedString("    error: %s: %v", name, symlinkErr))
+			dotfilesSkippedOrFailed++
+			dfPhase.AddFail(name, symlinkErr.Error(), symlinkErr)
+		} else {
+			if !dryRun { // only count as applied if not dry run
+				dotfilesApplied++
+				if manifestErr == nil {
+					if entry, err := dotfile.NewManifestEntry(df, cfg.DotfilesRepoPath); err == nil {
+						if entry.Checksums, err = dotfile.DeployedChecksums(entry); err != nil {
+							fmt.Fprintln(os.Stderr, color.YellowString("    - Warning: could not record checksums for %s: %v", name, err))
+						}
+						manifest.Dotfiles[name] = entry
+					}
+				}
+			}
+
+			// Execute post-link hooks for this specific dotfile if symlink was created successfully
+			postHookFailed := false
+			if postHooks, exists := cfg.Hooks.PostLink[name]; exists && len(postHooks) > 0 {
 				linkContext := &hooks.HookContext{
 					DotfileName: name,
 					SourcePath:  filepath.Join(cfg.DotfilesRepoPath, df.Source),
 					TargetPath:  df.Target,
 					DryRun:      dryRun,
 				}
-				if err := hooks.RunHooks(w, preHooks, hooks.PreLink, linkContext, dryRun); err != nil {
-					fmt.Fprintln(os.Stderr, color.RedString("Error executing pre-link hooks for %s: %v", name, err))
-					dotfilesSkippedOrFailed++
-					dfPhase.AddFail(name, fmt.Sprintf("pre-link hook: %v", err), err)
-					continue
+				if err := hooks.RunHooks(ctx, w, postHooks, hooks.PostLink, linkContext, dryRun); err != nil {
+					fmt.Fprintln(os.Stderr, color.YellowString("Warning: post-link hook for %s failed: %v", name, err))
+					dfPhase.AddWarn(name+"/post-hook", err.Error())
+					postHookFailed = true
 				}
 			}
+			if !postHookFailed && !inSync {
+				dfPhase.AddPending(name, "would apply")
+			} else if !postHookFailed {
+				dfPhase.AddChanged(name, appliedChange(change), "")
+			}
+		}
+	}
+	spinner.Stop()
+	printPhaseLine(dfPhase)
+	if manifestErr == nil && !dryRun {
+		if ctx.Err() == nil {
+			manifest.LastApply = &dotfile.ApplyRecord{Version: Version, ConfigHash: configHash, AppliedAt: time.Now()}
+		}
+		if err := dotfile.SaveManifest(manifest); err != nil {
+			fmt.Fprintln(os.Stderr, color.YellowString("Warning: could not save manifest: %v", err))
+		}
+	}
+	if dryRun {
+		fmt.Fprintln(w, "  Dotfiles processing (dry run): Inspect messages above for intended actions.")
+	} else {
+		fmt.Fprintf(w, "  Dotfiles processed: %s applied, %s skipped/failed.\n", color.GreenString("%d", dotfilesApplied), color.YellowString("%d", dotfilesSkippedOrFailed))
+	}
 
-			templateData := make(map[string]interface{})
-
-			var symlinkErr error
-			currentSourcePath := filepath.Join(cfg.DotfilesRepoPath, df.Source)
-			dotfileToSymlink := df
-			repoPathForSymlink := cfg.DotfilesRepoPath
+	applyBin(w, cfg, currentHost, rpt)
+	applyMacOSDefaults(w, cfg, currentHost, rpt)
+	applyVSCodeExtensions(w, cfg, currentHost, rpt)
+	applyServices(w, cfg, currentHost, rpt)
+	applyCron(w, cfg, currentHost, rpt)
+	if ctx.Err() != nil {
+		return stopInterrupted(rpt, cfg)
+	}
 
-			if df.IsTemplate {
-				fmt.Fprintf(w, "    %s\n", dim("template: "+df.Source))
-				var processedPath string
-				var templateErr error
-				if dryRun {
-					processedPath, templateErr = dotfile.WriteProcessedTemplateToFile(w, currentSourcePath, cfg, templateData, true)
-					if templateErr == nil && processedPath == "" { // dry run specific path
-						processedPath = "/tmp/fake_processed_template_for_dry_run" // ensure it has a value for dry run
-					}
-				} else {
-					processedPath, templateErr = dotfile.WriteProcessedTemplateToFile(w, currentSourcePath, cfg, templateData, false)
-				}
+	fmt.Fprintln(w, "\nProcessing shell configurations...")
+	shellPhase := rpt.AddPhase("Shell config")
+	shells, resolved := configuredShells(cfg)
+	if !resolved {
+		// Fallback to all shells means we couldn't determine a single shell
+		fmt.Fprintln(os.Stderr, color.YellowString("Could not determine current shell. Skipping shell configuration."))
+		shellPhase.AddSkip("shell", "could not determine shell")
+	} else {
+		for _, sh := range shells {
+			applyShell(w, cfg, sh, shells, currentHost, shellPhase)
+		}
+	}
+	printPhaseLine(shellPhase)
+	if ctx.Err() != nil {
+		return stopInterrupted(rpt, cfg)
+	}
 
-				if templateErr != nil {
-					fmt.Fprintln(os.Stderr, color.YellowString("    - Warning: Error processing template for %s: %v", name, templateErr))
-					dotfilesSkippedOrFailed++
-					dfPhase.AddWarn(name, fmt.Sprintf("template error: %v", templateErr))
-					continue
-				}
-				dotfileToSymlink.Source = processedPath
-				repoPathForSymlink = "" // Processed template is an absolute path
+	// Tool management in apply (TODO based on config)
+	toolPhase := rpt.AddPhase("Tools")
+	if len(cfg.Tools) > 0 {
+		fmt.Fprintln(w, "\nChecking tool configurations (installation not performed by apply):")
+		checker := tool.NewChecker(refreshTools)
+		for _, t := range cfg.Tools {
+			if !config.IsEnabled(t.Enable) {
+				fmt.Fprintf(w, "  Skipping tool: %s (disabled)\n", t.Name)
+				toolPhase.AddSkip(t.Name, "disabled")
+				continue
 			}
-
-			// Determine action based on action field
-			switch df.Action {
-			case "copy":
-				symlinkErr = dotfile.CopyFile(w, dotfileToSymlink, repoPathForSymlink, symlinkAction, dryRun)
-			case "symlink_dir":
-				symlinkErr = dotfile.CreateDirSymlink(w, dotfileToSymlink, repoPathForSymlink, symlinkAction, dryRun)
-			default:
-				// Default to regular symlink
-				symlinkErr = dotfile.CreateSymlink(w, dotfileToSymlink, repoPathForSymlink, symlinkAction, dryRun)
+			if !config.ShouldApplyForHost(t.Hosts, currentHost) {
+				fmt.Fprintf(w, "  Skipping tool: %s (host filter)\n", t.Name)
+				toolPhase.AddSkip(t.Name, "host filter")
+				continue
+			}
+			var statusColor func(format string, a ...interface{}) string
+			status := "Not installed"
+			installed := checker.Installed(t.CheckCommand)
+			if installed {
+				status = "Installed"
+				statusColor = color.GreenString
+				toolPhase.AddOK(t.Name, "installed")
+			} else {
+				statusColor = color.YellowString
+				toolPhase.AddWarn(t.Name, "not installed")
 			}
+			fmt.Fprintf(w, "  - Tool '%s': %s. Install hint: %s\n", t.Name, statusColor(status), t.InstallHint)
+			applyToolConfigFiles(w, cfg, t, installed, symlinkAction, toolPhase)
+		}
+		if err := checker.Save(); err != nil {
+			fmt.Fprintln(os.Stderr, color.YellowString("Warning: could not save tool check cache: %v", err))
+		}
+		printPhaseLine(toolPhase)
+	}
 
-			// Cleanup for templated files
-			if df.IsTemplate && repoPathForSymlink == "" && !dryRun && dotfileToSymlink.Source != "/tmp/fake_processed_template_for_dry_run" {
-				// Check if the source is in a temp-like directory before removing
-				// This is a basic check; for more robust checks, consider if WriteProcessedTemplateToFile returns if it's a temp file.
-				if strings.HasPrefix(dotfileToSymlink.Source, os.TempDir()) || strings.Contains(dotfileToSymlink.Source, "ralph-temp-") {
-					if removeErr := os.Remove(dotfileToSymlink.Source); removeErr != nil {
-						fmt.Fprintln(os.Stderr, color.YellowString("    - Warning: failed to remove temporary processed file %s: %v", dotfileToSymlink.Source, removeErr))
+	// Execute build hooks
+	if len(cfg.Hooks.Builds) > 0 || specificBuild != "" {
+		buildPhase := rpt.AddPhase("Builds")
+		buildOpts := hooks.BuildOptions{
+			DryRun:        dryRun,
+			Force:         forceBuilds,
+			SpecificBuild: specificBuild,
+			KeepGoing:     keepGoing,
+			Stream:        verbose,
+		}
+		for _, name := range sortedKeys(cfg.Hooks.Builds) {
+			if specificBuild != "" && name != specificBuild {
+				continue
+			}
+			if build := cfg.Hooks.Builds[name]; !config.IsEnabled(build.Enable) {
+				buildPhase.AddSkip(name, "disabled")
+			} else if !config.ShouldApplyForHost(build.Hosts, currentHost) {
+				buildPhase.AddSkip(name, "host filter")
+			}
+		}
+		spinner.Start(fmt.Sprintf("Builds (%d)", len(cfg.Hooks.Builds)))
+		err := hooks.RunBuilds(ctx, w, cfg.Hooks.Builds, currentHost, buildOpts)
+		spinner.Stop()
+		if err != nil {
+			printBuildOutput(hooks.BuildErrors(err))
+			fmt.Fprintln(os.Stderr, color.RedString("Error executing builds: %v", err))
+			addFailures(buildPhase, "builds", err)
+		} else {
+			buildPhase.AddOK("builds", "completed")
+			if dryRun {
+				for _, op := range pl.Pending() {
+					if op.Kind == config.KindBuild && cfg.Hooks.Builds[op.Name].Run != "always" {
+						buildPhase.AddPending(op.Name, "would run")
 					}
 				}
 			}
+		}
+		printPhaseLine(buildPhase)
+	}
 
-			if symlinkErr != nil {
-				fmt.Fprintln(os.Stderr, color.RedString("    error: %s: %v", name, symlinkErr))
-				dotfilesSkippedOrFailed++
-				dfPhase.AddFail(name, symlinkErr.Error(), symlinkErr)
-			} else {
-				if !dryRun { // only count as applied if not dry run
-					dotfilesApplied++
-				}
+	if ctx.Err() != nil {
+		return stopInterrupted(rpt, cfg)
+	}
 
-				// Execute post-link hooks for this specific dotfile if symlink was created successfully
-				postHookFailed := false
-				if postHooks, exists := cfg.Hooks.PostLink[name]; exists && len(postHooks) > 0 {
-					linkContext := &hooks.HookContext{
-						DotfileName: name,
-						SourcePath:  filepath.Join(cfg.DotfilesRepoPath, df.Source),
-						TargetPath:  df.Target,
-						DryRun:      dryRun,
-					}
-					if err := hooks.RunHooks(w, postHooks, hooks.PostLink, linkContext, dryRun); err != nil {
-						fmt.Fprintln(os.Stderr, color.YellowString("Warning: post-link hook for %s failed: %v", name, err))
-						dfPhase.AddWarn(name+"/post-hook", err.Error())
-						postHookFailed = true
-					}
-				}
-				if !postHookFailed {
-					dfPhase.AddOK(name, "")
-				}
-			}
+	verifyItems(ctx, w, cfg, currentHost, rpt)
+	if ctx.Err() != nil {
+		return stopInterrupted(rpt, cfg)
+	}
+
+	// Execute post-apply hooks
+	if len(cfg.Hooks.PostApply) > 0 {
+		postPhase := rpt.AddPhase("Post-apply hooks")
+		postContext := &hooks.HookContext{
+			DryRun: dryRun,
 		}
-		if dryRun {
-			fmt.Fprintln(w, "  Dotfiles processing (dry run): Inspect messages above for intended actions.")
+		if err := hooks.RunGroups(ctx, w, cfg.Hooks.PostApply, hooks.PostApply, postContext, dryRun); err != nil {
+			fmt.Fprintln(os.Stderr, color.YellowString("Warning: post-apply hooks failed: %v", err))
+			postPhase.AddWarn("post-apply", err.Error())
 		} else {
-			fmt.Fprintf(w, "  Dotfiles processed: %s applied, %s skipped/failed.\n", color.GreenString("%d", dotfilesApplied), color.YellowString("%d", dotfilesSkippedOrFailed))
+			postPhase.AddOK("post-apply", "completed")
 		}
+		printPhaseLine(postPhase)
+	}
+	if ctx.Err() != nil {
+		return stopInterrupted(rpt, cfg)
+	}
 
-		fmt.Fprintln(w, "\nProcessing shell configurations...")
-		shellPhase := rpt.AddPhase("Shell config")
-		resolvedShells := shell.ResolveShell(cfg.Shell.Name)
-		currentShell := resolvedShells[0]
-		if len(resolvedShells) > 1 {
-			// Fallback to all shells means we couldn't determine a single shell
-			fmt.Fprintln(os.Stderr, color.YellowString("Could not determine current shell. Skipping shell configuration."))
-			shellPhase.AddSkip("shell", "could not determine shell")
+	// A --host preview is not this machine's apply
+	if cfg.Inventory && config.HostOverride == "" {
+		recordMachine(w, cfg, currentHost, rpt)
+	}
+
+	// Commit (and push) changes in the dotfiles repo
+	if cfg.Git.AutoCommit {
+		gitPhase := rpt.AddPhase("Git")
+		if rpt.HasFailures() {
+			fmt.Fprintln(w, "\nSkipping auto-commit: apply had failures.")
+			gitPhase.AddSkip("auto-commit", "apply had failures")
 		} else {
-			fmt.Fprintf(w, "  Detected shell: %s\n", currentShell)
-			var aliasFile, funcFile string
-			var genErr error
+			fmt.Fprintln(w, "\nCommitting dotfiles repo changes...")
+			committed, err := repo.CommitChanges(ctx, w, cfg.DotfilesRepoPath, cfg.Git, dryRun)
+			switch {
+			case err != nil:
+				fmt.Fprintln(os.Stderr, color.RedString("Error committing dotfiles repo: %v", err))
+				gitPhase.AddFail("auto-commit", err.Error(), err)
+			case committed && dryRun:
+				gitPhase.AddPending("auto-commit", "would commit repo changes")
+			case committed:
+				gitPhase.AddOK("auto-commit", "committed")
+			default:
+				gitPhase.AddOK("auto-commit", "nothing to commit")
+			}
+		}
+		printPhaseLine(gitPhase)
+	}
 
-			aliasFile, funcFile, genErr = shell.GenerateShellConfigs(w, cfg, currentShell, dryRun)
+	fmt.Println("") // Add a newline for spacing
+	if dryRun {
+		color.Cyan("DRY RUN: Ralph apply finished. No actual changes were made.")
+	} else {
+		color.Green("Ralph apply complete.")
+	}
 
-			if genErr != nil {
-				fmt.Fprintln(os.Stderr, color.RedString("  Error generating shell configs for %s: %v", currentShell, genErr))
-				shellPhase.AddFail(string(currentShell), fmt.Sprintf("generate configs: %v", genErr), genErr)
-			} else {
-				linesToSource := []string{}
-				if aliasFile != "" && (len(cfg.Shell.Aliases) > 0 || (dryRun && aliasFile != "")) {
-					linesToSource = append(linesToSource, fmt.Sprintf("source %s", toPortablePath(aliasFile)))
-				}
-				if funcFile != "" && (len(cfg.Shell.Functions) > 0 || (dryRun && funcFile != "")) {
-					linesToSource = append(linesToSource, fmt.Sprintf("source %s", toPortablePath(funcFile)))
-				}
+	printApplySummary(rpt, cfg)
+	switch {
+	case ctx.Err() != nil:
+		return exitcode.Interrupted
+	case dryRun:
+		return rpt.DryRunExitCode()
+	}
+	return rpt.ExitCode()
+}
 
-				if len(linesToSource) > 0 {
-					fmt.Fprintf(w, "  Injecting source lines into %s rc file...\n", currentShell)
-					if err := shell.InjectSourceLines(w, currentShell, linesToSource, dryRun); err != nil {
-						fmt.Fprintln(os.Stderr, color.RedString("  Error injecting source lines into %s rc file: %v", currentShell, err))
-						shellPhase.AddFail(string(currentShell), fmt.Sprintf("inject source lines: %v", err), err)
-					} else {
-						shellPhase.AddOK(string(currentShell), "")
-					}
-				} else {
-					fmt.Fprintln(w, "  No shell aliases or functions configured to source.")
-					shellPhase.AddOK(string(currentShell), "no aliases/functions to source")
-				}
-			}
+// stopInterrupted ends an interrupted apply: the phases not started yet are
+// left out, and the summary shows what did run.
+func stopInterrupted(rpt *report.Report, cfg *config.Config) int {
+	fmt.Println()
+	color.Yellow("Ralph apply interrupted: the remaining steps were not started.")
+	printApplySummary(rpt, cfg)
+	return exitcode.Interrupted
+}
+
+// recipeItemKinds maps the apply phases whose steps are named after config
+// items to the kind of those items.
+var recipeItemKinds = map[string]string{
+	"Directories":  config.KindDirectory,
+	"Repositories": config.KindRepo,
+	"Dotfiles":     config.KindDotfile,
+	"Tools":        config.KindTool,
+	"Builds":       config.KindBuild,
+}
+
+// printApplySummary prints the apply summary, grouped by recipe with
+// --by-recipe.
+func printApplySummary(rpt *report.Report, cfg *config.Config) {
+	if !applyByRecipe {
+		rpt.PrintSummary(os.Stdout, summaryVerbosity())
+		return
+	}
+	rpt.SetRecipes(func(phase, name string) string {
+		kind, ok := recipeItemKinds[phase]
+		if !ok {
+			return ""
 		}
+		return config.OriginOf(cfg, kind, name).Recipe
+	})
+	rpt.PrintByRecipe(os.Stdout, summaryVerbosity())
+}
 
-		// Tool management in apply (TODO based on config)
-		toolPhase := rpt.AddPhase("Tools")
-		if len(cfg.Tools) > 0 {
-			fmt.Fprintln(w, "\nChecking tool configurations (installation not performed by apply):")
-			for _, t := range cfg.Tools {
-				if !config.IsEnabled(t.Enable) {
-					fmt.Fprintf(w, "  Skipping tool: %s (disabled)\n", t.Name)
-					toolPhase.AddSkip(t.Name, "disabled")
-					continue
-				}
-				if !config.ShouldApplyForHost(t.Hosts, currentHost) {
-					fmt.Fprintf(w, "  Skipping tool: %s (host filter)\n", t.Name)
-					toolPhase.AddSkip(t.Name, "host filter")
-					continue
-				}
-				var statusColor func(format string, a ...interface{}) string
-				status := "Not installed"
-				if tool.CheckStatus(t.CheckCommand) {
-					status = "Installed"
-					statusColor = color.GreenString
-					toolPhase.AddOK(t.Name, "installed")
-				} else {
-					statusColor = color.YellowString
-					toolPhase.AddWarn(t.Name, "not installed")
-				}
-				fmt.Fprintf(w, "  - Tool '%s': %s. Install hint: %s\n", t.Name, statusColor(status), t.InstallHint)
+// verifyItems runs the verify commands of the dotfiles, builds and tools
+// active on this host. A failing dotfile or build verification is a failure;
+// a failing tool verification is a warning, since apply does not install
+// tools. Nothing runs in a dry run.
+func verifyItems(ctx context.Context, w io.Writer, cfg *config.Config, currentHost string, rpt *report.Report) {
+	type verification struct {
+		id, command, dir string
+		warnOnly         bool
+	}
+	var checks []verification
+	for _, name := range sortedKeys(cfg.Dotfiles) {
+		df := cfg.Dotfiles[name]
+		if df.Verify != "" && config.IsEnabled(df.Enable) && config.ShouldApplyForHost(df.Hosts, currentHost) {
+			checks = append(checks, verification{id: config.KindDotfile + ":" + name, command: df.Verify})
+		}
+	}
+	for _, name := range sortedKeys(cfg.Hooks.Builds) {
+		build := cfg.Hooks.Builds[name]
+		if build.Verify == "" || !config.IsEnabled(build.Enable) || !config.ShouldApplyForHost(build.Hosts, currentHost) {
+			continue
+		}
+		dir := ""
+		if build.WorkingDir != "" {
+			expanded, err := config.ExpandPath(build.WorkingDir)
+			if err != nil {
+				expanded = build.WorkingDir
 			}
+			dir = expanded
+		}
+		checks = append(checks, verification{id: config.KindBuild + ":" + name, command: build.Verify, dir: dir})
+	}
+	for _, t := range cfg.Tools {
+		if t.Verify != "" && config.IsEnabled(t.Enable) && config.ShouldApplyForHost(t.Hosts, currentHost) {
+			checks = append(checks, verification{id: config.KindTool + ":" + t.Name, command: t.Verify, warnOnly: true})
 		}
+	}
+	if len(checks) == 0 {
+		return
+	}
+
+	fmt.Fprintln(w, "\nRunning verify commands...")
+	if dryRun {
+		for _, c := range checks {
+			fmt.Fprintf(w, "  %s would verify %s: %s\n", color.CyanString("[dry run]"), c.id, c.command)
+		}
+		return
+	}
+	verifyPhase := rpt.AddPhase("Verify")
+	for _, c := range checks {
+		fmt.Fprintf(w, "  %s: %s\n", color.New(color.Bold).Sprint(c.id), c.command)
+		err := hooks.Verify(ctx, c.command, c.dir)
+		switch {
+		case err == nil:
+			verifyPhase.AddOK(c.id, "")
+		case c.warnOnly:
+			fmt.Fprintln(os.Stderr, color.YellowString("  Warning: %s: %v", c.id, err))
+			verifyPhase.AddWarn(c.id, err.Error())
+		default:
+			fmt.Fprintln(os.Stderr, color.RedString("  Error: %s: %v", c.id, err))
+			verifyPhase.AddFail(c.id, err.Error(), err)
+		}
+	}
+	printPhaseLine(verifyPhase)
+}
 
-		// Execute build hooks
-		if len(cfg.Hooks.Builds) > 0 || specificBuild != "" {
-			buildPhase := rpt.AddPhase("Builds")
-			buildOpts := hooks.BuildOptions{
-				DryRun:        dryRun,
-				Force:         forceBuilds,
-				SpecificBuild: specificBuild,
+// recordMachine writes this host's inventory record into the dotfiles repo,
+// before auto-commit so the record is committed with the rest.
+func recordMachine(w io.Writer, cfg *config.Config, currentHost string, rpt *report.Report) {
+	m := inventory.Machine{
+		Host:      currentHost,
+		Version:   Version,
+		OS:        runtime.GOOS + "/" + runtime.GOARCH,
+		LastApply: time.Now().UTC().Truncate(time.Second),
+		Recipes:   []string{},
+	}
+	for _, info := range cfg.LoadedRecipes {
+		name := info.Name
+		if name == "" {
+			name = info.Dir
+		}
+		m.Recipes = append(m.Recipes, name)
+	}
+	for _, p := range rpt.Phases {
+		for _, step := range p.Steps {
+			if step.Status == report.StatusFail {
+				m.Failures = append(m.Failures, fmt.Sprintf("%s: %s: %s", p.Name, step.Name, step.Message))
 			}
-			if err := hooks.RunBuilds(w, cfg.Hooks.Builds, currentHost, buildOpts); err != nil {
-				fmt.Fprintln(os.Stderr, color.RedString("Error executing builds: %v", err))
-				buildPhase.AddFail("builds", err.Error(), err)
+		}
+	}
+
+	if err := inventory.Write(w, cfg.DotfilesRepoPath, m, dryRun); err != nil {
+		fmt.Fprintln(os.Stderr, color.YellowString("Warning: could not record this machine: %v", err))
+		rpt.AddPhase("Inventory").AddWarn(currentHost, err.Error())
+	}
+}
+
+// carryOverAliases moves manifest entries and build state recorded under a
+// dotfile's or build's aliases to its current name.
+func carryOverAliases(w io.Writer, cfg *config.Config, rpt *report.Report) {
+	var phase *report.Phase
+	record := func(what string, renames []config.Rename) {
+		if phase == nil {
+			phase = rpt.AddPhase("Renames")
+		}
+		for _, r := range renames {
+			msg := fmt.Sprintf("%s of '%s'", what, r.From)
+			if dryRun {
+				fmt.Fprintf(w, "[DRY RUN] Would move the %s to '%s'\n", msg, r.To)
+				phase.AddPending(r.To, "would take over the "+msg)
 			} else {
-				buildPhase.AddOK("builds", "completed")
+				fmt.Fprintf(w, "Moved the %s to '%s'\n", msg, r.To)
+				phase.AddOK(r.To, "took over the "+msg)
 			}
 		}
+	}
 
-		// Execute post-apply hooks
-		if len(cfg.Hooks.PostApply) > 0 {
-			postPhase := rpt.AddPhase("Post-apply hooks")
-			postContext := &hooks.HookContext{
-				DryRun: dryRun,
+	if manifest, err := dotfile.LoadManifest(); err != nil {
+		fmt.Fprintln(os.Stderr, color.YellowString("Warning: could not load manifest: %v", err))
+	} else if renames := config.CarryOver(manifest.Dotfiles, config.DotfileAliases(cfg)); len(renames) > 0 {
+		record("manifest entry", renames)
+		if !dryRun {
+			if err := dotfile.SaveManifest(manifest); err != nil {
+				fmt.Fprintln(os.Stderr, color.YellowString("Warning: could not save manifest: %v", err))
 			}
-			if err := hooks.RunHooks(w, cfg.Hooks.PostApply, hooks.PostApply, postContext, dryRun); err != nil {
-				fmt.Fprintln(os.Stderr, color.YellowString("Warning: post-apply hooks failed: %v", err))
-				postPhase.AddWarn("post-apply", err.Error())
-			} else {
-				postPhase.AddOK("post-apply", "completed")
+		}
+	}
+
+	if state, err := hooks.LoadBuildState(); err != nil {
+		fmt.Fprintln(os.Stderr, color.YellowString("Warning: could not load build state: %v", err))
+	} else if renames := config.CarryOver(state.Builds, config.BuildAliases(cfg)); len(renames) > 0 {
+		record("build state", renames)
+		if !dryRun {
+			if err := hooks.SaveBuildState(state); err != nil {
+				fmt.Fprintln(os.Stderr, color.YellowString("Warning: could not save build state: %v", err))
+			}
+		}
+	}
+
+	if phase != nil {
+		printPhaseLine(phase)
+	}
+}
+
+// sortedKeys returns the keys of m in sorted order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// applyBin deploys the [bin] scripts and prunes the ones that are gone from
+// the repo, or all of them once [bin] is disabled or filtered out.
+func applyBin(w io.Writer, cfg *config.Config, currentHost string, rpt *report.Report) {
+	state, err := dotfile.LoadBinState()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, color.YellowString("Warning: could not load bin state: %v", err))
+		return
+	}
+	if !cfg.Bin.Active(currentHost) && len(state.Scripts) == 0 {
+		return
+	}
+
+	fmt.Fprintln(w, "\nProcessing bin scripts...")
+	binPhase := rpt.AddPhase("Bin")
+	var keep []string
+	if cfg.Bin.Active(currentHost) {
+		scripts, err := dotfile.BinScripts(cfg)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, color.RedString("  Error: %v", err))
+			binPhase.AddFail("bin", err.Error(), err)
+			printPhaseLine(binPhase)
+			return
+		}
+		for _, name := range scripts {
+			fmt.Fprintf(w, "  %s\n", color.New(color.Bold).Sprint(name))
+			changed, err := dotfile.DeployBinScript(w, cfg, state, name, dryRun)
+			switch {
+			case err != nil:
+				fmt.Fprintln(os.Stderr, color.RedString("    error: %s: %v", name, err))
+				binPhase.AddFail(name, err.Error(), err)
+			case changed && dryRun:
+				binPhase.AddPending(name, "would deploy")
+			case changed:
+				binPhase.AddChanged(name, report.ChangeUpdated, "deployed")
+			default:
+				binPhase.AddChanged(name, report.ChangeUnchanged, "")
 			}
+			keep = append(keep, name)
 		}
+	}
 
-		fmt.Println("") // Add a newline for spacing
+	pruned, err := dotfile.PruneBinScripts(w, state, keep, dryRun)
+	for _, name := range pruned {
 		if dryRun {
-			color.Cyan("DRY RUN: Ralph apply finished. No actual changes were made.")
+			binPhase.AddPending(name, "would remove")
 		} else {
-			color.Green("Ralph apply complete.")
+			binPhase.AddChanged(name, report.ChangeUpdated, "removed")
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, color.RedString("  Error pruning bin scripts: %v", err))
+		binPhase.AddFail("prune", err.Error(), err)
+	}
+	if !dryRun {
+		if err := dotfile.SaveBinState(state); err != nil {
+			fmt.Fprintln(os.Stderr, color.YellowString("Warning: could not save bin state: %v", err))
 		}
+	}
+	printPhaseLine(binPhase)
+}
 
-		rpt.PrintSummary(os.Stdout, summaryVerbosity())
-		os.Exit(rpt.ExitCode())
-	},
+// applyMacOSDefaults writes the [macos.defaults] entries that differ from
+// their desired value. On other systems the entries are skipped.
+func applyMacOSDefaults(w io.Writer, cfg *config.Config, currentHost string, rpt *report.Report) {
+	if len(cfg.MacOS.Defaults) == 0 {
+		return
+	}
+
+	fmt.Fprintln(w, "\nProcessing macOS defaults...")
+	defaultsPhase := rpt.AddPhase("macOS defaults")
+	if !macos.Supported() {
+		fmt.Fprintln(w, "  Skipping macOS defaults (not running on macOS)")
+		defaultsPhase.AddSkip("defaults", "only applied on macOS")
+		printPhaseLine(defaultsPhase)
+		return
+	}
+
+	names := make([]string, 0, len(cfg.MacOS.Defaults))
+	for name := range cfg.MacOS.Defaults {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		def := cfg.MacOS.Defaults[name]
+		if !config.IsEnabled(def.Enable) {
+			fmt.Fprintf(w, "  Skipping default: %s (disabled)\n", name)
+			defaultsPhase.AddSkip(name, "disabled")
+			continue
+		}
+		if !config.ShouldApplyForHost(def.Hosts, currentHost) {
+			fmt.Fprintf(w, "  Skipping default: %s (host filter)\n", name)
+			defaultsPhase.AddSkip(name, "host filter")
+			continue
+		}
+		fmt.Fprintf(w, "  %s\n", color.New(color.Bold).Sprint(name))
+		changed, err := macos.ApplyDefault(w, def, dryRun)
+		switch {
+		case err != nil:
+			fmt.Fprintln(os.Stderr, color.RedString("    error: %s: %v", name, err))
+			defaultsPhase.AddFail(name, err.Error(), err)
+		case changed && dryRun:
+			defaultsPhase.AddPending(name, "would write")
+		case changed:
+			defaultsPhase.AddChanged(name, report.ChangeUpdated, "written")
+		default:
+			defaultsPhase.AddChanged(name, report.ChangeUnchanged, "")
+		}
+	}
+	printPhaseLine(defaultsPhase)
+}
+
+// applyVSCodeExtensions installs the [vscode] extensions that are missing.
+// Extensions installed outside the config are left alone.
+func applyVSCodeExtensions(w io.Writer, cfg *config.Config, currentHost string, rpt *report.Report) {
+	if !cfg.VSCode.Active(currentHost) {
+		return
+	}
+
+	fmt.Fprintln(w, "\nProcessing VS Code extensions...")
+	extPhase := rpt.AddPhase("VS Code extensions")
+	command := cfg.VSCode.CommandName()
+	if !vscode.Available(command) {
+		fmt.Fprintln(os.Stderr, color.YellowString("Warning: '%s' not found in PATH, skipping VS Code extensions", command))
+		extPhase.AddWarn(command, "not found in PATH")
+		printPhaseLine(extPhase)
+		return
+	}
+	installed, err := vscode.Installed(command)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, color.RedString("  Error: %v", err))
+		extPhase.AddFail(command, err.Error(), err)
+		printPhaseLine(extPhase)
+		return
+	}
+
+	missing, _ := vscode.Diff(cfg.VSCode.Extensions, installed)
+	fmt.Fprintf(w, "  %d configured, %d missing\n", len(cfg.VSCode.Extensions), len(missing))
+	for _, id := range missing {
+		fmt.Fprintf(w, "  %s\n", color.New(color.Bold).Sprint(id))
+		if err := vscode.Install(w, command, id, dryRun); err != nil {
+			fmt.Fprintln(os.Stderr, color.RedString("    error: %s: %v", id, err))
+			extPhase.AddFail(id, err.Error(), err)
+		} else if dryRun {
+			extPhase.AddPending(id, "would install")
+		} else {
+			extPhase.AddChanged(id, report.ChangeCreated, "installed")
+		}
+	}
+	if len(missing) == 0 {
+		extPhase.AddOK(command, "all extensions installed")
+	}
+	printPhaseLine(extPhase)
+}
+
+// serviceSkipReason returns why svc is not managed on currentHost, or "" if
+// it is. A service tied to a dotfile follows that dotfile's filters.
+func serviceSkipReason(cfg *config.Config, svc config.Service, currentHost string) string {
+	if !config.IsEnabled(svc.Enable) {
+		return "disabled"
+	}
+	if !config.ShouldApplyForHost(svc.Hosts, currentHost) {
+		return "host filter"
+	}
+	if svc.Dotfile != "" {
+		df := cfg.Dotfiles[svc.Dotfile]
+		if !config.IsEnabled(df.Enable) || !config.ShouldApplyForHost(df.Hosts, currentHost) {
+			return fmt.Sprintf("dotfile '%s' not applied on this host", svc.Dotfile)
+		}
+	}
+	return ""
+}
+
+// applyServices reloads systemd and enables (and starts) the [services]
+// units. It runs after dotfiles, so unit files they link are in place.
+func applyServices(w io.Writer, cfg *config.Config, currentHost string, rpt *report.Report) {
+	if len(cfg.Services) == 0 {
+		return
+	}
+
+	fmt.Fprintln(w, "\nProcessing systemd user services...")
+	svcPhase := rpt.AddPhase("Services")
+	if !services.Supported() {
+		fmt.Fprintln(w, "  Skipping services (systemctl not available)")
+		svcPhase.AddSkip("services", "systemd user units are only managed on Linux with systemctl")
+		printPhaseLine(svcPhase)
+		return
+	}
+
+	names := make([]string, 0, len(cfg.Services))
+	for name := range cfg.Services {
+		if reason := serviceSkipReason(cfg, cfg.Services[name], currentHost); reason != "" {
+			fmt.Fprintf(w, "  Skipping service: %s (%s)\n", name, reason)
+			svcPhase.AddSkip(name, reason)
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if len(names) == 0 {
+		printPhaseLine(svcPhase)
+		return
+	}
+
+	if err := services.DaemonReload(w, dryRun); err != nil {
+		fmt.Fprintln(os.Stderr, color.RedString("  Error: %v", err))
+		svcPhase.AddFail("daemon-reload", err.Error(), err)
+		printPhaseLine(svcPhase)
+		return
+	}
+	for _, name := range names {
+		svc := cfg.Services[name]
+		fmt.Fprintf(w, "  %s (%s)\n", color.New(color.Bold).Sprint(name), svc.Unit)
+		changed, err := services.Enable(w, svc.Unit, config.IsEnabled(svc.Start), dryRun)
+		switch {
+		case err != nil:
+			fmt.Fprintln(os.Stderr, color.RedString("    error: %s: %v", name, err))
+			svcPhase.AddFail(name, err.Error(), err)
+		case changed && dryRun:
+			svcPhase.AddPending(name, "would enable")
+		case changed:
+			svcPhase.AddChanged(name, report.ChangeUpdated, "enabled")
+		default:
+			svcPhase.AddChanged(name, report.ChangeUnchanged, "")
+		}
+	}
+	printPhaseLine(svcPhase)
+}
+
+// applyCron writes the [cron] jobs enabled on this host into the managed
+// block of the user crontab. Jobs removed from the config are dropped, and the
+// block is removed once no jobs are left.
+func applyCron(w io.Writer, cfg *config.Config, currentHost string, rpt *report.Report) {
+	if len(cfg.Cron) == 0 {
+		if !cron.Available() {
+			return
+		}
+		if found, err := cron.HasBlock(); err != nil || !found {
+			return
+		}
+	}
+
+	fmt.Fprintln(w, "\nProcessing cron jobs...")
+	cronPhase := rpt.AddPhase("Cron")
+	if !cron.Available() {
+		fmt.Fprintln(os.Stderr, color.YellowString("Warning: 'crontab' not found in PATH, skipping cron jobs"))
+		cronPhase.AddWarn("crontab", "not found in PATH")
+		printPhaseLine(cronPhase)
+		return
+	}
+
+	var jobs []cron.Job
+	for name, job := range cfg.Cron {
+		if !config.IsEnabled(job.Enable) {
+			fmt.Fprintf(w, "  Skipping cron job: %s (disabled)\n", name)
+			cronPhase.AddSkip(name, "disabled")
+			continue
+		}
+		if !config.ShouldApplyForHost(job.Hosts, currentHost) {
+			fmt.Fprintf(w, "  Skipping cron job: %s (host filter)\n", name)
+			cronPhase.AddSkip(name, "host filter")
+			continue
+		}
+		jobs = append(jobs, cron.Job{Name: name, Schedule: job.Schedule, Command: job.Command, Comment: job.Comment})
+	}
+
+	changed, err := cron.Sync(w, jobs, dryRun)
+	switch {
+	case err != nil:
+		fmt.Fprintln(os.Stderr, color.RedString("  Error: %v", err))
+		cronPhase.AddFail("crontab", err.Error(), err)
+	case changed && dryRun:
+		cronPhase.AddPending("crontab", fmt.Sprintf("would update (%d jobs)", len(jobs)))
+	case changed:
+		cronPhase.AddChanged("crontab", report.ChangeUpdated, fmt.Sprintf("%d jobs", len(jobs)))
+	default:
+		cronPhase.AddChanged("crontab", report.ChangeUnchanged, fmt.Sprintf("%d jobs", len(jobs)))
+	}
+	printPhaseLine(cronPhase)
+}
+
+// deployDotfile applies the action of df, handling an existing target as
+// existing (adjusted by ExistingFor). A template its action doesn't
+// render itself is rendered first, with templateData added to the template
+// data; templateErr is set if that fails, in which case nothing is applied.
+func deployDotfile(w io.Writer, cfg *config.Config, name string, df config.Dotfile, existing dotfile.SymlinkAction, templateData map[string]interface{}) (templateErr, err error) {
+	if templateData == nil {
+		templateData = make(map[string]interface{})
+	}
+	currentSourcePath := filepath.Join(cfg.DotfilesRepoPath, df.Source)
+	dotfileToSymlink := df
+	repoPathForSymlink := cfg.DotfilesRepoPath
+
+	if df.IsTemplate && !dotfile.RendersTemplates(df.Action) {
+		fmt.Fprintf(w, "    %s\n", color.New(color.Faint).Sprint("template: "+df.Source))
+		processedPath, err := dotfile.WriteProcessedTemplateToFileWithOptions(w, currentSourcePath, cfg, templateData, dotfile.TemplateOptionsFor(df, dryRun))
+		if err != nil {
+			return err, nil
+		}
+		if dryRun && processedPath == "" { // dry run specific path
+			processedPath = "/tmp/fake_processed_template_for_dry_run" // ensure it has a value for dry run
+		}
+		dotfileToSymlink.Source = processedPath
+		repoPathForSymlink = "" // Processed template is an absolute path
+	}
+
+	if action, lookupErr := dotfile.LookupAction(df.Action); lookupErr != nil {
+		err = lookupErr
+	} else {
+		err = action.Apply(w, dotfile.ApplyRequest{
+			Name:         name,
+			Dotfile:      dotfileToSymlink,
+			RepoPath:     repoPathForSymlink,
+			Config:       cfg,
+			TemplateData: templateData,
+			Existing:     dotfile.ExistingFor(df, existing),
+			DryRun:       dryRun,
+		})
+	}
+
+	// Cleanup for templated files
+	if df.IsTemplate && repoPathForSymlink == "" && !dryRun && dotfileToSymlink.Source != "/tmp/fake_processed_template_for_dry_run" {
+		// Check if the source is in a temp-like directory before removing
+		// This is a basic check; for more robust checks, consider if WriteProcessedTemplateToFile returns if it's a temp file.
+		if strings.HasPrefix(dotfileToSymlink.Source, os.TempDir()) || strings.Contains(dotfileToSymlink.Source, "ralph-temp-") {
+			if removeErr := os.Remove(dotfileToSymlink.Source); removeErr != nil {
+				fmt.Fprintln(os.Stderr, color.YellowString("    - Warning: failed to remove temporary processed file %s: %v", dotfileToSymlink.Source, removeErr))
+			}
+		}
+	}
+	return nil, err
+}
+
+// acquireApplyLock takes the apply lock, which every command that changes
+// the manifest or other state (apply, edit, uninstall, ui) holds so that they
+// don't overwrite each other's records. When the lock can't be taken it says
+// why and returns the exit code to use.
+func acquireApplyLock() (*lock.Lock, int) {
+	lockPath, err := config.StateFilePath(applyLockFileName)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, color.RedString("Error: %v", err))
+		return nil, exitcode.Failure
+	}
+	applyLock, err := lock.Acquire(lockPath)
+	var held *lock.HeldError
+	if errors.As(err, &held) {
+		if held.PID == 0 {
+			fmt.Fprintln(os.Stderr, color.RedString("Error: %s exists but records no PID; another ralph apply, edit, uninstall or ui may be starting. If none is, remove it.", config.ShortenHome(held.Path)))
+		} else {
+			fmt.Fprintln(os.Stderr, color.RedString("Error: another ralph apply, edit, uninstall or ui is running (pid %d). If it is not, remove %s.", held.PID, config.ShortenHome(held.Path)))
+		}
+		return nil, exitcode.LockHeld
+	} else if err != nil {
+		fmt.Fprintln(os.Stderr, color.RedString("Error: %v", err))
+		return nil, exitcode.Failure
+	}
+	return applyLock, exitcode.OK
+}
+
+// applySingleDotfile deploys one dotfile outside a full apply, as edit and
+// the ui dashboard do, backing up whatever is in the way.
+func applySingleDotfile(w io.Writer, cfg *config.Config, name string) error {
+	df, ok := cfg.Dotfiles[name]
+	if !ok {
+		return fmt.Errorf("dotfile '%s' not found in configuration", name)
+	}
+	templateErr, err := deployDotfile(w, cfg, name, df, dotfile.SymlinkActionBackup, nil)
+	if templateErr != nil {
+		return fmt.Errorf("template error: %w", templateErr)
+	}
+	return err
+}
+
+// applyToolConfigFiles applies the config files of t like dotfiles, whether
+// or not t is installed, recording a "<tool>:<target>" step in phase for
+// each. Templates can use .ToolName, .ToolInstalled and .ToolVersion.
+func applyToolConfigFiles(w io.Writer, cfg *config.Config, t config.Tool, installed bool, existing dotfile.SymlinkAction, phase *report.Phase) {
+	var data map[string]interface{}
+	for _, cf := range t.ConfigFiles {
+		name := t.Name + ":" + cf.Target
+		if !config.IsEnabled(cf.Enable) {
+			phase.AddSkip(name, "disabled")
+			continue
+		}
+		if cf.IsTemplate && data == nil {
+			data = tool.TemplateData(t, installed)
+		}
+		// Templates are checked without the tool data, so a dry run
+		// always renders them
+		change := ""
+		if !dryRun || !cf.IsTemplate {
+			change = plan.DotfileChange(cf, cfg)
+		}
+		if dryRun && change == plan.ChangeNone {
+			phase.AddChanged(name, report.ChangeUnchanged, "")
+			continue
+		}
+		fmt.Fprintf(w, "    %s → %s\n", cf.Target, cf.Source)
+		templateErr, err := deployDotfile(w, cfg, name, cf, existing, data)
+		switch {
+		case templateErr != nil:
+			fmt.Fprintln(os.Stderr, color.YellowString("    - Warning: Error processing template for %s: %v", name, templateErr))
+			phase.AddWarn(name, fmt.Sprintf("template error: %v", templateErr))
+		case err != nil:
+			fmt.Fprintln(os.Stderr, color.RedString("    error: %s: %v", name, err))
+			phase.AddFail(name, err.Error(), err)
+		case dryRun:
+			phase.AddPending(name, "would apply")
+		default:
+			phase.AddChanged(name, appliedChange(change), "")
+		}
+	}
+}
+
+// configuredShells returns the shells apply configures (see
+// shell.ResolveShells), plus every supported shell installed on this machine
+// with shell.detect. ok is false when there is no shell to configure.
+func configuredShells(cfg *config.Config) (shells []shell.SupportedShell, ok bool) {
+	shells, ok = shell.ResolveShells(cfg.Shell.Names, cfg.Shell.Name)
+	if !cfg.Shell.Detect {
+		return shells, ok
+	}
+	if !ok {
+		shells = nil
+	}
+	shells = shell.WithInstalled(shells)
+	return shells, len(shells) > 0
+}
+
+// applyShell generates the alias and function files for sh and sources them
+// from its rc file, along with the zsh plugins for zsh, recording a step
+// named after the shell in phase. shells are all the shells configured in
+// this apply.
+func applyShell(w io.Writer, cfg *config.Config, sh shell.SupportedShell, shells []shell.SupportedShell, currentHost string, phase *report.Phase) {
+	fmt.Fprintf(w, "  Shell: %s\n", sh)
+	aliasFile, funcFile, genErr := shell.GenerateShellConfigs(w, cfg, sh, dryRun)
+	if genErr != nil {
+		fmt.Fprintln(os.Stderr, color.RedString("  Error generating shell configs for %s: %v", sh, genErr))
+		phase.AddFail(string(sh), fmt.Sprintf("generate configs: %v", genErr), genErr)
+		return
+	}
+
+	linesToSource := []string{}
+	if aliasFile != "" && (len(cfg.Shell.Aliases) > 0 || (dryRun && aliasFile != "")) {
+		linesToSource = append(linesToSource, fmt.Sprintf("source %s", toPortablePath(aliasFile)))
+	}
+	if funcFile != "" && (len(cfg.Shell.Functions) > 0 || cfg.Shell.HelpFunction != "" || (dryRun && funcFile != "")) {
+		linesToSource = append(linesToSource, fmt.Sprintf("source %s", toPortablePath(funcFile)))
+	}
+	if sh == shell.Zsh {
+		if _, err := shell.LinkCompletions(w, cfg, dryRun); err != nil {
+			fmt.Fprintln(os.Stderr, color.RedString("  Error linking completions: %v", err))
+			phase.AddFail("completions", err.Error(), err)
+		}
+		if dir, err := shell.GetCompletionsDir(); err == nil {
+			linesToSource = append(shell.CompletionLines(cfg, toPortablePath(dir)), linesToSource...)
+		}
+		// Plugins load last, after aliases and functions they may wrap
+		plugins, err := shell.ZshPluginFiles(cfg, currentHost)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, color.RedString("  Error finding zsh plugins: %v", err))
+			phase.AddFail("plugins", err.Error(), err)
+		}
+		for _, file := range plugins {
+			linesToSource = append(linesToSource, fmt.Sprintf("source %s", toPortablePath(file)))
+		}
+	} else if !shell.ContainsShell(shells, shell.Zsh) {
+		if len(cfg.Shell.Completions.Paths) > 0 {
+			fmt.Fprintf(w, "  Skipping [shell.completions]: only supported for zsh, not %s\n", sh)
+			phase.AddSkip("completions", "only supported for zsh")
+		}
+		if len(cfg.Shell.Zsh.Plugins) > 0 {
+			fmt.Fprintf(w, "  Skipping [shell.zsh.plugins]: not configuring zsh\n")
+			phase.AddSkip("plugins", "only supported for zsh")
+		}
+	}
+	if len(cfg.Shell.Fish.Universal) > 0 {
+		if sh == shell.Fish {
+			applyFishUniversal(w, cfg, currentHost, phase)
+		} else if !shell.ContainsShell(shells, shell.Fish) {
+			fmt.Fprintf(w, "  Skipping [shell.fish.universal]: not configuring fish\n")
+			phase.AddSkip("fish universal", "only supported for fish")
+		}
+	}
+
+	if len(linesToSource) == 0 {
+		fmt.Fprintln(w, "  No shell aliases or functions configured to source.")
+		phase.AddOK(string(sh), "no aliases/functions to source")
+		return
+	}
+	fmt.Fprintf(w, "  Injecting source lines into %s rc file...\n", sh)
+	if err := shell.InjectSourceLines(w, sh, linesToSource, dryRun); err != nil {
+		fmt.Fprintln(os.Stderr, color.RedString("  Error injecting source lines into %s rc file: %v", sh, err))
+		phase.AddFail(string(sh), fmt.Sprintf("inject source lines: %v", err), err)
+	} else if dryRun && !shellInSync(cfg, sh, linesToSource) {
+		phase.AddPending(string(sh), "would update generated files or rc file")
+	} else {
+		phase.AddOK(string(sh), "")
+	}
+}
+
+// applyFishUniversal sets the [shell.fish.universal] variables whose value
+// differs, recording a step per variable set and one for those already up to
+// date.
+func applyFishUniversal(w io.Writer, cfg *config.Config, currentHost string, phase *report.Phase) {
+	vars, err := shell.ResolveFishUniversal(cfg, currentHost)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, color.RedString("  Error resolving fish universal variables: %v", err))
+		phase.AddFail("fish universal", err.Error(), err)
+		return
+	}
+	changes, unchanged, err := shell.ApplyFishUniversal(w, vars, dryRun)
+	for _, c := range changes {
+		switch {
+		case dryRun:
+			phase.AddPending("fish:"+c.Name, "would set -U")
+		case c.Created:
+			phase.AddChanged("fish:"+c.Name, report.ChangeCreated, "set -U")
+		default:
+			phase.AddChanged("fish:"+c.Name, report.ChangeUpdated, "set -U")
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, color.RedString("  Error applying fish universal variables: %v", err))
+		phase.AddFail("fish universal", err.Error(), err)
+	}
+	if unchanged > 0 {
+		phase.AddChanged("fish universal", report.ChangeUnchanged, fmt.Sprintf("%d already set", unchanged))
+	}
+}
+
+// shellInSync reports whether the generated shell files, the linked zsh
+// completions and the rc file managed block are already up to date for sh.
+func shellInSync(cfg *config.Config, sh shell.SupportedShell, linesToSource []string) bool {
+	if ok, err := shell.GeneratedConfigsInSync(cfg, sh); err != nil || !ok {
+		return false
+	}
+	if sh == shell.Zsh {
+		if ok, err := shell.CompletionsInSync(cfg); err != nil || !ok {
+			return false
+		}
+	}
+	ok, err := shell.SourceLinesInSync(sh, linesToSource)
+	return err == nil && ok
 }
 
 func init() {
@@ -383,11 +1368,99 @@ func init() {
 	applyCmd.Flags().BoolVar(&forceBuilds, "force", false, "Force re-run of 'once' builds even if previously completed")
 	applyCmd.Flags().StringVar(&specificBuild, "build", "", "Run only the specified build (works with 'manual' builds too)")
 	applyCmd.Flags().BoolVar(&resetBuilds, "reset-builds", false, "Clear all build state before running")
+	applyCmd.Flags().BoolVar(&keepGoing, "keep-going", false, "Continue past failing hooks, repos and builds; failures are reported and the exit code is non-zero")
+	applyCmd.Flags().BoolVar(&refreshTools, "refresh-tools", false, "Re-run every tool check_command instead of using cached results")
+	applyCmd.Flags().BoolVar(&applyByRecipe, "by-recipe", false, "Group the summary by the recipe that contributed each item")
+	applyCmd.Flags().StringVar(&applyOutput, "output", "text", "Output format: text, or json to print the plan of a --dry-run")
 	// Note: --overwrite and --skip are mutually exclusive in behavior.
 	// Cobra doesn't enforce this directly, would need custom validation or be handled by logic choosing one if both true.
 	// Current logic: if overwrite is true, it takes precedence over skip.
 }
 
+// planOptions returns the plan options set by the apply flags.
+func planOptions() plan.Options {
+	return plan.Options{ForceBuilds: forceBuilds, SpecificBuild: specificBuild}
+}
+
+// appliedChange returns the report change for an item applied with the plan
+// change ("" when not known, e.g. in a dry run).
+func appliedChange(change string) string {
+	switch change {
+	case plan.ChangeCreate:
+		return report.ChangeCreated
+	case plan.ChangeUpdate:
+		return report.ChangeUpdated
+	case plan.ChangeNone:
+		return report.ChangeUnchanged
+	}
+	return ""
+}
+
+// planned reports whether pl has a pending operation for the named item.
+func planned(pl *plan.Plan, kind, name string) bool {
+	op, ok := pl.Lookup(kind, name)
+	return ok && op.Pending()
+}
+
+// printPlanJSON prints the plan of a dry run as JSON and returns the dry-run
+// exit code: 0 when nothing would change, 1 if the state of an item could not
+// be determined and 2 when changes are pending.
+func printPlanJSON() int {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, color.RedString("Error loading configuration: %v", err))
+		return exitcode.Failure
+	}
+	pl := plan.Build(cfg, config.GetCurrentHost(), planOptions())
+	if err := pl.WriteJSON(os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, color.RedString("Error: %v", err))
+		return exitcode.Failure
+	}
+	if pl.HasErrors() {
+		return exitcode.Failure
+	}
+	for _, op := range pl.Pending() {
+		// As in a text dry run, hooks and "always" builds run every time and
+		// are not drift
+		if op.Kind == plan.KindHook || (op.Kind == config.KindBuild && cfg.Hooks.Builds[op.Name].Run == "always") {
+			continue
+		}
+		return exitcode.Drift
+	}
+	return exitcode.OK
+}
+
+// printBuildOutput prints the captured output of each failed build, which
+// builds only show when they fail unless run with --verbose. Builds stopped
+// by Ctrl-C didn't fail, so their output is left in the log.
+func printBuildOutput(errs []*hooks.BuildError) {
+	for _, e := range errs {
+		if e.Output == "" || errors.Is(e.Err, context.Canceled) {
+			continue
+		}
+		header := fmt.Sprintf("Output of build '%s':", e.Build)
+		if e.LogPath != "" {
+			header = fmt.Sprintf("Output of build '%s' (saved to %s):", e.Build, config.ShortenHome(e.LogPath))
+		}
+		fmt.Fprintln(os.Stderr, color.New(color.Bold).Sprint(header))
+		for _, line := range strings.Split(strings.TrimRight(e.Output, "\n"), "\n") {
+			fmt.Fprintln(os.Stderr, "    "+line)
+		}
+	}
+}
+
+// addFailures records err as a failure of phase. Errors joined by a
+// keep-going run are recorded as one failure each.
+func addFailures(phase *report.Phase, name string, err error) {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, e := range joined.Unwrap() {
+			phase.AddFail(name, e.Error(), e)
+		}
+		return
+	}
+	phase.AddFail(name, err.Error(), err)
+}
+
 // toPortablePath converts an absolute path to use $HOME instead of the expanded home directory.
 // This makes the path portable across different users/machines.
 func toPortablePath(path string) string {
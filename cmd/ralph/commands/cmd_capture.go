@@ -0,0 +1,142 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/fatih/color"
+	"github.com/mad01/ralph/internal/config"
+	"github.com/mad01/ralph/internal/dotfile"
+	"github.com/spf13/cobra"
+)
+
+var captureYes bool
+
+var captureCmd = &cobra.Command{
+	Use:   "capture [dotfile...]",
+	Short: "Copy edits made to deployed files back into the dotfiles repo",
+	Long: `Capture compares copied and rendered dotfiles against their sources and offers
+to copy changed targets back into the dotfiles repository.
+
+Each changed file is shown as a diff and confirmed individually. Symlinked
+dotfiles are skipped since edits to them already land in the repo.
+
+Templates (is_template and template_dir) are compared against their rendered
+output. Capturing one overwrites the template with the rendered file, losing
+its template expressions, so they are never captured without confirmation,
+even with --yes.
+
+With no arguments every enabled dotfile for this host is checked.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, err := config.LoadConfig()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, color.RedString("Error loading configuration: %v", err))
+			os.Exit(1)
+		}
+		currentHost := config.GetCurrentHost()
+
+		names := args
+		if len(names) == 0 {
+			for name, df := range cfg.Dotfiles {
+				if config.IsEnabled(df.Enable) && config.ShouldApplyForHost(df.Hosts, currentHost) {
+					names = append(names, name)
+				}
+			}
+			sort.Strings(names)
+		}
+
+		var candidates []dotfile.CaptureCandidate
+		failed := false
+		for _, name := range names {
+			df, ok := cfg.Dotfiles[name]
+			if !ok {
+				fmt.Fprintln(os.Stderr, color.RedString("Error: dotfile '%s' not found in configuration", name))
+				failed = true
+				continue
+			}
+			found, err := dotfile.FindCaptureCandidates(name, df, cfg)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, color.RedString("Error checking %s: %v", name, err))
+				failed = true
+				continue
+			}
+			candidates = append(candidates, found...)
+		}
+
+		if len(candidates) == 0 {
+			color.Green("No deployed files differ from their sources.")
+			if failed {
+				os.Exit(1)
+			}
+			return
+		}
+
+		captured := 0
+		for _, c := range candidates {
+			fmt.Printf("\n%s %s\n", color.New(color.Bold).Sprint(c.Name), config.ShortenHome(c.Target))
+			printCaptureDiff(c)
+
+			if c.Template {
+				color.Yellow("Warning: %s is a template; capturing replaces its template expressions with rendered values.", config.ShortenHome(c.Source))
+			}
+
+			if dryRun {
+				fmt.Printf("%s would copy %s → %s\n", color.CyanString("[dry run]"), config.ShortenHome(c.Target), config.ShortenHome(c.Source))
+				continue
+			}
+
+			accept := captureYes && !c.Template
+			if !accept {
+				prompt := &survey.Confirm{
+					Message: fmt.Sprintf("Copy %s back to %s?", config.ShortenHome(c.Target), config.ShortenHome(c.Source)),
+				}
+				if err := survey.AskOne(prompt, &accept); err != nil {
+					fmt.Fprintln(os.Stderr, color.RedString("Error during prompt: %v", err))
+					os.Exit(1)
+				}
+			}
+			if !accept {
+				fmt.Println(color.CyanString("skipped"))
+				continue
+			}
+			if err := dotfile.Capture(c); err != nil {
+				fmt.Fprintln(os.Stderr, color.RedString("Error capturing %s: %v", c.Name, err))
+				failed = true
+				continue
+			}
+			fmt.Println(color.GreenString("captured"))
+			captured++
+		}
+
+		if !dryRun {
+			fmt.Printf("\nCaptured %d of %d changed file(s).\n", captured, len(candidates))
+		}
+		if failed {
+			os.Exit(1)
+		}
+	},
+}
+
+// printCaptureDiff prints a unified diff from the expected content to the
+// deployed target.
+func printCaptureDiff(c dotfile.CaptureCandidate) {
+	label := config.ShortenHome(c.Source)
+	if c.Template {
+		label += " (rendered)"
+	}
+	diff := exec.Command("diff", "-u", "--label", label, "--label", config.ShortenHome(c.Target), "-", c.Target)
+	diff.Stdin = bytes.NewReader(c.Expected)
+	diff.Stdout = os.Stdout
+	diff.Stderr = os.Stderr
+	// diff exits 1 when the files differ, which is expected here.
+	_ = diff.Run()
+}
+
+func init() {
+	rootCmd.AddCommand(captureCmd)
+	captureCmd.Flags().BoolVarP(&captureYes, "yes", "y", false, "Capture non-template files without prompting")
+}
@@ -0,0 +1,95 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/fatih/color"
+	"github.com/mad01/ralph/internal/clean"
+	"github.com/mad01/ralph/internal/config"
+	"github.com/mad01/ralph/internal/dotfile"
+	"github.com/mad01/ralph/internal/exitcode"
+	"github.com/mad01/ralph/internal/report"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var cleanYes bool
+
+var cleanCmd = &cobra.Command{
+	Use:   "clean",
+	Short: "Remove broken symlinks where ralph deploys files",
+	Long: `Clean looks for broken symlinks in the directories ralph deploys into and
+offers to delete them, such as links left behind after a source moved or was
+removed in the dotfiles repo. Links are removed whether or not ralph created
+them.
+
+The directories searched are the one holding each dotfile and tool config
+file target (enabled on this host or not, and those recorded in the manifest
+from earlier applies), each target that is a directory itself, and the [bin]
+target. Only their own entries are checked, not subdirectories.
+
+Clean asks before removing each link unless --yes is given; --dry-run only
+lists them. The exit code follows apply: 0 when clean, 1 if a removal
+failed, and 4 with --dry-run when links would be removed.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, err := config.LoadConfig()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, color.RedString("Error loading configuration: %v", err))
+			os.Exit(exitcode.Failure)
+		}
+
+		targets := clean.Targets(cfg)
+		if manifest, err := dotfile.LoadManifest(); err != nil {
+			fmt.Fprintln(os.Stderr, color.YellowString("Warning: could not load manifest: %v", err))
+		} else {
+			for _, entry := range manifest.Dotfiles {
+				targets = append(targets, entry.Target)
+			}
+		}
+		links, err := clean.Find(clean.Roots(targets))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, color.RedString("Error looking for broken symlinks: %v", err))
+			os.Exit(exitcode.Failure)
+		}
+		if len(links) == 0 {
+			color.Green("No broken symlinks found.")
+			return
+		}
+		for _, link := range links {
+			fmt.Printf("  %s -> %s\n", config.ShortenHome(link.Path), color.RedString(link.Dest))
+		}
+		fmt.Println()
+
+		opts := clean.Options{DryRun: dryRun}
+		if !cleanYes && !dryRun {
+			if !term.IsTerminal(int(os.Stdin.Fd())) {
+				fmt.Fprintln(os.Stderr, color.RedString("Error: stdin is not a terminal; re-run with --yes to remove the links without confirmation."))
+				os.Exit(exitcode.Failure)
+			}
+			opts.Confirm = confirmClean
+		}
+
+		rpt := &report.Report{Command: "clean", Strict: strict || cfg.Strict}
+		clean.Remove(links, opts, rpt.AddPhase("Broken links"))
+		rpt.PrintSummary(os.Stdout, summaryVerbosity())
+		if dryRun {
+			os.Exit(rpt.DryRunExitCode())
+		}
+		os.Exit(rpt.ExitCode())
+	},
+}
+
+// confirmClean asks before a single broken link is removed.
+func confirmClean(link clean.BrokenLink) (bool, error) {
+	proceed := false
+	err := survey.AskOne(&survey.Confirm{Message: fmt.Sprintf("Remove %s (-> %s)?", config.ShortenHome(link.Path), link.Dest), Default: true}, &proceed)
+	return proceed, err
+}
+
+func init() {
+	rootCmd.AddCommand(cleanCmd)
+	cleanCmd.Flags().BoolVarP(&cleanYes, "yes", "y", false, "Remove the broken links without asking")
+}
@@ -0,0 +1,137 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/fatih/color"
+	"github.com/mad01/ralph/internal/config"
+	"github.com/mad01/ralph/internal/exitcode"
+	"github.com/mad01/ralph/internal/graph"
+	"github.com/spf13/cobra"
+)
+
+var (
+	configDiffHosts  []string
+	configDiffAll    bool
+	configDiffOutput string
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect the effective configuration",
+}
+
+var configDiffCmd = &cobra.Command{
+	Use:   "diff --host <a> [--host <b>]",
+	Short: "Compare what two hosts get from the config",
+	Long: `Diff loads the config as each of two hosts would, with their
+config.<host>.toml overlays and recipe host filters, and lists the recipes,
+dotfiles, directories, repos, tools, aliases, functions, env vars, builds and
+hooks that they get differently: only one host has it, one filters it out or
+has it disabled, or an overlay defines it differently.
+
+With a single --host, that host is compared with this machine. --all also
+lists the items both hosts get alike.
+
+  ralph config diff --host work-laptop --host home-desktop
+  ralph config diff --host new-server --output json | jq '.items[] | select(.b == null)'`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		hosts := configDiffHosts
+		switch len(hosts) {
+		case 1:
+			hosts = []string{config.GetCurrentHost(), hosts[0]}
+		case 2:
+		default:
+			fmt.Fprintln(os.Stderr, color.RedString("Error: give one or two --host flags"))
+			os.Exit(exitcode.Failure)
+		}
+
+		// Each host's config is loaded as that host, overlays included
+		names := make([]string, 2)
+		cfgs := make([]*config.Config, 2)
+		for i, host := range hosts {
+			config.HostOverride = host
+			names[i] = config.GetCurrentHost()
+			cfg, err := config.LoadConfig()
+			config.HostOverride = ""
+			if err != nil {
+				fmt.Fprintln(os.Stderr, color.RedString("Error loading configuration as %s: %v", host, err))
+				os.Exit(exitcode.Failure)
+			}
+			cfgs[i] = cfg
+		}
+		hostA, hostB := names[0], names[1]
+
+		var items []graph.ItemDiff
+		for _, d := range graph.Diff(cfgs[0], hostA, cfgs[1], hostB) {
+			if configDiffAll || !d.Same {
+				items = append(items, d)
+			}
+		}
+
+		switch configDiffOutput {
+		case "text":
+			printConfigDiff(hostA, hostB, items)
+		case "json":
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(struct {
+				Hosts []string         `json:"hosts"`
+				Items []graph.ItemDiff `json:"items"`
+			}{[]string{hostA, hostB}, items}); err != nil {
+				fmt.Fprintln(os.Stderr, color.RedString("Error writing diff: %v", err))
+				os.Exit(exitcode.Failure)
+			}
+		default:
+			fmt.Fprintln(os.Stderr, color.RedString("Error: unknown output '%s' (use text or json)", configDiffOutput))
+			os.Exit(exitcode.Failure)
+		}
+	},
+}
+
+// printConfigDiff prints each item with how each host gets it.
+func printConfigDiff(hostA, hostB string, items []graph.ItemDiff) {
+	if len(items) == 0 {
+		color.Green("%s and %s get the same items.", hostA, hostB)
+		return
+	}
+	width := len(hostA)
+	if len(hostB) > width {
+		width = len(hostB)
+	}
+	bold := color.New(color.Bold).SprintFunc()
+	for _, d := range items {
+		title := fmt.Sprintf("%s %s", d.Kind, bold(d.Label))
+		if d.Changed {
+			title += color.YellowString(" (defined differently)")
+		}
+		fmt.Println(title)
+		fmt.Printf("  %-*s  %s\n", width, hostA, diffState(d.A))
+		fmt.Printf("  %-*s  %s\n", width, hostB, diffState(d.B))
+	}
+}
+
+// diffState describes how a host gets a node.
+func diffState(n *graph.Node) string {
+	switch {
+	case n == nil:
+		return color.New(color.Faint).Sprint("not in config")
+	case !n.Active:
+		return color.YellowString(n.Detail)
+	case n.Detail != "":
+		return color.GreenString("applied") + " " + n.Detail
+	default:
+		return color.GreenString("applied")
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configDiffCmd)
+	configDiffCmd.Flags().StringSliceVar(&configDiffHosts, "host", nil, "Host to compare (give two, or one to compare with this machine)")
+	configDiffCmd.Flags().BoolVar(&configDiffAll, "all", false, "Also list the items both hosts get alike")
+	configDiffCmd.Flags().StringVar(&configDiffOutput, "output", "text", "Output format: text or json")
+}
@@ -1,28 +1,55 @@
 package commands
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 
+	"github.com/AlecAivazis/survey/v2"
 	"github.com/fatih/color"
 	"github.com/mad01/ralph/internal/config"
+	"github.com/mad01/ralph/internal/dotfile"
+	"github.com/mad01/ralph/internal/exitcode"
 	"github.com/mad01/ralph/internal/hooks"
 	"github.com/mad01/ralph/internal/report"
+	"github.com/mad01/ralph/internal/services"
 	"github.com/mad01/ralph/internal/shell"
 	"github.com/mad01/ralph/internal/tool"
+	"github.com/mad01/ralph/internal/vscode"
 	"github.com/spf13/cobra"
 )
 
+var (
+	doctorFix         bool
+	doctorExplainExit bool
+)
+
 var doctorCmd = &cobra.Command{
 	Use:   "doctor",
 	Short: "Check the health of the ralph setup",
-	Long:  `Performs a series of checks to ensure ralph is configured correctly and all managed items are in a healthy state.`,
+	Long: `Performs a series of checks to ensure ralph is configured correctly and all managed items are in a healthy state.
+
+Use --explain-exit to list the exit codes ralph commands use.`,
 	Run: func(cmd *cobra.Command, args []string) {
+		if doctorExplainExit {
+			exitcode.Explain(os.Stdout)
+			return
+		}
+		// --fix changes this machine, which the other host's config says
+		// nothing about.
+		if doctorFix && config.HostOverride != "" {
+			fmt.Fprintln(os.Stderr, color.RedString("Error: --fix changes this machine's login shell and can't be used with --host"))
+			os.Exit(exitcode.Failure)
+		}
 		color.Cyan("🩺 Running ralph doctor checks...")
 		healthy := true
-		rpt := &report.Report{Command: "doctor"}
+		rpt := &report.Report{Command: "doctor", Strict: strict}
 
 		// 1. Verify config.toml readability and validity
 		cfgPhase := rpt.AddPhase("Configuration")
@@ -35,89 +62,35 @@ var doctorCmd = &cobra.Command{
 		} else {
 			color.Green("OK")
 			cfgPhase.AddOK("config", "")
+			for _, overlay := range cfg.Overlays {
+				fmt.Printf("  Overlay: %s\n", config.ShortenHome(overlay))
+				cfgPhase.AddOK(filepath.Base(overlay), "overlay")
+			}
+			rpt.Strict = rpt.Strict || cfg.Strict
 		}
 
 		if cfg == nil { // If config failed to load, cannot proceed with other checks
 			fmt.Fprintln(os.Stderr, color.RedString("Cannot perform further checks due to configuration load failure."))
 			rpt.PrintSummary(os.Stdout, summaryVerbosity())
-			os.Exit(1)
+			os.Exit(exitcode.Failure)
 		}
+		checkLastApply(rpt.AddPhase("Last apply"), cfg)
 
 		// 2. Check for broken symlinks for managed dotfiles
 		dfPhase := rpt.AddPhase("Dotfile symlinks")
 		fmt.Println(color.New(color.FgWhite, color.Bold).Sprint("\nChecking managed dotfile symlinks:"))
-		foundIssuesInSymlinks := false
 		if len(cfg.Dotfiles) == 0 {
 			color.Yellow("  No dotfiles configured to check.")
 		} else {
-			for name, df := range cfg.Dotfiles {
-				templateMarker := ""
-				if df.IsTemplate {
-					templateMarker = color.CyanString(" (template)")
-				}
-				fmt.Printf("  - %s%s (Target: %s): ", color.New(color.Bold).Sprint(name), templateMarker, df.Target)
-				absoluteTarget, expandErr := config.ExpandPath(df.Target)
-				if expandErr != nil {
-					color.Red("Error expanding target path: %v", expandErr)
-					healthy = false
-					foundIssuesInSymlinks = true
-					dfPhase.AddFail(name, fmt.Sprintf("error expanding target path: %v", expandErr), expandErr)
-					continue
+			names := sortedKeys(cfg.Dotfiles)
+			var foundIssuesInSymlinks atomic.Bool
+			checkConcurrently(len(names), dfPhase, func(i int, w io.Writer, dfPhase *report.Phase) {
+				name := names[i]
+				if checkDotfile(w, cfg, name, cfg.Dotfiles[name], dfPhase) {
+					foundIssuesInSymlinks.Store(true)
 				}
-
-				targetInfo, statErr := os.Lstat(absoluteTarget)
-				if os.IsNotExist(statErr) {
-					color.Yellow("Not linked (target does not exist)")
-					dfPhase.AddWarn(name, "not linked (target does not exist)")
-				} else if statErr != nil {
-					color.Red("Error checking target: %v", statErr)
-					healthy = false
-					foundIssuesInSymlinks = true
-					dfPhase.AddFail(name, fmt.Sprintf("error checking target: %v", statErr), statErr)
-				} else {
-					if targetInfo.Mode()&os.ModeSymlink == 0 {
-						color.Yellow("Exists but is NOT a symlink")
-						foundIssuesInSymlinks = true // This is an issue if we expect a symlink
-						dfPhase.AddWarn(name, "exists but is not a symlink")
-					} else {
-						linkDest, readlinkErr := os.Readlink(absoluteTarget)
-						if readlinkErr != nil {
-							color.Red("Symlink (error reading destination: %v)", readlinkErr)
-							healthy = false
-							foundIssuesInSymlinks = true
-							dfPhase.AddFail(name, fmt.Sprintf("error reading symlink destination: %v", readlinkErr), readlinkErr)
-						} else {
-							var actualSourcePath string
-							if df.IsTemplate {
-								actualSourcePath = linkDest // For templates, linkDest is the absolute path to the processed file.
-							} else {
-								expandedRepoSource, _ := config.ExpandPath(filepath.Join(cfg.DotfilesRepoPath, df.Source))
-								actualSourcePath = expandedRepoSource
-								if linkDest != actualSourcePath {
-									color.Yellow("WARN: Symlink points to '%s', but config expects '%s'. Checking existence of actual '%s'... ", linkDest, actualSourcePath, linkDest)
-									actualSourcePath = linkDest // For broken check, use what it *actually* points to
-								}
-							}
-
-							if _, err := os.Stat(actualSourcePath); os.IsNotExist(err) {
-								color.Red("BROKEN SYMLINK (source '%s' does not exist)", actualSourcePath)
-								healthy = false
-								foundIssuesInSymlinks = true
-								dfPhase.AddFail(name, fmt.Sprintf("broken symlink (source '%s' does not exist)", actualSourcePath), err)
-							} else if err != nil {
-								color.Red("Error stating symlink source '%s': %v", actualSourcePath, err)
-								healthy = false
-								foundIssuesInSymlinks = true
-								dfPhase.AddFail(name, fmt.Sprintf("error stating source '%s': %v", actualSourcePath, err), err)
-							} else {
-								color.Green("OK")
-								dfPhase.AddOK(name, "")
-							}
-						}
-					}
-				}
-			}
-			if !foundIssuesInSymlinks && len(cfg.Dotfiles) > 0 {
+			})
+			if !foundIssuesInSymlinks.Load() {
 				color.Green("  All checked symlinks appear valid or target does not exist yet.")
 			}
 		}
@@ -128,32 +101,32 @@ var doctorCmd = &cobra.Command{
 		if len(cfg.Directories) == 0 {
 			color.Yellow("  No directories configured to check.")
 		} else {
-			for name, dir := range cfg.Directories {
-				fmt.Printf("  - %s (Target: %s): ", color.New(color.Bold).Sprint(name), dir.Target)
+			names := sortedKeys(cfg.Directories)
+			checkConcurrently(len(names), dirPhase, func(i int, w io.Writer, dirPhase *report.Phase) {
+				name := names[i]
+				dir := cfg.Directories[name]
+				fmt.Fprintf(w, "  - %s (Target: %s): ", color.New(color.Bold).Sprint(name), dir.Target)
 				absoluteTarget, expandErr := config.ExpandPath(dir.Target)
 				if expandErr != nil {
-					color.Red("Error expanding target path: %v", expandErr)
-					healthy = false
+					fmt.Fprintln(w, color.RedString("Error expanding target path: %v", expandErr))
 					dirPhase.AddFail(name, fmt.Sprintf("error expanding path: %v", expandErr), expandErr)
-					continue
+					return
 				}
 				info, statErr := os.Stat(absoluteTarget)
 				if os.IsNotExist(statErr) {
-					color.Yellow("Does not exist (will be created on apply)")
+					fmt.Fprintln(w, color.YellowString("Does not exist (will be created on apply)"))
 					dirPhase.AddWarn(name, "does not exist")
 				} else if statErr != nil {
-					color.Red("Error checking: %v", statErr)
-					healthy = false
+					fmt.Fprintln(w, color.RedString("Error checking: %v", statErr))
 					dirPhase.AddFail(name, fmt.Sprintf("error checking: %v", statErr), statErr)
 				} else if !info.IsDir() {
-					color.Red("Exists but is NOT a directory")
-					healthy = false
+					fmt.Fprintln(w, color.RedString("Exists but is NOT a directory"))
 					dirPhase.AddFail(name, "exists but is not a directory", nil)
 				} else {
-					color.Green("OK (exists)")
+					fmt.Fprintln(w, color.GreenString("OK (exists)"))
 					dirPhase.AddOK(name, "")
 				}
-			}
+			})
 		}
 
 		// Check configured repositories
@@ -162,39 +135,39 @@ var doctorCmd = &cobra.Command{
 		if len(cfg.Repos) == 0 {
 			color.Yellow("  No repositories configured to check.")
 		} else {
-			for name, rp := range cfg.Repos {
-				fmt.Printf("  - %s (URL: %s): ", color.New(color.Bold).Sprint(name), rp.URL)
+			names := sortedKeys(cfg.Repos)
+			checkConcurrently(len(names), repoPhase, func(i int, w io.Writer, repoPhase *report.Phase) {
+				name := names[i]
+				rp := cfg.Repos[name]
+				fmt.Fprintf(w, "  - %s (URL: %s): ", color.New(color.Bold).Sprint(name), rp.URL)
 				absoluteTarget, expandErr := config.ExpandPath(rp.Target)
 				if expandErr != nil {
-					color.Red("Error expanding target path: %v", expandErr)
-					healthy = false
+					fmt.Fprintln(w, color.RedString("Error expanding target path: %v", expandErr))
 					repoPhase.AddFail(name, fmt.Sprintf("error expanding path: %v", expandErr), expandErr)
-					continue
+					return
 				}
 				info, statErr := os.Stat(absoluteTarget)
 				if os.IsNotExist(statErr) {
-					color.Yellow("Not cloned (will be cloned on apply)")
+					fmt.Fprintln(w, color.YellowString("Not cloned (will be cloned on apply)"))
 					repoPhase.AddWarn(name, "not cloned")
 				} else if statErr != nil {
-					color.Red("Error checking: %v", statErr)
-					healthy = false
+					fmt.Fprintln(w, color.RedString("Error checking: %v", statErr))
 					repoPhase.AddFail(name, fmt.Sprintf("error checking: %v", statErr), statErr)
 				} else if !info.IsDir() {
-					color.Red("Target exists but is NOT a directory")
-					healthy = false
+					fmt.Fprintln(w, color.RedString("Target exists but is NOT a directory"))
 					repoPhase.AddFail(name, "target exists but is not a directory", nil)
 				} else {
 					// Check if it's a git repository
 					gitDir := filepath.Join(absoluteTarget, ".git")
 					if _, gitErr := os.Stat(gitDir); os.IsNotExist(gitErr) {
-						color.Yellow("Directory exists but is NOT a git repository")
+						fmt.Fprintln(w, color.YellowString("Directory exists but is NOT a git repository"))
 						repoPhase.AddWarn(name, "directory exists but is not a git repository")
 					} else {
-						color.Green("OK (cloned)")
+						fmt.Fprintln(w, color.GreenString("OK (cloned)"))
 						repoPhase.AddOK(name, "")
 					}
 				}
-			}
+			})
 		}
 
 		// Check configured builds
@@ -256,23 +229,95 @@ var doctorCmd = &cobra.Command{
 		if len(cfg.Tools) == 0 {
 			color.Yellow("  No tools configured to check.")
 		} else {
-			for _, t := range cfg.Tools {
-				fmt.Printf("  - %s: ", color.New(color.Bold).Sprint(t.Name))
-				if tool.CheckStatus(t.CheckCommand) {
-					color.Green("Installed")
+			checker := tool.NewChecker(refreshTools)
+			checkConcurrently(len(cfg.Tools), toolPhase, func(i int, w io.Writer, toolPhase *report.Phase) {
+				t := cfg.Tools[i]
+				fmt.Fprintf(w, "  - %s: ", color.New(color.Bold).Sprint(t.Name))
+				if checker.Installed(t.CheckCommand) {
+					fmt.Fprintln(w, color.GreenString("Installed"))
 					toolPhase.AddOK(t.Name, "installed")
 				} else {
-					color.Yellow("Not Installed (or check failed)")
-					fmt.Printf("      Install hint: %s\n", t.InstallHint)
+					fmt.Fprintln(w, color.YellowString("Not Installed (or check failed)"))
+					fmt.Fprintf(w, "      Install hint: %s\n", t.InstallHint)
 					toolPhase.AddWarn(t.Name, "not installed")
 				}
+			})
+			if err := checker.Save(); err != nil {
+				fmt.Fprintln(os.Stderr, color.YellowString("Warning: could not save tool check cache: %v", err))
+			}
+		}
+
+		if cfg.VSCode.Active(config.GetCurrentHost()) {
+			extPhase := rpt.AddPhase("VS Code extensions")
+			fmt.Println(color.New(color.FgWhite, color.Bold).Sprint("\nChecking VS Code extensions:"))
+			command := cfg.VSCode.CommandName()
+			if !vscode.Available(command) {
+				color.Yellow("  '%s' not found in PATH.", command)
+				extPhase.AddWarn(command, "not found in PATH")
+			} else if installed, err := vscode.Installed(command); err != nil {
+				color.Red("  Error: %v", err)
+				healthy = false
+				extPhase.AddFail(command, err.Error(), err)
+			} else {
+				missing, extra := vscode.Diff(cfg.VSCode.Extensions, installed)
+				for _, id := range missing {
+					fmt.Printf("  - %s: ", color.New(color.Bold).Sprint(id))
+					color.Yellow("Not installed (run apply to install)")
+					extPhase.AddWarn(id, "not installed")
+				}
+				for _, id := range extra {
+					fmt.Printf("  - %s: ", color.New(color.Bold).Sprint(id))
+					color.Yellow("Installed but not in [vscode] extensions")
+					extPhase.AddWarn(id, "not in config")
+				}
+				if len(missing) == 0 && len(extra) == 0 {
+					color.Green("  All %d configured extensions installed, no extras.", len(cfg.VSCode.Extensions))
+					extPhase.AddOK(command, "")
+				}
+			}
+		}
+
+		if len(cfg.Services) > 0 {
+			svcPhase := rpt.AddPhase("Services")
+			fmt.Println(color.New(color.FgWhite, color.Bold).Sprint("\nChecking systemd user services:"))
+			if !services.Supported() {
+				color.Yellow("  systemctl not available; services are only managed on Linux.")
+				svcPhase.AddSkip("services", "systemctl not available")
+			} else {
+				names := make([]string, 0, len(cfg.Services))
+				for name := range cfg.Services {
+					names = append(names, name)
+				}
+				sort.Strings(names)
+				for _, name := range names {
+					svc := cfg.Services[name]
+					fmt.Printf("  - %s (%s): ", color.New(color.Bold).Sprint(name), svc.Unit)
+					if reason := serviceSkipReason(cfg, svc, config.GetCurrentHost()); reason != "" {
+						color.Cyan("Skipped (%s)", reason)
+						svcPhase.AddSkip(name, reason)
+						continue
+					}
+					state := services.State(svc.Unit)
+					switch {
+					case state.Satisfied(config.IsEnabled(svc.Start)):
+						color.Green("%s", state)
+						svcPhase.AddOK(name, state.String())
+					case state.Active == "failed":
+						color.Red("%s", state)
+						healthy = false
+						svcPhase.AddFail(name, state.String(), nil)
+					default:
+						color.Yellow("%s (run apply to enable)", state)
+						svcPhase.AddWarn(name, state.String())
+					}
+				}
 			}
 		}
 
 		// 3. Verify if rc file snippets are correctly sourced
 		rcPhase := rpt.AddPhase("RC files")
 		fmt.Println(color.New(color.FgWhite, color.Bold).Sprint("\nChecking RC file sourcing:"))
-		shellsToTest := shell.ResolveShell(cfg.Shell.Name)
+		shellsToTest, _ := configuredShells(cfg)
 		foundRCIssues := false
 		for _, s := range shellsToTest {
 			fmt.Printf("  Shell '%s': ", color.New(color.Bold).Sprint(s))
@@ -371,18 +416,319 @@ var doctorCmd = &cobra.Command{
 			// color.Green("  RC file checks passed for tested shells.")
 		}
 
+		// 4. Compare the generated shell files with what the current config
+		// would generate, so config edits not yet applied are noticed
+		genPhase := rpt.AddPhase("Generated shell files")
+		fmt.Println(color.New(color.FgWhite, color.Bold).Sprint("\nChecking generated shell files:"))
+		for _, s := range shellsToTest {
+			fmt.Printf("  Shell '%s': ", color.New(color.Bold).Sprint(s))
+			shellName := string(s)
+			stale, err := shell.StaleGeneratedConfigs(cfg, s)
+			if err != nil {
+				color.Red("Could not check generated files: %v", err)
+				healthy = false
+				genPhase.AddFail(shellName, err.Error(), err)
+				continue
+			}
+			if s == shell.Zsh {
+				if ok, err := shell.CompletionsInSync(cfg); err == nil && !ok {
+					stale = append(stale, "completions")
+				}
+			}
+			if len(stale) > 0 {
+				color.Yellow("Stale: %s (config changed since the last apply; run 'ralph apply' to regenerate)", strings.Join(stale, ", "))
+				genPhase.AddWarn(shellName, fmt.Sprintf("stale %s: run 'ralph apply'", strings.Join(stale, ", ")))
+			} else {
+				color.Green("Up to date with the config.")
+				genPhase.AddOK(shellName, "")
+			}
+		}
+
+		// 5. Installed shells that apply leaves alone lose the aliases and
+		// functions as soon as one becomes the login shell
+		checkInstalledShells(rpt.AddPhase("Installed shells"), shellsToTest)
+
+		// 6. Verify the login shell is the configured one, or the rc block
+		// ralph maintains is never loaded by new terminals
+		if cfg.Shell.Name != "" {
+			if !checkLoginShell(rpt.AddPhase("Login shell"), shell.SupportedShell(cfg.Shell.Name)) {
+				healthy = false
+			}
+		}
+
+		// The verdict follows the exit code, as in apply: a failed check
+		// fails the run even where it was only printed
+		code := rpt.ExitCode()
+		if !healthy {
+			code = exitcode.Failure
+		}
 		fmt.Println("\n" + color.CyanString("Doctor checks complete."))
-		if healthy {
+		switch code {
+		case exitcode.OK:
 			color.Green("Ralph setup appears to be healthy! ✅")
-		} else {
+		case exitcode.Warnings:
+			color.Yellow("Ralph setup works, with warnings. ⚠️  Please review the messages above.")
+		default:
 			color.Red("Ralph setup has some issues. ❌ Please review the messages above.")
 		}
 
 		rpt.PrintSummary(os.Stdout, summaryVerbosity())
-		os.Exit(rpt.ExitCode())
+		os.Exit(code)
 	},
 }
 
+// doctorParallelism bounds how many doctor checks of one kind run at once,
+// which matters on NFS-mounted homes where every stat is a round trip.
+const doctorParallelism = 8
+
+// checkDotfile checks the target of one dotfile, recording a step in phase,
+// and reports whether it found a problem with a symlink. Only symlink and
+// symlink_dir without merge or is_template leave a symlink to the source;
+// every other target (copies, rendered templates, merged directories,
+// downloads, blocks, ...) is checked with dotfile.InSync, which honours
+// ignore.
+func checkDotfile(w io.Writer, cfg *config.Config, name string, df config.Dotfile, phase *report.Phase) bool {
+	templateMarker := ""
+	if df.IsTemplate {
+		templateMarker = color.CyanString(" (template)")
+	}
+	fmt.Fprintf(w, "  - %s%s (Target: %s): ", color.New(color.Bold).Sprint(name), templateMarker, df.Target)
+	absoluteTarget, expandErr := config.ExpandPath(df.Target)
+	if expandErr != nil {
+		fmt.Fprintln(w, color.RedString("Error expanding target path: %v", expandErr))
+		phase.AddFail(name, fmt.Sprintf("error expanding target path: %v", expandErr), expandErr)
+		return true
+	}
+
+	targetInfo, statErr := os.Lstat(absoluteTarget)
+	if os.IsNotExist(statErr) {
+		fmt.Fprintln(w, color.YellowString("Not deployed (target does not exist)"))
+		phase.AddWarn(name, "not deployed (target does not exist)")
+		return false
+	} else if statErr != nil {
+		fmt.Fprintln(w, color.RedString("Error checking target: %v", statErr))
+		phase.AddFail(name, fmt.Sprintf("error checking target: %v", statErr), statErr)
+		return true
+	}
+
+	if !linksToSource(df) {
+		action := df.Action
+		switch {
+		case df.Merge:
+			action = "merge"
+		case action == "" || action == "symlink":
+			action = "template"
+		}
+		ok, err := dotfile.InSync(df, cfg)
+		switch {
+		case err != nil:
+			fmt.Fprintln(w, color.RedString("Error checking %s: %v", action, err))
+			phase.AddFail(name, fmt.Sprintf("error checking %s: %v", action, err), err)
+			return true
+		case ok:
+			fmt.Fprintln(w, color.GreenString("OK"))
+			phase.AddOK(name, "")
+		default:
+			fix := "apply would update it"
+			switch df.Action {
+			case "download", "extract":
+				fix = fmt.Sprintf("apply would %s it again", df.Action)
+			case "append_block":
+				fix = "apply would update the block"
+			}
+			fmt.Fprintln(w, color.YellowString("Out of date (%s)", fix))
+			phase.AddWarn(name, fmt.Sprintf("out of date (%s)", fix))
+		}
+		return false
+	}
+
+	if !dotfile.IsLink(targetInfo) {
+		fmt.Fprintln(w, color.YellowString("Exists but is NOT a symlink"))
+		phase.AddWarn(name, "exists but is not a symlink")
+		return true
+	}
+	linkDest, readlinkErr := os.Readlink(absoluteTarget)
+	if readlinkErr != nil {
+		fmt.Fprintln(w, color.RedString("Symlink (error reading destination: %v)", readlinkErr))
+		phase.AddFail(name, fmt.Sprintf("error reading symlink destination: %v", readlinkErr), readlinkErr)
+		return true
+	}
+	actualSourcePath, _ := config.ExpandPath(filepath.Join(cfg.DotfilesRepoPath, df.Source))
+	if !config.SamePath(linkDest, actualSourcePath) {
+		fmt.Fprint(w, color.YellowString("WARN: Symlink points to '%s', but config expects '%s'. Checking existence of actual '%s'... ", linkDest, actualSourcePath, linkDest))
+		actualSourcePath = linkDest // For broken check, use what it *actually* points to
+	}
+
+	if _, err := os.Stat(actualSourcePath); os.IsNotExist(err) {
+		fmt.Fprintln(w, color.RedString("BROKEN SYMLINK (source '%s' does not exist)", actualSourcePath))
+		phase.AddFail(name, fmt.Sprintf("broken symlink (source '%s' does not exist)", actualSourcePath), err)
+		return true
+	} else if err != nil {
+		fmt.Fprintln(w, color.RedString("Error stating symlink source '%s': %v", actualSourcePath, err))
+		phase.AddFail(name, fmt.Sprintf("error stating source '%s': %v", actualSourcePath, err), err)
+		return true
+	}
+	fmt.Fprintln(w, color.GreenString("OK"))
+	phase.AddOK(name, "")
+	return false
+}
+
+// linksToSource reports whether apply deploys df as a symlink to its source.
+func linksToSource(df config.Dotfile) bool {
+	return (df.Action == "" || df.Action == "symlink" || df.Action == "symlink_dir") && !df.Merge && !df.IsTemplate
+}
+
+// checkConcurrently runs check for items 0 to n-1, at most doctorParallelism
+// at a time. Each check writes to its own buffer and records steps in its own
+// phase; once all are done the output is printed and the steps are added to
+// phase in item order, so the report reads as if they ran one by one.
+func checkConcurrently(n int, phase *report.Phase, check func(i int, w io.Writer, phase *report.Phase)) {
+	outputs := make([]bytes.Buffer, n)
+	phases := make([]report.Phase, n)
+	sem := make(chan struct{}, doctorParallelism)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			check(i, &outputs[i], &phases[i])
+		}(i)
+	}
+	wg.Wait()
+	for i := range outputs {
+		os.Stdout.Write(outputs[i].Bytes())
+		phase.Steps = append(phase.Steps, phases[i].Steps...)
+	}
+}
+
+// checkLastApply compares the ralph version and config hash the last apply
+// recorded in the manifest with this binary and the current config, and
+// warns when the apply was made by an older release or the config changed
+// since.
+func checkLastApply(phase *report.Phase, cfg *config.Config) {
+	fmt.Print(color.New(color.FgWhite, color.Bold).Sprint("Checking last apply... "))
+	manifest, err := dotfile.LoadManifest()
+	if err != nil {
+		color.Yellow("could not load manifest: %v", err)
+		phase.AddWarn("last apply", fmt.Sprintf("could not load manifest: %v", err))
+		return
+	}
+	last := manifest.LastApply
+	if last == nil {
+		color.Yellow("none recorded")
+		phase.AddSkip("last apply", "none recorded")
+		return
+	}
+	when := last.AppliedAt.Local().Format("2006-01-02 15:04")
+	color.Green("%s by ralph %s", when, last.Version)
+	if last.OlderThan(Version) {
+		color.Yellow("  Made by ralph %s, this is %s; run 'ralph apply' to redo it with this version", last.Version, Version)
+		phase.AddWarn("version", fmt.Sprintf("last apply by ralph %s, this is %s: run 'ralph apply'", last.Version, Version))
+	} else {
+		phase.AddOK("version", last.Version)
+	}
+	hash, err := config.Hash(cfg)
+	switch {
+	case err != nil:
+		phase.AddSkip("config", err.Error())
+	case hash != last.ConfigHash:
+		color.Yellow("  The config changed since the last apply (%s); run 'ralph apply'", when)
+		phase.AddWarn("config", fmt.Sprintf("changed since the last apply (%s): run 'ralph apply'", when))
+	default:
+		phase.AddOK("config", "unchanged since the last apply")
+	}
+}
+
+// checkInstalledShells lists the supported shells installed on this machine
+// and warns about one that $SHELL or the login shell runs but apply does not
+// configure, since switching to it silently drops the aliases and functions.
+func checkInstalledShells(phase *report.Phase, managed []shell.SupportedShell) {
+	fmt.Println(color.New(color.FgWhite, color.Bold).Sprint("\nChecking installed shells:"))
+	inUse := make(map[shell.SupportedShell]bool)
+	if env := os.Getenv("SHELL"); env != "" {
+		inUse[shell.ShellOf(env)] = true
+	}
+	if login, err := shell.LoginShell(); err == nil {
+		inUse[shell.ShellOf(login)] = true
+	}
+	for _, s := range shell.InstalledShells() {
+		name := string(s)
+		switch {
+		case shell.ContainsShell(managed, s):
+			color.Green("  %s: managed", name)
+			phase.AddOK(name, "managed")
+		case inUse[s]:
+			color.Yellow("  %s: in use but not configured; add it to shell.names or set shell.detect = true", name)
+			phase.AddWarn(name, "in use but not configured: add it to shell.names or set shell.detect = true")
+		default:
+			fmt.Printf("  %s: %s\n", name, color.New(color.Faint).Sprint("installed, not configured"))
+			phase.AddSkip(name, "installed, not configured")
+		}
+	}
+}
+
+// checkLoginShell compares $SHELL and the login shell recorded by the system
+// with the configured shell.name. With --fix it offers to run chsh. It
+// returns false if changing the login shell failed.
+func checkLoginShell(phase *report.Phase, configured shell.SupportedShell) bool {
+	fmt.Println(color.New(color.FgWhite, color.Bold).Sprint("\nChecking login shell:"))
+	if env := os.Getenv("SHELL"); env == "" {
+		color.Yellow("  $SHELL: not set")
+		phase.AddSkip("$SHELL", "not set")
+	} else if shell.ShellOf(env) != configured {
+		color.Yellow("  $SHELL: %s, but shell.name is %s", env, configured)
+		phase.AddWarn("$SHELL", fmt.Sprintf("%s, but shell.name is %s", env, configured))
+	} else {
+		color.Green("  $SHELL: %s", env)
+		phase.AddOK("$SHELL", env)
+	}
+
+	login, err := shell.LoginShell()
+	if err != nil {
+		color.Yellow("  Login shell: could not look up: %v", err)
+		phase.AddSkip("login shell", fmt.Sprintf("could not look up: %v", err))
+		return true
+	}
+	if shell.ShellOf(login) == configured {
+		color.Green("  Login shell: %s", login)
+		phase.AddOK("login shell", login)
+		return true
+	}
+	color.Yellow("  Login shell: %s, but shell.name is %s; new terminals won't load the %s rc file", login, configured, configured)
+	if !doctorFix {
+		fmt.Printf("    %s\n", color.New(color.Faint).Sprintf("Run 'ralph doctor --fix' to change it with chsh."))
+		phase.AddWarn("login shell", fmt.Sprintf("%s, but shell.name is %s (run doctor --fix to chsh)", login, configured))
+		return true
+	}
+
+	path, err := shell.ShellPath(configured)
+	if err != nil {
+		color.Red("    Cannot change the login shell: %v", err)
+		phase.AddFail("login shell", fmt.Sprintf("cannot change from %s: %v", login, err), err)
+		return false
+	}
+	proceed := false
+	if err := survey.AskOne(&survey.Confirm{Message: fmt.Sprintf("Run chsh -s %s?", path)}, &proceed); err != nil {
+		color.Red("    Error during prompt: %v", err)
+		phase.AddWarn("login shell", fmt.Sprintf("%s, but shell.name is %s", login, configured))
+		return true
+	}
+	if !proceed {
+		phase.AddWarn("login shell", fmt.Sprintf("%s, but shell.name is %s (chsh declined)", login, configured))
+		return true
+	}
+	if err := shell.ChangeLoginShell(path); err != nil {
+		color.Red("    %v", err)
+		phase.AddFail("login shell", err.Error(), err)
+		return false
+	}
+	color.Green("    Login shell changed to %s; it takes effect at the next login.", path)
+	phase.AddOK("login shell", fmt.Sprintf("changed from %s to %s", login, path))
+	return true
+}
+
 func shortenHome(path string) string {
 	home, err := os.UserHomeDir()
 	if err != nil || home == "" {
@@ -396,4 +742,7 @@ func shortenHome(path string) string {
 
 func init() {
 	rootCmd.AddCommand(doctorCmd)
+	doctorCmd.Flags().BoolVar(&doctorFix, "fix", false, "Offer to run chsh when the login shell is not the configured shell.name")
+	doctorCmd.Flags().BoolVar(&refreshTools, "refresh-tools", false, "Re-run every tool check_command instead of using cached results")
+	doctorCmd.Flags().BoolVar(&doctorExplainExit, "explain-exit", false, "List the exit codes ralph commands use and exit")
 }
@@ -0,0 +1,105 @@
+package commands
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mad01/ralph/internal/config"
+	"github.com/mad01/ralph/internal/report"
+)
+
+// writeFixture writes content to path, creating its directory.
+func writeFixture(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// checkDotfileStatus runs checkDotfile and returns the status of its step.
+func checkDotfileStatus(t *testing.T, cfg *config.Config, name string) report.Status {
+	t.Helper()
+	phase := &report.Phase{}
+	checkDotfile(io.Discard, cfg, name, cfg.Dotfiles[name], phase)
+	if len(phase.Steps) != 1 {
+		t.Fatalf("%s: steps = %+v, want one", name, phase.Steps)
+	}
+	return phase.Steps[0].Status
+}
+
+func TestCheckDotfile(t *testing.T) {
+	repo := t.TempDir()
+	home := t.TempDir()
+	writeFixture(t, filepath.Join(repo, "gitconfig"), "[user]\n")
+	writeFixture(t, filepath.Join(repo, "npmrc"), "registry=a\n")
+	writeFixture(t, filepath.Join(repo, "kitty", "kitty.conf"), "font_size 13\n")
+	writeFixture(t, filepath.Join(repo, "vimrc"), "set nu\n")
+
+	writeFixture(t, filepath.Join(home, ".gitconfig"), "[user]\n")
+	writeFixture(t, filepath.Join(home, ".npmrc"), "registry=b\n")
+	writeFixture(t, filepath.Join(home, "kitty", "kitty.conf"), "font_size 13\n")
+	if err := os.Symlink(filepath.Join(repo, "vimrc"), filepath.Join(home, ".vimrc")); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{
+		DotfilesRepoPath: repo,
+		Dotfiles: map[string]config.Dotfile{
+			"copy":         {Source: "gitconfig", Target: filepath.Join(home, ".gitconfig"), Action: "copy"},
+			"copy_drifted": {Source: "npmrc", Target: filepath.Join(home, ".npmrc"), Action: "copy"},
+			"template_dir": {Source: "kitty", Target: filepath.Join(home, "kitty"), Action: "template_dir"},
+			"symlink":      {Source: "vimrc", Target: filepath.Join(home, ".vimrc")},
+			"missing":      {Source: "vimrc", Target: filepath.Join(home, ".exrc")},
+		},
+	}
+
+	want := map[string]report.Status{
+		"copy":         report.StatusOK,
+		"copy_drifted": report.StatusWarn,
+		"template_dir": report.StatusOK,
+		"symlink":      report.StatusOK,
+		"missing":      report.StatusWarn,
+	}
+	for name, status := range want {
+		if got := checkDotfileStatus(t, cfg, name); got != status {
+			t.Errorf("%s: status = %v, want %v", name, got, status)
+		}
+	}
+}
+
+func TestCheckDotfile_MergedIgnore(t *testing.T) {
+	repo := t.TempDir()
+	home := t.TempDir()
+	writeFixture(t, filepath.Join(repo, "nvim", "init.lua"), "-- init\n")
+	writeFixture(t, filepath.Join(repo, "nvim", "lua", "plugins.lua"), "return {}\n")
+	writeFixture(t, filepath.Join(repo, "nvim", "cache.pyc"), "junk\n")
+	target := filepath.Join(home, "nvim")
+	if err := os.MkdirAll(target, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range []string{"init.lua", "lua"} {
+		if err := os.Symlink(filepath.Join(repo, "nvim", entry), filepath.Join(target, entry)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := &config.Config{
+		DotfilesRepoPath: repo,
+		Dotfiles: map[string]config.Dotfile{
+			"nvim": {Source: "nvim", Target: target, Action: "symlink_dir", Merge: true, Ignore: []string{"*.pyc"}},
+		},
+	}
+	if got := checkDotfileStatus(t, cfg, "nvim"); got != report.StatusOK {
+		t.Errorf("merged symlink_dir with an ignored, unlinked entry: status = %v, want OK", got)
+	}
+
+	cfg.Dotfiles["nvim"] = config.Dotfile{Source: "nvim", Target: target, Action: "symlink_dir", Merge: true}
+	if got := checkDotfileStatus(t, cfg, "nvim"); got != report.StatusWarn {
+		t.Errorf("merged symlink_dir missing a link: status = %v, want Warn", got)
+	}
+}
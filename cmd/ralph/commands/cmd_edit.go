@@ -0,0 +1,191 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/mad01/ralph/internal/config"
+	"github.com/mad01/ralph/internal/dotfile"
+	"github.com/mad01/ralph/internal/exitcode"
+	"github.com/mad01/ralph/internal/hooks"
+	"github.com/spf13/cobra"
+)
+
+var editNoApply bool
+
+var editCmd = &cobra.Command{
+	Use:   "edit <item>",
+	Short: "Open an item's source in $EDITOR and re-apply it",
+	Long: `Edit opens the source of the named dotfile in the dotfiles repo with $VISUAL,
+$EDITOR or vi, and when the editor exits re-applies just that dotfile: the
+template is rendered again, copies are refreshed, the manifest is updated and
+its post_link hooks run. With --dry-run it only shows what applying it would
+change.
+
+For any other item (alias, function, repo, build, ...) edit opens the recipe or
+config file that defines it; run 'ralph apply' afterwards.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := args[0]
+		cfg, err := config.LoadConfig()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, color.RedString("Error loading configuration: %v", err))
+			os.Exit(1)
+		}
+
+		df, isDotfile := cfg.Dotfiles[name]
+		items := explainItems(cfg, name)
+		var path string
+		switch {
+		case isDotfile:
+			path, err = config.ExpandPath(filepath.Join(cfg.DotfilesRepoPath, df.Source))
+		case len(items) > 0:
+			path, err = definingFile(cfg, items[0])
+		default:
+			fmt.Fprintln(os.Stderr, color.RedString("No dotfile, directory, repo, tool, alias, function, env var or build named '%s'.", name))
+			os.Exit(1)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, color.RedString("Error resolving the file to edit: %v", err))
+			os.Exit(1)
+		}
+
+		if err := runEditor(path); err != nil {
+			fmt.Fprintln(os.Stderr, color.RedString("Error: %v", err))
+			os.Exit(1)
+		}
+		if !isDotfile {
+			fmt.Println(color.CyanString("Run 'ralph apply' to apply the change."))
+			return
+		}
+		if editNoApply {
+			return
+		}
+		if !config.IsEnabled(df.Enable) || !config.ShouldApplyForHost(df.Hosts, config.GetCurrentHost()) {
+			fmt.Println(color.YellowString("Dotfile '%s' is not enabled on this host, not applying it.", name))
+			return
+		}
+		os.Exit(runReapply(name))
+	},
+}
+
+// runReapply re-applies the edited dotfile under the apply lock and returns
+// the exit code. The lock is only taken once the editor has exited, so a
+// long edit doesn't hold up a scheduled apply.
+func runReapply(name string) int {
+	if !dryRun {
+		applyLock, code := acquireApplyLock()
+		if applyLock == nil {
+			return code
+		}
+		defer applyLock.Release()
+	}
+
+	// The edit may have been to a recipe, so start from a fresh config.
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, color.RedString("Error loading configuration: %v", err))
+		return exitcode.Failure
+	}
+	if err := reapplyDotfile(cfg, name); err != nil {
+		fmt.Fprintln(os.Stderr, color.RedString("Error applying '%s': %v", name, err))
+		return exitcode.Failure
+	}
+	return exitcode.OK
+}
+
+// definingFile returns the recipe file or overlay that defines item, or the
+// main config.
+func definingFile(cfg *config.Config, item explainItem) (string, error) {
+	origin := config.OriginOf(cfg, item.kind, item.name)
+	if origin.Overlay != "" {
+		return origin.Overlay, nil
+	}
+	if origin.Path == "" {
+		return config.GetDefaultConfigPath()
+	}
+	return config.ExpandPath(filepath.Join(cfg.DotfilesRepoPath, origin.Path))
+}
+
+// runEditor opens path with $VISUAL, $EDITOR or vi, attached to the terminal.
+// The editor variable may carry arguments, e.g. "code --wait".
+func runEditor(path string) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if strings.TrimSpace(editor) == "" {
+		editor = "vi"
+	}
+	parts := strings.Fields(editor)
+	cmd := exec.Command(parts[0], append(parts[1:], path)...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("editor '%s' failed: %w", editor, err)
+	}
+	return nil
+}
+
+// reapplyDotfile applies a single dotfile as apply would: it is deployed,
+// recorded in the manifest and its post_link hooks run. With --dry-run it
+// only shows what would change.
+func reapplyDotfile(cfg *config.Config, name string) error {
+	df, ok := cfg.Dotfiles[name]
+	if !ok {
+		return fmt.Errorf("dotfile '%s' not found in configuration", name)
+	}
+	fmt.Printf("Applying %s\n", color.New(color.Bold).Sprint(name))
+	if err := applySingleDotfile(os.Stdout, cfg, name); err != nil {
+		return err
+	}
+
+	if !dryRun {
+		recordEditedDotfile(cfg, name, df)
+	}
+
+	if postHooks := cfg.Hooks.PostLink[name]; len(postHooks) > 0 {
+		linkContext := &hooks.HookContext{
+			DotfileName: name,
+			SourcePath:  filepath.Join(cfg.DotfilesRepoPath, df.Source),
+			TargetPath:  df.Target,
+			DryRun:      dryRun,
+		}
+		if err := hooks.RunHooks(context.Background(), os.Stdout, postHooks, hooks.PostLink, linkContext, dryRun); err != nil {
+			return fmt.Errorf("post-link hook failed: %w", err)
+		}
+	}
+	return nil
+}
+
+// recordEditedDotfile updates the manifest entry of a dotfile that was just
+// deployed, warning rather than failing when it can't.
+func recordEditedDotfile(cfg *config.Config, name string, df config.Dotfile) {
+	manifest, err := dotfile.LoadManifest()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, color.YellowString("Warning: could not load manifest: %v", err))
+		return
+	}
+	entry, err := dotfile.NewManifestEntry(df, cfg.DotfilesRepoPath)
+	if err != nil {
+		return
+	}
+	if entry.Checksums, err = dotfile.DeployedChecksums(entry); err != nil {
+		fmt.Fprintln(os.Stderr, color.YellowString("Warning: could not record checksums for %s: %v", name, err))
+	}
+	manifest.Dotfiles[name] = entry
+	if err := dotfile.SaveManifest(manifest); err != nil {
+		fmt.Fprintln(os.Stderr, color.YellowString("Warning: could not save manifest: %v", err))
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(editCmd)
+	editCmd.Flags().BoolVar(&editNoApply, "no-apply", false, "Only open the editor; don't re-apply the dotfile")
+}
@@ -0,0 +1,56 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/fatih/color"
+	"github.com/mad01/ralph/internal/config"
+	"github.com/mad01/ralph/internal/shell"
+	"github.com/spf13/cobra"
+)
+
+var envShell string
+
+var envCmd = &cobra.Command{
+	Use:   "env",
+	Short: "Print [shell.env] as statements to eval",
+	Long: `Env prints the [shell.env] variables enabled on this host, with template
+syntax in their values rendered, as export statements:
+
+  eval "$(ralph env)"           # bash, zsh, sh
+  ralph env --shell fish | source
+
+Values are double quoted, so $VAR references are expanded by the shell that
+evaluates them. Nothing is written and apply does not need to have run.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		shellType := shell.SupportedShell(envShell)
+		if envShell == "" {
+			shellType = shell.Bash
+			if filepath.Base(os.Getenv("SHELL")) == "fish" {
+				shellType = shell.Fish
+			}
+		} else if shellType != shell.Bash && shellType != shell.Zsh && shellType != shell.Fish && envShell != "sh" {
+			fmt.Fprintln(os.Stderr, color.RedString("Error: unsupported shell '%s' (use bash, zsh, sh or fish)", envShell))
+			os.Exit(1)
+		}
+
+		cfg, err := config.LoadConfig()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, color.RedString("Error loading configuration: %v", err))
+			os.Exit(1)
+		}
+		vars, err := shell.ResolveEnv(cfg, config.GetCurrentHost())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, color.RedString("Error: %v", err))
+			os.Exit(1)
+		}
+		fmt.Print(shell.EnvExports(vars, shellType))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(envCmd)
+	envCmd.Flags().StringVar(&envShell, "shell", "", "Output syntax: bash, zsh, sh or fish (default: fish if $SHELL is fish, else POSIX)")
+}
@@ -0,0 +1,325 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/mad01/ralph/internal/config"
+	"github.com/mad01/ralph/internal/dotfile"
+	"github.com/mad01/ralph/internal/hooks"
+	"github.com/mad01/ralph/internal/repo"
+	"github.com/mad01/ralph/internal/tool"
+	"github.com/spf13/cobra"
+)
+
+var explainCmd = &cobra.Command{
+	Use:   "explain <item>",
+	Short: "Explain why an item is applied, skipped or failing",
+	Long: `Explain prints the decision trail for every config item with the given name
+(dotfile, directory, repo, tool, alias, function, env var or build): where it
+is defined, its enable flag, how its host filter evaluates for this host, its
+current state on disk and when apply last deployed it.
+
+Items from recipes that are not loaded on this host are reported too.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := args[0]
+		cfg, err := config.LoadConfig()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, color.RedString("Error loading configuration: %v", err))
+			os.Exit(1)
+		}
+		currentHost := config.GetCurrentHost()
+
+		found := 0
+		for _, item := range explainItems(cfg, name) {
+			if found > 0 {
+				fmt.Println()
+			}
+			found++
+			printExplanation(cfg, item, currentHost)
+		}
+		for _, skipped := range skippedRecipesDefining(cfg, name) {
+			if found > 0 {
+				fmt.Println()
+			}
+			found++
+			fmt.Printf("%s\n", color.New(color.Bold).Sprintf("'%s' in skipped recipe '%s'", name, skipped.name))
+			explainLine("Defined in", fmt.Sprintf("recipe '%s' (%s)", skipped.name, skipped.path))
+			reason := "recipe is disabled (enable = false)"
+			if skipped.reason == "host filter" {
+				reason = fmt.Sprintf("recipe hosts %v do not include '%s'", skipped.hosts, currentHost)
+			}
+			explainLine("Verdict", color.YellowString("skipped: %s", reason))
+		}
+
+		if found == 0 {
+			fmt.Fprintln(os.Stderr, color.RedString("No dotfile, directory, repo, tool, alias, function, env var or build named '%s'.", name))
+			os.Exit(1)
+		}
+	},
+}
+
+// explainItem is a config item matching the name being explained.
+type explainItem struct {
+	kind   string
+	name   string
+	enable *bool
+	hosts  []string
+	status func() (details [][2]string, verdict string, ok bool) // kind-specific state, when active
+}
+
+// explainItems returns every item named name, of any kind.
+func explainItems(cfg *config.Config, name string) []explainItem {
+	var items []explainItem
+	if df, ok := cfg.Dotfiles[name]; ok {
+		items = append(items, explainItem{config.KindDotfile, name, df.Enable, df.Hosts, func() ([][2]string, string, bool) {
+			return dotfileStatus(cfg, name, df)
+		}})
+	}
+	if dir, ok := cfg.Directories[name]; ok {
+		items = append(items, explainItem{config.KindDirectory, name, dir.Enable, dir.Hosts, func() ([][2]string, string, bool) {
+			details := [][2]string{{"Target", dir.Target}}
+			inSync, err := dotfile.DirectoryInSync(dir)
+			switch {
+			case err != nil:
+				return details, fmt.Sprintf("error: %v", err), false
+			case inSync:
+				return details, "applied (directory exists with the configured mode)", true
+			default:
+				return details, "pending: apply would create the directory or fix its mode", true
+			}
+		}})
+	}
+	if rp, ok := cfg.Repos[name]; ok {
+		items = append(items, explainItem{config.KindRepo, name, rp.Enable, rp.Hosts, func() ([][2]string, string, bool) {
+			details := [][2]string{{"URL", rp.URL}, {"Target", rp.Target}}
+			if rp.Commit != "" {
+				details = append(details, [2]string{"Pinned commit", rp.Commit})
+			}
+			if len(repo.PendingRepos(map[string]config.Repo{name: rp}, "")) > 0 {
+				return details, "pending: apply would clone it or check out the pinned commit", true
+			}
+			return details, "applied (cloned)", true
+		}})
+	}
+	for _, t := range cfg.Tools {
+		if t.Name != name {
+			continue
+		}
+		t := t
+		items = append(items, explainItem{config.KindTool, name, t.Enable, t.Hosts, func() ([][2]string, string, bool) {
+			details := [][2]string{{"Check", t.CheckCommand}}
+			if tool.CheckStatus(t.CheckCommand) {
+				return details, "installed", true
+			}
+			return append(details, [2]string{"Install hint", t.InstallHint}), "not installed (check_command failed)", false
+		}})
+	}
+	if alias, ok := cfg.Shell.Aliases[name]; ok {
+		items = append(items, explainItem{config.KindAlias, name, alias.Enable, alias.Hosts, func() ([][2]string, string, bool) {
+			details := [][2]string{{"Command", alias.Command}}
+			if alias.Description != "" {
+				details = append(details, [2]string{"Description", alias.Description})
+			}
+			return details, "included in the generated aliases", true
+		}})
+	}
+	if fn, ok := cfg.Shell.Functions[name]; ok {
+		items = append(items, explainItem{config.KindFunction, name, fn.Enable, fn.Hosts, func() ([][2]string, string, bool) {
+			return nil, "included in the generated functions", true
+		}})
+	}
+	if env, ok := cfg.Shell.Env[name]; ok {
+		items = append(items, explainItem{config.KindEnv, name, env.Enable, env.Hosts, func() ([][2]string, string, bool) {
+			return [][2]string{{"Value", env.Value}}, "exported (see 'ralph env')", true
+		}})
+	}
+	if build, ok := cfg.Hooks.Builds[name]; ok {
+		items = append(items, explainItem{config.KindBuild, name, build.Enable, build.Hosts, func() ([][2]string, string, bool) {
+			return buildStatus(name, build)
+		}})
+	}
+	return items
+}
+
+// printExplanation prints the decision trail for a single item.
+func printExplanation(cfg *config.Config, item explainItem, currentHost string) {
+	fmt.Println(color.New(color.Bold).Sprintf("%s '%s'", item.kind, item.name))
+
+	explainLine("Defined in", describeOrigin(cfg, item.kind, item.name))
+
+	enabled := config.IsEnabled(item.enable)
+	if enabled {
+		explainLine("Enabled", "yes")
+	} else {
+		explainLine("Enabled", "no (enable = false)")
+	}
+
+	hostMatch := config.ShouldApplyForHost(item.hosts, currentHost)
+	switch {
+	case len(item.hosts) == 0:
+		explainLine("Hosts", "all hosts")
+	case hostMatch:
+		explainLine("Hosts", fmt.Sprintf("%s; current host '%s' matches", strings.Join(item.hosts, ", "), currentHost))
+	default:
+		explainLine("Hosts", fmt.Sprintf("%s; current host '%s' does not match", strings.Join(item.hosts, ", "), currentHost))
+	}
+
+	switch {
+	case !enabled:
+		explainLine("Verdict", color.YellowString("skipped: disabled"))
+	case !hostMatch:
+		explainLine("Verdict", color.YellowString("skipped: host filter"))
+	default:
+		details, verdict, ok := item.status()
+		for _, d := range details {
+			explainLine(d[0], d[1])
+		}
+		if ok {
+			explainLine("Verdict", color.GreenString(verdict))
+		} else {
+			explainLine("Verdict", color.RedString(verdict))
+		}
+	}
+}
+
+// dotfileStatus describes a dotfile's source, target, sync state and last deploy.
+func dotfileStatus(cfg *config.Config, name string, df config.Dotfile) ([][2]string, string, bool) {
+	source := filepath.Join(cfg.DotfilesRepoPath, df.Source)
+	details := [][2]string{{"Source", config.ShortenHome(source)}, {"Target", df.Target}}
+	if df.Action != "" {
+		details = append(details, [2]string{"Action", df.Action})
+	}
+
+	if manifest, err := dotfile.LoadManifest(); err == nil {
+		if entry, ok := manifest.Dotfiles[name]; ok {
+			details = append(details, [2]string{"Last applied", entry.AppliedAt.Local().Format("2006-01-02 15:04:05")})
+		} else {
+			details = append(details, [2]string{"Last applied", "never (not in the manifest)"})
+		}
+	}
+
+	expandedSource, err := config.ExpandPath(source)
+	if err == nil {
+		if _, statErr := os.Stat(expandedSource); os.IsNotExist(statErr) {
+			return details, "fails: source does not exist", false
+		}
+	}
+	inSync, err := dotfile.InSync(df, cfg)
+	switch {
+	case err != nil:
+		return details, fmt.Sprintf("error: %v", err), false
+	case inSync:
+		return details, "applied (target is up to date)", true
+	default:
+		return details, "pending: apply would change the target", true
+	}
+}
+
+// buildStatus describes a build's run mode and recorded state.
+func buildStatus(name string, build config.Build) ([][2]string, string, bool) {
+	details := [][2]string{{"Run", build.Run}}
+	if build.Repo != "" {
+		details = append(details, [2]string{"Repo", build.Repo})
+	}
+	if build.WorkingDir != "" {
+		details = append(details, [2]string{"Working dir", build.WorkingDir})
+	}
+	state, err := hooks.LoadBuildState()
+	if err != nil {
+		return details, fmt.Sprintf("error loading build state: %v", err), false
+	}
+	if record, ok := state.Builds[name]; ok {
+		details = append(details, [2]string{"Last completed", record.CompletedAt.Local().Format("2006-01-02 15:04:05")})
+	} else {
+		details = append(details, [2]string{"Last completed", "never"})
+	}
+
+	switch build.Run {
+	case "always":
+		return details, "runs on every apply", true
+	case "manual":
+		return details, fmt.Sprintf("runs only with 'ralph apply --build %s'", name), true
+	}
+	pending, err := hooks.PendingBuilds(map[string]config.Build{name: build}, "", hooks.BuildOptions{DryRun: true})
+	switch {
+	case err != nil:
+		return details, fmt.Sprintf("error: %v", err), false
+	case len(pending) > 0:
+		return details, "pending: runs on the next apply", true
+	default:
+		return details, "done (re-run with --force)", true
+	}
+}
+
+// skippedRecipe is a recipe not loaded on this host that defines the name.
+type skippedRecipe struct {
+	name, path, reason string
+	hosts              []string
+}
+
+// skippedRecipesDefining returns the recipes skipped on this host that define
+// an item called name.
+func skippedRecipesDefining(cfg *config.Config, name string) []skippedRecipe {
+	repoPath, err := config.ExpandPath(cfg.DotfilesRepoPath)
+	if err != nil {
+		return nil
+	}
+	var found []skippedRecipe
+	for _, skipped := range cfg.SkippedRecipes {
+		recipe, err := config.LoadRecipe(filepath.Join(repoPath, skipped.Ref.Path))
+		if err != nil || !recipeDefines(recipe, name) {
+			continue
+		}
+		recipeName := recipe.Recipe.Name
+		if recipeName == "" {
+			recipeName = skipped.Ref.Path
+		}
+		found = append(found, skippedRecipe{recipeName, skipped.Ref.Path, skipped.Reason, skipped.Ref.Hosts})
+	}
+	return found
+}
+
+// recipeDefines reports whether a recipe has an item of any kind called name.
+func recipeDefines(recipe *config.Recipe, name string) bool {
+	if _, ok := recipe.Dotfiles[name]; ok {
+		return true
+	}
+	if _, ok := recipe.Directories[name]; ok {
+		return true
+	}
+	if _, ok := recipe.Repos[name]; ok {
+		return true
+	}
+	if _, ok := recipe.Shell.Aliases[name]; ok {
+		return true
+	}
+	if _, ok := recipe.Shell.Functions[name]; ok {
+		return true
+	}
+	if _, ok := recipe.Shell.Env[name]; ok {
+		return true
+	}
+	if _, ok := recipe.Hooks.Builds[name]; ok {
+		return true
+	}
+	for _, t := range recipe.Tools {
+		if t.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// explainLine prints one aligned "label: value" line.
+func explainLine(label, value string) {
+	fmt.Printf("  %-15s %s\n", label+":", value)
+}
+
+func init() {
+	rootCmd.AddCommand(explainCmd)
+}
@@ -0,0 +1,58 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/fatih/color"
+	"github.com/mad01/ralph/internal/config"
+	"github.com/mad01/ralph/internal/graph"
+	"github.com/spf13/cobra"
+)
+
+var graphFormat string
+
+var graphCmd = &cobra.Command{
+	Use:   "graph",
+	Short: "Print how recipes, items, hooks and builds relate",
+	Long: `Graph prints the structure of your configuration: the main config includes
+recipes, the main config and recipes define items, items have pre_link and
+post_link hooks, and items depend on the directory or repo whose target
+contains their own target (a build's working_dir counts as its target).
+
+Items disabled or filtered out for this host, and recipes skipped on it, are
+drawn dashed.
+
+  ralph graph | dot -Tsvg > ralph.svg
+  ralph graph --format mermaid
+  ralph graph --format json | jq '.edges[] | select(.label == "depends on")'`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, err := config.LoadConfig()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, color.RedString("Error loading configuration: %v", err))
+			os.Exit(1)
+		}
+		g := graph.Build(cfg, config.GetCurrentHost())
+
+		switch graphFormat {
+		case "dot":
+			err = g.WriteDOT(os.Stdout)
+		case "mermaid":
+			err = g.WriteMermaid(os.Stdout)
+		case "json":
+			err = g.WriteJSON(os.Stdout)
+		default:
+			fmt.Fprintln(os.Stderr, color.RedString("Error: unknown format '%s' (use dot, mermaid or json)", graphFormat))
+			os.Exit(1)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, color.RedString("Error writing graph: %v", err))
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(graphCmd)
+	graphCmd.Flags().StringVar(&graphFormat, "format", "dot", "Output format: dot, mermaid or json")
+}
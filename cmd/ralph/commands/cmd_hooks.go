@@ -0,0 +1,132 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/mad01/ralph/internal/config"
+	"github.com/mad01/ralph/internal/exitcode"
+	"github.com/mad01/ralph/internal/hooks"
+	"github.com/spf13/cobra"
+)
+
+var hooksCmd = &cobra.Command{
+	Use:   "hooks",
+	Short: "Run lifecycle hooks",
+	Long:  `Run pre/post-apply and pre/post-link hooks from the config without a full apply.`,
+}
+
+var hooksRunCmd = &cobra.Command{
+	Use:   "run <type|group> [dotfile]",
+	Short: "Run hooks without applying",
+	Long: `Run executes hooks the way apply does, without applying anything else:
+
+  ralph hooks run pre_apply          # all pre_apply entries, in order
+  ralph hooks run setup              # only the pre_apply or post_apply group named setup
+  ralph hooks run post_link kitty    # the post_link hooks of dotfile kitty
+
+Link hooks get the dotfile's {dotfile}, {source} and {target}. With
+--dry-run the commands are printed instead of run.`,
+	Args: cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, err := config.LoadConfig()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, color.RedString("Error loading configuration: %v", err))
+			os.Exit(exitcode.Failure)
+		}
+		ctx, stop := interruptContext(cmd.Context())
+		defer stop()
+
+		hookContext := &hooks.HookContext{DryRun: dryRun}
+		switch hookType := hooks.HookType(args[0]); hookType {
+		case hooks.PreApply, hooks.PostApply:
+			groups := cfg.Hooks.PreApply
+			if hookType == hooks.PostApply {
+				groups = cfg.Hooks.PostApply
+			}
+			if len(groups) == 0 {
+				fmt.Println(color.YellowString("No %s hooks configured.", hookType))
+				return
+			}
+			err = hooks.RunGroups(ctx, os.Stdout, groups, hookType, hookContext, dryRun)
+		case hooks.PreLink, hooks.PostLink:
+			if len(args) != 2 {
+				fmt.Fprintln(os.Stderr, color.RedString("Error: %s hooks need a dotfile name, e.g. 'ralph hooks run %s <dotfile>'", hookType, hookType))
+				os.Exit(exitcode.Failure)
+			}
+			name := args[1]
+			df, ok := cfg.Dotfiles[name]
+			if !ok {
+				fmt.Fprintln(os.Stderr, color.RedString("Error: no dotfile named '%s'", name))
+				os.Exit(exitcode.Failure)
+			}
+			scripts := cfg.Hooks.PreLink[name]
+			if hookType == hooks.PostLink {
+				scripts = cfg.Hooks.PostLink[name]
+			}
+			if len(scripts) == 0 {
+				fmt.Println(color.YellowString("No %s hooks configured for '%s'.", hookType, name))
+				return
+			}
+			hookContext.DotfileName = name
+			hookContext.SourcePath = filepath.Join(cfg.DotfilesRepoPath, df.Source)
+			hookContext.TargetPath = df.Target
+			err = hooks.RunHooks(ctx, os.Stdout, scripts, hookType, hookContext, dryRun)
+		default:
+			group, groupType, ok := findHookGroup(cfg, args[0])
+			if !ok {
+				fmt.Fprintln(os.Stderr, color.RedString("Error: '%s' is not a hook type or a named hook group (groups: %s)", args[0], strings.Join(hookGroupNames(cfg), ", ")))
+				os.Exit(exitcode.Failure)
+			}
+			err = hooks.RunGroups(ctx, os.Stdout, []config.HookGroup{group}, groupType, hookContext, dryRun)
+		}
+
+		if ctx.Err() != nil {
+			fmt.Fprintln(os.Stderr, color.RedString("Interrupted."))
+			os.Exit(exitcode.Interrupted)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, color.RedString("Error: %v", err))
+			os.Exit(exitcode.Failure)
+		}
+	},
+}
+
+// findHookGroup returns the pre_apply or post_apply group called name.
+func findHookGroup(cfg *config.Config, name string) (config.HookGroup, hooks.HookType, bool) {
+	for _, g := range cfg.Hooks.PreApply {
+		if g.Name == name {
+			return g, hooks.PreApply, true
+		}
+	}
+	for _, g := range cfg.Hooks.PostApply {
+		if g.Name == name {
+			return g, hooks.PostApply, true
+		}
+	}
+	return config.HookGroup{}, "", false
+}
+
+// hookGroupNames lists the named pre_apply and post_apply groups, sorted.
+func hookGroupNames(cfg *config.Config) []string {
+	var names []string
+	for _, g := range append(append([]config.HookGroup(nil), cfg.Hooks.PreApply...), cfg.Hooks.PostApply...) {
+		if g.Name != "" {
+			names = append(names, g.Name)
+		}
+	}
+	if len(names) == 0 {
+		return []string{"none"}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func init() {
+	rootCmd.AddCommand(hooksCmd)
+	hooksCmd.AddCommand(hooksRunCmd)
+}
@@ -0,0 +1,136 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/mad01/ralph/internal/importer"
+	"github.com/spf13/cobra"
+)
+
+var (
+	importYadmRepo string
+	importYadmDir  string
+)
+
+var importCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Generate ralph config from another dotfiles manager",
+	Long:  `Import reads the setup of another dotfiles manager and generates the equivalent ralph config. Supported: yadm.`,
+}
+
+var importYadmCmd = &cobra.Command{
+	Use:   "yadm",
+	Short: "Generate dotfile entries from a yadm-managed home",
+	Long: `Import yadm lists the files tracked by yadm and prints a [dotfiles] entry
+for each, with the path in the yadm repo as source. Clone the yadm repo and
+set dotfiles_repo_path to the clone to use them.
+
+Alternate files become overlays with the same precedence as in yadm:
+##default alternates go in config.toml, ##os.<OS> alternates in
+config.<os>.toml and ##hostname.<host> alternates in config.<host>.toml.
+Alternate directories are linked with action = "symlink_dir". Alternates
+for class, user, distro or arch and yadm templates are reported instead.
+
+ralph does not encrypt dotfiles: files listed in yadm's encrypt file are
+reported so their secrets can move to template_variables_sops.
+
+Without --dir the files are printed one after the other with a header
+naming each; with --dir they are written there, and existing files are
+left alone.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, color.RedString("Error: %v", err))
+			os.Exit(1)
+		}
+		repo := importYadmRepo
+		if repo == "" {
+			if repo, err = importer.FindYadmRepo(home); err != nil {
+				fmt.Fprintln(os.Stderr, color.RedString("Error: %v", err))
+				os.Exit(1)
+			}
+		}
+		files, err := importer.ListYadmFiles(repo, home)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, color.RedString("Error: %v", err))
+			os.Exit(1)
+		}
+		encrypted, err := importer.ExpandEncryptList(home, filepath.Join(home, ".config", "yadm", "encrypt"))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, color.RedString("Error: %v", err))
+			os.Exit(1)
+		}
+		im := importer.FromYadm(files, encrypted)
+
+		if err := writeImport(im, importer.YadmRemote(repo)); err != nil {
+			fmt.Fprintln(os.Stderr, color.RedString("Error: %v", err))
+			os.Exit(1)
+		}
+
+		for _, w := range im.Warnings {
+			fmt.Fprintln(os.Stderr, color.YellowString("Not imported: %s", w))
+		}
+		if len(im.Encrypted) > 0 {
+			fmt.Fprintln(os.Stderr, color.YellowString("Encrypted by yadm, not imported (ralph doesn't encrypt dotfiles; keep the secrets in template_variables_sops):"))
+			for _, f := range im.Encrypted {
+				fmt.Fprintf(os.Stderr, "  ~/%s\n", f)
+			}
+		}
+	},
+}
+
+// writeImport prints the config files of im, or writes them to
+// importYadmDir.
+func writeImport(im *importer.Import, remote string) error {
+	files := []struct{ name, overlay string }{{"config.toml", ""}}
+	for _, overlay := range im.OverlayNames() {
+		files = append(files, struct{ name, overlay string }{"config." + overlay + ".toml", overlay})
+	}
+
+	for i, f := range files {
+		content, err := im.TOML(f.overlay)
+		if err != nil {
+			return err
+		}
+		if f.overlay == "" {
+			header := "# Imported from yadm: sources are paths in the yadm repo.\n"
+			if remote != "" {
+				header += fmt.Sprintf("# Clone it (git clone %s) and set dotfiles_repo_path to the clone.\n", remote)
+			}
+			content = header + "\n" + content
+		}
+
+		if importYadmDir == "" {
+			if i > 0 {
+				fmt.Println()
+			}
+			fmt.Printf("# ===== %s =====\n", f.name)
+			fmt.Print(content)
+			continue
+		}
+		path := filepath.Join(importYadmDir, f.name)
+		if _, err := os.Stat(path); err == nil {
+			fmt.Fprintln(os.Stderr, color.YellowString("Skipped %s: it already exists", path))
+			continue
+		}
+		if err := os.MkdirAll(importYadmDir, 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, []byte(strings.TrimLeft(content, "\n")), 0644); err != nil {
+			return err
+		}
+		fmt.Printf("Wrote %s\n", path)
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(importCmd)
+	importCmd.AddCommand(importYadmCmd)
+	importYadmCmd.Flags().StringVar(&importYadmRepo, "repo", "", "yadm repository (default: ~/.local/share/yadm/repo.git, else ~/.config/yadm/repo.git)")
+	importYadmCmd.Flags().StringVar(&importYadmDir, "dir", "", "Write config.toml and the overlays into this directory instead of printing them")
+}
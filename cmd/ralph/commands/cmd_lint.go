@@ -0,0 +1,90 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/mad01/ralph/internal/config"
+	"github.com/mad01/ralph/internal/exitcode"
+	"github.com/mad01/ralph/internal/inventory"
+	"github.com/mad01/ralph/internal/lint"
+	"github.com/mad01/ralph/internal/report"
+	"github.com/spf13/cobra"
+)
+
+var lintCmd = &cobra.Command{
+	Use:   "lint",
+	Short: "Find likely mistakes in the config and recipes",
+	Long: `Lint checks the loaded config and recipes for mistakes validation lets
+through:
+
+  missing source     dotfile or tool config sources not in the dotfiles repo
+  unreachable        hosts filters that match no known machine
+  unused variable    template_variables (and dotfile vars) no template uses
+  shadowed command   aliases named like a command on PATH that run something else
+  build repo         builds whose repo is disabled or not cloned where they run
+
+Known machines are this one and those recorded in the dotfiles repo (see
+'ralph machines'). Findings are warnings: lint exits 2 when there are any,
+1 with --strict.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, err := config.LoadConfig()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, color.RedString("Error loading configuration: %v", err))
+			os.Exit(exitcode.Failure)
+		}
+
+		hosts := knownHosts(cfg)
+		findings := lint.Run(cfg, lint.Options{Hosts: hosts})
+		fmt.Printf("Linted against %d known machine(s): %s\n", len(hosts), strings.Join(hosts, ", "))
+
+		rpt := &report.Report{Command: "lint", Strict: strict || cfg.Strict}
+		bold := color.New(color.Bold).SprintFunc()
+		for _, check := range lint.Checks() {
+			phase := rpt.AddPhase(strings.ToUpper(check[:1]) + check[1:])
+			for _, f := range findings {
+				if f.Check != check {
+					continue
+				}
+				fmt.Printf("  %s %s: %s\n", color.YellowString("%-17s", check), bold(f.Item), f.Message)
+				phase.AddWarn(f.Item, f.Message)
+			}
+		}
+		if len(findings) == 0 {
+			color.Green("No problems found.")
+		}
+
+		rpt.PrintSummary(os.Stdout, summaryVerbosity())
+		os.Exit(rpt.ExitCode())
+	},
+}
+
+// knownHosts returns this machine and those recorded in the dotfiles repo,
+// lowercase and sorted.
+func knownHosts(cfg *config.Config) []string {
+	seen := map[string]bool{}
+	if host := config.GetCurrentHost(); host != "" {
+		seen[host] = true
+	}
+	machines, err := inventory.List(cfg.DotfilesRepoPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, color.YellowString("Warning: could not read recorded machines: %v", err))
+	}
+	for _, m := range machines {
+		seen[strings.ToLower(m.Host)] = true
+	}
+	hosts := make([]string, 0, len(seen))
+	for h := range seen {
+		hosts = append(hosts, h)
+	}
+	sort.Strings(hosts)
+	return hosts
+}
+
+func init() {
+	rootCmd.AddCommand(lintCmd)
+}
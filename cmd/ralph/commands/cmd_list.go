@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 
 	"github.com/fatih/color"
 	"github.com/mad01/ralph/internal/config"
@@ -85,8 +86,9 @@ var listCmd = &cobra.Command{
 				if df.IsTemplate {
 					templateMarker = color.CyanString(" (template)")
 				}
-				fmt.Printf("  - %s%s:\n      Source: %s\n      Target: %s\n      Status: %s\n",
+				fmt.Printf("  - %s%s:\n      From:   %s\n      Source: %s\n      Target: %s\n      Status: %s\n",
 					color.New(color.Bold).Sprint(name), templateMarker,
+					describeOrigin(cfg, config.KindDotfile, name),
 					df.Source, df.Target,
 					statusColor.Sprint(statusMsg))
 			}
@@ -96,17 +98,22 @@ var listCmd = &cobra.Command{
 		if len(cfg.Tools) == 0 {
 			fmt.Println(color.YellowString("  No tools configured."))
 		} else {
+			checker := tool.NewChecker(refreshTools)
 			for _, t := range cfg.Tools {
 				var statusColor *color.Color
 				status := "Not installed"
-				if tool.CheckStatus(t.CheckCommand) {
+				if checker.Installed(t.CheckCommand) {
 					status = "Installed"
 					statusColor = color.New(color.FgGreen)
 				} else {
 					statusColor = color.New(color.FgYellow)
 				}
-				fmt.Printf("  - %s (Check: '%s', Hint: '%s'): %s\n",
-					color.New(color.Bold).Sprint(t.Name), t.CheckCommand, t.InstallHint, statusColor.Sprint(status))
+				fmt.Printf("  - %s (Check: '%s', Hint: '%s'): %s %s\n",
+					color.New(color.Bold).Sprint(t.Name), t.CheckCommand, t.InstallHint, statusColor.Sprint(status),
+					originSuffix(cfg, config.KindTool, t.Name))
+			}
+			if err := checker.Save(); err != nil {
+				fmt.Fprintln(os.Stderr, color.YellowString("Warning: could not save tool check cache: %v", err))
 			}
 		}
 
@@ -114,8 +121,18 @@ var listCmd = &cobra.Command{
 		if len(cfg.Shell.Aliases) == 0 {
 			fmt.Println(color.YellowString("  No shell aliases defined."))
 		} else {
-			for name, alias := range cfg.Shell.Aliases {
-				fmt.Printf("  - %s: %s\n", color.New(color.Bold).Sprint(name), alias.Command)
+			names := make([]string, 0, len(cfg.Shell.Aliases))
+			for name := range cfg.Shell.Aliases {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				alias := cfg.Shell.Aliases[name]
+				description := ""
+				if alias.Description != "" {
+					description = color.New(color.Faint).Sprint(" # " + alias.Description)
+				}
+				fmt.Printf("  - %s: %s%s %s\n", color.New(color.Bold).Sprint(name), alias.Command, description, originSuffix(cfg, config.KindAlias, name))
 			}
 		}
 
@@ -124,15 +141,44 @@ var listCmd = &cobra.Command{
 			fmt.Println(color.YellowString("  No shell functions defined."))
 		} else {
 			for name, fn := range cfg.Shell.Functions { // Iterate to get fn details if needed in future
-				fmt.Printf("  - %s\n", color.New(color.Bold).Sprint(name))
+				fmt.Printf("  - %s %s\n", color.New(color.Bold).Sprint(name), originSuffix(cfg, config.KindFunction, name))
 				// Could print fn.Body or a summary if desired, for now just the name
 				_ = fn // to satisfy linter if fn is not used
 			}
 		}
+
+		fmt.Println(color.New(color.FgWhite, color.Bold).Sprint("\nDefined Builds:"))
+		if len(cfg.Hooks.Builds) == 0 {
+			fmt.Println(color.YellowString("  No builds defined."))
+		} else {
+			for name, build := range cfg.Hooks.Builds {
+				fmt.Printf("  - %s (run: %s) %s\n", color.New(color.Bold).Sprint(name), build.Run, originSuffix(cfg, config.KindBuild, name))
+			}
+		}
 		fmt.Println("\n" + color.CyanString("Listing complete."))
 	},
 }
 
+// describeOrigin says where an item was defined: the main config file, a
+// host or OS overlay, or a recipe and its file relative to the dotfiles repo.
+func describeOrigin(cfg *config.Config, kind, name string) string {
+	origin := config.OriginOf(cfg, kind, name)
+	if origin.Recipe != "" {
+		return fmt.Sprintf("recipe '%s' (%s)", origin.Recipe, origin.Path)
+	}
+	if origin.Overlay != "" {
+		return fmt.Sprintf("overlay (%s)", config.ShortenHome(origin.Overlay))
+	}
+	configPath, _ := config.GetDefaultConfigPath()
+	return fmt.Sprintf("main config (%s)", config.ShortenHome(configPath))
+}
+
+// originSuffix is a dimmed "[recipe 'x' (path)]" marker for one-line entries.
+func originSuffix(cfg *config.Config, kind, name string) string {
+	return color.HiBlackString("[%s]", describeOrigin(cfg, kind, name))
+}
+
 func init() {
 	rootCmd.AddCommand(listCmd)
+	listCmd.Flags().BoolVar(&refreshTools, "refresh-tools", false, "Re-run every tool check_command instead of using cached results")
 }
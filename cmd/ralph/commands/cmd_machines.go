@@ -0,0 +1,85 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/mad01/ralph/internal/config"
+	"github.com/mad01/ralph/internal/inventory"
+	"github.com/spf13/cobra"
+)
+
+var machinesCmd = &cobra.Command{
+	Use:   "machines",
+	Short: "List the machines recorded in the dotfiles repo",
+	Long: `Machines lists every host that has run 'ralph apply' against this dotfiles
+repo, from the records apply writes to state/machines/<host>.toml: when it
+last applied, with which ralph version and recipes, and whether that apply
+converged or had failures.
+
+Records from other machines show up once their commits are pulled (see
+[git] auto_commit and 'ralph sync'). Machines are only recorded with
+inventory = true at the top of config.toml.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, err := config.LoadConfig()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, color.RedString("Error loading configuration: %v", err))
+			os.Exit(1)
+		}
+		machines, err := inventory.List(cfg.DotfilesRepoPath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, color.RedString("Error: %v", err))
+			os.Exit(1)
+		}
+		if len(machines) == 0 {
+			color.Yellow("No machines recorded yet. Set inventory = true in config.toml and run 'ralph apply' to record this one.")
+			return
+		}
+
+		currentHost := config.GetCurrentHost()
+		now := time.Now()
+		bold := color.New(color.Bold).SprintFunc()
+		for _, m := range machines {
+			name := m.Host
+			if m.Host == currentHost {
+				name += " (this machine)"
+			}
+			status := color.GreenString("converged")
+			if !m.Converged() {
+				status = color.RedString("%d failure(s)", len(m.Failures))
+			}
+			fmt.Printf("%s  %s\n", bold(name), status)
+			fmt.Printf("  %-10s %s (%s ago)\n", "Applied", m.LastApply.Local().Format("2006-01-02 15:04"), roundAge(now.Sub(m.LastApply)))
+			fmt.Printf("  %-10s %s, %s\n", "Version", m.Version, m.OS)
+			recipes := "none"
+			if len(m.Recipes) > 0 {
+				recipes = strings.Join(m.Recipes, ", ")
+			}
+			fmt.Printf("  %-10s %s\n", "Recipes", recipes)
+			for _, failure := range m.Failures {
+				fmt.Printf("  %s %s\n", color.RedString("FAIL"), failure)
+			}
+		}
+	},
+}
+
+// roundAge formats d coarsely, e.g. "3d", "5h" or "12m".
+func roundAge(d time.Duration) string {
+	switch {
+	case d >= 24*time.Hour:
+		return fmt.Sprintf("%dd", int(d.Hours()/24))
+	case d >= time.Hour:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	case d >= time.Minute:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	default:
+		return "<1m"
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(machinesCmd)
+}
@@ -4,12 +4,18 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/AlecAivazis/survey/v2"
 	"github.com/fatih/color"
 	"github.com/mad01/ralph/internal/config"
+	"github.com/mad01/ralph/internal/exitcode"
 	"github.com/mad01/ralph/internal/migrate"
+	"github.com/mad01/ralph/internal/report"
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
 )
 
+var migrateYes bool
+
 var migrateCmd = &cobra.Command{
 	Use:   "migrate",
 	Short: "Migrate symlinks after reorganizing dotfiles repository",
@@ -26,13 +32,27 @@ For this to work, your recipes must define legacy_paths mappings:
   "ralph_files/nvim/init.lua" = "nvim/init.lua"
   "ralph_files/nvim" = "nvim"
 
+When you change a dotfile's target instead, map the old target to the new one
+with legacy_targets. Migrate moves a symlink (or a copied file) from the old
+target to the new one and updates the manifest:
+
+  [recipe.legacy_targets]
+  "~/.vimrc" = "~/.config/nvim/init.vim"
+
 Example workflow:
   1. Reorganize files in your dotfiles repo
   2. Create recipe.toml files with legacy_paths mappings
   3. Update config.toml to reference the recipes
   4. Run 'ralph migrate --dry-run' to preview changes
-  5. Run 'ralph migrate' to update symlinks
-  6. Run 'ralph apply' to ensure everything is in sync`,
+  5. Run 'ralph migrate' to update symlinks (asks for each unless --yes)
+  6. Run 'ralph apply' to ensure everything is in sync
+
+Anything unexpected at a target, such as a file that replaced the symlink
+since the plan was made, is backed up to <target>.bak before it is replaced.
+
+The exit code follows apply: 0 when clean, 1 if an update failed, 2 for
+warnings such as broken symlinks without a legacy mapping. With --dry-run it
+is 4 when symlinks would be updated.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		fmt.Println("Checking for symlinks that need migration...")
 
@@ -45,13 +65,14 @@ Example workflow:
 		cfg, err := config.LoadConfig()
 		if err != nil {
 			fmt.Fprintln(os.Stderr, color.RedString("Error loading configuration: %v", err))
-			os.Exit(1)
+			os.Exit(exitcode.Failure)
 		}
 
 		// Check for legacy paths in loaded recipes
 		legacyPaths := config.GetAllLegacyPaths(cfg)
-		if len(legacyPaths) == 0 {
-			fmt.Println("\nNo legacy path mappings found in recipes.")
+		legacyTargets := config.GetAllLegacyTargets(cfg)
+		if len(legacyPaths) == 0 && len(legacyTargets) == 0 {
+			fmt.Println("\nNo legacy path or target mappings found in recipes.")
 			fmt.Println("If you've reorganized your dotfiles, add [recipe.legacy_paths] to your recipe files.")
 			fmt.Println("Example:")
 			fmt.Println("  [recipe.legacy_paths]")
@@ -59,38 +80,60 @@ Example workflow:
 			return
 		}
 
-		fmt.Printf("Found %d legacy path mapping(s) in recipes.\n", len(legacyPaths))
+		fmt.Printf("Found %d legacy path and %d legacy target mapping(s) in recipes.\n", len(legacyPaths), len(legacyTargets))
 
 		// Check migration status
 		plan, err := migrate.CheckMigration(cfg)
 		if err != nil {
 			fmt.Fprintln(os.Stderr, color.RedString("Error checking migration: %v", err))
-			os.Exit(1)
+			os.Exit(exitcode.Failure)
 		}
 
 		// Print the plan
 		migrate.PrintMigrationPlan(plan)
 
-		if plan.NeedsUpdate == 0 {
+		if plan.NeedsUpdate == 0 && plan.TargetMoves == 0 {
 			color.Green("No symlinks need to be updated.")
 			return
 		}
 
-		// Execute migration
-		if err := migrate.ExecuteMigration(plan, dryRun); err != nil {
-			fmt.Fprintln(os.Stderr, color.RedString("Error executing migration: %v", err))
-			os.Exit(1)
+		opts := migrate.Options{DryRun: dryRun}
+		if !migrateYes && !dryRun {
+			if !term.IsTerminal(int(os.Stdin.Fd())) {
+				fmt.Fprintln(os.Stderr, color.RedString("Error: stdin is not a terminal; re-run with --yes to migrate without confirmation."))
+				os.Exit(exitcode.Failure)
+			}
+			opts.Confirm = confirmMigration
 		}
 
+		rpt := &report.Report{Command: "migrate", Strict: strict || cfg.Strict}
+		migrate.RunMigration(plan, opts, rpt.AddPhase("Symlinks"))
+		rpt.PrintSummary(os.Stdout, summaryVerbosity())
+
 		fmt.Println()
 		if dryRun {
 			color.Cyan("DRY RUN: Migration preview complete. Run without --dry-run to apply changes.")
-		} else {
+			os.Exit(rpt.DryRunExitCode())
+		}
+		if !rpt.HasFailures() {
 			color.Green("Migration complete. Run 'ralph apply' to ensure everything is in sync.")
 		}
+		os.Exit(rpt.ExitCode())
 	},
 }
 
+// confirmMigration asks before a single symlink update or target move.
+func confirmMigration(result migrate.MigrationResult) (bool, error) {
+	message := fmt.Sprintf("Update %s -> %s?", config.ShortenHome(result.Target), config.ShortenHome(result.NewSource))
+	if result.Status == migrate.StatusMoveTarget {
+		message = fmt.Sprintf("Move %s to %s?", config.ShortenHome(result.OldTarget), config.ShortenHome(result.Target))
+	}
+	proceed := false
+	err := survey.AskOne(&survey.Confirm{Message: message, Default: true}, &proceed)
+	return proceed, err
+}
+
 func init() {
 	rootCmd.AddCommand(migrateCmd)
+	migrateCmd.Flags().BoolVarP(&migrateYes, "yes", "y", false, "Do not ask before each symlink update or target move")
 }
@@ -0,0 +1,111 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/fatih/color"
+	"github.com/mad01/ralph/internal/config"
+	"github.com/mad01/ralph/internal/exitcode"
+	"github.com/mad01/ralph/internal/migrate"
+	"github.com/mad01/ralph/internal/report"
+	"github.com/spf13/cobra"
+)
+
+var migrateConfigCmd = &cobra.Command{
+	Use:   "migrate-config",
+	Short: "Move dotfiles from a flat config.toml into recipes",
+	Long: `Migrate-config converts a pre-recipes layout, where config.toml lists every
+dotfile and the sources sit in the repo (often under dotter_files/), into
+recipes:
+
+  - Each dotfile defined in config.toml moves into recipes/<name>/: a source
+    in a subdirectory goes to the recipe named after that directory (with the
+    other sources there), a top-level source to a recipe named after its
+    dotfile. A leading dotter_files/ or ralph_files/ is dropped.
+  - The sources are moved and each recipe.toml is written with the dotfiles
+    and legacy_paths for their old sources.
+  - config.toml loses the migrated [dotfiles.*] tables and gains a
+    [[recipes]] reference per recipe (none with recipes_config.auto_discover).
+    Comments and everything else are kept; the original is saved as
+    config.toml.bak.
+  - Existing symlinks are then pointed at the moved sources, as
+    'ralph migrate --yes' would.
+
+Dotfiles from overlays or recipes and sources outside the repo are left
+alone. Use --dry-run to see the moves first. With recipes_config.auto_migrate
+set, 'ralph apply' does this itself before applying.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, err := config.LoadConfig()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, color.RedString("Error loading configuration: %v", err))
+			os.Exit(exitcode.Failure)
+		}
+
+		rpt := &report.Report{Command: "migrate-config", Strict: strict || cfg.Strict}
+		if _, empty := migrateConfig(os.Stdout, cfg, rpt); empty {
+			color.Green("No dotfiles in config.toml to move into recipes.")
+			return
+		}
+		rpt.PrintSummary(os.Stdout, summaryVerbosity())
+		if dryRun {
+			os.Exit(rpt.DryRunExitCode())
+		}
+		os.Exit(rpt.ExitCode())
+	},
+}
+
+// migrateConfig moves the dotfiles of a flat config.toml into recipes and
+// repoints their symlinks, recording a "Config migration" phase (and a
+// "Symlinks" phase) in rpt. It returns the config to go on with, reloaded
+// after a migration, and whether there was nothing to migrate.
+func migrateConfig(w io.Writer, cfg *config.Config, rpt *report.Report) (*config.Config, bool) {
+	configPath, err := config.GetDefaultConfigPath()
+	if err != nil {
+		rpt.AddPhase("Config migration").AddFail("config.toml", "could not find config path", err)
+		return cfg, false
+	}
+	plan, err := migrate.PlanConfigMigration(cfg, configPath)
+	if err != nil {
+		rpt.AddPhase("Config migration").AddFail("config.toml", err.Error(), err)
+		return cfg, false
+	}
+	if plan.Empty() {
+		return cfg, true
+	}
+
+	phase := rpt.AddPhase("Config migration")
+	if err := migrate.ApplyConfigMigration(plan, w, dryRun); err != nil {
+		phase.AddFail("config.toml", err.Error(), err)
+		return cfg, false
+	}
+	for _, r := range plan.Recipes {
+		if dryRun {
+			phase.AddPending(r.Name, fmt.Sprintf("%d dotfile(s) would move to %s", len(r.Dotfiles), r.Dir))
+		} else {
+			phase.AddOK(r.Name, fmt.Sprintf("%d dotfile(s) moved to %s", len(r.Dotfiles), r.Dir))
+		}
+	}
+	if dryRun {
+		return cfg, false
+	}
+
+	migrated, err := config.LoadConfig()
+	if err != nil {
+		phase.AddFail("config.toml", "migrated config does not load", err)
+		return cfg, false
+	}
+	symlinks, err := migrate.CheckMigration(migrated)
+	if err != nil {
+		rpt.AddPhase("Symlinks").AddFail("symlinks", err.Error(), err)
+		return migrated, false
+	}
+	migrate.RunMigration(symlinks, migrate.Options{}, rpt.AddPhase("Symlinks"))
+	return migrated, false
+}
+
+func init() {
+	rootCmd.AddCommand(migrateConfigCmd)
+}
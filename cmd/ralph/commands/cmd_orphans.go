@@ -0,0 +1,58 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/fatih/color"
+	"github.com/mad01/ralph/internal/config"
+	"github.com/mad01/ralph/internal/exitcode"
+	"github.com/mad01/ralph/internal/orphans"
+	"github.com/spf13/cobra"
+)
+
+var orphansCmd = &cobra.Command{
+	Use:   "orphans",
+	Short: "List files in the dotfiles repo that nothing refers to",
+	Long: `Orphans walks dotfiles_repo_path and lists the files that no dotfile, tool
+config file, recipe, [bin] source, completion path, template_variables_sops or
+template include ({{ source "..." }}, {{ rendered "..." }}) refers to, so dead
+configs can be cleaned up. A referenced directory covers everything under it,
+and a recipe skipped on this machine keeps its whole directory. config.toml and
+the config.*.toml overlays next to it count as referenced when they live in
+the repo.
+
+Files that belong in the repo without being applied, such as a README, can be
+ignored by glob, matched against the path in the repo or the file name:
+
+  [orphans]
+  ignore = ["README.md", "LICENSE", "docs/*"]
+
+Orphans only lists files; it never removes anything.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, err := config.LoadConfig()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, color.RedString("Error loading configuration: %v", err))
+			os.Exit(exitcode.Failure)
+		}
+
+		found, err := orphans.Find(cfg)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, color.RedString("Error walking dotfiles repo: %v", err))
+			os.Exit(exitcode.Failure)
+		}
+		if len(found) == 0 {
+			color.Green("No orphaned files in %s.", config.ShortenHome(cfg.DotfilesRepoPath))
+			return
+		}
+		for _, path := range found {
+			fmt.Println(path)
+		}
+		fmt.Fprintf(os.Stderr, "\n%d file(s) in %s are not referenced by the config.\n", len(found), config.ShortenHome(cfg.DotfilesRepoPath))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(orphansCmd)
+}
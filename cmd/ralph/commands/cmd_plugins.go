@@ -0,0 +1,106 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/fatih/color"
+	"github.com/mad01/ralph/internal/config"
+	"github.com/mad01/ralph/internal/dotfile"
+	"github.com/mad01/ralph/internal/plugin"
+	"github.com/spf13/cobra"
+)
+
+var pluginsCmd = &cobra.Command{
+	Use:   "plugins",
+	Short: "List plugins (ralph-* executables on PATH)",
+	Long: `Any executable on PATH named ralph-<name> can be run as 'ralph <name>'.
+Arguments after the name are passed through unchanged. The plugin gets a JSON
+context on stdin (version, config_path, dotfiles_repo_path, state_dir, host)
+and RALPH_CONFIG and RALPH_VERSION in its environment.
+
+Built-in commands take precedence over plugins with the same name.
+
+An executable named ralph-action-<name> instead provides the dotfile action
+<name>, used with action = "<name>". It is run as 'ralph-action-<name> plan',
+'apply' or 'verify' with the dotfile's source and target (and dry_run) added
+to the JSON context. plan exits 0 when the target is in sync and 1 when apply
+would change it; verify exits 1 after printing the drifted paths. Built-in
+actions take precedence.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		plugins := plugin.Discover(os.Getenv("PATH"))
+		if len(plugins) == 0 {
+			color.Yellow("No plugins found. Put an executable named ralph-<name> on your PATH.")
+			return
+		}
+		for _, p := range plugins {
+			note := ""
+			if action, ok := plugin.ActionName(p); ok {
+				note = fmt.Sprintf(" (dotfile action '%s')", action)
+			} else if isBuiltinCommand(p.Name) {
+				note = color.YellowString(" (shadowed by built-in command)")
+			}
+			fmt.Printf("  %-15s %s%s\n", color.New(color.Bold).Sprint(p.Name), config.ShortenHome(p.Path), note)
+		}
+	},
+}
+
+// pluginAnnotation marks the commands registerPlugins added.
+const pluginAnnotation = "plugin"
+
+// isBuiltinCommand reports whether name is a built-in command or alias.
+func isBuiltinCommand(name string) bool {
+	for _, c := range rootCmd.Commands() {
+		if _, isPlugin := c.Annotations[pluginAnnotation]; isPlugin {
+			continue
+		}
+		if c.Name() == name || c.HasAlias(name) {
+			return true
+		}
+	}
+	return name == "help" || name == "completion"
+}
+
+// registerPlugins adds a subcommand for every plugin on PATH that does not
+// clash with a built-in command, and registers the dotfile actions of action
+// plugins that do not clash with a built-in action.
+func registerPlugins() {
+	for _, p := range plugin.Discover(os.Getenv("PATH")) {
+		if action, ok := plugin.ActionName(p); ok {
+			if _, err := dotfile.LookupAction(action); err != nil {
+				dotfile.RegisterAction(action, plugin.Action{Plugin: p, Version: Version})
+			}
+			continue
+		}
+		if isBuiltinCommand(p.Name) {
+			continue
+		}
+		p := p
+		rootCmd.AddCommand(&cobra.Command{
+			Use:                p.Name,
+			Short:              fmt.Sprintf("Plugin (%s)", config.ShortenHome(p.Path)),
+			DisableFlagParsing: true,
+			Annotations:        map[string]string{pluginAnnotation: p.Path},
+			Run: func(cmd *cobra.Command, args []string) {
+				code, err := plugin.Run(p, args, pluginContext())
+				if err != nil {
+					fmt.Fprintln(os.Stderr, color.RedString("Error: %v", err))
+				}
+				os.Exit(code)
+			},
+		})
+	}
+}
+
+// pluginContext describes this ralph setup to a plugin.
+func pluginContext() plugin.Context {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		cfg = nil
+	}
+	return plugin.NewContext(Version, cfg)
+}
+
+func init() {
+	rootCmd.AddCommand(pluginsCmd)
+}
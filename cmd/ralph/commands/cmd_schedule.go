@@ -0,0 +1,97 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/mad01/ralph/internal/schedule"
+	"github.com/spf13/cobra"
+)
+
+var (
+	scheduleInterval time.Duration
+	schedulePush     bool
+)
+
+var scheduleCmd = &cobra.Command{
+	Use:   "schedule",
+	Short: "Run ralph sync periodically in the background",
+	Long: `Schedule installs a systemd user timer (Linux) or a launchd agent (macOS)
+that runs 'ralph sync --quiet' at a fixed interval, so machines stay
+converged without running apply by hand.`,
+}
+
+var scheduleInstallCmd = &cobra.Command{
+	Use:   "install",
+	Short: "Install and enable the scheduled sync",
+	Long: `Install writes and enables the systemd user timer or launchd agent, replacing
+an existing schedule. The job runs the ralph binary you invoke this command
+with, so reinstall after moving it.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		binary, err := os.Executable()
+		if err == nil {
+			binary, err = filepath.EvalSymlinks(binary)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, color.RedString("Error locating the ralph binary: %v", err))
+			os.Exit(1)
+		}
+
+		syncArgs := []string{"sync", "--quiet"}
+		if schedulePush {
+			syncArgs = append(syncArgs, "--push")
+		}
+		opts := schedule.Options{Binary: binary, Args: syncArgs, Interval: scheduleInterval}
+		if err := schedule.Install(os.Stdout, opts, dryRun); err != nil {
+			fmt.Fprintln(os.Stderr, color.RedString("Error installing schedule: %v", err))
+			os.Exit(1)
+		}
+		if !dryRun {
+			color.Green("Scheduled 'ralph %s' every %s.", strings.Join(syncArgs, " "), scheduleInterval)
+		}
+	},
+}
+
+var scheduleRemoveCmd = &cobra.Command{
+	Use:   "remove",
+	Short: "Disable and remove the scheduled sync",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := schedule.Remove(os.Stdout, dryRun); err != nil {
+			fmt.Fprintln(os.Stderr, color.RedString("Error removing schedule: %v", err))
+			os.Exit(1)
+		}
+	},
+}
+
+var scheduleStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show whether the scheduled sync is installed and when it runs",
+	Run: func(cmd *cobra.Command, args []string) {
+		installed, details, err := schedule.Status()
+		if !installed && err == nil {
+			fmt.Println("No schedule installed. Run 'ralph schedule install' to add one.")
+			return
+		}
+		if installed {
+			fmt.Println("Schedule installed.")
+		}
+		if details != "" {
+			fmt.Println(details)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, color.RedString("Error checking schedule: %v", err))
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(scheduleCmd)
+	scheduleCmd.AddCommand(scheduleInstallCmd, scheduleRemoveCmd, scheduleStatusCmd)
+	scheduleInstallCmd.Flags().DurationVar(&scheduleInterval, "interval", 6*time.Hour, "Time between runs, e.g. 30m or 6h")
+	scheduleInstallCmd.Flags().BoolVar(&schedulePush, "push", false, "Also push local commits (ralph sync --push)")
+}
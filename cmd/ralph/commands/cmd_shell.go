@@ -0,0 +1,60 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/fatih/color"
+	"github.com/mad01/ralph/internal/config"
+	"github.com/mad01/ralph/internal/shell"
+	"github.com/spf13/cobra"
+)
+
+var shellCmd = &cobra.Command{
+	Use:   "shell",
+	Short: "Inspect the managed shell aliases and functions",
+}
+
+var shellListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the aliases and functions enabled on this host, grouped by recipe",
+	Long: `List prints every alias and function enabled on this host with its command
+and description, grouped by the recipe that defines it.
+
+Set help_function under [shell] (e.g. help_function = "alias-help") to have
+apply also generate a shell function that prints the same list.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, err := config.LoadConfig()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, color.RedString("Error loading configuration: %v", err))
+			os.Exit(1)
+		}
+
+		groups := shell.Help(cfg, config.GetCurrentHost())
+		if len(groups) == 0 {
+			fmt.Println(color.YellowString("No shell aliases or functions enabled on this host."))
+			return
+		}
+		width := shell.LabelWidth(groups)
+		bold := color.New(color.Bold).SprintFunc()
+		for i, g := range groups {
+			if i > 0 {
+				fmt.Println()
+			}
+			if g.Path != "" {
+				fmt.Printf("%s %s\n", bold(g.Title()), color.HiBlackString("(%s)", g.Path))
+			} else {
+				fmt.Println(bold(g.Title()))
+			}
+			for _, e := range g.Entries {
+				label := fmt.Sprintf("%-*s", width, e.Label())
+				fmt.Printf("  %s  %s\n", color.CyanString(label), e.Detail())
+			}
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(shellCmd)
+	shellCmd.AddCommand(shellListCmd)
+}
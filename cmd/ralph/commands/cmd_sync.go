@@ -0,0 +1,96 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/fatih/color"
+	"github.com/mad01/ralph/internal/config"
+	"github.com/mad01/ralph/internal/exitcode"
+	"github.com/mad01/ralph/internal/repo"
+	"github.com/spf13/cobra"
+)
+
+var (
+	syncPush   bool
+	syncNoPull bool
+)
+
+var syncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "Pull the dotfiles repo, apply, and push local commits",
+	Long: `Sync runs the usual update workflow in one step:
+
+  1. git pull in dotfiles_repo_path. If the pull stops on merge conflicts the
+     conflicted files are listed and nothing is applied.
+  2. ralph apply (including the [git] auto_commit step, if configured).
+  3. With --push, push local commits that are not on the upstream branch yet.
+     Skipped if apply failed.
+
+The exit code is apply's, or 1 if the pull or push fails.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, stop := interruptContext(cmd.Context())
+		defer stop()
+		var w io.Writer = io.Discard
+		if verbose {
+			w = os.Stdout
+		}
+
+		cfg, err := config.LoadConfig()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, color.RedString("Error loading configuration: %v", err))
+			os.Exit(exitcode.Failure)
+		}
+		repoPath := config.ShortenHome(cfg.DotfilesRepoPath)
+
+		if !syncNoPull {
+			fmt.Printf("Pulling %s...\n", repoPath)
+			if err := repo.PullRepo(ctx, w, cfg.DotfilesRepoPath, dryRun); err != nil {
+				fmt.Fprintln(os.Stderr, color.RedString("Error pulling dotfiles repo: %v", err))
+				os.Exit(exitcode.Failure)
+			}
+		}
+
+		code := runApply(ctx)
+		if !syncPush {
+			os.Exit(code)
+		}
+		if ctx.Err() != nil {
+			fmt.Fprintln(os.Stderr, color.YellowString("Not pushing: interrupted."))
+			os.Exit(code)
+		}
+		switch code {
+		case exitcode.Failure:
+			fmt.Fprintln(os.Stderr, color.YellowString("Not pushing: apply had failures."))
+			os.Exit(code)
+		case exitcode.LockHeld:
+			fmt.Fprintln(os.Stderr, color.YellowString("Not pushing: apply did not run."))
+			os.Exit(code)
+		}
+
+		fmt.Printf("\nPushing %s...\n", repoPath)
+		pushed, err := repo.PushRepo(ctx, w, cfg.DotfilesRepoPath, dryRun)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, color.RedString("Error pushing dotfiles repo: %v", err))
+			os.Exit(exitcode.Failure)
+		}
+		switch {
+		case pushed == 0:
+			fmt.Println("Nothing to push.")
+		case dryRun:
+			color.Cyan("DRY RUN: would push %d commit(s).", pushed)
+		default:
+			color.Green("Pushed %d commit(s).", pushed)
+		}
+		os.Exit(code)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(syncCmd)
+	syncCmd.Flags().BoolVar(&syncPush, "push", false, "Push local commits after a successful apply")
+	syncCmd.Flags().BoolVar(&syncNoPull, "no-pull", false, "Skip the git pull step")
+	syncCmd.Flags().BoolVar(&keepGoing, "keep-going", false, "Continue past failing hooks, repos and builds during apply")
+	syncCmd.Flags().BoolVar(&refreshTools, "refresh-tools", false, "Re-run every tool check_command instead of using cached results")
+}
@@ -0,0 +1,55 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/fatih/color"
+	"github.com/mad01/ralph/internal/config"
+	"github.com/mad01/ralph/internal/lock"
+	"github.com/mad01/ralph/internal/ui"
+	"github.com/spf13/cobra"
+)
+
+var uiCmd = &cobra.Command{
+	Use:   "ui",
+	Short: "Interactive dashboard of managed items",
+	Long: `Opens an interactive terminal dashboard listing dotfiles, repositories, tools,
+builds and recipes with their live status.
+
+Keys:
+  ↑/↓ or j/k   move the selection
+  a            apply the selected dotfile, run the selected build, or sync the selected repo
+  u            unlink the selected dotfile (symlinks only)
+  d            diff the selected dotfile's target against its source
+  r            refresh statuses
+  q            quit`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, err := config.LoadConfig()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, color.RedString("Error loading configuration: %v", err))
+			os.Exit(1)
+		}
+
+		// Applying a dotfile or running a build from the dashboard changes
+		// state, so the apply lock is held for as long as it is open.
+		var applyLock *lock.Lock
+		if !dryRun {
+			var code int
+			if applyLock, code = acquireApplyLock(); applyLock == nil {
+				os.Exit(code)
+			}
+		}
+
+		err = ui.Run(cfg, config.GetCurrentHost(), applySingleDotfile)
+		applyLock.Release()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, color.RedString("Error running dashboard: %v", err))
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(uiCmd)
+}
@@ -0,0 +1,276 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/fatih/color"
+	"github.com/mad01/ralph/internal/config"
+	"github.com/mad01/ralph/internal/cron"
+	"github.com/mad01/ralph/internal/dotfile"
+	"github.com/mad01/ralph/internal/exitcode"
+	"github.com/mad01/ralph/internal/hooks"
+	"github.com/mad01/ralph/internal/report"
+	"github.com/mad01/ralph/internal/shell"
+	"github.com/spf13/cobra"
+)
+
+var (
+	uninstallYes        bool
+	uninstallPurgeState bool
+)
+
+var uninstallCmd = &cobra.Command{
+	Use:   "uninstall",
+	Short: "Remove everything ralph has applied",
+	Long: `Uninstall tears down what 'ralph apply' set up on this machine:
+
+  - dotfiles recorded in the manifest (symlinks, copies, rendered templates,
+    downloads and extracted archives), restoring any .bak backups apply made
+    of the files they replaced
+  - directories created with remove_on_disable, while they are empty
+  - [bin] scripts deployed into the bin directory
+  - the ralph managed block in the user crontab
+  - the generated alias and function files
+  - the ralph managed block in every shell rc file
+  - processed template files
+
+Symlinks that have since been replaced by real files, and copies that no
+longer match their source, are kept. Your config and dotfiles repository are
+never touched. With --purge-state the manifest and build/directory/bin state are
+deleted as well.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		os.Exit(runUninstall())
+	},
+}
+
+// runUninstall removes what apply set up and returns the exit code.
+func runUninstall() int {
+	var w io.Writer = io.Discard
+	if verbose || dryRun {
+		w = os.Stdout
+	}
+	rpt := &report.Report{Command: "uninstall", Strict: strict}
+	bold := color.New(color.Bold).SprintFunc()
+
+	// The config, when it loads, says where state and backups are;
+	// uninstall works from the manifest without it
+	config.LoadConfig()
+
+	if !dryRun {
+		applyLock, code := acquireApplyLock()
+		if applyLock == nil {
+			return code
+		}
+		defer applyLock.Release()
+	}
+
+	manifest, err := dotfile.LoadManifest()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, color.RedString("Error loading manifest: %v", err))
+		return exitcode.Failure
+	}
+	dirState, err := dotfile.LoadDirectoryState()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, color.RedString("Error loading directory state: %v", err))
+		return exitcode.Failure
+	}
+
+	binState, err := dotfile.LoadBinState()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, color.RedString("Error loading bin state: %v", err))
+		return exitcode.Failure
+	}
+
+	manifestCount, dirCount, binCount := len(manifest.Dotfiles), len(dirState.Directories), len(binState.Scripts)
+
+	if dryRun {
+		color.Cyan("*** DRY RUN MODE ENABLED ***")
+	} else if !uninstallYes {
+		proceed := false
+		prompt := &survey.Confirm{
+			Message: fmt.Sprintf("Remove %d dotfile(s), generated shell files and rc blocks managed by ralph?", len(manifest.Dotfiles)),
+		}
+		if err := survey.AskOne(prompt, &proceed); err != nil {
+			fmt.Fprintln(os.Stderr, color.RedString("Error during prompt: %v", err))
+			return exitcode.Failure
+		}
+		if !proceed {
+			color.Green("Uninstall cancelled.")
+			return exitcode.OK
+		}
+	}
+
+	dfPhase := rpt.AddPhase("Dotfiles")
+	names := make([]string, 0, len(manifest.Dotfiles))
+	for name := range manifest.Dotfiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %s\n", bold(name))
+		if err := dotfile.RemoveDeployed(w, manifest.Dotfiles[name], dryRun); err != nil {
+			fmt.Fprintln(os.Stderr, color.RedString("    error: %s: %v", name, err))
+			dfPhase.AddFail(name, err.Error(), err)
+			continue
+		}
+		addRemoved(dfPhase, name, "")
+		if !dryRun {
+			delete(manifest.Dotfiles, name)
+		}
+	}
+
+	dirPhase := rpt.AddPhase("Directories")
+	for name, record := range dirState.Directories {
+		fmt.Fprintf(w, "  %s\n", bold(name))
+		gone, err := dotfile.RemoveCreatedDirectories(w, record, dryRun)
+		switch {
+		case err != nil:
+			fmt.Fprintln(os.Stderr, color.RedString("    error: %s: %v", name, err))
+			dirPhase.AddFail(name, err.Error(), err)
+		case gone:
+			addRemoved(dirPhase, name, "")
+			if !dryRun {
+				delete(dirState.Directories, name)
+			}
+		default:
+			dirPhase.AddWarn(name, "not removed: directory is not empty")
+		}
+	}
+
+	if binCount > 0 {
+		binPhase := rpt.AddPhase("Bin")
+		removed, err := dotfile.PruneBinScripts(w, binState, nil, dryRun)
+		for _, name := range removed {
+			addRemoved(binPhase, name, "")
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, color.RedString("  Error removing bin scripts: %v", err))
+			binPhase.AddFail("bin", err.Error(), err)
+		}
+	}
+
+	if cron.Available() {
+		if found, err := cron.HasBlock(); err != nil {
+			rpt.AddPhase("Cron").AddFail("crontab", err.Error(), err)
+		} else if found {
+			cronPhase := rpt.AddPhase("Cron")
+			if _, err := cron.Sync(w, nil, dryRun); err != nil {
+				fmt.Fprintln(os.Stderr, color.RedString("  Error removing cron jobs: %v", err))
+				cronPhase.AddFail("crontab", err.Error(), err)
+			} else {
+				addRemoved(cronPhase, "crontab", "managed block")
+			}
+		}
+	}
+
+	shellPhase := rpt.AddPhase("Shell config")
+	for _, sh := range shell.GetSupportedShells() {
+		found, err := shell.RemoveRalphBlock(w, sh, dryRun)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, color.RedString("  Error removing managed block for %s: %v", sh, err))
+			shellPhase.AddFail(string(sh), err.Error(), err)
+		} else if found {
+			addRemoved(shellPhase, string(sh), "managed block")
+		}
+	}
+	if found, err := removeGeneratedShellFiles(w); err != nil {
+		fmt.Fprintln(os.Stderr, color.RedString("  Error removing generated shell files: %v", err))
+		shellPhase.AddFail("generated", err.Error(), err)
+	} else if found {
+		addRemoved(shellPhase, "generated", "files")
+	} else {
+		shellPhase.AddOK("generated", "")
+	}
+	if err := dotfile.RemoveProcessedTemplates(w, dryRun); err != nil {
+		fmt.Fprintln(os.Stderr, color.RedString("  Error removing processed templates: %v", err))
+		shellPhase.AddFail("templates", err.Error(), err)
+	}
+
+	if !dryRun {
+		statePhase := rpt.AddPhase("State")
+		if uninstallPurgeState {
+			purges := []struct {
+				name  string
+				purge func() error
+			}{
+				{"bin", dotfile.ResetBinState},
+				{"builds", hooks.ResetBuildState},
+				{"directories", dotfile.ResetDirectoryState},
+				{"manifest", dotfile.ResetManifest},
+			}
+			for _, p := range purges {
+				if err := p.purge(); err != nil {
+					statePhase.AddFail(p.name, err.Error(), err)
+				} else {
+					statePhase.AddOK(p.name, "purged")
+				}
+			}
+		} else {
+			// Keep entries that failed so a later uninstall can retry them.
+			if len(manifest.Dotfiles) != manifestCount {
+				if err := dotfile.SaveManifest(manifest); err != nil {
+					statePhase.AddWarn("manifest", err.Error())
+				}
+			}
+			if len(dirState.Directories) != dirCount {
+				if err := dotfile.SaveDirectoryState(dirState); err != nil {
+					statePhase.AddWarn("directories", err.Error())
+				}
+			}
+			if len(binState.Scripts) != binCount {
+				if err := dotfile.SaveBinState(binState); err != nil {
+					statePhase.AddWarn("bin", err.Error())
+				}
+			}
+		}
+	}
+
+	rpt.PrintSummary(os.Stdout, summaryVerbosity())
+	if dryRun {
+		color.Cyan("\nDRY RUN: nothing was removed.")
+		return rpt.DryRunExitCode()
+	}
+	return rpt.ExitCode()
+}
+
+// addRemoved records that name (its "what", when given) was removed, or with
+// --dry-run that it would be, as a pending change the way apply reports one.
+func addRemoved(phase *report.Phase, name, what string) {
+	if dryRun {
+		phase.AddPending(name, strings.TrimSpace("would remove "+what))
+		return
+	}
+	phase.AddOK(name, strings.TrimSpace(what+" removed"))
+}
+
+// removeGeneratedShellFiles deletes ralph's generated alias and function
+// scripts, reporting whether there were any.
+func removeGeneratedShellFiles(w io.Writer) (bool, error) {
+	dir, err := shell.GetRalphGeneratedDir()
+	if err != nil {
+		return false, err
+	}
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return false, nil
+	}
+	if dryRun {
+		fmt.Fprintf(w, "  %s would remove %s\n", color.CyanString("[dry run]"), dir)
+		return true, nil
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return false, err
+	}
+	fmt.Fprintf(w, "  %s %s\n", color.YellowString("removed"), dir)
+	return true, nil
+}
+
+func init() {
+	rootCmd.AddCommand(uninstallCmd)
+	uninstallCmd.Flags().BoolVarP(&uninstallYes, "yes", "y", false, "Do not ask for confirmation")
+	uninstallCmd.Flags().BoolVar(&uninstallPurgeState, "purge-state", false, "Also delete the manifest and build/directory/bin state")
+}
@@ -0,0 +1,94 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/mad01/ralph/internal/config"
+	"github.com/mad01/ralph/internal/dotfile"
+	"github.com/mad01/ralph/internal/exitcode"
+	"github.com/mad01/ralph/internal/report"
+	"github.com/spf13/cobra"
+)
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify [dotfile...]",
+	Short: "Check deployed dotfiles against the checksums recorded at apply",
+	Long: `Verify compares every dotfile recorded in the manifest (or only the named
+ones) against the SHA-256 checksums apply stored when it deployed them, and
+lists each file that is missing, was modified, or is no longer a symlink.
+
+For copies and rendered templates a modified file was edited in place; for
+symlinks it means the source in the dotfiles repo changed since the last
+apply. Only the manifest is read, so verify works without the config.
+
+Exits 4 if any file drifted, 1 if a dotfile could not be checked.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		manifest, err := dotfile.LoadManifest()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, color.RedString("Error loading manifest: %v", err))
+			os.Exit(exitcode.Failure)
+		}
+
+		names := args
+		if len(names) == 0 {
+			for name := range manifest.Dotfiles {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+		}
+
+		rpt := &report.Report{Command: "verify", Strict: strict}
+		phase := rpt.AddPhase("Dotfiles")
+		bold := color.New(color.Bold).SprintFunc()
+		drifted, errored := false, false
+		for _, name := range names {
+			entry, ok := manifest.Dotfiles[name]
+			if !ok {
+				fmt.Fprintln(os.Stderr, color.RedString("Error: '%s' is not in the manifest", name))
+				phase.AddFail(name, "not in the manifest", nil)
+				errored = true
+				continue
+			}
+			if len(entry.Checksums) == 0 {
+				fmt.Printf("  %s %s\n", bold(name), color.YellowString("no checksums recorded (run apply)"))
+				phase.AddSkip(name, "no checksums recorded")
+				continue
+			}
+			drifts, err := dotfile.VerifyEntry(entry)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, color.RedString("  error: %s: %v", name, err))
+				phase.AddFail(name, err.Error(), err)
+				errored = true
+				continue
+			}
+			if len(drifts) == 0 {
+				fmt.Printf("  %s %s\n", bold(name), color.GreenString("ok"))
+				phase.AddOK(name, "")
+				continue
+			}
+			fmt.Printf("  %s %s\n", bold(name), color.RedString("%d file(s) differ", len(drifts)))
+			var problems []string
+			for _, d := range drifts {
+				fmt.Printf("    %s %s\n", color.RedString("%-19s", d.Problem), config.ShortenHome(d.Path))
+				problems = append(problems, fmt.Sprintf("%s %s", config.ShortenHome(d.Path), d.Problem))
+			}
+			phase.AddFail(name, strings.Join(problems, ", "), nil)
+			drifted = true
+		}
+
+		rpt.PrintSummary(os.Stdout, summaryVerbosity())
+		code := rpt.ExitCode()
+		if drifted && !errored {
+			code = exitcode.Drift
+		}
+		os.Exit(code)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(verifyCmd)
+}
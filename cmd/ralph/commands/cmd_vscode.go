@@ -0,0 +1,56 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/fatih/color"
+	"github.com/mad01/ralph/internal/config"
+	"github.com/mad01/ralph/internal/vscode"
+	"github.com/spf13/cobra"
+)
+
+var vscodeCommand string
+
+var vscodeCmd = &cobra.Command{
+	Use:   "vscode",
+	Short: "Manage VS Code extensions",
+	Long:  `Apply installs the extensions listed in [vscode] extensions and doctor reports missing and extra ones. Use 'ralph vscode export' to capture what is installed now.`,
+}
+
+var vscodeExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Print the installed extensions as a [vscode] section",
+	Long: `Export lists the extensions installed in VS Code and prints them as a
+[vscode] section for config.toml:
+
+  ralph vscode export >> ~/.config/ralph/config.toml
+
+The editor CLI is --command, else [vscode] command from the config if it
+loads, else "code".`,
+	Run: func(cmd *cobra.Command, args []string) {
+		command := vscodeCommand
+		if command == "" {
+			command = config.DefaultVSCodeCommand
+			if cfg, err := config.LoadConfig(); err == nil {
+				command = cfg.VSCode.CommandName()
+			}
+		}
+		if !vscode.Available(command) {
+			fmt.Fprintln(os.Stderr, color.RedString("Error: '%s' not found in PATH", command))
+			os.Exit(1)
+		}
+		installed, err := vscode.Installed(command)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, color.RedString("Error: %v", err))
+			os.Exit(1)
+		}
+		fmt.Print(vscode.ExportTOML(installed))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(vscodeCmd)
+	vscodeCmd.AddCommand(vscodeExportCmd)
+	vscodeExportCmd.Flags().StringVar(&vscodeCommand, "command", "", "Editor CLI to query, e.g. codium")
+}
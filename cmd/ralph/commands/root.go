@@ -1,11 +1,21 @@
 package commands
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/fatih/color"
+	"github.com/mad01/ralph/internal/config"
+	"github.com/mad01/ralph/internal/exitcode"
+	"github.com/mad01/ralph/internal/progress"
 	"github.com/mad01/ralph/internal/report"
+	"github.com/mad01/ralph/pkg/pipeutil"
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
 )
 
 var rootCmd = &cobra.Command{
@@ -13,27 +23,96 @@ var rootCmd = &cobra.Command{
 	Short: "ralph is a tool for managing dotfiles and shell configurations.",
 	Long: `ralph helps you manage your dotfiles, shell tools, rc files, and helper functions seamlessly.
 Inspired by tools like Starship, it uses a TOML configuration file to define how your environment is set up.`,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		// Honor --no-color and the NO_COLOR convention (https://no-color.org)
+		if noColor || os.Getenv("NO_COLOR") != "" {
+			color.NoColor = true
+		}
+		// Reuse parsed recipes whose files haven't changed since the last run
+		config.CacheRecipes = !noCache
+		// Act as another machine for host filters, overlays and .Host. That
+		// only makes sense to look at: applying another host's config here
+		// would deploy its files and record this machine under its name.
+		if hostOverride != "" && !dryRun && !hostReadOnly[cmd.CommandPath()] {
+			fmt.Fprintln(os.Stderr, color.RedString("Error: --host only previews another machine; use it with --dry-run for '%s'", cmd.CommandPath()))
+			os.Exit(exitcode.Failure)
+		}
+		config.HostOverride = hostOverride
+		// Template variables declared with prompt = true can only be asked for on a terminal
+		if term.IsTerminal(int(os.Stdin.Fd())) {
+			config.Prompter = askTemplateVariable
+		}
+	},
 	Run: func(cmd *cobra.Command, args []string) {
 		// Default action when ralph is run without subcommands
 		fmt.Println("Use 'ralph --help' for more information.")
 	},
 }
 
-var dryRun bool // Global variable for the dry-run flag
-var verbose bool // Show all items in summary (including OK and skip)
-var quiet bool   // Show only failures in summary
+var dryRun bool         // Global variable for the dry-run flag
+var verbose bool        // Show all items in summary (including OK and skip)
+var quiet bool          // Show only failures in summary
+var noColor bool        // Disable colored output
+var strict bool         // Treat warnings as failures in the exit code
+var noCache bool        // Parse every recipe and rerun variable commands instead of using the caches
+var hostOverride string // Hostname to use instead of this machine's
+
+// hostReadOnly lists the commands that only read the config and this
+// machine's state, so --host may be used with them without --dry-run.
+// doctor rejects --fix with --host itself.
+var hostReadOnly = map[string]bool{
+	"ralph doctor":        true,
+	"ralph env":           true,
+	"ralph explain":       true,
+	"ralph graph":         true,
+	"ralph lint":          true,
+	"ralph list":          true,
+	"ralph machines":      true,
+	"ralph orphans":       true,
+	"ralph plugins":       true,
+	"ralph shell list":    true,
+	"ralph verify":        true,
+	"ralph version":       true,
+	"ralph vscode export": true,
+}
 
 func Execute() {
+	registerPlugins()
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
 }
 
+// interruptContext returns a context that is cancelled on Ctrl-C or
+// SIGTERM, for commands that run hooks, builds or git and should stop them
+// and wind down rather than leave things half done. After the first signal
+// the default handling is restored, so a second Ctrl-C quits immediately.
+// stop releases the signal handler.
+func interruptContext(parent context.Context) (ctx context.Context, stop context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		defer signal.Stop(signals)
+		select {
+		case <-signals:
+			fmt.Fprintln(os.Stderr, color.YellowString("\nInterrupted: stopping after the current step (Ctrl-C again to quit now)."))
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
 func init() { // This init is for the package, not a specific command
 	rootCmd.PersistentFlags().BoolVarP(&dryRun, "dry-run", "n", false, "Show what changes would be made without actually making them")
 	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Show all items in summary (including OK and skip)")
 	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "Show only failures in summary")
+	rootCmd.PersistentFlags().BoolVar(&strict, "strict", false, "Treat warnings as errors: exit 1 if anything warned (also set by strict = true in the config)")
+	rootCmd.PersistentFlags().BoolVar(&noCache, "no-cache", false, "Parse every recipe and rerun template_variables_from_commands instead of reusing cached results")
+	rootCmd.PersistentFlags().StringVar(&hostOverride, "host", "", "Preview the named host: host filters, config.<host>.toml and .Host use it instead of this machine's hostname (commands that change anything need --dry-run)")
+	rootCmd.PersistentFlags().BoolVar(&noColor, "no-color", false, "Disable colored output (also honored via the NO_COLOR env var)")
 }
 
 // summaryVerbosity returns the report verbosity level based on --verbose/--quiet flags.
@@ -46,3 +125,33 @@ func summaryVerbosity() report.Verbosity {
 	}
 	return report.VerbosityNormal
 }
+
+// printPhaseLine prints the one-line result of a finished phase. It is the
+// default progress output: --verbose shows per-item detail instead, and
+// --quiet only the final summary.
+func printPhaseLine(p *report.Phase) {
+	if verbose || quiet {
+		return
+	}
+	fmt.Println("  " + p.Line())
+}
+
+// askTemplateVariable asks for the value of a template variable declared with
+// prompt = true.
+func askTemplateVariable(name, description string) (string, error) {
+	message := fmt.Sprintf("Value for template variable '%s':", name)
+	if description != "" {
+		message = fmt.Sprintf("%s (%s):", description, name)
+	}
+	var value string
+	prompt := &survey.Input{Message: color.New(color.FgWhite, color.Bold).Sprint(message)}
+	err := survey.AskOne(prompt, &value, survey.WithValidator(survey.Required))
+	return value, err
+}
+
+// newSpinner returns a progress spinner for long-running phases. It is only
+// active at default verbosity and when stdout is a terminal, so --verbose
+// output and piped logs are left untouched.
+func newSpinner() *progress.Spinner {
+	return progress.New(os.Stdout, !verbose && pipeutil.StdoutIsTTY())
+}
@@ -0,0 +1,138 @@
+package block
+
+import "strings"
+
+// Prefix starts the marker lines of every block ralph manages in a file it
+// doesn't own entirely.
+const Prefix = "RALPH MANAGED BLOCK"
+
+// Markers are the lines that delimit a managed block. They are matched
+// against whole lines, ignoring surrounding whitespace.
+type Markers struct {
+	Begin string
+	End   string
+}
+
+// Named returns "# BEGIN RALPH MANAGED BLOCK: id" style markers, so several
+// blocks can share a file. An empty id gives the plain markers.
+func Named(id string) Markers {
+	suffix := ""
+	if id != "" {
+		suffix = ": " + id
+	}
+	return Markers{Begin: "# BEGIN " + Prefix + suffix, End: "# END " + Prefix + suffix}
+}
+
+// Lines returns the block holding body, markers included.
+func (m Markers) Lines(body []string) []string {
+	lines := append([]string{m.Begin}, body...)
+	return append(lines, m.End)
+}
+
+// splitLines splits content into lines, dropping the final newline.
+func splitLines(content string) []string {
+	if content == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimRight(content, "\n"), "\n")
+}
+
+// joinLines joins lines into file content ending in a newline, or "".
+func joinLines(lines []string) string {
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// Find returns the lines of the block in content, markers included, or nil if
+// there is none. An unterminated block runs to the end of content.
+func Find(content string, m Markers) []string {
+	var block []string
+	inBlock := false
+	for _, line := range splitLines(content) {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case !inBlock && trimmed == m.Begin:
+			inBlock = true
+			block = append(block, line)
+		case inBlock:
+			block = append(block, line)
+			if trimmed == m.End {
+				return block
+			}
+		}
+	}
+	return block
+}
+
+// Body returns the lines between the markers of the block in content, and
+// whether there is a block.
+func Body(content string, m Markers) ([]string, bool) {
+	block := Find(content, m)
+	if len(block) == 0 {
+		return nil, false
+	}
+	body := block[1:]
+	if len(body) > 0 && strings.TrimSpace(body[len(body)-1]) == m.End {
+		body = body[:len(body)-1]
+	}
+	return body, true
+}
+
+// Upsert replaces the block in content with one holding body, or appends it
+// after a blank line if there is none. Lines outside the block are kept as
+// they are. It returns the new content and whether it differs.
+func Upsert(content string, m Markers, body []string) (string, bool) {
+	var out []string
+	inBlock, placed := false, false
+	for _, line := range splitLines(content) {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case !inBlock && trimmed == m.Begin:
+			inBlock = true
+		case inBlock && trimmed == m.End:
+			inBlock = false
+			if !placed {
+				out = append(out, m.Lines(body)...)
+				placed = true
+			}
+		case !inBlock:
+			out = append(out, line)
+		}
+	}
+	if !placed {
+		if len(out) > 0 && strings.TrimSpace(out[len(out)-1]) != "" {
+			out = append(out, "")
+		}
+		out = append(out, m.Lines(body)...)
+	}
+	updated := joinLines(out)
+	return updated, updated != content
+}
+
+// Remove drops the block from content, along with the blank line that
+// separated it from the lines before. It returns the new content and whether
+// there was a block.
+func Remove(content string, m Markers) (string, bool) {
+	var out []string
+	inBlock, found := false, false
+	for _, line := range splitLines(content) {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case !inBlock && trimmed == m.Begin:
+			inBlock, found = true, true
+			if len(out) > 0 && strings.TrimSpace(out[len(out)-1]) == "" {
+				out = out[:len(out)-1]
+			}
+		case inBlock && trimmed == m.End:
+			inBlock = false
+		case !inBlock:
+			out = append(out, line)
+		}
+	}
+	if !found {
+		return content, false
+	}
+	return joinLines(out), true
+}
@@ -0,0 +1,84 @@
+package block
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNamed(t *testing.T) {
+	if m := Named(""); m.Begin != "# BEGIN RALPH MANAGED BLOCK" || m.End != "# END RALPH MANAGED BLOCK" {
+		t.Errorf("Named(\"\") = %+v", m)
+	}
+	if m := Named("zsh/local"); m.Begin != "# BEGIN RALPH MANAGED BLOCK: zsh/local" || m.End != "# END RALPH MANAGED BLOCK: zsh/local" {
+		t.Errorf("Named(\"zsh/local\") = %+v", m)
+	}
+}
+
+func TestUpsert(t *testing.T) {
+	m := Named("a")
+	tests := []struct {
+		name    string
+		content string
+		body    []string
+		want    string
+		changed bool
+	}{
+		{"empty file", "", []string{"x"}, "# BEGIN RALPH MANAGED BLOCK: a\nx\n# END RALPH MANAGED BLOCK: a\n", true},
+		{"append after blank line", "keep\n", []string{"x"}, "keep\n\n# BEGIN RALPH MANAGED BLOCK: a\nx\n# END RALPH MANAGED BLOCK: a\n", true},
+		{
+			"replace in place",
+			"before\n# BEGIN RALPH MANAGED BLOCK: a\nold\n# END RALPH MANAGED BLOCK: a\nafter\n",
+			[]string{"new", "lines"},
+			"before\n# BEGIN RALPH MANAGED BLOCK: a\nnew\nlines\n# END RALPH MANAGED BLOCK: a\nafter\n",
+			true,
+		},
+		{
+			"unchanged",
+			"before\n# BEGIN RALPH MANAGED BLOCK: a\nx\n# END RALPH MANAGED BLOCK: a\n",
+			[]string{"x"},
+			"before\n# BEGIN RALPH MANAGED BLOCK: a\nx\n# END RALPH MANAGED BLOCK: a\n",
+			false,
+		},
+		{
+			"other blocks are kept",
+			"# BEGIN RALPH MANAGED BLOCK: b\ny\n# END RALPH MANAGED BLOCK: b\n",
+			[]string{"x"},
+			"# BEGIN RALPH MANAGED BLOCK: b\ny\n# END RALPH MANAGED BLOCK: b\n\n# BEGIN RALPH MANAGED BLOCK: a\nx\n# END RALPH MANAGED BLOCK: a\n",
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, changed := Upsert(tt.content, m, tt.body)
+			if got != tt.want || changed != tt.changed {
+				t.Errorf("Upsert() = (%q, %v), want (%q, %v)", got, changed, tt.want, tt.changed)
+			}
+		})
+	}
+}
+
+func TestFindAndBody(t *testing.T) {
+	m := Named("a")
+	content := "x\n  # BEGIN RALPH MANAGED BLOCK: a\none\ntwo\n# END RALPH MANAGED BLOCK: a\ny\n"
+	if got, want := Find(content, m), []string{"  # BEGIN RALPH MANAGED BLOCK: a", "one", "two", "# END RALPH MANAGED BLOCK: a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Find() = %q, want %q", got, want)
+	}
+	if body, ok := Body(content, m); !ok || !reflect.DeepEqual(body, []string{"one", "two"}) {
+		t.Errorf("Body() = (%q, %v)", body, ok)
+	}
+	if _, ok := Body("x\n", m); ok {
+		t.Error("Body() found a block in content without one")
+	}
+}
+
+func TestRemove(t *testing.T) {
+	m := Named("a")
+	content := "keep\n\n# BEGIN RALPH MANAGED BLOCK: a\nx\n# END RALPH MANAGED BLOCK: a\nafter\n"
+	got, found := Remove(content, m)
+	if !found || got != "keep\nafter\n" {
+		t.Errorf("Remove() = (%q, %v)", got, found)
+	}
+	if got, found := Remove("keep\n", m); found || got != "keep\n" {
+		t.Errorf("Remove() without a block = (%q, %v)", got, found)
+	}
+}
@@ -0,0 +1,135 @@
+// Package clean finds broken symlinks in the directories ralph deploys into,
+// such as links left dangling when a source moved in the dotfiles repo, and
+// removes them.
+package clean
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/mad01/ralph/internal/config"
+	"github.com/mad01/ralph/internal/report"
+)
+
+// BrokenLink is a symlink whose destination does not exist.
+type BrokenLink struct {
+	Path string // Absolute path of the link
+	Dest string // Where it points, as stored in the link
+}
+
+// Options controls Remove.
+type Options struct {
+	DryRun bool
+	// Confirm, if set, is asked before each link is removed.
+	Confirm func(link BrokenLink) (bool, error)
+}
+
+// Targets returns the expanded targets of every dotfile and tool config file
+// in cfg, enabled on this host or not, and the [bin] target.
+func Targets(cfg *config.Config) []string {
+	var targets []string
+	add := func(target string) {
+		if target == "" {
+			return
+		}
+		if expanded, err := config.ExpandPath(target); err == nil {
+			targets = append(targets, expanded)
+		}
+	}
+	for _, df := range cfg.Dotfiles {
+		add(df.Target)
+	}
+	for _, t := range cfg.Tools {
+		for _, cf := range t.ConfigFiles {
+			add(cf.Target)
+		}
+	}
+	if cfg.Bin.Source != "" {
+		add(cfg.Bin.TargetDir())
+	}
+	return targets
+}
+
+// Roots returns the directories to look for broken links in, sorted: the
+// directory each target is in, and each target that is itself a directory
+// (a merged symlink_dir, a bin directory).
+func Roots(targets []string) []string {
+	seen := make(map[string]bool)
+	for _, target := range targets {
+		seen[filepath.Dir(target)] = true
+		if info, err := os.Lstat(target); err == nil && info.IsDir() {
+			seen[target] = true
+		}
+	}
+	roots := make([]string, 0, len(seen))
+	for dir := range seen {
+		roots = append(roots, dir)
+	}
+	sort.Strings(roots)
+	return roots
+}
+
+// Find returns the broken symlinks directly inside dirs, sorted by path.
+// Directories that don't exist are skipped.
+func Find(dirs []string) ([]BrokenLink, error) {
+	var links []BrokenLink
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", dir, err)
+		}
+		for _, entry := range entries {
+			if entry.Type()&os.ModeSymlink == 0 {
+				continue
+			}
+			path := filepath.Join(dir, entry.Name())
+			if _, err := os.Stat(path); !os.IsNotExist(err) {
+				continue
+			}
+			dest, err := os.Readlink(path)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read link %s: %w", path, err)
+			}
+			links = append(links, BrokenLink{Path: path, Dest: dest})
+		}
+	}
+	sort.Slice(links, func(i, j int) bool { return links[i].Path < links[j].Path })
+	return links, nil
+}
+
+// Remove deletes links, recording each in phase: removed links as changed
+// (pending in a dry run), declined ones as skipped.
+func Remove(links []BrokenLink, opts Options, phase *report.Phase) {
+	for _, link := range links {
+		name := config.ShortenHome(link.Path)
+		if opts.DryRun {
+			phase.AddPending(name, "would remove link to "+link.Dest)
+			continue
+		}
+		if opts.Confirm != nil {
+			proceed, err := opts.Confirm(link)
+			if err != nil {
+				phase.AddFail(name, "confirmation failed", err)
+				continue
+			}
+			if !proceed {
+				phase.AddSkip(name, "declined")
+				continue
+			}
+		}
+		// The link may have been fixed since it was found
+		if _, err := os.Stat(link.Path); !os.IsNotExist(err) {
+			phase.AddSkip(name, "no longer broken")
+			continue
+		}
+		if err := os.Remove(link.Path); err != nil {
+			phase.AddFail(name, err.Error(), err)
+			continue
+		}
+		phase.AddChanged(name, report.ChangeUpdated, "removed link to "+link.Dest)
+	}
+}
@@ -0,0 +1,72 @@
+package clean
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mad01/ralph/internal/report"
+)
+
+func mkdirAll(t *testing.T, dir string) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func symlink(t *testing.T, oldname, newname string) {
+	t.Helper()
+	if err := os.Symlink(oldname, newname); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFindAndRemove(t *testing.T) {
+	home := t.TempDir()
+	merged := filepath.Join(home, ".config", "nvim")
+	mkdirAll(t, merged)
+	source := filepath.Join(t.TempDir(), "zshrc")
+	if err := os.WriteFile(source, []byte("x\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	symlink(t, source, filepath.Join(home, ".zshrc"))                          // Fine
+	symlink(t, "/nonexistent/gitconfig", filepath.Join(home, ".gitconfig"))    // Broken, not ralph's
+	symlink(t, "/nonexistent/init.lua", filepath.Join(merged, "init.lua"))     // Broken, in a merged dir
+	symlink(t, "/nonexistent/deep", filepath.Join(merged, "lua"))              // Broken
+	mkdirAll(t, filepath.Join(merged, "sub"))                                  // Not searched
+	symlink(t, "/nonexistent/sub", filepath.Join(merged, "sub", "nested.lua")) // Broken, too deep
+
+	roots := Roots([]string{filepath.Join(home, ".zshrc"), merged, filepath.Join(home, "missing", "file")})
+	want := []string{home, filepath.Join(home, ".config"), merged, filepath.Join(home, "missing")}
+	if len(roots) != len(want) {
+		t.Fatalf("Roots() = %v, want %v", roots, want)
+	}
+
+	links, err := Find(roots)
+	if err != nil {
+		t.Fatalf("Find() error = %v", err)
+	}
+	if len(links) != 3 || links[0].Path != filepath.Join(home, ".config", "nvim", "init.lua") || links[2].Path != filepath.Join(home, ".gitconfig") {
+		t.Fatalf("Find() = %+v, want the three broken links next to targets", links)
+	}
+
+	rpt := &report.Report{}
+	Remove(links, Options{DryRun: true}, rpt.AddPhase("Broken links"))
+	if _, err := os.Lstat(links[0].Path); err != nil {
+		t.Error("a dry run should not remove links")
+	}
+
+	declined := links[1].Path
+	Remove(links, Options{Confirm: func(link BrokenLink) (bool, error) { return link.Path != declined, nil }}, rpt.AddPhase("Broken links"))
+	if _, err := os.Lstat(links[0].Path); !os.IsNotExist(err) {
+		t.Error("broken link should be removed")
+	}
+	if _, err := os.Lstat(declined); err != nil {
+		t.Error("declined link should be kept")
+	}
+	if _, err := os.Lstat(filepath.Join(home, ".zshrc")); err != nil {
+		t.Error("working link should be kept")
+	}
+}
@@ -0,0 +1,54 @@
+package config
+
+import "sort"
+
+// Rename is state recorded under a former name (an alias) that now belongs
+// to the item called To.
+type Rename struct {
+	From string
+	To   string
+}
+
+// DotfileAliases returns the aliases of every dotfile, keyed by its name.
+func DotfileAliases(cfg *Config) map[string][]string {
+	aliases := make(map[string][]string, len(cfg.Dotfiles))
+	for name, df := range cfg.Dotfiles {
+		aliases[name] = df.Aliases
+	}
+	return aliases
+}
+
+// BuildAliases returns the aliases of every build, keyed by its name.
+func BuildAliases(cfg *Config) map[string][]string {
+	aliases := make(map[string][]string, len(cfg.Hooks.Builds))
+	for name, build := range cfg.Hooks.Builds {
+		aliases[name] = build.Aliases
+	}
+	return aliases
+}
+
+// CarryOver moves the records kept under an alias to the current name of
+// the item, so renaming a config key doesn't orphan its state. A record the
+// current name already has wins, leaving the alias's where it is. It returns
+// the moves, sorted by the current name.
+func CarryOver[T any](records map[string]T, aliases map[string][]string) []Rename {
+	var renames []Rename
+	for name, former := range aliases {
+		for _, alias := range former {
+			record, ok := records[alias]
+			if _, exists := records[name]; !ok || exists {
+				continue
+			}
+			delete(records, alias)
+			records[name] = record
+			renames = append(renames, Rename{From: alias, To: name})
+		}
+	}
+	sort.Slice(renames, func(i, j int) bool {
+		if renames[i].To != renames[j].To {
+			return renames[i].To < renames[j].To
+		}
+		return renames[i].From < renames[j].From
+	})
+	return renames
+}
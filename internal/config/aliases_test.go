@@ -0,0 +1,28 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCarryOver(t *testing.T) {
+	records := map[string]int{"nvim": 1, "zsh": 2, "old_git": 3, "git": 4, "kept": 5}
+	aliases := map[string][]string{
+		"nvim_init": {"nvim"},
+		"zshrc":     {"zsh_old", "zsh"},
+		"git":       {"old_git"},
+		"kept":      nil,
+	}
+
+	renames := CarryOver(records, aliases)
+
+	wantRenames := []Rename{{From: "nvim", To: "nvim_init"}, {From: "zsh", To: "zshrc"}}
+	if !reflect.DeepEqual(renames, wantRenames) {
+		t.Errorf("CarryOver() = %v, want %v", renames, wantRenames)
+	}
+	// git already has a record: it wins and old_git's stays where it is
+	wantRecords := map[string]int{"nvim_init": 1, "zshrc": 2, "old_git": 3, "git": 4, "kept": 5}
+	if !reflect.DeepEqual(records, wantRecords) {
+		t.Errorf("records = %v, want %v", records, wantRecords)
+	}
+}
@@ -0,0 +1,58 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// configuredBackup is the [backup] section of the loaded config.
+var configuredBackup BackupConfig
+
+// SetBackupConfig sets where backups of replaced targets go, from the
+// [backup] section of the loaded config.
+func SetBackupConfig(b BackupConfig) {
+	configuredBackup = b
+}
+
+// BackupKeep returns how many backups are kept per target.
+func BackupKeep() int {
+	if configuredBackup.Keep < 1 {
+		return 1
+	}
+	return configuredBackup.Keep
+}
+
+// BackupPath returns the path of the nth backup of target, 0 being the
+// newest: target plus the suffix, or the same path relative to home under
+// the backup dir. Older backups add ".1", ".2", ...
+func BackupPath(target string, n int) (string, error) {
+	suffix := configuredBackup.Suffix
+	if suffix == "" {
+		suffix = DefaultBackupSuffix
+	}
+	path := target + suffix
+	if configuredBackup.Dir != "" {
+		dir, err := ExpandPath(configuredBackup.Dir)
+		if err != nil {
+			return "", fmt.Errorf("failed to expand backup dir '%s': %w", configuredBackup.Dir, err)
+		}
+		path = filepath.Join(dir, backupRelPath(target)+suffix)
+	}
+	if n > 0 {
+		path = fmt.Sprintf("%s.%d", path, n)
+	}
+	return path, nil
+}
+
+// backupRelPath returns target relative to home, or relative to the root of
+// its volume when it is outside home.
+func backupRelPath(target string) string {
+	if home, err := os.UserHomeDir(); err == nil {
+		if rel, err := filepath.Rel(home, target); err == nil && !strings.HasPrefix(rel, "..") {
+			return rel
+		}
+	}
+	return strings.TrimLeft(target[len(filepath.VolumeName(target)):], `/\`)
+}
@@ -0,0 +1,23 @@
+package config
+
+import "path/filepath"
+
+// ApplyBuildRepos resolves the working directory of every build linked to a
+// repo with repo = "<name>": without a working_dir the build runs in the
+// repo's target, and a relative working_dir is taken inside it. Builds with
+// run = "once" then re-run whenever the repo's checkout changes.
+func ApplyBuildRepos(cfg *Config) {
+	for name, build := range cfg.Hooks.Builds {
+		rp, ok := cfg.Repos[build.Repo]
+		if build.Repo == "" || !ok {
+			continue
+		}
+		switch {
+		case build.WorkingDir == "":
+			build.WorkingDir = rp.Target
+		case !filepath.IsAbs(build.WorkingDir) && build.WorkingDir[0] != '~':
+			build.WorkingDir = filepath.Join(rp.Target, build.WorkingDir)
+		}
+		cfg.Hooks.Builds[name] = build
+	}
+}
@@ -0,0 +1,31 @@
+package config
+
+import "testing"
+
+func TestApplyBuildRepos(t *testing.T) {
+	cfg := &Config{
+		Repos: map[string]Repo{"fzf": {URL: "https://github.com/junegunn/fzf", Target: "~/src/fzf"}},
+		Hooks: HooksConfig{Builds: map[string]Build{
+			"default":  {Repo: "fzf", Commands: []string{"make"}, Run: "once"},
+			"relative": {Repo: "fzf", WorkingDir: "shell", Commands: []string{"make"}, Run: "once"},
+			"absolute": {Repo: "fzf", WorkingDir: "/opt/fzf", Commands: []string{"make"}, Run: "once"},
+			"home":     {Repo: "fzf", WorkingDir: "~/build", Commands: []string{"make"}, Run: "once"},
+			"unlinked": {WorkingDir: "tools", Commands: []string{"make"}, Run: "once"},
+		}},
+	}
+
+	ApplyBuildRepos(cfg)
+
+	want := map[string]string{
+		"default":  "~/src/fzf",
+		"relative": "~/src/fzf/shell",
+		"absolute": "/opt/fzf",
+		"home":     "~/build",
+		"unlinked": "tools",
+	}
+	for name, dir := range want {
+		if got := cfg.Hooks.Builds[name].WorkingDir; got != dir {
+			t.Errorf("build %s: WorkingDir = %q, want %q", name, got, dir)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// CommandVariablesCacheFileName is the state file command variable values
+// are cached in.
+const CommandVariablesCacheFileName = "command-variables.json"
+
+// CommandVariablesTTL is how long the output of a
+// template_variables_from_commands command is reused.
+const CommandVariablesTTL = time.Hour
+
+// commandVariableTimeout bounds a single variable command.
+const commandVariableTimeout = 10 * time.Second
+
+// runVariableCommand runs a template variable command and returns its output.
+// This is a variable to allow for easier testing.
+var runVariableCommand = runVariableCommandInternal
+
+// runVariableCommandInternal runs command with sh -c and returns its stdout
+// without surrounding whitespace.
+func runVariableCommandInternal(command string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), commandVariableTimeout)
+	defer cancel()
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if ctx.Err() != nil {
+		return "", fmt.Errorf("timed out after %s", commandVariableTimeout)
+	}
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%w: %s", err, msg)
+		}
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// cachedCommandValue is the output of a variable command and when it ran.
+type cachedCommandValue struct {
+	Value string    `json:"value"`
+	RanAt time.Time `json:"ran_at"`
+}
+
+// LoadCommandVariables runs the template_variables_from_commands commands and
+// merges their output into cfg.TemplateVariables. With CacheRecipes set, a
+// command's output is reused for CommandVariablesTTL from the state
+// directory; failed commands are never cached. A key that is also defined in
+// template_variables, a recipe or template_variables_sops is an error.
+func LoadCommandVariables(cfg *Config) error {
+	if len(cfg.TemplateVariablesFromCommands) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(cfg.TemplateVariablesFromCommands))
+	for name := range cfg.TemplateVariablesFromCommands {
+		if _, exists := cfg.TemplateVariables[name]; exists {
+			return fmt.Errorf("template variable '%s' defined in multiple locations: template_variables_from_commands and main config (or a recipe or template_variables_sops)", name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	cachePath := ""
+	cache := make(map[string]cachedCommandValue)
+	if CacheRecipes {
+		if path, err := StateFilePath(CommandVariablesCacheFileName); err == nil {
+			cachePath = path
+			if data, err := os.ReadFile(path); err == nil {
+				json.Unmarshal(data, &cache)
+			}
+		}
+	}
+
+	if cfg.TemplateVariables == nil {
+		cfg.TemplateVariables = make(map[string]interface{})
+	}
+	fresh := make(map[string]cachedCommandValue, len(names))
+	dirty := false
+	for _, name := range names {
+		command := cfg.TemplateVariablesFromCommands[name]
+		cached, ok := cache[command]
+		if !ok || time.Since(cached.RanAt) >= CommandVariablesTTL {
+			value, err := runVariableCommand(command)
+			if err != nil {
+				return fmt.Errorf("template variable '%s': command '%s' failed: %w", name, command, err)
+			}
+			cached = cachedCommandValue{Value: value, RanAt: time.Now()}
+			dirty = true
+		}
+		fresh[command] = cached
+		cfg.TemplateVariables[name] = cached.Value
+	}
+
+	if cachePath != "" && (dirty || len(fresh) != len(cache)) {
+		saveCommandVariableCache(cachePath, fresh)
+	}
+	return nil
+}
+
+// saveCommandVariableCache writes the cache, keeping only the commands of
+// this run. Failing to write it is not an error: the commands run again next
+// time.
+func saveCommandVariableCache(path string, cache map[string]cachedCommandValue) {
+	data, err := json.Marshal(cache)
+	if err != nil {
+		return
+	}
+	// Command output can be a secret (a token read from a password
+	// manager), so only the user may read the cache: CreateTemp makes the
+	// file 0600.
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return
+	}
+	tmp := f.Name()
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+	}
+}
@@ -0,0 +1,131 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeVariableCommand replaces runVariableCommand for the duration of a test,
+// counting the commands it was asked to run.
+func fakeVariableCommand(t *testing.T, outputs map[string]string) map[string]int {
+	t.Helper()
+	ran := make(map[string]int)
+	orig := runVariableCommand
+	runVariableCommand = func(command string) (string, error) {
+		ran[command]++
+		out, ok := outputs[command]
+		if !ok {
+			return "", errors.New("exit status 1")
+		}
+		return out, nil
+	}
+	t.Cleanup(func() { runVariableCommand = orig })
+	return ran
+}
+
+func TestLoadCommandVariables(t *testing.T) {
+	fakeVariableCommand(t, map[string]string{"git config user.email": "me@example.com"})
+	cfg := &Config{
+		TemplateVariables:             map[string]interface{}{"name": "Me"},
+		TemplateVariablesFromCommands: map[string]string{"email": "git config user.email"},
+	}
+	if err := LoadCommandVariables(cfg); err != nil {
+		t.Fatalf("LoadCommandVariables failed: %v", err)
+	}
+	if cfg.TemplateVariables["email"] != "me@example.com" || cfg.TemplateVariables["name"] != "Me" {
+		t.Errorf("TemplateVariables = %v", cfg.TemplateVariables)
+	}
+}
+
+func TestLoadCommandVariables_Cache(t *testing.T) {
+	t.Setenv(StateDirEnv, t.TempDir())
+	CacheRecipes = true
+	defer func() { CacheRecipes = false }()
+	ran := fakeVariableCommand(t, map[string]string{"hostname -f": "box.example.com"})
+
+	for i := 0; i < 2; i++ {
+		cfg := &Config{TemplateVariablesFromCommands: map[string]string{"fqdn": "hostname -f"}}
+		if err := LoadCommandVariables(cfg); err != nil {
+			t.Fatalf("LoadCommandVariables failed: %v", err)
+		}
+		if cfg.TemplateVariables["fqdn"] != "box.example.com" {
+			t.Errorf("fqdn = %v", cfg.TemplateVariables["fqdn"])
+		}
+	}
+	if ran["hostname -f"] != 1 {
+		t.Errorf("command ran %d times, want 1 (second load from the cache)", ran["hostname -f"])
+	}
+	path, _ := StateFilePath(CommandVariablesCacheFileName)
+	if info, err := os.Stat(path); err != nil {
+		t.Errorf("cache not written: %v", err)
+	} else if info.Mode().Perm() != 0600 {
+		t.Errorf("cache mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	CacheRecipes = false
+	cfg := &Config{TemplateVariablesFromCommands: map[string]string{"fqdn": "hostname -f"}}
+	if err := LoadCommandVariables(cfg); err != nil {
+		t.Fatalf("LoadCommandVariables failed: %v", err)
+	}
+	if ran["hostname -f"] != 2 {
+		t.Errorf("command ran %d times, want 2 with the cache off", ran["hostname -f"])
+	}
+}
+
+func TestSaveCommandVariableCache_Mode(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "state")
+	path := filepath.Join(dir, CommandVariablesCacheFileName)
+	saveCommandVariableCache(path, map[string]cachedCommandValue{"token": {Value: "s3cret"}})
+
+	info, err := os.Stat(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0700 {
+		t.Errorf("cache directory mode = %v, want 0700", info.Mode().Perm())
+	}
+
+	// A cache written by an older version readable by everyone is replaced
+	if err := os.Chmod(path, 0644); err != nil {
+		t.Fatal(err)
+	}
+	saveCommandVariableCache(path, map[string]cachedCommandValue{"token": {Value: "s3cret"}})
+	if info, err = os.Stat(path); err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("cache mode = %v, want 0600", info.Mode().Perm())
+	}
+}
+
+func TestLoadCommandVariables_Errors(t *testing.T) {
+	fakeVariableCommand(t, map[string]string{"echo hi": "hi"})
+
+	cfg := &Config{
+		TemplateVariables:             map[string]interface{}{"email": "me@example.com"},
+		TemplateVariablesFromCommands: map[string]string{"email": "echo hi"},
+	}
+	if err := LoadCommandVariables(cfg); err == nil || !strings.Contains(err.Error(), "'email' defined in multiple locations") {
+		t.Errorf("error = %v, want a duplicate variable error", err)
+	}
+
+	cfg = &Config{TemplateVariablesFromCommands: map[string]string{"email": "git config user.email"}}
+	if err := LoadCommandVariables(cfg); err == nil || !strings.Contains(err.Error(), "command 'git config user.email' failed") {
+		t.Errorf("error = %v, want a failed command error", err)
+	}
+}
+
+func TestRunVariableCommand(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "v"), []byte("1.2.3\n"), 0644)
+	out, err := runVariableCommandInternal("cat " + filepath.Join(dir, "v"))
+	if err != nil || out != "1.2.3" {
+		t.Errorf("runVariableCommandInternal() = %q, %v, want 1.2.3", out, err)
+	}
+	if _, err := runVariableCommandInternal("echo oops >&2; exit 3"); err == nil || !strings.Contains(err.Error(), "oops") {
+		t.Errorf("error = %v, want stderr in the error", err)
+	}
+}
@@ -0,0 +1,106 @@
+package config
+
+import (
+	"os"
+	"regexp"
+	"strings"
+)
+
+// bracedVarPattern matches ${VAR} environment variable references.
+var bracedVarPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// expandBraced replaces ${VAR} in s with the variable's value, or nothing
+// when it is unset. Bare $VAR is left alone.
+func expandBraced(s string) string {
+	if !strings.Contains(s, "${") {
+		return s
+	}
+	return bracedVarPattern.ReplaceAllStringFunc(s, func(token string) string {
+		return os.Getenv(token[2 : len(token)-1])
+	})
+}
+
+// ApplyEnvExpansion expands ${VAR} in the config fields that aren't paths
+// passed through ExpandPath: build working_dir, repo urls, hook commands and
+// template variable string values (top level and per-dotfile vars). It runs
+// on the config files and recipes only; sops values and config.local.toml
+// answers are merged afterwards and never expanded.
+//
+// no_expand lists the keys to leave as written, e.g. "template_variables.token"
+// or "hooks.post_apply.setup"; a key also covers everything under it.
+func ApplyEnvExpansion(cfg *Config) {
+	skip := func(key string) bool {
+		for _, k := range cfg.NoExpand {
+			if key == k || strings.HasPrefix(key, k+".") {
+				return true
+			}
+		}
+		return false
+	}
+	expand := func(key, s string) string {
+		if skip(key) {
+			return s
+		}
+		return expandBraced(s)
+	}
+
+	for name, build := range cfg.Hooks.Builds {
+		build.WorkingDir = expand("hooks.builds."+name+".working_dir", build.WorkingDir)
+		cfg.Hooks.Builds[name] = build
+	}
+	for name, rp := range cfg.Repos {
+		rp.URL = expand("repos."+name+".url", rp.URL)
+		cfg.Repos[name] = rp
+	}
+	expandGroups := func(key string, groups []HookGroup) {
+		for i, g := range groups {
+			groupKey := key
+			if g.Name != "" {
+				groupKey += "." + g.Name
+			}
+			for j, c := range g.Commands {
+				groups[i].Commands[j] = expand(groupKey, c)
+			}
+		}
+	}
+	expandGroups("hooks.pre_apply", cfg.Hooks.PreApply)
+	expandGroups("hooks.post_apply", cfg.Hooks.PostApply)
+	for key, scripts := range map[string]map[string][]string{"hooks.pre_link": cfg.Hooks.PreLink, "hooks.post_link": cfg.Hooks.PostLink} {
+		for name, commands := range scripts {
+			for i, c := range commands {
+				commands[i] = expand(key+"."+name, c)
+			}
+		}
+	}
+
+	expandVars(cfg.TemplateVariables, "template_variables", expand)
+	for name, df := range cfg.Dotfiles {
+		expandVars(df.Vars, "dotfiles."+name+".vars", expand)
+	}
+}
+
+// expandVars expands the string values of vars in place, descending into
+// tables and arrays.
+func expandVars(vars map[string]interface{}, key string, expand func(key, s string) string) {
+	for k, v := range vars {
+		vars[k] = expandValue(v, key+"."+k, expand)
+	}
+}
+
+func expandValue(v interface{}, key string, expand func(key, s string) string) interface{} {
+	switch v := v.(type) {
+	case string:
+		return expand(key, v)
+	case map[string]interface{}:
+		expandVars(v, key, expand)
+	case []interface{}:
+		for i, e := range v {
+			v[i] = expandValue(e, key, expand)
+		}
+	case []map[string]interface{}:
+		for _, m := range v {
+			expandVars(m, key, expand)
+		}
+	}
+	return v
+}
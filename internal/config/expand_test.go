@@ -0,0 +1,81 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestApplyEnvExpansion(t *testing.T) {
+	t.Setenv("RALPH_TEST_USER", "mad01")
+	t.Setenv("RALPH_TEST_DIR", "/opt/src")
+
+	cfg := &Config{
+		Repos: map[string]Repo{"nvim": {URL: "git@github.com:${RALPH_TEST_USER}/nvim.git", Target: "~/src/nvim"}},
+		Hooks: HooksConfig{
+			PreApply:  []HookGroup{{Commands: []string{"echo ${RALPH_TEST_USER}"}}},
+			PostApply: []HookGroup{{Name: "raw", Commands: []string{"echo ${RALPH_TEST_USER}"}}},
+			PostLink:  map[string][]string{"kitty": {"touch ${RALPH_TEST_DIR}/kitty"}},
+			Builds:    map[string]Build{"fzf": {WorkingDir: "${RALPH_TEST_DIR}/fzf", Commands: []string{"make"}}},
+		},
+		TemplateVariables: map[string]interface{}{
+			"user":    "${RALPH_TEST_USER}",
+			"bare":    "$RALPH_TEST_USER",
+			"unset":   "[${RALPH_TEST_UNSET}]",
+			"token":   "${RALPH_TEST_USER}",
+			"port":    int64(8080),
+			"nested":  map[string]interface{}{"list": []interface{}{"${RALPH_TEST_DIR}", int64(1)}},
+			"servers": []map[string]interface{}{{"host": "${RALPH_TEST_USER}.local"}},
+		},
+		Dotfiles: map[string]Dotfile{"kitty": {Vars: map[string]interface{}{"home": "${RALPH_TEST_DIR}"}}},
+		NoExpand: []string{"template_variables.token", "hooks.post_apply.raw"},
+	}
+
+	ApplyEnvExpansion(cfg)
+
+	if got := cfg.Repos["nvim"].URL; got != "git@github.com:mad01/nvim.git" {
+		t.Errorf("repo url = %q", got)
+	}
+	if got := cfg.Hooks.PreApply[0].Commands[0]; got != "echo mad01" {
+		t.Errorf("pre_apply command = %q", got)
+	}
+	if got := cfg.Hooks.PostApply[0].Commands[0]; got != "echo ${RALPH_TEST_USER}" {
+		t.Errorf("no_expand post_apply group command = %q, want it as written", got)
+	}
+	if got := cfg.Hooks.PostLink["kitty"][0]; got != "touch /opt/src/kitty" {
+		t.Errorf("post_link command = %q", got)
+	}
+	if got := cfg.Hooks.Builds["fzf"].WorkingDir; got != "/opt/src/fzf" {
+		t.Errorf("build working_dir = %q", got)
+	}
+	if got := cfg.Dotfiles["kitty"].Vars["home"]; got != "/opt/src" {
+		t.Errorf("dotfile var = %v", got)
+	}
+
+	want := map[string]interface{}{
+		"user":    "mad01",
+		"bare":    "$RALPH_TEST_USER",
+		"unset":   "[]",
+		"token":   "${RALPH_TEST_USER}",
+		"port":    int64(8080),
+		"nested":  map[string]interface{}{"list": []interface{}{"/opt/src", int64(1)}},
+		"servers": []map[string]interface{}{{"host": "mad01.local"}},
+	}
+	if !reflect.DeepEqual(cfg.TemplateVariables, want) {
+		t.Errorf("template variables = %#v, want %#v", cfg.TemplateVariables, want)
+	}
+}
+
+func TestApplyEnvExpansion_NoExpandPrefix(t *testing.T) {
+	t.Setenv("RALPH_TEST_USER", "mad01")
+	cfg := &Config{
+		TemplateVariables: map[string]interface{}{"a": "${RALPH_TEST_USER}", "b": map[string]interface{}{"c": "${RALPH_TEST_USER}"}},
+		NoExpand:          []string{"template_variables"},
+	}
+
+	ApplyEnvExpansion(cfg)
+
+	want := map[string]interface{}{"a": "${RALPH_TEST_USER}", "b": map[string]interface{}{"c": "${RALPH_TEST_USER}"}}
+	if !reflect.DeepEqual(cfg.TemplateVariables, want) {
+		t.Errorf("template variables = %#v, want them as written", cfg.TemplateVariables)
+	}
+}
@@ -0,0 +1,86 @@
+package config
+
+import (
+	"regexp"
+	"strings"
+)
+
+// scpURLPattern matches scp-like git URLs: [user@]host:path.
+var scpURLPattern = regexp.MustCompile(`^(?:([^@/:]+)@)?([^/:]+):(.+)$`)
+
+// ApplyURLRewrites replaces the URL of every repo with RewriteURL's result,
+// so clones and updates use it.
+func ApplyURLRewrites(cfg *Config) {
+	if len(cfg.Git.URLRewrites) == 0 && cfg.Git.Prefer == "" {
+		return
+	}
+	for name, rp := range cfg.Repos {
+		rp.URL = RewriteURL(cfg.Git, rp.URL)
+		cfg.Repos[name] = rp
+	}
+}
+
+// RewriteURL returns the URL a repo is cloned from. Like git's insteadOf,
+// the longest url_rewrites prefix that matches is replaced. Without a match,
+// prefer = "ssh" turns https://host/path into git@host:path and prefer =
+// "https" turns git@host:path and ssh://git@host/path into
+// https://host/path. Other URLs are returned unchanged.
+func RewriteURL(git GitConfig, url string) string {
+	best := ""
+	for prefix := range git.URLRewrites {
+		if strings.HasPrefix(url, prefix) && len(prefix) > len(best) {
+			best = prefix
+		}
+	}
+	if best != "" {
+		return git.URLRewrites[best] + strings.TrimPrefix(url, best)
+	}
+
+	switch git.Prefer {
+	case "ssh":
+		if rest, ok := strings.CutPrefix(url, "https://"); ok {
+			if host, path, ok := strings.Cut(rest, "/"); ok && !strings.Contains(host, "@") {
+				return "git@" + host + ":" + path
+			}
+		}
+	case "https":
+		if rest, ok := strings.CutPrefix(url, "ssh://"); ok {
+			_, hostPath, _ := strings.Cut(rest, "@")
+			if hostPath == "" {
+				hostPath = rest
+			}
+			if host, path, ok := strings.Cut(hostPath, "/"); ok {
+				host, _, _ = strings.Cut(host, ":") // Drop the ssh port
+				return "https://" + host + "/" + path
+			}
+		}
+		if !strings.Contains(url, "://") {
+			if m := scpURLPattern.FindStringSubmatch(url); m != nil {
+				return "https://" + m[2] + "/" + m[3]
+			}
+		}
+	}
+	return url
+}
+
+// SameRepoURL reports whether two git URLs name the same repository,
+// ignoring the protocol, user and a trailing .git: git@github.com:a/b.git
+// and https://github.com/a/b are the same.
+func SameRepoURL(a, b string) bool {
+	return normalizeRepoURL(a) == normalizeRepoURL(b)
+}
+
+// normalizeRepoURL reduces a git URL to host/path.
+func normalizeRepoURL(url string) string {
+	if scheme, rest, ok := strings.Cut(url, "://"); ok && scheme != "" {
+		if _, hostPath, ok := strings.Cut(rest, "@"); ok {
+			rest = hostPath
+		}
+		host, path, _ := strings.Cut(rest, "/")
+		host, _, _ = strings.Cut(host, ":")
+		url = host + "/" + path
+	} else if m := scpURLPattern.FindStringSubmatch(url); m != nil {
+		url = m[2] + "/" + m[3]
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(strings.ToLower(url), "/"), ".git")
+}
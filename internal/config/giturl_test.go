@@ -0,0 +1,61 @@
+package config
+
+import "testing"
+
+func TestRewriteURL(t *testing.T) {
+	rewrites := map[string]string{
+		"https://github.com/":      "git@github.com:",
+		"https://github.com/work/": "git@github-work:work/",
+	}
+	tests := []struct {
+		name   string
+		git    GitConfig
+		url    string
+		expect string
+	}{
+		{"no config", GitConfig{}, "https://github.com/a/b.git", "https://github.com/a/b.git"},
+		{"rewrite", GitConfig{URLRewrites: rewrites}, "https://github.com/a/b.git", "git@github.com:a/b.git"},
+		{"longest prefix wins", GitConfig{URLRewrites: rewrites}, "https://github.com/work/api.git", "git@github-work:work/api.git"},
+		{"rewrite beats prefer", GitConfig{URLRewrites: rewrites, Prefer: "https"}, "https://github.com/a/b", "git@github.com:a/b"},
+		{"prefer ssh", GitConfig{Prefer: "ssh"}, "https://gitlab.com/a/b.git", "git@gitlab.com:a/b.git"},
+		{"prefer ssh keeps ssh", GitConfig{Prefer: "ssh"}, "git@gitlab.com:a/b.git", "git@gitlab.com:a/b.git"},
+		{"prefer https from scp form", GitConfig{Prefer: "https"}, "git@github.com:a/b.git", "https://github.com/a/b.git"},
+		{"prefer https from ssh url", GitConfig{Prefer: "https"}, "ssh://git@example.com:2222/a/b.git", "https://example.com/a/b.git"},
+		{"prefer https keeps local path", GitConfig{Prefer: "https"}, "/srv/git/b.git", "/srv/git/b.git"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RewriteURL(tt.git, tt.url); got != tt.expect {
+				t.Errorf("RewriteURL(%q) = %q, want %q", tt.url, got, tt.expect)
+			}
+		})
+	}
+}
+
+func TestSameRepoURL(t *testing.T) {
+	tests := []struct {
+		a, b   string
+		expect bool
+	}{
+		{"git@github.com:a/b.git", "https://github.com/a/b", true},
+		{"ssh://git@github.com/a/b.git", "https://github.com/a/b.git", true},
+		{"https://github.com/a/b.git", "https://github.com/a/c.git", false},
+		{"git@github.com:a/b.git", "git@gitlab.com:a/b.git", false},
+	}
+	for _, tt := range tests {
+		if got := SameRepoURL(tt.a, tt.b); got != tt.expect {
+			t.Errorf("SameRepoURL(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.expect)
+		}
+	}
+}
+
+func TestApplyURLRewrites(t *testing.T) {
+	cfg := &Config{
+		Git:   GitConfig{Prefer: "ssh"},
+		Repos: map[string]Repo{"tpm": {URL: "https://github.com/tmux-plugins/tpm", Target: "~/.tmux/plugins/tpm"}},
+	}
+	ApplyURLRewrites(cfg)
+	if got := cfg.Repos["tpm"].URL; got != "git@github.com:tmux-plugins/tpm" {
+		t.Errorf("URL = %q, want the ssh form", got)
+	}
+}
@@ -0,0 +1,22 @@
+package config
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Hash returns a SHA-256 of the merged config as loaded, recipes and
+// overlays included, to tell whether it changed since the last apply. Fields
+// filled in while loading (origins, loaded recipes) are left out.
+func Hash(cfg *Config) (string, error) {
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(cfg); err != nil {
+		return "", fmt.Errorf("failed to encode config: %w", err)
+	}
+	sum := sha256.Sum256(buf.Bytes())
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,23 @@
+package config
+
+import "testing"
+
+func TestHash(t *testing.T) {
+	cfg := &Config{
+		DotfilesRepoPath: "~/dotfiles",
+		Dotfiles:         map[string]Dotfile{"zsh": {Source: "zshrc", Target: "~/.zshrc"}, "git": {Source: "gitconfig", Target: "~/.gitconfig"}},
+		Origins:          map[string]ItemOrigin{"dotfile:zsh": {Recipe: "zsh"}},
+	}
+	first, err := Hash(cfg)
+	if err != nil {
+		t.Fatalf("Hash() error = %v", err)
+	}
+	cfg.Origins = nil
+	if again, _ := Hash(cfg); again != first {
+		t.Error("Hash() should not depend on fields filled in while loading")
+	}
+	cfg.Dotfiles["zsh"] = Dotfile{Source: "zshrc", Target: "~/.zshrc.d/main"}
+	if changed, _ := Hash(cfg); changed == first {
+		t.Error("Hash() should change with the config")
+	}
+}
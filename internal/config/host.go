@@ -5,8 +5,16 @@ import (
 	"strings"
 )
 
-// GetCurrentHost returns the lowercase hostname of the current machine
+// HostOverride replaces the hostname GetCurrentHost returns when set, to
+// see what another machine would get (--host). Empty = the real hostname.
+var HostOverride string
+
+// GetCurrentHost returns the lowercase hostname of the current machine, or
+// HostOverride if set
 func GetCurrentHost() string {
+	if HostOverride != "" {
+		return strings.ToLower(HostOverride)
+	}
 	hostname, err := os.Hostname()
 	if err != nil {
 		return ""
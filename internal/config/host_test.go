@@ -12,6 +12,14 @@ func TestGetCurrentHost_ReturnsLowercase(t *testing.T) {
 	}
 }
 
+func TestGetCurrentHost_Override(t *testing.T) {
+	HostOverride = "Work-Laptop"
+	defer func() { HostOverride = "" }()
+	if host := GetCurrentHost(); host != "work-laptop" {
+		t.Errorf("GetCurrentHost() = %q, want the lowercased override", host)
+	}
+}
+
 func TestShouldApplyForHost_EmptyHosts_ReturnsTrue(t *testing.T) {
 	if !ShouldApplyForHost([]string{}, "anyhost") {
 		t.Error("ShouldApplyForHost() with empty hosts should return true")
@@ -4,8 +4,6 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-
-	"github.com/BurntSushi/toml"
 )
 
 // DefaultConfigFileName is the expected name of the configuration file.
@@ -35,31 +33,74 @@ func LoadConfigWithHost(host string) (*Config, error) {
 		return nil, fmt.Errorf("configuration file not found at %s. Run 'ralph init' to create one", configPath)
 	}
 
+	currentHost := host
+	if currentHost == "" {
+		currentHost = GetCurrentHost()
+	}
+
+	// config.<os>.toml and config.<host>.toml are merged over config.toml
 	var cfg Config
-	if _, err := toml.DecodeFile(configPath, &cfg); err != nil {
-		return nil, fmt.Errorf("failed to decode config file %s: %w", configPath, err)
+	overlays, err := decodeConfigWithOverlays(configPath, currentHost, &cfg)
+	if err != nil {
+		return nil, err
 	}
+	cfg.Overlays = overlays
 
 	// Validate the base config first
 	if err := ValidateConfig(&cfg); err != nil {
 		return nil, fmt.Errorf("configuration validation failed: %w", err)
 	}
 
+	SetStateDir(cfg.StateDir)
+	SetBackupConfig(cfg.Backup)
+
 	// Process recipes if configured
-	currentHost := host
-	if currentHost == "" {
-		currentHost = GetCurrentHost()
-	}
 
 	if err := ProcessRecipes(&cfg, currentHost); err != nil {
 		return nil, fmt.Errorf("recipe processing failed: %w", err)
 	}
 
+	// Per-machine enable/hosts for aliases and functions from any source
+	if err := ApplyShellOverrides(&cfg); err != nil {
+		return nil, fmt.Errorf("applying shell overrides failed: %w", err)
+	}
+
+	// zsh plugins from urls become repos, so they are cloned like any other
+	if err := ApplyZshPlugins(&cfg); err != nil {
+		return nil, fmt.Errorf("zsh plugins: %w", err)
+	}
+
+	// Expand ${VAR} before secrets and local answers are merged in
+	ApplyEnvExpansion(&cfg)
+
+	// Clone and update repos from their rewritten URLs
+	ApplyURLRewrites(&cfg)
+
+	if err := LoadSopsVariables(&cfg); err != nil {
+		return nil, fmt.Errorf("loading encrypted template variables failed: %w", err)
+	}
+
+	if err := LoadCommandVariables(&cfg); err != nil {
+		return nil, fmt.Errorf("loading template variables from commands failed: %w", err)
+	}
+
+	if err := ApplyLocalConfig(&cfg); err != nil {
+		return nil, fmt.Errorf("loading local configuration failed: %w", err)
+	}
+
+	// Detect templates by extension once all dotfiles are known
+	if cfg.AutoTemplate {
+		ApplyAutoTemplate(&cfg)
+	}
+
 	// Validate the merged config (recipes may have added items)
 	if err := ValidateMergedConfig(&cfg); err != nil {
 		return nil, fmt.Errorf("merged configuration validation failed: %w", err)
 	}
 
+	// Run builds linked to a repo inside its checkout
+	ApplyBuildRepos(&cfg)
+
 	return &cfg, nil
 }
 
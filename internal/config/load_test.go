@@ -113,6 +113,15 @@ func TestLoadConfig_WithHostsField(t *testing.T) {
 	body = "echo setup"
 	hosts = ["work-laptop"]
 
+	[shell.env]
+	EDITOR = "nvim"
+	GOPATH = { value = "~/go", hosts = ["work-laptop"] }
+
+	[shell.fish.universal]
+	fish_greeting = ""
+	fish_user_paths = ["~/go/bin", "~/.cargo/bin"]
+	fish_color_command = { value = "blue", hosts = ["work-laptop"] }
+
 	[hooks.builds.work_build]
 	commands = ["echo build"]
 	run = "once"
@@ -163,6 +172,124 @@ func TestLoadConfig_WithHostsField(t *testing.T) {
 	if len(cfg.Hooks.Builds["work_build"].Hosts) != 1 {
 		t.Errorf("Expected 1 host for build, got %d", len(cfg.Hooks.Builds["work_build"].Hosts))
 	}
+
+	if env := cfg.Shell.Env["EDITOR"]; env.Value != "nvim" || len(env.Hosts) != 0 {
+		t.Errorf("Expected plain env var EDITOR=nvim, got %+v", env)
+	}
+	if env := cfg.Shell.Env["GOPATH"]; env.Value != "~/go" || len(env.Hosts) != 1 {
+		t.Errorf("Expected GOPATH with 1 host, got %+v", env)
+	}
+	universal := cfg.Shell.Fish.Universal
+	if v := universal["fish_greeting"]; len(v.Values) != 1 || v.Values[0] != "" {
+		t.Errorf("Expected an empty fish_greeting, got %+v", v)
+	}
+	if v := universal["fish_user_paths"]; len(v.Values) != 2 || v.Values[1] != "~/.cargo/bin" {
+		t.Errorf("Expected a fish_user_paths list, got %+v", v)
+	}
+	if v := universal["fish_color_command"]; len(v.Values) != 1 || v.Values[0] != "blue" || len(v.Hosts) != 1 {
+		t.Errorf("Expected fish_color_command with 1 host, got %+v", v)
+	}
+}
+
+func TestLoadConfig_AliasForms(t *testing.T) {
+	content := `
+	dotfiles_repo_path = "~/.dotfiles"
+
+	[shell.aliases]
+	g = "git"
+	ll = { command = "ls -alhF", description = "long listing", hosts = ["work-laptop"], enable = true }
+	`
+	tempCfgPath, cleanup := createTempConfigFile(t, content)
+	defer cleanup()
+
+	originalGetDefaultConfigPath := GetDefaultConfigPath
+	GetDefaultConfigPath = func() (string, error) {
+		return tempCfgPath, nil
+	}
+	defer func() { GetDefaultConfigPath = originalGetDefaultConfigPath }()
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig() returned error: %v", err)
+	}
+	if alias := cfg.Shell.Aliases["g"]; alias.Command != "git" || alias.Description != "" || alias.Hosts != nil {
+		t.Errorf("Expected plain alias g=git, got %+v", alias)
+	}
+	ll := cfg.Shell.Aliases["ll"]
+	if ll.Command != "ls -alhF" || ll.Description != "long listing" || len(ll.Hosts) != 1 || ll.Enable == nil || !*ll.Enable {
+		t.Errorf("Expected structured alias ll, got %+v", ll)
+	}
+}
+
+func TestLoadConfig_HookGroups(t *testing.T) {
+	content := `
+	dotfiles_repo_path = "~/.dotfiles"
+
+	[hooks]
+	pre_apply = [
+		"echo start",
+		{ name = "setup", parallel = true, commands = ["brew update", "mkdir -p ~/src"] },
+	]
+	post_apply = ["echo done"]
+	`
+	tempCfgPath, cleanup := createTempConfigFile(t, content)
+	defer cleanup()
+
+	originalGetDefaultConfigPath := GetDefaultConfigPath
+	GetDefaultConfigPath = func() (string, error) {
+		return tempCfgPath, nil
+	}
+	defer func() { GetDefaultConfigPath = originalGetDefaultConfigPath }()
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig() returned error: %v", err)
+	}
+	want := []HookGroup{
+		{Commands: []string{"echo start"}},
+		{Name: "setup", Parallel: true, Commands: []string{"brew update", "mkdir -p ~/src"}},
+	}
+	if !reflect.DeepEqual(cfg.Hooks.PreApply, want) {
+		t.Errorf("PreApply = %+v, want %+v", cfg.Hooks.PreApply, want)
+	}
+	if got := HookCommands(cfg.Hooks.PostApply); !reflect.DeepEqual(got, []string{"echo done"}) {
+		t.Errorf("PostApply commands = %v", got)
+	}
+}
+
+func TestHookGroup_UnmarshalTOMLErrors(t *testing.T) {
+	for _, data := range []interface{}{
+		42,
+		map[string]interface{}{"name": 1},
+		map[string]interface{}{"commands": "make"},
+		map[string]interface{}{"parallel": "yes"},
+		map[string]interface{}{"run": "make"},
+	} {
+		var g HookGroup
+		if err := g.UnmarshalTOML(data); err == nil {
+			t.Errorf("UnmarshalTOML(%v) should fail", data)
+		}
+	}
+
+	cfg := &Config{Hooks: HooksConfig{PostApply: []HookGroup{{Name: "empty"}}}}
+	if err := ValidateMergedConfig(cfg); err == nil {
+		t.Error("ValidateMergedConfig() should reject a hook group without commands")
+	}
+}
+
+func TestShellAlias_UnmarshalTOMLErrors(t *testing.T) {
+	for _, data := range []interface{}{
+		42,
+		map[string]interface{}{"command": 1},
+		map[string]interface{}{"hosts": "work"},
+		map[string]interface{}{"enable": "yes"},
+		map[string]interface{}{"cmd": "git"},
+	} {
+		var alias ShellAlias
+		if err := alias.UnmarshalTOML(data); err == nil {
+			t.Errorf("UnmarshalTOML(%v) should fail", data)
+		}
+	}
 }
 
 func TestLoadConfig_NonExistentConfig(t *testing.T) {
@@ -0,0 +1,71 @@
+package config
+
+// Item kinds used to key provenance records.
+const (
+	KindDotfile   = "dotfile"
+	KindDirectory = "directory"
+	KindRepo      = "repo"
+	KindTool      = "tool"
+	KindAlias     = "alias"
+	KindFunction  = "function"
+	KindEnv       = "env"
+	KindBuild     = "build"
+)
+
+// ItemOrigin records where a config item was defined.
+type ItemOrigin struct {
+	Recipe  string // Recipe name; empty for the main config
+	Path    string // Recipe file relative to dotfiles_repo_path; empty for the main config
+	Overlay string // Host or OS overlay that last set the item, e.g. ~/.config/ralph/config.work.toml
+}
+
+// SkippedRecipe is a recipe reference that was not loaded for this host.
+type SkippedRecipe struct {
+	Ref    RecipeRef
+	Reason string // "disabled" or "host filter"
+}
+
+// originKey identifies an item in Config.Origins.
+func originKey(kind, name string) string {
+	return kind + ":" + name
+}
+
+// OriginOf returns where the named item of kind was defined. Items that were
+// not merged from a recipe come from the main config (the zero ItemOrigin).
+func OriginOf(cfg *Config, kind, name string) ItemOrigin {
+	return cfg.Origins[originKey(kind, name)]
+}
+
+// recordOrigins notes origin as the source of every item in recipe.
+func recordOrigins(cfg *Config, recipe *Recipe, origin ItemOrigin) {
+	if cfg.Origins == nil {
+		cfg.Origins = make(map[string]ItemOrigin)
+	}
+	record := func(kind, name string) {
+		cfg.Origins[originKey(kind, name)] = origin
+	}
+	for name := range recipe.Dotfiles {
+		record(KindDotfile, name)
+	}
+	for name := range recipe.Directories {
+		record(KindDirectory, name)
+	}
+	for name := range recipe.Repos {
+		record(KindRepo, name)
+	}
+	for _, tool := range recipe.Tools {
+		record(KindTool, tool.Name)
+	}
+	for name := range recipe.Shell.Aliases {
+		record(KindAlias, name)
+	}
+	for name := range recipe.Shell.Functions {
+		record(KindFunction, name)
+	}
+	for name := range recipe.Shell.Env {
+		record(KindEnv, name)
+	}
+	for name := range recipe.Hooks.Builds {
+		record(KindBuild, name)
+	}
+}
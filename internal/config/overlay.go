@@ -0,0 +1,104 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/BurntSushi/toml"
+)
+
+// overlayPaths returns the overlays that apply to host next to configPath,
+// in the order they are merged: config.<os>.toml, then config.<host>.toml,
+// so a host overlay wins over the OS one. Missing files are left out.
+func overlayPaths(configPath, host string) []string {
+	dir := filepath.Dir(configPath)
+	names := []string{runtime.GOOS}
+	if host != "" && host != runtime.GOOS {
+		names = append(names, host)
+	}
+	var paths []string
+	for _, name := range names {
+		path := filepath.Join(dir, "config."+name+".toml")
+		// config.local.toml holds prompt answers, not an overlay
+		if filepath.Base(path) == LocalConfigFileName {
+			continue
+		}
+		if _, err := os.Stat(path); err == nil {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// decodeConfigWithOverlays decodes the config at configPath merged with the
+// overlays for host, and returns the overlay files that were merged.
+//
+// Tables are merged key by key, so an overlay can add items or change single
+// fields of items from the main config. Arrays of tables ([[tools]],
+// [[recipes]]) are appended to; any other value in an overlay replaces the
+// one it overrides.
+func decodeConfigWithOverlays(configPath, host string, cfg *Config) ([]string, error) {
+	overlays := overlayPaths(configPath, host)
+	if len(overlays) == 0 {
+		if _, err := toml.DecodeFile(configPath, cfg); err != nil {
+			return nil, fmt.Errorf("failed to decode config file %s: %w", configPath, err)
+		}
+		return nil, nil
+	}
+
+	merged := make(map[string]interface{})
+	for _, path := range append([]string{configPath}, overlays...) {
+		// Decoding into Config first reports type errors against the file
+		// that has them
+		var layer Config
+		if _, err := toml.DecodeFile(path, &layer); err != nil {
+			return nil, fmt.Errorf("failed to decode config file %s: %w", path, err)
+		}
+		if path != configPath {
+			recordOrigins(cfg, &Recipe{
+				Dotfiles:    layer.Dotfiles,
+				Directories: layer.Directories,
+				Repos:       layer.Repos,
+				Tools:       layer.Tools,
+				Shell:       layer.Shell,
+				Hooks:       layer.Hooks,
+			}, ItemOrigin{Overlay: path})
+		}
+		var table map[string]interface{}
+		if _, err := toml.DecodeFile(path, &table); err != nil {
+			return nil, fmt.Errorf("failed to decode config file %s: %w", path, err)
+		}
+		mergeTables(merged, table)
+	}
+
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(merged); err != nil {
+		return nil, fmt.Errorf("failed to merge config overlays: %w", err)
+	}
+	if _, err := toml.Decode(buf.String(), cfg); err != nil {
+		return nil, fmt.Errorf("failed to merge config overlays: %w", err)
+	}
+	return overlays, nil
+}
+
+// mergeTables merges src into dst as described for decodeConfigWithOverlays.
+func mergeTables(dst, src map[string]interface{}) {
+	for key, value := range src {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			if existing, ok := dst[key].(map[string]interface{}); ok {
+				mergeTables(existing, v)
+				continue
+			}
+		case []map[string]interface{}:
+			if existing, ok := dst[key].([]map[string]interface{}); ok {
+				dst[key] = append(existing, v...)
+				continue
+			}
+		}
+		dst[key] = value
+	}
+}
@@ -0,0 +1,112 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfigWithHost_Overlays(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(StateDirEnv, t.TempDir())
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(DefaultConfigFileName, `
+dotfiles_repo_path = "~/.dotfiles"
+keep_going = false
+
+[dotfiles.zshrc]
+source = "zshrc"
+target = "~/.zshrc"
+
+[shell.aliases]
+ll = "ls -l"
+
+[[tools]]
+name = "git"
+check_command = "git --version"
+`)
+	write("config."+runtime.GOOS+".toml", `
+keep_going = true
+
+[dotfiles.zshrc]
+source = "zshrc-os"
+
+[[tools]]
+name = "fzf"
+check_command = "fzf --version"
+`)
+	write("config.work-laptop.toml", `
+[dotfiles.zshrc]
+source = "zshrc-work"
+
+[dotfiles.npmrc]
+source = "npmrc-work"
+target = "~/.npmrc"
+
+[shell.aliases]
+k = "kubectl"
+`)
+	original := GetDefaultConfigPath
+	GetDefaultConfigPath = func() (string, error) { return filepath.Join(dir, DefaultConfigFileName), nil }
+	defer func() { GetDefaultConfigPath = original }()
+
+	cfg, err := LoadConfigWithHost("work-laptop")
+	if err != nil {
+		t.Fatalf("LoadConfigWithHost() error = %v", err)
+	}
+	if want := []string{filepath.Join(dir, "config."+runtime.GOOS+".toml"), filepath.Join(dir, "config.work-laptop.toml")}; strings.Join(cfg.Overlays, ",") != strings.Join(want, ",") {
+		t.Errorf("Overlays = %v, want %v", cfg.Overlays, want)
+	}
+	if zshrc := cfg.Dotfiles["zshrc"]; zshrc.Source != "zshrc-work" || zshrc.Target != "~/.zshrc" {
+		t.Errorf("zshrc = %+v, want the host overlay's source and the main target", zshrc)
+	}
+	if _, ok := cfg.Dotfiles["npmrc"]; !ok {
+		t.Error("npmrc from the host overlay is missing")
+	}
+	if got := OriginOf(cfg, KindDotfile, "npmrc").Overlay; got != filepath.Join(dir, "config.work-laptop.toml") {
+		t.Errorf("npmrc origin overlay = %q, want the host overlay", got)
+	}
+	if got := OriginOf(cfg, KindTool, "git"); got != (ItemOrigin{}) {
+		t.Errorf("git origin = %+v, want the main config", got)
+	}
+	if !cfg.KeepGoing {
+		t.Error("keep_going from the OS overlay should override the main config")
+	}
+	if len(cfg.Tools) != 2 || cfg.Tools[0].Name != "git" || cfg.Tools[1].Name != "fzf" {
+		t.Errorf("Tools = %+v, want git then fzf", cfg.Tools)
+	}
+	if cfg.Shell.Aliases["ll"].Command != "ls -l" || cfg.Shell.Aliases["k"].Command != "kubectl" {
+		t.Errorf("Aliases = %+v, want ll and k", cfg.Shell.Aliases)
+	}
+
+	// Another host gets only the OS overlay
+	cfg, err = LoadConfigWithHost("desktop")
+	if err != nil {
+		t.Fatalf("LoadConfigWithHost() error = %v", err)
+	}
+	if len(cfg.Overlays) != 1 || cfg.Dotfiles["zshrc"].Source != "zshrc-os" {
+		t.Errorf("desktop: Overlays = %v, zshrc = %+v, want only the OS overlay", cfg.Overlays, cfg.Dotfiles["zshrc"])
+	}
+
+	// Errors name the overlay they are in
+	write("config.broken.toml", "[dotfiles.zshrc]\nsource = 1\n")
+	if _, err := LoadConfigWithHost("broken"); err == nil || !strings.Contains(err.Error(), "config.broken.toml") {
+		t.Errorf("LoadConfigWithHost(broken) error = %v, want it to name the overlay", err)
+	}
+}
+
+func TestOverlayPaths_SkipsLocalConfig(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, DefaultConfigFileName)
+	os.WriteFile(filepath.Join(dir, LocalConfigFileName), []byte(""), 0644)
+	if paths := overlayPaths(configPath, "local"); len(paths) != 0 {
+		t.Errorf("overlayPaths() = %v, want config.local.toml left out", paths)
+	}
+}
@@ -0,0 +1,161 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/BurntSushi/toml"
+)
+
+// LocalConfigFileName is the per-machine file next to config.toml that holds
+// answers to template variable prompts. It is not meant to be committed.
+const LocalConfigFileName = "config.local.toml"
+
+// TemplatePrompt declares a template variable whose value is asked for the
+// first time a template uses it:
+//
+//	[template_variables.email]
+//	prompt = true
+//	description = "Email for git commits"
+type TemplatePrompt struct {
+	Description string
+}
+
+// Prompter asks the user for the value of a template variable. Commands set
+// it when stdin is a terminal; when it is nil, prompting fails with an error
+// explaining how to set the value instead.
+var Prompter func(name, description string) (string, error)
+
+// localConfig is the subset of config.local.toml ralph reads.
+type localConfig struct {
+	TemplateVariables map[string]interface{} `toml:"template_variables"`
+}
+
+// getLocalConfigPath returns the path of config.local.toml.
+func getLocalConfigPath() (string, error) {
+	configPath, err := GetDefaultConfigPath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(configPath), LocalConfigFileName), nil
+}
+
+// ApplyLocalConfig moves prompt declarations out of cfg.TemplateVariables into
+// cfg.TemplatePrompts, then merges the values from config.local.toml, which
+// override same-named variables from config.toml and recipes.
+func ApplyLocalConfig(cfg *Config) error {
+	for name, val := range cfg.TemplateVariables {
+		if prompt, ok := promptDeclaration(val); ok {
+			if cfg.TemplatePrompts == nil {
+				cfg.TemplatePrompts = make(map[string]TemplatePrompt)
+			}
+			cfg.TemplatePrompts[name] = prompt
+			delete(cfg.TemplateVariables, name)
+		}
+	}
+
+	localPath, err := getLocalConfigPath()
+	if err != nil {
+		return err
+	}
+	var local localConfig
+	if _, err := toml.DecodeFile(localPath, &local); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to decode %s: %w", localPath, err)
+	}
+	if len(local.TemplateVariables) > 0 && cfg.TemplateVariables == nil {
+		cfg.TemplateVariables = make(map[string]interface{})
+	}
+	for name, val := range local.TemplateVariables {
+		cfg.TemplateVariables[name] = val
+		delete(cfg.TemplatePrompts, name)
+	}
+	return nil
+}
+
+// promptDeclaration reports whether a template variable value is a prompt
+// declaration: a table with prompt = true and at most a description besides.
+func promptDeclaration(val interface{}) (TemplatePrompt, bool) {
+	table, ok := val.(map[string]interface{})
+	if !ok || table["prompt"] != true {
+		return TemplatePrompt{}, false
+	}
+	var prompt TemplatePrompt
+	for key, v := range table {
+		switch key {
+		case "prompt":
+		case "description":
+			s, ok := v.(string)
+			if !ok {
+				return TemplatePrompt{}, false
+			}
+			prompt.Description = s
+		default:
+			return TemplatePrompt{}, false
+		}
+	}
+	return prompt, true
+}
+
+// ResolvePrompt asks for the value of the declared prompt variable name via
+// Prompter, stores it in cfg.TemplateVariables and persists it to
+// config.local.toml so later runs don't ask again.
+func ResolvePrompt(cfg *Config, name string) error {
+	prompt, ok := cfg.TemplatePrompts[name]
+	if !ok {
+		return nil
+	}
+	localPath, err := getLocalConfigPath()
+	if err != nil {
+		return err
+	}
+	if Prompter == nil {
+		return fmt.Errorf("template variable '%s' has no value and ralph is not running interactively; run it in a terminal to be asked, or add %s = \"...\" under [template_variables] in %s", name, name, localPath)
+	}
+
+	value, err := Prompter(name, prompt.Description)
+	if err != nil {
+		return fmt.Errorf("failed to read a value for template variable '%s': %w", name, err)
+	}
+	if err := saveLocalVariable(localPath, name, value); err != nil {
+		return err
+	}
+	if cfg.TemplateVariables == nil {
+		cfg.TemplateVariables = make(map[string]interface{})
+	}
+	cfg.TemplateVariables[name] = value
+	delete(cfg.TemplatePrompts, name)
+	return nil
+}
+
+// saveLocalVariable sets template_variables.name in the local config file,
+// keeping the rest of its content.
+func saveLocalVariable(localPath, name, value string) error {
+	content := make(map[string]interface{})
+	if _, err := toml.DecodeFile(localPath, &content); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to decode %s: %w", localPath, err)
+	}
+	vars, _ := content["template_variables"].(map[string]interface{})
+	if vars == nil {
+		vars = make(map[string]interface{})
+		content["template_variables"] = vars
+	}
+	vars[name] = value
+
+	var buf bytes.Buffer
+	buf.WriteString("# Machine-local values for ralph, e.g. answers to template variable prompts.\n# Not meant to be committed to your dotfiles repo.\n\n")
+	if err := toml.NewEncoder(&buf).Encode(content); err != nil {
+		return fmt.Errorf("failed to encode %s: %w", localPath, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(localPath), 0755); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", localPath, err)
+	}
+	if err := os.WriteFile(localPath, buf.Bytes(), 0600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", localPath, err)
+	}
+	return nil
+}
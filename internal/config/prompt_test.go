@@ -0,0 +1,88 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// useTempConfigDir points GetDefaultConfigPath at a temp dir and returns the
+// path config.local.toml would have there.
+func useTempConfigDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	orig := GetDefaultConfigPath
+	GetDefaultConfigPath = func() (string, error) { return filepath.Join(dir, DefaultConfigFileName), nil }
+	t.Cleanup(func() { GetDefaultConfigPath = orig })
+	return filepath.Join(dir, LocalConfigFileName)
+}
+
+func TestApplyLocalConfig(t *testing.T) {
+	localPath := useTempConfigDir(t)
+	os.WriteFile(localPath, []byte("[template_variables]\nemail = \"me@example.com\"\nname = \"Local\"\n"), 0600)
+
+	cfg := &Config{TemplateVariables: map[string]interface{}{
+		"email":   map[string]interface{}{"prompt": true, "description": "Git email"},
+		"token":   map[string]interface{}{"prompt": true},
+		"name":    "Shared",
+		"palette": map[string]interface{}{"prompt": true, "bg": "#000"}, // not a declaration
+	}}
+	if err := ApplyLocalConfig(cfg); err != nil {
+		t.Fatalf("ApplyLocalConfig failed: %v", err)
+	}
+
+	if _, ok := cfg.TemplatePrompts["token"]; !ok || len(cfg.TemplatePrompts) != 1 {
+		t.Errorf("TemplatePrompts = %v, want only the unanswered token", cfg.TemplatePrompts)
+	}
+	if cfg.TemplateVariables["email"] != "me@example.com" {
+		t.Errorf("email = %v, want the local answer", cfg.TemplateVariables["email"])
+	}
+	if cfg.TemplateVariables["name"] != "Local" {
+		t.Errorf("name = %v, want the local value to override", cfg.TemplateVariables["name"])
+	}
+	if _, ok := cfg.TemplateVariables["token"]; ok {
+		t.Error("prompt declaration should be removed from TemplateVariables")
+	}
+	if _, ok := cfg.TemplateVariables["palette"].(map[string]interface{}); !ok {
+		t.Error("tables with other keys are regular variables")
+	}
+}
+
+func TestResolvePrompt(t *testing.T) {
+	localPath := useTempConfigDir(t)
+	os.WriteFile(localPath, []byte("[template_variables]\nname = \"Me\"\n"), 0600)
+	orig := Prompter
+	t.Cleanup(func() { Prompter = orig })
+
+	cfg := &Config{TemplatePrompts: map[string]TemplatePrompt{"email": {Description: "Git email"}}}
+
+	Prompter = nil
+	err := ResolvePrompt(cfg, "email")
+	if err == nil || !strings.Contains(err.Error(), "not running interactively") || !strings.Contains(err.Error(), localPath) {
+		t.Fatalf("non-interactive error = %v", err)
+	}
+
+	var asked string
+	Prompter = func(name, description string) (string, error) {
+		asked = description
+		return "me@example.com", nil
+	}
+	if err := ResolvePrompt(cfg, "email"); err != nil {
+		t.Fatalf("ResolvePrompt failed: %v", err)
+	}
+	if asked != "Git email" || cfg.TemplateVariables["email"] != "me@example.com" {
+		t.Errorf("asked %q, got email = %v", asked, cfg.TemplateVariables["email"])
+	}
+	if _, ok := cfg.TemplatePrompts["email"]; ok {
+		t.Error("answered prompt should be removed")
+	}
+
+	reloaded := &Config{TemplateVariables: map[string]interface{}{"email": map[string]interface{}{"prompt": true}}}
+	if err := ApplyLocalConfig(reloaded); err != nil {
+		t.Fatal(err)
+	}
+	if reloaded.TemplateVariables["email"] != "me@example.com" || reloaded.TemplateVariables["name"] != "Me" {
+		t.Errorf("persisted variables = %v", reloaded.TemplateVariables)
+	}
+}
@@ -34,6 +34,13 @@ func ResolveRecipePaths(recipe *Recipe, recipeDir string) {
 		}
 	}
 
+	// Resolve completion paths
+	for i, path := range recipe.Shell.Completions.Paths {
+		if path != "" && !filepath.IsAbs(path) && !strings.HasPrefix(path, "~") {
+			recipe.Shell.Completions.Paths[i] = filepath.Join(recipeDir, path)
+		}
+	}
+
 	// Resolve tool config file source paths
 	for i, tool := range recipe.Tools {
 		for j, cf := range tool.ConfigFiles {
@@ -119,7 +126,7 @@ func MergeRecipeIntoConfig(cfg *Config, recipe *Recipe, recipeName string) error
 	// Merge shell env vars
 	if recipe.Shell.Env != nil {
 		if cfg.Shell.Env == nil {
-			cfg.Shell.Env = make(map[string]string)
+			cfg.Shell.Env = make(map[string]ShellEnvVar)
 		}
 		for name, val := range recipe.Shell.Env {
 			if _, exists := cfg.Shell.Env[name]; exists {
@@ -129,6 +136,10 @@ func MergeRecipeIntoConfig(cfg *Config, recipe *Recipe, recipeName string) error
 		}
 	}
 
+	// Merge completions (append)
+	cfg.Shell.Completions.Paths = append(cfg.Shell.Completions.Paths, recipe.Shell.Completions.Paths...)
+	cfg.Shell.Completions.Compinit = cfg.Shell.Completions.Compinit || recipe.Shell.Completions.Compinit
+
 	// Merge hooks - pre_apply and post_apply (append)
 	cfg.Hooks.PreApply = append(cfg.Hooks.PreApply, recipe.Hooks.PreApply...)
 	cfg.Hooks.PostApply = append(cfg.Hooks.PostApply, recipe.Hooks.PostApply...)
@@ -325,21 +336,26 @@ func ProcessRecipes(cfg *Config, currentHost string) error {
 		return nil
 	}
 
+	cache := loadRecipeCache()
+	defer cache.save()
+
 	// Process each recipe
 	for _, ref := range recipeRefs {
 		// Check if recipe is enabled
 		if !IsEnabled(ref.Enable) {
+			cfg.SkippedRecipes = append(cfg.SkippedRecipes, SkippedRecipe{Ref: ref, Reason: "disabled"})
 			continue
 		}
 
 		// Check host filter for recipe
 		if !ShouldApplyForHost(ref.Hosts, currentHost) {
+			cfg.SkippedRecipes = append(cfg.SkippedRecipes, SkippedRecipe{Ref: ref, Reason: "host filter"})
 			continue
 		}
 
 		// Load the recipe
 		recipePath := filepath.Join(expandedRepoPath, ref.Path)
-		recipe, err := LoadRecipe(recipePath)
+		recipe, err := cache.loadRecipe(recipePath)
 		if err != nil {
 			return fmt.Errorf("failed to load recipe '%s': %w", ref.Path, err)
 		}
@@ -367,13 +383,15 @@ func ProcessRecipes(cfg *Config, currentHost string) error {
 		if err := MergeRecipeIntoConfig(cfg, recipe, recipeName); err != nil {
 			return err
 		}
+		recordOrigins(cfg, recipe, ItemOrigin{Recipe: recipeName, Path: ref.Path})
 
 		// Store loaded recipe info for migration support
 		cfg.LoadedRecipes = append(cfg.LoadedRecipes, LoadedRecipeInfo{
-			Path:        ref.Path,
-			Dir:         recipeDir,
-			Name:        recipeName,
-			LegacyPaths: recipe.Recipe.LegacyPaths,
+			Path:          ref.Path,
+			Dir:           recipeDir,
+			Name:          recipeName,
+			LegacyPaths:   recipe.Recipe.LegacyPaths,
+			LegacyTargets: recipe.Recipe.LegacyTargets,
 		})
 	}
 
@@ -381,7 +399,8 @@ func ProcessRecipes(cfg *Config, currentHost string) error {
 }
 
 // applyRecipeHostFilter applies the recipe-level host filter to items that
-// don't have their own host filter specified.
+// don't have their own host filter specified, so 'ralph list', 'explain' and
+// 'lint' show where each item really applies.
 func applyRecipeHostFilter(recipe *Recipe, recipeHosts []string) {
 	if len(recipeHosts) == 0 {
 		return // No recipe-level filter to apply
@@ -434,6 +453,14 @@ func applyRecipeHostFilter(recipe *Recipe, recipeHosts []string) {
 		}
 	}
 
+	// Apply to shell env vars
+	for name, env := range recipe.Shell.Env {
+		if len(env.Hosts) == 0 {
+			env.Hosts = recipeHosts
+			recipe.Shell.Env[name] = env
+		}
+	}
+
 	// Apply to builds
 	for name, build := range recipe.Hooks.Builds {
 		if len(build.Hosts) == 0 {
@@ -441,6 +468,10 @@ func applyRecipeHostFilter(recipe *Recipe, recipeHosts []string) {
 			recipe.Hooks.Builds[name] = build
 		}
 	}
+
+	// pre_link and post_link hooks have no hosts of their own: they run when
+	// their dotfile is linked, so they follow its (now inherited) filter, and
+	// a recipe skipped by its host filter never merges them at all.
 }
 
 // GetAllLegacyPaths returns a consolidated map of all legacy paths from all
@@ -459,3 +490,16 @@ func GetAllLegacyPaths(cfg *Config) map[string]string {
 	}
 	return result
 }
+
+// GetAllLegacyTargets returns a consolidated map of all legacy targets from
+// all loaded recipes. The map keys are old target paths and values are the
+// new target paths, both as written in the recipe (~ is not expanded).
+func GetAllLegacyTargets(cfg *Config) map[string]string {
+	result := make(map[string]string)
+	for _, info := range cfg.LoadedRecipes {
+		for oldTarget, newTarget := range info.LegacyTargets {
+			result[oldTarget] = newTarget
+		}
+	}
+	return result
+}
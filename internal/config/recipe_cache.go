@@ -0,0 +1,241 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// RecipeCacheFileName is the state file parsed recipes are cached in.
+const RecipeCacheFileName = "recipe-cache.json"
+
+// CacheRecipes enables the recipe cache and the cache of
+// template_variables_from_commands output. The CLI turns it on unless run
+// with --no-cache; it is off by default so library callers and tests never
+// touch the state directory.
+var CacheRecipes bool
+
+// recipeCache maps recipe files to their parsed content, so that a recipe is
+// only decoded from TOML again when its modification time or size changes.
+// A cache written by a different ralph binary is discarded, since the
+// recipe types may have changed.
+type recipeCache struct {
+	Binary  string                  `json:"binary"`
+	Recipes map[string]cachedRecipe `json:"recipes"`
+
+	path  string
+	used  map[string]bool
+	dirty bool
+}
+
+// cachedRecipe is a recipe as decoded from the file, before its paths are
+// resolved or host filters applied.
+type cachedRecipe struct {
+	ModTime time.Time       `json:"mod_time"`
+	Size    int64           `json:"size"`
+	Recipe  json.RawMessage `json:"recipe"`
+}
+
+// binaryFingerprint identifies the running ralph binary by path, size and
+// modification time.
+func binaryFingerprint() string {
+	exe, err := os.Executable()
+	if err != nil {
+		return ""
+	}
+	info, err := os.Stat(exe)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%s:%d:%d", exe, info.Size(), info.ModTime().UnixNano())
+}
+
+// loadRecipeCache reads the recipe cache, or returns nil if caching is
+// disabled. An unreadable or outdated cache starts out empty.
+func loadRecipeCache() *recipeCache {
+	if !CacheRecipes {
+		return nil
+	}
+	path, err := StateFilePath(RecipeCacheFileName)
+	if err != nil {
+		return nil
+	}
+	binary := binaryFingerprint()
+	cache := &recipeCache{path: path, used: make(map[string]bool)}
+	if data, err := os.ReadFile(path); err == nil {
+		if json.Unmarshal(data, cache) != nil || cache.Binary != binary {
+			cache.Recipes = nil
+			cache.dirty = true
+		}
+	}
+	cache.Binary = binary
+	if cache.Recipes == nil {
+		cache.Recipes = make(map[string]cachedRecipe)
+	}
+	return cache
+}
+
+// loadRecipe returns the recipe at path from the cache when the file is
+// unchanged, and parses and caches it otherwise. A nil cache always parses.
+func (c *recipeCache) loadRecipe(path string) (*Recipe, error) {
+	if c == nil {
+		return LoadRecipe(path)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return LoadRecipe(path)
+	}
+	c.used[path] = true
+	if cached, ok := c.Recipes[path]; ok && cached.Size == info.Size() && cached.ModTime.Equal(info.ModTime()) {
+		if recipe, err := decodeCachedRecipe(cached.Recipe); err == nil {
+			return recipe, nil
+		}
+	}
+
+	recipe, err := LoadRecipe(path)
+	if err != nil {
+		return nil, err
+	}
+	if recipeCacheable(recipe) {
+		if data, err := json.Marshal(recipe); err == nil {
+			c.Recipes[path] = cachedRecipe{ModTime: info.ModTime(), Size: info.Size(), Recipe: data}
+			c.dirty = true
+		}
+	}
+	return recipe, nil
+}
+
+// save writes the cache back if anything changed, dropping recipes that
+// were not loaded this time. Failing to write it is not an error: the next
+// run parses the recipes again.
+func (c *recipeCache) save() {
+	if c == nil {
+		return
+	}
+	for path := range c.Recipes {
+		if !c.used[path] {
+			delete(c.Recipes, path)
+			c.dirty = true
+		}
+	}
+	if !c.dirty {
+		return
+	}
+	data, err := json.Marshal(c)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return
+	}
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return
+	}
+	if err := os.Rename(tmp, c.path); err != nil {
+		os.Remove(tmp)
+	}
+}
+
+// decodeCachedRecipe decodes a cached recipe. JSON has a single number type,
+// so template variables and dotfile vars get back the int64 and float64 values the TOML
+// decoder produces.
+func decodeCachedRecipe(data []byte) (*Recipe, error) {
+	var recipe Recipe
+	if err := json.Unmarshal(data, &recipe); err != nil {
+		return nil, err
+	}
+	var raw struct {
+		TemplateVariables map[string]interface{}
+		Dotfiles          map[string]struct{ Vars map[string]interface{} }
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&raw); err != nil {
+		return nil, err
+	}
+	if raw.TemplateVariables != nil {
+		recipe.TemplateVariables = restoreNumbers(raw.TemplateVariables).(map[string]interface{})
+	}
+	for name, df := range raw.Dotfiles {
+		if df.Vars != nil {
+			d := recipe.Dotfiles[name]
+			d.Vars = restoreNumbers(df.Vars).(map[string]interface{})
+			recipe.Dotfiles[name] = d
+		}
+	}
+	return &recipe, nil
+}
+
+// recipeCacheable reports whether the template variables and dotfile vars
+// of recipe are cacheable.
+func recipeCacheable(recipe *Recipe) bool {
+	if !cacheable(recipe.TemplateVariables) {
+		return false
+	}
+	for _, df := range recipe.Dotfiles {
+		if !cacheable(df.Vars) {
+			return false
+		}
+	}
+	return true
+}
+
+// restoreNumbers replaces the json.Numbers in v with int64 or float64.
+func restoreNumbers(v interface{}) interface{} {
+	switch v := v.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		for k, item := range v {
+			v[k] = restoreNumbers(item)
+		}
+		return v
+	case []interface{}:
+		for i, item := range v {
+			v[i] = restoreNumbers(item)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// cacheable reports whether v survives a JSON round trip: TOML datetimes
+// would come back as strings and whole floats such as 1.0 as integers, so
+// recipes with such variables are always parsed.
+func cacheable(v interface{}) bool {
+	switch v := v.(type) {
+	case time.Time:
+		return false
+	case float64:
+		return v != math.Trunc(v)
+	case map[string]interface{}:
+		for _, item := range v {
+			if !cacheable(item) {
+				return false
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			if !cacheable(item) {
+				return false
+			}
+		}
+	case []map[string]interface{}:
+		for _, item := range v {
+			if !cacheable(item) {
+				return false
+			}
+		}
+	}
+	return true
+}
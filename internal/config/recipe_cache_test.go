@@ -0,0 +1,118 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProcessRecipes_Cache(t *testing.T) {
+	tempDir := t.TempDir()
+	stateDir := t.TempDir()
+	t.Setenv(StateDirEnv, stateDir)
+	CacheRecipes = true
+	defer func() { CacheRecipes = false }()
+
+	recipePath := filepath.Join(tempDir, "git", RecipeFileName)
+	os.MkdirAll(filepath.Dir(recipePath), 0755)
+	os.WriteFile(recipePath, []byte(`
+[dotfiles.gitconfig]
+source = "gitconfig"
+target = "~/.gitconfig"
+enable = false
+
+[dotfiles.gitconfig.vars]
+email = "me@example.com"
+signing = 1
+
+[template_variables]
+port = 8080
+ratio = 0.5
+nested = { list = [1, 2.5, "x"] }
+`), 0644)
+
+	process := func() *Config {
+		t.Helper()
+		cfg := &Config{DotfilesRepoPath: tempDir, Recipes: []RecipeRef{{Path: "git/recipe.toml"}}}
+		if err := ProcessRecipes(cfg, "test-host"); err != nil {
+			t.Fatalf("ProcessRecipes() returned error: %v", err)
+		}
+		return cfg
+	}
+
+	parsed := process()
+	cachePath := filepath.Join(stateDir, RecipeCacheFileName)
+	data, err := os.ReadFile(cachePath)
+	if err != nil {
+		t.Fatalf("recipe cache was not written: %v", err)
+	}
+
+	cached := process()
+	if !reflect.DeepEqual(cached.Dotfiles, parsed.Dotfiles) {
+		t.Errorf("cached dotfiles = %+v, want %+v", cached.Dotfiles, parsed.Dotfiles)
+	}
+	if !reflect.DeepEqual(cached.TemplateVariables, parsed.TemplateVariables) {
+		t.Errorf("cached template variables = %#v, want %#v", cached.TemplateVariables, parsed.TemplateVariables)
+	}
+
+	// A cache hit doesn't read the recipe: edit only the cache to tell.
+	os.WriteFile(cachePath, []byte(strings.Replace(string(data), `"~/.gitconfig"`, `"~/.cached"`, 1)), 0644)
+	if got := process().Dotfiles["gitconfig"].Target; got != "~/.cached" {
+		t.Errorf("unchanged recipe target = %q, want the cached one", got)
+	}
+
+	// Changing the recipe invalidates its entry.
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(recipePath, later, later)
+	if got := process().Dotfiles["gitconfig"].Target; got != "~/.gitconfig" {
+		t.Errorf("touched recipe target = %q, want it parsed again", got)
+	}
+
+	// Recipes that are no longer loaded are dropped.
+	falseVal := false
+	cfg := &Config{DotfilesRepoPath: tempDir, Recipes: []RecipeRef{{Path: "git/recipe.toml", Enable: &falseVal}}}
+	if err := ProcessRecipes(cfg, "test-host"); err != nil {
+		t.Fatal(err)
+	}
+	var cache recipeCache
+	data, _ = os.ReadFile(cachePath)
+	if err := json.Unmarshal(data, &cache); err != nil || len(cache.Recipes) != 0 {
+		t.Errorf("cache after disabling the recipe = %v (%v), want no recipes", cache.Recipes, err)
+	}
+}
+
+func TestProcessRecipes_CacheDisabled(t *testing.T) {
+	tempDir := t.TempDir()
+	stateDir := t.TempDir()
+	t.Setenv(StateDirEnv, stateDir)
+
+	createTempRecipeFile(t, tempDir, "[dotfiles.a]\nsource = \"a\"\ntarget = \"~/.a\"\n")
+	cfg := &Config{DotfilesRepoPath: tempDir, Recipes: []RecipeRef{{Path: RecipeFileName}}}
+	if err := ProcessRecipes(cfg, "test-host"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(stateDir, RecipeCacheFileName)); !os.IsNotExist(err) {
+		t.Error("recipe cache should not be written when caching is disabled")
+	}
+}
+
+func TestCacheable(t *testing.T) {
+	tests := []struct {
+		v    interface{}
+		want bool
+	}{
+		{map[string]interface{}{"n": int64(1), "f": 0.5, "s": "x"}, true},
+		{map[string]interface{}{"f": 1.0}, false},
+		{map[string]interface{}{"t": []interface{}{time.Now()}}, false},
+		{map[string]interface{}{"tables": []map[string]interface{}{{"t": time.Now()}}}, false},
+	}
+	for _, tt := range tests {
+		if got := cacheable(tt.v); got != tt.want {
+			t.Errorf("cacheable(%v) = %v, want %v", tt.v, got, tt.want)
+		}
+	}
+}
@@ -207,11 +207,11 @@ func TestMergeRecipeIntoConfig_AllTypes(t *testing.T) {
 		Shell: ShellConfig{
 			Aliases:   map[string]ShellAlias{"alias": {Command: "echo"}},
 			Functions: map[string]ShellFunction{"func": {Body: "echo"}},
-			Env:       map[string]string{"VAR": "value"},
+			Env:       map[string]ShellEnvVar{"VAR": {Value: "value"}},
 		},
 		Hooks: HooksConfig{
-			PreApply:  []string{"echo pre"},
-			PostApply: []string{"echo post"},
+			PreApply:  []HookGroup{{Commands: []string{"echo pre"}}},
+			PostApply: []HookGroup{{Commands: []string{"echo post"}}},
 			PreLink:   map[string][]string{"df": {"echo prelink"}},
 			PostLink:  map[string][]string{"df": {"echo postlink"}},
 			Builds:    map[string]Build{"build": {Commands: []string{"make"}, Run: "once"}},
@@ -382,6 +382,15 @@ target = "~/.myfile"
 	if len(cfg.LoadedRecipes) != 1 {
 		t.Errorf("len(LoadedRecipes) = %d, want 1", len(cfg.LoadedRecipes))
 	}
+
+	// Check provenance
+	want := ItemOrigin{Recipe: "myrecipe", Path: "myrecipe/recipe.toml"}
+	if got := OriginOf(cfg, KindDotfile, "myfile"); got != want {
+		t.Errorf("OriginOf(myfile) = %+v, want %+v", got, want)
+	}
+	if got := OriginOf(cfg, KindDotfile, "other"); got != (ItemOrigin{}) {
+		t.Errorf("OriginOf(other) = %+v, want the main config", got)
+	}
 }
 
 func TestProcessRecipes_ShortName(t *testing.T) {
@@ -534,6 +543,9 @@ target = "~/.file"
 	if len(cfg.Dotfiles) != 0 {
 		t.Errorf("Disabled recipe should not add dotfiles, got %d", len(cfg.Dotfiles))
 	}
+	if len(cfg.SkippedRecipes) != 1 || cfg.SkippedRecipes[0].Reason != "disabled" {
+		t.Errorf("SkippedRecipes = %+v, want the disabled recipe", cfg.SkippedRecipes)
+	}
 }
 
 func TestProcessRecipes_HostFiltered(t *testing.T) {
@@ -597,6 +609,12 @@ target = "~/.file1"
 source = "file2.txt"
 target = "~/.file2"
 hosts = ["specific-host"]
+
+[directories.work]
+target = "~/work"
+
+[shell.env]
+WORK_PROXY = "http://proxy.internal:3128"
 `), 0644)
 
 	cfg := &Config{
@@ -624,6 +642,13 @@ hosts = ["specific-host"]
 			t.Errorf("file2 should keep its own hosts, got %v", df.Hosts)
 		}
 	}
+
+	if dir := cfg.Directories["work"]; len(dir.Hosts) != 1 || dir.Hosts[0] != "work-laptop" {
+		t.Errorf("directory should inherit recipe hosts, got %v", dir.Hosts)
+	}
+	if env := cfg.Shell.Env["WORK_PROXY"]; len(env.Hosts) != 1 || env.Hosts[0] != "work-laptop" {
+		t.Errorf("env var should inherit recipe hosts, got %v", env.Hosts)
+	}
 }
 
 func TestProcessRecipes_LegacyPaths(t *testing.T) {
@@ -702,6 +727,20 @@ func TestGetAllLegacyPaths(t *testing.T) {
 	}
 }
 
+func TestGetAllLegacyTargets(t *testing.T) {
+	cfg := &Config{
+		LoadedRecipes: []LoadedRecipeInfo{
+			{Dir: "vim", LegacyTargets: map[string]string{"~/.vimrc": "~/.config/nvim/init.vim"}},
+			{Dir: "shell"},
+		},
+	}
+
+	legacyTargets := GetAllLegacyTargets(cfg)
+	if len(legacyTargets) != 1 || legacyTargets["~/.vimrc"] != "~/.config/nvim/init.vim" {
+		t.Errorf("GetAllLegacyTargets() = %v, want the target mapping unchanged", legacyTargets)
+	}
+}
+
 func TestLoadConfig_WithRecipes(t *testing.T) {
 	// Create temp directory structure
 	tempDir := t.TempDir()
@@ -0,0 +1,45 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ApplyShellOverrides applies [shell.overrides] to the merged aliases and
+// functions. An override may name an alias, a function or both; one that
+// names neither is an error, since it is most likely a typo.
+func ApplyShellOverrides(cfg *Config) error {
+	names := make([]string, 0, len(cfg.Shell.Overrides))
+	for name := range cfg.Shell.Overrides {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		override := cfg.Shell.Overrides[name]
+		alias, isAlias := cfg.Shell.Aliases[name]
+		fn, isFunction := cfg.Shell.Functions[name]
+		if !isAlias && !isFunction {
+			return fmt.Errorf("shell override '%s' matches no alias or function", name)
+		}
+		if isAlias {
+			if override.Enable != nil {
+				alias.Enable = override.Enable
+			}
+			if len(override.Hosts) > 0 {
+				alias.Hosts = override.Hosts
+			}
+			cfg.Shell.Aliases[name] = alias
+		}
+		if isFunction {
+			if override.Enable != nil {
+				fn.Enable = override.Enable
+			}
+			if len(override.Hosts) > 0 {
+				fn.Hosts = override.Hosts
+			}
+			cfg.Shell.Functions[name] = fn
+		}
+	}
+	return nil
+}
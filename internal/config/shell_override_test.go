@@ -0,0 +1,48 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestApplyShellOverrides(t *testing.T) {
+	falseVal := false
+	cfg := &Config{Shell: ShellConfig{
+		Aliases: map[string]ShellAlias{
+			"k":  {Command: "kubectl"},
+			"gs": {Command: "git status", Hosts: []string{"laptop"}},
+		},
+		Functions: map[string]ShellFunction{
+			"mkcd": {Body: "mkdir -p \"$1\" && cd \"$1\""},
+		},
+		Overrides: map[string]ShellOverride{
+			"k":    {Enable: &falseVal},
+			"mkcd": {Hosts: []string{"work"}},
+		},
+	}}
+
+	if err := ApplyShellOverrides(cfg); err != nil {
+		t.Fatalf("ApplyShellOverrides() error = %v", err)
+	}
+	if IsEnabled(cfg.Shell.Aliases["k"].Enable) {
+		t.Error("alias k should be disabled by its override")
+	}
+	if got := cfg.Shell.Aliases["gs"].Hosts; !reflect.DeepEqual(got, []string{"laptop"}) {
+		t.Errorf("alias gs hosts = %v, want its own", got)
+	}
+	if got := cfg.Shell.Functions["mkcd"].Hosts; !reflect.DeepEqual(got, []string{"work"}) {
+		t.Errorf("function mkcd hosts = %v, want [work]", got)
+	}
+	if !IsEnabled(cfg.Shell.Functions["mkcd"].Enable) {
+		t.Error("function mkcd should stay enabled")
+	}
+}
+
+func TestApplyShellOverrides_Unknown(t *testing.T) {
+	cfg := &Config{Shell: ShellConfig{
+		Overrides: map[string]ShellOverride{"nope": {}},
+	}}
+	if err := ApplyShellOverrides(cfg); err == nil {
+		t.Error("expected an error for an override that names no alias or function")
+	}
+}
@@ -0,0 +1,73 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// sopsDecrypt decrypts a sops-encrypted file to JSON.
+// This is a variable to allow for easier testing.
+var sopsDecrypt = sopsDecryptInternal
+
+// sopsDecryptInternal runs `sops --decrypt --output-type json` on path. Key
+// management (age, KMS, PGP, ...) is left to sops and its usual configuration.
+func sopsDecryptInternal(path string) ([]byte, error) {
+	if _, err := exec.LookPath("sops"); err != nil {
+		return nil, fmt.Errorf("sops is not installed (needed to decrypt '%s')", path)
+	}
+	var stderr bytes.Buffer
+	cmd := exec.Command("sops", "--decrypt", "--output-type", "json", path)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("sops failed to decrypt '%s': %w: %s", path, err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// LoadSopsVariables decrypts the template_variables_sops file, if configured,
+// and merges its top-level keys into cfg.TemplateVariables. The path is
+// relative to dotfiles_repo_path unless absolute. A key that is also defined
+// in template_variables or a recipe is an error, as with recipes.
+func LoadSopsVariables(cfg *Config) error {
+	if cfg.TemplateVariablesSops == "" {
+		return nil
+	}
+	path, err := ExpandPath(cfg.TemplateVariablesSops)
+	if err != nil {
+		return fmt.Errorf("failed to expand template_variables_sops path: %w", err)
+	}
+	if !filepath.IsAbs(path) {
+		repoPath, err := ExpandPath(cfg.DotfilesRepoPath)
+		if err != nil {
+			return fmt.Errorf("failed to expand dotfiles_repo_path: %w", err)
+		}
+		path = filepath.Join(repoPath, path)
+	}
+
+	decrypted, err := sopsDecrypt(path)
+	if err != nil {
+		return err
+	}
+	var vars map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(decrypted))
+	decoder.UseNumber()
+	if err := decoder.Decode(&vars); err != nil {
+		return fmt.Errorf("failed to parse decrypted '%s' (top level must be a map): %w", path, err)
+	}
+
+	if cfg.TemplateVariables == nil {
+		cfg.TemplateVariables = make(map[string]interface{})
+	}
+	for name, val := range vars {
+		if _, exists := cfg.TemplateVariables[name]; exists {
+			return fmt.Errorf("template variable '%s' defined in multiple locations: template_variables_sops and main config (or a recipe)", name)
+		}
+		cfg.TemplateVariables[name] = val
+	}
+	return nil
+}
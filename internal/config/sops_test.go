@@ -0,0 +1,82 @@
+package config
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeSops replaces sopsDecrypt for the duration of a test, recording the
+// path it was asked to decrypt.
+func fakeSops(t *testing.T, output string, err error) *string {
+	t.Helper()
+	var decrypted string
+	orig := sopsDecrypt
+	sopsDecrypt = func(path string) ([]byte, error) {
+		decrypted = path
+		return []byte(output), err
+	}
+	t.Cleanup(func() { sopsDecrypt = orig })
+	return &decrypted
+}
+
+func TestLoadSopsVariables(t *testing.T) {
+	decrypted := fakeSops(t, `{"github_token": "s3cret", "smtp": {"port": 587}}`, nil)
+	cfg := &Config{
+		DotfilesRepoPath:      "/repo",
+		TemplateVariables:     map[string]interface{}{"email": "me@example.com"},
+		TemplateVariablesSops: "secrets.enc.yaml",
+	}
+
+	if err := LoadSopsVariables(cfg); err != nil {
+		t.Fatalf("LoadSopsVariables failed: %v", err)
+	}
+	if *decrypted != filepath.Join("/repo", "secrets.enc.yaml") {
+		t.Errorf("decrypted %q, want the path relative to the repo", *decrypted)
+	}
+	if cfg.TemplateVariables["github_token"] != "s3cret" || cfg.TemplateVariables["email"] != "me@example.com" {
+		t.Errorf("TemplateVariables = %v", cfg.TemplateVariables)
+	}
+	smtp, ok := cfg.TemplateVariables["smtp"].(map[string]interface{})
+	if !ok || smtp["port"].(interface{ String() string }).String() != "587" {
+		t.Errorf("nested value = %v, want port 587", cfg.TemplateVariables["smtp"])
+	}
+}
+
+func TestLoadSopsVariables_DuplicateKey(t *testing.T) {
+	fakeSops(t, `{"email": "other@example.com"}`, nil)
+	cfg := &Config{
+		DotfilesRepoPath:      "/repo",
+		TemplateVariables:     map[string]interface{}{"email": "me@example.com"},
+		TemplateVariablesSops: "secrets.enc.yaml",
+	}
+	err := LoadSopsVariables(cfg)
+	if err == nil || !strings.Contains(err.Error(), "'email' defined in multiple locations") {
+		t.Errorf("error = %v, want a duplicate variable error", err)
+	}
+}
+
+func TestLoadSopsVariables_Errors(t *testing.T) {
+	cfg := &Config{DotfilesRepoPath: "/repo", TemplateVariablesSops: "/abs/secrets.enc.json"}
+
+	decrypted := fakeSops(t, "", errors.New("no key"))
+	if err := LoadSopsVariables(cfg); err == nil {
+		t.Error("expected the decryption error to be returned")
+	}
+	if *decrypted != "/abs/secrets.enc.json" {
+		t.Errorf("absolute path was rewritten to %q", *decrypted)
+	}
+
+	fakeSops(t, `["not", "a", "map"]`, nil)
+	if err := LoadSopsVariables(cfg); err == nil {
+		t.Error("expected an error for a non-map document")
+	}
+}
+
+func TestLoadSopsVariables_Unset(t *testing.T) {
+	decrypted := fakeSops(t, "", errors.New("should not be called"))
+	if err := LoadSopsVariables(&Config{}); err != nil || *decrypted != "" {
+		t.Errorf("unset template_variables_sops should be a no-op, got %v", err)
+	}
+}
@@ -0,0 +1,79 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// StateDirEnv names the environment variable that overrides the state directory.
+const StateDirEnv = "RALPH_STATE_DIR"
+
+// configuredStateDir is the state_dir from the loaded config, if any.
+var configuredStateDir string
+
+// SetStateDir sets the state directory configured with state_dir. The
+// RALPH_STATE_DIR environment variable still takes precedence.
+func SetStateDir(dir string) {
+	configuredStateDir = dir
+}
+
+// GetStateDir returns the directory ralph keeps its state files in (build
+// state, the deploy manifest, created directories). In order of precedence:
+// $RALPH_STATE_DIR, state_dir from the config, $XDG_STATE_HOME/ralph, and
+// ~/.local/state/ralph.
+func GetStateDir() (string, error) {
+	dir := os.Getenv(StateDirEnv)
+	if dir == "" {
+		dir = configuredStateDir
+	}
+	if dir != "" {
+		return ExpandPath(dir)
+	}
+
+	stateHome := os.Getenv("XDG_STATE_HOME")
+	if stateHome == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("could not get user home directory: %w", err)
+		}
+		stateHome = filepath.Join(homeDir, ".local", "state")
+	}
+	return filepath.Join(stateHome, "ralph"), nil
+}
+
+// StateFilePath returns the path of the named state file in the state
+// directory. Older versions kept state files as hidden files in the config
+// directory (~/.config/ralph/.<name>); such a file is moved to the new
+// location the first time it is looked up.
+func StateFilePath(name string) (string, error) {
+	stateDir, err := GetStateDir()
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(stateDir, name)
+
+	configPath, err := GetDefaultConfigPath()
+	if err != nil {
+		return path, nil
+	}
+	legacyPath := filepath.Join(filepath.Dir(configPath), "."+name)
+	if SamePath(legacyPath, path) {
+		return path, nil
+	}
+	if _, err := os.Stat(legacyPath); err != nil {
+		return path, nil
+	}
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	}
+
+	if err := os.MkdirAll(stateDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create state directory '%s': %w", stateDir, err)
+	}
+	if err := os.Rename(legacyPath, path); err != nil {
+		return "", fmt.Errorf("failed to migrate state file from %s to %s: %w", legacyPath, path, err)
+	}
+	fmt.Printf("Migrated state file: %s -> %s\n", legacyPath, path)
+	return path, nil
+}
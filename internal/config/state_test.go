@@ -0,0 +1,95 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// isolateStateDir points HOME and the XDG directories at a temp dir and
+// clears any configured state directory.
+func isolateStateDir(t *testing.T) string {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("XDG_STATE_HOME", "")
+	t.Setenv(StateDirEnv, "")
+	SetStateDir("")
+	t.Cleanup(func() { SetStateDir("") })
+	return home
+}
+
+func TestGetStateDir(t *testing.T) {
+	home := isolateStateDir(t)
+
+	dir, err := GetStateDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(home, ".local", "state", "ralph"); dir != want {
+		t.Errorf("default state dir = %q, want %q", dir, want)
+	}
+
+	t.Setenv("XDG_STATE_HOME", filepath.Join(home, "xdg-state"))
+	if dir, _ := GetStateDir(); dir != filepath.Join(home, "xdg-state", "ralph") {
+		t.Errorf("XDG_STATE_HOME state dir = %q", dir)
+	}
+
+	SetStateDir("~/ralph-state")
+	if dir, _ := GetStateDir(); dir != filepath.Join(home, "ralph-state") {
+		t.Errorf("configured state dir = %q", dir)
+	}
+
+	t.Setenv(StateDirEnv, filepath.Join(home, "from-env"))
+	if dir, _ := GetStateDir(); dir != filepath.Join(home, "from-env") {
+		t.Errorf("%s should take precedence, got %q", StateDirEnv, dir)
+	}
+}
+
+func TestStateFilePath_MigratesLegacyFile(t *testing.T) {
+	home := isolateStateDir(t)
+	legacyDir := filepath.Join(home, ".config", "ralph")
+	if err := os.MkdirAll(legacyDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	legacy := filepath.Join(legacyDir, ".builds_state")
+	if err := os.WriteFile(legacy, []byte(`{"builds":{}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	path, err := StateFilePath("builds_state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(home, ".local", "state", "ralph", "builds_state"); path != want {
+		t.Errorf("path = %q, want %q", path, want)
+	}
+	if content, err := os.ReadFile(path); err != nil || string(content) != `{"builds":{}}` {
+		t.Errorf("migrated file = %q, %v", content, err)
+	}
+	if _, err := os.Stat(legacy); !os.IsNotExist(err) {
+		t.Errorf("legacy file should have been moved, stat err = %v", err)
+	}
+}
+
+func TestStateFilePath_KeepsExistingStateFile(t *testing.T) {
+	home := isolateStateDir(t)
+	legacyDir := filepath.Join(home, ".config", "ralph")
+	stateDir := filepath.Join(home, ".local", "state", "ralph")
+	for _, dir := range []string{legacyDir, stateDir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	os.WriteFile(filepath.Join(legacyDir, ".manifest"), []byte("old"), 0644)
+	os.WriteFile(filepath.Join(stateDir, "manifest"), []byte("new"), 0644)
+
+	path, err := StateFilePath("manifest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content, _ := os.ReadFile(path); string(content) != "new" {
+		t.Errorf("existing state file was replaced, content = %q", content)
+	}
+}
@@ -0,0 +1,21 @@
+package config
+
+import "strings"
+
+// TemplateExtension is the source file extension that marks a template
+// when auto_template is enabled.
+const TemplateExtension = ".tmpl"
+
+// ApplyAutoTemplate marks every dotfile whose source ends in TemplateExtension
+// as a template. If the target also ends in the extension, it is stripped so
+// the rendered file lands at the intended name.
+func ApplyAutoTemplate(cfg *Config) {
+	for name, df := range cfg.Dotfiles {
+		if !strings.HasSuffix(df.Source, TemplateExtension) {
+			continue
+		}
+		df.IsTemplate = true
+		df.Target = strings.TrimSuffix(df.Target, TemplateExtension)
+		cfg.Dotfiles[name] = df
+	}
+}
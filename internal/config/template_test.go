@@ -0,0 +1,26 @@
+package config
+
+import "testing"
+
+func TestApplyAutoTemplate(t *testing.T) {
+	cfg := &Config{
+		AutoTemplate: true,
+		Dotfiles: map[string]Dotfile{
+			"gitconfig": {Source: "git/gitconfig.tmpl", Target: "~/.gitconfig"},
+			"zshrc":     {Source: "zsh/zshrc.tmpl", Target: "~/.zshrc.tmpl"},
+			"vimrc":     {Source: "vim/vimrc", Target: "~/.vimrc"},
+		},
+	}
+
+	ApplyAutoTemplate(cfg)
+
+	if !cfg.Dotfiles["gitconfig"].IsTemplate {
+		t.Error("Expected gitconfig (.tmpl source) to be marked as template")
+	}
+	if got := cfg.Dotfiles["zshrc"].Target; got != "~/.zshrc" {
+		t.Errorf("Expected .tmpl to be stripped from target, got %q", got)
+	}
+	if cfg.Dotfiles["vimrc"].IsTemplate {
+		t.Error("Expected vimrc (no .tmpl) to not be marked as template")
+	}
+}
@@ -1,49 +1,293 @@
 package config
 
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
 // Config represents the main configuration structure for ralph.
 // It will be loaded from a TOML file.
 type Config struct {
-	DotfilesRepoPath  string                 `toml:"dotfiles_repo_path"`
-	Dotfiles          map[string]Dotfile     `toml:"dotfiles"`
-	Directories       map[string]Directory   `toml:"directories"`
-	Repos             map[string]Repo        `toml:"repos"`
-	Tools             []Tool                 `toml:"tools"`
-	Shell             ShellConfig            `toml:"shell"`
-	TemplateVariables map[string]interface{} `toml:"template_variables"`
-	Hooks             HooksConfig            `toml:"hooks"`
-	Recipes           []RecipeRef            `toml:"recipes"`        // Explicit recipe references (Mode A)
-	RecipesConfig     RecipesConfig          `toml:"recipes_config"` // Auto-discovery configuration (Mode B)
+	DotfilesRepoPath      string                 `toml:"dotfiles_repo_path"`
+	Dotfiles              map[string]Dotfile     `toml:"dotfiles"`
+	Directories           map[string]Directory   `toml:"directories"`
+	Repos                 map[string]Repo        `toml:"repos"`
+	Tools                 []Tool                 `toml:"tools"`
+	Shell                 ShellConfig            `toml:"shell"`
+	Bin                   BinConfig              `toml:"bin"`
+	MacOS                 MacOSConfig            `toml:"macos"`
+	VSCode                VSCodeConfig           `toml:"vscode"`
+	Services              map[string]Service     `toml:"services"`
+	Cron                  map[string]CronJob     `toml:"cron"`
+	TemplateVariables     map[string]interface{} `toml:"template_variables"`
+	TemplateVariablesSops string                 `toml:"template_variables_sops,omitempty"` // sops-encrypted file (relative to dotfiles_repo_path) merged into TemplateVariables
+	AutoTemplate          bool                   `toml:"auto_template,omitempty"`           // Treat any dotfile source ending in .tmpl as a template
+	KeepGoing             bool                   `toml:"keep_going,omitempty"`              // Continue apply past failing hooks, repos and builds (same as --keep-going)
+	Strict                bool                   `toml:"strict,omitempty"`                  // Warnings fail the run with exit code 1 (same as --strict)
+	Inventory             bool                   `toml:"inventory,omitempty"`               // Record each apply in state/machines/<host>.toml in the repo
+	StateDir              string                 `toml:"state_dir,omitempty"`               // Where state files live (default: $XDG_STATE_HOME/ralph); RALPH_STATE_DIR overrides
+	NoExpand              []string               `toml:"no_expand,omitempty"`               // Config keys whose ${VAR} references are kept as written, e.g. "template_variables.token"
+	Hooks                 HooksConfig            `toml:"hooks"`
+	Git                   GitConfig              `toml:"git"`
+	Backup                BackupConfig           `toml:"backup"`
+	Orphans               OrphansConfig          `toml:"orphans"`
+	Recipes               []RecipeRef            `toml:"recipes"`        // Explicit recipe references (Mode A)
+	RecipesConfig         RecipesConfig          `toml:"recipes_config"` // Auto-discovery configuration (Mode B)
+
+	// TemplateVariablesFromCommands maps template variables to shell commands
+	// whose output becomes their value when the config loads.
+	TemplateVariablesFromCommands map[string]string `toml:"template_variables_from_commands,omitempty"`
 
 	// loadedRecipes stores metadata about loaded recipes for migration support.
 	// This is populated during config loading and not from the TOML file.
 	LoadedRecipes []LoadedRecipeInfo `toml:"-"`
+
+	// Origins records which recipe each merged item came from, keyed by kind
+	// and name (see OriginOf). SkippedRecipes lists recipes not loaded for
+	// this host. Both are populated during config loading.
+	Origins        map[string]ItemOrigin `toml:"-"`
+	SkippedRecipes []SkippedRecipe       `toml:"-"`
+
+	// Overlays lists the config.<os>.toml and config.<host>.toml files merged
+	// over the main config, in merge order.
+	Overlays []string `toml:"-"`
+
+	// TemplatePrompts holds template variables declared with prompt = true
+	// that have no value yet (see ApplyLocalConfig).
+	TemplatePrompts map[string]TemplatePrompt `toml:"-"`
 }
 
 // LoadedRecipeInfo stores information about a loaded recipe for migration support.
 type LoadedRecipeInfo struct {
-	Path        string            // Path to the recipe file relative to dotfiles_repo_path
-	Dir         string            // Directory containing the recipe (relative to dotfiles_repo_path)
-	Name        string            // Recipe name from metadata
-	LegacyPaths map[string]string // Legacy path mappings for migration
+	Path          string            // Path to the recipe file relative to dotfiles_repo_path
+	Dir           string            // Directory containing the recipe (relative to dotfiles_repo_path)
+	Name          string            // Recipe name from metadata
+	LegacyPaths   map[string]string // Legacy path mappings for migration
+	LegacyTargets map[string]string // Old target paths -> new target paths for migration
 }
 
 // Dotfile represents a single dotfile to be managed.
 // The map key in Config.Dotfiles will be a logical name for the dotfile (e.g., "bashrc", "nvim_config").
 type Dotfile struct {
-	Source     string   `toml:"source"`                // Relative path within the dotfiles_repo_path
-	Target     string   `toml:"target"`                // Absolute path on the system, supporting ~
-	IsTemplate bool     `toml:"is_template,omitempty"` // Whether this dotfile should be processed as a Go template
-	Action     string   `toml:"action,omitempty"`      // "symlink" (default), "copy", or "symlink_dir"
-	Hosts      []string `toml:"hosts,omitempty"`       // List of hostnames this dotfile should apply to (empty = all hosts)
-	Enable     *bool    `toml:"enable,omitempty"`      // nil/true = enabled, false = disabled
+	Source         string   `toml:"source"`                    // Relative path within the dotfiles_repo_path
+	Target         string   `toml:"target"`                    // Absolute path on the system, supporting ~
+	IsTemplate     bool     `toml:"is_template,omitempty"`     // Whether this dotfile should be processed as a Go template
+	Action         string   `toml:"action,omitempty"`          // "symlink" (default), "copy", "symlink_dir", "template_dir" or a registered action
+	TemplateDelims []string `toml:"template_delims,omitempty"` // Custom template delimiters, e.g. ["[[", "]]"] (default: ["{{", "}}"])
+	Merge          bool     `toml:"merge,omitempty"`           // symlink_dir only: link the source's entries into an existing target directory
+	Ignore         []string `toml:"ignore,omitempty"`          // symlink_dir with merge and template_dir: globs of source paths to leave out, e.g. ["*.pyc", "spell/"]
+	Verify         string   `toml:"verify,omitempty"`          // Command run after apply to check the config loads, e.g. "nvim --headless +qa"
+	URL            string   `toml:"url,omitempty"`             // download and extract: where to fetch the file or archive from
+	Checksum       string   `toml:"checksum,omitempty"`        // download and extract: "sha256:<hex>" the file or archive must match
+	Mode           string   `toml:"mode,omitempty"`            // download only: octal file mode, e.g. "0755" (default "0644")
+	Hosts          []string `toml:"hosts,omitempty"`           // List of hostnames this dotfile should apply to (empty = all hosts)
+	Enable         *bool    `toml:"enable,omitempty"`          // nil/true = enabled, false = disabled
+	Aliases        []string `toml:"aliases,omitempty"`         // Former names: apply moves their manifest entry to this name
+	Backup         *bool    `toml:"backup,omitempty"`          // false = replace an existing target instead of backing it up
+
+	// Vars are template variables for this dotfile only, merged over all
+	// other template data ([dotfiles.<name>.vars])
+	Vars map[string]interface{} `toml:"vars,omitempty"`
+}
+
+// SHA256 returns the configured checksum as lowercase hex without the
+// "sha256:" prefix, or "" if none is set.
+func (d Dotfile) SHA256() (string, error) {
+	if d.Checksum == "" {
+		return "", nil
+	}
+	sum := strings.ToLower(strings.TrimPrefix(d.Checksum, "sha256:"))
+	if len(sum) != 64 || strings.Trim(sum, "0123456789abcdef") != "" {
+		return "", fmt.Errorf("checksum must be 'sha256:' followed by 64 hex digits, got '%s'", d.Checksum)
+	}
+	return sum, nil
+}
+
+// ArchiveName returns the URL or, without one, the source of an extract
+// dotfile's archive.
+func (d Dotfile) ArchiveName() string {
+	if d.URL == "" {
+		return d.Source
+	}
+	return d.URL
+}
+
+// IgnoredPath reports whether the source path rel (relative to the source
+// directory, slash or OS separated) matches one of patterns. A pattern
+// ending in "/" only matches directories, and a pattern without a "/" is
+// matched against the last element of rel, so "*.pyc" covers any depth.
+func IgnoredPath(patterns []string, rel string, isDir bool) bool {
+	rel = filepath.ToSlash(rel)
+	for _, pattern := range patterns {
+		if strings.HasSuffix(pattern, "/") {
+			if !isDir {
+				continue
+			}
+			pattern = strings.TrimSuffix(pattern, "/")
+		}
+		name := rel
+		if !strings.Contains(pattern, "/") {
+			name = path.Base(rel)
+		}
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ArchiveFormat returns the format of the archive at name, a path or URL, by
+// its extension: "zip", "tar", "tar.gz" or "tar.bz2", or "" if it is none of
+// them. A URL's query and fragment are ignored.
+func ArchiveFormat(name string) string {
+	if strings.Contains(name, "://") {
+		if i := strings.IndexAny(name, "?#"); i >= 0 {
+			name = name[:i]
+		}
+	}
+	name = strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(name, ".zip"):
+		return "zip"
+	case strings.HasSuffix(name, ".tar"):
+		return "tar"
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return "tar.gz"
+	case strings.HasSuffix(name, ".tar.bz2"), strings.HasSuffix(name, ".tbz2"):
+		return "tar.bz2"
+	}
+	return ""
+}
+
+// FileMode returns the configured mode, or 0 if none is set.
+func (d Dotfile) FileMode() (os.FileMode, error) {
+	if d.Mode == "" {
+		return 0, nil
+	}
+	mode, err := strconv.ParseUint(d.Mode, 8, 32)
+	if err != nil || mode > 0777 {
+		return 0, fmt.Errorf("mode must be an octal permission like '0644', got '%s'", d.Mode)
+	}
+	return os.FileMode(mode), nil
 }
 
 // Directory represents a directory to create.
 type Directory struct {
-	Target string   `toml:"target"`          // Absolute path on the system, supporting ~
-	Mode   string   `toml:"mode,omitempty"`  // Permission mode, e.g. "0755" (default)
-	Hosts  []string `toml:"hosts,omitempty"` // List of hostnames this directory should apply to (empty = all hosts)
-	Enable *bool    `toml:"enable,omitempty"` // nil/true = enabled, false = disabled
+	Target          string   `toml:"target"`                      // Absolute path on the system, supporting ~
+	Mode            string   `toml:"mode,omitempty"`              // Permission mode, e.g. "0755" (default)
+	Recursive       bool     `toml:"recursive,omitempty"`         // Apply mode to missing parent directories too (default: parents get 0755)
+	RemoveOnDisable bool     `toml:"remove_on_disable,omitempty"` // Remove the directories ralph created, if empty, once this entry is disabled or removed
+	Hosts           []string `toml:"hosts,omitempty"`             // List of hostnames this directory should apply to (empty = all hosts)
+	Enable          *bool    `toml:"enable,omitempty"`            // nil/true = enabled, false = disabled
+}
+
+// DefaultBinTarget is where [bin] scripts are deployed when target is not set.
+const DefaultBinTarget = "~/.local/bin"
+
+// BinConfig deploys the executables in a repo directory into a directory on
+// PATH. The section is active once source is set.
+type BinConfig struct {
+	Source    string   `toml:"source,omitempty"`      // Directory within dotfiles_repo_path holding the scripts, e.g. "bin"
+	Target    string   `toml:"target,omitempty"`      // Where scripts are deployed (default: DefaultBinTarget)
+	Copy      bool     `toml:"copy,omitempty"`        // Copy scripts instead of symlinking them
+	AddToPath *bool    `toml:"add_to_path,omitempty"` // nil/true = prepend target to PATH in 'ralph env'
+	Hosts     []string `toml:"hosts,omitempty"`       // List of hostnames scripts are deployed on (empty = all hosts)
+	Enable    *bool    `toml:"enable,omitempty"`      // nil/true = enabled, false = disabled
+}
+
+// Active reports whether bin scripts should be deployed on currentHost.
+func (b BinConfig) Active(currentHost string) bool {
+	return b.Source != "" && IsEnabled(b.Enable) && ShouldApplyForHost(b.Hosts, currentHost)
+}
+
+// TargetDir returns the configured target, or DefaultBinTarget.
+func (b BinConfig) TargetDir() string {
+	if b.Target == "" {
+		return DefaultBinTarget
+	}
+	return b.Target
+}
+
+// MacOSConfig holds macOS-only settings.
+type MacOSConfig struct {
+	Defaults map[string]MacOSDefault `toml:"defaults"` // Preferences set with `defaults write`, keyed by a logical name
+}
+
+// MacOSDefault is a single preference written with `defaults write`.
+type MacOSDefault struct {
+	Domain string      `toml:"domain"`           // e.g. "com.apple.dock" or "NSGlobalDomain"
+	Key    string      `toml:"key"`              // e.g. "autohide"
+	Type   string      `toml:"type,omitempty"`   // "string", "int", "float" or "bool"; inferred from value if omitted
+	Value  interface{} `toml:"value"`            // The desired value
+	Hosts  []string    `toml:"hosts,omitempty"`  // List of hostnames this default should apply to (empty = all hosts)
+	Enable *bool       `toml:"enable,omitempty"` // nil/true = enabled, false = disabled
+}
+
+// ValueType returns the configured type, or the one matching the TOML type
+// of Value ("" if Value has an unsupported type).
+func (d MacOSDefault) ValueType() string {
+	if d.Type != "" {
+		return d.Type
+	}
+	switch d.Value.(type) {
+	case bool:
+		return "bool"
+	case int64:
+		return "int"
+	case float64:
+		return "float"
+	case string:
+		return "string"
+	}
+	return ""
+}
+
+// DefaultVSCodeCommand is the editor CLI used when [vscode] command is not set.
+const DefaultVSCodeCommand = "code"
+
+// VSCodeConfig lists the VS Code extensions apply installs.
+type VSCodeConfig struct {
+	Extensions []string `toml:"extensions,omitempty"` // Extension IDs, e.g. "golang.go"
+	Command    string   `toml:"command,omitempty"`    // Editor CLI (default: DefaultVSCodeCommand), e.g. "codium"
+	Hosts      []string `toml:"hosts,omitempty"`      // List of hostnames extensions are installed on (empty = all hosts)
+	Enable     *bool    `toml:"enable,omitempty"`     // nil/true = enabled, false = disabled
+}
+
+// Active reports whether extensions should be installed on currentHost.
+func (v VSCodeConfig) Active(currentHost string) bool {
+	return len(v.Extensions) > 0 && IsEnabled(v.Enable) && ShouldApplyForHost(v.Hosts, currentHost)
+}
+
+// CommandName returns the configured command, or DefaultVSCodeCommand.
+func (v VSCodeConfig) CommandName() string {
+	if v.Command == "" {
+		return DefaultVSCodeCommand
+	}
+	return v.Command
+}
+
+// Service is a systemd user unit that apply enables (and by default starts)
+// once its unit file is in place, e.g. linked by a dotfile entry.
+type Service struct {
+	Unit    string   `toml:"unit"`              // Unit name, e.g. "syncthing.service"
+	Dotfile string   `toml:"dotfile,omitempty"` // Dotfile entry that installs the unit file; the service follows its enable/host filter
+	Start   *bool    `toml:"start,omitempty"`   // nil/true = enable --now, false = enable only
+	Hosts   []string `toml:"hosts,omitempty"`   // List of hostnames this service should apply to (empty = all hosts)
+	Enable  *bool    `toml:"enable,omitempty"`  // nil/true = enabled, false = disabled
+}
+
+// CronJob is an entry apply keeps in the ralph managed block of the user
+// crontab.
+type CronJob struct {
+	Schedule string   `toml:"schedule"`          // Five cron fields or a macro, e.g. "0 3 * * *" or "@daily"
+	Command  string   `toml:"command"`           // Command run by cron's shell
+	Comment  string   `toml:"comment,omitempty"` // Written above the entry, after the job name
+	Hosts    []string `toml:"hosts,omitempty"`   // List of hostnames this job should apply to (empty = all hosts)
+	Enable   *bool    `toml:"enable,omitempty"`  // nil/true = enabled, false = disabled
 }
 
 // Repo represents a git repository to clone.
@@ -59,53 +303,368 @@ type Repo struct {
 
 // Tool represents a standard tool that ralph can manage or check.
 type Tool struct {
-	Name         string    `toml:"name"`
-	CheckCommand string    `toml:"check_command"`
-	InstallHint  string    `toml:"install_hint"`
-	ConfigFiles  []Dotfile `toml:"config_files,omitempty"` // Optional: config files for this tool
-	Hosts        []string  `toml:"hosts,omitempty"`        // List of hostnames this tool should apply to (empty = all hosts)
-	Enable       *bool     `toml:"enable,omitempty"`       // nil/true = enabled, false = disabled
+	Name           string    `toml:"name"`
+	CheckCommand   string    `toml:"check_command"`
+	InstallHint    string    `toml:"install_hint"`
+	Verify         string    `toml:"verify,omitempty"`          // Command run after apply to check the tool works, e.g. "fzf --version"
+	VersionCommand string    `toml:"version_command,omitempty"` // Prints the version for .ToolVersion in config file templates, e.g. "starship --version"
+	ConfigFiles    []Dotfile `toml:"config_files,omitempty"`    // Optional: config files for this tool, applied like dotfiles
+	Hosts          []string  `toml:"hosts,omitempty"`           // List of hostnames this tool should apply to (empty = all hosts)
+	Enable         *bool     `toml:"enable,omitempty"`          // nil/true = enabled, false = disabled
 }
 
 // ShellConfig holds configurations related to shell aliases and functions.
 type ShellConfig struct {
-	Name      string                   `toml:"name,omitempty"` // Explicit shell name (bash/zsh/fish); auto-detected from $SHELL if omitted
-	Aliases   map[string]ShellAlias    `toml:"aliases"`
-	Functions map[string]ShellFunction `toml:"functions"`
-	Env       map[string]string        `toml:"env"` // Environment variables (no host filtering for now)
+	Name        string                   `toml:"name,omitempty"`   // Explicit shell name (bash/zsh/fish); auto-detected from $SHELL if omitted
+	Names       []string                 `toml:"names,omitempty"`  // Several shells to configure in one apply, e.g. ["zsh", "fish"]; takes precedence over name
+	Detect      bool                     `toml:"detect,omitempty"` // Also configure every supported shell installed on this machine (/etc/shells and PATH)
+	Aliases     map[string]ShellAlias    `toml:"aliases"`
+	Functions   map[string]ShellFunction `toml:"functions"`
+	Env         map[string]ShellEnvVar   `toml:"env"` // Environment variables
+	Completions ShellCompletions         `toml:"completions,omitempty"`
+	Zsh         ZshConfig                `toml:"zsh,omitempty"`
+	Fish        FishConfig               `toml:"fish,omitempty"`
+	// HelpFunction names a generated shell function (e.g. "alias-help") that
+	// prints the aliases and functions like 'ralph shell list'. Empty = none.
+	HelpFunction string `toml:"help_function,omitempty"`
+	// Overrides set enable/hosts of aliases and functions by name, after
+	// recipes are merged, e.g. in config.<host>.toml to drop one alias on
+	// one machine. Only read from the main config and its overlays.
+	Overrides map[string]ShellOverride `toml:"overrides,omitempty"`
+}
+
+// ShellOverride replaces the enable flag and/or hosts of the aliases and
+// functions with its name. Unset fields leave the item's own value.
+type ShellOverride struct {
+	Enable *bool    `toml:"enable,omitempty"` // nil = keep, false = disabled, true = enabled
+	Hosts  []string `toml:"hosts,omitempty"`  // Replaces the item's hosts (empty = keep)
+}
+
+// ShellCompletions lists zsh completion files in the dotfiles repo. They are
+// linked into one directory that the managed rc block adds to fpath.
+type ShellCompletions struct {
+	Paths    []string `toml:"paths,omitempty"`    // Completion files, or directories of them, relative to the repo
+	Compinit bool     `toml:"compinit,omitempty"` // Run compinit from the managed block and drop its cache when the completions change
+}
+
+// ZshConfig holds settings that only apply to zsh.
+type ZshConfig struct {
+	Plugins    map[string]ZshPlugin `toml:"plugins,omitempty"`     // Plugins cloned as repos and sourced from the managed rc block
+	PluginsDir string               `toml:"plugins_dir,omitempty"` // Where url plugins are cloned (default: ~/.local/share/ralph/zsh-plugins)
+}
+
+// ZshPlugin is a zsh plugin or prompt theme from [shell.zsh.plugins]. It is
+// either cloned from url into the plugins dir, as a repo named
+// zsh-plugin-<name>, or taken from a [repos.<name>] checkout.
+type ZshPlugin struct {
+	URL    string   `toml:"url,omitempty"`    // Git URL to clone
+	Repo   string   `toml:"repo,omitempty"`   // Name of a managed repo to use instead of url
+	Source string   `toml:"source,omitempty"` // File to source, relative to the checkout (default: detected, e.g. <name>.plugin.zsh)
+	Order  int      `toml:"order,omitempty"`  // Load order, lowest first; ties load by name
+	Branch string   `toml:"branch,omitempty"` // Branch to check out (url only)
+	Commit string   `toml:"commit,omitempty"` // Pin to a commit (url only)
+	Update bool     `toml:"update,omitempty"` // Pull on each apply (url only)
+	Hosts  []string `toml:"hosts,omitempty"`  // List of hostnames this plugin should apply to (empty = all hosts)
+	Enable *bool    `toml:"enable,omitempty"` // nil/true = enabled, false = disabled
+}
+
+// FishConfig holds settings that only apply to fish.
+type FishConfig struct {
+	Universal map[string]FishUniversalVar `toml:"universal,omitempty"` // Universal variables set with set -U when they differ
+}
+
+// FishUniversalVar is a fish universal variable from [shell.fish.universal].
+// In TOML it is a string (fish_greeting = ""), an array for a list variable
+// (fish_user_paths = ["~/go/bin"]) or a table with a value and optional host
+// filtering (fish_color_command = { value = "blue", hosts = ["work"] }).
+// Values may use template syntax; a leading ~ is expanded.
+type FishUniversalVar struct {
+	Values []string
+	Hosts  []string
+	Enable *bool
+}
+
+// UnmarshalTOML decodes each form of FishUniversalVar.
+func (v *FishUniversalVar) UnmarshalTOML(data interface{}) error {
+	switch d := data.(type) {
+	case string:
+		v.Values = []string{d}
+		return nil
+	case []interface{}:
+		values, err := stringList(d)
+		if err != nil {
+			return fmt.Errorf("fish universal variable must be an array of strings")
+		}
+		v.Values = values
+		return nil
+	case map[string]interface{}:
+		for key, val := range d {
+			switch key {
+			case "value":
+				if s, ok := val.(string); ok {
+					v.Values = []string{s}
+					continue
+				}
+				values, err := stringList(val)
+				if err != nil {
+					return fmt.Errorf("fish universal 'value' must be a string or an array of strings")
+				}
+				v.Values = values
+			case "hosts":
+				hosts, err := stringList(val)
+				if err != nil {
+					return fmt.Errorf("fish universal 'hosts' must be an array of strings")
+				}
+				v.Hosts = hosts
+			case "enable":
+				b, ok := val.(bool)
+				if !ok {
+					return fmt.Errorf("fish universal 'enable' must be a boolean")
+				}
+				v.Enable = &b
+			default:
+				return fmt.Errorf("unknown fish universal key '%s' (expected value, hosts or enable)", key)
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("fish universal variable must be a string, an array or a table, got %T", data)
+	}
+}
+
+// ShellEnvVar is an environment variable from [shell.env]. In TOML it is either
+// a plain string (EDITOR = "nvim") or a table with a value and optional host
+// filtering (GOPATH = { value = "~/go", hosts = ["work"] }).
+// The value may use template syntax, e.g. {{ .Home }}.
+type ShellEnvVar struct {
+	Value  string
+	Hosts  []string
+	Enable *bool
+}
+
+// UnmarshalTOML decodes either form of ShellEnvVar.
+func (v *ShellEnvVar) UnmarshalTOML(data interface{}) error {
+	switch d := data.(type) {
+	case string:
+		v.Value = d
+		return nil
+	case map[string]interface{}:
+		for key, val := range d {
+			switch key {
+			case "value":
+				s, ok := val.(string)
+				if !ok {
+					return fmt.Errorf("env 'value' must be a string")
+				}
+				v.Value = s
+			case "hosts":
+				hosts, err := stringList(val)
+				if err != nil {
+					return fmt.Errorf("env 'hosts' must be an array of strings")
+				}
+				v.Hosts = hosts
+			case "enable":
+				b, ok := val.(bool)
+				if !ok {
+					return fmt.Errorf("env 'enable' must be a boolean")
+				}
+				v.Enable = &b
+			default:
+				return fmt.Errorf("unknown env key '%s' (expected value, hosts or enable)", key)
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("env value must be a string or a table, got %T", data)
+	}
 }
 
-// ShellAlias represents a shell alias with optional host filtering.
+// stringList converts a decoded TOML array to strings.
+func stringList(data interface{}) ([]string, error) {
+	list, ok := data.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("not an array")
+	}
+	strs := make([]string, 0, len(list))
+	for _, item := range list {
+		s, ok := item.(string)
+		if !ok {
+			return nil, fmt.Errorf("not a string: %v", item)
+		}
+		strs = append(strs, s)
+	}
+	return strs, nil
+}
+
+// ShellAlias represents a shell alias with optional host filtering. In TOML
+// it is either a plain string (ll = "ls -alhF") or a table with a command and
+// optional description, hosts and enable fields.
 type ShellAlias struct {
-	Command string   `toml:"command"`         // The command this alias executes
-	Hosts   []string `toml:"hosts,omitempty"` // List of hostnames this alias should apply to (empty = all hosts)
-	Enable  *bool    `toml:"enable,omitempty"` // nil/true = enabled, false = disabled
+	Command     string   `toml:"command"`               // The command this alias executes
+	Description string   `toml:"description,omitempty"` // Shown in the generated file and ralph list
+	Hosts       []string `toml:"hosts,omitempty"`       // List of hostnames this alias should apply to (empty = all hosts)
+	Enable      *bool    `toml:"enable,omitempty"`      // nil/true = enabled, false = disabled
+}
+
+// UnmarshalTOML decodes either form of ShellAlias.
+func (a *ShellAlias) UnmarshalTOML(data interface{}) error {
+	switch d := data.(type) {
+	case string:
+		a.Command = d
+		return nil
+	case map[string]interface{}:
+		for key, val := range d {
+			switch key {
+			case "command", "description":
+				s, ok := val.(string)
+				if !ok {
+					return fmt.Errorf("alias '%s' must be a string", key)
+				}
+				if key == "command" {
+					a.Command = s
+				} else {
+					a.Description = s
+				}
+			case "hosts":
+				hosts, err := stringList(val)
+				if err != nil {
+					return fmt.Errorf("alias 'hosts' must be an array of strings")
+				}
+				a.Hosts = hosts
+			case "enable":
+				b, ok := val.(bool)
+				if !ok {
+					return fmt.Errorf("alias 'enable' must be a boolean")
+				}
+				a.Enable = &b
+			default:
+				return fmt.Errorf("unknown alias key '%s' (expected command, description, hosts or enable)", key)
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("alias must be a string or a table, got %T", data)
+	}
 }
 
 // ShellFunction represents a custom shell function.
 // The map key in ShellConfig.Functions will be the function name.
 type ShellFunction struct {
-	Body   string   `toml:"body"`            // The actual shell script for the function body
-	Hosts  []string `toml:"hosts,omitempty"` // List of hostnames this function should apply to (empty = all hosts)
-	Enable *bool    `toml:"enable,omitempty"` // nil/true = enabled, false = disabled
+	Body        string   `toml:"body"`                  // The actual shell script for the function body
+	Description string   `toml:"description,omitempty"` // Shown in the generated file and ralph shell list
+	Hosts       []string `toml:"hosts,omitempty"`       // List of hostnames this function should apply to (empty = all hosts)
+	Enable      *bool    `toml:"enable,omitempty"`      // nil/true = enabled, false = disabled
 }
 
 // HooksConfig holds configuration for various lifecycle hooks
 type HooksConfig struct {
-	PreApply  []string            `toml:"pre_apply"`  // Hooks to run before applying any dotfiles
-	PostApply []string            `toml:"post_apply"` // Hooks to run after applying all dotfiles
+	PreApply  []HookGroup         `toml:"pre_apply"`  // Hooks to run before applying any dotfiles
+	PostApply []HookGroup         `toml:"post_apply"` // Hooks to run after applying all dotfiles
 	PreLink   map[string][]string `toml:"pre_link"`   // Hooks to run before linking a specific dotfile
 	PostLink  map[string][]string `toml:"post_link"`  // Hooks to run after linking a specific dotfile
 	Builds    map[string]Build    `toml:"builds"`     // Build hooks that run during apply
 }
 
+// HookGroup is one entry of pre_apply or post_apply. In TOML it is either a
+// command string, which runs on its own, or a table naming a group of
+// commands ({ name = "setup", commands = [...], parallel = true }). Entries
+// run one after the other in order; the commands of a parallel group run at
+// the same time.
+type HookGroup struct {
+	Name     string   `toml:"name,omitempty"`
+	Commands []string `toml:"commands"`
+	Parallel bool     `toml:"parallel,omitempty"`
+}
+
+// UnmarshalTOML decodes either form of HookGroup.
+func (g *HookGroup) UnmarshalTOML(data interface{}) error {
+	switch d := data.(type) {
+	case string:
+		g.Commands = []string{d}
+		return nil
+	case map[string]interface{}:
+		for key, val := range d {
+			switch key {
+			case "name":
+				s, ok := val.(string)
+				if !ok {
+					return fmt.Errorf("hook group 'name' must be a string")
+				}
+				g.Name = s
+			case "commands":
+				commands, err := stringList(val)
+				if err != nil {
+					return fmt.Errorf("hook group 'commands' must be an array of strings")
+				}
+				g.Commands = commands
+			case "parallel":
+				b, ok := val.(bool)
+				if !ok {
+					return fmt.Errorf("hook group 'parallel' must be a boolean")
+				}
+				g.Parallel = b
+			default:
+				return fmt.Errorf("unknown hook group key '%s' (expected name, commands or parallel)", key)
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("hook must be a command string or a table, got %T", data)
+	}
+}
+
+// HookCommands returns the commands of groups in order.
+func HookCommands(groups []HookGroup) []string {
+	var commands []string
+	for _, g := range groups {
+		commands = append(commands, g.Commands...)
+	}
+	return commands
+}
+
+// GitConfig controls committing and pushing changes in the dotfiles repo
+// after a successful apply.
+type GitConfig struct {
+	AutoCommit      bool   `toml:"auto_commit,omitempty"`      // Commit all changes in dotfiles_repo_path after apply
+	AutoPush        bool   `toml:"auto_push,omitempty"`        // Push after committing
+	MessageTemplate string `toml:"message_template,omitempty"` // Go template for the commit message (default: DefaultCommitMessageTemplate)
+
+	// URLRewrites and Prefer change the URLs managed repos are cloned and
+	// updated from, like git's url.<base>.insteadOf (see RewriteURL).
+	URLRewrites map[string]string `toml:"url_rewrites,omitempty"` // URL prefix -> replacement, e.g. "https://github.com/" = "git@github.com:"
+	Prefer      string            `toml:"prefer,omitempty"`       // "ssh" or "https": convert repo URLs no rewrite matched to that form
+}
+
+// BackupConfig controls the backups apply makes of targets it replaces
+// ([backup] section).
+type BackupConfig struct {
+	Suffix string `toml:"suffix,omitempty"` // Appended to the target's name (default: DefaultBackupSuffix)
+	Dir    string `toml:"dir,omitempty"`    // Keep backups here, at the target's path relative to home, instead of next to the target
+	Keep   int    `toml:"keep,omitempty"`   // Backups kept per target, older ones numbered .1, .2, ... (default: 1)
+}
+
+// OrphansConfig configures 'ralph orphans' ([orphans] section).
+type OrphansConfig struct {
+	Ignore []string `toml:"ignore,omitempty"` // Glob patterns for repo files that are not orphans, e.g. "README.md" or "docs/*"
+}
+
+// DefaultBackupSuffix is the backup suffix used when backup.suffix is not set.
+const DefaultBackupSuffix = ".bak"
+
+// DefaultCommitMessageTemplate is the commit message used when
+// git.message_template is not set.
+const DefaultCommitMessageTemplate = "ralph: update dotfiles from {{ .Host }}"
+
 // Build represents a build hook with multiple commands
 type Build struct {
 	Commands   []string `toml:"commands"`              // Commands to execute
 	WorkingDir string   `toml:"working_dir,omitempty"` // Working directory for commands
+	Repo       string   `toml:"repo,omitempty"`        // [repos] entry the build belongs to: working_dir defaults to, or is relative to, its target
 	Run        string   `toml:"run"`                   // "always", "once", or "manual"
+	Verify     string   `toml:"verify,omitempty"`      // Command run in WorkingDir after apply to check the build output works
 	Hosts      []string `toml:"hosts,omitempty"`       // List of hostnames this build should apply to (empty = all hosts)
 	Enable     *bool    `toml:"enable,omitempty"`      // nil/true = enabled, false = disabled
+	Aliases    []string `toml:"aliases,omitempty"`     // Former names: apply moves their build state to this name
 }
 
 // RecipeRef represents a reference to a recipe file in the main config.
@@ -129,6 +688,7 @@ type RecipesConfig struct {
 	Dir          string                    `toml:"dir,omitempty"`           // Directory to search for recipes (default: "recipes")
 	Exclude      []string                  `toml:"exclude,omitempty"`       // Glob patterns to exclude from auto-discovery
 	Overrides    map[string]RecipeOverride `toml:"overrides,omitempty"`     // Override enable/hosts for specific recipes by directory name
+	AutoMigrate  bool                      `toml:"auto_migrate,omitempty"`  // apply moves dotfiles defined in config.toml into recipes first (see 'ralph migrate-config')
 }
 
 // DefaultRecipesDir is the default directory for recipes when using auto-discovery or short names.
@@ -136,9 +696,10 @@ const DefaultRecipesDir = "recipes"
 
 // RecipeMetadata contains optional metadata about a recipe.
 type RecipeMetadata struct {
-	Name        string            `toml:"name,omitempty"`         // Human-readable name for the recipe
-	Description string            `toml:"description,omitempty"`  // Description of what this recipe provides
-	LegacyPaths map[string]string `toml:"legacy_paths,omitempty"` // Map of old source paths to new paths for migration
+	Name          string            `toml:"name,omitempty"`           // Human-readable name for the recipe
+	Description   string            `toml:"description,omitempty"`    // Description of what this recipe provides
+	LegacyPaths   map[string]string `toml:"legacy_paths,omitempty"`   // Map of old source paths to new paths for migration
+	LegacyTargets map[string]string `toml:"legacy_targets,omitempty"` // Map of old target paths to new target paths for migration
 }
 
 // Recipe represents a modular configuration file (recipe.toml) that can be
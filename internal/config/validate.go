@@ -3,10 +3,55 @@ package config
 import (
 	"fmt"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
 	"strings"
+	"text/template"
 )
 
+// dotfileActions are the valid values of a dotfile's action field. Actions
+// implemented outside this package are added with RegisterDotfileAction.
+var dotfileActions = map[string]bool{
+	"symlink":      true,
+	"copy":         true,
+	"symlink_dir":  true,
+	"template_dir": true,
+	"download":     true,
+	"extract":      true,
+	"append_block": true,
+}
+
+// RegisterDotfileAction makes name a valid dotfile action. It must be called
+// before the config is loaded.
+func RegisterDotfileAction(name string) {
+	dotfileActions[name] = true
+}
+
+// DotfileActions returns the valid dotfile actions, sorted.
+func DotfileActions() []string {
+	names := make([]string, 0, len(dotfileActions))
+	for name := range dotfileActions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// quotedList formats names as 'a', 'b' or 'c'.
+func quotedList(names []string) string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = "'" + name + "'"
+	}
+	if len(quoted) < 2 {
+		return strings.Join(quoted, "")
+	}
+	return strings.Join(quoted[:len(quoted)-1], ", ") + " or " + quoted[len(quoted)-1]
+}
+
 // ValidateConfig performs basic validation on the loaded configuration.
 func ValidateConfig(cfg *Config) error {
 	if cfg.DotfilesRepoPath == "" {
@@ -28,15 +73,27 @@ func ValidateConfig(cfg *Config) error {
 	}
 
 	for name, df := range cfg.Dotfiles {
-		if df.Source == "" {
+		if df.Source == "" && df.Action != "download" && (df.Action != "extract" || df.URL == "") {
 			return fmt.Errorf("dotfile item '%s': source cannot be empty", name)
 		}
 		if df.Target == "" {
 			return fmt.Errorf("dotfile item '%s': target cannot be empty", name)
 		}
 		// Validate action field
-		if df.Action != "" && df.Action != "symlink" && df.Action != "copy" && df.Action != "symlink_dir" {
-			return fmt.Errorf("dotfile item '%s': action must be 'symlink', 'copy', or 'symlink_dir', got '%s'", name, df.Action)
+		if df.Action != "" && !dotfileActions[df.Action] {
+			return fmt.Errorf("dotfile item '%s': action must be one of %s, got '%s'", name, quotedList(DotfileActions()), df.Action)
+		}
+		if err := validateTemplateDelims(df.TemplateDelims); err != nil {
+			return fmt.Errorf("dotfile item '%s': %w", name, err)
+		}
+		if df.Merge && df.Action != "symlink_dir" {
+			return fmt.Errorf("dotfile item '%s': merge is only supported with action 'symlink_dir'", name)
+		}
+		if err := validateIgnore(df); err != nil {
+			return fmt.Errorf("dotfile item '%s': %w", name, err)
+		}
+		if err := validateFetched(df); err != nil {
+			return fmt.Errorf("dotfile item '%s': %w", name, err)
 		}
 		// Target should ideally be an absolute path after expansion
 		expandedTarget, err := ExpandPath(df.Target)
@@ -106,6 +163,27 @@ func ValidateConfig(cfg *Config) error {
 		}
 	}
 
+	for i, path := range cfg.Shell.Completions.Paths {
+		if path == "" {
+			return fmt.Errorf("shell.completions: path at index %d cannot be empty", i)
+		}
+	}
+
+	seenShells := make(map[string]bool)
+	for _, name := range cfg.Shell.Names {
+		if name != "bash" && name != "zsh" && name != "fish" {
+			return fmt.Errorf("shell.names: unsupported shell '%s' (supported: bash, zsh, fish)", name)
+		}
+		if seenShells[name] {
+			return fmt.Errorf("shell.names: '%s' is listed twice", name)
+		}
+		seenShells[name] = true
+	}
+
+	if err := validateHelpFunction(cfg.Shell); err != nil {
+		return err
+	}
+
 	// Validate build hooks
 	for name, build := range cfg.Hooks.Builds {
 		if len(build.Commands) == 0 {
@@ -126,6 +204,27 @@ func ValidateConfig(cfg *Config) error {
 		}
 	}
 
+	if cfg.Git.AutoPush && !cfg.Git.AutoCommit {
+		return fmt.Errorf("git: auto_push requires auto_commit")
+	}
+	if cfg.Git.MessageTemplate != "" {
+		if _, err := template.New("message_template").Parse(cfg.Git.MessageTemplate); err != nil {
+			return fmt.Errorf("git: invalid message_template: %w", err)
+		}
+	}
+
+	switch cfg.Git.Prefer {
+	case "", "ssh", "https":
+	default:
+		return fmt.Errorf("git: prefer must be 'ssh' or 'https', got '%s'", cfg.Git.Prefer)
+	}
+	if cfg.Backup.Keep < 0 {
+		return fmt.Errorf("backup: keep must be 1 or more, got %d", cfg.Backup.Keep)
+	}
+	if strings.ContainsAny(cfg.Backup.Suffix, `/\`) {
+		return fmt.Errorf("backup: suffix '%s' must not contain a path separator; use dir to move backups elsewhere", cfg.Backup.Suffix)
+	}
+
 	return nil
 }
 
@@ -133,16 +232,37 @@ func ValidateConfig(cfg *Config) error {
 // (after recipes have been processed). This validates the consistency
 // of the complete configuration.
 func ValidateMergedConfig(cfg *Config) error {
+	// Validate hook groups, including those from recipes
+	for hookType, groups := range map[string][]HookGroup{"pre_apply": cfg.Hooks.PreApply, "post_apply": cfg.Hooks.PostApply} {
+		for i, g := range groups {
+			if len(g.Commands) == 0 {
+				return fmt.Errorf("hooks: %s entry %d has no commands", hookType, i+1)
+			}
+		}
+	}
+
 	// Validate all dotfiles (including those from recipes)
 	for name, df := range cfg.Dotfiles {
-		if df.Source == "" {
+		if df.Source == "" && df.Action != "download" && (df.Action != "extract" || df.URL == "") {
 			return fmt.Errorf("dotfile item '%s': source cannot be empty", name)
 		}
 		if df.Target == "" {
 			return fmt.Errorf("dotfile item '%s': target cannot be empty", name)
 		}
-		if df.Action != "" && df.Action != "symlink" && df.Action != "copy" && df.Action != "symlink_dir" {
-			return fmt.Errorf("dotfile item '%s': action must be 'symlink', 'copy', or 'symlink_dir', got '%s'", name, df.Action)
+		if df.Action != "" && !dotfileActions[df.Action] {
+			return fmt.Errorf("dotfile item '%s': action must be one of %s, got '%s'", name, quotedList(DotfileActions()), df.Action)
+		}
+		if err := validateTemplateDelims(df.TemplateDelims); err != nil {
+			return fmt.Errorf("dotfile item '%s': %w", name, err)
+		}
+		if df.Merge && df.Action != "symlink_dir" {
+			return fmt.Errorf("dotfile item '%s': merge is only supported with action 'symlink_dir'", name)
+		}
+		if err := validateIgnore(df); err != nil {
+			return fmt.Errorf("dotfile item '%s': %w", name, err)
+		}
+		if err := validateFetched(df); err != nil {
+			return fmt.Errorf("dotfile item '%s': %w", name, err)
 		}
 		expandedTarget, err := ExpandPath(df.Target)
 		if err != nil {
@@ -151,6 +271,13 @@ func ValidateMergedConfig(cfg *Config) error {
 		_ = expandedTarget // Used for validation
 	}
 
+	if err := validateAliases("dotfile item", DotfileAliases(cfg)); err != nil {
+		return err
+	}
+	if err := validateAliases("build", BuildAliases(cfg)); err != nil {
+		return err
+	}
+
 	// Validate all directories
 	for name, dir := range cfg.Directories {
 		if dir.Target == "" {
@@ -211,6 +338,83 @@ func ValidateMergedConfig(cfg *Config) error {
 		}
 	}
 
+	for i, path := range cfg.Shell.Completions.Paths {
+		if path == "" {
+			return fmt.Errorf("shell.completions: path at index %d cannot be empty", i)
+		}
+	}
+
+	if err := validateHelpFunction(cfg.Shell); err != nil {
+		return err
+	}
+
+	// Validate all shell env var names
+	for name := range cfg.Shell.Env {
+		if !envNamePattern.MatchString(name) {
+			return fmt.Errorf("shell env var '%s': name must contain only letters, digits and underscores and not start with a digit", name)
+		}
+	}
+	for name := range cfg.Shell.Fish.Universal {
+		if !envNamePattern.MatchString(name) {
+			return fmt.Errorf("fish universal variable '%s': name must contain only letters, digits and underscores and not start with a digit", name)
+		}
+	}
+
+	// Validate all macOS defaults
+	for name, def := range cfg.MacOS.Defaults {
+		if def.Domain == "" {
+			return fmt.Errorf("macos default '%s': domain cannot be empty", name)
+		}
+		if def.Key == "" {
+			return fmt.Errorf("macos default '%s': key cannot be empty", name)
+		}
+		if def.Value == nil {
+			return fmt.Errorf("macos default '%s': value cannot be empty", name)
+		}
+		if err := validateMacOSDefaultValue(def); err != nil {
+			return fmt.Errorf("macos default '%s': %w", name, err)
+		}
+	}
+
+	// Validate VS Code extension IDs (publisher.name)
+	for _, id := range cfg.VSCode.Extensions {
+		publisher, name, ok := strings.Cut(id, ".")
+		if !ok || publisher == "" || name == "" || strings.ContainsAny(id, " \t/") {
+			return fmt.Errorf("vscode extension '%s': expected an ID of the form publisher.name", id)
+		}
+	}
+
+	// Validate all services
+	for name, svc := range cfg.Services {
+		if svc.Unit == "" {
+			return fmt.Errorf("service '%s': unit cannot be empty", name)
+		}
+		if !strings.Contains(svc.Unit, ".") || strings.Contains(svc.Unit, "/") {
+			return fmt.Errorf("service '%s': unit '%s' must be a unit name such as 'name.service'", name, svc.Unit)
+		}
+		if svc.Dotfile != "" {
+			if _, ok := cfg.Dotfiles[svc.Dotfile]; !ok {
+				return fmt.Errorf("service '%s': dotfile '%s' is not defined", name, svc.Dotfile)
+			}
+		}
+	}
+
+	// Validate all cron jobs
+	for name, job := range cfg.Cron {
+		if strings.ContainsAny(name, "\n\r") {
+			return fmt.Errorf("cron job '%s': name cannot contain newlines", name)
+		}
+		if job.Command == "" {
+			return fmt.Errorf("cron job '%s': command cannot be empty", name)
+		}
+		if strings.ContainsAny(job.Command+job.Comment, "\n\r") {
+			return fmt.Errorf("cron job '%s': command and comment must be a single line", name)
+		}
+		if fields := strings.Fields(job.Schedule); !(len(fields) == 5 || (len(fields) == 1 && strings.HasPrefix(fields[0], "@"))) {
+			return fmt.Errorf("cron job '%s': schedule '%s' must have five fields or be a macro like @daily", name, job.Schedule)
+		}
+	}
+
 	// Validate all builds
 	for name, build := range cfg.Hooks.Builds {
 		if len(build.Commands) == 0 {
@@ -222,11 +426,100 @@ func ValidateMergedConfig(cfg *Config) error {
 		if build.Run != "always" && build.Run != "once" && build.Run != "manual" {
 			return fmt.Errorf("build '%s': run mode must be 'always', 'once', or 'manual', got '%s'", name, build.Run)
 		}
+		if _, ok := cfg.Repos[build.Repo]; build.Repo != "" && !ok {
+			return fmt.Errorf("build '%s': repo '%s' is not defined in [repos]", name, build.Repo)
+		}
 	}
 
 	return nil
 }
 
+// validateTemplateDelims checks that template_delims, if set, holds exactly
+// two non-empty delimiters.
+func validateTemplateDelims(delims []string) error {
+	if len(delims) == 0 {
+		return nil
+	}
+	if len(delims) != 2 || delims[0] == "" || delims[1] == "" {
+		return fmt.Errorf("template_delims must contain exactly two non-empty delimiters, got %q", delims)
+	}
+	return nil
+}
+
+// helpFunctionName matches the names help_function may use.
+var helpFunctionName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+
+// validateHelpFunction checks shell.help_function.
+func validateHelpFunction(sh ShellConfig) error {
+	if sh.HelpFunction == "" {
+		return nil
+	}
+	if !helpFunctionName.MatchString(sh.HelpFunction) {
+		return fmt.Errorf("shell.help_function: '%s' is not a valid function name", sh.HelpFunction)
+	}
+	if _, exists := sh.Functions[sh.HelpFunction]; exists {
+		return fmt.Errorf("shell.help_function: '%s' is already a shell function", sh.HelpFunction)
+	}
+	if _, exists := sh.Aliases[sh.HelpFunction]; exists {
+		return fmt.Errorf("shell.help_function: '%s' is already a shell alias", sh.HelpFunction)
+	}
+	return nil
+}
+
+// validateIgnore checks the ignore patterns of a dotfile. A symlink_dir
+// without merge links the whole directory, so nothing in it can be left out.
+func validateIgnore(df Dotfile) error {
+	if len(df.Ignore) == 0 {
+		return nil
+	}
+	if df.Action != "template_dir" && !(df.Action == "symlink_dir" && df.Merge) {
+		return fmt.Errorf("ignore is only supported with action 'template_dir' or 'symlink_dir' with merge = true")
+	}
+	for _, pattern := range df.Ignore {
+		if _, err := path.Match(strings.TrimSuffix(pattern, "/"), ""); err != nil || strings.TrimSuffix(pattern, "/") == "" {
+			return fmt.Errorf("invalid ignore pattern '%s'", pattern)
+		}
+	}
+	return nil
+}
+
+// validateFetched checks the fields of action = "download" and "extract".
+func validateFetched(df Dotfile) error {
+	fetched := df.Action == "download" || df.Action == "extract"
+	for _, field := range [][2]string{{"url", df.URL}, {"checksum", df.Checksum}} {
+		if field[1] != "" && !fetched {
+			return fmt.Errorf("%s is only supported with action 'download' or 'extract'", field[0])
+		}
+	}
+	if df.Mode != "" && df.Action != "download" {
+		return fmt.Errorf("mode is only supported with action 'download'")
+	}
+	if !fetched {
+		return nil
+	}
+	if df.IsTemplate {
+		return fmt.Errorf("is_template is not supported with action '%s'", df.Action)
+	}
+	if df.Action == "extract" {
+		if (df.URL == "") == (df.Source == "") {
+			return fmt.Errorf("action 'extract' needs either a source archive in the repo or a url")
+		}
+		if ArchiveFormat(df.ArchiveName()) == "" {
+			return fmt.Errorf("action 'extract' supports .zip, .tar, .tar.gz, .tgz, .tar.bz2 and .tbz2 archives, got '%s'", df.ArchiveName())
+		}
+	}
+	if df.URL != "" || df.Action == "download" {
+		if !strings.HasPrefix(df.URL, "https://") && !strings.HasPrefix(df.URL, "http://") {
+			return fmt.Errorf("action '%s' needs an http(s) url, got '%s'", df.Action, df.URL)
+		}
+	}
+	if _, err := df.SHA256(); err != nil {
+		return err
+	}
+	_, err := df.FileMode()
+	return err
+}
+
 // ShortenHome replaces the user's home directory prefix with ~ for display.
 func ShortenHome(path string) string {
 	home, err := os.UserHomeDir()
@@ -242,7 +535,9 @@ func ShortenHome(path string) string {
 	return path
 }
 
-// ExpandPath expands ~ and environment variables in a path.
+// ExpandPath expands ~ and environment variables in a path. Besides $VAR and
+// ${VAR}, Windows-style %VAR% tokens (e.g. %APPDATA%, %LOCALAPPDATA%) are
+// expanded when the variable is set; unknown tokens are left as they are.
 func ExpandPath(path string) (string, error) {
 	if strings.HasPrefix(path, "~") {
 		homeDir, err := os.UserHomeDir()
@@ -251,5 +546,95 @@ func ExpandPath(path string) (string, error) {
 		}
 		path = filepath.Join(homeDir, path[1:])
 	}
+	path = percentVarPattern.ReplaceAllStringFunc(path, func(token string) string {
+		if value, ok := os.LookupEnv(token[1 : len(token)-1]); ok {
+			return value
+		}
+		return token
+	})
 	return os.ExpandEnv(path), nil
 }
+
+// validateAliases checks that every alias names a single former item: not
+// an item that still exists, and not claimed by two items.
+func validateAliases(kind string, aliases map[string][]string) error {
+	names := make([]string, 0, len(aliases))
+	for name := range aliases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	owners := make(map[string]string)
+	for _, name := range names {
+		for _, alias := range aliases[name] {
+			if alias == "" {
+				return fmt.Errorf("%s '%s': aliases cannot contain an empty name", kind, name)
+			}
+			if _, exists := aliases[alias]; exists {
+				return fmt.Errorf("%s '%s': alias '%s' is the name of a configured %s", kind, name, alias, kind)
+			}
+			if owner, taken := owners[alias]; taken {
+				return fmt.Errorf("%s '%s': alias '%s' is also an alias of '%s'", kind, name, alias, owner)
+			}
+			owners[alias] = name
+		}
+	}
+	return nil
+}
+
+// envNamePattern matches a valid environment variable name.
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// percentVarPattern matches %VAR% environment variable tokens.
+var percentVarPattern = regexp.MustCompile(`%[A-Za-z_][A-Za-z0-9_]*%`)
+
+// caseInsensitivePaths is set on platforms whose file systems compare paths
+// case-insensitively.
+var caseInsensitivePaths = runtime.GOOS == "windows"
+
+// SamePath reports whether two paths refer to the same location, comparing
+// cleaned paths and ignoring case on Windows.
+func SamePath(a, b string) bool {
+	a, b = filepath.Clean(a), filepath.Clean(b)
+	if caseInsensitivePaths {
+		return strings.EqualFold(a, b)
+	}
+	return a == b
+}
+
+// PathHasSuffix reports whether path ends with the relative path suffix at a
+// path element boundary, accepting either separator and ignoring case on
+// Windows.
+func PathHasSuffix(path, suffix string) bool {
+	path, suffix = filepath.ToSlash(path), "/"+strings.TrimPrefix(filepath.ToSlash(suffix), "/")
+	if caseInsensitivePaths {
+		path, suffix = strings.ToLower(path), strings.ToLower(suffix)
+	}
+	return strings.HasSuffix(path, suffix)
+}
+
+// validateMacOSDefaultValue checks that a macOS default's value matches its type.
+func validateMacOSDefaultValue(def MacOSDefault) error {
+	valueType := def.ValueType()
+	ok := false
+	switch valueType {
+	case "bool":
+		_, ok = def.Value.(bool)
+	case "int":
+		_, ok = def.Value.(int64)
+	case "float":
+		switch def.Value.(type) {
+		case float64, int64:
+			ok = true
+		}
+	case "string":
+		_, ok = def.Value.(string)
+	case "":
+		return fmt.Errorf("value must be a string, integer, float or boolean")
+	default:
+		return fmt.Errorf("type must be 'string', 'int', 'float' or 'bool', got '%s'", valueType)
+	}
+	if !ok {
+		return fmt.Errorf("value %v does not match type '%s'", def.Value, valueType)
+	}
+	return nil
+}
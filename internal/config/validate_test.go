@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -51,6 +52,17 @@ func TestExpandPath(t *testing.T) {
 			input: "~",
 			want:  homeDir,
 		},
+		{
+			name:     "percent variable",
+			input:    "%TEST_APPDATA%/Code/User",
+			want:     "/appdata/Code/User",
+			setupEnv: map[string]string{"TEST_APPDATA": "/appdata"},
+		},
+		{
+			name:  "unset percent variable is kept",
+			input: "%TEST_UNSET_PERCENT_VAR%/x",
+			want:  "%TEST_UNSET_PERCENT_VAR%/x",
+		},
 	}
 
 	for _, tt := range tests {
@@ -86,6 +98,46 @@ func TestExpandPath(t *testing.T) {
 	}
 }
 
+func TestSamePath(t *testing.T) {
+	if !SamePath("/a/b/../c/", "/a/c") {
+		t.Error("SamePath should compare cleaned paths")
+	}
+	if SamePath("/a/c", "/a/d") {
+		t.Error("SamePath(/a/c, /a/d) = true, want false")
+	}
+
+	orig := caseInsensitivePaths
+	defer func() { caseInsensitivePaths = orig }()
+	caseInsensitivePaths = false
+	if SamePath("/Users/Me/.vimrc", "/users/me/.vimrc") {
+		t.Error("SamePath should be case-sensitive when the platform is")
+	}
+	caseInsensitivePaths = true
+	if !SamePath("/Users/Me/.vimrc", "/users/me/.vimrc") {
+		t.Error("SamePath should ignore case when the platform does")
+	}
+}
+
+func TestPathHasSuffix(t *testing.T) {
+	orig := caseInsensitivePaths
+	defer func() { caseInsensitivePaths = orig }()
+	caseInsensitivePaths = false
+
+	if !PathHasSuffix("/repo/nvim/init.lua", "nvim/init.lua") {
+		t.Error("expected suffix match")
+	}
+	if PathHasSuffix("/repo/xnvim/init.lua", "nvim/init.lua") {
+		t.Error("suffix must match at a path element boundary")
+	}
+	if PathHasSuffix("/repo/NVIM/init.lua", "nvim/init.lua") {
+		t.Error("expected case-sensitive mismatch")
+	}
+	caseInsensitivePaths = true
+	if !PathHasSuffix("/repo/NVIM/init.lua", "nvim/init.lua") {
+		t.Error("expected case-insensitive match")
+	}
+}
+
 func TestValidateConfig_Valid(t *testing.T) {
 	cfg := &Config{
 		DotfilesRepoPath: "~/.dotfiles",
@@ -175,3 +227,376 @@ func TestValidateConfig_ShellFunctionMissingBody(t *testing.T) {
 		t.Logf("Got expected error: %v", err)
 	}
 }
+
+func TestValidateConfig_HelpFunction(t *testing.T) {
+	tests := []struct {
+		name    string
+		help    string
+		wantErr bool
+	}{
+		{"unset", "", false},
+		{"valid", "alias-help", false},
+		{"invalid name", "alias help", true},
+		{"clashes with alias", "ll", true},
+		{"clashes with function", "mkcd", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				DotfilesRepoPath: "~/.dotfiles",
+				Shell: ShellConfig{
+					Aliases:      map[string]ShellAlias{"ll": {Command: "ls -l"}},
+					Functions:    map[string]ShellFunction{"mkcd": {Body: "mkdir -p \"$1\""}},
+					HelpFunction: tt.help,
+				},
+			}
+			err := ValidateConfig(cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateConfig_TemplateDelims(t *testing.T) {
+	tests := []struct {
+		name    string
+		delims  []string
+		wantErr bool
+	}{
+		{name: "unset", delims: nil, wantErr: false},
+		{name: "valid pair", delims: []string{"[[", "]]"}, wantErr: false},
+		{name: "single delimiter", delims: []string{"[["}, wantErr: true},
+		{name: "empty delimiter", delims: []string{"[[", ""}, wantErr: true},
+		{name: "too many", delims: []string{"[[", "]]", "!!"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				DotfilesRepoPath: "~/.dotfiles",
+				Dotfiles: map[string]Dotfile{
+					"zshtheme": {Source: "theme.zsh", Target: "~/.theme.zsh", IsTemplate: true, TemplateDelims: tt.delims},
+				},
+			}
+			err := ValidateConfig(cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateConfig_MergeRequiresSymlinkDir(t *testing.T) {
+	cfg := &Config{
+		DotfilesRepoPath: "~/.dotfiles",
+		Dotfiles:         map[string]Dotfile{"fish": {Source: "fish", Target: "~/.config/fish", Merge: true}},
+	}
+	if err := ValidateConfig(cfg); err == nil {
+		t.Error("ValidateConfig() with merge on a plain symlink did not return an error")
+	}
+
+	cfg.Dotfiles["fish"] = Dotfile{Source: "fish", Target: "~/.config/fish", Action: "symlink_dir", Merge: true}
+	if err := ValidateConfig(cfg); err != nil {
+		t.Errorf("ValidateConfig() with merge on symlink_dir returned error: %v", err)
+	}
+}
+
+func TestValidateConfig_ShellNames(t *testing.T) {
+	tests := []struct {
+		names   []string
+		wantErr bool
+	}{
+		{[]string{"zsh", "fish"}, false},
+		{[]string{"zsh", "nu"}, true},
+		{[]string{"zsh", "zsh"}, true},
+	}
+	for _, tt := range tests {
+		cfg := &Config{DotfilesRepoPath: "~/.dotfiles", Shell: ShellConfig{Names: tt.names}}
+		if err := ValidateConfig(cfg); (err != nil) != tt.wantErr {
+			t.Errorf("ValidateConfig() with shell.names %q error = %v, wantErr %v", tt.names, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidateConfig_Ignore(t *testing.T) {
+	tests := []struct {
+		name    string
+		dotfile Dotfile
+		wantErr bool
+	}{
+		{"merged symlink_dir", Dotfile{Action: "symlink_dir", Merge: true, Ignore: []string{"*.pyc", "spell/"}}, false},
+		{"template_dir", Dotfile{Action: "template_dir", Ignore: []string{"cache/*"}}, false},
+		{"whole symlink_dir", Dotfile{Action: "symlink_dir", Ignore: []string{"*.pyc"}}, true},
+		{"symlink", Dotfile{Ignore: []string{"*.pyc"}}, true},
+		{"bad pattern", Dotfile{Action: "template_dir", Ignore: []string{"[a-"}}, true},
+		{"empty pattern", Dotfile{Action: "template_dir", Ignore: []string{"/"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.dotfile.Source, tt.dotfile.Target = "nvim", "~/.config/nvim"
+			cfg := &Config{DotfilesRepoPath: "~/.dotfiles", Dotfiles: map[string]Dotfile{"nvim": tt.dotfile}}
+			err := ValidateConfig(cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestIgnoredPath(t *testing.T) {
+	patterns := []string{"*.pyc", "spell/", "lua/cache/*"}
+	tests := []struct {
+		rel   string
+		isDir bool
+		want  bool
+	}{
+		{"init.pyc", false, true},
+		{"lua/plugins/x.pyc", false, true},
+		{"spell", true, true},
+		{"spell", false, false},
+		{"lua/spell", true, true},
+		{"lua/cache/a.lua", false, true},
+		{"cache/a.lua", false, false},
+		{"init.lua", false, false},
+	}
+	for _, tt := range tests {
+		if got := IgnoredPath(patterns, tt.rel, tt.isDir); got != tt.want {
+			t.Errorf("IgnoredPath(%q, dir=%v) = %v, want %v", tt.rel, tt.isDir, got, tt.want)
+		}
+	}
+}
+
+func TestValidateConfig_Git(t *testing.T) {
+	tests := []struct {
+		name    string
+		git     GitConfig
+		wantErr bool
+	}{
+		{"unset", GitConfig{}, false},
+		{"commit and push", GitConfig{AutoCommit: true, AutoPush: true, MessageTemplate: "sync {{ .Host }}"}, false},
+		{"push without commit", GitConfig{AutoPush: true}, true},
+		{"invalid template", GitConfig{AutoCommit: true, MessageTemplate: "{{ .Host"}, true},
+		{"prefer ssh", GitConfig{Prefer: "ssh"}, false},
+		{"prefer unknown", GitConfig{Prefer: "git"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{DotfilesRepoPath: "~/.dotfiles", Git: tt.git}
+			err := ValidateConfig(cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateConfig_Backup(t *testing.T) {
+	tests := []struct {
+		name    string
+		backup  BackupConfig
+		wantErr bool
+	}{
+		{"unset", BackupConfig{}, false},
+		{"dir, suffix and keep", BackupConfig{Dir: "~/.backups", Suffix: ".orig", Keep: 5}, false},
+		{"negative keep", BackupConfig{Keep: -1}, true},
+		{"suffix with separator", BackupConfig{Suffix: "/bak"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{DotfilesRepoPath: "~/.dotfiles", Backup: tt.backup}
+			err := ValidateConfig(cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateMergedConfig_MacOSDefaults(t *testing.T) {
+	tests := []struct {
+		name    string
+		def     MacOSDefault
+		wantErr bool
+	}{
+		{"inferred bool", MacOSDefault{Domain: "com.apple.dock", Key: "autohide", Value: true}, false},
+		{"float from integer", MacOSDefault{Domain: "com.apple.dock", Key: "autohide-delay", Type: "float", Value: int64(0)}, false},
+		{"missing domain", MacOSDefault{Key: "autohide", Value: true}, true},
+		{"missing value", MacOSDefault{Domain: "com.apple.dock", Key: "autohide"}, true},
+		{"type mismatch", MacOSDefault{Domain: "com.apple.dock", Key: "tilesize", Type: "int", Value: "36"}, true},
+		{"unknown type", MacOSDefault{Domain: "com.apple.dock", Key: "tilesize", Type: "dict", Value: "36"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{MacOS: MacOSConfig{Defaults: map[string]MacOSDefault{"d": tt.def}}}
+			err := ValidateMergedConfig(cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateMergedConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateMergedConfig_VSCodeExtensions(t *testing.T) {
+	tests := []struct {
+		name    string
+		ids     []string
+		wantErr bool
+	}{
+		{"valid", []string{"golang.go", "ms-python.python"}, false},
+		{"missing publisher", []string{".go"}, true},
+		{"no dot", []string{"golang"}, true},
+		{"path", []string{"./ext/my.vsix"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{VSCode: VSCodeConfig{Extensions: tt.ids}}
+			err := ValidateMergedConfig(cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateMergedConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateMergedConfig_Services(t *testing.T) {
+	tests := []struct {
+		name    string
+		svc     Service
+		wantErr bool
+	}{
+		{"unit only", Service{Unit: "syncthing.service"}, false},
+		{"with dotfile", Service{Unit: "backup.timer", Dotfile: "backup-timer"}, false},
+		{"missing unit", Service{}, true},
+		{"unit path", Service{Unit: "~/.config/systemd/user/x.service"}, true},
+		{"unknown dotfile", Service{Unit: "x.service", Dotfile: "nope"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{
+				Dotfiles: map[string]Dotfile{"backup-timer": {Source: "backup.timer", Target: "~/.config/systemd/user/backup.timer"}},
+				Services: map[string]Service{"s": tt.svc},
+			}
+			err := ValidateMergedConfig(cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateMergedConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateMergedConfig_Cron(t *testing.T) {
+	tests := []struct {
+		name    string
+		job     CronJob
+		wantErr bool
+	}{
+		{"five fields", CronJob{Schedule: "0 3 * * *", Command: "~/bin/backup"}, false},
+		{"macro", CronJob{Schedule: "@daily", Command: "brew update"}, false},
+		{"missing command", CronJob{Schedule: "@daily"}, true},
+		{"short schedule", CronJob{Schedule: "0 3 *", Command: "x"}, true},
+		{"multi-line command", CronJob{Schedule: "@daily", Command: "a\nb"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Cron: map[string]CronJob{"job": tt.job}}
+			err := ValidateMergedConfig(cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateMergedConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateMergedConfig_BuildRepo(t *testing.T) {
+	cfg := &Config{
+		Repos: map[string]Repo{"fzf": {URL: "https://github.com/junegunn/fzf", Target: "~/src/fzf"}},
+		Hooks: HooksConfig{Builds: map[string]Build{"fzf": {Repo: "fzf", Commands: []string{"./install --bin"}, Run: "once"}}},
+	}
+	if err := ValidateMergedConfig(cfg); err != nil {
+		t.Errorf("ValidateMergedConfig() with a defined repo returned error: %v", err)
+	}
+	cfg.Hooks.Builds["fzf"] = Build{Repo: "fzf-typo", Commands: []string{"./install --bin"}, Run: "once"}
+	if err := ValidateMergedConfig(cfg); err == nil || !strings.Contains(err.Error(), "fzf-typo") {
+		t.Errorf("ValidateMergedConfig() with an undefined repo error = %v, want it named", err)
+	}
+}
+
+func TestValidateMergedConfig_Download(t *testing.T) {
+	sum := "sha256:" + strings.Repeat("ab", 32)
+	tests := []struct {
+		name    string
+		df      Dotfile
+		wantErr bool
+	}{
+		{"url only", Dotfile{Target: "~/.local/share/theme.zsh", Action: "download", URL: "https://example.com/theme.zsh"}, false},
+		{"checksum and mode", Dotfile{Target: "~/bin/tool", Action: "download", URL: "https://example.com/tool", Checksum: sum, Mode: "0755"}, false},
+		{"bare hex checksum", Dotfile{Target: "~/bin/tool", Action: "download", URL: "https://example.com/tool", Checksum: strings.Repeat("AB", 32)}, false},
+		{"missing url", Dotfile{Target: "~/bin/tool", Action: "download"}, true},
+		{"not http", Dotfile{Target: "~/bin/tool", Action: "download", URL: "file:///etc/passwd"}, true},
+		{"short checksum", Dotfile{Target: "~/bin/tool", Action: "download", URL: "https://example.com/tool", Checksum: "sha256:abc"}, true},
+		{"bad mode", Dotfile{Target: "~/bin/tool", Action: "download", URL: "https://example.com/tool", Mode: "rwx"}, true},
+		{"template", Dotfile{Target: "~/bin/tool", Action: "download", URL: "https://example.com/tool", IsTemplate: true}, true},
+		{"url without download", Dotfile{Source: "tool", Target: "~/bin/tool", URL: "https://example.com/tool"}, true},
+		{"mode without download", Dotfile{Source: "tool", Target: "~/bin/tool", Action: "copy", Mode: "0755"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Dotfiles: map[string]Dotfile{"d": tt.df}}
+			err := ValidateMergedConfig(cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateMergedConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateMergedConfig_Extract(t *testing.T) {
+	tests := []struct {
+		name    string
+		df      Dotfile
+		wantErr bool
+	}{
+		{"repo archive", Dotfile{Source: "fonts.tar.gz", Target: "~/.local/share/fonts", Action: "extract"}, false},
+		{"url archive", Dotfile{Target: "~/.local/share/nvim/parsers", Action: "extract", URL: "https://example.com/parsers.zip?dl=1"}, false},
+		{"neither", Dotfile{Target: "~/x", Action: "extract"}, true},
+		{"both", Dotfile{Source: "a.zip", Target: "~/x", Action: "extract", URL: "https://example.com/a.zip"}, true},
+		{"unknown format", Dotfile{Source: "fonts.rar", Target: "~/x", Action: "extract"}, true},
+		{"mode", Dotfile{Source: "a.tgz", Target: "~/x", Action: "extract", Mode: "0755"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Dotfiles: map[string]Dotfile{"d": tt.df}}
+			err := ValidateMergedConfig(cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateMergedConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateMergedConfig_Aliases(t *testing.T) {
+	tests := []struct {
+		name     string
+		dotfiles map[string]Dotfile
+		wantErr  bool
+	}{
+		{"renamed", map[string]Dotfile{"nvim_init": {Source: "nvim", Target: "~/.config/nvim", Aliases: []string{"nvim"}}}, false},
+		{"empty alias", map[string]Dotfile{"nvim_init": {Source: "nvim", Target: "~/.config/nvim", Aliases: []string{""}}}, true},
+		{"alias still configured", map[string]Dotfile{
+			"nvim":      {Source: "nvim", Target: "~/.config/nvim"},
+			"nvim_init": {Source: "nvim", Target: "~/.config/nvim2", Aliases: []string{"nvim"}},
+		}, true},
+		{"alias claimed twice", map[string]Dotfile{
+			"a": {Source: "a", Target: "~/a", Aliases: []string{"old"}},
+			"b": {Source: "b", Target: "~/b", Aliases: []string{"old"}},
+		}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateMergedConfig(&Config{Dotfiles: tt.dotfiles})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateMergedConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+)
+
+// ZshPluginRepoPrefix prefixes the names of the repos url plugins are cloned as.
+const ZshPluginRepoPrefix = "zsh-plugin-"
+
+// DefaultZshPluginsDir is where url plugins are cloned without plugins_dir.
+const DefaultZshPluginsDir = "~/.local/share/ralph/zsh-plugins"
+
+// ApplyZshPlugins adds a repo named zsh-plugin-<name> for every
+// [shell.zsh.plugins] entry with a url, cloned into the plugins dir with the
+// plugin's branch, commit, update, hosts and enable. A plugin must have
+// exactly one of url and repo, and repo must name a configured repo.
+func ApplyZshPlugins(cfg *Config) error {
+	if len(cfg.Shell.Zsh.Plugins) == 0 {
+		return nil
+	}
+	dir := cfg.Shell.Zsh.PluginsDir
+	if dir == "" {
+		dir = DefaultZshPluginsDir
+	}
+	for _, name := range ZshPluginNames(cfg) {
+		plugin := cfg.Shell.Zsh.Plugins[name]
+		switch {
+		case plugin.URL != "" && plugin.Repo != "":
+			return fmt.Errorf("plugin '%s': url and repo are mutually exclusive", name)
+		case plugin.URL == "" && plugin.Repo == "":
+			return fmt.Errorf("plugin '%s': needs a url or a repo", name)
+		case plugin.Repo != "":
+			if _, ok := cfg.Repos[plugin.Repo]; !ok {
+				return fmt.Errorf("plugin '%s': repo '%s' is not defined", name, plugin.Repo)
+			}
+			continue
+		}
+
+		repoName := ZshPluginRepoPrefix + name
+		if _, exists := cfg.Repos[repoName]; exists {
+			return fmt.Errorf("plugin '%s': a repo named '%s' is already defined", name, repoName)
+		}
+		if cfg.Repos == nil {
+			cfg.Repos = make(map[string]Repo)
+		}
+		cfg.Repos[repoName] = Repo{
+			URL:    plugin.URL,
+			Target: filepath.Join(dir, name),
+			Branch: plugin.Branch,
+			Commit: plugin.Commit,
+			Update: plugin.Update,
+			Hosts:  plugin.Hosts,
+			Enable: plugin.Enable,
+		}
+	}
+	return nil
+}
+
+// ZshPluginNames returns the names of the zsh plugins in load order: by
+// order, then by name.
+func ZshPluginNames(cfg *Config) []string {
+	plugins := cfg.Shell.Zsh.Plugins
+	names := make([]string, 0, len(plugins))
+	for name := range plugins {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if plugins[names[i]].Order != plugins[names[j]].Order {
+			return plugins[names[i]].Order < plugins[names[j]].Order
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
+
+// ZshPluginRepo returns the repo a zsh plugin is checked out by: the
+// referenced repo or the one ApplyZshPlugins added for its url.
+func ZshPluginRepo(cfg *Config, name string) (string, Repo, bool) {
+	plugin, ok := cfg.Shell.Zsh.Plugins[name]
+	if !ok {
+		return "", Repo{}, false
+	}
+	repoName := plugin.Repo
+	if repoName == "" {
+		repoName = ZshPluginRepoPrefix + name
+	}
+	rp, ok := cfg.Repos[repoName]
+	return repoName, rp, ok
+}
@@ -0,0 +1,72 @@
+package config
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestApplyZshPlugins(t *testing.T) {
+	cfg := &Config{
+		Repos: map[string]Repo{"p10k": {URL: "https://github.com/romkatv/powerlevel10k", Target: "~/src/p10k"}},
+		Shell: ShellConfig{Zsh: ZshConfig{Plugins: map[string]ZshPlugin{
+			"zsh-autosuggestions":     {URL: "https://github.com/zsh-users/zsh-autosuggestions", Branch: "master", Hosts: []string{"laptop"}},
+			"zsh-syntax-highlighting": {URL: "https://github.com/zsh-users/zsh-syntax-highlighting", Order: 100},
+			"powerlevel10k":           {Repo: "p10k", Order: -1},
+		}}},
+	}
+
+	if err := ApplyZshPlugins(cfg); err != nil {
+		t.Fatalf("ApplyZshPlugins failed: %v", err)
+	}
+	rp, ok := cfg.Repos["zsh-plugin-zsh-autosuggestions"]
+	if !ok {
+		t.Fatalf("no repo added for url plugin: %v", cfg.Repos)
+	}
+	want := Repo{
+		URL:    "https://github.com/zsh-users/zsh-autosuggestions",
+		Target: filepath.Join(DefaultZshPluginsDir, "zsh-autosuggestions"),
+		Branch: "master",
+		Hosts:  []string{"laptop"},
+	}
+	if !reflect.DeepEqual(rp, want) {
+		t.Errorf("repo = %+v, want %+v", rp, want)
+	}
+	if len(cfg.Repos) != 3 {
+		t.Errorf("repos = %v, want p10k and the two url plugins", cfg.Repos)
+	}
+
+	order := ZshPluginNames(cfg)
+	if !reflect.DeepEqual(order, []string{"powerlevel10k", "zsh-autosuggestions", "zsh-syntax-highlighting"}) {
+		t.Errorf("load order = %v", order)
+	}
+	if name, rp, ok := ZshPluginRepo(cfg, "powerlevel10k"); !ok || name != "p10k" || rp.Target != "~/src/p10k" {
+		t.Errorf("ZshPluginRepo(powerlevel10k) = %q, %+v, %v", name, rp, ok)
+	}
+}
+
+func TestApplyZshPlugins_Errors(t *testing.T) {
+	tests := []struct {
+		plugin ZshPlugin
+		want   string
+	}{
+		{ZshPlugin{}, "needs a url or a repo"},
+		{ZshPlugin{URL: "https://example.com/p", Repo: "p"}, "mutually exclusive"},
+		{ZshPlugin{Repo: "missing"}, "repo 'missing' is not defined"},
+	}
+	for _, tt := range tests {
+		cfg := &Config{Shell: ShellConfig{Zsh: ZshConfig{Plugins: map[string]ZshPlugin{"p": tt.plugin}}}}
+		if err := ApplyZshPlugins(cfg); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("ApplyZshPlugins(%+v) error = %v, want %q", tt.plugin, err, tt.want)
+		}
+	}
+
+	cfg := &Config{
+		Repos: map[string]Repo{"zsh-plugin-p": {URL: "https://example.com/other", Target: "~/other"}},
+		Shell: ShellConfig{Zsh: ZshConfig{Plugins: map[string]ZshPlugin{"p": {URL: "https://example.com/p"}}}},
+	}
+	if err := ApplyZshPlugins(cfg); err == nil || !strings.Contains(err.Error(), "already defined") {
+		t.Errorf("error = %v, want a repo name clash", err)
+	}
+}
@@ -0,0 +1,155 @@
+package cron
+
+import (
+	"fmt"
+	"io"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/mad01/ralph/internal/block"
+)
+
+// Markers around the entries ralph manages in the user crontab. Lines outside
+// them are never touched.
+const (
+	BlockBeginMarker = "# BEGIN RALPH MANAGED BLOCK"
+	BlockEndMarker   = "# END RALPH MANAGED BLOCK"
+)
+
+// Job is a crontab entry.
+type Job struct {
+	Name     string
+	Schedule string
+	Command  string
+	Comment  string
+}
+
+// readCrontab returns the user crontab, or "" if there is none. It is a
+// variable so tests can replace it.
+var readCrontab = func() (string, error) {
+	out, err := exec.Command("crontab", "-l").CombinedOutput()
+	if err != nil {
+		if strings.Contains(string(out), "no crontab") {
+			return "", nil
+		}
+		return "", fmt.Errorf("crontab -l failed: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return string(out), nil
+}
+
+// writeCrontab installs content as the user crontab. It is a variable so
+// tests can replace it.
+var writeCrontab = func(content string) error {
+	cmd := exec.Command("crontab", "-")
+	cmd.Stdin = strings.NewReader(content)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("crontab - failed: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// Available reports whether the crontab command is on PATH.
+func Available() bool {
+	_, err := exec.LookPath("crontab")
+	return err == nil
+}
+
+// markers delimit the managed block.
+var markers = block.Markers{Begin: BlockBeginMarker, End: BlockEndMarker}
+
+// blockBody returns the entries of the managed block for jobs, sorted by
+// name. Each entry is preceded by a "# name: comment" line so it can be
+// traced back to the config.
+func blockBody(jobs []Job) []string {
+	sorted := append([]Job(nil), jobs...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	var lines []string
+	for _, job := range sorted {
+		marker := "# " + job.Name
+		if job.Comment != "" {
+			marker += ": " + job.Comment
+		}
+		lines = append(lines, marker, job.Schedule+" "+job.Command)
+	}
+	return lines
+}
+
+// merge replaces the managed block in crontab with one holding jobs, appending
+// it if there is none. With no jobs the block is removed, along with the blank
+// line before it. It also returns the lines of the old block.
+func merge(crontab string, jobs []Job) (string, []string) {
+	old := block.Find(crontab, markers)
+	if len(jobs) == 0 {
+		updated, _ := block.Remove(crontab, markers)
+		return updated, old
+	}
+	updated, _ := block.Upsert(crontab, markers, blockBody(jobs))
+	return updated, old
+}
+
+// Sync makes the managed block of the user crontab hold exactly jobs, so jobs
+// removed from the config are dropped. With no jobs the block is removed. It
+// returns whether the crontab changed (or would change).
+// If dryRun is true, it will only print the lines it would add and remove.
+func Sync(w io.Writer, jobs []Job, dryRun bool) (bool, error) {
+	current, err := readCrontab()
+	if err != nil {
+		return false, err
+	}
+	updated, old := merge(current, jobs)
+	if updated == current {
+		fmt.Fprintf(w, "  %s\n", color.GreenString("crontab unchanged"))
+		return false, nil
+	}
+
+	if dryRun {
+		fmt.Fprintf(w, "  %s would update the crontab:\n", color.CyanString("[dry run]"))
+		var added []string
+		if len(jobs) > 0 {
+			added = markers.Lines(blockBody(jobs))
+		}
+		printLineDiff(w, old, added)
+		return true, nil
+	}
+	if err := writeCrontab(updated); err != nil {
+		return false, err
+	}
+	fmt.Fprintf(w, "  %s\n", color.GreenString("crontab updated"))
+	return true, nil
+}
+
+// printLineDiff prints the lines only in old with "-" and those only in
+// updated with "+".
+func printLineDiff(w io.Writer, old, updated []string) {
+	inOld := make(map[string]bool, len(old))
+	for _, line := range old {
+		inOld[line] = true
+	}
+	inUpdated := make(map[string]bool, len(updated))
+	for _, line := range updated {
+		inUpdated[line] = true
+	}
+	for _, line := range old {
+		if !inUpdated[line] {
+			fmt.Fprintf(w, "    %s\n", color.RedString("- %s", line))
+		}
+	}
+	for _, line := range updated {
+		if !inOld[line] {
+			fmt.Fprintf(w, "    %s\n", color.GreenString("+ %s", line))
+		}
+	}
+}
+
+// HasBlock reports whether the user crontab has a managed block.
+func HasBlock() (bool, error) {
+	current, err := readCrontab()
+	if err != nil {
+		return false, err
+	}
+	_, old := merge(current, nil)
+	return len(old) > 0, nil
+}
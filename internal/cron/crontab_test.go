@@ -0,0 +1,84 @@
+package cron
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+// fakeCrontab replaces readCrontab and writeCrontab with an in-memory crontab.
+func fakeCrontab(t *testing.T, initial string) *string {
+	t.Helper()
+	content := initial
+	origRead, origWrite := readCrontab, writeCrontab
+	readCrontab = func() (string, error) { return content, nil }
+	writeCrontab = func(s string) error { content = s; return nil }
+	t.Cleanup(func() { readCrontab, writeCrontab = origRead, origWrite })
+	return &content
+}
+
+func TestSync(t *testing.T) {
+	content := fakeCrontab(t, "MAILTO=me@example.com\n0 * * * * /usr/bin/mine\n")
+	jobs := []Job{
+		{Name: "backup", Schedule: "0 3 * * *", Command: "~/bin/backup", Comment: "nightly"},
+		{Name: "brew", Schedule: "@weekly", Command: "brew update"},
+	}
+
+	var out bytes.Buffer
+	if changed, err := Sync(&out, jobs, true); err != nil || !changed {
+		t.Fatalf("dry run = (%v, %v), want (true, nil)", changed, err)
+	}
+	if strings.Contains(*content, BlockBeginMarker) {
+		t.Error("dry run should not write the crontab")
+	}
+	if !strings.Contains(out.String(), "+ 0 3 * * * ~/bin/backup") {
+		t.Errorf("dry run output = %q, want the added entry", out.String())
+	}
+
+	if changed, err := Sync(io.Discard, jobs, false); err != nil || !changed {
+		t.Fatalf("sync = (%v, %v), want (true, nil)", changed, err)
+	}
+	want := "MAILTO=me@example.com\n0 * * * * /usr/bin/mine\n\n" +
+		BlockBeginMarker + "\n# backup: nightly\n0 3 * * * ~/bin/backup\n# brew\n@weekly brew update\n" + BlockEndMarker + "\n"
+	if *content != want {
+		t.Errorf("crontab =\n%s\nwant\n%s", *content, want)
+	}
+
+	if changed, err := Sync(io.Discard, jobs, false); err != nil || changed {
+		t.Errorf("second sync = (%v, %v), want (false, nil)", changed, err)
+	}
+
+	// Dropping a job rewrites the block in place.
+	*content += "# after\n"
+	if _, err := Sync(io.Discard, jobs[1:], false); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(*content, "backup") || !strings.HasSuffix(*content, BlockEndMarker+"\n# after\n") {
+		t.Errorf("crontab after pruning =\n%s", *content)
+	}
+
+	// No jobs removes the block and the blank line before it.
+	if changed, err := Sync(io.Discard, nil, false); err != nil || !changed {
+		t.Fatalf("removing block = (%v, %v), want (true, nil)", changed, err)
+	}
+	if want := "MAILTO=me@example.com\n0 * * * * /usr/bin/mine\n# after\n"; *content != want {
+		t.Errorf("crontab after removal = %q, want %q", *content, want)
+	}
+	if found, err := HasBlock(); err != nil || found {
+		t.Errorf("HasBlock = (%v, %v), want (false, nil)", found, err)
+	}
+}
+
+func TestSync_EmptyCrontab(t *testing.T) {
+	content := fakeCrontab(t, "")
+	if changed, err := Sync(io.Discard, nil, false); err != nil || changed {
+		t.Errorf("no jobs on empty crontab = (%v, %v), want (false, nil)", changed, err)
+	}
+	if _, err := Sync(io.Discard, []Job{{Name: "x", Schedule: "@daily", Command: "true"}}, false); err != nil {
+		t.Fatal(err)
+	}
+	if want := BlockBeginMarker + "\n# x\n@daily true\n" + BlockEndMarker + "\n"; *content != want {
+		t.Errorf("crontab = %q, want %q", *content, want)
+	}
+}
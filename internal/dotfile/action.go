@@ -0,0 +1,148 @@
+package dotfile
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/mad01/ralph/internal/config"
+)
+
+// Action deploys dotfiles for one value of the action field. New actions are
+// added with RegisterAction instead of editing apply.
+type Action interface {
+	// Plan reports whether df's target already matches what Apply would
+	// deploy, without changing anything.
+	Plan(df config.Dotfile, cfg *config.Config) (bool, error)
+	// Apply deploys the dotfile. With req.DryRun set it only prints what it
+	// would do.
+	Apply(w io.Writer, req ApplyRequest) error
+	// Verify returns how the files deployed for entry drifted since apply
+	// recorded it in the manifest.
+	Verify(entry ManifestEntry) ([]Drift, error)
+}
+
+// ApplyRequest is a dotfile for Action.Apply to deploy.
+type ApplyRequest struct {
+	Name         string
+	Dotfile      config.Dotfile // Source is the rendered file for single-file templates
+	RepoPath     string         // Directory Dotfile.Source is relative to; empty when Source is absolute
+	Config       *config.Config
+	TemplateData map[string]interface{}
+	Existing     SymlinkAction // What to do with a target that is in the way
+	DryRun       bool
+}
+
+// DefaultAction is used for dotfiles without an action.
+const DefaultAction = "symlink"
+
+var actions = make(map[string]Action)
+
+// RegisterAction makes a an action for dotfiles with action = name, replacing
+// any action registered under that name before. It must be called before the
+// config is loaded, so validation accepts name.
+func RegisterAction(name string, a Action) {
+	actions[name] = a
+	config.RegisterDotfileAction(name)
+}
+
+// LookupAction returns the action registered for name ("" is DefaultAction).
+func LookupAction(name string) (Action, error) {
+	if name == "" {
+		name = DefaultAction
+	}
+	a, ok := actions[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown action '%s'", name)
+	}
+	return a, nil
+}
+
+// Actions returns the names of the registered actions, sorted.
+func Actions() []string {
+	names := make([]string, 0, len(actions))
+	for name := range actions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// InSync reports whether a dotfile's target already matches what apply would
+// deploy, without changing anything. See Action.Plan.
+func InSync(df config.Dotfile, cfg *config.Config) (bool, error) {
+	a, err := LookupAction(df.Action)
+	if err != nil {
+		return false, err
+	}
+	return a.Plan(df, cfg)
+}
+
+// VerifyEntry returns the deployed files of entry that drifted since apply,
+// sorted by path. See Action.Verify.
+func VerifyEntry(entry ManifestEntry) ([]Drift, error) {
+	a, err := LookupAction(entry.Action)
+	if err != nil {
+		return nil, err
+	}
+	return a.Verify(entry)
+}
+
+// templateRenderer is implemented by actions that render is_template sources
+// themselves, so apply hands them the dotfile as configured instead of a
+// rendered copy of its source.
+type templateRenderer interface {
+	RendersTemplates() bool
+}
+
+// RendersTemplates reports whether the action registered for name renders
+// templates itself.
+func RendersTemplates(name string) bool {
+	a, err := LookupAction(name)
+	if err != nil {
+		return false
+	}
+	r, ok := a.(templateRenderer)
+	return ok && r.RendersTemplates()
+}
+
+// builtinAction is one of the actions that link, copy or render files from
+// the dotfiles repo. They share planning and verification.
+type builtinAction func(w io.Writer, req ApplyRequest) error
+
+func (a builtinAction) Plan(df config.Dotfile, cfg *config.Config) (bool, error) {
+	return filesInSync(df, cfg)
+}
+
+func (a builtinAction) Apply(w io.Writer, req ApplyRequest) error {
+	return a(w, req)
+}
+
+func (a builtinAction) Verify(entry ManifestEntry) ([]Drift, error) {
+	return verifyFiles(entry)
+}
+
+// renderingAction is a builtinAction that renders templates itself.
+type renderingAction struct {
+	builtinAction
+}
+
+func (renderingAction) RendersTemplates() bool { return true }
+
+func init() {
+	RegisterAction("symlink", builtinAction(func(w io.Writer, req ApplyRequest) error {
+		return CreateSymlink(w, req.Dotfile, req.RepoPath, req.Existing, req.DryRun)
+	}))
+	RegisterAction("copy", builtinAction(func(w io.Writer, req ApplyRequest) error {
+		return CopyFile(w, req.Dotfile, req.RepoPath, req.Existing, req.DryRun)
+	}))
+	RegisterAction("symlink_dir", builtinAction(func(w io.Writer, req ApplyRequest) error {
+		if req.Dotfile.Merge {
+			return MergeDirSymlinks(w, req.Dotfile, req.RepoPath, req.Existing, req.DryRun)
+		}
+		return CreateDirSymlink(w, req.Dotfile, req.RepoPath, req.Existing, req.DryRun)
+	}))
+	RegisterAction("template_dir", renderingAction{func(w io.Writer, req ApplyRequest) error {
+		return RenderTemplateDir(w, req.Dotfile, req.Config, req.TemplateData, req.Existing, req.DryRun)
+	}})
+}
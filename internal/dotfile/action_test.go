@@ -0,0 +1,75 @@
+package dotfile
+
+import (
+	"io"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/mad01/ralph/internal/config"
+)
+
+// recordingAction is an Action that records the requests it is given.
+type recordingAction struct {
+	applied []ApplyRequest
+}
+
+func (a *recordingAction) Plan(df config.Dotfile, cfg *config.Config) (bool, error) {
+	return len(a.applied) > 0, nil
+}
+
+func (a *recordingAction) Apply(w io.Writer, req ApplyRequest) error {
+	a.applied = append(a.applied, req)
+	return nil
+}
+
+func (a *recordingAction) Verify(entry ManifestEntry) ([]Drift, error) {
+	return []Drift{{Path: entry.Target, Problem: DriftModified}}, nil
+}
+
+func TestLookupAction_Builtins(t *testing.T) {
+	for _, name := range []string{"", "symlink", "copy", "symlink_dir", "template_dir"} {
+		if _, err := LookupAction(name); err != nil {
+			t.Errorf("LookupAction(%q) failed: %v", name, err)
+		}
+	}
+	if _, err := LookupAction("teleport"); err == nil {
+		t.Error("expected an error for an unregistered action")
+	}
+}
+
+func TestRegisterAction(t *testing.T) {
+	action := &recordingAction{}
+	RegisterAction("recording", action)
+	defer delete(actions, "recording")
+
+	cfg := &config.Config{
+		DotfilesRepoPath: t.TempDir(),
+		Dotfiles: map[string]config.Dotfile{
+			"theme": {Source: "theme", Target: filepath.Join(t.TempDir(), "theme"), Action: "recording"},
+		},
+	}
+	if err := config.ValidateMergedConfig(cfg); err != nil {
+		t.Fatalf("validation should accept a registered action: %v", err)
+	}
+
+	df := cfg.Dotfiles["theme"]
+	if ok, err := InSync(df, cfg); err != nil || ok {
+		t.Fatalf("InSync before apply = (%v, %v), want (false, nil)", ok, err)
+	}
+	found, _ := LookupAction("recording")
+	if err := found.Apply(io.Discard, ApplyRequest{Name: "theme", Dotfile: df, DryRun: true}); err != nil {
+		t.Fatal(err)
+	}
+	if len(action.applied) != 1 || action.applied[0].Name != "theme" || !action.applied[0].DryRun {
+		t.Errorf("applied = %+v, want the theme request", action.applied)
+	}
+	if ok, _ := InSync(df, cfg); !ok {
+		t.Error("InSync should use the registered action's Plan")
+	}
+
+	drifts, err := VerifyEntry(ManifestEntry{Action: "recording", Target: "/t"})
+	if err != nil || !reflect.DeepEqual(drifts, []Drift{{Path: "/t", Problem: DriftModified}}) {
+		t.Errorf("VerifyEntry = (%v, %v), want the registered action's drift", drifts, err)
+	}
+}
@@ -0,0 +1,211 @@
+package dotfile
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/mad01/ralph/internal/block"
+	"github.com/mad01/ralph/internal/config"
+)
+
+// appendBlockAction keeps the content of the source inside a marked block of
+// the target (action = "append_block"), for files such as ~/.bashrc that
+// other tools also write to. Lines outside the block are never touched.
+type appendBlockAction struct{}
+
+// blockMarkers returns the markers of the block for a dotfile with the given
+// source, relative to the dotfiles repo. Naming the block after its source
+// lets several dotfiles keep blocks in one target.
+func blockMarkers(source string) block.Markers {
+	return block.Named(filepath.ToSlash(source))
+}
+
+// blockBody returns the lines the block of df should hold: its source,
+// rendered when it is a template.
+func blockBody(df config.Dotfile, cfg *config.Config, dryRun bool) ([]string, error) {
+	source, err := config.ExpandPath(filepath.Join(cfg.DotfilesRepoPath, df.Source))
+	if err != nil {
+		return nil, fmt.Errorf("failed to expand source path '%s': %w", df.Source, err)
+	}
+	var content []byte
+	if df.IsTemplate {
+		content, err = ProcessTemplateWithOptions(source, cfg, nil, TemplateOptionsFor(df, dryRun))
+	} else {
+		content, err = os.ReadFile(source)
+		if err != nil {
+			err = fmt.Errorf("failed to read source '%s': %w", source, err)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	trimmed := strings.TrimRight(string(content), "\n")
+	if trimmed == "" {
+		return nil, nil
+	}
+	return strings.Split(trimmed, "\n"), nil
+}
+
+// readTarget returns the content of target, or "" if it doesn't exist.
+func readTarget(target string) (string, error) {
+	content, err := os.ReadFile(target)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read target '%s': %w", target, err)
+	}
+	return string(content), nil
+}
+
+// Plan reports whether the target has the block with the current content of
+// the source. Templates are rendered as in a dry run.
+func (appendBlockAction) Plan(df config.Dotfile, cfg *config.Config) (bool, error) {
+	target, err := config.ExpandPath(df.Target)
+	if err != nil {
+		return false, fmt.Errorf("failed to expand target path '%s': %w", df.Target, err)
+	}
+	want, err := blockBody(df, cfg, true)
+	if err != nil {
+		return false, err
+	}
+	content, err := readTarget(target)
+	if err != nil {
+		return false, err
+	}
+	_, changed := block.Upsert(content, blockMarkers(df.Source), want)
+	return !changed, nil
+}
+
+// Apply adds the block to the target, creating the file if needed, or
+// replaces the block that is already there. The target keeps its mode.
+func (appendBlockAction) Apply(w io.Writer, req ApplyRequest) error {
+	df := req.Dotfile
+	target, err := config.ExpandPath(df.Target)
+	if err != nil {
+		return fmt.Errorf("failed to expand target path '%s': %w", df.Target, err)
+	}
+	var cfg config.Config
+	if req.Config != nil {
+		cfg = *req.Config
+	}
+	cfg.DotfilesRepoPath = req.RepoPath
+	body, err := blockBody(df, &cfg, req.DryRun)
+	if err != nil {
+		return err
+	}
+	content, err := readTarget(target)
+	if err != nil {
+		return err
+	}
+	markers := blockMarkers(df.Source)
+	updated, changed := block.Upsert(content, markers, body)
+	if !changed {
+		fmt.Fprintf(w, "    %s\n", color.GreenString("unchanged"))
+		return nil
+	}
+
+	_, found := block.Body(content, markers)
+	if req.DryRun {
+		verb := "update"
+		if !found {
+			verb = "add"
+		}
+		fmt.Fprintf(w, "    %s would %s block in %s\n", color.CyanString("[dry run]"), verb, faint(config.ShortenHome(target)))
+		return nil
+	}
+
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(target); err == nil {
+		mode = info.Mode().Perm()
+	} else if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return fmt.Errorf("failed to create target directory '%s': %w", filepath.Dir(target), err)
+	}
+	if err := os.WriteFile(target, []byte(updated), mode); err != nil {
+		return fmt.Errorf("failed to write '%s': %w", target, err)
+	}
+	status := "block updated"
+	if !found {
+		status = "block added"
+	}
+	fmt.Fprintf(w, "    %s %s\n", color.GreenString(status), faint("→ "+config.ShortenHome(target)))
+	return nil
+}
+
+// Verify reports the block as missing when it was removed from the target,
+// and as modified when its lines changed since apply.
+func (appendBlockAction) Verify(entry ManifestEntry) ([]Drift, error) {
+	sum, found, err := blockChecksum(entry)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return []Drift{{Path: entry.Target, Problem: DriftMissing}}, nil
+	}
+	if want, ok := entry.Checksums["."]; ok && sum != want {
+		return []Drift{{Path: entry.Target, Problem: DriftModified}}, nil
+	}
+	return nil, nil
+}
+
+// blockChecksum returns the SHA-256 of the block of entry in its target,
+// markers included, and whether the target has the block.
+func blockChecksum(entry ManifestEntry) (string, bool, error) {
+	content, err := readTarget(entry.Target)
+	if err != nil {
+		return "", false, err
+	}
+	lines := block.Find(content, blockMarkers(entry.Block))
+	if len(lines) == 0 {
+		return "", false, nil
+	}
+	sum := sha256.Sum256([]byte(strings.Join(lines, "\n")))
+	return hex.EncodeToString(sum[:]), true, nil
+}
+
+// removeBlock removes the block of entry from its target. A target left
+// empty is removed too.
+func removeBlock(w io.Writer, entry ManifestEntry, dryRun bool) error {
+	content, err := readTarget(entry.Target)
+	if err != nil {
+		return err
+	}
+	updated, found := block.Remove(content, blockMarkers(entry.Block))
+	if !found {
+		return nil
+	}
+	if dryRun {
+		fmt.Fprintf(w, "    %s would remove block from %s\n", color.CyanString("[dry run]"), faint(config.ShortenHome(entry.Target)))
+		return nil
+	}
+	if strings.TrimSpace(updated) == "" {
+		if err := os.Remove(entry.Target); err != nil {
+			return fmt.Errorf("failed to remove '%s': %w", entry.Target, err)
+		}
+		fmt.Fprintf(w, "    %s %s\n", color.YellowString("removed"), faint(config.ShortenHome(entry.Target)))
+		return nil
+	}
+	info, err := os.Stat(entry.Target)
+	if err != nil {
+		return fmt.Errorf("failed to stat target '%s': %w", entry.Target, err)
+	}
+	if err := os.WriteFile(entry.Target, []byte(updated), info.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to write '%s': %w", entry.Target, err)
+	}
+	fmt.Fprintf(w, "    %s %s\n", color.YellowString("block removed"), faint("from "+config.ShortenHome(entry.Target)))
+	return nil
+}
+
+// RendersTemplates implements templateRenderer: the block is rendered from
+// the template source itself.
+func (appendBlockAction) RendersTemplates() bool { return true }
+
+func init() {
+	RegisterAction("append_block", appendBlockAction{})
+}
@@ -0,0 +1,125 @@
+package dotfile
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mad01/ralph/internal/config"
+)
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+func TestAppendBlockAction(t *testing.T) {
+	repo := t.TempDir()
+	target := filepath.Join(t.TempDir(), ".bashrc")
+	createDummyFile(t, filepath.Join(repo, "bash", "local.sh"), "alias ll='ls -l'\n")
+	createDummyFile(t, target, "# written by the installer\nexport PATH=/opt/bin:$PATH\n")
+	if err := os.Chmod(target, 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{DotfilesRepoPath: repo}
+	df := config.Dotfile{Source: "bash/local.sh", Target: target, Action: "append_block"}
+	action, _ := LookupAction("append_block")
+	req := ApplyRequest{Name: "bashrc", Dotfile: df, RepoPath: repo, Config: cfg}
+
+	if ok, err := InSync(df, cfg); err != nil || ok {
+		t.Fatalf("InSync before apply = (%v, %v), want (false, nil)", ok, err)
+	}
+	if err := action.Apply(io.Discard, req); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	want := "# written by the installer\nexport PATH=/opt/bin:$PATH\n\n" +
+		"# BEGIN RALPH MANAGED BLOCK: bash/local.sh\nalias ll='ls -l'\n# END RALPH MANAGED BLOCK: bash/local.sh\n"
+	if got := readFile(t, target); got != want {
+		t.Errorf("target =\n%s\nwant\n%s", got, want)
+	}
+	if info, _ := os.Stat(target); info.Mode().Perm() != 0600 {
+		t.Errorf("mode = %v, want 0600", info.Mode().Perm())
+	}
+	if ok, err := InSync(df, cfg); err != nil || !ok {
+		t.Errorf("InSync after apply = (%v, %v), want (true, nil)", ok, err)
+	}
+
+	entry, err := NewManifestEntry(df, repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.Checksums, err = DeployedChecksums(entry); err != nil {
+		t.Fatal(err)
+	}
+	// Edits outside the block are not drift.
+	createDummyFile(t, target, "# edited\n"+readFile(t, target))
+	if drifts, err := VerifyEntry(entry); err != nil || len(drifts) != 0 {
+		t.Errorf("VerifyEntry after an edit outside the block = (%v, %v)", drifts, err)
+	}
+
+	// A changed source replaces the block in place.
+	createDummyFile(t, filepath.Join(repo, "bash", "local.sh"), "alias la='ls -a'\n")
+	if err := action.Apply(io.Discard, req); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := readFile(t, target), "# edited\n"+strings.Replace(want, "alias ll='ls -l'", "alias la='ls -a'", 1); got != want {
+		t.Errorf("target after update =\n%s", got)
+	}
+	if drifts, err := VerifyEntry(entry); err != nil || len(drifts) != 1 || drifts[0].Problem != DriftModified {
+		t.Errorf("VerifyEntry after the block changed = (%v, %v), want modified", drifts, err)
+	}
+
+	if err := RemoveDeployed(io.Discard, entry, false); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, target); got != "# edited\n# written by the installer\nexport PATH=/opt/bin:$PATH\n" {
+		t.Errorf("target after removal =\n%s", got)
+	}
+	if drifts, err := VerifyEntry(entry); err != nil || len(drifts) != 1 || drifts[0].Problem != DriftMissing {
+		t.Errorf("VerifyEntry without the block = (%v, %v), want missing", drifts, err)
+	}
+}
+
+func TestAppendBlockAction_TemplateAndDryRun(t *testing.T) {
+	repo := t.TempDir()
+	target := filepath.Join(t.TempDir(), "config")
+	createDummyFile(t, filepath.Join(repo, "config.tmpl"), "user = {{ .user }}\n")
+	cfg := &config.Config{DotfilesRepoPath: repo, TemplateVariables: map[string]interface{}{"user": "ada"}}
+	df := config.Dotfile{Source: "config.tmpl", Target: target, Action: "append_block", IsTemplate: true}
+	action, _ := LookupAction("append_block")
+	if !RendersTemplates("append_block") {
+		t.Error("append_block should render its templates itself")
+	}
+
+	req := ApplyRequest{Dotfile: df, RepoPath: repo, Config: cfg, DryRun: true}
+	if err := action.Apply(io.Discard, req); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(target); !os.IsNotExist(err) {
+		t.Fatal("dry run should not create the target")
+	}
+
+	req.DryRun = false
+	if err := action.Apply(io.Discard, req); err != nil {
+		t.Fatal(err)
+	}
+	want := "# BEGIN RALPH MANAGED BLOCK: config.tmpl\nuser = ada\n# END RALPH MANAGED BLOCK: config.tmpl\n"
+	if got := readFile(t, target); got != want {
+		t.Errorf("target =\n%s\nwant\n%s", got, want)
+	}
+
+	// A target holding nothing but the block is removed with it.
+	entry, _ := NewManifestEntry(df, repo)
+	if err := RemoveDeployed(io.Discard, entry, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(target); !os.IsNotExist(err) {
+		t.Error("target holding only the block should be removed")
+	}
+}
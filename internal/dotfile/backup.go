@@ -0,0 +1,109 @@
+package dotfile
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/mad01/ralph/internal/config"
+)
+
+// backupTarget moves target out of the way to its newest backup path (see
+// config.BackupPath), first shifting the existing backups to older numbers
+// and removing those beyond backup.keep. what describes the target in the
+// output, e.g. " directory".
+func backupTarget(w io.Writer, target, what string, dryRun bool) error {
+	backupPath, err := config.BackupPath(target, 0)
+	if err != nil {
+		return err
+	}
+	if dryRun {
+		fmt.Fprintf(w, "    %s would back up%s %s %s\n", color.CyanString("[dry run]"), what, faint("→"), faint(config.ShortenHome(backupPath)))
+		return nil
+	}
+	if err := rotateBackups(w, target); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(backupPath), 0755); err != nil {
+		return fmt.Errorf("failed to create backup directory '%s': %w", filepath.Dir(backupPath), err)
+	}
+	fmt.Fprintf(w, "    %s %s %s\n", color.YellowString("backed up"+what), faint("→"), faint(config.ShortenHome(backupPath)))
+	if err := os.Rename(target, backupPath); err != nil {
+		return fmt.Errorf("failed to backup '%s' to '%s': %w", target, backupPath, err)
+	}
+	return nil
+}
+
+// rotateBackups makes room for a new backup of target: backups from number
+// keep-1 on are removed and the rest move one number up.
+func rotateBackups(w io.Writer, target string) error {
+	keep := config.BackupKeep()
+	if err := pruneBackups(w, target, keep-1); err != nil {
+		return err
+	}
+	for n := keep - 2; n >= 0; n-- {
+		from, err := config.BackupPath(target, n)
+		if err != nil {
+			return err
+		}
+		if _, err := os.Lstat(from); err != nil {
+			continue
+		}
+		to, err := config.BackupPath(target, n+1)
+		if err != nil {
+			return err
+		}
+		if err := os.Rename(from, to); err != nil {
+			return fmt.Errorf("failed to rotate backup '%s': %w", from, err)
+		}
+	}
+	return nil
+}
+
+// pruneBackups removes the backups of target numbered from and up.
+func pruneBackups(w io.Writer, target string, from int) error {
+	newest, err := config.BackupPath(target, 0)
+	if err != nil {
+		return err
+	}
+	dir, base := filepath.Split(newest)
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to read backup directory '%s': %w", dir, err)
+	}
+	for _, e := range entries {
+		n, ok := backupNumber(base, e.Name())
+		if !ok || n < from {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		if err := os.RemoveAll(path); err != nil {
+			return fmt.Errorf("failed to remove old backup '%s': %w", path, err)
+		}
+		fmt.Fprintf(w, "    %s %s\n", color.YellowString("removed old backup"), faint(config.ShortenHome(path)))
+	}
+	return nil
+}
+
+// backupNumber returns the number of the backup named name, for backups
+// whose newest is named base.
+func backupNumber(base, name string) (int, bool) {
+	if name == base {
+		return 0, true
+	}
+	rest, ok := strings.CutPrefix(name, base+".")
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.Atoi(rest)
+	if err != nil || n < 1 || strconv.Itoa(n) != rest {
+		return 0, false
+	}
+	return n, true
+}
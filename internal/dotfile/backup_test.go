@@ -0,0 +1,95 @@
+package dotfile
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mad01/ralph/internal/config"
+)
+
+func TestBackupTarget_Retention(t *testing.T) {
+	config.SetBackupConfig(config.BackupConfig{Keep: 3})
+	t.Cleanup(func() { config.SetBackupConfig(config.BackupConfig{}) })
+	target := filepath.Join(t.TempDir(), ".zshrc")
+
+	for _, content := range []string{"v1", "v2", "v3", "v4"} {
+		createDummyFile(t, target, content)
+		if err := backupTarget(io.Discard, target, "", false); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The newest three are kept, newest first
+	for path, want := range map[string]string{target + ".bak": "v4", target + ".bak.1": "v3", target + ".bak.2": "v2"} {
+		if got, err := os.ReadFile(path); err != nil || string(got) != want {
+			t.Errorf("%s = %q (%v), want %q", filepath.Base(path), got, err, want)
+		}
+	}
+	if _, err := os.Lstat(target + ".bak.3"); !os.IsNotExist(err) {
+		t.Error("backups beyond keep should be removed")
+	}
+
+	// Lowering keep prunes the excess on the next backup
+	config.SetBackupConfig(config.BackupConfig{})
+	createDummyFile(t, target, "v5")
+	if err := backupTarget(io.Discard, target, "", false); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(target + ".bak"); string(got) != "v5" {
+		t.Errorf(".bak = %q, want v5", got)
+	}
+	for _, old := range []string{".bak.1", ".bak.2"} {
+		if _, err := os.Lstat(target + old); !os.IsNotExist(err) {
+			t.Errorf("%s should be pruned with keep = 1", old)
+		}
+	}
+}
+
+func TestBackupTarget_Dir(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	backupDir := filepath.Join(home, "backups")
+	config.SetBackupConfig(config.BackupConfig{Dir: backupDir, Suffix: ".orig"})
+	t.Cleanup(func() { config.SetBackupConfig(config.BackupConfig{}) })
+
+	target := filepath.Join(home, ".config", "git", "config")
+	createDummyFile(t, target, "old")
+	if err := backupTarget(io.Discard, target, "", false); err != nil {
+		t.Fatal(err)
+	}
+	backup := filepath.Join(backupDir, ".config", "git", "config.orig")
+	if got, err := os.ReadFile(backup); err != nil || string(got) != "old" {
+		t.Errorf("backup = %q (%v), want the old target at %s", got, err, backup)
+	}
+
+	// uninstall restores from the same place
+	if err := restoreBackup(io.Discard, target, false); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(target); string(got) != "old" {
+		t.Errorf("restored target = %q, want old", got)
+	}
+}
+
+func TestBackupNumber(t *testing.T) {
+	tests := []struct {
+		name string
+		n    int
+		ok   bool
+	}{
+		{".zshrc.bak", 0, true},
+		{".zshrc.bak.2", 2, true},
+		{".zshrc.bak.02", 0, false},
+		{".zshrc.bak.x", 0, false},
+		{".zshrc.bak.0", 0, false},
+		{".zshrc", 0, false},
+	}
+	for _, tt := range tests {
+		n, ok := backupNumber(".zshrc.bak", tt.name)
+		if n != tt.n || ok != tt.ok {
+			t.Errorf("backupNumber(%q) = %d, %v; want %d, %v", tt.name, n, ok, tt.n, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,271 @@
+package dotfile
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/mad01/ralph/internal/config"
+)
+
+// BinState records the [bin] scripts apply has deployed, so scripts removed
+// from the repo can be pruned from the target directory.
+type BinState struct {
+	Scripts map[string]BinScript `json:"scripts"` // Keyed by script name
+}
+
+// BinScript is a deployed [bin] script.
+type BinScript struct {
+	Source string `json:"source"` // Absolute script path in the dotfiles repo
+	Target string `json:"target"` // Absolute deployed path
+	Copy   bool   `json:"copy,omitempty"`
+}
+
+// getBinStateFilePath returns the path to the bin state file
+func getBinStateFilePath() (string, error) {
+	return config.StateFilePath("bin_state")
+}
+
+// LoadBinState loads the bin state from the state file
+func LoadBinState() (*BinState, error) {
+	statePath, err := getBinStateFilePath()
+	if err != nil {
+		return nil, err
+	}
+
+	state := &BinState{
+		Scripts: make(map[string]BinScript),
+	}
+
+	data, err := os.ReadFile(statePath)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state file: %w", err)
+	}
+
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("failed to parse state file: %w", err)
+	}
+	if state.Scripts == nil {
+		state.Scripts = make(map[string]BinScript)
+	}
+
+	return state, nil
+}
+
+// SaveBinState saves the bin state to the state file
+func SaveBinState(state *BinState) error {
+	statePath, err := getBinStateFilePath()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(statePath), 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal state: %w", err)
+	}
+
+	if err := os.WriteFile(statePath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+
+	return nil
+}
+
+// ResetBinState deletes the bin state file
+func ResetBinState() error {
+	statePath, err := getBinStateFilePath()
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(statePath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove state file: %w", err)
+	}
+	return nil
+}
+
+// binPaths returns the expanded [bin] source and target directories.
+func binPaths(cfg *config.Config) (string, string, error) {
+	source, err := config.ExpandPath(filepath.Join(cfg.DotfilesRepoPath, cfg.Bin.Source))
+	if err != nil {
+		return "", "", fmt.Errorf("failed to expand bin source '%s': %w", cfg.Bin.Source, err)
+	}
+	target, err := config.ExpandPath(cfg.Bin.TargetDir())
+	if err != nil {
+		return "", "", fmt.Errorf("failed to expand bin target '%s': %w", cfg.Bin.TargetDir(), err)
+	}
+	return source, target, nil
+}
+
+// BinScripts returns the sorted names of the scripts in the [bin] source
+// directory: its regular files, skipping hidden ones and subdirectories.
+func BinScripts(cfg *config.Config) ([]string, error) {
+	source, _, err := binPaths(cfg)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bin source '%s': %w", source, err)
+	}
+	var names []string
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") || !entry.Type().IsRegular() {
+			continue
+		}
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// DeployBinScript symlinks (or, with copy = true, copies) one script into the
+// [bin] target and makes sure it is executable. A symlinked script gets the
+// executable bit set on its source in the repo. Files at the target that
+// ralph did not deploy are never replaced. It returns whether anything
+// changed, and records the script in state.
+// If dryRun is true, it will only print the actions it would take.
+func DeployBinScript(w io.Writer, cfg *config.Config, state *BinState, name string, dryRun bool) (bool, error) {
+	sourceDir, targetDir, err := binPaths(cfg)
+	if err != nil {
+		return false, err
+	}
+	source := filepath.Join(sourceDir, name)
+	target := filepath.Join(targetDir, name)
+
+	sourceInfo, err := os.Stat(source)
+	if err != nil {
+		return false, fmt.Errorf("failed to stat script '%s': %w", source, err)
+	}
+	_, tracked := state.Scripts[name]
+
+	changed := false
+	targetInfo, err := os.Lstat(target)
+	switch {
+	case os.IsNotExist(err):
+		changed = true
+	case err != nil:
+		return false, fmt.Errorf("failed to stat '%s': %w", target, err)
+	case cfg.Bin.Copy:
+		same, hashErr := sameContent(source, target)
+		if !targetInfo.Mode().IsRegular() || hashErr != nil || !same || targetInfo.Mode().Perm()&0111 == 0 {
+			changed = true
+		}
+	default:
+		dest, readErr := os.Readlink(target)
+		changed = readErr != nil || !config.SamePath(dest, source)
+	}
+	if changed && err == nil && !tracked {
+		return false, fmt.Errorf("'%s' already exists and was not deployed by ralph; remove it or rename the script", target)
+	}
+
+	makeExecutable := !cfg.Bin.Copy && sourceInfo.Mode().Perm()&0111 == 0
+	if !changed && !makeExecutable {
+		fmt.Fprintf(w, "    %s\n", color.GreenString("unchanged"))
+		state.Scripts[name] = BinScript{Source: source, Target: target, Copy: cfg.Bin.Copy}
+		return false, nil
+	}
+
+	if dryRun {
+		if makeExecutable {
+			fmt.Fprintf(w, "    %s would set the executable bit on %s\n", color.CyanString("[dry run]"), faint(config.ShortenHome(source)))
+		}
+		if changed {
+			verb := "link"
+			if cfg.Bin.Copy {
+				verb = "copy"
+			}
+			fmt.Fprintf(w, "    %s would %s %s %s\n", color.CyanString("[dry run]"), verb, faint("→"), faint(config.ShortenHome(target)))
+		}
+		return true, nil
+	}
+
+	if makeExecutable {
+		if err := os.Chmod(source, sourceInfo.Mode().Perm()|0111); err != nil {
+			return false, fmt.Errorf("failed to make '%s' executable: %w", source, err)
+		}
+		fmt.Fprintf(w, "    %s %s\n", color.YellowString("made executable"), faint(config.ShortenHome(source)))
+	}
+	if changed {
+		if err := os.MkdirAll(targetDir, 0755); err != nil {
+			return false, fmt.Errorf("failed to create bin directory '%s': %w", targetDir, err)
+		}
+		if err := os.Remove(target); err != nil && !os.IsNotExist(err) {
+			return false, fmt.Errorf("failed to replace '%s': %w", target, err)
+		}
+		if cfg.Bin.Copy {
+			content, err := os.ReadFile(source)
+			if err != nil {
+				return false, fmt.Errorf("failed to read script '%s': %w", source, err)
+			}
+			if err := os.WriteFile(target, content, sourceInfo.Mode().Perm()|0111); err != nil {
+				return false, fmt.Errorf("failed to copy script to '%s': %w", target, err)
+			}
+			fmt.Fprintf(w, "    %s %s %s\n", color.GreenString("copied"), faint("→"), faint(config.ShortenHome(target)))
+		} else {
+			if err := Symlink(source, target); err != nil {
+				return false, fmt.Errorf("failed to link '%s': %w", target, err)
+			}
+			fmt.Fprintf(w, "    %s %s %s\n", color.GreenString("linked"), faint("→"), faint(config.ShortenHome(target)))
+		}
+	}
+	state.Scripts[name] = BinScript{Source: source, Target: target, Copy: cfg.Bin.Copy}
+	return true, nil
+}
+
+// PruneBinScripts removes scripts recorded in state that are not in keep,
+// e.g. because they were deleted from the repo or [bin] was disabled, and
+// returns their names. A symlink is only removed while it still points at
+// the script it was deployed from; a copy is always removed.
+// If dryRun is true, it will only print the actions it would take.
+func PruneBinScripts(w io.Writer, state *BinState, keep []string, dryRun bool) ([]string, error) {
+	kept := make(map[string]bool, len(keep))
+	for _, name := range keep {
+		kept[name] = true
+	}
+
+	names := make([]string, 0, len(state.Scripts))
+	for name := range state.Scripts {
+		if !kept[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var pruned []string
+	for _, name := range names {
+		script := state.Scripts[name]
+		if !script.Copy {
+			if dest, err := os.Readlink(script.Target); err == nil && !config.SamePath(dest, script.Source) {
+				fmt.Fprintf(w, "    %s %s %s\n", color.CyanString("kept"), faint(config.ShortenHome(script.Target)), faint("(links elsewhere now)"))
+				if !dryRun {
+					delete(state.Scripts, name)
+				}
+				continue
+			}
+		}
+		if dryRun {
+			fmt.Fprintf(w, "    %s would remove %s\n", color.CyanString("[dry run]"), faint(config.ShortenHome(script.Target)))
+			pruned = append(pruned, name)
+			continue
+		}
+		if err := os.Remove(script.Target); err != nil && !os.IsNotExist(err) {
+			return pruned, fmt.Errorf("failed to remove '%s': %w", script.Target, err)
+		}
+		fmt.Fprintf(w, "    %s %s\n", color.YellowString("removed"), faint(config.ShortenHome(script.Target)))
+		delete(state.Scripts, name)
+		pruned = append(pruned, name)
+	}
+	return pruned, nil
+}
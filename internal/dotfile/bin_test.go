@@ -0,0 +1,161 @@
+package dotfile
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/mad01/ralph/internal/config"
+)
+
+// binTestConfig returns a config with a repo bin/ directory holding the given
+// scripts and a bin target inside the temp dir.
+func binTestConfig(t *testing.T, copyScripts bool, scripts ...string) *config.Config {
+	t.Helper()
+	base := t.TempDir()
+	repo := filepath.Join(base, "repo")
+	if err := os.MkdirAll(filepath.Join(repo, "bin", "lib"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(repo, "bin", ".hidden"), []byte("x"), 0644)
+	for _, name := range scripts {
+		if err := os.WriteFile(filepath.Join(repo, "bin", name), []byte("#!/bin/sh\necho "+name+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &config.Config{
+		DotfilesRepoPath: repo,
+		Bin:              config.BinConfig{Source: "bin", Target: filepath.Join(base, "local", "bin"), Copy: copyScripts},
+	}
+}
+
+func TestBinScripts(t *testing.T) {
+	cfg := binTestConfig(t, false, "b-script", "a-script")
+	names, err := BinScripts(cfg)
+	if err != nil {
+		t.Fatalf("BinScripts failed: %v", err)
+	}
+	if want := []string{"a-script", "b-script"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("BinScripts = %v, want %v (sorted, no hidden files or directories)", names, want)
+	}
+}
+
+func TestDeployBinScript_Symlink(t *testing.T) {
+	cfg := binTestConfig(t, false, "hello")
+	state := &BinState{Scripts: map[string]BinScript{}}
+	source := filepath.Join(cfg.DotfilesRepoPath, "bin", "hello")
+	target := filepath.Join(cfg.Bin.Target, "hello")
+
+	changed, err := DeployBinScript(io.Discard, cfg, state, "hello", true)
+	if err != nil || !changed {
+		t.Fatalf("dry run = (%v, %v), want (true, nil)", changed, err)
+	}
+	if _, err := os.Lstat(target); !os.IsNotExist(err) {
+		t.Error("dry run should not create the target")
+	}
+
+	changed, err = DeployBinScript(io.Discard, cfg, state, "hello", false)
+	if err != nil || !changed {
+		t.Fatalf("deploy = (%v, %v), want (true, nil)", changed, err)
+	}
+	if dest, _ := os.Readlink(target); dest != source {
+		t.Errorf("target links to %q, want %q", dest, source)
+	}
+	if info, _ := os.Stat(source); info.Mode().Perm()&0111 == 0 {
+		t.Errorf("source mode = %04o, want the executable bit set", info.Mode().Perm())
+	}
+	if state.Scripts["hello"].Target != target {
+		t.Errorf("state = %v, want hello recorded", state.Scripts)
+	}
+
+	if changed, err := DeployBinScript(io.Discard, cfg, state, "hello", false); err != nil || changed {
+		t.Errorf("second deploy = (%v, %v), want (false, nil)", changed, err)
+	}
+}
+
+func TestDeployBinScript_Copy(t *testing.T) {
+	cfg := binTestConfig(t, true, "hello")
+	state := &BinState{Scripts: map[string]BinScript{}}
+	target := filepath.Join(cfg.Bin.Target, "hello")
+
+	if _, err := DeployBinScript(io.Discard, cfg, state, "hello", false); err != nil {
+		t.Fatalf("deploy failed: %v", err)
+	}
+	info, err := os.Lstat(target)
+	if err != nil || !info.Mode().IsRegular() || info.Mode().Perm()&0111 == 0 {
+		t.Fatalf("target = %v, %v; want an executable regular file", info, err)
+	}
+	if info, _ := os.Stat(filepath.Join(cfg.DotfilesRepoPath, "bin", "hello")); info.Mode().Perm()&0111 != 0 {
+		t.Error("copying should not change the source mode")
+	}
+
+	os.WriteFile(filepath.Join(cfg.DotfilesRepoPath, "bin", "hello"), []byte("#!/bin/sh\necho changed\n"), 0644)
+	if changed, err := DeployBinScript(io.Discard, cfg, state, "hello", false); err != nil || !changed {
+		t.Errorf("deploy after edit = (%v, %v), want (true, nil)", changed, err)
+	}
+}
+
+func TestDeployBinScript_RefusesForeignFile(t *testing.T) {
+	cfg := binTestConfig(t, false, "hello")
+	os.MkdirAll(cfg.Bin.Target, 0755)
+	foreign := filepath.Join(cfg.Bin.Target, "hello")
+	os.WriteFile(foreign, []byte("mine"), 0755)
+
+	state := &BinState{Scripts: map[string]BinScript{}}
+	if _, err := DeployBinScript(io.Discard, cfg, state, "hello", false); err == nil {
+		t.Error("expected an error for a file ralph did not deploy")
+	}
+	if content, _ := os.ReadFile(foreign); string(content) != "mine" {
+		t.Error("foreign file should be left alone")
+	}
+}
+
+func TestPruneBinScripts(t *testing.T) {
+	cfg := binTestConfig(t, false, "keep", "gone")
+	state := &BinState{Scripts: map[string]BinScript{}}
+	for _, name := range []string{"keep", "gone"} {
+		if _, err := DeployBinScript(io.Discard, cfg, state, name, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	os.Remove(filepath.Join(cfg.DotfilesRepoPath, "bin", "gone"))
+
+	pruned, err := PruneBinScripts(io.Discard, state, []string{"keep"}, true)
+	if err != nil || !reflect.DeepEqual(pruned, []string{"gone"}) {
+		t.Fatalf("dry run prune = (%v, %v), want ([gone], nil)", pruned, err)
+	}
+	if _, err := os.Lstat(filepath.Join(cfg.Bin.Target, "gone")); err != nil {
+		t.Error("dry run should not remove anything")
+	}
+
+	pruned, err = PruneBinScripts(io.Discard, state, []string{"keep"}, false)
+	if err != nil || !reflect.DeepEqual(pruned, []string{"gone"}) {
+		t.Fatalf("prune = (%v, %v), want ([gone], nil)", pruned, err)
+	}
+	if _, err := os.Lstat(filepath.Join(cfg.Bin.Target, "gone")); !os.IsNotExist(err) {
+		t.Error("pruned script should be removed")
+	}
+	if _, err := os.Lstat(filepath.Join(cfg.Bin.Target, "keep")); err != nil {
+		t.Error("kept script should stay")
+	}
+	if _, ok := state.Scripts["gone"]; ok {
+		t.Error("pruned script should be dropped from state")
+	}
+}
+
+func TestBinState_RoundTrip(t *testing.T) {
+	t.Setenv(config.StateDirEnv, t.TempDir())
+	state := &BinState{Scripts: map[string]BinScript{"hello": {Source: "/repo/bin/hello", Target: "/home/u/.local/bin/hello"}}}
+	if err := SaveBinState(state); err != nil {
+		t.Fatalf("SaveBinState failed: %v", err)
+	}
+	loaded, err := LoadBinState()
+	if err != nil {
+		t.Fatalf("LoadBinState failed: %v", err)
+	}
+	if !reflect.DeepEqual(loaded, state) {
+		t.Errorf("loaded = %v, want %v", loaded, state)
+	}
+}
@@ -0,0 +1,131 @@
+package dotfile
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/mad01/ralph/internal/config"
+)
+
+// CaptureCandidate is a deployed file whose content no longer matches what
+// apply would write, typically because it was edited in place.
+type CaptureCandidate struct {
+	Name   string // Dotfile entry name
+	Source string // Absolute path of the source in the dotfiles repo
+	Target string // Absolute path of the deployed file
+	// Template is set when Source is a template. Expected then holds the
+	// rendered output the target is compared against, and copying the target
+	// back would replace template expressions with rendered values.
+	Template bool
+	Expected []byte
+}
+
+// FindCaptureCandidates returns the deployed files of a dotfile entry that
+// differ from their source. Only copied and rendered files are considered;
+// plain symlinks already point into the repo, and append_block targets hold
+// more than the source. Missing targets are ignored.
+func FindCaptureCandidates(name string, df config.Dotfile, cfg *config.Config) ([]CaptureCandidate, error) {
+	if df.Action == "append_block" {
+		return nil, nil
+	}
+	absoluteSource, err := config.ExpandPath(filepath.Join(cfg.DotfilesRepoPath, df.Source))
+	if err != nil {
+		return nil, fmt.Errorf("failed to expand source path '%s': %w", df.Source, err)
+	}
+	absoluteTarget, err := config.ExpandPath(df.Target)
+	if err != nil {
+		return nil, fmt.Errorf("failed to expand target path '%s': %w", df.Target, err)
+	}
+	opts := TemplateOptionsFor(df, false)
+
+	switch {
+	case df.Action == "template_dir":
+		var candidates []CaptureCandidate
+		err := filepath.WalkDir(absoluteSource, func(path string, d fs.DirEntry, walkErr error) error {
+			if walkErr != nil {
+				return walkErr
+			}
+			if !d.Type().IsRegular() {
+				return nil
+			}
+			rel, err := filepath.Rel(absoluteSource, path)
+			if err != nil {
+				return err
+			}
+			target := filepath.Join(absoluteTarget, filepath.Dir(rel), renderedName(rel))
+			c, err := templateCandidate(name, path, target, cfg, opts)
+			if err != nil || c == nil {
+				return err
+			}
+			candidates = append(candidates, *c)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		return candidates, nil
+	case df.IsTemplate:
+		c, err := templateCandidate(name, absoluteSource, absoluteTarget, cfg, opts)
+		if err != nil || c == nil {
+			return nil, err
+		}
+		return []CaptureCandidate{*c}, nil
+	case df.Action == "copy":
+		if _, err := os.Stat(absoluteTarget); os.IsNotExist(err) {
+			return nil, nil
+		}
+		same, err := sameContent(absoluteSource, absoluteTarget)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compare '%s' with '%s': %w", absoluteTarget, absoluteSource, err)
+		}
+		if same {
+			return nil, nil
+		}
+		expected, err := os.ReadFile(absoluteSource)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read source '%s': %w", absoluteSource, err)
+		}
+		return []CaptureCandidate{{Name: name, Source: absoluteSource, Target: absoluteTarget, Expected: expected}}, nil
+	}
+	return nil, nil
+}
+
+// templateCandidate renders source and returns a candidate if target exists
+// and differs from the rendered output.
+func templateCandidate(name, source, target string, cfg *config.Config, opts TemplateOptions) (*CaptureCandidate, error) {
+	current, err := os.ReadFile(target)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read target '%s': %w", target, err)
+	}
+	rendered, err := ProcessTemplateWithOptions(source, cfg, nil, opts)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Equal(current, rendered) {
+		return nil, nil
+	}
+	return &CaptureCandidate{Name: name, Source: source, Target: target, Template: true, Expected: rendered}, nil
+}
+
+// Capture copies the deployed target back over its source in the dotfiles
+// repo, keeping the source's file mode.
+func Capture(c CaptureCandidate) error {
+	content, err := os.ReadFile(c.Target)
+	if err != nil {
+		return fmt.Errorf("failed to read target '%s': %w", c.Target, err)
+	}
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(c.Source); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if err := os.WriteFile(c.Source, content, mode); err != nil {
+		return fmt.Errorf("failed to write source '%s': %w", c.Source, err)
+	}
+	return nil
+}
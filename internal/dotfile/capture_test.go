@@ -0,0 +1,102 @@
+package dotfile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mad01/ralph/internal/config"
+)
+
+func TestFindCaptureCandidates_Copy(t *testing.T) {
+	repo := t.TempDir()
+	source := filepath.Join(repo, "gitconfig")
+	if err := os.WriteFile(source, []byte("original\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	target := filepath.Join(t.TempDir(), ".gitconfig")
+	if err := os.WriteFile(target, []byte("original\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{DotfilesRepoPath: repo}
+	df := config.Dotfile{Source: "gitconfig", Target: target, Action: "copy"}
+
+	candidates, err := FindCaptureCandidates("git", df, cfg)
+	if err != nil {
+		t.Fatalf("FindCaptureCandidates failed: %v", err)
+	}
+	if len(candidates) != 0 {
+		t.Fatalf("expected no candidates for identical files, got %d", len(candidates))
+	}
+
+	if err := os.WriteFile(target, []byte("edited\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	candidates, err = FindCaptureCandidates("git", df, cfg)
+	if err != nil {
+		t.Fatalf("FindCaptureCandidates failed: %v", err)
+	}
+	if len(candidates) != 1 || candidates[0].Template {
+		t.Fatalf("expected one non-template candidate, got %+v", candidates)
+	}
+
+	if err := Capture(candidates[0]); err != nil {
+		t.Fatalf("Capture failed: %v", err)
+	}
+	got, _ := os.ReadFile(source)
+	if string(got) != "edited\n" {
+		t.Errorf("source content = %q, want %q", got, "edited\n")
+	}
+	info, _ := os.Stat(source)
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("source mode = %v, want 0600", info.Mode().Perm())
+	}
+}
+
+func TestFindCaptureCandidates_Template(t *testing.T) {
+	repo := t.TempDir()
+	if err := os.WriteFile(filepath.Join(repo, "env.tmpl"), []byte("name={{ .Name }}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	target := filepath.Join(t.TempDir(), ".env")
+	if err := os.WriteFile(target, []byte("name=ralph\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{DotfilesRepoPath: repo, TemplateVariables: map[string]interface{}{"Name": "ralph"}}
+	df := config.Dotfile{Source: "env.tmpl", Target: target, IsTemplate: true}
+
+	candidates, err := FindCaptureCandidates("env", df, cfg)
+	if err != nil {
+		t.Fatalf("FindCaptureCandidates failed: %v", err)
+	}
+	if len(candidates) != 0 {
+		t.Fatalf("expected no candidates when target matches rendered output, got %d", len(candidates))
+	}
+
+	if err := os.WriteFile(target, []byte("name=edited\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	candidates, err = FindCaptureCandidates("env", df, cfg)
+	if err != nil {
+		t.Fatalf("FindCaptureCandidates failed: %v", err)
+	}
+	if len(candidates) != 1 || !candidates[0].Template {
+		t.Fatalf("expected one template candidate, got %+v", candidates)
+	}
+	if string(candidates[0].Expected) != "name=ralph\n" {
+		t.Errorf("expected rendered content, got %q", candidates[0].Expected)
+	}
+}
+
+func TestFindCaptureCandidates_SymlinkIgnored(t *testing.T) {
+	cfg := &config.Config{DotfilesRepoPath: t.TempDir()}
+	df := config.Dotfile{Source: "zshrc", Target: filepath.Join(t.TempDir(), ".zshrc")}
+
+	candidates, err := FindCaptureCandidates("zsh", df, cfg)
+	if err != nil {
+		t.Fatalf("FindCaptureCandidates failed: %v", err)
+	}
+	if len(candidates) != 0 {
+		t.Errorf("expected symlinked dotfiles to be ignored, got %d candidates", len(candidates))
+	}
+}
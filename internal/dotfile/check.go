@@ -0,0 +1,111 @@
+package dotfile
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/mad01/ralph/internal/config"
+)
+
+// filesInSync is Plan for the built-in actions. Symlinks must point at their
+// source, copies must have the same content, and templates (single files and
+// template_dir) must match their rendered output. Templates are rendered
+// as in a dry run, so ones that use the output function never match.
+// Source paths matching the dotfile's ignore patterns are not checked.
+func filesInSync(df config.Dotfile, cfg *config.Config) (bool, error) {
+	absoluteSource, err := config.ExpandPath(filepath.Join(cfg.DotfilesRepoPath, df.Source))
+	if err != nil {
+		return false, fmt.Errorf("failed to expand source path '%s': %w", df.Source, err)
+	}
+	absoluteTarget, err := config.ExpandPath(df.Target)
+	if err != nil {
+		return false, fmt.Errorf("failed to expand target path '%s': %w", df.Target, err)
+	}
+	if _, err := os.Lstat(absoluteTarget); os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("failed to stat target '%s': %w", absoluteTarget, err)
+	}
+	opts := TemplateOptionsFor(df, true)
+
+	switch {
+	case df.Action == "template_dir":
+		inSync := true
+		err := filepath.WalkDir(absoluteSource, func(path string, d fs.DirEntry, walkErr error) error {
+			if walkErr != nil {
+				return walkErr
+			}
+			rel, err := filepath.Rel(absoluteSource, path)
+			if err != nil {
+				return err
+			}
+			if rel != "." && config.IgnoredPath(df.Ignore, rel, d.IsDir()) {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !d.Type().IsRegular() || !inSync {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			dest := filepath.Join(absoluteTarget, filepath.Dir(rel), renderedName(rel))
+			if existing, err := os.Stat(dest); err != nil || existing.Mode().Perm() != info.Mode().Perm() {
+				inSync = false
+				return nil
+			}
+			inSync, err = matchesRendered(path, dest, cfg, opts)
+			return err
+		})
+		return inSync, err
+	case df.IsTemplate:
+		return matchesRendered(absoluteSource, absoluteTarget, cfg, opts)
+	case df.Action == "copy":
+		return sameContent(absoluteSource, absoluteTarget)
+	case df.Merge:
+		entries, err := os.ReadDir(absoluteSource)
+		if err != nil {
+			return false, fmt.Errorf("failed to read source directory '%s': %w", absoluteSource, err)
+		}
+		for _, e := range entries {
+			if config.IgnoredPath(df.Ignore, e.Name(), e.IsDir()) {
+				continue
+			}
+			if !linksTo(filepath.Join(absoluteTarget, e.Name()), filepath.Join(absoluteSource, e.Name())) {
+				return false, nil
+			}
+		}
+		return true, nil
+	default:
+		return linksTo(absoluteTarget, absoluteSource), nil
+	}
+}
+
+// linksTo reports whether path is a symlink pointing at dest.
+func linksTo(path, dest string) bool {
+	linkTarget, err := os.Readlink(path)
+	return err == nil && config.SamePath(linkTarget, dest)
+}
+
+// matchesRendered reports whether target (following symlinks) holds the
+// rendered output of the template at source.
+func matchesRendered(source, target string, cfg *config.Config, opts TemplateOptions) (bool, error) {
+	current, err := os.ReadFile(target)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read target '%s': %w", target, err)
+	}
+	rendered, err := ProcessTemplateWithOptions(source, cfg, nil, opts)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(current, rendered), nil
+}
@@ -0,0 +1,69 @@
+package dotfile
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mad01/ralph/internal/config"
+)
+
+func TestInSync_Symlink(t *testing.T) {
+	repo := t.TempDir()
+	createDummyFile(t, filepath.Join(repo, "zshrc"), "repo")
+	cfg := &config.Config{DotfilesRepoPath: repo}
+	df := config.Dotfile{Source: "zshrc", Target: filepath.Join(t.TempDir(), ".zshrc")}
+
+	if ok, err := InSync(df, cfg); err != nil || ok {
+		t.Fatalf("missing target = (%v, %v), want (false, nil)", ok, err)
+	}
+	if err := CreateSymlink(io.Discard, df, repo, SymlinkActionBackup, false); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := InSync(df, cfg); err != nil || !ok {
+		t.Fatalf("linked target = (%v, %v), want (true, nil)", ok, err)
+	}
+
+	os.Remove(df.Target)
+	createDummyFile(t, df.Target, "repo")
+	if ok, _ := InSync(df, cfg); ok {
+		t.Error("regular file at a symlink target should not be in sync")
+	}
+}
+
+func TestInSync_Copy(t *testing.T) {
+	repo := t.TempDir()
+	createDummyFile(t, filepath.Join(repo, "gitconfig"), "repo")
+	cfg := &config.Config{DotfilesRepoPath: repo}
+	df := config.Dotfile{Source: "gitconfig", Target: filepath.Join(t.TempDir(), ".gitconfig"), Action: "copy"}
+
+	createDummyFile(t, df.Target, "repo")
+	if ok, err := InSync(df, cfg); err != nil || !ok {
+		t.Fatalf("identical copy = (%v, %v), want (true, nil)", ok, err)
+	}
+	createDummyFile(t, df.Target, "edited")
+	if ok, _ := InSync(df, cfg); ok {
+		t.Error("edited copy should not be in sync")
+	}
+}
+
+func TestInSync_TemplateDir(t *testing.T) {
+	cfg, target := setupTemplateDir(t)
+	df := config.Dotfile{Source: "conf", Target: target, Action: "template_dir"}
+
+	if ok, _ := InSync(df, cfg); ok {
+		t.Fatal("unrendered template_dir should not be in sync")
+	}
+	if err := RenderTemplateDir(io.Discard, df, cfg, nil, SymlinkActionBackup, false); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := InSync(df, cfg); err != nil || !ok {
+		t.Fatalf("rendered template_dir = (%v, %v), want (true, nil)", ok, err)
+	}
+
+	cfg.TemplateVariables["Name"] = "changed"
+	if ok, _ := InSync(df, cfg); ok {
+		t.Error("template_dir with changed variables should not be in sync")
+	}
+}
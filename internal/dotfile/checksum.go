@@ -0,0 +1,148 @@
+package dotfile
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/mad01/ralph/internal/config"
+)
+
+// Drift problems reported by VerifyEntry.
+const (
+	DriftMissing    = "missing"
+	DriftModified   = "modified"
+	DriftNotSymlink = "no longer a symlink"
+)
+
+// Drift is a deployed file that no longer matches what apply recorded.
+type Drift struct {
+	Path    string // Absolute path of the deployed file
+	Problem string // One of the Drift* constants
+}
+
+// deployedFiles returns the files apply deployed for entry, keyed by their
+// path relative to the target ("." for a single file). Directory entries are
+// enumerated from the source (the archive, for extract), so files added at
+// the target by other tools are not included.
+func deployedFiles(entry ManifestEntry) (map[string]string, error) {
+	if entry.Action == "extract" {
+		archive, err := entryArchive(entry)
+		if err != nil {
+			return nil, err
+		}
+		rels, err := archiveFiles(archive, config.ArchiveFormat(entry.Source))
+		if err != nil {
+			return nil, err
+		}
+		files := make(map[string]string, len(rels))
+		for _, rel := range rels {
+			files[rel] = filepath.Join(entry.Target, rel)
+		}
+		return files, nil
+	}
+	if entry.Action != "symlink_dir" && entry.Action != "template_dir" {
+		return map[string]string{".": entry.Target}, nil
+	}
+	files := make(map[string]string)
+	err := filepath.WalkDir(entry.Source, func(path string, d fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		rel, err := filepath.Rel(entry.Source, path)
+		if err != nil {
+			return err
+		}
+		if rel != "." && config.IgnoredPath(entry.Ignore, rel, d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		if entry.Action == "template_dir" {
+			rel = filepath.Join(filepath.Dir(rel), renderedName(rel))
+		}
+		files[rel] = filepath.Join(entry.Target, rel)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list source '%s': %w", entry.Source, err)
+	}
+	return files, nil
+}
+
+// DeployedChecksums returns the SHA-256 checksums of the files apply deployed
+// for entry, keyed like deployedFiles. Symlinks are followed, so a symlinked
+// file records its source's content and a copy or rendered template records
+// the content written to the target. For append_block it is the checksum of
+// the block alone.
+func DeployedChecksums(entry ManifestEntry) (map[string]string, error) {
+	if entry.Action == "append_block" {
+		sum, found, err := blockChecksum(entry)
+		if err != nil || !found {
+			return nil, err
+		}
+		return map[string]string{".": sum}, nil
+	}
+	files, err := deployedFiles(entry)
+	if err != nil {
+		return nil, err
+	}
+	sums := make(map[string]string, len(files))
+	for rel, path := range files {
+		sum, err := fileChecksum(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to hash '%s': %w", path, err)
+		}
+		sums[rel] = hex.EncodeToString(sum)
+	}
+	return sums, nil
+}
+
+// verifyFiles is Verify for the built-in actions: it compares the deployed
+// files of entry against the checksums recorded at apply time and returns
+// the ones that drifted, sorted by path. Symlinked entries must still be
+// symlinks; for them a modified file means the source in the repo changed
+// since the last apply.
+func verifyFiles(entry ManifestEntry) ([]Drift, error) {
+	var drifts []Drift
+	symlinked := entry.Action == "symlink" || entry.Action == "symlink_dir"
+	if symlinked && !entry.Merge {
+		if info, err := os.Lstat(entry.Target); err != nil {
+			return []Drift{{Path: entry.Target, Problem: DriftMissing}}, nil
+		} else if !IsLink(info) {
+			return []Drift{{Path: entry.Target, Problem: DriftNotSymlink}}, nil
+		}
+	}
+
+	rels := make([]string, 0, len(entry.Checksums))
+	for rel := range entry.Checksums {
+		rels = append(rels, rel)
+	}
+	sort.Strings(rels)
+	for _, rel := range rels {
+		path := filepath.Join(entry.Target, rel)
+		if entry.Merge && filepath.Dir(rel) == "." {
+			if info, err := os.Lstat(path); err == nil && !IsLink(info) {
+				drifts = append(drifts, Drift{Path: path, Problem: DriftNotSymlink})
+				continue
+			}
+		}
+		sum, err := fileChecksum(path)
+		switch {
+		case os.IsNotExist(err):
+			drifts = append(drifts, Drift{Path: path, Problem: DriftMissing})
+		case err != nil:
+			return drifts, fmt.Errorf("failed to hash '%s': %w", path, err)
+		case hex.EncodeToString(sum) != entry.Checksums[rel]:
+			drifts = append(drifts, Drift{Path: path, Problem: DriftModified})
+		}
+	}
+	return drifts, nil
+}
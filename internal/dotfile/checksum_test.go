@@ -0,0 +1,75 @@
+package dotfile
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestVerifyEntry_Copy(t *testing.T) {
+	tempDir := t.TempDir()
+	source := filepath.Join(tempDir, "gitconfig")
+	target := filepath.Join(tempDir, "home", ".gitconfig")
+	os.MkdirAll(filepath.Dir(target), 0755)
+	os.WriteFile(source, []byte("[user]\n"), 0644)
+	os.WriteFile(target, []byte("[user]\n"), 0644)
+
+	entry := ManifestEntry{Action: "copy", Source: source, Target: target}
+	sums, err := DeployedChecksums(entry)
+	if err != nil {
+		t.Fatalf("DeployedChecksums failed: %v", err)
+	}
+	entry.Checksums = sums
+	if drifts, err := VerifyEntry(entry); err != nil || len(drifts) != 0 {
+		t.Fatalf("fresh copy = (%v, %v), want no drift", drifts, err)
+	}
+
+	os.WriteFile(target, []byte("[user]\n  name = tampered\n"), 0644)
+	drifts, err := VerifyEntry(entry)
+	if want := []Drift{{Path: target, Problem: DriftModified}}; err != nil || !reflect.DeepEqual(drifts, want) {
+		t.Errorf("edited copy = (%v, %v), want %v", drifts, err, want)
+	}
+
+	os.Remove(target)
+	drifts, _ = VerifyEntry(entry)
+	if want := []Drift{{Path: target, Problem: DriftMissing}}; !reflect.DeepEqual(drifts, want) {
+		t.Errorf("removed copy = %v, want %v", drifts, want)
+	}
+}
+
+func TestVerifyEntry_SymlinkDir(t *testing.T) {
+	tempDir := t.TempDir()
+	source := filepath.Join(tempDir, "nvim")
+	target := filepath.Join(tempDir, "home", "nvim")
+	os.MkdirAll(filepath.Join(source, "lua"), 0755)
+	os.MkdirAll(filepath.Dir(target), 0755)
+	os.WriteFile(filepath.Join(source, "init.lua"), []byte("-- init\n"), 0644)
+	os.WriteFile(filepath.Join(source, "lua", "opts.lua"), []byte("-- opts\n"), 0644)
+	if err := os.Symlink(source, target); err != nil {
+		t.Fatal(err)
+	}
+
+	entry := ManifestEntry{Action: "symlink_dir", Source: source, Target: target}
+	sums, err := DeployedChecksums(entry)
+	if err != nil {
+		t.Fatalf("DeployedChecksums failed: %v", err)
+	}
+	if len(sums) != 2 || sums[filepath.Join("lua", "opts.lua")] == "" {
+		t.Fatalf("checksums = %v, want both files", sums)
+	}
+	entry.Checksums = sums
+
+	os.WriteFile(filepath.Join(source, "lua", "opts.lua"), []byte("-- changed\n"), 0644)
+	drifts, err := VerifyEntry(entry)
+	if want := []Drift{{Path: filepath.Join(target, "lua", "opts.lua"), Problem: DriftModified}}; err != nil || !reflect.DeepEqual(drifts, want) {
+		t.Errorf("changed source = (%v, %v), want %v", drifts, err, want)
+	}
+
+	os.Remove(target)
+	os.MkdirAll(target, 0755)
+	drifts, _ = VerifyEntry(entry)
+	if want := []Drift{{Path: target, Problem: DriftNotSymlink}}; !reflect.DeepEqual(drifts, want) {
+		t.Errorf("replaced link = %v, want %v", drifts, want)
+	}
+}
@@ -1,6 +1,8 @@
 package dotfile
 
 import (
+	"bytes"
+	"crypto/sha256"
 	"fmt"
 	"io"
 	"os"
@@ -41,18 +43,19 @@ func CopyFile(w io.Writer, dotfileCfg config.Dotfile, dotfilesRepoPath string, a
 	}
 
 	// Handle existing target file
-	_, err = os.Lstat(absoluteTarget)
+	targetInfo, err := os.Lstat(absoluteTarget)
 	if err == nil {
+		// Leave identical targets alone so their mtimes don't churn
+		if targetInfo.Mode().IsRegular() {
+			if same, hashErr := sameContent(absoluteSource, absoluteTarget); hashErr == nil && same {
+				fmt.Fprintf(w, "    %s\n", color.GreenString("unchanged"))
+				return nil
+			}
+		}
 		switch action {
 		case SymlinkActionBackup:
-			backupPath := absoluteTarget + ".bak"
-			if dryRun {
-				fmt.Fprintf(w, "    %s would back up %s %s\n", color.CyanString("[dry run]"), faint("→"), faint(config.ShortenHome(backupPath)))
-			} else {
-				fmt.Fprintf(w, "    %s %s %s\n", color.YellowString("backed up"), faint("→"), faint(config.ShortenHome(backupPath)))
-				if err := os.Rename(absoluteTarget, backupPath); err != nil {
-					return fmt.Errorf("failed to backup '%s' to '%s': %w", absoluteTarget, backupPath, err)
-				}
+			if err := backupTarget(w, absoluteTarget, "", dryRun); err != nil {
+				return err
 			}
 		case SymlinkActionOverwrite:
 			if dryRun {
@@ -89,6 +92,34 @@ func CopyFile(w io.Writer, dotfileCfg config.Dotfile, dotfilesRepoPath string, a
 	return nil
 }
 
+// sameContent reports whether two files have identical SHA-256 checksums.
+func sameContent(a, b string) (bool, error) {
+	hashA, err := fileChecksum(a)
+	if err != nil {
+		return false, err
+	}
+	hashB, err := fileChecksum(b)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(hashA, hashB), nil
+}
+
+// fileChecksum returns the SHA-256 checksum of a file's contents.
+func fileChecksum(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
 // copyFileContents copies the contents of the source file to the target file,
 // preserving the source file's permissions.
 func copyFileContents(src, dst string) error {
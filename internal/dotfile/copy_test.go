@@ -0,0 +1,74 @@
+package dotfile
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mad01/ralph/internal/config"
+)
+
+func TestCopyFile_UnchangedTargetIsNotRewritten(t *testing.T) {
+	repo := t.TempDir()
+	if err := os.WriteFile(filepath.Join(repo, "gitconfig"), []byte("[user]\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	target := filepath.Join(t.TempDir(), ".gitconfig")
+	if err := os.WriteFile(target, []byte("[user]\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(target, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	df := config.Dotfile{Source: "gitconfig", Target: target, Action: "copy"}
+	if err := CopyFile(&buf, df, repo, SymlinkActionBackup, false); err != nil {
+		t.Fatalf("CopyFile failed: %v", err)
+	}
+
+	if !strings.Contains(buf.String(), "unchanged") {
+		t.Errorf("expected 'unchanged' in output, got %q", buf.String())
+	}
+	info, err := os.Stat(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.ModTime().Equal(old) {
+		t.Errorf("target mtime changed: got %v, want %v", info.ModTime(), old)
+	}
+	if _, err := os.Stat(target + ".bak"); !os.IsNotExist(err) {
+		t.Error("identical target should not be backed up")
+	}
+}
+
+func TestCopyFile_ChangedTargetIsReplaced(t *testing.T) {
+	repo := t.TempDir()
+	if err := os.WriteFile(filepath.Join(repo, "gitconfig"), []byte("new\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	target := filepath.Join(t.TempDir(), ".gitconfig")
+	if err := os.WriteFile(target, []byte("old\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	df := config.Dotfile{Source: "gitconfig", Target: target, Action: "copy"}
+	if err := CopyFile(&buf, df, repo, SymlinkActionBackup, false); err != nil {
+		t.Fatalf("CopyFile failed: %v", err)
+	}
+
+	if !strings.Contains(buf.String(), "copied") {
+		t.Errorf("expected 'copied' in output, got %q", buf.String())
+	}
+	if got, _ := os.ReadFile(target); string(got) != "new\n" {
+		t.Errorf("target content = %q, want %q", got, "new\n")
+	}
+	if got, _ := os.ReadFile(target + ".bak"); string(got) != "old\n" {
+		t.Errorf("backup content = %q, want %q", got, "old\n")
+	}
+}
@@ -0,0 +1,239 @@
+package dotfile
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/mad01/ralph/internal/config"
+)
+
+// DefaultDownloadMode is the mode of downloaded files without a mode.
+const DefaultDownloadMode os.FileMode = 0644
+
+// downloadCacheDir is the directory under the state directory that keeps
+// downloaded files, so a target that was removed is restored without
+// fetching it again.
+const downloadCacheDir = "downloads"
+
+// httpClient fetches action = "download" URLs.
+var httpClient = &http.Client{Timeout: 5 * time.Minute}
+
+// downloadAction fetches a file from a URL to the target (action =
+// "download"). A target is fetched again when it is missing or, with a
+// checksum, when its content doesn't match.
+type downloadAction struct{}
+
+// Plan reports whether the target exists, matches the checksum if one is
+// set, and has the configured mode. It never touches the network.
+func (downloadAction) Plan(df config.Dotfile, cfg *config.Config) (bool, error) {
+	target, err := config.ExpandPath(df.Target)
+	if err != nil {
+		return false, fmt.Errorf("failed to expand target path '%s': %w", df.Target, err)
+	}
+	content, mode, err := downloadTargetState(df, target)
+	return err == nil && content && mode, err
+}
+
+// downloadTargetState reports whether target holds the right content (any
+// content without a checksum) and the right mode. A missing target or one
+// that is not a regular file has neither.
+func downloadTargetState(df config.Dotfile, target string) (contentOK, modeOK bool, err error) {
+	want, err := df.SHA256()
+	if err != nil {
+		return false, false, err
+	}
+	mode, err := df.FileMode()
+	if err != nil {
+		return false, false, err
+	}
+	info, err := os.Lstat(target)
+	if os.IsNotExist(err) {
+		return false, false, nil
+	}
+	if err != nil {
+		return false, false, fmt.Errorf("failed to stat target '%s': %w", target, err)
+	}
+	if !info.Mode().IsRegular() {
+		return false, false, nil
+	}
+	contentOK = true
+	if want != "" {
+		sum, err := fileChecksum(target)
+		if err != nil {
+			return false, false, fmt.Errorf("failed to hash '%s': %w", target, err)
+		}
+		contentOK = hex.EncodeToString(sum) == want
+	}
+	return contentOK, mode == 0 || info.Mode().Perm() == mode, nil
+}
+
+// Apply fetches the file, from the download cache when it has a copy that
+// matches the checksum, and writes it to the target. A target that only has
+// the wrong mode is chmod'ed.
+func (downloadAction) Apply(w io.Writer, req ApplyRequest) error {
+	df := req.Dotfile
+	target, err := config.ExpandPath(df.Target)
+	if err != nil {
+		return fmt.Errorf("failed to expand target path '%s': %w", df.Target, err)
+	}
+	contentOK, modeOK, err := downloadTargetState(df, target)
+	if err != nil {
+		return err
+	}
+	mode, _ := df.FileMode()
+	if contentOK {
+		if modeOK {
+			fmt.Fprintf(w, "    %s\n", color.GreenString("unchanged"))
+			return nil
+		}
+		if req.DryRun {
+			fmt.Fprintf(w, "    %s would set mode %04o\n", color.CyanString("[dry run]"), mode)
+			return nil
+		}
+		if err := os.Chmod(target, mode); err != nil {
+			return fmt.Errorf("failed to set mode of '%s': %w", target, err)
+		}
+		fmt.Fprintf(w, "    %s %s\n", color.YellowString("mode set"), faint(fmt.Sprintf("%04o", mode)))
+		return nil
+	}
+
+	if _, err := os.Lstat(target); err == nil {
+		if req.Existing == SymlinkActionSkip {
+			fmt.Fprintf(w, "    %s %s\n", color.CyanString("skipped"), faint("target exists"))
+			return nil
+		}
+		if err := handleExistingTarget(w, target, req.Existing, req.DryRun); err != nil {
+			return err
+		}
+	}
+
+	want, _ := df.SHA256()
+	if req.DryRun {
+		if path, err := downloadCachePath(df.URL); err == nil && cacheValid(path, want) {
+			fmt.Fprintf(w, "    %s would copy %s from the download cache\n", color.CyanString("[dry run]"), faint(df.URL))
+		} else {
+			fmt.Fprintf(w, "    %s would download %s\n", color.CyanString("[dry run]"), faint(df.URL))
+		}
+		return nil
+	}
+
+	cached, fromCache, err := fetchToCache(df.URL, want)
+	if err != nil {
+		return err
+	}
+	if mode == 0 {
+		mode = DefaultDownloadMode
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return fmt.Errorf("failed to create target directory '%s': %w", filepath.Dir(target), err)
+	}
+	if err := writeFileFrom(cached, target, mode); err != nil {
+		return fmt.Errorf("failed to write '%s': %w", target, err)
+	}
+	if fromCache {
+		fmt.Fprintf(w, "    %s %s\n", color.GreenString("copied from cache"), faint(df.URL))
+	} else {
+		fmt.Fprintf(w, "    %s %s\n", color.GreenString("downloaded"), faint(df.URL))
+	}
+	return nil
+}
+
+// Verify compares the target with the checksum recorded at apply time.
+func (downloadAction) Verify(entry ManifestEntry) ([]Drift, error) {
+	return verifyFiles(entry)
+}
+
+// downloadCachePath returns where the download cache keeps url.
+func downloadCachePath(url string) (string, error) {
+	stateDir, err := config.GetStateDir()
+	if err != nil {
+		return "", err
+	}
+	key := sha256.Sum256([]byte(url))
+	return filepath.Join(stateDir, downloadCacheDir, hex.EncodeToString(key[:])), nil
+}
+
+// cacheValid reports whether the cached file at path exists and, if want is
+// set, has that SHA-256 checksum.
+func cacheValid(path, want string) bool {
+	sum, err := fileChecksum(path)
+	return err == nil && (want == "" || hex.EncodeToString(sum) == want)
+}
+
+// fetchToCache returns the path of url in the download cache, downloading
+// it first unless the cache already has a copy matching want. A download that
+// doesn't match want is discarded.
+func fetchToCache(url, want string) (path string, fromCache bool, err error) {
+	path, err = downloadCachePath(url)
+	if err != nil {
+		return "", false, err
+	}
+	if cacheValid(path, want) {
+		return path, true, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", false, fmt.Errorf("failed to create download cache: %w", err)
+	}
+
+	resp, err := httpClient.Get(url)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to download '%s': %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", false, fmt.Errorf("failed to download '%s': %s", url, resp.Status)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".download-*")
+	if err != nil {
+		return "", false, fmt.Errorf("failed to create download cache file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	h := sha256.New()
+	_, err = io.Copy(io.MultiWriter(tmp, h), resp.Body)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("failed to download '%s': %w", url, err)
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); want != "" && got != want {
+		return "", false, fmt.Errorf("checksum mismatch for '%s': got sha256:%s, want sha256:%s", url, got, want)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", false, fmt.Errorf("failed to store download in cache: %w", err)
+	}
+	return path, false, nil
+}
+
+// writeFileFrom copies src to dst with the given mode.
+func writeFileFrom(src, dst string, mode os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Chmod(dst, mode)
+}
+
+func init() {
+	RegisterAction("download", downloadAction{})
+}
@@ -0,0 +1,173 @@
+package dotfile
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/mad01/ralph/internal/config"
+)
+
+// downloadServer serves body and counts the requests it gets.
+func downloadServer(t *testing.T, body string) (*httptest.Server, *int) {
+	t.Helper()
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != "/theme.zsh" {
+			http.NotFound(w, r)
+			return
+		}
+		io.WriteString(w, body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &requests
+}
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestDownloadAction(t *testing.T) {
+	t.Setenv(config.StateDirEnv, t.TempDir())
+	srv, requests := downloadServer(t, "theme v1\n")
+	cfg := &config.Config{}
+	df := config.Dotfile{
+		Target:   filepath.Join(t.TempDir(), "themes", "theme.zsh"),
+		Action:   "download",
+		URL:      srv.URL + "/theme.zsh",
+		Checksum: "sha256:" + sha256Hex("theme v1\n"),
+		Mode:     "0600",
+	}
+	action, _ := LookupAction("download")
+	req := ApplyRequest{Name: "theme", Dotfile: df, Config: cfg, Existing: SymlinkActionBackup}
+
+	if ok, err := InSync(df, cfg); err != nil || ok {
+		t.Fatalf("InSync before apply = (%v, %v), want (false, nil)", ok, err)
+	}
+	dryRun := req
+	dryRun.DryRun = true
+	if err := action.Apply(io.Discard, dryRun); err != nil {
+		t.Fatalf("dry run failed: %v", err)
+	}
+	if *requests != 0 {
+		t.Errorf("dry run made %d requests, want none", *requests)
+	}
+
+	if err := action.Apply(io.Discard, req); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	info, err := os.Stat(df.Target)
+	if err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("target = %v, %v; want a file with mode 0600", info, err)
+	}
+	if ok, err := InSync(df, cfg); err != nil || !ok {
+		t.Errorf("InSync after apply = (%v, %v), want (true, nil)", ok, err)
+	}
+
+	// A removed target comes back from the cache, without a request.
+	os.Remove(df.Target)
+	if err := action.Apply(io.Discard, req); err != nil {
+		t.Fatalf("Apply from cache failed: %v", err)
+	}
+	if *requests != 1 {
+		t.Errorf("requests = %d, want 1 (second apply served from the cache)", *requests)
+	}
+
+	// A target edited by hand no longer matches the checksum and is fetched again.
+	os.WriteFile(df.Target, []byte("edited"), 0600)
+	if ok, _ := InSync(df, cfg); ok {
+		t.Error("edited target should not be in sync")
+	}
+	if err := action.Apply(io.Discard, req); err != nil {
+		t.Fatalf("Apply after edit failed: %v", err)
+	}
+	if content, _ := os.ReadFile(df.Target); string(content) != "theme v1\n" {
+		t.Errorf("target = %q, want the download", content)
+	}
+	if backup, _ := os.ReadFile(df.Target + ".bak"); string(backup) != "edited" {
+		t.Errorf("backup = %q, want the edited file", backup)
+	}
+}
+
+func TestDownloadAction_ChecksumMismatch(t *testing.T) {
+	t.Setenv(config.StateDirEnv, t.TempDir())
+	srv, _ := downloadServer(t, "tampered\n")
+	df := config.Dotfile{
+		Target:   filepath.Join(t.TempDir(), "theme.zsh"),
+		Action:   "download",
+		URL:      srv.URL + "/theme.zsh",
+		Checksum: "sha256:" + sha256Hex("theme v1\n"),
+	}
+	action, _ := LookupAction("download")
+	if err := action.Apply(io.Discard, ApplyRequest{Dotfile: df, Existing: SymlinkActionBackup}); err == nil {
+		t.Fatal("expected a checksum mismatch error")
+	}
+	if _, err := os.Stat(df.Target); !os.IsNotExist(err) {
+		t.Error("a download that fails its checksum should not be written")
+	}
+	path, _ := downloadCachePath(df.URL)
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("a download that fails its checksum should not be cached")
+	}
+}
+
+func TestDownloadAction_NotFound(t *testing.T) {
+	t.Setenv(config.StateDirEnv, t.TempDir())
+	srv, _ := downloadServer(t, "")
+	df := config.Dotfile{Target: filepath.Join(t.TempDir(), "x"), Action: "download", URL: srv.URL + "/missing"}
+	action, _ := LookupAction("download")
+	if err := action.Apply(io.Discard, ApplyRequest{Dotfile: df, Existing: SymlinkActionBackup}); err == nil {
+		t.Error("expected an error for a 404")
+	}
+}
+
+func TestDownloadAction_ManifestAndRemove(t *testing.T) {
+	t.Setenv(config.StateDirEnv, t.TempDir())
+	srv, _ := downloadServer(t, "theme v1\n")
+	df := config.Dotfile{Target: filepath.Join(t.TempDir(), "theme.zsh"), Action: "download", URL: srv.URL + "/theme.zsh"}
+	action, _ := LookupAction("download")
+	if err := action.Apply(io.Discard, ApplyRequest{Dotfile: df, Existing: SymlinkActionBackup}); err != nil {
+		t.Fatal(err)
+	}
+
+	entry, err := NewManifestEntry(df, "/repo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.Source != df.URL {
+		t.Errorf("manifest source = %q, want the URL", entry.Source)
+	}
+	if entry.Checksums, err = DeployedChecksums(entry); err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{".": sha256Hex("theme v1\n")}; !reflect.DeepEqual(entry.Checksums, want) {
+		t.Errorf("checksums = %v, want %v", entry.Checksums, want)
+	}
+
+	os.WriteFile(df.Target, []byte("mine now"), 0644)
+	if drifts, _ := VerifyEntry(entry); len(drifts) != 1 || drifts[0].Problem != DriftModified {
+		t.Errorf("VerifyEntry = %v, want the target modified", drifts)
+	}
+	if err := RemoveDeployed(io.Discard, entry, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(df.Target); err != nil {
+		t.Error("a modified download should be kept on removal")
+	}
+
+	os.WriteFile(df.Target, []byte("theme v1\n"), 0644)
+	if err := RemoveDeployed(io.Discard, entry, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(df.Target); !os.IsNotExist(err) {
+		t.Error("an unmodified download should be removed")
+	}
+}
@@ -0,0 +1,417 @@
+package dotfile
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/bzip2"
+	"compress/gzip"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/mad01/ralph/internal/config"
+)
+
+// extractAction unpacks a zip or tar archive, from the repo or a URL, into
+// the target directory (action = "extract"). The manifest records the
+// archive's checksum and the files it produced, so an unchanged archive is
+// not extracted again and files a new version dropped are removed.
+type extractAction struct{}
+
+// Plan reports whether the target holds the files of the current archive, as
+// recorded in the manifest. A URL archive without a checksum counts as
+// current once extracted; it is never fetched to find out.
+func (extractAction) Plan(df config.Dotfile, cfg *config.Config) (bool, error) {
+	return extractInSync(df, cfg.DotfilesRepoPath)
+}
+
+func extractInSync(df config.Dotfile, repoPath string) (bool, error) {
+	target, err := config.ExpandPath(df.Target)
+	if err != nil {
+		return false, fmt.Errorf("failed to expand target path '%s': %w", df.Target, err)
+	}
+	entry, err := extractedEntry(target)
+	if err != nil || entry == nil {
+		return false, err
+	}
+	want, err := df.SHA256()
+	if err != nil {
+		return false, err
+	}
+	if df.URL == "" {
+		source, err := config.ExpandPath(filepath.Join(repoPath, df.Source))
+		if err != nil {
+			return false, fmt.Errorf("failed to expand source path '%s': %w", df.Source, err)
+		}
+		sum, err := fileChecksum(source)
+		if err != nil {
+			return false, fmt.Errorf("failed to hash archive '%s': %w", source, err)
+		}
+		want = hex.EncodeToString(sum)
+	}
+	if want != "" && want != entry.Archive {
+		return false, nil
+	}
+	drifts, err := verifyFiles(*entry)
+	return err == nil && len(drifts) == 0, err
+}
+
+// extractedEntry returns the manifest entry of the extract dotfile deployed
+// to target, or nil if there is none.
+func extractedEntry(target string) (*ManifestEntry, error) {
+	manifest, err := LoadManifest()
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range manifest.Dotfiles {
+		if entry.Action == "extract" && config.SamePath(entry.Target, target) {
+			return &entry, nil
+		}
+	}
+	return nil, nil
+}
+
+// Apply extracts the archive into the target directory, fetching a URL
+// archive through the download cache. Files in the target that the archive
+// also contains are replaced; files the previous extraction created that
+// the archive no longer contains are removed while unmodified.
+func (extractAction) Apply(w io.Writer, req ApplyRequest) error {
+	df := req.Dotfile
+	target, err := config.ExpandPath(df.Target)
+	if err != nil {
+		return fmt.Errorf("failed to expand target path '%s': %w", df.Target, err)
+	}
+	if inSync, err := extractInSync(df, req.RepoPath); err != nil {
+		return err
+	} else if inSync {
+		fmt.Fprintf(w, "    %s\n", color.GreenString("unchanged"))
+		return nil
+	}
+
+	if info, err := os.Lstat(target); err == nil && !info.IsDir() {
+		if req.Existing == SymlinkActionSkip {
+			fmt.Fprintf(w, "    %s %s\n", color.CyanString("skipped"), faint("target exists"))
+			return nil
+		}
+		if err := handleExistingTarget(w, target, req.Existing, req.DryRun); err != nil {
+			return err
+		}
+	}
+
+	want, _ := df.SHA256()
+	if req.DryRun {
+		fmt.Fprintf(w, "    %s would extract %s\n", color.CyanString("[dry run]"), faint(df.ArchiveName()))
+		return nil
+	}
+
+	var archive string
+	if df.URL != "" {
+		archive, _, err = fetchToCache(df.URL, want)
+	} else {
+		archive, err = localArchive(df, req.RepoPath)
+	}
+	if err != nil {
+		return err
+	}
+	if df.URL == "" && want != "" {
+		if sum, err := fileChecksum(archive); err != nil || hex.EncodeToString(sum) != want {
+			return fmt.Errorf("archive '%s' does not match its checksum", archive)
+		}
+	}
+
+	previous, _ := extractedEntry(target)
+	files, err := extractArchive(archive, config.ArchiveFormat(df.ArchiveName()), target)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "    %s %d files from %s\n", color.GreenString("extracted"), len(files), faint(df.ArchiveName()))
+
+	if previous != nil {
+		pruneExtracted(w, *previous, files)
+	}
+	return nil
+}
+
+// Verify compares the extracted files with the checksums recorded at apply
+// time.
+func (extractAction) Verify(entry ManifestEntry) ([]Drift, error) {
+	return verifyFiles(entry)
+}
+
+// localArchive returns the path of df's archive in the repo.
+func localArchive(df config.Dotfile, repoPath string) (string, error) {
+	source, err := config.ExpandPath(filepath.Join(repoPath, df.Source))
+	if err != nil {
+		return "", fmt.Errorf("failed to expand source path '%s': %w", df.Source, err)
+	}
+	return source, nil
+}
+
+// entryArchive returns the local archive of a manifest entry, whose Source
+// is the archive path or its URL.
+func entryArchive(entry ManifestEntry) (string, error) {
+	if strings.HasPrefix(entry.Source, "https://") || strings.HasPrefix(entry.Source, "http://") {
+		return downloadCachePath(entry.Source)
+	}
+	return entry.Source, nil
+}
+
+// pruneExtracted removes the files previous recorded that are not in files,
+// keeping any that were modified since, and directories left empty.
+func pruneExtracted(w io.Writer, previous ManifestEntry, files []string) {
+	kept := make(map[string]bool, len(files))
+	for _, rel := range files {
+		kept[rel] = true
+	}
+	var removed []string
+	for rel, sum := range previous.Checksums {
+		if kept[rel] {
+			continue
+		}
+		path := filepath.Join(previous.Target, rel)
+		if current, err := fileChecksum(path); err != nil || hex.EncodeToString(current) != sum {
+			continue
+		}
+		if err := os.Remove(path); err == nil {
+			removed = append(removed, path)
+		}
+	}
+	removeEmptyParents(removed, previous.Target)
+	if len(removed) > 0 {
+		fmt.Fprintf(w, "    %s %d files no longer in the archive\n", color.YellowString("removed"), len(removed))
+	}
+}
+
+// removeEmptyParents removes the directories of paths that are empty, up to
+// and including root, deepest first.
+func removeEmptyParents(paths []string, root string) {
+	seen := make(map[string]bool)
+	var dirs []string
+	for _, path := range paths {
+		for dir := filepath.Dir(path); within(dir, root) && !seen[dir]; dir = filepath.Dir(dir) {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+	sort.Slice(dirs, func(i, j int) bool {
+		return strings.Count(dirs[i], string(filepath.Separator)) > strings.Count(dirs[j], string(filepath.Separator))
+	})
+	for _, dir := range dirs {
+		if entries, err := os.ReadDir(dir); err == nil && len(entries) == 0 {
+			os.Remove(dir)
+		}
+	}
+}
+
+// within reports whether path is root or inside it.
+func within(path, root string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// archiveEntry is a file or directory in an archive.
+type archiveEntry struct {
+	rel  string // Cleaned path relative to the archive root
+	mode fs.FileMode
+	open func() (io.ReadCloser, error) // nil for directories
+}
+
+// walkArchive calls fn for each file and directory in the archive at path.
+// Links and other special entries are skipped. Entries whose path would
+// leave the extraction directory are an error.
+func walkArchive(path, format string, fn func(archiveEntry) error) error {
+	if format == "zip" {
+		r, err := zip.OpenReader(path)
+		if err != nil {
+			return fmt.Errorf("failed to open archive '%s': %w", path, err)
+		}
+		defer r.Close()
+		for _, f := range r.File {
+			f := f
+			rel, err := archivePath(f.Name)
+			if err != nil {
+				return err
+			}
+			if rel == "." {
+				continue
+			}
+			mode := f.Mode()
+			if !mode.IsDir() && !mode.IsRegular() {
+				continue
+			}
+			entry := archiveEntry{rel: rel, mode: mode}
+			if mode.IsRegular() {
+				entry.open = func() (io.ReadCloser, error) { return f.Open() }
+			}
+			if err := fn(entry); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open archive '%s': %w", path, err)
+	}
+	defer file.Close()
+	var stream io.Reader = file
+	switch format {
+	case "tar.gz":
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return fmt.Errorf("failed to read archive '%s': %w", path, err)
+		}
+		defer gz.Close()
+		stream = gz
+	case "tar.bz2":
+		stream = bzip2.NewReader(file)
+	}
+	tr := tar.NewReader(stream)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read archive '%s': %w", path, err)
+		}
+		rel, err := archivePath(hdr.Name)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			continue
+		}
+		var entry archiveEntry
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			entry = archiveEntry{rel: rel, mode: fs.ModeDir | hdr.FileInfo().Mode().Perm()}
+		case tar.TypeReg:
+			entry = archiveEntry{rel: rel, mode: hdr.FileInfo().Mode().Perm(), open: func() (io.ReadCloser, error) { return io.NopCloser(tr), nil }}
+		default:
+			continue
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+}
+
+// archivePath cleans the path of an archive entry, rejecting absolute paths
+// and ones that climb out of the extraction directory.
+func archivePath(name string) (string, error) {
+	rel := filepath.Clean(filepath.FromSlash(name))
+	if filepath.IsAbs(rel) || strings.HasPrefix(name, "/") || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("archive entry '%s' would be extracted outside the target", name)
+	}
+	return rel, nil
+}
+
+// archiveFiles returns the files in the archive at path, sorted.
+func archiveFiles(path, format string) ([]string, error) {
+	var files []string
+	err := walkArchive(path, format, func(entry archiveEntry) error {
+		if entry.open != nil {
+			files = append(files, entry.rel)
+		}
+		return nil
+	})
+	sort.Strings(files)
+	return files, err
+}
+
+// extractArchive unpacks the archive at path into dest and returns the files
+// it wrote, sorted. Files keep the permissions they have in the archive.
+func extractArchive(path, format, dest string) ([]string, error) {
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create target directory '%s': %w", dest, err)
+	}
+	var files []string
+	err := walkArchive(path, format, func(entry archiveEntry) error {
+		out := filepath.Join(dest, entry.rel)
+		if entry.open == nil {
+			return os.MkdirAll(out, 0755)
+		}
+		if err := os.MkdirAll(filepath.Dir(out), 0755); err != nil {
+			return err
+		}
+		in, err := entry.open()
+		if err != nil {
+			return fmt.Errorf("failed to read '%s' from archive: %w", entry.rel, err)
+		}
+		defer in.Close()
+		// Remove first so a symlink at out is replaced, not written through.
+		if err := os.Remove(out); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to replace '%s': %w", out, err)
+		}
+		mode := entry.mode.Perm()
+		if mode == 0 {
+			mode = 0644
+		}
+		f, err := os.OpenFile(out, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+		if err != nil {
+			return fmt.Errorf("failed to create '%s': %w", out, err)
+		}
+		if _, err := io.Copy(f, in); err != nil {
+			f.Close()
+			return fmt.Errorf("failed to write '%s': %w", out, err)
+		}
+		if err := f.Close(); err != nil {
+			return fmt.Errorf("failed to write '%s': %w", out, err)
+		}
+		files = append(files, entry.rel)
+		return nil
+	})
+	sort.Strings(files)
+	return files, err
+}
+
+// removeExtracted removes the files recorded for an extract entry that are
+// unmodified, then the directories left empty, and restores a backup of the
+// target.
+func removeExtracted(w io.Writer, entry ManifestEntry, dryRun bool) error {
+	rels := make([]string, 0, len(entry.Checksums))
+	for rel := range entry.Checksums {
+		rels = append(rels, rel)
+	}
+	sort.Strings(rels)
+	var removed []string
+	for _, rel := range rels {
+		path := filepath.Join(entry.Target, rel)
+		sum, err := fileChecksum(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil || hex.EncodeToString(sum) != entry.Checksums[rel] {
+			fmt.Fprintf(w, "    %s %s\n", color.CyanString("kept"), faint(config.ShortenHome(path)+" (modified since extraction)"))
+			continue
+		}
+		if dryRun {
+			fmt.Fprintf(w, "    %s would remove %s\n", color.CyanString("[dry run]"), faint(config.ShortenHome(path)))
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("failed to remove '%s': %w", path, err)
+		}
+		removed = append(removed, path)
+	}
+	if dryRun {
+		return restoreBackup(w, entry.Target, dryRun)
+	}
+	removeEmptyParents(removed, entry.Target)
+	if len(removed) > 0 {
+		fmt.Fprintf(w, "    %s %d files from %s\n", color.YellowString("removed"), len(removed), faint(config.ShortenHome(entry.Target)))
+	}
+	return restoreBackup(w, entry.Target, dryRun)
+}
+
+func init() {
+	RegisterAction("extract", extractAction{})
+}
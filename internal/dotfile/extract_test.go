@@ -0,0 +1,175 @@
+package dotfile
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/mad01/ralph/internal/config"
+)
+
+// writeTarGz writes a .tar.gz holding files (path → content) to path.
+func writeTarGz(t *testing.T, path string, files map[string]string) {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		hdr := &tar.Header{Name: name, Mode: 0644, Size: int64(len(files[name])), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write([]byte(files[name]))
+	}
+	tw.Close()
+	gz.Close()
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// zipBytes returns a zip archive holding files (path → content).
+func zipBytes(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		f, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(f, content)
+	}
+	zw.Close()
+	return buf.Bytes()
+}
+
+// applyExtract applies df and records it in the manifest, as apply does.
+func applyExtract(t *testing.T, df config.Dotfile, repo string) {
+	t.Helper()
+	action, _ := LookupAction("extract")
+	if err := action.Apply(io.Discard, ApplyRequest{Name: "fonts", Dotfile: df, RepoPath: repo, Existing: SymlinkActionBackup}); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	entry, err := NewManifestEntry(df, repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.Checksums, err = DeployedChecksums(entry); err != nil {
+		t.Fatal(err)
+	}
+	manifest, _ := LoadManifest()
+	manifest.Dotfiles["fonts"] = entry
+	if err := SaveManifest(manifest); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExtractAction_RepoArchive(t *testing.T) {
+	t.Setenv(config.StateDirEnv, t.TempDir())
+	repo := t.TempDir()
+	target := filepath.Join(t.TempDir(), "fonts")
+	writeTarGz(t, filepath.Join(repo, "fonts.tar.gz"), map[string]string{
+		"./Hack-Regular.ttf": "regular",
+		"bold/Hack-Bold.ttf": "bold",
+	})
+	cfg := &config.Config{DotfilesRepoPath: repo}
+	df := config.Dotfile{Source: "fonts.tar.gz", Target: target, Action: "extract"}
+
+	if ok, err := InSync(df, cfg); err != nil || ok {
+		t.Fatalf("InSync before apply = (%v, %v), want (false, nil)", ok, err)
+	}
+	applyExtract(t, df, repo)
+	if content, _ := os.ReadFile(filepath.Join(target, "bold", "Hack-Bold.ttf")); string(content) != "bold" {
+		t.Errorf("extracted bold/Hack-Bold.ttf = %q, want %q", content, "bold")
+	}
+	if ok, err := InSync(df, cfg); err != nil || !ok {
+		t.Errorf("InSync after apply = (%v, %v), want (true, nil)", ok, err)
+	}
+
+	// A new archive version is extracted again and files it dropped go away.
+	writeTarGz(t, filepath.Join(repo, "fonts.tar.gz"), map[string]string{"Hack-Regular.ttf": "regular v2"})
+	if ok, _ := InSync(df, cfg); ok {
+		t.Error("a changed archive should not be in sync")
+	}
+	applyExtract(t, df, repo)
+	if content, _ := os.ReadFile(filepath.Join(target, "Hack-Regular.ttf")); string(content) != "regular v2" {
+		t.Errorf("Hack-Regular.ttf = %q, want the new version", content)
+	}
+	if _, err := os.Stat(filepath.Join(target, "bold")); !os.IsNotExist(err) {
+		t.Error("files dropped from the archive, and their empty directory, should be removed")
+	}
+
+	// Editing an extracted file is drift, and uninstall keeps it.
+	os.WriteFile(filepath.Join(target, "Hack-Regular.ttf"), []byte("edited"), 0644)
+	manifest, _ := LoadManifest()
+	entry := manifest.Dotfiles["fonts"]
+	drifts, err := VerifyEntry(entry)
+	if want := []Drift{{Path: filepath.Join(target, "Hack-Regular.ttf"), Problem: DriftModified}}; err != nil || !reflect.DeepEqual(drifts, want) {
+		t.Errorf("VerifyEntry = (%v, %v), want (%v, nil)", drifts, err, want)
+	}
+	if err := RemoveDeployed(io.Discard, entry, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(target, "Hack-Regular.ttf")); err != nil {
+		t.Error("a modified extracted file should be kept on removal")
+	}
+}
+
+func TestExtractAction_URLArchive(t *testing.T) {
+	t.Setenv(config.StateDirEnv, t.TempDir())
+	archive := zipBytes(t, map[string]string{"parser/lua.so": "lua", "parser/go.so": "go"})
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write(archive)
+	}))
+	defer srv.Close()
+
+	target := filepath.Join(t.TempDir(), "nvim")
+	df := config.Dotfile{Target: target, Action: "extract", URL: srv.URL + "/parsers.zip?raw=1"}
+	applyExtract(t, df, "")
+	if content, _ := os.ReadFile(filepath.Join(target, "parser", "go.so")); string(content) != "go" {
+		t.Errorf("parser/go.so = %q, want %q", content, "go")
+	}
+	if ok, _ := InSync(df, &config.Config{}); !ok {
+		t.Error("an extracted URL archive without a checksum should be in sync")
+	}
+
+	manifest, _ := LoadManifest()
+	if err := RemoveDeployed(io.Discard, manifest.Dotfiles["fonts"], false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(target); !os.IsNotExist(err) {
+		t.Error("removing an unmodified extraction should remove the target directory")
+	}
+	if requests != 1 {
+		t.Errorf("requests = %d, want 1", requests)
+	}
+}
+
+func TestExtractArchive_RejectsEscapingPaths(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "evil.zip")
+	os.WriteFile(path, zipBytes(t, map[string]string{"../evil": "x"}), 0644)
+	if _, err := extractArchive(path, "zip", filepath.Join(dir, "out")); err == nil {
+		t.Error("expected an error for an entry outside the target")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "evil")); !os.IsNotExist(err) {
+		t.Error("escaping entry should not be written")
+	}
+}
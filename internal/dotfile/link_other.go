@@ -0,0 +1,15 @@
+//go:build !windows
+
+package dotfile
+
+import "os"
+
+// Symlink creates a symbolic link at target pointing to source.
+func Symlink(source, target string) error {
+	return os.Symlink(source, target)
+}
+
+// IsLink reports whether info describes a symbolic link.
+func IsLink(info os.FileInfo) bool {
+	return info.Mode()&os.ModeSymlink != 0
+}
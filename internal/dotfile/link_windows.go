@@ -0,0 +1,43 @@
+//go:build windows
+
+package dotfile
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+// errorPrivilegeNotHeld is ERROR_PRIVILEGE_NOT_HELD, returned when creating a
+// symlink without the SeCreateSymbolicLinkPrivilege (Developer Mode off and
+// not elevated).
+const errorPrivilegeNotHeld syscall.Errno = 1314
+
+// Symlink creates a symbolic link at target pointing to source. When the
+// process lacks the symlink privilege, directories are linked with a junction
+// (mklink /J), which needs no privilege. Files have no unprivileged
+// equivalent, so the error explains how to enable symlinks instead.
+func Symlink(source, target string) error {
+	err := os.Symlink(source, target)
+	if err == nil || !errors.Is(err, errorPrivilegeNotHeld) {
+		return err
+	}
+	info, statErr := os.Stat(source)
+	if statErr != nil || !info.IsDir() {
+		return fmt.Errorf("%w (enable Developer Mode or run as administrator to allow file symlinks)", err)
+	}
+	out, junctionErr := exec.Command("cmd", "/c", "mklink", "/J", target, source).CombinedOutput()
+	if junctionErr != nil {
+		return fmt.Errorf("%w; junction fallback failed: %v: %s", err, junctionErr, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// IsLink reports whether info describes a symbolic link or a junction.
+// Junctions are reported as irregular files rather than symlinks.
+func IsLink(info os.FileInfo) bool {
+	return info.Mode()&(os.ModeSymlink|os.ModeIrregular) != 0
+}
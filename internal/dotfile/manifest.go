@@ -0,0 +1,344 @@
+package dotfile
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/mad01/ralph/internal/config"
+)
+
+// Manifest records every dotfile apply has deployed, so they can be removed
+// again even after their entries have left the configuration.
+type Manifest struct {
+	Dotfiles  map[string]ManifestEntry `json:"dotfiles"`
+	LastApply *ApplyRecord             `json:"last_apply,omitempty"`
+}
+
+// ApplyRecord describes the last apply that saved the manifest.
+type ApplyRecord struct {
+	Version    string    `json:"version"`     // ralph version that applied
+	ConfigHash string    `json:"config_hash"` // config.Hash of the merged config it applied
+	AppliedAt  time.Time `json:"applied_at"`
+}
+
+// OlderThan reports whether the record was made by a ralph release at least
+// one minor version before current, e.g. 1.2.3 against 1.4.0. Versions that
+// are not MAJOR.MINOR[...] with an optional "v" (such as "dev") are never
+// older.
+func (r ApplyRecord) OlderThan(current string) bool {
+	recMajor, recMinor, ok := majorMinor(r.Version)
+	if !ok {
+		return false
+	}
+	curMajor, curMinor, ok := majorMinor(current)
+	if !ok {
+		return false
+	}
+	return recMajor < curMajor || (recMajor == curMajor && recMinor < curMinor)
+}
+
+// majorMinor parses the major and minor number of a version like "v1.4.0".
+func majorMinor(version string) (major, minor int, ok bool) {
+	parts := strings.SplitN(strings.TrimPrefix(version, "v"), ".", 3)
+	if len(parts) < 2 {
+		return 0, 0, false
+	}
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, false
+	}
+	minor, err = strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, 0, false
+	}
+	return major, minor, true
+}
+
+// ManifestEntry describes how a single dotfile was deployed.
+type ManifestEntry struct {
+	Action    string            `json:"action"`              // "symlink", "copy", "symlink_dir", "template_dir", "download", "extract", "append_block" or a plugin's action
+	Source    string            `json:"source"`              // Absolute source path in the dotfiles repo, or the URL of a download or archive
+	Target    string            `json:"target"`              // Absolute target path
+	Merge     bool              `json:"merge,omitempty"`     // symlink_dir entries were linked individually
+	Ignore    []string          `json:"ignore,omitempty"`    // Source paths left out, see config.IgnoredPath
+	Template  bool              `json:"template,omitempty"`  // Target was rendered from a template
+	Checksums map[string]string `json:"checksums,omitempty"` // SHA-256 of each deployed file, keyed by path relative to the target ("." for a file)
+	Archive   string            `json:"archive,omitempty"`   // extract only: SHA-256 of the archive the files came from
+	Block     string            `json:"block,omitempty"`     // append_block only: name of the block in the target
+	AppliedAt time.Time         `json:"applied_at"`
+}
+
+// getManifestFilePath returns the path to the manifest file
+func getManifestFilePath() (string, error) {
+	return config.StateFilePath("manifest")
+}
+
+// LoadManifest loads the manifest from the manifest file
+func LoadManifest() (*Manifest, error) {
+	manifestPath, err := getManifestFilePath()
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := &Manifest{
+		Dotfiles: make(map[string]ManifestEntry),
+	}
+
+	data, err := os.ReadFile(manifestPath)
+	if os.IsNotExist(err) {
+		return manifest, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+	if manifest.Dotfiles == nil {
+		manifest.Dotfiles = make(map[string]ManifestEntry)
+	}
+
+	return manifest, nil
+}
+
+// SaveManifest saves the manifest to the manifest file
+func SaveManifest(manifest *Manifest) error {
+	manifestPath, err := getManifestFilePath()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(manifestPath), 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal manifest: %w", err)
+	}
+
+	if err := os.WriteFile(manifestPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+
+	return nil
+}
+
+// ResetManifest deletes the manifest file
+func ResetManifest() error {
+	manifestPath, err := getManifestFilePath()
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(manifestPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove manifest: %w", err)
+	}
+	return nil
+}
+
+// NewManifestEntry builds the manifest entry for a dotfile deployed from
+// dotfilesRepoPath.
+func NewManifestEntry(df config.Dotfile, dotfilesRepoPath string) (ManifestEntry, error) {
+	absoluteSource, err := config.ExpandPath(filepath.Join(dotfilesRepoPath, df.Source))
+	if err != nil {
+		return ManifestEntry{}, fmt.Errorf("failed to expand source path '%s': %w", df.Source, err)
+	}
+	absoluteTarget, err := config.ExpandPath(df.Target)
+	if err != nil {
+		return ManifestEntry{}, fmt.Errorf("failed to expand target path '%s': %w", df.Target, err)
+	}
+	if df.URL != "" {
+		absoluteSource = df.URL
+	}
+	action := df.Action
+	if action == "" {
+		action = DefaultAction
+	}
+	entry := ManifestEntry{
+		Action:    action,
+		Source:    absoluteSource,
+		Target:    absoluteTarget,
+		Merge:     df.Merge,
+		Ignore:    df.Ignore,
+		Template:  df.IsTemplate || action == "template_dir",
+		AppliedAt: time.Now(),
+	}
+	if action == "extract" {
+		archive, err := entryArchive(entry)
+		if err != nil {
+			return ManifestEntry{}, err
+		}
+		sum, err := fileChecksum(archive)
+		if err != nil {
+			return ManifestEntry{}, fmt.Errorf("failed to hash archive '%s': %w", archive, err)
+		}
+		entry.Archive = hex.EncodeToString(sum)
+	}
+	if action == "append_block" {
+		entry.Block = filepath.ToSlash(df.Source)
+	}
+	return entry, nil
+}
+
+// RemoveDeployed removes what apply deployed for a manifest entry and restores
+// the newest backup it made of the previous target (see config.BackupPath). Symlinks are only removed
+// while they are still symlinks, copies only while they match their source,
+// downloads only while they match their recorded checksum, and template_dir
+// and extract remove only the files they wrote, pruning directories that end
+// up empty, and append_block removes only its block.
+// If dryRun is true, it will only print the actions it would take.
+func RemoveDeployed(w io.Writer, entry ManifestEntry, dryRun bool) error {
+	switch {
+	case entry.Action == "template_dir":
+		return removeRenderedDir(w, entry, dryRun)
+	case entry.Merge:
+		entries, err := os.ReadDir(entry.Source)
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to read source directory '%s': %w", entry.Source, err)
+		}
+		for _, e := range entries {
+			if config.IgnoredPath(entry.Ignore, e.Name(), e.IsDir()) {
+				continue
+			}
+			if err := removeTarget(w, filepath.Join(entry.Target, e.Name()), true, dryRun); err != nil {
+				return err
+			}
+		}
+		return nil
+	case entry.Action == "copy":
+		// A copy that no longer matches its source was edited (or was never
+		// ours, with --skip); keep it rather than lose data.
+		if !entry.Template {
+			if same, err := sameContent(entry.Source, entry.Target); err == nil && !same {
+				fmt.Fprintf(w, "    %s %s\n", color.CyanString("kept"), faint(config.ShortenHome(entry.Target)+" (differs from source)"))
+				return nil
+			}
+		}
+		return removeTarget(w, entry.Target, false, dryRun)
+	case entry.Action == "extract":
+		return removeExtracted(w, entry, dryRun)
+	case entry.Action == "append_block":
+		return removeBlock(w, entry, dryRun)
+	case entry.Action == "download":
+		// Keep a download that was edited since apply recorded its checksum.
+		if sum, err := fileChecksum(entry.Target); err == nil && entry.Checksums["."] != "" && hex.EncodeToString(sum) != entry.Checksums["."] {
+			fmt.Fprintf(w, "    %s %s\n", color.CyanString("kept"), faint(config.ShortenHome(entry.Target)+" (modified since download)"))
+			return nil
+		}
+		return removeTarget(w, entry.Target, false, dryRun)
+	default:
+		return removeTarget(w, entry.Target, true, dryRun)
+	}
+}
+
+// removeTarget removes a deployed file or symlink and restores its backup.
+// When symlinkOnly is set, anything that is no longer a symlink is kept.
+func removeTarget(w io.Writer, target string, symlinkOnly bool, dryRun bool) error {
+	info, err := os.Lstat(target)
+	if os.IsNotExist(err) {
+		return restoreBackup(w, target, dryRun)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to stat target '%s': %w", target, err)
+	}
+	if symlinkOnly && !IsLink(info) {
+		fmt.Fprintf(w, "    %s %s\n", color.CyanString("kept"), faint(config.ShortenHome(target)+" (no longer a symlink)"))
+		return nil
+	}
+	if !symlinkOnly && info.IsDir() {
+		fmt.Fprintf(w, "    %s %s\n", color.CyanString("kept"), faint(config.ShortenHome(target)+" (is a directory)"))
+		return nil
+	}
+
+	if dryRun {
+		fmt.Fprintf(w, "    %s would remove %s\n", color.CyanString("[dry run]"), faint(config.ShortenHome(target)))
+	} else {
+		if err := os.Remove(target); err != nil {
+			return fmt.Errorf("failed to remove '%s': %w", target, err)
+		}
+		fmt.Fprintf(w, "    %s %s\n", color.YellowString("removed"), faint(config.ShortenHome(target)))
+	}
+	return restoreBackup(w, target, dryRun)
+}
+
+// restoreBackup moves the newest backup of target back when the target is
+// free.
+func restoreBackup(w io.Writer, target string, dryRun bool) error {
+	backupPath, err := config.BackupPath(target, 0)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Lstat(backupPath); err != nil {
+		return nil
+	}
+	if dryRun {
+		fmt.Fprintf(w, "    %s would restore %s\n", color.CyanString("[dry run]"), faint(config.ShortenHome(backupPath)))
+		return nil
+	}
+	if _, err := os.Lstat(target); err == nil {
+		return nil
+	}
+	if err := os.Rename(backupPath, target); err != nil {
+		return fmt.Errorf("failed to restore backup '%s': %w", backupPath, err)
+	}
+	fmt.Fprintf(w, "    %s %s\n", color.GreenString("restored"), faint(config.ShortenHome(backupPath)))
+	return nil
+}
+
+// removeRenderedDir removes the files a template_dir entry rendered and prunes
+// directories left empty, deepest first.
+func removeRenderedDir(w io.Writer, entry ManifestEntry, dryRun bool) error {
+	dirs := []string{entry.Target}
+	err := filepath.WalkDir(entry.Source, func(path string, d fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		rel, err := filepath.Rel(entry.Source, path)
+		if err != nil {
+			return err
+		}
+		if rel != "." && config.IgnoredPath(entry.Ignore, rel, d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			if rel != "." {
+				dirs = append(dirs, filepath.Join(entry.Target, rel))
+			}
+			return nil
+		}
+		return removeTarget(w, filepath.Join(entry.Target, filepath.Dir(rel), renderedName(rel)), false, dryRun)
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if dryRun {
+		return nil
+	}
+
+	// Remove directories deepest first so parents can become empty.
+	sort.Slice(dirs, func(i, j int) bool {
+		return strings.Count(dirs[i], string(filepath.Separator)) > strings.Count(dirs[j], string(filepath.Separator))
+	})
+	for _, dir := range dirs {
+		if entries, err := os.ReadDir(dir); err == nil && len(entries) == 0 {
+			if err := os.Remove(dir); err != nil {
+				return fmt.Errorf("failed to remove directory '%s': %w", dir, err)
+			}
+		}
+	}
+	return restoreBackup(w, entry.Target, dryRun)
+}
@@ -0,0 +1,149 @@
+package dotfile
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mad01/ralph/internal/config"
+)
+
+func TestManifest_Roundtrip(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	manifest, err := LoadManifest()
+	if err != nil {
+		t.Fatalf("LoadManifest failed: %v", err)
+	}
+	if len(manifest.Dotfiles) != 0 {
+		t.Fatalf("expected empty manifest, got %v", manifest.Dotfiles)
+	}
+
+	entry, err := NewManifestEntry(config.Dotfile{Source: "zshrc", Target: "/home/u/.zshrc"}, "/repo")
+	if err != nil {
+		t.Fatalf("NewManifestEntry failed: %v", err)
+	}
+	if entry.Action != "symlink" || entry.Source != "/repo/zshrc" {
+		t.Errorf("unexpected entry: %+v", entry)
+	}
+	manifest.Dotfiles["zsh"] = entry
+	if err := SaveManifest(manifest); err != nil {
+		t.Fatalf("SaveManifest failed: %v", err)
+	}
+
+	loaded, err := LoadManifest()
+	if err != nil {
+		t.Fatalf("LoadManifest failed: %v", err)
+	}
+	if got := loaded.Dotfiles["zsh"]; got.Target != "/home/u/.zshrc" {
+		t.Errorf("loaded entry = %+v", got)
+	}
+
+	if err := ResetManifest(); err != nil {
+		t.Fatalf("ResetManifest failed: %v", err)
+	}
+	if loaded, _ := LoadManifest(); len(loaded.Dotfiles) != 0 {
+		t.Error("expected manifest to be empty after reset")
+	}
+}
+
+func TestRemoveDeployed_SymlinkRestoresBackup(t *testing.T) {
+	tempDir := t.TempDir()
+	source := filepath.Join(tempDir, "repo", "zshrc")
+	createDummyFile(t, source, "repo")
+	target := filepath.Join(tempDir, ".zshrc")
+	createDummyFile(t, target+".bak", "original")
+	if err := os.Symlink(source, target); err != nil {
+		t.Fatal(err)
+	}
+
+	entry := ManifestEntry{Action: "symlink", Source: source, Target: target}
+	if err := RemoveDeployed(io.Discard, entry, false); err != nil {
+		t.Fatalf("RemoveDeployed failed: %v", err)
+	}
+
+	info, err := os.Lstat(target)
+	if err != nil || info.Mode()&os.ModeSymlink != 0 {
+		t.Fatalf("expected backup to be restored as a regular file, got %v (err %v)", info, err)
+	}
+	if content, _ := os.ReadFile(target); string(content) != "original" {
+		t.Errorf("restored content = %q, want %q", content, "original")
+	}
+}
+
+func TestRemoveDeployed_KeepsReplacedSymlinkAndEditedCopy(t *testing.T) {
+	tempDir := t.TempDir()
+	source := filepath.Join(tempDir, "repo", "gitconfig")
+	createDummyFile(t, source, "repo")
+
+	replaced := filepath.Join(tempDir, ".zshrc")
+	createDummyFile(t, replaced, "user file")
+	if err := RemoveDeployed(io.Discard, ManifestEntry{Action: "symlink", Source: source, Target: replaced}, false); err != nil {
+		t.Fatalf("RemoveDeployed failed: %v", err)
+	}
+	if _, err := os.Stat(replaced); err != nil {
+		t.Error("regular file replacing a symlink must be kept")
+	}
+
+	edited := filepath.Join(tempDir, ".gitconfig")
+	createDummyFile(t, edited, "edited")
+	if err := RemoveDeployed(io.Discard, ManifestEntry{Action: "copy", Source: source, Target: edited}, false); err != nil {
+		t.Fatalf("RemoveDeployed failed: %v", err)
+	}
+	if _, err := os.Stat(edited); err != nil {
+		t.Error("copy that differs from its source must be kept")
+	}
+
+	unchanged := filepath.Join(tempDir, ".gitconfig2")
+	createDummyFile(t, unchanged, "repo")
+	if err := RemoveDeployed(io.Discard, ManifestEntry{Action: "copy", Source: source, Target: unchanged}, false); err != nil {
+		t.Fatalf("RemoveDeployed failed: %v", err)
+	}
+	if _, err := os.Stat(unchanged); !os.IsNotExist(err) {
+		t.Error("unchanged copy should be removed")
+	}
+}
+
+func TestRemoveDeployed_TemplateDir(t *testing.T) {
+	cfg, target := setupTemplateDir(t)
+	df := config.Dotfile{Source: "conf", Target: target, Action: "template_dir"}
+	if err := RenderTemplateDir(io.Discard, df, cfg, nil, SymlinkActionBackup, false); err != nil {
+		t.Fatalf("RenderTemplateDir failed: %v", err)
+	}
+	createDummyFile(t, filepath.Join(target, "sub", "foreign.txt"), "not ours")
+
+	entry, err := NewManifestEntry(df, cfg.DotfilesRepoPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := RemoveDeployed(io.Discard, entry, false); err != nil {
+		t.Fatalf("RemoveDeployed failed: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(target, "main.conf")); !os.IsNotExist(err) {
+		t.Error("rendered file should be removed")
+	}
+	if _, err := os.Stat(filepath.Join(target, "sub", "foreign.txt")); err != nil {
+		t.Error("foreign file should be kept")
+	}
+}
+
+func TestApplyRecord_OlderThan(t *testing.T) {
+	tests := []struct {
+		recorded, current string
+		want              bool
+	}{
+		{"1.2.3", "1.4.0", true},
+		{"v0.9.0", "v1.0.0", true},
+		{"1.4.0", "1.4.7", false},
+		{"1.5.0", "1.4.0", false},
+		{"dev", "1.4.0", false},
+		{"1.2.0", "dev", false},
+	}
+	for _, tt := range tests {
+		if got := (ApplyRecord{Version: tt.recorded}).OlderThan(tt.current); got != tt.want {
+			t.Errorf("ApplyRecord{%q}.OlderThan(%q) = %v, want %v", tt.recorded, tt.current, got, tt.want)
+		}
+	}
+}
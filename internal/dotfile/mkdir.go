@@ -1,53 +1,250 @@
 package dotfile
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"strconv"
 
 	"github.com/fatih/color"
 	"github.com/mad01/ralph/internal/config"
 )
 
+// DirectoryState tracks directories created by ralph with remove_on_disable,
+// so they can be cleaned up once their entry is disabled or removed.
+type DirectoryState struct {
+	Directories map[string]DirectoryRecord `json:"directories"`
+}
+
+// DirectoryRecord lists the paths ralph created for a directory entry,
+// deepest first.
+type DirectoryRecord struct {
+	Target  string   `json:"target"`
+	Created []string `json:"created"`
+}
+
+// getDirectoryStateFilePath returns the path to the directories state file
+func getDirectoryStateFilePath() (string, error) {
+	return config.StateFilePath("directories_state")
+}
+
+// LoadDirectoryState loads the directory state from the state file
+func LoadDirectoryState() (*DirectoryState, error) {
+	statePath, err := getDirectoryStateFilePath()
+	if err != nil {
+		return nil, err
+	}
+
+	state := &DirectoryState{
+		Directories: make(map[string]DirectoryRecord),
+	}
+
+	data, err := os.ReadFile(statePath)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state file: %w", err)
+	}
+
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("failed to parse state file: %w", err)
+	}
+	if state.Directories == nil {
+		state.Directories = make(map[string]DirectoryRecord)
+	}
+
+	return state, nil
+}
+
+// SaveDirectoryState saves the directory state to the state file
+func SaveDirectoryState(state *DirectoryState) error {
+	statePath, err := getDirectoryStateFilePath()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(statePath), 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal state: %w", err)
+	}
+
+	if err := os.WriteFile(statePath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+
+	return nil
+}
+
+// ResetDirectoryState deletes the directory state file
+func ResetDirectoryState() error {
+	statePath, err := getDirectoryStateFilePath()
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(statePath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove state file: %w", err)
+	}
+	return nil
+}
+
+// parseDirMode parses an octal mode string, defaulting to 0755.
+func parseDirMode(s string) (os.FileMode, error) {
+	if s == "" {
+		return 0755, nil
+	}
+	parsed, err := strconv.ParseUint(s, 8, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid mode '%s': %w", s, err)
+	}
+	return os.FileMode(parsed), nil
+}
+
 // CreateDirectory creates a directory at the specified target path.
 // If dryRun is true, it will only print the actions it would take.
 func CreateDirectory(w io.Writer, dir config.Directory, dryRun bool) error {
+	_, err := EnsureDirectory(w, dir, dryRun)
+	return err
+}
+
+// EnsureDirectory creates a directory and returns the paths it created,
+// deepest first. The configured mode is applied exactly (ignoring the umask)
+// to the target, and to every missing parent when dir.Recursive is set;
+// otherwise missing parents are created with mode 0755. An existing target
+// has its mode corrected when dir.Mode is set and differs.
+// If dryRun is true, it will only print the actions it would take.
+func EnsureDirectory(w io.Writer, dir config.Directory, dryRun bool) ([]string, error) {
 	absoluteTarget, err := config.ExpandPath(dir.Target)
 	if err != nil {
-		return fmt.Errorf("failed to expand target path '%s': %w", dir.Target, err)
+		return nil, fmt.Errorf("failed to expand target path '%s': %w", dir.Target, err)
 	}
 
-	// Parse mode, default to 0755
-	mode := os.FileMode(0755)
-	if dir.Mode != "" {
-		parsed, err := strconv.ParseUint(dir.Mode, 8, 32)
-		if err != nil {
-			return fmt.Errorf("invalid mode '%s': %w", dir.Mode, err)
-		}
-		mode = os.FileMode(parsed)
+	mode, err := parseDirMode(dir.Mode)
+	if err != nil {
+		return nil, err
 	}
 
 	// Check if directory already exists
 	info, err := os.Stat(absoluteTarget)
 	if err == nil {
-		if info.IsDir() {
-			fmt.Fprintf(w, "    %s\n", color.GreenString("already exists"))
-			return nil
+		if !info.IsDir() {
+			return nil, fmt.Errorf("target '%s' exists but is not a directory", absoluteTarget)
 		}
-		return fmt.Errorf("target '%s' exists but is not a directory", absoluteTarget)
+		if dir.Mode != "" && info.Mode().Perm() != mode {
+			if dryRun {
+				fmt.Fprintf(w, "    %s would change %s\n", color.CyanString("[dry run]"), faint(fmt.Sprintf("mode %04o → %04o", info.Mode().Perm(), mode)))
+				return nil, nil
+			}
+			if err := os.Chmod(absoluteTarget, mode); err != nil {
+				return nil, fmt.Errorf("failed to set mode on '%s': %w", absoluteTarget, err)
+			}
+			fmt.Fprintf(w, "    %s %s\n", color.YellowString("mode updated"), faint(fmt.Sprintf("%04o → %04o", info.Mode().Perm(), mode)))
+			return nil, nil
+		}
+		fmt.Fprintf(w, "    %s\n", color.GreenString("already exists"))
+		return nil, nil
 	} else if !os.IsNotExist(err) {
-		return fmt.Errorf("failed to stat target '%s': %w", absoluteTarget, err)
+		return nil, fmt.Errorf("failed to stat target '%s': %w", absoluteTarget, err)
+	}
+
+	// Collect the missing path components, deepest first.
+	var missing []string
+	for p := absoluteTarget; ; p = filepath.Dir(p) {
+		if _, err := os.Stat(p); err == nil {
+			break
+		} else if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to stat '%s': %w", p, err)
+		}
+		missing = append(missing, p)
+		if filepath.Dir(p) == p {
+			break
+		}
 	}
 
 	if dryRun {
 		fmt.Fprintf(w, "    %s would create %s\n", color.CyanString("[dry run]"), faint(fmt.Sprintf("mode %04o", mode)))
-	} else {
-		fmt.Fprintf(w, "    %s %s\n", color.GreenString("created"), faint(fmt.Sprintf("mode %04o", mode)))
-		if err := os.MkdirAll(absoluteTarget, mode); err != nil {
-			return fmt.Errorf("failed to create directory '%s': %w", absoluteTarget, err)
+		return missing, nil
+	}
+
+	fmt.Fprintf(w, "    %s %s\n", color.GreenString("created"), faint(fmt.Sprintf("mode %04o", mode)))
+	for i := len(missing) - 1; i >= 0; i-- {
+		p := missing[i]
+		pathMode := os.FileMode(0755)
+		if i == 0 || dir.Recursive {
+			pathMode = mode
+		}
+		if err := os.Mkdir(p, pathMode); err != nil && !os.IsExist(err) {
+			return missing[i+1:], fmt.Errorf("failed to create directory '%s': %w", p, err)
+		}
+		if err := os.Chmod(p, pathMode); err != nil {
+			return missing[i:], fmt.Errorf("failed to set mode on '%s': %w", p, err)
 		}
 	}
 
-	return nil
+	return missing, nil
+}
+
+// DirectoryInSync reports whether a directory exists with its configured
+// mode, i.e. whether EnsureDirectory would leave it unchanged.
+func DirectoryInSync(dir config.Directory) (bool, error) {
+	absoluteTarget, err := config.ExpandPath(dir.Target)
+	if err != nil {
+		return false, fmt.Errorf("failed to expand target path '%s': %w", dir.Target, err)
+	}
+	mode, err := parseDirMode(dir.Mode)
+	if err != nil {
+		return false, err
+	}
+	info, err := os.Stat(absoluteTarget)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to stat target '%s': %w", absoluteTarget, err)
+	}
+	return info.IsDir() && (dir.Mode == "" || info.Mode().Perm() == mode), nil
+}
+
+// RemoveCreatedDirectories removes the directories recorded for an entry,
+// deepest first, stopping at the first one that is not empty. Paths that no
+// longer exist are ignored. It reports whether every recorded path is gone.
+// If dryRun is true, it will only print the actions it would take.
+func RemoveCreatedDirectories(w io.Writer, record DirectoryRecord, dryRun bool) (bool, error) {
+	removed := make(map[string]bool) // paths removed so far (or that would be, in a dry run)
+	for _, p := range record.Created {
+		entries, err := os.ReadDir(p)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return false, fmt.Errorf("failed to read directory '%s': %w", p, err)
+		}
+		remaining := 0
+		for _, e := range entries {
+			if !removed[filepath.Join(p, e.Name())] {
+				remaining++
+			}
+		}
+		if remaining > 0 {
+			fmt.Fprintf(w, "    %s %s\n", color.CyanString("kept"), faint(config.ShortenHome(p)+" (not empty)"))
+			return false, nil
+		}
+		if dryRun {
+			fmt.Fprintf(w, "    %s would remove %s\n", color.CyanString("[dry run]"), faint(config.ShortenHome(p)))
+			removed[p] = true
+			continue
+		}
+		if err := os.Remove(p); err != nil {
+			return false, fmt.Errorf("failed to remove directory '%s': %w", p, err)
+		}
+		fmt.Fprintf(w, "    %s %s\n", color.YellowString("removed"), faint(config.ShortenHome(p)))
+		removed[p] = true
+	}
+	return true, nil
 }
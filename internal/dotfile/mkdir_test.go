@@ -0,0 +1,150 @@
+package dotfile
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mad01/ralph/internal/config"
+)
+
+func TestEnsureDirectory_Modes(t *testing.T) {
+	base := t.TempDir()
+	target := filepath.Join(base, "a", "b", "c")
+
+	created, err := EnsureDirectory(io.Discard, config.Directory{Target: target, Mode: "0700"}, false)
+	if err != nil {
+		t.Fatalf("EnsureDirectory failed: %v", err)
+	}
+	want := []string{target, filepath.Join(base, "a", "b"), filepath.Join(base, "a")}
+	if len(created) != len(want) {
+		t.Fatalf("created = %v, want %v", created, want)
+	}
+	for i := range want {
+		if created[i] != want[i] {
+			t.Errorf("created[%d] = %s, want %s", i, created[i], want[i])
+		}
+	}
+
+	if info, _ := os.Stat(target); info.Mode().Perm() != 0700 {
+		t.Errorf("target mode = %04o, want 0700", info.Mode().Perm())
+	}
+	if info, _ := os.Stat(filepath.Join(base, "a")); info.Mode().Perm() != 0755 {
+		t.Errorf("non-recursive parent mode = %04o, want 0755", info.Mode().Perm())
+	}
+}
+
+func TestEnsureDirectory_Recursive(t *testing.T) {
+	base := t.TempDir()
+	target := filepath.Join(base, "a", "b")
+
+	if _, err := EnsureDirectory(io.Discard, config.Directory{Target: target, Mode: "0700", Recursive: true}, false); err != nil {
+		t.Fatalf("EnsureDirectory failed: %v", err)
+	}
+	for _, p := range []string{target, filepath.Join(base, "a")} {
+		if info, _ := os.Stat(p); info.Mode().Perm() != 0700 {
+			t.Errorf("%s mode = %04o, want 0700", p, info.Mode().Perm())
+		}
+	}
+}
+
+func TestEnsureDirectory_FixesExistingMode(t *testing.T) {
+	target := filepath.Join(t.TempDir(), "secrets")
+	if err := os.Mkdir(target, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	created, err := EnsureDirectory(io.Discard, config.Directory{Target: target, Mode: "0700"}, false)
+	if err != nil {
+		t.Fatalf("EnsureDirectory failed: %v", err)
+	}
+	if len(created) != 0 {
+		t.Errorf("expected nothing to be created, got %v", created)
+	}
+	if info, _ := os.Stat(target); info.Mode().Perm() != 0700 {
+		t.Errorf("mode = %04o, want 0700", info.Mode().Perm())
+	}
+}
+
+func TestDirectoryInSync(t *testing.T) {
+	dir := config.Directory{Target: filepath.Join(t.TempDir(), "secrets"), Mode: "0700"}
+	if ok, err := DirectoryInSync(dir); err != nil || ok {
+		t.Fatalf("missing directory = (%v, %v), want (false, nil)", ok, err)
+	}
+	if err := os.Mkdir(dir.Target, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := DirectoryInSync(dir); ok {
+		t.Error("directory with the wrong mode should not be in sync")
+	}
+	if err := os.Chmod(dir.Target, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := DirectoryInSync(dir); err != nil || !ok {
+		t.Errorf("directory = (%v, %v), want (true, nil)", ok, err)
+	}
+}
+
+func TestRemoveCreatedDirectories(t *testing.T) {
+	base := t.TempDir()
+	target := filepath.Join(base, "a", "b")
+	created, err := EnsureDirectory(io.Discard, config.Directory{Target: target}, false)
+	if err != nil {
+		t.Fatalf("EnsureDirectory failed: %v", err)
+	}
+	record := DirectoryRecord{Target: target, Created: created}
+
+	// A dry run reports success without touching anything.
+	if gone, err := RemoveCreatedDirectories(io.Discard, record, true); err != nil || !gone {
+		t.Fatalf("dry run = (%v, %v), want (true, nil)", gone, err)
+	}
+	if _, err := os.Stat(target); err != nil {
+		t.Fatal("dry run removed the directory")
+	}
+
+	// Non-empty directories are kept.
+	createDummyFile(t, filepath.Join(target, "keep.txt"), "data")
+	if gone, err := RemoveCreatedDirectories(io.Discard, record, false); err != nil || gone {
+		t.Fatalf("non-empty = (%v, %v), want (false, nil)", gone, err)
+	}
+	if _, err := os.Stat(target); err != nil {
+		t.Fatal("non-empty directory was removed")
+	}
+
+	os.Remove(filepath.Join(target, "keep.txt"))
+	if gone, err := RemoveCreatedDirectories(io.Discard, record, false); err != nil || !gone {
+		t.Fatalf("empty = (%v, %v), want (true, nil)", gone, err)
+	}
+	if _, err := os.Stat(filepath.Join(base, "a")); !os.IsNotExist(err) {
+		t.Error("expected the created parent to be removed too")
+	}
+	if _, err := os.Stat(base); err != nil {
+		t.Error("pre-existing base directory must not be removed")
+	}
+}
+
+func TestDirectoryState_Roundtrip(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	state, err := LoadDirectoryState()
+	if err != nil {
+		t.Fatalf("LoadDirectoryState failed: %v", err)
+	}
+	if len(state.Directories) != 0 {
+		t.Fatalf("expected empty state, got %v", state.Directories)
+	}
+
+	state.Directories["cache"] = DirectoryRecord{Target: "~/.cache/app", Created: []string{"/x/.cache/app"}}
+	if err := SaveDirectoryState(state); err != nil {
+		t.Fatalf("SaveDirectoryState failed: %v", err)
+	}
+
+	loaded, err := LoadDirectoryState()
+	if err != nil {
+		t.Fatalf("LoadDirectoryState failed: %v", err)
+	}
+	if got := loaded.Directories["cache"]; got.Target != "~/.cache/app" || len(got.Created) != 1 {
+		t.Errorf("loaded record = %+v", got)
+	}
+}
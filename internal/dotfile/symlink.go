@@ -22,6 +22,16 @@ const (
 	SymlinkActionSkip
 )
 
+// ExistingFor returns what to do with a target in the way of df when the
+// run as a whole does action: a dotfile with backup = false has the target
+// replaced rather than backed up. Skipping is left as it is.
+func ExistingFor(df config.Dotfile, action SymlinkAction) SymlinkAction {
+	if action == SymlinkActionBackup && df.Backup != nil && !*df.Backup {
+		return SymlinkActionOverwrite
+	}
+	return action
+}
+
 var (
 	faint = color.New(color.Faint).SprintFunc()
 )
@@ -58,16 +68,19 @@ func CreateSymlink(w io.Writer, dotfileCfg config.Dotfile, dotfilesRepoPath stri
 
 	targetInfo, err := os.Lstat(absoluteTarget)
 	if err == nil {
+		// Leave a link that already points at the source alone, whatever the
+		// action, so re-applying doesn't churn it or leave a .bak behind
+		if IsLink(targetInfo) {
+			linkTarget, readErr := os.Readlink(absoluteTarget)
+			if readErr == nil && config.SamePath(linkTarget, absoluteSource) {
+				fmt.Fprintf(w, "    %s\n", color.GreenString("already linked"))
+				return nil
+			}
+		}
 		switch action {
 		case SymlinkActionBackup:
-			backupPath := absoluteTarget + ".bak"
-			if dryRun {
-				fmt.Fprintf(w, "    %s would back up %s %s\n", color.CyanString("[dry run]"), faint("→"), faint(config.ShortenHome(backupPath)))
-			} else {
-				fmt.Fprintf(w, "    %s %s %s\n", color.YellowString("backed up"), faint("→"), faint(config.ShortenHome(backupPath)))
-				if err := os.Rename(absoluteTarget, backupPath); err != nil {
-					return fmt.Errorf("failed to backup '%s' to '%s': %w", absoluteTarget, backupPath, err)
-				}
+			if err := backupTarget(w, absoluteTarget, "", dryRun); err != nil {
+				return err
 			}
 		case SymlinkActionOverwrite:
 			if dryRun {
@@ -79,13 +92,6 @@ func CreateSymlink(w io.Writer, dotfileCfg config.Dotfile, dotfilesRepoPath stri
 				}
 			}
 		case SymlinkActionSkip:
-			if targetInfo.Mode()&os.ModeSymlink != 0 {
-				linkTarget, readErr := os.Readlink(absoluteTarget)
-				if readErr == nil && linkTarget == absoluteSource {
-					fmt.Fprintf(w, "    %s\n", color.GreenString("already linked"))
-					return nil
-				}
-			}
 			fmt.Fprintf(w, "    %s %s\n", color.CyanString("skipped"), faint("target exists"))
 			return nil
 		default:
@@ -103,7 +109,7 @@ func CreateSymlink(w io.Writer, dotfileCfg config.Dotfile, dotfilesRepoPath stri
 			return fmt.Errorf("failed to create target directory '%s': %w", targetDir, err)
 		}
 		fmt.Fprintf(w, "    %s\n", color.GreenString("linked"))
-		if err := os.Symlink(absoluteSource, absoluteTarget); err != nil {
+		if err := Symlink(absoluteSource, absoluteTarget); err != nil {
 			return fmt.Errorf("failed to create symlink from '%s' to '%s': %w", absoluteSource, absoluteTarget, err)
 		}
 	}
@@ -152,10 +158,10 @@ func CreateDirSymlink(w io.Writer, dotfileCfg config.Dotfile, dotfilesRepoPath s
 	targetInfo, err := os.Lstat(absoluteTarget)
 	if err == nil {
 		// Target exists - check what it is
-		if targetInfo.Mode()&os.ModeSymlink != 0 {
+		if IsLink(targetInfo) {
 			// It's a symlink - check if it points to our source
 			linkTarget, readErr := os.Readlink(absoluteTarget)
-			if readErr == nil && linkTarget == absoluteSource {
+			if readErr == nil && config.SamePath(linkTarget, absoluteSource) {
 				fmt.Fprintf(w, "    %s\n", color.GreenString("already linked"))
 				return nil
 			}
@@ -196,7 +202,7 @@ func CreateDirSymlink(w io.Writer, dotfileCfg config.Dotfile, dotfilesRepoPath s
 			return fmt.Errorf("failed to create target directory '%s': %w", targetDir, err)
 		}
 		fmt.Fprintf(w, "    %s\n", color.GreenString("linked"))
-		if err := os.Symlink(absoluteSource, absoluteTarget); err != nil {
+		if err := Symlink(absoluteSource, absoluteTarget); err != nil {
 			return fmt.Errorf("failed to create symlink from '%s' to '%s': %w", absoluteSource, absoluteTarget, err)
 		}
 	}
@@ -208,14 +214,8 @@ func CreateDirSymlink(w io.Writer, dotfileCfg config.Dotfile, dotfilesRepoPath s
 func handleExistingTarget(w io.Writer, absoluteTarget string, action SymlinkAction, dryRun bool) error {
 	switch action {
 	case SymlinkActionBackup:
-		backupPath := absoluteTarget + ".bak"
-		if dryRun {
-			fmt.Fprintf(w, "    %s would back up %s %s\n", color.CyanString("[dry run]"), faint("→"), faint(config.ShortenHome(backupPath)))
-		} else {
-			fmt.Fprintf(w, "    %s %s %s\n", color.YellowString("backed up"), faint("→"), faint(config.ShortenHome(backupPath)))
-			if err := os.Rename(absoluteTarget, backupPath); err != nil {
-				return fmt.Errorf("failed to backup '%s' to '%s': %w", absoluteTarget, backupPath, err)
-			}
+		if err := backupTarget(w, absoluteTarget, "", dryRun); err != nil {
+			return err
 		}
 	case SymlinkActionOverwrite:
 		if dryRun {
@@ -236,14 +236,8 @@ func handleExistingTarget(w io.Writer, absoluteTarget string, action SymlinkActi
 func handleExistingDirTarget(w io.Writer, absoluteTarget string, action SymlinkAction, dryRun bool) error {
 	switch action {
 	case SymlinkActionBackup:
-		backupPath := absoluteTarget + ".bak"
-		if dryRun {
-			fmt.Fprintf(w, "    %s would back up directory %s %s\n", color.CyanString("[dry run]"), faint("→"), faint(config.ShortenHome(backupPath)))
-		} else {
-			fmt.Fprintf(w, "    %s %s %s\n", color.YellowString("backed up directory"), faint("→"), faint(config.ShortenHome(backupPath)))
-			if err := os.Rename(absoluteTarget, backupPath); err != nil {
-				return fmt.Errorf("failed to backup '%s' to '%s': %w", absoluteTarget, backupPath, err)
-			}
+		if err := backupTarget(w, absoluteTarget, " directory", dryRun); err != nil {
+			return err
 		}
 	case SymlinkActionOverwrite:
 		if dryRun {
@@ -259,3 +253,75 @@ func handleExistingDirTarget(w io.Writer, absoluteTarget string, action SymlinkA
 	}
 	return nil
 }
+
+// MergeDirSymlinks links each top-level entry of a source directory into the
+// target directory individually, leaving unrelated files in the target alone.
+// This is the merge = true variant of CreateDirSymlink. An existing symlink or
+// file at the target itself is handled according to action and replaced by a
+// real directory; entries that collide with existing files are handled per entry.
+// Entries matching the dotfile's ignore patterns are not linked.
+// If dryRun is true, it will only print the actions it would take.
+func MergeDirSymlinks(w io.Writer, dotfileCfg config.Dotfile, dotfilesRepoPath string, action SymlinkAction, dryRun bool) error {
+	var absoluteSource string
+	var err error
+
+	if dotfilesRepoPath == "" {
+		absoluteSource = dotfileCfg.Source
+	} else {
+		absoluteSource, err = config.ExpandPath(filepath.Join(dotfilesRepoPath, dotfileCfg.Source))
+		if err != nil {
+			return fmt.Errorf("failed to expand source path '%s' relative to '%s': %w", dotfileCfg.Source, dotfilesRepoPath, err)
+		}
+	}
+
+	absoluteTarget, err := config.ExpandPath(dotfileCfg.Target)
+	if err != nil {
+		return fmt.Errorf("failed to expand target path '%s': %w", dotfileCfg.Target, err)
+	}
+
+	entries, err := os.ReadDir(absoluteSource)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("source directory '%s' (expanded: '%s') does not exist", dotfileCfg.Source, absoluteSource)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read source directory '%s': %w", absoluteSource, err)
+	}
+
+	// The target must be a real directory to merge into.
+	targetInfo, err := os.Lstat(absoluteTarget)
+	if err == nil && (IsLink(targetInfo) || !targetInfo.IsDir()) {
+		if err := handleExistingTarget(w, absoluteTarget, action, dryRun); err != nil {
+			return err
+		}
+		if action == SymlinkActionSkip {
+			return nil
+		}
+	} else if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to stat target '%s': %w", absoluteTarget, err)
+	}
+	if !dryRun {
+		if err := os.MkdirAll(absoluteTarget, 0755); err != nil {
+			return fmt.Errorf("failed to create target directory '%s': %w", absoluteTarget, err)
+		}
+	}
+
+	for _, entry := range entries {
+		if config.IgnoredPath(dotfileCfg.Ignore, entry.Name(), entry.IsDir()) {
+			continue
+		}
+		entrySource := filepath.Join(absoluteSource, entry.Name())
+		entryTarget := filepath.Join(absoluteTarget, entry.Name())
+
+		fmt.Fprintf(w, "    %s\n", faint(entry.Name()))
+		if linkTarget, err := os.Readlink(entryTarget); err == nil && config.SamePath(linkTarget, entrySource) {
+			fmt.Fprintf(w, "    %s\n", color.GreenString("already linked"))
+			continue
+		}
+		entryCfg := config.Dotfile{Source: entrySource, Target: entryTarget}
+		if err := CreateSymlink(w, entryCfg, "", action, dryRun); err != nil {
+			return fmt.Errorf("failed to link '%s': %w", entry.Name(), err)
+		}
+	}
+
+	return nil
+}
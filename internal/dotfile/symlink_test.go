@@ -189,6 +189,35 @@ func TestCreateSymlink_TargetExists_BackupAction(t *testing.T) {
 	}
 }
 
+func TestCreateSymlink_AlreadyLinked(t *testing.T) {
+	tempDir := t.TempDir()
+	dotfilesRepo := filepath.Join(tempDir, "repo")
+	sourcePath := filepath.Join(dotfilesRepo, "source.txt")
+	createDummyFile(t, sourcePath, "source content")
+	targetFilePath := filepath.Join(tempDir, "target.txt")
+	if err := os.Symlink(sourcePath, targetFilePath); err != nil {
+		t.Fatal(err)
+	}
+	df := config.Dotfile{Source: "source.txt", Target: targetFilePath}
+
+	for _, action := range []SymlinkAction{SymlinkActionBackup, SymlinkActionOverwrite, SymlinkActionSkip} {
+		before, _ := os.Lstat(targetFilePath)
+		var out strings.Builder
+		if err := CreateSymlink(&out, df, dotfilesRepo, action, false); err != nil {
+			t.Fatalf("action %d: %v", action, err)
+		}
+		if !strings.Contains(out.String(), "already linked") {
+			t.Errorf("action %d output = %q, want 'already linked'", action, out.String())
+		}
+		if after, _ := os.Lstat(targetFilePath); !os.SameFile(before, after) {
+			t.Errorf("action %d recreated a correct link", action)
+		}
+	}
+	if _, err := os.Lstat(targetFilePath + ".bak"); !os.IsNotExist(err) {
+		t.Error("a correct link should not be backed up")
+	}
+}
+
 func TestCreateSymlink_TargetExists_OverwriteAction(t *testing.T) {
 	tempDir := t.TempDir()
 	dotfilesRepo := filepath.Join(tempDir, "repo")
@@ -252,3 +281,140 @@ func TestCreateSymlink_AbsoluteSourcePath(t *testing.T) {
 		t.Errorf("Symlink for absolute source at %s points to %s, expected %s", targetPath, linkDest, df.Source)
 	}
 }
+
+func TestMergeDirSymlinks_PreservesForeignFiles(t *testing.T) {
+	tempDir := t.TempDir()
+	dotfilesRepo := filepath.Join(tempDir, "repo")
+	createDummyFile(t, filepath.Join(dotfilesRepo, "fish", "config.fish"), "set -x EDITOR nvim")
+	createDummyFile(t, filepath.Join(dotfilesRepo, "fish", "functions", "ll.fish"), "function ll; end")
+
+	target := filepath.Join(tempDir, "config", "fish")
+	createDummyFile(t, filepath.Join(target, "fish_variables"), "SETUVAR foo")
+
+	df := config.Dotfile{Source: "fish", Target: target, Action: "symlink_dir", Merge: true}
+	if err := MergeDirSymlinks(io.Discard, df, dotfilesRepo, SymlinkActionBackup, false); err != nil {
+		t.Fatalf("MergeDirSymlinks failed: %v", err)
+	}
+
+	for _, name := range []string{"config.fish", "functions"} {
+		linkDest, err := os.Readlink(filepath.Join(target, name))
+		if err != nil {
+			t.Errorf("expected %s to be a symlink: %v", name, err)
+			continue
+		}
+		if want := filepath.Join(dotfilesRepo, "fish", name); linkDest != want {
+			t.Errorf("%s links to %s, want %s", name, linkDest, want)
+		}
+	}
+	if content, err := os.ReadFile(filepath.Join(target, "fish_variables")); err != nil || string(content) != "SETUVAR foo" {
+		t.Errorf("foreign file was not preserved: %q, %v", content, err)
+	}
+
+	// A second run must be a no-op rather than backing up the existing links.
+	if err := MergeDirSymlinks(io.Discard, df, dotfilesRepo, SymlinkActionBackup, false); err != nil {
+		t.Fatalf("second MergeDirSymlinks failed: %v", err)
+	}
+	if _, err := os.Lstat(filepath.Join(target, "config.fish.bak")); !os.IsNotExist(err) {
+		t.Error("already linked entries should not be backed up")
+	}
+}
+
+func TestMergeDirSymlinks_Ignore(t *testing.T) {
+	tempDir := t.TempDir()
+	dotfilesRepo := filepath.Join(tempDir, "repo")
+	createDummyFile(t, filepath.Join(dotfilesRepo, "nvim", "init.lua"), "-- init")
+	createDummyFile(t, filepath.Join(dotfilesRepo, "nvim", "spell", "en.utf-8.add"), "ralph")
+	createDummyFile(t, filepath.Join(dotfilesRepo, "nvim", "plugin.pyc"), "cache")
+
+	target := filepath.Join(tempDir, "config", "nvim")
+	df := config.Dotfile{Source: "nvim", Target: target, Action: "symlink_dir", Merge: true, Ignore: []string{"*.pyc", "spell/"}}
+	if err := MergeDirSymlinks(io.Discard, df, dotfilesRepo, SymlinkActionBackup, false); err != nil {
+		t.Fatalf("MergeDirSymlinks failed: %v", err)
+	}
+
+	if _, err := os.Readlink(filepath.Join(target, "init.lua")); err != nil {
+		t.Errorf("expected init.lua to be linked: %v", err)
+	}
+	for _, name := range []string{"spell", "plugin.pyc"} {
+		if _, err := os.Lstat(filepath.Join(target, name)); !os.IsNotExist(err) {
+			t.Errorf("ignored %s should not be linked", name)
+		}
+	}
+
+	cfg := &config.Config{DotfilesRepoPath: dotfilesRepo}
+	if inSync, err := InSync(df, cfg); err != nil || !inSync {
+		t.Errorf("InSync() = %v, %v; ignored entries should not count", inSync, err)
+	}
+	entry, err := NewManifestEntry(df, dotfilesRepo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sums, err := DeployedChecksums(entry)
+	if err != nil {
+		t.Fatalf("DeployedChecksums() error = %v", err)
+	}
+	if len(sums) != 1 || sums["init.lua"] == "" {
+		t.Errorf("checksums = %v, want only init.lua", sums)
+	}
+}
+
+func TestMergeDirSymlinks_ReplacesDirSymlink(t *testing.T) {
+	tempDir := t.TempDir()
+	dotfilesRepo := filepath.Join(tempDir, "repo")
+	createDummyFile(t, filepath.Join(dotfilesRepo, "kitty", "kitty.conf"), "font_size 12")
+
+	target := filepath.Join(tempDir, "kitty")
+	if err := os.Symlink(filepath.Join(dotfilesRepo, "kitty"), target); err != nil {
+		t.Fatal(err)
+	}
+
+	df := config.Dotfile{Source: "kitty", Target: target, Action: "symlink_dir", Merge: true}
+	if err := MergeDirSymlinks(io.Discard, df, dotfilesRepo, SymlinkActionOverwrite, false); err != nil {
+		t.Fatalf("MergeDirSymlinks failed: %v", err)
+	}
+
+	info, err := os.Lstat(target)
+	if err != nil || !info.IsDir() {
+		t.Fatalf("expected target to become a real directory, got %v (err %v)", info, err)
+	}
+	if _, err := os.Readlink(filepath.Join(target, "kitty.conf")); err != nil {
+		t.Errorf("expected kitty.conf to be linked: %v", err)
+	}
+}
+
+func TestExistingFor(t *testing.T) {
+	off, on := false, true
+	tests := []struct {
+		backup *bool
+		action SymlinkAction
+		want   SymlinkAction
+	}{
+		{nil, SymlinkActionBackup, SymlinkActionBackup},
+		{&on, SymlinkActionBackup, SymlinkActionBackup},
+		{&off, SymlinkActionBackup, SymlinkActionOverwrite},
+		{&off, SymlinkActionSkip, SymlinkActionSkip},
+		{&off, SymlinkActionOverwrite, SymlinkActionOverwrite},
+	}
+	for _, tt := range tests {
+		if got := ExistingFor(config.Dotfile{Backup: tt.backup}, tt.action); got != tt.want {
+			t.Errorf("ExistingFor(backup=%v, %v) = %v, want %v", tt.backup, tt.action, got, tt.want)
+		}
+	}
+
+	tempDir := t.TempDir()
+	dotfilesRepo := filepath.Join(tempDir, "repo")
+	createDummyFile(t, filepath.Join(dotfilesRepo, "zsh_history"), "")
+	target := filepath.Join(tempDir, ".zsh_history")
+	createDummyFile(t, target, "old history")
+
+	df := config.Dotfile{Source: "zsh_history", Target: target, Backup: &off}
+	if err := CreateSymlink(io.Discard, df, dotfilesRepo, ExistingFor(df, SymlinkActionBackup), false); err != nil {
+		t.Fatalf("CreateSymlink failed: %v", err)
+	}
+	if _, err := os.Readlink(target); err != nil {
+		t.Errorf("expected target to be linked: %v", err)
+	}
+	if _, err := os.Lstat(target + ".bak"); !os.IsNotExist(err) {
+		t.Error("backup = false should not leave a backup")
+	}
+}
@@ -5,66 +5,185 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"os/user"
 	"path/filepath"
+	"runtime"
+	"strings"
 	"text/template"
 
 	"github.com/fatih/color"
 	"github.com/mad01/ralph/internal/config"
 )
 
+// TemplateOptions controls how a template is rendered.
+type TemplateOptions struct {
+	// DryRun prevents template functions with side effects (such as output)
+	// from running commands; they return a placeholder instead.
+	DryRun bool
+	// LeftDelim and RightDelim override the default "{{" and "}}" action delimiters.
+	LeftDelim  string
+	RightDelim string
+	// Vars are the dotfile's own variables, which override all other data.
+	Vars map[string]interface{}
+
+	// rendering lists the dotfiles being rendered through the rendered
+	// function, outermost first, to catch templates that include each other.
+	rendering []string
+}
+
+// TemplateOptionsFor returns the rendering options for a dotfile entry.
+func TemplateOptionsFor(df config.Dotfile, dryRun bool) TemplateOptions {
+	opts := TemplateOptions{DryRun: dryRun, Vars: df.Vars}
+	if len(df.TemplateDelims) == 2 {
+		opts.LeftDelim, opts.RightDelim = df.TemplateDelims[0], df.TemplateDelims[1]
+	}
+	return opts
+}
+
 // ProcessTemplate takes a source file path, processes it as a Go template,
 // and returns the processed content as a byte slice.
 // It uses data from the ralphConfig and environment variables for templating.
 func ProcessTemplate(sourcePath string, ralphConfig *config.Config, templateData map[string]interface{}) ([]byte, error) {
+	return ProcessTemplateWithOptions(sourcePath, ralphConfig, templateData, TemplateOptions{})
+}
+
+// ProcessTemplateWithOptions is ProcessTemplate with explicit rendering options.
+func ProcessTemplateWithOptions(sourcePath string, ralphConfig *config.Config, templateData map[string]interface{}, opts TemplateOptions) ([]byte, error) {
 	content, err := os.ReadFile(sourcePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read template file '%s': %w", sourcePath, err)
 	}
+	return render(sourcePath, string(content), ralphConfig, templateData, opts)
+}
 
-	tmpl, err := template.New(filepath.Base(sourcePath)).
-		Funcs(template.FuncMap{ // Add any custom template functions here if needed
-			"env": os.Getenv,
-		}).
-		Parse(string(content))
+// RenderString renders text as a template with the same data and functions
+// as dotfile templates. name identifies the text in error messages.
+func RenderString(name, text string, ralphConfig *config.Config, opts TemplateOptions) (string, error) {
+	out, err := render(name, text, ralphConfig, nil, opts)
+	return string(out), err
+}
+
+// render parses and executes a template. name identifies it in errors.
+func render(name, text string, ralphConfig *config.Config, templateData map[string]interface{}, opts TemplateOptions) ([]byte, error) {
+	tmpl, err := template.New(filepath.Base(name)).
+		Delims(opts.LeftDelim, opts.RightDelim).
+		Funcs(templateFuncs(ralphConfig, opts)).
+		Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template '%s': %w", name, err)
+	}
+	// Ask for declared prompt variables the template uses but that have no
+	// value yet; answers are added to the config's TemplateVariables
+	prompted, err := promptedData(tmpl, ralphConfig, opts.DryRun)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse template '%s': %w", sourcePath, err)
+		return nil, fmt.Errorf("failed to render template '%s': %w", name, err)
 	}
 
-	// Prepare data for the template
-	data := make(map[string]interface{})
+	// Prepare data for the template, starting with the built-in machine facts
+	data := builtinTemplateData()
 
 	// Add ralph config if available - provides access to global config like DotfilesRepoPath
 	if ralphConfig != nil {
 		data["RalphConfig"] = ralphConfig
 		data["DotterConfig"] = ralphConfig // backward compatibility
 		// Merge variables from config.TemplateVariables
-		// These will override any same-named keys from other sources if any (none yet)
+		// These override same-named built-in variables
 		for k, v := range ralphConfig.TemplateVariables {
 			data[k] = v
 		}
 	}
 
+	// Dry-run placeholders for unanswered prompt variables
+	for k, v := range prompted {
+		data[k] = v
+	}
+
 	// Add custom data passed in templateData (e.g. from command line flags in future, or per-dotfile variables)
 	// These could override general TemplateVariables if names clash.
 	for k, v := range templateData {
 		data[k] = v
 	}
 
+	// Per-dotfile vars win over everything else
+	for k, v := range opts.Vars {
+		data[k] = v
+	}
+
 	var processedContent bytes.Buffer
 	if err := tmpl.Execute(&processedContent, data); err != nil {
-		return nil, fmt.Errorf("failed to execute template '%s': %w", sourcePath, err)
+		return nil, fmt.Errorf("failed to execute template '%s': %w", name, err)
 	}
 
 	return processedContent.Bytes(), nil
 }
 
+// builtinTemplateData returns the standard variables available to every template:
+// .Host, .OS, .Arch, .User, .Home and .Shell. Config template variables and
+// per-dotfile data can override them.
+func builtinTemplateData() map[string]interface{} {
+	userName := os.Getenv("USER")
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		userName = u.Username
+	}
+	home, _ := os.UserHomeDir()
+	shellName := ""
+	if shellPath := os.Getenv("SHELL"); shellPath != "" {
+		shellName = filepath.Base(shellPath)
+	}
+
+	return map[string]interface{}{
+		"Host":  config.GetCurrentHost(),
+		"OS":    runtime.GOOS,
+		"Arch":  runtime.GOARCH,
+		"User":  userName,
+		"Home":  home,
+		"Shell": shellName,
+	}
+}
+
+// renderedName returns the base name of a rendered template, with the
+// template extension stripped.
+func renderedName(sourcePath string) string {
+	return strings.TrimSuffix(filepath.Base(sourcePath), config.TemplateExtension)
+}
+
+// processedTemplatesDir returns the directory processed templates are written to.
+func processedTemplatesDir() string {
+	return filepath.Join(os.TempDir(), "ralph", "processed_templates")
+}
+
+// RemoveProcessedTemplates deletes all processed template files.
+// If dryRun is true, it will only print the actions it would take.
+func RemoveProcessedTemplates(w io.Writer, dryRun bool) error {
+	dir := processedTemplatesDir()
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return nil
+	}
+	if dryRun {
+		fmt.Fprintf(w, "    %s would remove %s\n", color.CyanString("[dry run]"), faint(dir))
+		return nil
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("failed to remove processed templates in '%s': %w", dir, err)
+	}
+	fmt.Fprintf(w, "    %s %s\n", color.YellowString("removed"), faint(dir))
+	return nil
+}
+
 // WriteProcessedTemplateToFile handles processing a template and writing it to a temporary file.
 // This temp file can then be symlinked.
 // Returns the path to the temporary processed file.
 // If dryRun is true, it processes the template (to catch errors) but does not write the file,
 // and returns a placeholder path.
 func WriteProcessedTemplateToFile(w io.Writer, sourcePath string, ralphConfig *config.Config, templateData map[string]interface{}, dryRun bool) (string, error) {
-	processedBytes, err := ProcessTemplate(sourcePath, ralphConfig, templateData)
+	return WriteProcessedTemplateToFileWithOptions(w, sourcePath, ralphConfig, templateData, TemplateOptions{DryRun: dryRun})
+}
+
+// WriteProcessedTemplateToFileWithOptions is WriteProcessedTemplateToFile with
+// explicit rendering options; opts.DryRun takes the place of the dryRun argument.
+func WriteProcessedTemplateToFileWithOptions(w io.Writer, sourcePath string, ralphConfig *config.Config, templateData map[string]interface{}, opts TemplateOptions) (string, error) {
+	dryRun := opts.DryRun
+	processedBytes, err := ProcessTemplateWithOptions(sourcePath, ralphConfig, templateData, opts)
 	if err != nil {
 		return "", err // Error in processing is an error regardless of dryRun
 	}
@@ -72,17 +191,17 @@ func WriteProcessedTemplateToFile(w io.Writer, sourcePath string, ralphConfig *c
 	if dryRun {
 		fmt.Fprintf(w, "    %s would process template %s\n", color.CyanString("[dry run]"), faint(filepath.Base(sourcePath)))
 		// Return a fake path for dry run symlinking to use
-		return filepath.Join(os.TempDir(), "ralph_dry_run_processed_template", filepath.Base(sourcePath)+".processed"), nil
+		return filepath.Join(os.TempDir(), "ralph_dry_run_processed_template", renderedName(sourcePath)+".processed"), nil
 	}
 
 	// Create a temporary file to store the processed template
 	// It's good practice to put these in a ralph-specific temp location
-	tempDir := filepath.Join(os.TempDir(), "ralph", "processed_templates")
+	tempDir := processedTemplatesDir()
 	if err := os.MkdirAll(tempDir, 0700); err != nil {
 		return "", fmt.Errorf("failed to create temp directory for processed templates: %w", err)
 	}
 
-	tempFile, err := os.CreateTemp(tempDir, filepath.Base(sourcePath)+".*.processed")
+	tempFile, err := os.CreateTemp(tempDir, renderedName(sourcePath)+".*.processed")
 	if err != nil {
 		return "", fmt.Errorf("failed to create temporary file for processed template: %w", err)
 	}
@@ -0,0 +1,131 @@
+package dotfile
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/fatih/color"
+	"github.com/mad01/ralph/internal/config"
+)
+
+// RenderTemplateDir walks the source directory of a dotfile, renders every file
+// through the template engine and writes the result to the same relative path
+// under the target directory. Directory structure and file modes are preserved,
+// and a ".tmpl" extension is stripped from rendered file names.
+// Files whose rendered content and mode already match the target are left alone.
+// An existing non-directory target is handled according to action.
+// If dryRun is true, templates are still rendered (to catch errors) but nothing is written.
+func RenderTemplateDir(w io.Writer, dotfileCfg config.Dotfile, ralphConfig *config.Config, templateData map[string]interface{}, action SymlinkAction, dryRun bool) error {
+	absoluteSource, err := config.ExpandPath(filepath.Join(ralphConfig.DotfilesRepoPath, dotfileCfg.Source))
+	if err != nil {
+		return fmt.Errorf("failed to expand source path '%s' relative to '%s': %w", dotfileCfg.Source, ralphConfig.DotfilesRepoPath, err)
+	}
+
+	absoluteTarget, err := config.ExpandPath(dotfileCfg.Target)
+	if err != nil {
+		return fmt.Errorf("failed to expand target path '%s': %w", dotfileCfg.Target, err)
+	}
+
+	info, err := os.Stat(absoluteSource)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("source directory '%s' (expanded: '%s') does not exist", dotfileCfg.Source, absoluteSource)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to stat source '%s': %w", absoluteSource, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("source '%s' is not a directory (use is_template for single files)", absoluteSource)
+	}
+
+	// A symlink or file at the target (e.g. left over from symlink_dir) is
+	// replaced; an existing directory is rendered into.
+	targetInfo, err := os.Lstat(absoluteTarget)
+	if err == nil && (IsLink(targetInfo) || !targetInfo.IsDir()) {
+		if err := handleExistingTarget(w, absoluteTarget, action, dryRun); err != nil {
+			return err
+		}
+		if action == SymlinkActionSkip {
+			return nil
+		}
+	} else if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to stat target '%s': %w", absoluteTarget, err)
+	}
+
+	opts := TemplateOptionsFor(dotfileCfg, dryRun)
+	rendered, unchanged := 0, 0
+	err = filepath.WalkDir(absoluteSource, func(path string, d fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		rel, err := filepath.Rel(absoluteSource, path)
+		if err != nil {
+			return err
+		}
+		if rel != "." && config.IgnoredPath(dotfileCfg.Ignore, rel, d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			if dryRun {
+				return nil
+			}
+			dir := filepath.Join(absoluteTarget, rel)
+			if err := os.MkdirAll(dir, info.Mode().Perm()); err != nil {
+				return fmt.Errorf("failed to create directory '%s': %w", dir, err)
+			}
+			return nil
+		}
+		if !info.Mode().IsRegular() {
+			fmt.Fprintf(w, "    %s %s\n", color.CyanString("skipped"), faint(rel+" (not a regular file)"))
+			return nil
+		}
+
+		content, err := ProcessTemplateWithOptions(path, ralphConfig, templateData, opts)
+		if err != nil {
+			return err
+		}
+		dest := filepath.Join(absoluteTarget, filepath.Dir(rel), renderedName(rel))
+		mode := info.Mode().Perm()
+
+		if existing, statErr := os.Stat(dest); statErr == nil && existing.Mode().Perm() == mode {
+			if current, readErr := os.ReadFile(dest); readErr == nil && bytes.Equal(current, content) {
+				unchanged++
+				return nil
+			}
+		}
+		rendered++
+		if dryRun {
+			fmt.Fprintf(w, "    %s would render %s\n", color.CyanString("[dry run]"), faint(config.ShortenHome(dest)))
+			return nil
+		}
+		if err := os.WriteFile(dest, content, mode); err != nil {
+			return fmt.Errorf("failed to write rendered file '%s': %w", dest, err)
+		}
+		// WriteFile only applies mode when creating the file.
+		if err := os.Chmod(dest, mode); err != nil {
+			return fmt.Errorf("failed to set mode on '%s': %w", dest, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if dryRun {
+		fmt.Fprintf(w, "    %s would render %d file(s), %d unchanged\n", color.CyanString("[dry run]"), rendered, unchanged)
+	} else {
+		fmt.Fprintf(w, "    %s %s\n", color.GreenString("rendered"), faint(fmt.Sprintf("%d file(s), %d unchanged", rendered, unchanged)))
+	}
+	return nil
+}
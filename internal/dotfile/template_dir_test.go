@@ -0,0 +1,133 @@
+package dotfile
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mad01/ralph/internal/config"
+)
+
+// setupTemplateDir creates a repo with a "conf" template directory.
+func setupTemplateDir(t *testing.T) (*config.Config, string) {
+	t.Helper()
+	repo := t.TempDir()
+	src := filepath.Join(repo, "conf")
+	if err := os.MkdirAll(filepath.Join(src, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]struct {
+		content string
+		mode    os.FileMode
+	}{
+		"main.conf.tmpl":  {"name={{ .Name }}\n", 0644},
+		"sub/run.sh":      {"#!/bin/sh\necho {{ .Name }}\n", 0755},
+		"sub/static.conf": {"plain\n", 0600},
+	}
+	for rel, f := range files {
+		if err := os.WriteFile(filepath.Join(src, rel), []byte(f.content), f.mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cfg := &config.Config{
+		DotfilesRepoPath:  repo,
+		TemplateVariables: map[string]interface{}{"Name": "ralph"},
+	}
+	return cfg, filepath.Join(t.TempDir(), "out")
+}
+
+func TestRenderTemplateDir(t *testing.T) {
+	cfg, target := setupTemplateDir(t)
+	df := config.Dotfile{Source: "conf", Target: target, Action: "template_dir"}
+
+	if err := RenderTemplateDir(io.Discard, df, cfg, nil, SymlinkActionBackup, false); err != nil {
+		t.Fatalf("RenderTemplateDir failed: %v", err)
+	}
+
+	tests := []struct {
+		rel     string
+		content string
+		mode    os.FileMode
+	}{
+		{"main.conf", "name=ralph\n", 0644},
+		{"sub/run.sh", "#!/bin/sh\necho ralph\n", 0755},
+		{"sub/static.conf", "plain\n", 0600},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(target, tt.rel)
+		got, err := os.ReadFile(path)
+		if err != nil {
+			t.Errorf("expected %s to be rendered: %v", tt.rel, err)
+			continue
+		}
+		if string(got) != tt.content {
+			t.Errorf("%s content = %q, want %q", tt.rel, got, tt.content)
+		}
+		info, _ := os.Stat(path)
+		if info.Mode().Perm() != tt.mode {
+			t.Errorf("%s mode = %v, want %v", tt.rel, info.Mode().Perm(), tt.mode)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(target, "main.conf.tmpl")); !os.IsNotExist(err) {
+		t.Error("expected .tmpl extension to be stripped from rendered file")
+	}
+}
+
+func TestRenderTemplateDir_Ignore(t *testing.T) {
+	cfg, target := setupTemplateDir(t)
+	df := config.Dotfile{Source: "conf", Target: target, Action: "template_dir", Ignore: []string{"sub/"}}
+
+	if err := RenderTemplateDir(io.Discard, df, cfg, nil, SymlinkActionBackup, false); err != nil {
+		t.Fatalf("RenderTemplateDir failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(target, "main.conf")); err != nil {
+		t.Errorf("expected main.conf to be rendered: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(target, "sub")); !os.IsNotExist(err) {
+		t.Error("ignored sub/ should not be rendered")
+	}
+	if inSync, err := InSync(df, cfg); err != nil || !inSync {
+		t.Errorf("InSync() = %v, %v; ignored files should not count", inSync, err)
+	}
+}
+
+func TestRenderTemplateDir_DryRun(t *testing.T) {
+	cfg, target := setupTemplateDir(t)
+	df := config.Dotfile{Source: "conf", Target: target, Action: "template_dir"}
+
+	if err := RenderTemplateDir(io.Discard, df, cfg, nil, SymlinkActionBackup, true); err != nil {
+		t.Fatalf("RenderTemplateDir dry run failed: %v", err)
+	}
+	if _, err := os.Stat(target); !os.IsNotExist(err) {
+		t.Error("dry run should not create the target directory")
+	}
+}
+
+func TestRenderTemplateDir_ReplacesSymlink(t *testing.T) {
+	cfg, target := setupTemplateDir(t)
+	if err := os.Symlink(filepath.Join(cfg.DotfilesRepoPath, "conf"), target); err != nil {
+		t.Fatal(err)
+	}
+	df := config.Dotfile{Source: "conf", Target: target, Action: "template_dir"}
+
+	if err := RenderTemplateDir(io.Discard, df, cfg, nil, SymlinkActionBackup, false); err != nil {
+		t.Fatalf("RenderTemplateDir failed: %v", err)
+	}
+	info, err := os.Lstat(target)
+	if err != nil || !info.IsDir() {
+		t.Fatalf("expected target to be a real directory, got %v (err %v)", info, err)
+	}
+	if _, err := os.Lstat(target + ".bak"); err != nil {
+		t.Errorf("expected previous symlink to be backed up: %v", err)
+	}
+}
+
+func TestRenderTemplateDir_SourceNotDirectory(t *testing.T) {
+	cfg, target := setupTemplateDir(t)
+	df := config.Dotfile{Source: "conf/sub/run.sh", Target: target, Action: "template_dir"}
+
+	if err := RenderTemplateDir(io.Discard, df, cfg, nil, SymlinkActionBackup, false); err == nil {
+		t.Error("expected error when source is a file")
+	}
+}
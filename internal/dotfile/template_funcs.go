@@ -0,0 +1,144 @@
+package dotfile
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/mad01/ralph/internal/config"
+)
+
+var (
+	// outputCache memoizes `output` results for the lifetime of the process,
+	// so a command used by several templates runs only once per apply.
+	outputCache   = make(map[string]string)
+	outputCacheMu sync.Mutex
+)
+
+// templateFuncs returns the function map available to every template.
+func templateFuncs(ralphConfig *config.Config, opts TemplateOptions) template.FuncMap {
+	return template.FuncMap{
+		"env": os.Getenv,
+		"output": func(command string) (string, error) {
+			return commandOutput(command, opts.DryRun)
+		},
+		"rendered": func(name string) (string, error) {
+			return renderedDotfile(ralphConfig, name, opts)
+		},
+		"source": func(source string) (string, error) {
+			return targetOfSource(ralphConfig, source)
+		},
+	}
+}
+
+// renderedDotfile returns the content the dotfile called name deploys: its
+// rendered template, or its source as is.
+func renderedDotfile(ralphConfig *config.Config, name string, opts TemplateOptions) (string, error) {
+	if ralphConfig == nil {
+		return "", fmt.Errorf("rendered %q: no configuration loaded", name)
+	}
+	df, ok := ralphConfig.Dotfiles[name]
+	if !ok {
+		return "", fmt.Errorf("rendered %q: no such dotfile", name)
+	}
+	for _, outer := range opts.rendering {
+		if outer == name {
+			return "", fmt.Errorf("rendered %q: templates include each other (%s -> %s)", name, strings.Join(opts.rendering, " -> "), name)
+		}
+	}
+	path, err := config.ExpandPath(filepath.Join(ralphConfig.DotfilesRepoPath, df.Source))
+	if err != nil {
+		return "", fmt.Errorf("rendered %q: %w", name, err)
+	}
+	if !df.IsTemplate {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("rendered %q: %w", name, err)
+		}
+		return string(content), nil
+	}
+	inner := TemplateOptionsFor(df, opts.DryRun)
+	inner.rendering = append(append([]string(nil), opts.rendering...), name)
+	content, err := ProcessTemplateWithOptions(path, ralphConfig, nil, inner)
+	if err != nil {
+		return "", err
+	}
+	return string(content), nil
+}
+
+// targetOfSource returns where the file at source, a path in the dotfiles
+// repo, is deployed: the expanded target of the dotfile with that source, or
+// the matching path under the target of a dotfile whose source directory
+// contains it.
+func targetOfSource(ralphConfig *config.Config, source string) (string, error) {
+	if ralphConfig == nil {
+		return "", fmt.Errorf("source %q: no configuration loaded", source)
+	}
+	source = filepath.Clean(source)
+	names := make([]string, 0, len(ralphConfig.Dotfiles))
+	for name := range ralphConfig.Dotfiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if df := ralphConfig.Dotfiles[name]; df.Source != "" && filepath.Clean(df.Source) == source {
+			return config.ExpandPath(df.Target)
+		}
+	}
+	for _, name := range names {
+		df := ralphConfig.Dotfiles[name]
+		if df.Source == "" {
+			continue
+		}
+		rel, err := filepath.Rel(filepath.Clean(df.Source), source)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		if df.Action == "template_dir" {
+			rel = filepath.Join(filepath.Dir(rel), renderedName(rel))
+		}
+		target, err := config.ExpandPath(df.Target)
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(target, rel), nil
+	}
+	return "", fmt.Errorf("source %q: no dotfile deploys it", source)
+}
+
+// commandOutput runs command via `sh -c` and returns its stdout with trailing
+// newlines removed. Results are cached per command string. In dry-run mode the
+// command is not executed unless it has already been cached.
+func commandOutput(command string, dryRun bool) (string, error) {
+	outputCacheMu.Lock()
+	defer outputCacheMu.Unlock()
+
+	if out, ok := outputCache[command]; ok {
+		return out, nil
+	}
+	if dryRun {
+		return fmt.Sprintf("<output of %q>", command), nil
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("output %q failed: %w: %s", command, err, msg)
+		}
+		return "", fmt.Errorf("output %q failed: %w", command, err)
+	}
+
+	result := strings.TrimRight(string(out), "\r\n")
+	outputCache[command] = result
+	return result, nil
+}
@@ -0,0 +1,120 @@
+package dotfile
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mad01/ralph/internal/config"
+)
+
+func TestProcessTemplate_Output(t *testing.T) {
+	templatePath := createTempTemplateFile(t, "output.tmpl", `email = {{ output "echo user@example.com" }}`)
+
+	processed, err := ProcessTemplate(templatePath, &config.Config{}, nil)
+	if err != nil {
+		t.Fatalf("ProcessTemplate failed: %v", err)
+	}
+	if string(processed) != "email = user@example.com" {
+		t.Errorf("ProcessTemplate output mismatch: got %q", string(processed))
+	}
+}
+
+func TestCommandOutput_Cached(t *testing.T) {
+	counter := filepath.Join(t.TempDir(), "count")
+	command := "echo x >> " + counter + "; echo done"
+
+	for i := 0; i < 3; i++ {
+		out, err := commandOutput(command, false)
+		if err != nil {
+			t.Fatalf("commandOutput failed: %v", err)
+		}
+		if out != "done" {
+			t.Errorf("commandOutput = %q, want %q", out, "done")
+		}
+	}
+
+	data, _ := os.ReadFile(counter)
+	if runs := strings.Count(string(data), "x"); runs != 1 {
+		t.Errorf("Expected command to run once, ran %d times", runs)
+	}
+}
+
+func TestCommandOutput_DryRunDoesNotExecute(t *testing.T) {
+	marker := filepath.Join(t.TempDir(), "marker")
+	out, err := commandOutput("touch "+marker, true)
+	if err != nil {
+		t.Fatalf("commandOutput (dry run) failed: %v", err)
+	}
+	if !strings.Contains(out, "output of") {
+		t.Errorf("Expected dry run placeholder, got %q", out)
+	}
+	if _, statErr := os.Stat(marker); !os.IsNotExist(statErr) {
+		t.Error("Dry run executed the command")
+	}
+}
+
+func TestCommandOutput_Failure(t *testing.T) {
+	_, err := commandOutput("echo boom >&2; exit 3", false)
+	if err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("Expected error including stderr, got %v", err)
+	}
+}
+
+func TestProcessTemplate_RenderedAndSource(t *testing.T) {
+	repo := t.TempDir()
+	files := map[string]string{
+		"git/gitconfig_work.tmpl": "[user]\n\temail = {{ .Email }}\n",
+		"git/gitconfig.tmpl":      "[include]\n\tpath = {{ source \"git/gitconfig_work.tmpl\" }}\n# {{ rendered \"gitconfig_work\" }}",
+		"zsh/plain":               "plain {{ not rendered }}",
+	}
+	for rel, content := range files {
+		createDummyFile(t, filepath.Join(repo, rel), content)
+	}
+	cfg := &config.Config{
+		DotfilesRepoPath:  repo,
+		TemplateVariables: map[string]interface{}{"Email": "me@work.example"},
+		Dotfiles: map[string]config.Dotfile{
+			"gitconfig_work": {Source: "git/gitconfig_work.tmpl", Target: "/home/me/.gitconfig_work", IsTemplate: true},
+			"gitconfig":      {Source: "git/gitconfig.tmpl", Target: "/home/me/.gitconfig", IsTemplate: true},
+			"zsh":            {Source: "zsh", Target: "/home/me/.config/zsh", Action: "symlink_dir"},
+		},
+	}
+
+	processed, err := ProcessTemplate(filepath.Join(repo, "git/gitconfig.tmpl"), cfg, nil)
+	if err != nil {
+		t.Fatalf("ProcessTemplate failed: %v", err)
+	}
+	want := "[include]\n\tpath = /home/me/.gitconfig_work\n# [user]\n\temail = me@work.example\n"
+	if string(processed) != want {
+		t.Errorf("ProcessTemplate = %q, want %q", processed, want)
+	}
+
+	if got, err := renderedDotfile(cfg, "zsh", TemplateOptions{}); err == nil {
+		t.Errorf("rendered of a directory should fail, got %q", got)
+	}
+	if got, err := targetOfSource(cfg, "zsh/plain"); err != nil || got != "/home/me/.config/zsh/plain" {
+		t.Errorf("source of a file in a linked directory = %q, %v", got, err)
+	}
+	if _, err := targetOfSource(cfg, "unmanaged"); err == nil {
+		t.Error("source of an unmanaged file should fail")
+	}
+}
+
+func TestProcessTemplate_RenderedCycle(t *testing.T) {
+	repo := t.TempDir()
+	createDummyFile(t, filepath.Join(repo, "a.tmpl"), `{{ rendered "b" }}`)
+	createDummyFile(t, filepath.Join(repo, "b.tmpl"), `{{ rendered "a" }}`)
+	cfg := &config.Config{
+		DotfilesRepoPath: repo,
+		Dotfiles: map[string]config.Dotfile{
+			"a": {Source: "a.tmpl", Target: "/tmp/a", IsTemplate: true},
+			"b": {Source: "b.tmpl", Target: "/tmp/b", IsTemplate: true},
+		},
+	}
+	_, err := ProcessTemplate(filepath.Join(repo, "a.tmpl"), cfg, nil)
+	if err == nil || !strings.Contains(err.Error(), "include each other") {
+		t.Errorf("expected a cycle error, got %v", err)
+	}
+}
@@ -0,0 +1,88 @@
+package dotfile
+
+import (
+	"fmt"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/mad01/ralph/internal/config"
+)
+
+// promptedData resolves the declared prompt variables (see
+// config.TemplatePrompt) that tmpl refers to, asking for their values. In
+// dry-run mode nothing is asked or saved; placeholders are returned for the
+// template data instead.
+func promptedData(tmpl *template.Template, cfg *config.Config, dryRun bool) (map[string]interface{}, error) {
+	placeholders := make(map[string]interface{})
+	if cfg == nil || len(cfg.TemplatePrompts) == 0 {
+		return placeholders, nil
+	}
+	for name := range referencedFields(tmpl) {
+		if _, ok := cfg.TemplatePrompts[name]; !ok {
+			continue
+		}
+		if dryRun {
+			placeholders[name] = fmt.Sprintf("<prompt for %s>", name)
+			continue
+		}
+		if err := config.ResolvePrompt(cfg, name); err != nil {
+			return nil, err
+		}
+	}
+	return placeholders, nil
+}
+
+// referencedFields returns the top-level data fields (.name) used anywhere in
+// tmpl and its associated templates.
+func referencedFields(tmpl *template.Template) map[string]bool {
+	fields := make(map[string]bool)
+	var walk func(node parse.Node)
+	walk = func(node parse.Node) {
+		switch n := node.(type) {
+		case *parse.ListNode:
+			if n == nil {
+				return
+			}
+			for _, child := range n.Nodes {
+				walk(child)
+			}
+		case *parse.ActionNode:
+			walk(n.Pipe)
+		case *parse.PipeNode:
+			if n == nil {
+				return
+			}
+			for _, cmd := range n.Cmds {
+				walk(cmd)
+			}
+		case *parse.CommandNode:
+			for _, arg := range n.Args {
+				walk(arg)
+			}
+		case *parse.FieldNode:
+			fields[n.Ident[0]] = true
+		case *parse.ChainNode:
+			walk(n.Node)
+		case *parse.IfNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.RangeNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.WithNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.TemplateNode:
+			walk(n.Pipe)
+		}
+	}
+	for _, t := range tmpl.Templates() {
+		if t.Tree != nil {
+			walk(t.Tree.Root)
+		}
+	}
+	return fields
+}
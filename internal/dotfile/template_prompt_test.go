@@ -0,0 +1,60 @@
+package dotfile
+
+import (
+	"path/filepath"
+	"testing"
+	"text/template"
+
+	"github.com/mad01/ralph/internal/config"
+)
+
+func TestReferencedFields(t *testing.T) {
+	tmpl := template.Must(template.New("t").Parse(
+		`{{ .a }}{{ if .b }}{{ .c.d }}{{ else }}{{ range .e }}{{ . }}{{ end }}{{ end }}{{ with .f }}{{ .inner }}{{ end }}{{ define "x" }}{{ .g }}{{ end }}{{ printf "%s" .h | print }}`))
+	fields := referencedFields(tmpl)
+	for _, want := range []string{"a", "b", "c", "e", "f", "g", "h"} {
+		if !fields[want] {
+			t.Errorf("field %q not found in %v", want, fields)
+		}
+	}
+}
+
+func TestRenderString_Prompts(t *testing.T) {
+	dir := t.TempDir()
+	origPath := config.GetDefaultConfigPath
+	config.GetDefaultConfigPath = func() (string, error) { return filepath.Join(dir, "config.toml"), nil }
+	origPrompter := config.Prompter
+	t.Cleanup(func() {
+		config.GetDefaultConfigPath = origPath
+		config.Prompter = origPrompter
+	})
+
+	asked := 0
+	config.Prompter = func(name, description string) (string, error) {
+		asked++
+		return "me@example.com", nil
+	}
+	newCfg := func() *config.Config {
+		return &config.Config{TemplatePrompts: map[string]config.TemplatePrompt{
+			"email":  {Description: "Git email"},
+			"unused": {},
+		}}
+	}
+
+	out, err := RenderString("t", "{{ .email }}", newCfg(), TemplateOptions{DryRun: true})
+	if err != nil || out != "<prompt for email>" || asked != 0 {
+		t.Fatalf("dry run = (%q, %v) after %d prompts, want a placeholder and no prompt", out, err, asked)
+	}
+
+	cfg := newCfg()
+	out, err = RenderString("t", "{{ .email }}", cfg, TemplateOptions{})
+	if err != nil || out != "me@example.com" {
+		t.Fatalf("RenderString = (%q, %v)", out, err)
+	}
+	if asked != 1 {
+		t.Errorf("asked %d times, want 1 (unused prompts are not asked)", asked)
+	}
+	if _, ok := cfg.TemplatePrompts["unused"]; !ok {
+		t.Error("unused prompt should stay pending")
+	}
+}
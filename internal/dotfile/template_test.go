@@ -5,6 +5,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"testing"
 
@@ -51,6 +52,65 @@ func TestProcessTemplate_Basic(t *testing.T) {
 	}
 }
 
+func TestProcessTemplate_BuiltinVariables(t *testing.T) {
+	t.Setenv("SHELL", "/usr/bin/zsh")
+	templateContent := "{{ .OS }}/{{ .Arch }} host={{ .Host }} home={{ .Home }} shell={{ .Shell }} user={{ if .User }}set{{ end }}"
+	templatePath := createTempTemplateFile(t, "builtin.tmpl", templateContent)
+
+	processed, err := ProcessTemplate(templatePath, &config.Config{}, nil)
+	if err != nil {
+		t.Fatalf("ProcessTemplate failed: %v", err)
+	}
+
+	home, _ := os.UserHomeDir()
+	expected := fmt.Sprintf("%s/%s host=%s home=%s shell=zsh user=set", runtime.GOOS, runtime.GOARCH, config.GetCurrentHost(), home)
+	if string(processed) != expected {
+		t.Errorf("ProcessTemplate output mismatch:\nGot:  %s\nWant: %s", string(processed), expected)
+	}
+}
+
+func TestProcessTemplate_ConfigOverridesBuiltin(t *testing.T) {
+	cfg := &config.Config{TemplateVariables: map[string]interface{}{"OS": "custom"}}
+	templatePath := createTempTemplateFile(t, "override.tmpl", "{{ .OS }}")
+
+	processed, err := ProcessTemplate(templatePath, cfg, nil)
+	if err != nil {
+		t.Fatalf("ProcessTemplate failed: %v", err)
+	}
+	if string(processed) != "custom" {
+		t.Errorf("Expected config variable to override built-in, got %q", string(processed))
+	}
+}
+
+func TestProcessTemplate_DotfileVars(t *testing.T) {
+	cfg := &config.Config{TemplateVariables: map[string]interface{}{"font_size": 11, "theme": "dark"}}
+	templatePath := createTempTemplateFile(t, "vars.tmpl", "{{ .font_size }} {{ .theme }} {{ .OS }}")
+
+	opts := TemplateOptionsFor(config.Dotfile{Vars: map[string]interface{}{"font_size": 13, "OS": "custom"}}, false)
+	processed, err := ProcessTemplateWithOptions(templatePath, cfg, map[string]interface{}{"font_size": 12}, opts)
+	if err != nil {
+		t.Fatalf("ProcessTemplateWithOptions failed: %v", err)
+	}
+	if expected := "13 dark custom"; string(processed) != expected {
+		t.Errorf("Expected dotfile vars to override all other data, got %q, want %q", string(processed), expected)
+	}
+}
+
+func TestProcessTemplate_CustomDelims(t *testing.T) {
+	cfg := &config.Config{TemplateVariables: map[string]interface{}{"name": "ralph"}}
+	templatePath := createTempTemplateFile(t, "delims.tmpl", "literal {{ .keep }} and [[ .name ]]")
+
+	opts := TemplateOptionsFor(config.Dotfile{TemplateDelims: []string{"[[", "]]"}}, false)
+	processed, err := ProcessTemplateWithOptions(templatePath, cfg, nil, opts)
+	if err != nil {
+		t.Fatalf("ProcessTemplateWithOptions failed: %v", err)
+	}
+	expected := "literal {{ .keep }} and ralph"
+	if string(processed) != expected {
+		t.Errorf("ProcessTemplateWithOptions output mismatch:\nGot:  %s\nWant: %s", string(processed), expected)
+	}
+}
+
 func TestProcessTemplate_SourceFileDoesNotExist(t *testing.T) {
 	_, err := ProcessTemplate("non_existent_template.tmpl", nil, nil)
 	if err == nil {
@@ -0,0 +1,46 @@
+// Package exitcode defines the process exit codes every ralph command uses,
+// so scripts can tell a failed run from a warning, a held lock or drift.
+package exitcode
+
+import (
+	"fmt"
+	"io"
+)
+
+// Exit codes shared by all commands.
+const (
+	OK          = 0   // Clean run: nothing failed or warned, nothing left to change
+	Failure     = 1   // Something failed, or the command could not run (warnings too with --strict)
+	Warnings    = 2   // Only warnings, e.g. a tool that isn't installed
+	LockHeld    = 3   // Another ralph apply (or edit, uninstall, ui) holds the apply lock
+	Drift       = 4   // A check found changes to make: a dry run with pending items, or verify finding modified files
+	Interrupted = 130 // Stopped by Ctrl-C or SIGTERM, the code shells use for SIGINT
+)
+
+// Code describes one exit code.
+type Code struct {
+	Code    int
+	Name    string
+	Meaning string
+}
+
+// Codes lists the exit codes in numeric order.
+func Codes() []Code {
+	return []Code{
+		{OK, "ok", "Clean run: nothing failed or warned, nothing left to change"},
+		{Failure, "failure", "Something failed or the command could not run; with --strict (or strict = true) also warnings"},
+		{Warnings, "warnings", "Only warnings: e.g. a tool that isn't installed or a post-apply hook that failed"},
+		{LockHeld, "lock held", "Another ralph apply, edit, uninstall or ui is running and holds the apply lock"},
+		{Drift, "drift", "A check found changes to make: apply/migrate --dry-run with pending items, or verify finding drifted files"},
+		{Interrupted, "interrupted", "Stopped by Ctrl-C or SIGTERM; what was done so far is kept"},
+	}
+}
+
+// Explain writes a table of the exit codes to w.
+func Explain(w io.Writer) {
+	fmt.Fprintln(w, "Exit codes (apply, sync, doctor, migrate, uninstall, verify, hooks):")
+	for _, c := range Codes() {
+		fmt.Fprintf(w, "  %3d  %-12s %s\n", c.Code, c.Name, c.Meaning)
+	}
+	fmt.Fprintln(w, "\nFailures win over drift, drift over warnings.")
+}
@@ -0,0 +1,33 @@
+package exitcode
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCodes(t *testing.T) {
+	codes := Codes()
+	want := []int{OK, Failure, Warnings, LockHeld, Drift, Interrupted}
+	if len(codes) != len(want) {
+		t.Fatalf("Codes() has %d entries, want %d", len(codes), len(want))
+	}
+	for i, c := range codes {
+		if c.Code != want[i] {
+			t.Errorf("Codes()[%d].Code = %d, want %d", i, c.Code, want[i])
+		}
+		if c.Name == "" || c.Meaning == "" {
+			t.Errorf("code %d has no name or meaning", c.Code)
+		}
+	}
+}
+
+func TestExplain(t *testing.T) {
+	var buf bytes.Buffer
+	Explain(&buf)
+	for _, line := range []string{"  3  lock held", "  4  drift", "130  interrupted"} {
+		if !strings.Contains(buf.String(), line) {
+			t.Errorf("Explain() output missing %q:\n%s", line, buf.String())
+		}
+	}
+}
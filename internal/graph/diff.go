@@ -0,0 +1,88 @@
+package graph
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/mad01/ralph/internal/config"
+)
+
+// ItemDiff compares one node of the graphs of two hosts.
+type ItemDiff struct {
+	ID    string `json:"id"`
+	Kind  string `json:"kind"`
+	Label string `json:"label"`
+	A     *Node  `json:"a"` // nil when the node is not in the first host's config
+	B     *Node  `json:"b"` // nil when the node is not in the second host's config
+	// Changed is set when the item is defined differently, e.g. an overlay
+	// of one host changed its source or command.
+	Changed bool `json:"changed,omitempty"`
+	Same    bool `json:"same"`
+}
+
+// Diff compares what two hosts get from their configs: cfgA loaded as hostA
+// (with its overlays) and cfgB as hostB. It returns every recipe, item and
+// hook node of either graph, sorted by ID; Same is set for those both hosts
+// get alike.
+func Diff(cfgA *config.Config, hostA string, cfgB *config.Config, hostB string) []ItemDiff {
+	a, b := Build(cfgA, hostA), Build(cfgB, hostB)
+	byID := make(map[string]*ItemDiff)
+	for i := range a.Nodes {
+		n := &a.Nodes[i]
+		byID[n.ID] = &ItemDiff{ID: n.ID, Kind: n.Kind, Label: n.Label, A: n}
+	}
+	for i := range b.Nodes {
+		n := &b.Nodes[i]
+		if d, ok := byID[n.ID]; ok {
+			d.B = n
+		} else {
+			byID[n.ID] = &ItemDiff{ID: n.ID, Kind: n.Kind, Label: n.Label, B: n}
+		}
+	}
+
+	diffs := make([]ItemDiff, 0, len(byID))
+	for _, d := range byID {
+		if d.Kind == KindConfig {
+			continue
+		}
+		if d.A != nil && d.B != nil {
+			d.Changed = definition(cfgA, d.Kind, d.Label) != definition(cfgB, d.Kind, d.Label)
+			d.Same = !d.Changed && d.A.Active == d.B.Active && d.A.Detail == d.B.Detail
+		}
+		diffs = append(diffs, *d)
+	}
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i].ID < diffs[j].ID })
+	return diffs
+}
+
+// definition returns the config entry of an item as JSON, to compare it
+// between configs. Recipes and hooks are compared by their node alone.
+func definition(cfg *config.Config, kind, name string) string {
+	var v interface{}
+	switch kind {
+	case config.KindDotfile:
+		v = cfg.Dotfiles[name]
+	case config.KindDirectory:
+		v = cfg.Directories[name]
+	case config.KindRepo:
+		v = cfg.Repos[name]
+	case config.KindTool:
+		for _, t := range cfg.Tools {
+			if t.Name == name {
+				v = t
+			}
+		}
+	case config.KindAlias:
+		v = cfg.Shell.Aliases[name]
+	case config.KindFunction:
+		v = cfg.Shell.Functions[name]
+	case config.KindEnv:
+		v = cfg.Shell.Env[name]
+	case config.KindBuild:
+		v = cfg.Hooks.Builds[name]
+	default:
+		return ""
+	}
+	data, _ := json.Marshal(v)
+	return string(data)
+}
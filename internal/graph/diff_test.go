@@ -0,0 +1,41 @@
+package graph
+
+import (
+	"testing"
+
+	"github.com/mad01/ralph/internal/config"
+)
+
+func TestDiff(t *testing.T) {
+	work := testConfig(t)
+	home := *work
+	home.Dotfiles = make(map[string]config.Dotfile)
+	for name, df := range work.Dotfiles {
+		home.Dotfiles[name] = df
+	}
+	work.Dotfiles["npmrc"] = work.Dotfiles["nvim"] // Only in work's overlay
+	nvim := home.Dotfiles["nvim"]
+	nvim.Source = "nvim-home"
+	home.Dotfiles["nvim"] = nvim
+
+	diffs := Diff(work, "work", &home, "other")
+	byID := make(map[string]ItemDiff)
+	for _, d := range diffs {
+		byID[d.ID] = d
+	}
+	if _, ok := byID[KindConfig]; ok {
+		t.Error("the main config node should not be compared")
+	}
+	if d := byID["dotfile:npmrc"]; d.A == nil || d.B != nil || d.Same {
+		t.Errorf("npmrc = %+v, want only on the first host", d)
+	}
+	if d := byID["dotfile:zsh"]; d.Same || d.A.Active || !d.B.Active {
+		t.Errorf("zsh = %+v, want filtered out on work only", d)
+	}
+	if d := byID["dotfile:nvim"]; !d.Changed || d.Same {
+		t.Errorf("nvim = %+v, want changed", d)
+	}
+	if d := byID["repo:tpm"]; !d.Same {
+		t.Errorf("tpm = %+v, want the same on both", d)
+	}
+}
@@ -0,0 +1,286 @@
+package graph
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mad01/ralph/internal/config"
+)
+
+// Node kinds besides the config.Kind* item kinds.
+const (
+	KindConfig = "config"
+	KindRecipe = "recipe"
+	KindHook   = "hook"
+)
+
+// Edge labels.
+const (
+	EdgeIncludes  = "includes"   // main config → recipe
+	EdgeDefines   = "defines"    // config or recipe → item
+	EdgeHook      = "hook"       // item or config → hook commands
+	EdgeDependsOn = "depends on" // item → item whose target contains it
+)
+
+// Node is a recipe, config item or hook in the graph.
+type Node struct {
+	ID     string `json:"id"`
+	Kind   string `json:"kind"`
+	Label  string `json:"label"`
+	Active bool   `json:"active"`           // false when disabled or filtered out for this host
+	Detail string `json:"detail,omitempty"` // Target path, hook commands or why the node is inactive
+}
+
+// Edge connects two nodes by ID.
+type Edge struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Label string `json:"label"`
+}
+
+// Graph is the structure of a loaded configuration: the main config includes
+// recipes, recipes define items, items have hooks and depend on the items
+// whose target directory contains theirs.
+type Graph struct {
+	Nodes []Node `json:"nodes"`
+	Edges []Edge `json:"edges"`
+}
+
+// itemTarget is an item whose target path may contain other items.
+type itemTarget struct {
+	id   string
+	path string
+}
+
+// Build returns the graph of cfg as seen from currentHost. Recipes skipped on
+// this host are included as inactive nodes without items.
+func Build(cfg *config.Config, currentHost string) *Graph {
+	g := &Graph{}
+	configID := KindConfig
+	g.addNode(Node{ID: configID, Kind: KindConfig, Label: "main config", Active: true})
+
+	recipeIDs := make(map[string]string)
+	for _, origin := range cfg.Origins {
+		if _, ok := recipeIDs[origin.Recipe]; ok {
+			continue
+		}
+		id := KindRecipe + ":" + origin.Recipe
+		recipeIDs[origin.Recipe] = id
+		g.addNode(Node{ID: id, Kind: KindRecipe, Label: origin.Recipe, Active: true, Detail: origin.Path})
+		g.addEdge(configID, id, EdgeIncludes)
+	}
+	for _, skipped := range cfg.SkippedRecipes {
+		name := skipped.Ref.Name
+		if name == "" {
+			name = skipped.Ref.Path
+		}
+		id := KindRecipe + ":" + name
+		g.addNode(Node{ID: id, Kind: KindRecipe, Label: name, Detail: "skipped: " + skipped.Reason})
+		g.addEdge(configID, id, EdgeIncludes)
+	}
+
+	var targets []itemTarget
+	var children []itemTarget
+	addItem := func(kind, name string, enable *bool, hosts []string, target string) string {
+		id := kind + ":" + name
+		node := Node{ID: id, Kind: kind, Label: name, Active: true, Detail: target}
+		if !config.IsEnabled(enable) {
+			node.Active, node.Detail = false, "disabled"
+		} else if !config.ShouldApplyForHost(hosts, currentHost) {
+			node.Active, node.Detail = false, "host filter"
+		}
+		g.addNode(node)
+
+		parent := configID
+		if origin := config.OriginOf(cfg, kind, name); origin.Recipe != "" {
+			parent = recipeIDs[origin.Recipe]
+		}
+		g.addEdge(parent, id, EdgeDefines)
+
+		if target != "" {
+			if expanded, err := config.ExpandPath(target); err == nil {
+				children = append(children, itemTarget{id, expanded})
+				if kind == config.KindDirectory || kind == config.KindRepo {
+					targets = append(targets, itemTarget{id, expanded})
+				}
+			}
+		}
+		return id
+	}
+
+	for name, df := range cfg.Dotfiles {
+		id := addItem(config.KindDotfile, name, df.Enable, df.Hosts, df.Target)
+		if cmds := cfg.Hooks.PreLink[name]; len(cmds) > 0 {
+			g.addHook(id, "pre_link:"+name, "pre_link", cmds)
+		}
+		if cmds := cfg.Hooks.PostLink[name]; len(cmds) > 0 {
+			g.addHook(id, "post_link:"+name, "post_link", cmds)
+		}
+	}
+	for name, dir := range cfg.Directories {
+		addItem(config.KindDirectory, name, dir.Enable, dir.Hosts, dir.Target)
+	}
+	for name, rp := range cfg.Repos {
+		addItem(config.KindRepo, name, rp.Enable, rp.Hosts, rp.Target)
+	}
+	for _, t := range cfg.Tools {
+		addItem(config.KindTool, t.Name, t.Enable, t.Hosts, "")
+	}
+	for name, alias := range cfg.Shell.Aliases {
+		addItem(config.KindAlias, name, alias.Enable, alias.Hosts, "")
+	}
+	for name, fn := range cfg.Shell.Functions {
+		addItem(config.KindFunction, name, fn.Enable, fn.Hosts, "")
+	}
+	for name, env := range cfg.Shell.Env {
+		addItem(config.KindEnv, name, env.Enable, env.Hosts, "")
+	}
+	for name, build := range cfg.Hooks.Builds {
+		addItem(config.KindBuild, name, build.Enable, build.Hosts, build.WorkingDir)
+	}
+	if len(cfg.Hooks.PreApply) > 0 {
+		g.addHook(configID, "pre_apply", "pre_apply", config.HookCommands(cfg.Hooks.PreApply))
+	}
+	if len(cfg.Hooks.PostApply) > 0 {
+		g.addHook(configID, "post_apply", "post_apply", config.HookCommands(cfg.Hooks.PostApply))
+	}
+
+	// An item depends on the closest directory or repo whose target contains
+	// its own target (a build's working_dir counts as its target).
+	for _, child := range children {
+		best := ""
+		bestLen := -1
+		for _, parent := range targets {
+			if parent.id == child.id || len(parent.path) <= bestLen || !within(child.path, parent.path) {
+				continue
+			}
+			best, bestLen = parent.id, len(parent.path)
+		}
+		if best != "" {
+			g.addEdge(child.id, best, EdgeDependsOn)
+		}
+	}
+
+	g.sort()
+	return g
+}
+
+// within reports whether path is parent or inside it.
+func within(path, parent string) bool {
+	rel, err := filepath.Rel(parent, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+func (g *Graph) addNode(n Node) {
+	g.Nodes = append(g.Nodes, n)
+}
+
+func (g *Graph) addEdge(from, to, label string) {
+	g.Edges = append(g.Edges, Edge{From: from, To: to, Label: label})
+}
+
+func (g *Graph) addHook(owner, name, label string, cmds []string) {
+	id := KindHook + ":" + name
+	g.addNode(Node{ID: id, Kind: KindHook, Label: label, Active: true, Detail: strings.Join(cmds, "; ")})
+	g.addEdge(owner, id, EdgeHook)
+}
+
+// sort orders nodes and edges by ID so output is stable across runs.
+func (g *Graph) sort() {
+	sort.Slice(g.Nodes, func(i, j int) bool { return g.Nodes[i].ID < g.Nodes[j].ID })
+	sort.Slice(g.Edges, func(i, j int) bool {
+		if g.Edges[i].From != g.Edges[j].From {
+			return g.Edges[i].From < g.Edges[j].From
+		}
+		return g.Edges[i].To < g.Edges[j].To
+	})
+}
+
+// nodeShapes maps node kinds to Graphviz shapes.
+var nodeShapes = map[string]string{
+	KindConfig: "house",
+	KindRecipe: "folder",
+	KindHook:   "cds",
+}
+
+// WriteDOT writes the graph in Graphviz DOT format. Inactive nodes are dashed.
+func (g *Graph) WriteDOT(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("digraph ralph {\n")
+	b.WriteString("  rankdir=LR;\n")
+	b.WriteString("  node [shape=box, fontname=\"Helvetica\"];\n")
+	for _, n := range g.Nodes {
+		attrs := []string{fmt.Sprintf("label=%s", dotQuote(n.Kind+"\\n"+n.Label))}
+		if shape, ok := nodeShapes[n.Kind]; ok {
+			attrs = append(attrs, "shape="+shape)
+		}
+		if !n.Active {
+			attrs = append(attrs, "style=dashed", "fontcolor=gray50", "color=gray50")
+		}
+		if n.Detail != "" {
+			attrs = append(attrs, "tooltip="+dotQuote(n.Detail))
+		}
+		fmt.Fprintf(&b, "  %s [%s];\n", dotQuote(n.ID), strings.Join(attrs, ", "))
+	}
+	for _, e := range g.Edges {
+		style := ""
+		if e.Label == EdgeDependsOn {
+			style = ", style=dashed"
+		}
+		fmt.Fprintf(&b, "  %s -> %s [label=%s%s];\n", dotQuote(e.From), dotQuote(e.To), dotQuote(e.Label), style)
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// dotQuote quotes s as a DOT string. A literal \n is kept as a line break.
+func dotQuote(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
+
+// WriteMermaid writes the graph as a Mermaid flowchart. Mermaid node IDs are
+// generated, since item names may contain characters Mermaid rejects.
+func (g *Graph) WriteMermaid(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("flowchart LR\n")
+	ids := make(map[string]string, len(g.Nodes))
+	var inactive []string
+	for i, n := range g.Nodes {
+		id := fmt.Sprintf("n%d", i)
+		ids[n.ID] = id
+		fmt.Fprintf(&b, "  %s[\"%s: %s\"]\n", id, n.Kind, mermaidEscape(n.Label))
+		if !n.Active {
+			inactive = append(inactive, id)
+		}
+	}
+	for _, e := range g.Edges {
+		arrow := "-->"
+		if e.Label == EdgeDependsOn {
+			arrow = "-.->"
+		}
+		fmt.Fprintf(&b, "  %s %s|%s| %s\n", ids[e.From], arrow, e.Label, ids[e.To])
+	}
+	if len(inactive) > 0 {
+		b.WriteString("  classDef inactive stroke-dasharray: 5 5,color:#888\n")
+		fmt.Fprintf(&b, "  class %s inactive\n", strings.Join(inactive, ","))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// mermaidEscape replaces characters that end a quoted Mermaid label.
+func mermaidEscape(s string) string {
+	return strings.ReplaceAll(s, `"`, "#quot;")
+}
+
+// WriteJSON writes the graph as indented JSON.
+func (g *Graph) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(g)
+}
@@ -0,0 +1,133 @@
+package graph
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mad01/ralph/internal/config"
+)
+
+func testConfig(t *testing.T) *config.Config {
+	t.Helper()
+	home := t.TempDir()
+	disabled := false
+	return &config.Config{
+		Dotfiles: map[string]config.Dotfile{
+			"nvim": {Source: "nvim", Target: filepath.Join(home, ".config", "nvim", "init.lua")},
+			"zsh":  {Source: "zshrc", Target: filepath.Join(home, ".zshrc"), Hosts: []string{"other"}},
+		},
+		Directories: map[string]config.Directory{
+			"config": {Target: filepath.Join(home, ".config")},
+		},
+		Repos: map[string]config.Repo{
+			"tpm": {URL: "https://example.com/tpm.git", Target: filepath.Join(home, ".config", "tmux", "tpm")},
+		},
+		Hooks: config.HooksConfig{
+			PreApply: []config.HookGroup{{Commands: []string{"echo start"}}},
+			PostLink: map[string][]string{"nvim": {"nvim --headless +qa"}},
+			Builds: map[string]config.Build{
+				"tpm-install": {Commands: []string{"./install"}, WorkingDir: filepath.Join(home, ".config", "tmux", "tpm"), Enable: &disabled},
+			},
+		},
+		Origins: map[string]config.ItemOrigin{
+			"dotfile:nvim": {Recipe: "editor", Path: "recipes/editor/recipe.toml"},
+		},
+		SkippedRecipes: []config.SkippedRecipe{
+			{Ref: config.RecipeRef{Name: "work"}, Reason: "host filter"},
+		},
+	}
+}
+
+func hasEdge(g *Graph, from, to, label string) bool {
+	for _, e := range g.Edges {
+		if e.From == from && e.To == to && e.Label == label {
+			return true
+		}
+	}
+	return false
+}
+
+func node(g *Graph, id string) *Node {
+	for i := range g.Nodes {
+		if g.Nodes[i].ID == id {
+			return &g.Nodes[i]
+		}
+	}
+	return nil
+}
+
+func TestBuild(t *testing.T) {
+	g := Build(testConfig(t), "laptop")
+
+	edges := []Edge{
+		{"config", "recipe:editor", EdgeIncludes},
+		{"config", "recipe:work", EdgeIncludes},
+		{"recipe:editor", "dotfile:nvim", EdgeDefines},
+		{"config", "dotfile:zsh", EdgeDefines},
+		{"dotfile:nvim", "hook:post_link:nvim", EdgeHook},
+		{"config", "hook:pre_apply", EdgeHook},
+		{"dotfile:nvim", "directory:config", EdgeDependsOn},
+		{"repo:tpm", "directory:config", EdgeDependsOn},
+		{"build:tpm-install", "repo:tpm", EdgeDependsOn},
+	}
+	for _, e := range edges {
+		if !hasEdge(g, e.From, e.To, e.Label) {
+			t.Errorf("missing edge %s -[%s]-> %s", e.From, e.Label, e.To)
+		}
+	}
+	if hasEdge(g, "build:tpm-install", "directory:config", EdgeDependsOn) {
+		t.Error("build should depend only on the closest containing item")
+	}
+	if hasEdge(g, "dotfile:zsh", "directory:config", EdgeDependsOn) {
+		t.Error("~/.zshrc is not inside ~/.config")
+	}
+
+	if n := node(g, "dotfile:zsh"); n == nil || n.Active || n.Detail != "host filter" {
+		t.Errorf("dotfile:zsh = %+v, want inactive by host filter", n)
+	}
+	if n := node(g, "build:tpm-install"); n == nil || n.Active || n.Detail != "disabled" {
+		t.Errorf("build:tpm-install = %+v, want inactive and disabled", n)
+	}
+	if n := node(g, "recipe:work"); n == nil || n.Active {
+		t.Errorf("recipe:work = %+v, want inactive", n)
+	}
+}
+
+func TestWriteFormats(t *testing.T) {
+	g := Build(testConfig(t), "laptop")
+
+	var dot bytes.Buffer
+	if err := g.WriteDOT(&dot); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"digraph ralph {", `"recipe:editor" -> "dotfile:nvim" [label="defines"];`, "style=dashed"} {
+		if !strings.Contains(dot.String(), want) {
+			t.Errorf("DOT output missing %q:\n%s", want, dot.String())
+		}
+	}
+
+	var mermaid bytes.Buffer
+	if err := g.WriteMermaid(&mermaid); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"flowchart LR", `["dotfile: nvim"]`, "-.->|depends on|", "class "} {
+		if !strings.Contains(mermaid.String(), want) {
+			t.Errorf("Mermaid output missing %q:\n%s", want, mermaid.String())
+		}
+	}
+
+	var out bytes.Buffer
+	if err := g.WriteJSON(&out); err != nil {
+		t.Fatal(err)
+	}
+	var decoded Graph
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if len(decoded.Nodes) != len(g.Nodes) || len(decoded.Edges) != len(g.Edges) {
+		t.Errorf("JSON round trip = %d nodes, %d edges; want %d, %d", len(decoded.Nodes), len(decoded.Edges), len(g.Nodes), len(g.Edges))
+	}
+}
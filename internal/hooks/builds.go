@@ -1,12 +1,16 @@
 package hooks
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
@@ -26,11 +30,7 @@ type BuildRecord struct {
 
 // getStateFilePath returns the path to the builds state file
 func getStateFilePath() (string, error) {
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		return "", fmt.Errorf("could not get user home directory: %w", err)
-	}
-	return filepath.Join(homeDir, ".config", "ralph", ".builds_state"), nil
+	return config.StateFilePath("builds_state")
 }
 
 // LoadBuildState loads the build state from the state file
@@ -89,6 +89,69 @@ type BuildOptions struct {
 	DryRun        bool
 	Force         bool   // Force re-run of "once" builds
 	SpecificBuild string // Run only this specific build (empty = run all applicable)
+	KeepGoing     bool   // Run the remaining builds after one fails, returning all failures joined
+	Stream        bool   // Stream command output to w instead of capturing it to the build log
+}
+
+// BuildError is returned when a build command fails. When the output was
+// captured rather than streamed, it holds what the build printed.
+type BuildError struct {
+	Build   string
+	Command string
+	Output  string // Captured stdout and stderr of the build so far
+	LogPath string // Build log the output was saved to, if any
+	Err     error
+}
+
+func (e *BuildError) Error() string {
+	return fmt.Sprintf("command failed: %s: %v", e.Command, e.Err)
+}
+
+func (e *BuildError) Unwrap() error { return e.Err }
+
+// BuildErrors returns the BuildErrors in err, which may join several.
+func BuildErrors(err error) []*BuildError {
+	if err == nil {
+		return nil
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		var errs []*BuildError
+		for _, e := range joined.Unwrap() {
+			errs = append(errs, BuildErrors(e)...)
+		}
+		return errs
+	}
+	var buildErr *BuildError
+	if errors.As(err, &buildErr) {
+		return []*BuildError{buildErr}
+	}
+	return nil
+}
+
+// BuildLogPath returns the log that the captured output of the last run of
+// the named build is saved to.
+func BuildLogPath(name string) (string, error) {
+	stateDir, err := config.GetStateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(stateDir, "build-logs", strings.ReplaceAll(name, string(filepath.Separator), "_")+".log"), nil
+}
+
+// saveBuildLog writes the captured output of a build to its log and returns
+// the log's path, or "" if it could not be written.
+func saveBuildLog(name string, output []byte) string {
+	path, err := BuildLogPath(name)
+	if err != nil {
+		return ""
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return ""
+	}
+	if err := os.WriteFile(path, output, 0644); err != nil {
+		return ""
+	}
+	return path
 }
 
 // getGitHash returns the current git commit hash for a directory
@@ -146,8 +209,15 @@ func ResetBuildStateForName(name string) error {
 	return nil
 }
 
-// RunBuild executes a build hook
-func RunBuild(w io.Writer, name string, build config.Build, currentHost string, opts BuildOptions) error {
+// BuildCommandLine returns the program and arguments RunBuild executes for
+// one of a build's commands.
+func BuildCommandLine(command string) []string {
+	return []string{"sh", "-c", command}
+}
+
+// RunBuild executes a build hook. Cancelling ctx kills the running command
+// and leaves the build unrecorded, so a "once" build runs again next time.
+func RunBuild(ctx context.Context, w io.Writer, name string, build config.Build, currentHost string, opts BuildOptions) error {
 	// Check enable first
 	if !config.IsEnabled(build.Enable) {
 		fmt.Fprintf(w, "  Skipping build: %s (disabled)\n", name)
@@ -170,49 +240,20 @@ func RunBuild(w io.Writer, name string, build config.Build, currentHost string,
 		}
 	}
 
-	// Check run mode
-	switch build.Run {
-	case "always":
-		// Always run
-	case "once":
-		if !opts.Force {
-			state, err := LoadBuildState()
-			if err != nil {
-				return fmt.Errorf("failed to load build state: %w", err)
-			}
-			if record, exists := state.Builds[name]; exists {
-				// Check if git hash has changed (if we have a working dir and recorded hash)
-				if workingDir != "" && record.GitHash != "" {
-					currentHash := getGitHash(workingDir)
-					if currentHash != "" && currentHash != record.GitHash {
-						fmt.Fprintf(w, "  Build '%s' has git changes (was: %s, now: %s). Re-running.\n",
-							name, record.GitHash[:8], currentHash[:8])
-						// Continue to run the build
-					} else if hasGitChanges(workingDir) {
-						fmt.Fprintf(w, "  Build '%s' has uncommitted changes. Re-running.\n", name)
-						// Continue to run the build
-					} else {
-						fmt.Fprintf(w, "  Build '%s' already completed (run=once). Skipping.\n", name)
-						return nil
-					}
-				} else {
-					fmt.Fprintf(w, "  Build '%s' already completed (run=once). Skipping.\n", name)
-					return nil
-				}
-			}
-		}
-	case "manual":
-		// Manual builds only run when explicitly requested
-		if opts.SpecificBuild != name {
-			fmt.Fprintf(w, "  Build '%s' is manual. Skipping (use --build=%s to run).\n", name, name)
-			return nil
-		}
-	default:
-		return fmt.Errorf("unknown run mode '%s' for build '%s'", build.Run, name)
+	if run, err := shouldRun(w, name, build, workingDir, opts); err != nil || !run {
+		return err
 	}
 
 	fmt.Fprintf(w, "  Running build: %s\n", name)
 
+	if err := ensureWorkingDir(w, build, workingDir, opts.DryRun); err != nil {
+		return err
+	}
+
+	// Unless streaming, the output of every command is captured and only
+	// shown when one fails
+	var output bytes.Buffer
+
 	// Execute each command
 	for i, cmdStr := range build.Commands {
 		if opts.DryRun {
@@ -226,17 +267,35 @@ func RunBuild(w io.Writer, name string, build config.Build, currentHost string,
 
 		fmt.Fprintf(w, "    [%d/%d] %s\n", i+1, len(build.Commands), cmdStr)
 
-		cmd := exec.Command("sh", "-c", cmdStr)
-		cmd.Stdout = w
-		cmd.Stderr = os.Stderr
+		argv := BuildCommandLine(cmdStr)
+		cmd := commandContext(ctx, argv[0], argv[1:]...)
+		if opts.Stream {
+			cmd.Stdout = w
+			cmd.Stderr = os.Stderr
+		} else {
+			fmt.Fprintf(&output, "$ %s\n", cmdStr)
+			cmd.Stdout = &output
+			cmd.Stderr = &output
+		}
 		if workingDir != "" {
 			cmd.Dir = workingDir
 		}
 
 		if err := cmd.Run(); err != nil {
-			return fmt.Errorf("command failed: %s: %w", cmdStr, err)
+			if ctx.Err() != nil {
+				err = ctx.Err()
+			}
+			buildErr := &BuildError{Build: name, Command: cmdStr, Err: err}
+			if !opts.Stream {
+				buildErr.Output = output.String()
+				buildErr.LogPath = saveBuildLog(name, output.Bytes())
+			}
+			return buildErr
 		}
 	}
+	if !opts.DryRun && !opts.Stream {
+		saveBuildLog(name, output.Bytes())
+	}
 
 	// Mark build as completed for "once" mode
 	if !opts.DryRun && build.Run == "once" {
@@ -262,8 +321,115 @@ func RunBuild(w io.Writer, name string, build config.Build, currentHost string,
 	return nil
 }
 
-// RunBuilds executes all build hooks that should run
-func RunBuilds(w io.Writer, builds map[string]config.Build, currentHost string, opts BuildOptions) error {
+// ensureWorkingDir creates the working directory of a build if it doesn't
+// exist yet. The checkout of a linked repo is left to the repos phase, so a
+// missing one is an error.
+func ensureWorkingDir(w io.Writer, build config.Build, workingDir string, dryRun bool) error {
+	if workingDir == "" {
+		return nil
+	}
+	if _, err := os.Stat(workingDir); err == nil || !os.IsNotExist(err) {
+		return err
+	}
+	if build.Repo != "" {
+		if dryRun {
+			fmt.Fprintf(w, "    [DRY RUN] Would run in '%s' once repo '%s' is cloned\n", workingDir, build.Repo)
+			return nil
+		}
+		return fmt.Errorf("working directory '%s' does not exist: is repo '%s' cloned?", workingDir, build.Repo)
+	}
+	if dryRun {
+		fmt.Fprintf(w, "    [DRY RUN] Would create working directory '%s'\n", workingDir)
+		return nil
+	}
+	if err := os.MkdirAll(workingDir, 0755); err != nil {
+		return fmt.Errorf("failed to create working directory '%s': %w", workingDir, err)
+	}
+	fmt.Fprintf(w, "    Created working directory '%s'\n", workingDir)
+	return nil
+}
+
+// shouldRun reports whether a build is due according to its run mode,
+// explaining to w why it is skipped or re-run.
+func shouldRun(w io.Writer, name string, build config.Build, workingDir string, opts BuildOptions) (bool, error) {
+	// Check run mode
+	switch build.Run {
+	case "always":
+		// Always run
+	case "once":
+		if !opts.Force {
+			state, err := LoadBuildState()
+			if err != nil {
+				return false, fmt.Errorf("failed to load build state: %w", err)
+			}
+			if record, exists := state.Builds[name]; exists {
+				// Check if git hash has changed (if we have a working dir and recorded hash)
+				if workingDir != "" && record.GitHash != "" {
+					currentHash := getGitHash(workingDir)
+					if currentHash != "" && currentHash != record.GitHash {
+						fmt.Fprintf(w, "  Build '%s' has git changes (was: %s, now: %s). Re-running.\n",
+							name, record.GitHash[:8], currentHash[:8])
+						// Continue to run the build
+					} else if hasGitChanges(workingDir) {
+						fmt.Fprintf(w, "  Build '%s' has uncommitted changes. Re-running.\n", name)
+						// Continue to run the build
+					} else {
+						fmt.Fprintf(w, "  Build '%s' already completed (run=once). Skipping.\n", name)
+						return false, nil
+					}
+				} else {
+					fmt.Fprintf(w, "  Build '%s' already completed (run=once). Skipping.\n", name)
+					return false, nil
+				}
+			}
+		}
+	case "manual":
+		// Manual builds only run when explicitly requested
+		if opts.SpecificBuild != name {
+			fmt.Fprintf(w, "  Build '%s' is manual. Skipping (use --build=%s to run).\n", name, name)
+			return false, nil
+		}
+	default:
+		return false, fmt.Errorf("unknown run mode '%s' for build '%s'", build.Run, name)
+	}
+	return true, nil
+}
+
+// PendingBuilds returns the names of the enabled "once" and requested
+// "manual" builds that apply would run, sorted. "always" builds are left out:
+// they run on every apply by design, so they never indicate drift.
+func PendingBuilds(builds map[string]config.Build, currentHost string, opts BuildOptions) ([]string, error) {
+	var pending []string
+	for name, build := range builds {
+		if build.Run == "always" || !config.IsEnabled(build.Enable) || !config.ShouldApplyForHost(build.Hosts, currentHost) {
+			continue
+		}
+		if opts.SpecificBuild != "" && opts.SpecificBuild != name {
+			continue
+		}
+		workingDir := ""
+		if build.WorkingDir != "" {
+			var err error
+			workingDir, err = config.ExpandPath(build.WorkingDir)
+			if err != nil {
+				return nil, fmt.Errorf("failed to expand working directory '%s': %w", build.WorkingDir, err)
+			}
+		}
+		run, err := shouldRun(io.Discard, name, build, workingDir, opts)
+		if err != nil {
+			return nil, err
+		}
+		if run {
+			pending = append(pending, name)
+		}
+	}
+	sort.Strings(pending)
+	return pending, nil
+}
+
+// RunBuilds executes all build hooks that should run. Once ctx is cancelled
+// no further builds are started.
+func RunBuilds(ctx context.Context, w io.Writer, builds map[string]config.Build, currentHost string, opts BuildOptions) error {
 	if len(builds) == 0 {
 		return nil
 	}
@@ -276,17 +442,29 @@ func RunBuilds(w io.Writer, builds map[string]config.Build, currentHost string,
 		if !exists {
 			return fmt.Errorf("build '%s' not found in configuration", opts.SpecificBuild)
 		}
-		if err := RunBuild(w, opts.SpecificBuild, build, currentHost, opts); err != nil {
+		if err := RunBuild(ctx, w, opts.SpecificBuild, build, currentHost, opts); err != nil {
 			return fmt.Errorf("build '%s' failed: %w", opts.SpecificBuild, err)
 		}
 		return nil
 	}
 
 	// Run all applicable builds
+	var errs []error
 	for name, build := range builds {
-		if err := RunBuild(w, name, build, currentHost, opts); err != nil {
-			return fmt.Errorf("build '%s' failed: %w", name, err)
+		if ctx.Err() != nil {
+			err := fmt.Errorf("build '%s' not started: %w", name, ctx.Err())
+			if !opts.KeepGoing {
+				return err
+			}
+			errs = append(errs, err)
+			continue
+		}
+		if err := RunBuild(ctx, w, name, build, currentHost, opts); err != nil {
+			if !opts.KeepGoing {
+				return fmt.Errorf("build '%s' failed: %w", name, err)
+			}
+			errs = append(errs, fmt.Errorf("build '%s' failed: %w", name, err))
 		}
 	}
-	return nil
+	return errors.Join(errs...)
 }
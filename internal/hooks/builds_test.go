@@ -1,11 +1,14 @@
 package hooks
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -51,7 +54,7 @@ func TestLoadBuildState_ValidJSON(t *testing.T) {
 	defer cleanup()
 
 	// Create state file with test data
-	stateDir := filepath.Join(tmpDir, ".config", "ralph")
+	stateDir := filepath.Join(tmpDir, ".local", "state", "ralph")
 	if err := os.MkdirAll(stateDir, 0755); err != nil {
 		t.Fatalf("failed to create state dir: %v", err)
 	}
@@ -65,7 +68,7 @@ func TestLoadBuildState_ValidJSON(t *testing.T) {
 		},
 	}
 	data, _ := json.MarshalIndent(testState, "", "  ")
-	if err := os.WriteFile(filepath.Join(stateDir, ".builds_state"), data, 0644); err != nil {
+	if err := os.WriteFile(filepath.Join(stateDir, "builds_state"), data, 0644); err != nil {
 		t.Fatalf("failed to write state file: %v", err)
 	}
 
@@ -89,13 +92,13 @@ func TestLoadBuildState_InvalidJSON(t *testing.T) {
 	tmpDir, cleanup := testStateDir(t)
 	defer cleanup()
 
-	stateDir := filepath.Join(tmpDir, ".config", "ralph")
+	stateDir := filepath.Join(tmpDir, ".local", "state", "ralph")
 	if err := os.MkdirAll(stateDir, 0755); err != nil {
 		t.Fatalf("failed to create state dir: %v", err)
 	}
 
 	// Write invalid JSON
-	if err := os.WriteFile(filepath.Join(stateDir, ".builds_state"), []byte("not valid json"), 0644); err != nil {
+	if err := os.WriteFile(filepath.Join(stateDir, "builds_state"), []byte("not valid json"), 0644); err != nil {
 		t.Fatalf("failed to write state file: %v", err)
 	}
 
@@ -125,13 +128,13 @@ func TestSaveBuildState_CreatesDirectory(t *testing.T) {
 	}
 
 	// Verify directory was created
-	stateDir := filepath.Join(tmpDir, ".config", "ralph")
+	stateDir := filepath.Join(tmpDir, ".local", "state", "ralph")
 	if _, err := os.Stat(stateDir); os.IsNotExist(err) {
 		t.Error("expected state directory to be created")
 	}
 
 	// Verify file was created
-	statePath := filepath.Join(stateDir, ".builds_state")
+	statePath := filepath.Join(stateDir, "builds_state")
 	if _, err := os.Stat(statePath); os.IsNotExist(err) {
 		t.Error("expected state file to be created")
 	}
@@ -190,7 +193,7 @@ func TestResetBuildState_ClearsAllState(t *testing.T) {
 	}
 
 	// Verify file exists
-	statePath := filepath.Join(tmpDir, ".config", "ralph", ".builds_state")
+	statePath := filepath.Join(tmpDir, ".local", "state", "ralph", "builds_state")
 	if _, err := os.Stat(statePath); os.IsNotExist(err) {
 		t.Fatal("expected state file to exist before reset")
 	}
@@ -306,7 +309,7 @@ func TestRunBuild_AlwaysRuns(t *testing.T) {
 	SaveBuildState(state)
 
 	opts := BuildOptions{DryRun: true}
-	err := RunBuild(io.Discard, "always_build", testBuild("always"), "testhost", opts)
+	err := RunBuild(context.Background(), io.Discard, "always_build", testBuild("always"), "testhost", opts)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -319,7 +322,7 @@ func TestRunBuild_OnceNoPriorState_Runs(t *testing.T) {
 
 	// No prior state - build should run
 	opts := BuildOptions{DryRun: true}
-	err := RunBuild(io.Discard, "new_build", testBuild("once"), "testhost", opts)
+	err := RunBuild(context.Background(), io.Discard, "new_build", testBuild("once"), "testhost", opts)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -345,7 +348,7 @@ func TestRunBuild_OnceWithPriorState_NoWorkingDir_Skips(t *testing.T) {
 	}
 
 	opts := BuildOptions{DryRun: true}
-	err := RunBuild(io.Discard, "completed_build", build, "testhost", opts)
+	err := RunBuild(context.Background(), io.Discard, "completed_build", build, "testhost", opts)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -394,7 +397,7 @@ func TestRunBuild_OnceWithPriorState_SameHash_NoChanges_Skips(t *testing.T) {
 	}
 
 	opts := BuildOptions{DryRun: true}
-	err := RunBuild(io.Discard, "git_build", build, "testhost", opts)
+	err := RunBuild(context.Background(), io.Discard, "git_build", build, "testhost", opts)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -440,7 +443,7 @@ func TestRunBuild_OnceWithPriorState_DifferentHash_Reruns(t *testing.T) {
 	}
 
 	opts := BuildOptions{DryRun: true}
-	err := RunBuild(io.Discard, "git_build", build, "testhost", opts)
+	err := RunBuild(context.Background(), io.Discard, "git_build", build, "testhost", opts)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -489,7 +492,7 @@ func TestRunBuild_OnceWithPriorState_UncommittedChanges_Reruns(t *testing.T) {
 	}
 
 	opts := BuildOptions{DryRun: true}
-	err := RunBuild(io.Discard, "git_build", build, "testhost", opts)
+	err := RunBuild(context.Background(), io.Discard, "git_build", build, "testhost", opts)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -501,7 +504,7 @@ func TestRunBuild_ManualWithoutFlag_Skips(t *testing.T) {
 	defer cleanup()
 
 	opts := BuildOptions{DryRun: true}
-	err := RunBuild(io.Discard, "manual_build", testBuild("manual"), "testhost", opts)
+	err := RunBuild(context.Background(), io.Discard, "manual_build", testBuild("manual"), "testhost", opts)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -516,7 +519,7 @@ func TestRunBuild_ManualWithMatchingFlag_Runs(t *testing.T) {
 		DryRun:        true,
 		SpecificBuild: "manual_build",
 	}
-	err := RunBuild(io.Discard, "manual_build", testBuild("manual"), "testhost", opts)
+	err := RunBuild(context.Background(), io.Discard, "manual_build", testBuild("manual"), "testhost", opts)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -539,7 +542,7 @@ func TestRunBuild_ForceOverridesOnce(t *testing.T) {
 		DryRun: true,
 		Force:  true,
 	}
-	err := RunBuild(io.Discard, "force_build", testBuild("once"), "testhost", opts)
+	err := RunBuild(context.Background(), io.Discard, "force_build", testBuild("once"), "testhost", opts)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -556,7 +559,7 @@ func TestRunBuild_InvalidRunMode(t *testing.T) {
 	}
 
 	opts := BuildOptions{DryRun: true}
-	err := RunBuild(io.Discard, "bad_build", build, "testhost", opts)
+	err := RunBuild(context.Background(), io.Discard, "bad_build", build, "testhost", opts)
 	if err == nil {
 		t.Fatal("expected error for invalid run mode")
 	}
@@ -577,7 +580,7 @@ func TestRunBuild_SavesStateAfterOnceRun(t *testing.T) {
 	}
 
 	opts := BuildOptions{DryRun: false} // Actually run
-	err := RunBuild(io.Discard, "save_test", build, "testhost", opts)
+	err := RunBuild(context.Background(), io.Discard, "save_test", build, "testhost", opts)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -603,7 +606,7 @@ func TestRunBuild_DryRunDoesNotSaveState(t *testing.T) {
 	}
 
 	opts := BuildOptions{DryRun: true}
-	err := RunBuild(io.Discard, "dry_run_test", build, "testhost", opts)
+	err := RunBuild(context.Background(), io.Discard, "dry_run_test", build, "testhost", opts)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -622,12 +625,12 @@ func TestRunBuild_DryRunDoesNotSaveState(t *testing.T) {
 // --- Tests for RunBuilds ---
 
 func TestRunBuilds_EmptyBuilds(t *testing.T) {
-	err := RunBuilds(io.Discard, nil, "testhost", BuildOptions{})
+	err := RunBuilds(context.Background(), io.Discard, nil, "testhost", BuildOptions{})
 	if err != nil {
 		t.Fatalf("unexpected error for empty builds: %v", err)
 	}
 
-	err = RunBuilds(io.Discard, map[string]config.Build{}, "testhost", BuildOptions{})
+	err = RunBuilds(context.Background(), io.Discard, map[string]config.Build{}, "testhost", BuildOptions{})
 	if err != nil {
 		t.Fatalf("unexpected error for empty builds map: %v", err)
 	}
@@ -639,7 +642,7 @@ func TestRunBuilds_SpecificBuildNotFound(t *testing.T) {
 	}
 
 	opts := BuildOptions{SpecificBuild: "nonexistent"}
-	err := RunBuilds(io.Discard, builds, "testhost", opts)
+	err := RunBuilds(context.Background(), io.Discard, builds, "testhost", opts)
 	if err == nil {
 		t.Fatal("expected error for non-existent specific build")
 	}
@@ -658,12 +661,37 @@ func TestRunBuilds_SpecificBuildRuns(t *testing.T) {
 		DryRun:        true,
 		SpecificBuild: "target",
 	}
-	err := RunBuilds(io.Discard, builds, "testhost", opts)
+	err := RunBuilds(context.Background(), io.Discard, builds, "testhost", opts)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 }
 
+func TestRunBuilds_KeepGoingCollectsAllFailures(t *testing.T) {
+	_, cleanup := testStateDir(t)
+	defer cleanup()
+
+	builds := map[string]config.Build{
+		"first":  testBuild("bogus"),
+		"second": testBuild("bogus"),
+	}
+
+	err := RunBuilds(context.Background(), io.Discard, builds, "testhost", BuildOptions{DryRun: true})
+	if err == nil || (strings.Contains(err.Error(), "first") && strings.Contains(err.Error(), "second")) {
+		t.Fatalf("expected only the first failure without KeepGoing, got %v", err)
+	}
+
+	err = RunBuilds(context.Background(), io.Discard, builds, "testhost", BuildOptions{DryRun: true, KeepGoing: true})
+	if err == nil {
+		t.Fatal("expected an error with KeepGoing")
+	}
+	for _, name := range []string{"first", "second"} {
+		if !strings.Contains(err.Error(), name) {
+			t.Errorf("expected error to mention build %q, got %v", name, err)
+		}
+	}
+}
+
 // --- Tests for Host Filtering ---
 
 func TestRunBuild_HostFilter_MatchingHost_Runs(t *testing.T) {
@@ -677,7 +705,7 @@ func TestRunBuild_HostFilter_MatchingHost_Runs(t *testing.T) {
 	}
 
 	opts := BuildOptions{DryRun: true}
-	err := RunBuild(io.Discard, "host_test", build, "matchinghost", opts)
+	err := RunBuild(context.Background(), io.Discard, "host_test", build, "matchinghost", opts)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -695,7 +723,7 @@ func TestRunBuild_HostFilter_NonMatchingHost_Skips(t *testing.T) {
 	}
 
 	opts := BuildOptions{DryRun: true}
-	err := RunBuild(io.Discard, "host_test", build, "myhost", opts)
+	err := RunBuild(context.Background(), io.Discard, "host_test", build, "myhost", opts)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -713,7 +741,7 @@ func TestRunBuild_HostFilter_EmptyHosts_Runs(t *testing.T) {
 	}
 
 	opts := BuildOptions{DryRun: true}
-	err := RunBuild(io.Discard, "host_test", build, "anyhost", opts)
+	err := RunBuild(context.Background(), io.Discard, "host_test", build, "anyhost", opts)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -731,7 +759,7 @@ func TestRunBuild_HostFilter_CaseInsensitive(t *testing.T) {
 	}
 
 	opts := BuildOptions{DryRun: true}
-	err := RunBuild(io.Discard, "host_test", build, "myhost", opts) // Lowercase current host
+	err := RunBuild(context.Background(), io.Discard, "host_test", build, "myhost", opts) // Lowercase current host
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -752,7 +780,7 @@ func TestRunBuild_Disabled_Skips(t *testing.T) {
 	}
 
 	opts := BuildOptions{DryRun: true}
-	err := RunBuild(io.Discard, "disabled_build", build, "testhost", opts)
+	err := RunBuild(context.Background(), io.Discard, "disabled_build", build, "testhost", opts)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -771,7 +799,7 @@ func TestRunBuild_Enabled_Runs(t *testing.T) {
 	}
 
 	opts := BuildOptions{DryRun: true}
-	err := RunBuild(io.Discard, "enabled_build", build, "testhost", opts)
+	err := RunBuild(context.Background(), io.Discard, "enabled_build", build, "testhost", opts)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -789,13 +817,50 @@ func TestRunBuild_EnableNotSet_Runs(t *testing.T) {
 	}
 
 	opts := BuildOptions{DryRun: true}
-	err := RunBuild(io.Discard, "default_enabled_build", build, "testhost", opts)
+	err := RunBuild(context.Background(), io.Discard, "default_enabled_build", build, "testhost", opts)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 	// Should run since enable not set means enabled
 }
 
+func TestPendingBuilds(t *testing.T) {
+	_, cleanup := testStateDir(t)
+	defer cleanup()
+
+	SaveBuildState(&BuildState{
+		Builds: map[string]BuildRecord{
+			"done": {CompletedAt: time.Now()},
+		},
+	})
+	disabled := false
+	builds := map[string]config.Build{
+		"always":   testBuild("always"),
+		"done":     testBuild("once"),
+		"new":      testBuild("once"),
+		"manual":   testBuild("manual"),
+		"disabled": {Commands: []string{"echo test"}, Run: "once", Enable: &disabled},
+	}
+
+	pending, err := PendingBuilds(builds, "testhost", BuildOptions{DryRun: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Join(pending, ",") != "new" {
+		t.Errorf("pending = %v, want [new]", pending)
+	}
+
+	pending, _ = PendingBuilds(builds, "testhost", BuildOptions{DryRun: true, Force: true})
+	if strings.Join(pending, ",") != "done,new" {
+		t.Errorf("pending with force = %v, want [done new]", pending)
+	}
+
+	pending, _ = PendingBuilds(builds, "testhost", BuildOptions{DryRun: true, SpecificBuild: "manual"})
+	if strings.Join(pending, ",") != "manual" {
+		t.Errorf("pending for --build=manual = %v, want [manual]", pending)
+	}
+}
+
 // --- Helper functions ---
 
 func runGitCmd(t *testing.T, dir string, args ...string) {
@@ -807,3 +872,132 @@ func runGitCmd(t *testing.T, dir string, args ...string) {
 		t.Logf("git command failed: %s, output: %s", err, output)
 	}
 }
+
+// --- Tests for output capture ---
+
+func TestRunBuild_CapturesOutputToLog(t *testing.T) {
+	home, cleanup := testStateDir(t)
+	defer cleanup()
+	t.Setenv("XDG_STATE_HOME", filepath.Join(home, "state"))
+
+	var w strings.Builder
+	build := config.Build{Commands: []string{"echo out", "echo err >&2"}, Run: "always"}
+	if err := RunBuild(context.Background(), &w, "quiet", build, "testhost", BuildOptions{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Contains(w.String(), "\nout\n") {
+		t.Errorf("captured output leaked to w: %q", w.String())
+	}
+	logPath, _ := BuildLogPath("quiet")
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatalf("build log not written: %v", err)
+	}
+	if want := "$ echo out\nout\n$ echo err >&2\nerr\n"; string(data) != want {
+		t.Errorf("build log = %q, want %q", data, want)
+	}
+}
+
+func TestRunBuild_FailureCarriesOutput(t *testing.T) {
+	home, cleanup := testStateDir(t)
+	defer cleanup()
+	t.Setenv("XDG_STATE_HOME", filepath.Join(home, "state"))
+
+	builds := map[string]config.Build{
+		"broken": {Commands: []string{"echo compiling", "echo boom >&2; exit 2", "echo never"}, Run: "always"},
+	}
+	err := RunBuilds(context.Background(), io.Discard, builds, "testhost", BuildOptions{KeepGoing: true})
+	errs := BuildErrors(err)
+	if len(errs) != 1 {
+		t.Fatalf("BuildErrors(%v) = %v, want one error", err, errs)
+	}
+	got := errs[0]
+	if got.Build != "broken" || got.Command != "echo boom >&2; exit 2" {
+		t.Errorf("BuildError = %+v", got)
+	}
+	if want := "$ echo compiling\ncompiling\n$ echo boom >&2; exit 2\nboom\n"; got.Output != want {
+		t.Errorf("Output = %q, want %q", got.Output, want)
+	}
+	if data, _ := os.ReadFile(got.LogPath); string(data) != got.Output {
+		t.Errorf("log %s = %q, want the captured output", got.LogPath, data)
+	}
+}
+
+func TestRunBuild_StreamWritesToW(t *testing.T) {
+	_, cleanup := testStateDir(t)
+	defer cleanup()
+
+	var w strings.Builder
+	build := config.Build{Commands: []string{"echo streamed"}, Run: "always"}
+	if err := RunBuild(context.Background(), &w, "loud", build, "testhost", BuildOptions{Stream: true}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(w.String(), "streamed\n") {
+		t.Errorf("streamed output missing from w: %q", w.String())
+	}
+	var buildErr *BuildError
+	err := RunBuild(context.Background(), io.Discard, "loud", config.Build{Commands: []string{"exit 1"}, Run: "always"}, "testhost", BuildOptions{Stream: true})
+	if !errors.As(err, &buildErr) || buildErr.Output != "" || buildErr.LogPath != "" {
+		t.Errorf("streamed failure = %#v, want a BuildError without output", err)
+	}
+}
+
+// --- Tests for working directories ---
+
+func TestRunBuild_CreatesWorkingDir(t *testing.T) {
+	home, cleanup := testStateDir(t)
+	defer cleanup()
+
+	dir := filepath.Join(home, "build", "out")
+	build := config.Build{Commands: []string{"touch built"}, WorkingDir: dir, Run: "always"}
+	if err := RunBuild(context.Background(), io.Discard, "mk", build, "testhost", BuildOptions{DryRun: true}); err != nil {
+		t.Fatalf("dry run: unexpected error: %v", err)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Error("dry run should not create the working directory")
+	}
+	if err := RunBuild(context.Background(), io.Discard, "mk", build, "testhost", BuildOptions{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "built")); err != nil {
+		t.Errorf("build did not run in the created working directory: %v", err)
+	}
+}
+
+func TestRunBuild_MissingRepoCheckout(t *testing.T) {
+	home, cleanup := testStateDir(t)
+	defer cleanup()
+
+	dir := filepath.Join(home, "src", "fzf")
+	build := config.Build{Repo: "fzf", Commands: []string{"true"}, WorkingDir: dir, Run: "always"}
+	err := RunBuild(context.Background(), io.Discard, "fzf", build, "testhost", BuildOptions{})
+	if err == nil || !strings.Contains(err.Error(), "repo 'fzf'") {
+		t.Errorf("RunBuild() error = %v, want one naming the repo", err)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Error("the checkout of a linked repo should not be created")
+	}
+}
+
+func TestRunBuilds_Cancelled(t *testing.T) {
+	_, cleanup := testStateDir(t)
+	defer cleanup()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	builds := map[string]config.Build{
+		"a": {Commands: []string{"true"}, Run: "once"},
+		"b": {Commands: []string{"true"}, Run: "once"},
+	}
+	err := RunBuilds(ctx, io.Discard, builds, "testhost", BuildOptions{KeepGoing: true})
+	if !errors.Is(err, context.Canceled) || len(BuildErrors(err)) != 0 {
+		t.Fatalf("RunBuilds() error = %v, want both builds not started", err)
+	}
+	if !strings.Contains(err.Error(), "build 'a' not started") || !strings.Contains(err.Error(), "build 'b' not started") {
+		t.Errorf("RunBuilds() error = %v, want both builds named", err)
+	}
+	state, _ := LoadBuildState()
+	if len(state.Builds) != 0 {
+		t.Errorf("build state = %v, want nothing recorded", state.Builds)
+	}
+}
@@ -1,11 +1,18 @@
 package hooks
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
 	"strings"
+	"sync"
+	"time"
+
+	"github.com/mad01/ralph/internal/config"
 )
 
 // HookType represents the different types of hooks that can be triggered.
@@ -34,44 +41,156 @@ type HookContext struct {
 	DryRun bool
 }
 
-// Run executes a hook script with the given context
-func Run(w io.Writer, script string, context *HookContext, dryRun bool) error {
+// hookStderr receives the stderr of hooks. Unlike their stdout, which only
+// shows with --verbose, it is always printed so a failing hook can be told
+// apart. Tests replace it.
+var hookStderr io.Writer = os.Stderr
+
+// Run executes a hook script with the given context. Cancelling ctx kills
+// the script.
+func Run(ctx context.Context, w io.Writer, script string, hookContext *HookContext, dryRun bool) error {
+	return run(ctx, w, hookStderr, script, hookContext, dryRun)
+}
+
+// run is Run with the script's stderr going to errw.
+func run(ctx context.Context, w, errw io.Writer, script string, hookContext *HookContext, dryRun bool) error {
 	// Expand the script command with context variables
-	expandedScript := expandVariables(script, context)
+	expandedScript := expandVariables(script, hookContext)
 
 	if dryRun {
 		fmt.Fprintf(w, "[DRY RUN] Would run hook: %s\n", expandedScript)
 		return nil
 	}
 
-	// Split the command and arguments
-	parts := strings.Fields(expandedScript)
+	parts := CommandLine(script, hookContext)
 	if len(parts) == 0 {
 		return fmt.Errorf("empty hook command")
 	}
 
-	cmd := exec.Command(parts[0], parts[1:]...)
+	cmd := commandContext(ctx, parts[0], parts[1:]...)
 	cmd.Stdout = w
-	cmd.Stderr = os.Stderr
+	cmd.Stderr = errw
 
-	return cmd.Run()
+	err := cmd.Run()
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+// CommandLine returns the program and arguments Run executes for script:
+// the script with hookContext's placeholders filled in, split on whitespace.
+// Hooks are not run through a shell.
+func CommandLine(script string, hookContext *HookContext) []string {
+	return strings.Fields(expandVariables(script, hookContext))
 }
 
-// RunHooks executes all hooks of a specific type with the given context
-func RunHooks(w io.Writer, scripts []string, hookType HookType, context *HookContext, dryRun bool) error {
+// RunHooks executes all hooks of a specific type with the given context.
+// Once ctx is cancelled no further hooks are started.
+func RunHooks(ctx context.Context, w io.Writer, scripts []string, hookType HookType, hookContext *HookContext, dryRun bool) error {
 	if len(scripts) == 0 {
 		return nil
 	}
 
 	fmt.Fprintf(w, "Running %s hooks...\n", hookType)
+	return runInOrder(ctx, w, scripts, hookContext, dryRun)
+}
+
+// runInOrder runs scripts one after the other, stopping at the first that
+// fails or once ctx is cancelled.
+func runInOrder(ctx context.Context, w io.Writer, scripts []string, hookContext *HookContext, dryRun bool) error {
 	for _, script := range scripts {
-		if err := Run(w, script, context, dryRun); err != nil {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := Run(ctx, w, script, hookContext, dryRun); err != nil {
 			return fmt.Errorf("hook %s failed: %w", script, err)
 		}
 	}
 	return nil
 }
 
+// RunGroups executes the pre_apply or post_apply hook groups in order,
+// stopping at the first one that fails. The commands of a parallel group are
+// started together and all run to the end; their output is printed once the
+// group is done, in the order they are listed. Once ctx is cancelled no
+// further hooks are started.
+func RunGroups(ctx context.Context, w io.Writer, groups []config.HookGroup, hookType HookType, hookContext *HookContext, dryRun bool) error {
+	if len(groups) == 0 {
+		return nil
+	}
+
+	fmt.Fprintf(w, "Running %s hooks...\n", hookType)
+	for _, g := range groups {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if g.Name != "" {
+			mode := "in order"
+			if g.Parallel {
+				mode = "in parallel"
+			}
+			fmt.Fprintf(w, "Group %s (%d commands, %s)\n", g.Name, len(g.Commands), mode)
+		}
+		var err error
+		if g.Parallel && !dryRun {
+			err = runParallel(ctx, w, g.Commands, hookContext)
+		} else {
+			err = runInOrder(ctx, w, g.Commands, hookContext, dryRun)
+		}
+		if err != nil {
+			if g.Name != "" {
+				return fmt.Errorf("group %s: %w", g.Name, err)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// runParallel runs scripts at the same time and returns their failures
+// joined. Each script's stdout and stderr are buffered and written to w and
+// hookStderr in order, as a sequential hook's would be.
+func runParallel(ctx context.Context, w io.Writer, scripts []string, hookContext *HookContext) error {
+	outputs := make([]bytes.Buffer, len(scripts))
+	errOutputs := make([]bytes.Buffer, len(scripts))
+	errs := make([]error, len(scripts))
+	var wg sync.WaitGroup
+	for i, script := range scripts {
+		wg.Add(1)
+		go func(i int, script string) {
+			defer wg.Done()
+			if err := run(ctx, &outputs[i], &errOutputs[i], script, hookContext, false); err != nil {
+				errs[i] = fmt.Errorf("hook %s failed: %w", script, err)
+			}
+		}(i, script)
+	}
+	wg.Wait()
+
+	for i := range scripts {
+		w.Write(outputs[i].Bytes())
+		hookStderr.Write(errOutputs[i].Bytes())
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return errors.Join(errs...)
+}
+
+// killWaitDelay is how long a cancelled command's children may hold on to
+// its output after the command was killed.
+const killWaitDelay = time.Second
+
+// commandContext is exec.CommandContext for hooks, builds and verify
+// commands. Killing the shell does not kill what it started, so once ctx is
+// cancelled Wait stops waiting for output after killWaitDelay instead of
+// until the orphaned children exit.
+func commandContext(ctx context.Context, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.WaitDelay = killWaitDelay
+	return cmd
+}
+
 // expandVariables replaces placeholder variables in the script with context values
 func expandVariables(script string, context *HookContext) string {
 	if context == nil {
@@ -1,8 +1,17 @@
 package hooks
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"io"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/mad01/ralph/internal/config"
 )
 
 // --- Tests for expandVariables ---
@@ -106,21 +115,21 @@ func TestExpandVariables_EmptyContextValues(t *testing.T) {
 
 func TestRun_DryRunDoesNotExecute(t *testing.T) {
 	// Dry run should not actually execute the command
-	err := Run(io.Discard, "echo test", &HookContext{}, true)
+	err := Run(context.Background(), io.Discard, "echo test", &HookContext{}, true)
 	if err != nil {
 		t.Errorf("expected no error in dry run, got: %v", err)
 	}
 }
 
 func TestRun_EmptyCommand(t *testing.T) {
-	err := Run(io.Discard, "", &HookContext{}, false)
+	err := Run(context.Background(), io.Discard, "", &HookContext{}, false)
 	if err == nil {
 		t.Error("expected error for empty command")
 	}
 }
 
 func TestRun_WhitespaceOnlyCommand(t *testing.T) {
-	err := Run(io.Discard, "   ", &HookContext{}, false)
+	err := Run(context.Background(), io.Discard, "   ", &HookContext{}, false)
 	if err == nil {
 		t.Error("expected error for whitespace-only command")
 	}
@@ -128,32 +137,32 @@ func TestRun_WhitespaceOnlyCommand(t *testing.T) {
 
 func TestRun_SimpleCommand(t *testing.T) {
 	// Test that a simple command runs successfully
-	err := Run(io.Discard, "true", nil, false)
+	err := Run(context.Background(), io.Discard, "true", nil, false)
 	if err != nil {
 		t.Errorf("expected no error, got: %v", err)
 	}
 }
 
 func TestRun_FailingCommand(t *testing.T) {
-	err := Run(io.Discard, "false", nil, false)
+	err := Run(context.Background(), io.Discard, "false", nil, false)
 	if err == nil {
 		t.Error("expected error for failing command")
 	}
 }
 
 func TestRun_CommandWithArguments(t *testing.T) {
-	err := Run(io.Discard, "test -d /tmp", nil, false)
+	err := Run(context.Background(), io.Discard, "test -d /tmp", nil, false)
 	if err != nil {
 		t.Errorf("expected no error, got: %v", err)
 	}
 }
 
 func TestRun_VariableExpansion(t *testing.T) {
-	context := &HookContext{
+	hookContext := &HookContext{
 		DotfileName: "test_file",
 	}
 	// Use a command that will succeed if the variable is expanded
-	err := Run(io.Discard, "test {dotfile} = test_file", context, false)
+	err := Run(context.Background(), io.Discard, "test {dotfile} = test_file", hookContext, false)
 	if err != nil {
 		t.Errorf("expected variable expansion to work, got: %v", err)
 	}
@@ -162,12 +171,12 @@ func TestRun_VariableExpansion(t *testing.T) {
 // --- Tests for RunHooks ---
 
 func TestRunHooks_EmptyScripts(t *testing.T) {
-	err := RunHooks(io.Discard, nil, PreApply, &HookContext{}, false)
+	err := RunHooks(context.Background(), io.Discard, nil, PreApply, &HookContext{}, false)
 	if err != nil {
 		t.Errorf("expected no error for nil scripts, got: %v", err)
 	}
 
-	err = RunHooks(io.Discard, []string{}, PostApply, &HookContext{}, false)
+	err = RunHooks(context.Background(), io.Discard, []string{}, PostApply, &HookContext{}, false)
 	if err != nil {
 		t.Errorf("expected no error for empty scripts, got: %v", err)
 	}
@@ -175,7 +184,7 @@ func TestRunHooks_EmptyScripts(t *testing.T) {
 
 func TestRunHooks_SingleScript(t *testing.T) {
 	scripts := []string{"true"}
-	err := RunHooks(io.Discard, scripts, PreApply, &HookContext{}, false)
+	err := RunHooks(context.Background(), io.Discard, scripts, PreApply, &HookContext{}, false)
 	if err != nil {
 		t.Errorf("expected no error, got: %v", err)
 	}
@@ -183,7 +192,7 @@ func TestRunHooks_SingleScript(t *testing.T) {
 
 func TestRunHooks_MultipleScripts(t *testing.T) {
 	scripts := []string{"true", "true", "true"}
-	err := RunHooks(io.Discard, scripts, PostApply, &HookContext{}, false)
+	err := RunHooks(context.Background(), io.Discard, scripts, PostApply, &HookContext{}, false)
 	if err != nil {
 		t.Errorf("expected no error, got: %v", err)
 	}
@@ -192,7 +201,7 @@ func TestRunHooks_MultipleScripts(t *testing.T) {
 func TestRunHooks_StopsOnFirstFailure(t *testing.T) {
 	// Second script fails - should stop there
 	scripts := []string{"true", "false", "true"}
-	err := RunHooks(io.Discard, scripts, PreLink, &HookContext{}, false)
+	err := RunHooks(context.Background(), io.Discard, scripts, PreLink, &HookContext{}, false)
 	if err == nil {
 		t.Error("expected error when script fails")
 	}
@@ -201,36 +210,118 @@ func TestRunHooks_StopsOnFirstFailure(t *testing.T) {
 func TestRunHooks_DryRun(t *testing.T) {
 	// With dry run, even a failing command shouldn't error
 	scripts := []string{"false"}
-	err := RunHooks(io.Discard, scripts, PostLink, &HookContext{}, true)
+	err := RunHooks(context.Background(), io.Discard, scripts, PostLink, &HookContext{}, true)
 	if err != nil {
 		t.Errorf("expected no error in dry run mode, got: %v", err)
 	}
 }
 
 func TestRunHooks_HookTypePreApply(t *testing.T) {
-	err := RunHooks(io.Discard, []string{"true"}, PreApply, nil, false)
+	err := RunHooks(context.Background(), io.Discard, []string{"true"}, PreApply, nil, false)
 	if err != nil {
 		t.Errorf("unexpected error: %v", err)
 	}
 }
 
 func TestRunHooks_HookTypePostApply(t *testing.T) {
-	err := RunHooks(io.Discard, []string{"true"}, PostApply, nil, false)
+	err := RunHooks(context.Background(), io.Discard, []string{"true"}, PostApply, nil, false)
 	if err != nil {
 		t.Errorf("unexpected error: %v", err)
 	}
 }
 
 func TestRunHooks_HookTypePreLink(t *testing.T) {
-	err := RunHooks(io.Discard, []string{"true"}, PreLink, nil, false)
+	err := RunHooks(context.Background(), io.Discard, []string{"true"}, PreLink, nil, false)
 	if err != nil {
 		t.Errorf("unexpected error: %v", err)
 	}
 }
 
 func TestRunHooks_HookTypePostLink(t *testing.T) {
-	err := RunHooks(io.Discard, []string{"true"}, PostLink, nil, false)
+	err := RunHooks(context.Background(), io.Discard, []string{"true"}, PostLink, nil, false)
 	if err != nil {
 		t.Errorf("unexpected error: %v", err)
 	}
 }
+
+func TestRunHooks_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+	start := time.Now()
+	err := RunHooks(ctx, io.Discard, []string{"sleep 5"}, PostApply, nil, false)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("RunHooks() error = %v, want context.Canceled", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("cancelled hook ran for %s, want it killed", elapsed)
+	}
+}
+
+func TestRunGroups_Parallel(t *testing.T) {
+	slow := filepath.Join(t.TempDir(), "slow.sh")
+	os.WriteFile(slow, []byte("#!/bin/sh\nsleep 0.3\necho one\n"), 0755)
+	groups := []config.HookGroup{
+		{Name: "setup", Parallel: true, Commands: []string{slow, "echo two", "sleep 0.3"}},
+		{Commands: []string{"echo three"}},
+	}
+	var buf bytes.Buffer
+	start := time.Now()
+	if err := RunGroups(context.Background(), &buf, groups, PreApply, nil, false); err != nil {
+		t.Fatalf("RunGroups() error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 550*time.Millisecond {
+		t.Errorf("parallel group took %s, want its commands to run at the same time", elapsed)
+	}
+	// Output comes in the order the commands are listed
+	out := buf.String()
+	if i, j, k := strings.Index(out, "one"), strings.Index(out, "two"), strings.Index(out, "three"); i < 0 || i > j || j > k {
+		t.Errorf("output = %q, want one, two, three in order", out)
+	}
+}
+
+func TestRunGroups_Failures(t *testing.T) {
+	// Every command of a parallel group runs; the failures are joined
+	groups := []config.HookGroup{
+		{Name: "setup", Parallel: true, Commands: []string{"false", "true", "ls /nonexistent"}},
+		{Commands: []string{"echo never"}},
+	}
+	var buf bytes.Buffer
+	err := RunGroups(context.Background(), &buf, groups, PreApply, nil, false)
+	if err == nil || !strings.Contains(err.Error(), "group setup") || !strings.Contains(err.Error(), "hook false failed") || !strings.Contains(err.Error(), "hook ls /nonexistent failed") {
+		t.Errorf("RunGroups() error = %v, want both failures of group setup", err)
+	}
+	if strings.Contains(buf.String(), "never") {
+		t.Error("groups after a failing one should not run")
+	}
+
+	// A sequential group stops at its first failure
+	buf.Reset()
+	groups = []config.HookGroup{{Name: "steps", Commands: []string{"false", "echo never"}}}
+	if err := RunGroups(context.Background(), &buf, groups, PostApply, nil, false); err == nil {
+		t.Error("RunGroups() should fail")
+	}
+	if strings.Contains(buf.String(), "never") {
+		t.Error("a sequential group should stop at its first failure")
+	}
+}
+
+func TestRunGroups_ParallelStderr(t *testing.T) {
+	var errBuf bytes.Buffer
+	hookStderr = &errBuf
+	defer func() { hookStderr = os.Stderr }()
+
+	// Without --verbose stdout is discarded, but a failure's stderr is not
+	groups := []config.HookGroup{{Parallel: true, Commands: []string{"ls /nonexistent-ralph-hook", "echo fine"}}}
+	if err := RunGroups(context.Background(), io.Discard, groups, PreApply, nil, false); err == nil {
+		t.Fatal("RunGroups() should fail")
+	}
+	if !strings.Contains(errBuf.String(), "nonexistent-ralph-hook") {
+		t.Errorf("stderr = %q, want the failing hook's error output", errBuf.String())
+	}
+	if strings.Contains(errBuf.String(), "fine") {
+		t.Errorf("stderr = %q, want stdout kept out of it", errBuf.String())
+	}
+}
@@ -0,0 +1,62 @@
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// VerifyTimeout bounds how long a verify command may run, so a command that
+// waits for input (e.g. an editor that did not exit) cannot hang apply.
+const VerifyTimeout = 30 * time.Second
+
+// verifyOutputLines is how many trailing output lines a failed verify
+// command includes in its error.
+const verifyOutputLines = 5
+
+// Verify runs an item's verify command with sh -c in dir (the current
+// directory if empty). It fails when the command exits non-zero or runs
+// longer than VerifyTimeout; the error ends with the command's last lines of
+// output. Cancelling ctx kills the command.
+func Verify(ctx context.Context, command, dir string) error {
+	parent := ctx
+	ctx, cancel := context.WithTimeout(ctx, VerifyTimeout)
+	defer cancel()
+
+	cmd := commandContext(ctx, "sh", "-c", command)
+	cmd.Dir = dir
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	err := cmd.Run()
+	if parent.Err() != nil {
+		return parent.Err()
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("verify '%s' timed out after %s", command, VerifyTimeout)
+	}
+	if err != nil {
+		if tail := lastLines(out.String(), verifyOutputLines); tail != "" {
+			return fmt.Errorf("verify '%s' failed: %w: %s", command, err, tail)
+		}
+		return fmt.Errorf("verify '%s' failed: %w", command, err)
+	}
+	return nil
+}
+
+// lastLines returns the last n non-empty lines of s joined by "; ".
+func lastLines(s string, n int) string {
+	var lines []string
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "; ")
+}
@@ -0,0 +1,32 @@
+package hooks
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestVerify(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "built"), []byte("ok"), 0644)
+
+	if err := Verify(context.Background(), "test -f built", dir); err != nil {
+		t.Errorf("Verify in working dir failed: %v", err)
+	}
+
+	err := Verify(context.Background(), "echo line1; echo 'config error at line 3' >&2; exit 2", dir)
+	if err == nil {
+		t.Fatal("expected an error for a failing command")
+	}
+	if !strings.Contains(err.Error(), "config error at line 3") {
+		t.Errorf("error = %q, want the command's output", err)
+	}
+}
+
+func TestLastLines(t *testing.T) {
+	if got := lastLines("a\n\nb\nc\n", 2); got != "b; c" {
+		t.Errorf("lastLines = %q, want %q", got, "b; c")
+	}
+}
@@ -0,0 +1,310 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/mad01/ralph/internal/config"
+)
+
+// Import is a ralph configuration generated from another dotfiles manager.
+type Import struct {
+	// Dotfiles go in config.toml
+	Dotfiles map[string]config.Dotfile
+	// Overlays holds the dotfiles for config.<name>.toml, keyed by OS or
+	// hostname
+	Overlays map[string]map[string]config.Dotfile
+	// Encrypted lists files, relative to home, that the other manager
+	// encrypts and ralph can't
+	Encrypted []string
+	// Warnings lists files that were not imported, and why
+	Warnings []string
+}
+
+// yadmOS maps the `uname -s` values yadm matches ##os conditions against to
+// the GOOS names of config.<os>.toml overlays.
+var yadmOS = map[string]string{
+	"darwin":  "darwin",
+	"linux":   "linux",
+	"freebsd": "freebsd",
+	"openbsd": "openbsd",
+	"netbsd":  "netbsd",
+}
+
+// yadmInternal are the directories, relative to home, holding yadm's own
+// files (repo, encrypted archive, bootstrap), which are not dotfiles.
+var yadmInternal = []string{".config/yadm/", ".local/share/yadm/"}
+
+// FindYadmRepo returns the yadm repository under home: ~/.local/share/yadm/repo.git
+// (yadm 3) or ~/.config/yadm/repo.git (yadm 2).
+func FindYadmRepo(home string) (string, error) {
+	candidates := []string{
+		filepath.Join(home, ".local", "share", "yadm", "repo.git"),
+		filepath.Join(home, ".config", "yadm", "repo.git"),
+	}
+	for _, path := range candidates {
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("no yadm repository found (looked for %s)", strings.Join(candidates, " and "))
+}
+
+// ListYadmFiles returns the files tracked in the yadm repository at repo,
+// relative to home.
+func ListYadmFiles(repo, home string) ([]string, error) {
+	out, err := exec.Command("git", "--git-dir", repo, "--work-tree", home, "ls-files", "-z").Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files in %s: %w", repo, err)
+	}
+	var files []string
+	for _, f := range strings.Split(string(out), "\x00") {
+		if f != "" {
+			files = append(files, f)
+		}
+	}
+	return files, nil
+}
+
+// YadmRemote returns the URL of the yadm repository's origin, or "".
+func YadmRemote(repo string) string {
+	out, err := exec.Command("git", "--git-dir", repo, "remote", "get-url", "origin").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// ExpandEncryptList returns the files under home matched by the patterns of
+// yadm's encrypt file (~/.config/yadm/encrypt), relative to home. Patterns
+// are globs relative to home; a matched directory stands for the files in
+// it, and patterns starting with "!" exclude. A missing list is empty.
+func ExpandEncryptList(home, listPath string) ([]string, error) {
+	f, err := os.Open(listPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	matched := make(map[string]bool)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		pattern := strings.TrimSpace(scanner.Text())
+		if pattern == "" || strings.HasPrefix(pattern, "#") {
+			continue
+		}
+		exclude := strings.HasPrefix(pattern, "!")
+		pattern = strings.TrimPrefix(pattern, "!")
+		paths, err := filepath.Glob(filepath.Join(home, pattern))
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern '%s' in %s: %w", pattern, listPath, err)
+		}
+		for _, path := range paths {
+			filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+				if err != nil || d.IsDir() {
+					return nil
+				}
+				rel, err := filepath.Rel(home, p)
+				if err != nil {
+					return nil
+				}
+				matched[filepath.ToSlash(rel)] = !exclude
+				return nil
+			})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	var files []string
+	for rel, include := range matched {
+		if include {
+			files = append(files, rel)
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// FromYadm converts the files tracked by yadm, relative to home, into
+// dotfiles whose sources are those paths in a clone of the yadm repo.
+//
+// Alternate files (name##conditions) become overlays, which reproduces
+// yadm's precedence: a ##hostname alternate goes to config.<host>.toml, an
+// ##os alternate to config.<os>.toml and ##default to config.toml, all under
+// the same dotfile name so the more specific overlay replaces the source.
+// Alternates for class, user, distro or arch, and yadm templates, have no
+// equivalent and are reported as warnings instead.
+func FromYadm(files, encrypted []string) *Import {
+	im := &Import{
+		Dotfiles:  make(map[string]config.Dotfile),
+		Overlays:  make(map[string]map[string]config.Dotfile),
+		Encrypted: encrypted,
+	}
+	sorted := append([]string(nil), files...)
+	sort.Strings(sorted)
+	seen := make(map[string]bool)
+
+	for _, file := range sorted {
+		if isYadmInternal(file) {
+			if strings.HasPrefix(filepath.Base(file), "bootstrap") {
+				im.warn(file, "yadm bootstrap script: run it from a pre_apply hook or a build instead")
+			}
+			continue
+		}
+
+		components := strings.Split(file, "/")
+		alt := -1
+		for i, c := range components {
+			if strings.Contains(c, "##") {
+				alt = i
+				break
+			}
+		}
+		if alt < 0 {
+			im.add("", file, config.Dotfile{Source: file, Target: "~/" + file})
+			continue
+		}
+
+		// An alternate directory (dir##cond/...) is linked as a whole
+		source := strings.Join(components[:alt+1], "/")
+		if seen[source] {
+			continue
+		}
+		seen[source] = true
+		base, conditions := splitAlt(components[alt])
+		target := strings.Join(append(append([]string(nil), components[:alt]...), base), "/")
+		df := config.Dotfile{Source: source, Target: "~/" + target}
+		if alt < len(components)-1 {
+			df.Action = "symlink_dir"
+		}
+
+		overlay, reason := yadmOverlay(conditions)
+		if reason != "" {
+			im.warn(source, reason)
+			continue
+		}
+		im.add(overlay, target, df)
+	}
+	return im
+}
+
+// add records df, deployed to target (relative to home), in overlay ("" for
+// config.toml).
+func (im *Import) add(overlay, target string, df config.Dotfile) {
+	dotfiles := im.Dotfiles
+	if overlay != "" {
+		if im.Overlays[overlay] == nil {
+			im.Overlays[overlay] = make(map[string]config.Dotfile)
+		}
+		dotfiles = im.Overlays[overlay]
+	}
+	name := dotfileName(target)
+	if existing, ok := dotfiles[name]; ok {
+		im.warn(df.Source, fmt.Sprintf("another alternate for the same machines (%s) was imported", existing.Source))
+		return
+	}
+	dotfiles[name] = df
+}
+
+func (im *Import) warn(file, reason string) {
+	im.Warnings = append(im.Warnings, fmt.Sprintf("%s: %s", file, reason))
+}
+
+// splitAlt splits "name##cond,cond" into the name and its conditions.
+func splitAlt(component string) (string, []string) {
+	i := strings.Index(component, "##")
+	return component[:i], strings.Split(component[i+2:], ",")
+}
+
+// yadmOverlay returns the overlay an alternate with conditions belongs in:
+// the hostname, else the OS, else "" for ##default. The reason is set when
+// ralph can't express a condition.
+func yadmOverlay(conditions []string) (overlay, reason string) {
+	var host, goos string
+	for _, cond := range conditions {
+		kind, value, _ := strings.Cut(cond, ".")
+		switch kind {
+		case "default", "e", "extension":
+			// ##default is the fallback; extensions only help editors
+		case "h", "hostname":
+			host = strings.ToLower(value)
+		case "o", "os":
+			var ok bool
+			if goos, ok = yadmOS[strings.ToLower(value)]; !ok {
+				return "", fmt.Sprintf("no config.<os>.toml overlay matches yadm OS '%s'", value)
+			}
+		case "t", "template", "yadm":
+			return "", "yadm template: convert it to a Go template (is_template = true) by hand"
+		default:
+			return "", fmt.Sprintf("condition '%s' has no ralph equivalent; use hosts or an overlay", cond)
+		}
+	}
+	if host != "" {
+		return host, ""
+	}
+	return goos, ""
+}
+
+func isYadmInternal(file string) bool {
+	for _, dir := range yadmInternal {
+		if strings.HasPrefix(file, dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// dotfileName derives a dotfile name from its target relative to home, e.g.
+// ".config/nvim/init.lua" becomes "config_nvim_init_lua".
+func dotfileName(target string) string {
+	var b strings.Builder
+	underscore := false
+	for _, r := range strings.ToLower(target) {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' {
+			b.WriteRune(r)
+			underscore = false
+		} else if !underscore && b.Len() > 0 {
+			b.WriteByte('_')
+			underscore = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "_")
+}
+
+// TOML renders the dotfiles of overlay ("" for config.toml) as [dotfiles]
+// tables.
+func (im *Import) TOML(overlay string) (string, error) {
+	dotfiles := im.Dotfiles
+	if overlay != "" {
+		dotfiles = im.Overlays[overlay]
+	}
+	if len(dotfiles) == 0 {
+		return "", nil
+	}
+	var buf bytes.Buffer
+	err := toml.NewEncoder(&buf).Encode(struct {
+		Dotfiles map[string]config.Dotfile `toml:"dotfiles"`
+	}{dotfiles})
+	return buf.String(), err
+}
+
+// OverlayNames returns the names of the overlays, sorted.
+func (im *Import) OverlayNames() []string {
+	names := make([]string, 0, len(im.Overlays))
+	for name := range im.Overlays {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
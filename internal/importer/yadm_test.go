@@ -0,0 +1,129 @@
+package importer
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/BurntSushi/toml"
+	"github.com/mad01/ralph/internal/config"
+)
+
+func TestFromYadm(t *testing.T) {
+	im := FromYadm([]string{
+		".zshrc",
+		".gitconfig##default",
+		".gitconfig##os.Darwin",
+		".gitconfig##hostname.Work-Laptop",
+		".config/nvim##os.Linux/init.lua",
+		".config/nvim##os.Linux/lua/plugins.lua",
+		".bashrc##class.Work",
+		".vimrc##template",
+		".profile##os.WSL",
+		".tmux.conf##os.Linux,e.conf",
+		".config/yadm/bootstrap",
+		".config/yadm/encrypt",
+	}, []string{".ssh/id_ed25519"})
+
+	wantMain := map[string]config.Dotfile{
+		"zshrc":     {Source: ".zshrc", Target: "~/.zshrc"},
+		"gitconfig": {Source: ".gitconfig##default", Target: "~/.gitconfig"},
+	}
+	if !reflect.DeepEqual(im.Dotfiles, wantMain) {
+		t.Errorf("Dotfiles = %+v, want %+v", im.Dotfiles, wantMain)
+	}
+	wantOverlays := map[string]map[string]config.Dotfile{
+		"darwin": {"gitconfig": {Source: ".gitconfig##os.Darwin", Target: "~/.gitconfig"}},
+		"linux": {
+			"config_nvim": {Source: ".config/nvim##os.Linux", Target: "~/.config/nvim", Action: "symlink_dir"},
+			"tmux_conf":   {Source: ".tmux.conf##os.Linux,e.conf", Target: "~/.tmux.conf"},
+		},
+		"work-laptop": {"gitconfig": {Source: ".gitconfig##hostname.Work-Laptop", Target: "~/.gitconfig"}},
+	}
+	if !reflect.DeepEqual(im.Overlays, wantOverlays) {
+		t.Errorf("Overlays = %+v, want %+v", im.Overlays, wantOverlays)
+	}
+	if got := im.OverlayNames(); !reflect.DeepEqual(got, []string{"darwin", "linux", "work-laptop"}) {
+		t.Errorf("OverlayNames() = %v", got)
+	}
+
+	// class, template and WSL alternates and the bootstrap are reported
+	if len(im.Warnings) != 4 {
+		t.Fatalf("Warnings = %q, want 4", im.Warnings)
+	}
+	for i, prefix := range []string{".bashrc##class.Work:", ".config/yadm/bootstrap:", ".profile##os.WSL:", ".vimrc##template:"} {
+		if !strings.HasPrefix(im.Warnings[i], prefix) {
+			t.Errorf("Warnings[%d] = %q, want it to start with %q", i, im.Warnings[i], prefix)
+		}
+	}
+}
+
+func TestFromYadm_DuplicateAlternate(t *testing.T) {
+	im := FromYadm([]string{".gitconfig##os.Linux", ".gitconfig##o.Linux"}, nil)
+	if got := im.Overlays["linux"]["gitconfig"].Source; got != ".gitconfig##o.Linux" {
+		t.Errorf("imported source = %q, want the first in order", got)
+	}
+	if len(im.Warnings) != 1 {
+		t.Errorf("Warnings = %q, want one for the skipped alternate", im.Warnings)
+	}
+}
+
+func TestImportTOML(t *testing.T) {
+	im := FromYadm([]string{".gitconfig##default", ".gitconfig##os.Darwin"}, nil)
+	for _, overlay := range []string{"", "darwin"} {
+		out, err := im.TOML(overlay)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var cfg config.Config
+		if _, err := toml.Decode(out, &cfg); err != nil {
+			t.Fatalf("TOML(%q) is not a valid config: %v\n%s", overlay, err, out)
+		}
+		if cfg.Dotfiles["gitconfig"].Target != "~/.gitconfig" {
+			t.Errorf("TOML(%q) decoded to %+v", overlay, cfg.Dotfiles)
+		}
+	}
+	if out, _ := im.TOML("linux"); out != "" {
+		t.Errorf("TOML of a missing overlay = %q, want empty", out)
+	}
+}
+
+func TestExpandEncryptList(t *testing.T) {
+	home := t.TempDir()
+	for _, f := range []string{".ssh/id_ed25519", ".ssh/id_ed25519.pub", ".ssh/config", ".gnupg/keys/a.key", ".netrc"} {
+		path := filepath.Join(home, f)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		os.WriteFile(path, []byte("x"), 0600)
+	}
+	list := filepath.Join(home, "encrypt")
+	os.WriteFile(list, []byte("# secrets\n.ssh/*\n!.ssh/*.pub\n.gnupg\n\n.missing\n"), 0644)
+
+	got, err := ExpandEncryptList(home, list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{".gnupg/keys/a.key", ".ssh/config", ".ssh/id_ed25519"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ExpandEncryptList() = %v, want %v", got, want)
+	}
+
+	if got, err := ExpandEncryptList(home, filepath.Join(home, "none")); err != nil || got != nil {
+		t.Errorf("missing list = %v, %v; want nothing", got, err)
+	}
+}
+
+func TestDotfileName(t *testing.T) {
+	tests := map[string]string{
+		".zshrc":                "zshrc",
+		".config/nvim/init.lua": "config_nvim_init_lua",
+		"bin/My Script":         "bin_my_script",
+		".tmux.conf":            "tmux_conf",
+	}
+	for target, want := range tests {
+		if got := dotfileName(target); got != want {
+			t.Errorf("dotfileName(%q) = %q, want %q", target, got, want)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package inventory
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/mad01/ralph/internal/config"
+)
+
+// Dir is where machine records live, relative to dotfiles_repo_path.
+const Dir = "state/machines"
+
+// Machine is what apply records about the host it ran on.
+type Machine struct {
+	Host      string    `toml:"host"`
+	Version   string    `toml:"version"`            // ralph version that applied
+	OS        string    `toml:"os"`                 // GOOS/GOARCH
+	LastApply time.Time `toml:"last_apply"`         // When the last (non-dry-run) apply finished
+	Recipes   []string  `toml:"recipes"`            // Recipes loaded on this host
+	Failures  []string  `toml:"failures,omitempty"` // "Phase: item: message" for each failed step of the last apply
+}
+
+// Converged reports whether the last apply finished without failures.
+func (m Machine) Converged() bool {
+	return len(m.Failures) == 0
+}
+
+// Path returns the record file of host in the dotfiles repo.
+func Path(repoPath, host string) (string, error) {
+	absoluteRepo, err := config.ExpandPath(repoPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to expand repo path '%s': %w", repoPath, err)
+	}
+	name := strings.NewReplacer("/", "_", "\\", "_").Replace(host)
+	return filepath.Join(absoluteRepo, Dir, name+".toml"), nil
+}
+
+// Write stores m as state/machines/<host>.toml in the dotfiles repo.
+// If dryRun is true, it will only print the path it would write.
+func Write(w io.Writer, repoPath string, m Machine, dryRun bool) error {
+	path, err := Path(repoPath, m.Host)
+	if err != nil {
+		return err
+	}
+	if dryRun {
+		fmt.Fprintf(w, "[DRY RUN] Would record this machine in %s\n", config.ShortenHome(path))
+		return nil
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("# Written by ralph apply; see 'ralph machines'.\n")
+	if err := toml.NewEncoder(&buf).Encode(m); err != nil {
+		return fmt.Errorf("failed to encode machine record: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create '%s': %w", filepath.Dir(path), err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write machine record '%s': %w", path, err)
+	}
+	fmt.Fprintf(w, "Recorded this machine in %s\n", config.ShortenHome(path))
+	return nil
+}
+
+// List reads every machine record in the dotfiles repo, sorted by host. A
+// missing directory means no machines have been recorded yet.
+func List(repoPath string) ([]Machine, error) {
+	path, err := Path(repoPath, "x")
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Dir(path)
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read '%s': %w", dir, err)
+	}
+
+	var machines []Machine
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".toml" {
+			continue
+		}
+		var m Machine
+		if _, err := toml.DecodeFile(filepath.Join(dir, entry.Name()), &m); err != nil {
+			return nil, fmt.Errorf("failed to parse '%s': %w", entry.Name(), err)
+		}
+		if m.Host == "" {
+			m.Host = strings.TrimSuffix(entry.Name(), ".toml")
+		}
+		machines = append(machines, m)
+	}
+	sort.Slice(machines, func(i, j int) bool { return machines[i].Host < machines[j].Host })
+	return machines, nil
+}
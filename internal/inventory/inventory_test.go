@@ -0,0 +1,53 @@
+package inventory
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestWriteAndList(t *testing.T) {
+	repo := t.TempDir()
+	applied := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	laptop := Machine{Host: "laptop", Version: "v1.2.0", OS: "darwin/arm64", LastApply: applied, Recipes: []string{"editors", "git"}}
+	server := Machine{Host: "server", Version: "dev", OS: "linux/amd64", LastApply: applied, Recipes: []string{}, Failures: []string{"Repositories: tpm: clone failed"}}
+
+	if err := Write(io.Discard, repo, server, true); err != nil {
+		t.Fatalf("dry run failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(repo, Dir)); !os.IsNotExist(err) {
+		t.Error("dry run should not write anything")
+	}
+	if machines, err := List(repo); err != nil || len(machines) != 0 {
+		t.Errorf("List before any apply = (%v, %v), want none", machines, err)
+	}
+
+	for _, m := range []Machine{server, laptop} {
+		if err := Write(io.Discard, repo, m, false); err != nil {
+			t.Fatalf("Write(%s) failed: %v", m.Host, err)
+		}
+	}
+	machines, err := List(repo)
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if want := []Machine{laptop, server}; !reflect.DeepEqual(machines, want) {
+		t.Errorf("List = %+v, want %+v", machines, want)
+	}
+	if !machines[0].Converged() || machines[1].Converged() {
+		t.Error("only the machine without failures should be converged")
+	}
+}
+
+func TestPath_SanitizesHost(t *testing.T) {
+	path, err := Path("/repo", "weird/host")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join("/repo", Dir, "weird_host.toml"); path != want {
+		t.Errorf("Path = %q, want %q", path, want)
+	}
+}
@@ -0,0 +1,277 @@
+// Package lint finds likely mistakes in a loaded config that validation
+// accepts: sources missing from the repo, items no known machine applies,
+// template variables no template uses, aliases that shadow installed
+// commands and builds whose repo isn't cloned where they run.
+package lint
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/mad01/ralph/internal/config"
+)
+
+// Checks, in the order Run reports them.
+const (
+	CheckMissingSource   = "missing source"
+	CheckUnreachable     = "unreachable"
+	CheckUnusedVariable  = "unused variable"
+	CheckShadowedCommand = "shadowed command"
+	CheckBuildRepo       = "build repo"
+)
+
+// Checks lists every check in report order.
+func Checks() []string {
+	return []string{CheckMissingSource, CheckUnreachable, CheckUnusedVariable, CheckShadowedCommand, CheckBuildRepo}
+}
+
+// Finding is one problem a check found.
+type Finding struct {
+	Check   string // One of the Check constants
+	Item    string // The item, e.g. "dotfile nvim"
+	Message string
+}
+
+// Options configures Run.
+type Options struct {
+	// Hosts are the known machines (lowercase): items and builds are checked
+	// against them. Without any, the unreachable and build repo checks are
+	// skipped.
+	Hosts []string
+	// LookPath finds the commands aliases may shadow (default exec.LookPath).
+	LookPath func(string) (string, error)
+}
+
+// Run runs every check on cfg and returns the findings, sorted by check
+// and item.
+func Run(cfg *config.Config, opts Options) []Finding {
+	if opts.LookPath == nil {
+		opts.LookPath = exec.LookPath
+	}
+	var findings []Finding
+	findings = append(findings, missingSources(cfg)...)
+	findings = append(findings, unreachable(cfg, opts.Hosts)...)
+	findings = append(findings, unusedVariables(cfg)...)
+	findings = append(findings, shadowedCommands(cfg, opts.LookPath)...)
+	findings = append(findings, buildRepos(cfg, opts.Hosts)...)
+
+	order := make(map[string]int)
+	for i, check := range Checks() {
+		order[check] = i
+	}
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].Check != findings[j].Check {
+			return order[findings[i].Check] < order[findings[j].Check]
+		}
+		return findings[i].Item < findings[j].Item
+	})
+	return findings
+}
+
+// missingSources flags dotfiles and tool config files whose source is not in
+// the repo. Downloads and extracts from a URL have no source to check.
+func missingSources(cfg *config.Config) []Finding {
+	var findings []Finding
+	check := func(item, source string) {
+		path, err := config.ExpandPath(filepath.Join(cfg.DotfilesRepoPath, source))
+		if err != nil {
+			return
+		}
+		if _, err := os.Lstat(path); os.IsNotExist(err) {
+			findings = append(findings, Finding{CheckMissingSource, item, fmt.Sprintf("source '%s' does not exist", source)})
+		}
+	}
+	for name, df := range cfg.Dotfiles {
+		if df.Action == "download" || (df.Action == "extract" && df.URL != "") {
+			continue
+		}
+		check("dotfile "+name, df.Source)
+	}
+	for _, t := range cfg.Tools {
+		for _, cf := range t.ConfigFiles {
+			check("tool "+t.Name, cf.Source)
+		}
+	}
+	return findings
+}
+
+// hostFiltered is an item with a hosts filter.
+type hostFiltered struct {
+	item  string
+	hosts []string
+}
+
+// hostFilteredItems returns the enabled items that have a hosts filter.
+func hostFilteredItems(cfg *config.Config) []hostFiltered {
+	var items []hostFiltered
+	add := func(item string, enable *bool, hosts []string) {
+		if len(hosts) > 0 && config.IsEnabled(enable) {
+			items = append(items, hostFiltered{item, hosts})
+		}
+	}
+	for name, df := range cfg.Dotfiles {
+		add("dotfile "+name, df.Enable, df.Hosts)
+	}
+	for name, dir := range cfg.Directories {
+		add("directory "+name, dir.Enable, dir.Hosts)
+	}
+	for name, rp := range cfg.Repos {
+		add("repo "+name, rp.Enable, rp.Hosts)
+	}
+	for _, t := range cfg.Tools {
+		add("tool "+t.Name, t.Enable, t.Hosts)
+	}
+	for name, alias := range cfg.Shell.Aliases {
+		add("alias "+name, alias.Enable, alias.Hosts)
+	}
+	for name, fn := range cfg.Shell.Functions {
+		add("function "+name, fn.Enable, fn.Hosts)
+	}
+	for name, env := range cfg.Shell.Env {
+		add("env "+name, env.Enable, env.Hosts)
+	}
+	for name, build := range cfg.Hooks.Builds {
+		add("build "+name, build.Enable, build.Hosts)
+	}
+	return items
+}
+
+// unreachable flags items whose hosts filter matches none of hosts.
+func unreachable(cfg *config.Config, hosts []string) []Finding {
+	if len(hosts) == 0 {
+		return nil
+	}
+	var findings []Finding
+	for _, it := range hostFilteredItems(cfg) {
+		if !appliesOnAny(it.hosts, hosts) {
+			findings = append(findings, Finding{CheckUnreachable, it.item, fmt.Sprintf("hosts %s match no known machine", strings.Join(it.hosts, ", "))})
+		}
+	}
+	return findings
+}
+
+// appliesOnAny reports whether an item filtered to itemHosts applies on any
+// of hosts.
+func appliesOnAny(itemHosts, hosts []string) bool {
+	for _, h := range hosts {
+		if config.ShouldApplyForHost(itemHosts, h) {
+			return true
+		}
+	}
+	return false
+}
+
+// unusedVariables flags template_variables that no template, [shell.env]
+// value or commit message template refers to, and dotfile vars its own
+// template doesn't use.
+func unusedVariables(cfg *config.Config) []Finding {
+	var texts []string
+	templates := make(map[string]string, len(cfg.Dotfiles))
+	for name, df := range cfg.Dotfiles {
+		templates[name] = dotfileTemplates(cfg, df)
+		texts = append(texts, templates[name])
+	}
+	for _, env := range cfg.Shell.Env {
+		texts = append(texts, env.Value)
+	}
+	texts = append(texts, cfg.Git.MessageTemplate)
+	all := strings.Join(texts, "\n")
+
+	var findings []Finding
+	for key := range cfg.TemplateVariables {
+		if !referencesVariable(all, key) {
+			findings = append(findings, Finding{CheckUnusedVariable, "template_variables." + key, "not used by any template"})
+		}
+	}
+	for name, df := range cfg.Dotfiles {
+		for key := range df.Vars {
+			if !referencesVariable(templates[name], key) {
+				findings = append(findings, Finding{CheckUnusedVariable, "dotfile " + name, fmt.Sprintf("var '%s' is not used by its template", key)})
+			}
+		}
+	}
+	return findings
+}
+
+// dotfileTemplates returns the text of the templates df renders: its source
+// for is_template, every file under it for template_dir.
+func dotfileTemplates(cfg *config.Config, df config.Dotfile) string {
+	if !df.IsTemplate && df.Action != "template_dir" {
+		return ""
+	}
+	source, err := config.ExpandPath(filepath.Join(cfg.DotfilesRepoPath, df.Source))
+	if err != nil {
+		return ""
+	}
+	var b strings.Builder
+	filepath.WalkDir(source, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		if data, err := os.ReadFile(path); err == nil {
+			b.Write(data)
+			b.WriteByte('\n')
+		}
+		return nil
+	})
+	return b.String()
+}
+
+// referencesVariable reports whether text refers to the template variable
+// key, as .key or through index with "key".
+func referencesVariable(text, key string) bool {
+	pattern := regexp.MustCompile(`\.` + regexp.QuoteMeta(key) + `\b|"` + regexp.QuoteMeta(key) + `"`)
+	return pattern.MatchString(text)
+}
+
+// shadowedCommands flags enabled aliases named like a command on PATH that
+// run something else: alias ls='ls -G' extends ls, alias cat=bat replaces it.
+func shadowedCommands(cfg *config.Config, lookPath func(string) (string, error)) []Finding {
+	var findings []Finding
+	for name, alias := range cfg.Shell.Aliases {
+		if !config.IsEnabled(alias.Enable) {
+			continue
+		}
+		path, err := lookPath(name)
+		if err != nil {
+			continue
+		}
+		if fields := strings.Fields(alias.Command); len(fields) > 0 && fields[0] == name {
+			continue
+		}
+		findings = append(findings, Finding{CheckShadowedCommand, "alias " + name, fmt.Sprintf("shadows %s with '%s'", path, alias.Command)})
+	}
+	return findings
+}
+
+// buildRepos flags builds linked to a repo that is disabled, or not cloned
+// on some of the known hosts the build runs on.
+func buildRepos(cfg *config.Config, hosts []string) []Finding {
+	var findings []Finding
+	for name, build := range cfg.Hooks.Builds {
+		rp, ok := cfg.Repos[build.Repo]
+		if build.Repo == "" || !ok || !config.IsEnabled(build.Enable) {
+			continue
+		}
+		if !config.IsEnabled(rp.Enable) {
+			findings = append(findings, Finding{CheckBuildRepo, "build " + name, fmt.Sprintf("repo '%s' is disabled", build.Repo)})
+			continue
+		}
+		var missing []string
+		for _, h := range hosts {
+			if config.ShouldApplyForHost(build.Hosts, h) && !config.ShouldApplyForHost(rp.Hosts, h) {
+				missing = append(missing, h)
+			}
+		}
+		if len(missing) > 0 {
+			findings = append(findings, Finding{CheckBuildRepo, "build " + name, fmt.Sprintf("repo '%s' is not cloned on %s", build.Repo, strings.Join(missing, ", "))})
+		}
+	}
+	return findings
+}
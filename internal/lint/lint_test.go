@@ -0,0 +1,74 @@
+package lint
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/mad01/ralph/internal/config"
+)
+
+func TestRun(t *testing.T) {
+	repo := t.TempDir()
+	os.WriteFile(filepath.Join(repo, "zshrc"), []byte("export EMAIL={{ .email }}\n"), 0644)
+	os.WriteFile(filepath.Join(repo, "kitty.conf"), []byte("font_size {{ .font_size }}\n"), 0644)
+
+	falseVal := false
+	cfg := &config.Config{
+		DotfilesRepoPath: repo,
+		Dotfiles: map[string]config.Dotfile{
+			"zsh":   {Source: "zshrc", Target: "~/.zshrc", IsTemplate: true},
+			"kitty": {Source: "kitty.conf", Target: "~/.config/kitty/kitty.conf", IsTemplate: true, Vars: map[string]interface{}{"font_size": 13, "theme": "dark"}},
+			"gone":  {Source: "missing", Target: "~/.gone", Hosts: []string{"old-laptop"}},
+			"curl":  {Action: "download", URL: "https://example.com/x", Target: "~/bin/x"},
+		},
+		Repos: map[string]config.Repo{
+			"fzf":  {URL: "https://github.com/junegunn/fzf", Target: "~/src/fzf", Hosts: []string{"work"}},
+			"tpm":  {URL: "https://github.com/tmux-plugins/tpm", Target: "~/src/tpm", Enable: &falseVal},
+			"nvim": {URL: "https://github.com/neovim/neovim", Target: "~/src/nvim"},
+		},
+		Hooks: config.HooksConfig{Builds: map[string]config.Build{
+			"fzf":  {Repo: "fzf", Commands: []string{"make"}},
+			"tpm":  {Repo: "tpm", Commands: []string{"make"}},
+			"nvim": {Repo: "nvim", Commands: []string{"make"}},
+		}},
+		Shell: config.ShellConfig{Aliases: map[string]config.ShellAlias{
+			"cat": {Command: "bat"},
+			"ls":  {Command: "ls -G"},
+			"gs":  {Command: "git status"},
+		}},
+		TemplateVariables: map[string]interface{}{"email": "me@example.com", "unused": "x"},
+	}
+	lookPath := func(name string) (string, error) {
+		if name == "cat" || name == "ls" {
+			return "/bin/" + name, nil
+		}
+		return "", errors.New("not found")
+	}
+
+	got := Run(cfg, Options{Hosts: []string{"home", "work"}, LookPath: lookPath})
+
+	want := []Finding{
+		{CheckMissingSource, "dotfile gone", "source 'missing' does not exist"},
+		{CheckUnreachable, "dotfile gone", "hosts old-laptop match no known machine"},
+		{CheckUnusedVariable, "dotfile kitty", "var 'theme' is not used by its template"},
+		{CheckUnusedVariable, "template_variables.unused", "not used by any template"},
+		{CheckShadowedCommand, "alias cat", "shadows /bin/cat with 'bat'"},
+		{CheckBuildRepo, "build fzf", "repo 'fzf' is not cloned on home"},
+		{CheckBuildRepo, "build tpm", "repo 'tpm' is disabled"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Run() =\n%v\nwant\n%v", got, want)
+	}
+}
+
+func TestRun_NoKnownHosts(t *testing.T) {
+	cfg := &config.Config{
+		Dotfiles: map[string]config.Dotfile{"work": {Action: "download", URL: "https://example.com/x", Target: "~/x", Hosts: []string{"work"}}},
+	}
+	if got := Run(cfg, Options{LookPath: func(string) (string, error) { return "", errors.New("none") }}); len(got) != 0 {
+		t.Errorf("Run() without known hosts = %v, want no findings", got)
+	}
+}
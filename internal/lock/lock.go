@@ -0,0 +1,143 @@
+package lock
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Lock is an exclusive lock held by creating a file that records the
+// holder's PID.
+type Lock struct {
+	path string
+}
+
+// held records the lock files this process holds, to tell them apart from
+// stale ones that happen to record this process's PID (PIDs are reused,
+// e.g. in containers).
+var (
+	heldMu sync.Mutex
+	held   = make(map[string]bool)
+)
+
+// HeldError is returned by Acquire when another running process holds the
+// lock. PID is 0 when the lock file does not record a PID ralph can read.
+type HeldError struct {
+	Path string
+	PID  int
+}
+
+func (e *HeldError) Error() string {
+	if e.PID == 0 {
+		return fmt.Sprintf("locked (%s records no PID; remove it if no other process is running)", e.Path)
+	}
+	return fmt.Sprintf("locked by process %d (%s)", e.PID, e.Path)
+}
+
+// Acquire takes the lock at path. The PID is written to a temporary file
+// that is then linked into place, so the lock file never exists without it.
+// A lock file left behind by a process that no longer runs (e.g. one killed
+// with a second Ctrl-C) is taken over; one that can't be read as a PID is
+// left alone and reported as held.
+func Acquire(path string) (*Lock, error) {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create lock directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create lock file '%s': %w", path, err)
+	}
+	defer os.Remove(tmp.Name())
+	_, err = fmt.Fprintf(tmp, "%d\n", os.Getpid())
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to write lock file '%s': %w", path, err)
+	}
+
+	heldMu.Lock()
+	defer heldMu.Unlock()
+	for attempt := 0; attempt < 3; attempt++ {
+		err := os.Link(tmp.Name(), path)
+		if err == nil {
+			held[path] = true
+			return &Lock{path: path}, nil
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("failed to create lock file '%s': %w", path, err)
+		}
+		pid, ok := holder(path)
+		switch {
+		case !ok:
+			return nil, &HeldError{Path: path}
+		case pid == os.Getpid() && held[path]:
+			return nil, &HeldError{Path: path, PID: pid}
+		case pid != os.Getpid() && processAlive(pid):
+			return nil, &HeldError{Path: path, PID: pid}
+		}
+		if err := removeStale(path, tmp.Name()+".stale", pid); err != nil {
+			return nil, err
+		}
+	}
+	return nil, fmt.Errorf("failed to acquire lock '%s'", path)
+}
+
+// removeStale removes the lock file at path recording the dead process pid.
+// Another process may have taken the stale lock over since it was read, so
+// the file is first moved aside (atomically, to the unique name aside) and
+// only deleted if it still records pid. A fresh lock moved aside by mistake
+// is put back and reported as held.
+func removeStale(path, aside string, pid int) error {
+	if err := os.Rename(path, aside); err != nil {
+		if os.IsNotExist(err) {
+			return nil // Someone else removed it first
+		}
+		return fmt.Errorf("failed to remove stale lock file '%s': %w", path, err)
+	}
+	defer os.Remove(aside)
+	current, ok := holder(aside)
+	if ok && current == pid {
+		return nil
+	}
+	// Link fails if yet another process took the lock in the meantime;
+	// either way the lock is held.
+	os.Link(aside, path)
+	return &HeldError{Path: path, PID: current}
+}
+
+// Release removes the lock file. Releasing a nil lock does nothing.
+func (l *Lock) Release() error {
+	if l == nil {
+		return nil
+	}
+	heldMu.Lock()
+	delete(held, l.path)
+	heldMu.Unlock()
+	if err := os.Remove(l.path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove lock file '%s': %w", l.path, err)
+	}
+	return nil
+}
+
+// holder returns the PID recorded in the lock file at path. ok is false when
+// the file can't be read or holds no positive PID. A file that is gone
+// counts as recording no running process.
+func holder(path string) (pid int, ok bool) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return 0, true
+	}
+	if err != nil {
+		return 0, false
+	}
+	pid, err = strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || pid <= 0 {
+		return 0, false
+	}
+	return pid, true
+}
@@ -0,0 +1,155 @@
+package lock
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAcquireRelease(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "apply.lock")
+
+	l, err := Acquire(path)
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+	if got, _ := holder(path); got != os.Getpid() {
+		t.Errorf("lock file records pid %d, want %d", got, os.Getpid())
+	}
+
+	var held *HeldError
+	if _, err := Acquire(path); !errors.As(err, &held) || held.PID != os.Getpid() {
+		t.Errorf("second Acquire() error = %v, want it held by this process", err)
+	}
+
+	if err := l.Release(); err != nil {
+		t.Fatalf("Release() error = %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("lock file should be removed on release")
+	}
+	if err := (*Lock)(nil).Release(); err != nil {
+		t.Errorf("releasing a nil lock: %v", err)
+	}
+}
+
+func TestAcquire_Stale(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "apply.lock")
+	// A PID that is not running, and this process's own PID left behind by
+	// an earlier process that no longer runs (PIDs are reused)
+	for _, pid := range []int{1<<22 + 12345, os.Getpid()} {
+		if err := os.WriteFile(path, []byte(strconv.Itoa(pid)+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		l, err := Acquire(path)
+		if err != nil {
+			t.Fatalf("Acquire() over stale lock of pid %d: %v", pid, err)
+		}
+		if err := l.Release(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestAcquire_Unreadable(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "apply.lock")
+	// An empty file may be a lock whose holder hasn't written its PID yet
+	for _, content := range []string{"", "garbage\n", "0\n"} {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		var held *HeldError
+		if _, err := Acquire(path); !errors.As(err, &held) || held.PID != 0 {
+			t.Errorf("Acquire() over lock file %q: error = %v, want it held", content, err)
+		}
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("lock file %q should be left in place: %v", content, err)
+		}
+	}
+}
+
+func TestAcquire_NoTempFilesLeft(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "apply.lock")
+	l, err := Acquire(path)
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+	if _, err := Acquire(path); err == nil {
+		t.Fatal("second Acquire() should fail")
+	}
+	if err := l.Release(); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		t.Errorf("left behind %s", e.Name())
+	}
+}
+
+// lockHelperEnv makes TestLockHelper act as a separate process taking the
+// lock, for testing Acquire across processes.
+const lockHelperEnv = "RALPH_LOCK_HELPER_PATH"
+
+func TestLockHelper(t *testing.T) {
+	path := os.Getenv(lockHelperEnv)
+	if path == "" {
+		t.Skip("only run by TestAcquire_ConcurrentStale")
+	}
+	// Start together once the parent creates the go file
+	for _, err := os.Stat(path + ".go"); err != nil; _, err = os.Stat(path + ".go") {
+		time.Sleep(time.Millisecond)
+	}
+	if _, err := Acquire(path); err == nil {
+		fmt.Println("acquired")
+		// Hold the lock until every process has tried; exiting leaves it stale
+		time.Sleep(time.Second)
+	}
+}
+
+func TestAcquire_ConcurrentStale(t *testing.T) {
+	if testing.Short() {
+		t.Skip("starts processes")
+	}
+	path := filepath.Join(t.TempDir(), "apply.lock")
+	if err := os.WriteFile(path, []byte(strconv.Itoa(1<<22+12345)+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	const processes = 8
+	outputs := make([]bytes.Buffer, processes)
+	cmds := make([]*exec.Cmd, processes)
+	for i := range cmds {
+		cmds[i] = exec.Command(os.Args[0], "-test.run=^TestLockHelper$")
+		cmds[i].Env = append(os.Environ(), lockHelperEnv+"="+path)
+		cmds[i].Stdout = &outputs[i]
+		if err := cmds[i].Start(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(path+".go", nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	acquired := 0
+	for i, cmd := range cmds {
+		if err := cmd.Wait(); err != nil {
+			t.Fatalf("helper process failed: %v\n%s", err, outputs[i].String())
+		}
+		if strings.Contains(outputs[i].String(), "acquired") {
+			acquired++
+		}
+	}
+	if acquired != 1 {
+		t.Errorf("%d processes took over the stale lock, want exactly 1", acquired)
+	}
+}
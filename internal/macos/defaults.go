@@ -0,0 +1,98 @@
+package macos
+
+import (
+	"fmt"
+	"io"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/mad01/ralph/internal/config"
+)
+
+// runDefaults runs the defaults command and returns its trimmed output.
+// It is a variable so tests can replace it.
+var runDefaults = func(args ...string) (string, error) {
+	out, err := exec.Command("defaults", args...).CombinedOutput()
+	return strings.TrimSpace(string(out)), err
+}
+
+// Supported reports whether macOS defaults can be managed on this system.
+func Supported() bool {
+	return runtime.GOOS == "darwin"
+}
+
+// formatValue returns def's value the way `defaults read` prints it and the
+// way `defaults write` takes it.
+func formatValue(def config.MacOSDefault) (read, write string) {
+	switch v := def.Value.(type) {
+	case bool:
+		if v {
+			return "1", "true"
+		}
+		return "0", "false"
+	case int64:
+		s := strconv.FormatInt(v, 10)
+		return s, s
+	case float64:
+		s := strconv.FormatFloat(v, 'f', -1, 64)
+		return s, s
+	default:
+		s := fmt.Sprint(v)
+		return s, s
+	}
+}
+
+// CurrentValue returns the current value of domain/key as printed by
+// `defaults read`, and whether the key is set at all.
+func CurrentValue(domain, key string) (string, bool, error) {
+	out, err := runDefaults("read", domain, key)
+	if err != nil {
+		if strings.Contains(out, "does not exist") {
+			return "", false, nil
+		}
+		return "", false, fmt.Errorf("defaults read %s %s failed: %w: %s", domain, key, err, out)
+	}
+	return out, true, nil
+}
+
+// InSync reports whether def already holds its desired value.
+func InSync(def config.MacOSDefault) (bool, error) {
+	current, exists, err := CurrentValue(def.Domain, def.Key)
+	if err != nil {
+		return false, err
+	}
+	desired, _ := formatValue(def)
+	return exists && current == desired, nil
+}
+
+// ApplyDefault writes def with `defaults write` unless it already holds the
+// desired value, and returns whether it changed (or would change) anything.
+// If dryRun is true, it will only print the change it would make.
+func ApplyDefault(w io.Writer, def config.MacOSDefault, dryRun bool) (bool, error) {
+	current, exists, err := CurrentValue(def.Domain, def.Key)
+	if err != nil {
+		return false, err
+	}
+	desired, writeValue := formatValue(def)
+	if exists && current == desired {
+		fmt.Fprintf(w, "    %s\n", color.GreenString("unchanged"))
+		return false, nil
+	}
+
+	if !exists {
+		current = "(unset)"
+	}
+	change := fmt.Sprintf("%s %s: %s → %s", def.Domain, def.Key, current, desired)
+	if dryRun {
+		fmt.Fprintf(w, "    %s would set %s\n", color.CyanString("[dry run]"), change)
+		return true, nil
+	}
+	if out, err := runDefaults("write", def.Domain, def.Key, "-"+def.ValueType(), writeValue); err != nil {
+		return false, fmt.Errorf("defaults write %s %s failed: %w: %s", def.Domain, def.Key, err, out)
+	}
+	fmt.Fprintf(w, "    %s %s\n", color.GreenString("set"), change)
+	return true, nil
+}
@@ -0,0 +1,96 @@
+package macos
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mad01/ralph/internal/config"
+)
+
+// fakeDefaults replaces runDefaults with an in-memory store and returns it
+// along with a log of the write calls.
+func fakeDefaults(t *testing.T, store map[string]string) *[][]string {
+	t.Helper()
+	var writes [][]string
+	original := runDefaults
+	runDefaults = func(args ...string) (string, error) {
+		key := args[1] + " " + args[2]
+		switch args[0] {
+		case "read":
+			if v, ok := store[key]; ok {
+				return v, nil
+			}
+			return "The domain/default pair of (" + args[1] + ", " + args[2] + ") does not exist", errors.New("exit status 1")
+		case "write":
+			writes = append(writes, args)
+			if args[3] == "-bool" {
+				store[key] = map[string]string{"true": "1", "false": "0"}[args[4]]
+			} else {
+				store[key] = args[4]
+			}
+			return "", nil
+		}
+		t.Fatalf("unexpected defaults call %v", args)
+		return "", nil
+	}
+	t.Cleanup(func() { runDefaults = original })
+	return &writes
+}
+
+func TestApplyDefault(t *testing.T) {
+	store := map[string]string{"com.apple.dock tilesize": "36"}
+	writes := fakeDefaults(t, store)
+
+	autohide := config.MacOSDefault{Domain: "com.apple.dock", Key: "autohide", Value: true}
+	tilesize := config.MacOSDefault{Domain: "com.apple.dock", Key: "tilesize", Value: int64(36)}
+
+	if changed, err := ApplyDefault(io.Discard, tilesize, false); err != nil || changed {
+		t.Errorf("matching value = (%v, %v), want (false, nil)", changed, err)
+	}
+
+	var out bytes.Buffer
+	if changed, err := ApplyDefault(&out, autohide, true); err != nil || !changed {
+		t.Fatalf("dry run = (%v, %v), want (true, nil)", changed, err)
+	}
+	if len(*writes) != 0 {
+		t.Errorf("dry run wrote %v", *writes)
+	}
+	if !strings.Contains(out.String(), "(unset) → 1") {
+		t.Errorf("dry run output = %q, want the change", out.String())
+	}
+
+	if changed, err := ApplyDefault(io.Discard, autohide, false); err != nil || !changed {
+		t.Fatalf("apply = (%v, %v), want (true, nil)", changed, err)
+	}
+	want := [][]string{{"write", "com.apple.dock", "autohide", "-bool", "true"}}
+	if !reflect.DeepEqual(*writes, want) {
+		t.Errorf("writes = %v, want %v", *writes, want)
+	}
+
+	if inSync, err := InSync(autohide); err != nil || !inSync {
+		t.Errorf("InSync after apply = (%v, %v), want (true, nil)", inSync, err)
+	}
+}
+
+func TestFormatValue(t *testing.T) {
+	tests := []struct {
+		value       interface{}
+		read, write string
+	}{
+		{false, "0", "false"},
+		{int64(42), "42", "42"},
+		{0.5, "0.5", "0.5"},
+		{float64(2), "2", "2"},
+		{"Nlsv", "Nlsv", "Nlsv"},
+	}
+	for _, tt := range tests {
+		read, write := formatValue(config.MacOSDefault{Value: tt.value})
+		if read != tt.read || write != tt.write {
+			t.Errorf("formatValue(%v) = (%q, %q), want (%q, %q)", tt.value, read, write, tt.read, tt.write)
+		}
+	}
+}
@@ -0,0 +1,311 @@
+package migrate
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/mad01/ralph/internal/config"
+)
+
+// LegacyRoots are the directories pre-recipes layouts kept every dotfile
+// source under. They are dropped from the paths sources move to.
+var LegacyRoots = []string{"dotter_files", "ralph_files"}
+
+// NewRecipe is a recipe migrate-config creates from dotfiles in config.toml.
+type NewRecipe struct {
+	Name     string                    // Recipe name, also its directory under the recipes dir
+	Dir      string                    // Recipe directory relative to the repo
+	Dotfiles map[string]config.Dotfile // Dotfiles with sources relative to Dir
+	Moves    map[string]string         // Old source -> new source, both relative to the repo
+}
+
+// ConfigPlan describes how to turn the dotfiles of a flat config.toml into
+// recipes: which files move where and the config that results.
+type ConfigPlan struct {
+	ConfigPath string
+	RepoPath   string      // Expanded dotfiles_repo_path
+	Recipes    []NewRecipe // Sorted by name
+	NewConfig  []byte      // config.toml without the migrated dotfiles, plus recipe references
+}
+
+// Empty reports whether there is nothing to migrate.
+func (p *ConfigPlan) Empty() bool {
+	return len(p.Recipes) == 0
+}
+
+// PlanConfigMigration finds the dotfiles defined directly in config.toml
+// (not in a recipe or overlay) and plans moving each into a recipe under the
+// recipes dir. A source in a subdirectory goes to the recipe named after that
+// directory, with the other sources there; a source at the top level gets a
+// recipe named after its dotfile. A leading dotter_files/ or ralph_files/ is
+// dropped first.
+func PlanConfigMigration(cfg *config.Config, configPath string) (*ConfigPlan, error) {
+	repoPath, err := config.ExpandPath(cfg.DotfilesRepoPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to expand dotfiles repo path: %w", err)
+	}
+	recipesDir := cfg.RecipesConfig.Dir
+	if recipesDir == "" {
+		recipesDir = config.DefaultRecipesDir
+	}
+	plan := &ConfigPlan{ConfigPath: configPath, RepoPath: repoPath}
+
+	existing := make(map[string]bool)
+	for _, r := range cfg.LoadedRecipes {
+		existing[r.Name] = true
+	}
+	recipes := make(map[string]*NewRecipe)
+	newSources := make(map[string]string) // new source -> old source
+	var migrated []string
+	for _, name := range sortedDotfileNames(cfg) {
+		df := cfg.Dotfiles[name]
+		if config.OriginOf(cfg, config.KindDotfile, name) != (config.ItemOrigin{}) {
+			continue // From a recipe or an overlay
+		}
+		source := filepath.Clean(df.Source)
+		if df.Source == "" || filepath.IsAbs(source) || strings.HasPrefix(source, "~") || source == ".." || strings.HasPrefix(source, ".."+string(filepath.Separator)) {
+			continue
+		}
+		if source == recipesDir || strings.HasPrefix(source, recipesDir+string(filepath.Separator)) {
+			continue
+		}
+
+		recipeName, rest := recipeFor(name, source)
+		if existing[recipeName] {
+			return nil, fmt.Errorf("dotfile '%s' would move to recipe '%s', which already exists", name, recipeName)
+		}
+		r, ok := recipes[recipeName]
+		if !ok {
+			dir := filepath.Join(recipesDir, recipeName)
+			if _, err := os.Stat(filepath.Join(repoPath, dir)); err == nil {
+				return nil, fmt.Errorf("dotfile '%s' would move to %s, which already exists", name, dir)
+			}
+			r = &NewRecipe{Name: recipeName, Dir: dir, Dotfiles: make(map[string]config.Dotfile), Moves: make(map[string]string)}
+			recipes[recipeName] = r
+		}
+		newSource := filepath.Join(r.Dir, rest)
+		if old, ok := newSources[newSource]; ok && old != source {
+			return nil, fmt.Errorf("sources '%s' and '%s' would both move to %s", old, source, newSource)
+		}
+		newSources[newSource] = source
+		r.Moves[source] = newSource
+		df.Source = rest
+		r.Dotfiles[name] = df
+		migrated = append(migrated, name)
+	}
+	if len(recipes) == 0 {
+		return plan, nil
+	}
+
+	for _, r := range recipes {
+		plan.Recipes = append(plan.Recipes, *r)
+	}
+	sort.Slice(plan.Recipes, func(i, j int) bool { return plan.Recipes[i].Name < plan.Recipes[j].Name })
+
+	original, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	if !cfg.RecipesConfig.AutoDiscover {
+		for _, r := range plan.Recipes {
+			names = append(names, r.Name)
+		}
+	}
+	plan.NewConfig, err = rewriteConfig(original, migrated, names)
+	if err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// recipeFor returns the recipe a source moves to and its path in the recipe.
+func recipeFor(dotfileName, source string) (recipe, rest string) {
+	parts := strings.Split(filepath.ToSlash(source), "/")
+	for _, root := range LegacyRoots {
+		if len(parts) > 1 && parts[0] == root {
+			parts = parts[1:]
+			break
+		}
+	}
+	if len(parts) > 1 {
+		if name := strings.TrimLeft(parts[0], "."); name != "" {
+			return name, filepath.Join(parts[1:]...)
+		}
+	}
+	return dotfileName, filepath.Join(parts...)
+}
+
+func sortedDotfileNames(cfg *config.Config) []string {
+	names := make([]string, 0, len(cfg.Dotfiles))
+	for name := range cfg.Dotfiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// tableHeaderPattern matches a TOML table header line, capturing its key.
+var tableHeaderPattern = regexp.MustCompile(`^\s*\[\s*([^\[\]]+?)\s*\]\s*(#.*)?$`)
+
+// rewriteConfig removes the [dotfiles.<name>] tables (and their subtables)
+// of dotfiles, with the comment lines right above them, from a config file
+// and appends a [[recipes]] reference for each of recipes. Everything else is
+// kept as written.
+// The result is decoded again to check the dotfiles are really gone, since
+// dotfiles written as inline tables can't be removed this way.
+func rewriteConfig(original []byte, dotfiles, recipes []string) ([]byte, error) {
+	remove := make(map[string]bool, len(dotfiles))
+	for _, name := range dotfiles {
+		remove[name] = true
+	}
+
+	var kept []string
+	skipping := false
+	for _, line := range strings.SplitAfter(string(original), "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "[") {
+			skipping = false
+			if m := tableHeaderPattern.FindStringSubmatch(line); m != nil {
+				key := splitKey(m[1])
+				skipping = len(key) >= 2 && key[0] == "dotfiles" && remove[key[1]]
+			}
+			// Comments right above a removed table describe it
+			for skipping && len(kept) > 0 && strings.HasPrefix(strings.TrimSpace(kept[len(kept)-1]), "#") {
+				kept = kept[:len(kept)-1]
+			}
+		}
+		if !skipping {
+			kept = append(kept, line)
+		}
+	}
+
+	var out bytes.Buffer
+	out.WriteString(strings.TrimRight(strings.Join(kept, ""), "\n") + "\n")
+	for _, name := range recipes {
+		fmt.Fprintf(&out, "\n[[recipes]]\nname = %q\n", name)
+	}
+
+	var check struct {
+		Dotfiles map[string]interface{} `toml:"dotfiles"`
+	}
+	if _, err := toml.Decode(out.String(), &check); err != nil {
+		return nil, fmt.Errorf("rewritten config does not parse (add the recipes by hand if recipes is an inline array): %w", err)
+	}
+	for _, name := range dotfiles {
+		if _, ok := check.Dotfiles[name]; ok {
+			return nil, fmt.Errorf("could not remove dotfile '%s' from the config (is it an inline table?); move it by hand", name)
+		}
+	}
+	return out.Bytes(), nil
+}
+
+// splitKey splits a dotted TOML key, unquoting quoted parts.
+func splitKey(key string) []string {
+	var parts []string
+	var cur strings.Builder
+	var quote rune
+	for _, c := range key {
+		switch {
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0:
+			cur.WriteRune(c)
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '.':
+			parts = append(parts, strings.TrimSpace(cur.String()))
+			cur.Reset()
+		case c == ' ' || c == '\t':
+		default:
+			cur.WriteRune(c)
+		}
+	}
+	return append(parts, strings.TrimSpace(cur.String()))
+}
+
+// ApplyConfigMigration moves the sources, writes each recipe.toml with
+// legacy_paths for the old sources (so 'ralph migrate' can repoint existing
+// symlinks) and rewrites config.toml, keeping the original as
+// config.toml.bak. With dryRun it only describes the changes.
+func ApplyConfigMigration(plan *ConfigPlan, w io.Writer, dryRun bool) error {
+	prefix := ""
+	if dryRun {
+		prefix = "[DRY RUN] Would "
+	}
+	for _, r := range plan.Recipes {
+		fmt.Fprintf(w, "Recipe %s (%s):\n", r.Name, r.Dir)
+		olds := make([]string, 0, len(r.Moves))
+		for old := range r.Moves {
+			olds = append(olds, old)
+		}
+		sort.Strings(olds)
+		for _, old := range olds {
+			fmt.Fprintf(w, "  %smove %s -> %s\n", prefix, old, r.Moves[old])
+			if dryRun {
+				continue
+			}
+			from := filepath.Join(plan.RepoPath, old)
+			to := filepath.Join(plan.RepoPath, r.Moves[old])
+			if err := os.MkdirAll(filepath.Dir(to), 0755); err != nil {
+				return err
+			}
+			if err := os.Rename(from, to); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("failed to move %s: %w", old, err)
+			}
+		}
+
+		content, err := recipeTOML(r)
+		if err != nil {
+			return err
+		}
+		path := filepath.Join(r.Dir, config.RecipeFileName)
+		fmt.Fprintf(w, "  %swrite %s\n", prefix, path)
+		if !dryRun {
+			if err := os.MkdirAll(filepath.Join(plan.RepoPath, r.Dir), 0755); err != nil {
+				return err
+			}
+			if err := os.WriteFile(filepath.Join(plan.RepoPath, path), content, 0644); err != nil {
+				return err
+			}
+		}
+	}
+
+	fmt.Fprintf(w, "%srewrite %s (original kept as %s.bak)\n", prefix, config.ShortenHome(plan.ConfigPath), filepath.Base(plan.ConfigPath))
+	if dryRun {
+		return nil
+	}
+	original, err := os.ReadFile(plan.ConfigPath)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(plan.ConfigPath+".bak", original, 0644); err != nil {
+		return err
+	}
+	return os.WriteFile(plan.ConfigPath, plan.NewConfig, 0644)
+}
+
+// recipeTOML renders the recipe.toml of r.
+func recipeTOML(r NewRecipe) ([]byte, error) {
+	legacy := make(map[string]string, len(r.Moves))
+	for old, newSource := range r.Moves {
+		rel, err := filepath.Rel(r.Dir, newSource)
+		if err != nil {
+			return nil, err
+		}
+		legacy[old] = rel
+	}
+	var buf bytes.Buffer
+	buf.WriteString("# Written by ralph migrate-config from dotfiles in config.toml.\n\n")
+	err := toml.NewEncoder(&buf).Encode(struct {
+		Recipe   config.RecipeMetadata     `toml:"recipe"`
+		Dotfiles map[string]config.Dotfile `toml:"dotfiles"`
+	}{config.RecipeMetadata{Name: r.Name, LegacyPaths: legacy}, r.Dotfiles})
+	return buf.Bytes(), err
+}
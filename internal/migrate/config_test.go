@@ -0,0 +1,165 @@
+package migrate
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mad01/ralph/internal/config"
+)
+
+const flatConfig = `# Dotfiles
+dotfiles_repo_path = "REPO"
+
+# Editor
+[dotfiles.nvim]
+source = "dotter_files/nvim/init.lua"
+target = "~/.config/nvim/init.lua"
+
+[dotfiles.nvim_lua]
+source = "dotter_files/nvim/lua"
+target = "~/.config/nvim/lua"
+action = "symlink_dir"
+
+[dotfiles.zsh]
+source = "dotter_files/zshrc"
+target = "~/.zshrc"
+
+[dotfiles.zsh.vars]
+prompt = "%"
+
+[shell.aliases.ll]
+command = "ls -l" # kept
+`
+
+func TestPlanConfigMigration(t *testing.T) {
+	tempDir := t.TempDir()
+	repo := filepath.Join(tempDir, "dotfiles")
+	for _, f := range []string{"dotter_files/nvim/init.lua", "dotter_files/nvim/lua/plugins.lua", "dotter_files/zshrc"} {
+		os.MkdirAll(filepath.Join(repo, filepath.Dir(f)), 0755)
+		os.WriteFile(filepath.Join(repo, f), []byte("x\n"), 0644)
+	}
+	configPath := filepath.Join(tempDir, "config.toml")
+	os.WriteFile(configPath, []byte(strings.Replace(flatConfig, "REPO", repo, 1)), 0644)
+
+	cfg := &config.Config{
+		DotfilesRepoPath: repo,
+		Dotfiles: map[string]config.Dotfile{
+			"nvim":     {Source: "dotter_files/nvim/init.lua", Target: "~/.config/nvim/init.lua"},
+			"nvim_lua": {Source: "dotter_files/nvim/lua", Target: "~/.config/nvim/lua", Action: "symlink_dir"},
+			"zsh":      {Source: "dotter_files/zshrc", Target: "~/.zshrc", Vars: map[string]interface{}{"prompt": "%"}},
+			"git":      {Source: "recipes/git/gitconfig", Target: "~/.gitconfig"},
+		},
+	}
+
+	plan, err := PlanConfigMigration(cfg, configPath)
+	if err != nil {
+		t.Fatalf("PlanConfigMigration() error = %v", err)
+	}
+	if len(plan.Recipes) != 2 || plan.Recipes[0].Name != "nvim" || plan.Recipes[1].Name != "zsh" {
+		t.Fatalf("recipes = %+v, want nvim and zsh", plan.Recipes)
+	}
+	wantMoves := map[string]string{
+		"dotter_files/nvim/init.lua": "recipes/nvim/init.lua",
+		"dotter_files/nvim/lua":      "recipes/nvim/lua",
+	}
+	if !reflect.DeepEqual(plan.Recipes[0].Moves, wantMoves) {
+		t.Errorf("nvim moves = %v, want %v", plan.Recipes[0].Moves, wantMoves)
+	}
+	if got := plan.Recipes[1].Dotfiles["zsh"].Source; got != "zshrc" {
+		t.Errorf("zsh source = %q, want zshrc", got)
+	}
+
+	newConfig := string(plan.NewConfig)
+	for _, gone := range []string{"[dotfiles.", "prompt =", "# Editor"} {
+		if strings.Contains(newConfig, gone) {
+			t.Errorf("rewritten config still contains %q:\n%s", gone, newConfig)
+		}
+	}
+	for _, kept := range []string{"# Dotfiles", `command = "ls -l" # kept`, "[[recipes]]\nname = \"nvim\"", "[[recipes]]\nname = \"zsh\""} {
+		if !strings.Contains(newConfig, kept) {
+			t.Errorf("rewritten config is missing %q:\n%s", kept, newConfig)
+		}
+	}
+
+	var out bytes.Buffer
+	if err := ApplyConfigMigration(plan, &out, true); err != nil {
+		t.Fatalf("ApplyConfigMigration(dry run) error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(repo, "recipes")); !os.IsNotExist(err) {
+		t.Error("dry run should not create the recipes dir")
+	}
+
+	if err := ApplyConfigMigration(plan, &out, false); err != nil {
+		t.Fatalf("ApplyConfigMigration() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(repo, "recipes", "nvim", "lua", "plugins.lua")); err != nil {
+		t.Errorf("nvim/lua was not moved: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(repo, "dotter_files", "zshrc")); !os.IsNotExist(err) {
+		t.Error("zshrc should have moved out of dotter_files")
+	}
+	recipe, err := config.LoadRecipe(filepath.Join(repo, "recipes", "nvim", config.RecipeFileName))
+	if err != nil {
+		t.Fatalf("LoadRecipe() error = %v", err)
+	}
+	if got := recipe.Recipe.LegacyPaths["dotter_files/nvim/lua"]; got != "lua" {
+		t.Errorf("legacy path = %q, want lua", got)
+	}
+	if got := recipe.Dotfiles["nvim_lua"]; got.Source != "lua" || got.Action != "symlink_dir" {
+		t.Errorf("nvim_lua = %+v", got)
+	}
+	if data, _ := os.ReadFile(configPath); string(data) != newConfig {
+		t.Error("config.toml was not rewritten")
+	}
+	if data, _ := os.ReadFile(configPath + ".bak"); !strings.Contains(string(data), "[dotfiles.nvim]") {
+		t.Error("config.toml.bak should hold the original config")
+	}
+}
+
+func TestPlanConfigMigration_AutoDiscoverAndExisting(t *testing.T) {
+	tempDir := t.TempDir()
+	repo := filepath.Join(tempDir, "dotfiles")
+	os.MkdirAll(filepath.Join(repo, "recipes", "zsh"), 0755)
+	configPath := filepath.Join(tempDir, "config.toml")
+	os.WriteFile(configPath, []byte("[recipes_config]\nauto_discover = true\n\n[dotfiles.tmux]\nsource = \"tmux.conf\"\ntarget = \"~/.tmux.conf\"\n"), 0644)
+
+	cfg := &config.Config{
+		DotfilesRepoPath: repo,
+		RecipesConfig:    config.RecipesConfig{AutoDiscover: true},
+		Dotfiles:         map[string]config.Dotfile{"tmux": {Source: "tmux.conf", Target: "~/.tmux.conf"}},
+	}
+	plan, err := PlanConfigMigration(cfg, configPath)
+	if err != nil {
+		t.Fatalf("PlanConfigMigration() error = %v", err)
+	}
+	if strings.Contains(string(plan.NewConfig), "[[recipes]]") {
+		t.Errorf("auto-discovery needs no recipe references:\n%s", plan.NewConfig)
+	}
+
+	cfg.Dotfiles["zsh"] = config.Dotfile{Source: "zshrc", Target: "~/.zshrc"}
+	if _, err := PlanConfigMigration(cfg, configPath); err == nil {
+		t.Error("expected an error when the recipe dir already exists")
+	}
+}
+
+func TestRecipeFor(t *testing.T) {
+	tests := []struct {
+		dotfile, source string
+		recipe, rest    string
+	}{
+		{"nvim", "dotter_files/nvim/init.lua", "nvim", "init.lua"},
+		{"zsh", "ralph_files/zshrc", "zsh", "zshrc"},
+		{"zsh", "zshrc", "zsh", "zshrc"},
+		{"kitty", ".config/kitty/kitty.conf", "config", "kitty/kitty.conf"},
+	}
+	for _, tt := range tests {
+		recipe, rest := recipeFor(tt.dotfile, tt.source)
+		if recipe != tt.recipe || rest != tt.rest {
+			t.Errorf("recipeFor(%q, %q) = %q, %q, want %q, %q", tt.dotfile, tt.source, recipe, rest, tt.recipe, tt.rest)
+		}
+	}
+}
@@ -4,14 +4,17 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"strings"
 
 	"github.com/mad01/ralph/internal/config"
+	"github.com/mad01/ralph/internal/dotfile"
+	"github.com/mad01/ralph/internal/report"
 )
 
 // MigrationResult represents the result of checking a single symlink
 type MigrationResult struct {
+	Name          string // Dotfile name
 	Target        string // Target path (e.g., ~/.config/nvim/init.lua)
+	OldTarget     string // Legacy target still on disk (StatusMoveTarget only)
 	CurrentSource string // Where the symlink currently points
 	NewSource     string // Where the symlink should point
 	Status        MigrationStatus
@@ -34,6 +37,9 @@ const (
 	StatusNotExist
 	// StatusError means an error occurred checking the symlink
 	StatusError
+	// StatusMoveTarget means a legacy target still exists and must be moved
+	// (or relinked) to the dotfile's current target
+	StatusMoveTarget
 )
 
 func (s MigrationStatus) String() string {
@@ -50,6 +56,8 @@ func (s MigrationStatus) String() string {
 		return "NOT_EXIST"
 	case StatusError:
 		return "ERROR"
+	case StatusMoveTarget:
+		return "MOVE"
 	default:
 		return "UNKNOWN"
 	}
@@ -64,6 +72,7 @@ type MigrationPlan struct {
 	NotSymlinks   int
 	NotExist      int
 	Errors        int
+	TargetMoves   int
 	RepoPath      string
 	LegacyPathMap map[string]string // old path -> new path
 }
@@ -76,8 +85,9 @@ func CheckMigration(cfg *config.Config) (*MigrationPlan, error) {
 		return nil, fmt.Errorf("failed to expand dotfiles repo path: %w", err)
 	}
 
-	// Get all legacy path mappings
+	// Get all legacy path and target mappings
 	legacyPaths := config.GetAllLegacyPaths(cfg)
+	legacyTargets := config.GetAllLegacyTargets(cfg)
 
 	plan := &MigrationPlan{
 		RepoPath:      expandedRepoPath,
@@ -86,6 +96,12 @@ func CheckMigration(cfg *config.Config) (*MigrationPlan, error) {
 
 	// Check each dotfile
 	for name, df := range cfg.Dotfiles {
+		if result, ok := checkTargetMove(name, df, expandedRepoPath, legacyTargets); ok {
+			plan.Results = append(plan.Results, result)
+			plan.TargetMoves++
+			continue
+		}
+
 		result := checkSymlink(name, df, expandedRepoPath, legacyPaths)
 		plan.Results = append(plan.Results, result)
 
@@ -108,9 +124,44 @@ func CheckMigration(cfg *config.Config) (*MigrationPlan, error) {
 	return plan, nil
 }
 
+// checkTargetMove returns a StatusMoveTarget result when a legacy target that
+// maps to the dotfile's target still exists on disk.
+func checkTargetMove(name string, df config.Dotfile, repoPath string, legacyTargets map[string]string) (MigrationResult, bool) {
+	newTarget, err := config.ExpandPath(df.Target)
+	if err != nil {
+		return MigrationResult{}, false
+	}
+	for oldTarget, mappedTarget := range legacyTargets {
+		expandedMapped, err := config.ExpandPath(mappedTarget)
+		if err != nil || !config.SamePath(expandedMapped, newTarget) {
+			continue
+		}
+		expandedOld, err := config.ExpandPath(oldTarget)
+		if err != nil {
+			continue
+		}
+		if _, err := os.Lstat(expandedOld); err != nil {
+			continue
+		}
+
+		result := MigrationResult{
+			Name:      name,
+			Target:    newTarget,
+			OldTarget: expandedOld,
+			NewSource: filepath.Join(repoPath, df.Source),
+			Status:    StatusMoveTarget,
+		}
+		if dest, err := os.Readlink(expandedOld); err == nil {
+			result.CurrentSource = dest
+		}
+		return result, true
+	}
+	return MigrationResult{}, false
+}
+
 // checkSymlink checks a single dotfile's symlink status
-func checkSymlink(_ string, df config.Dotfile, repoPath string, legacyPaths map[string]string) MigrationResult {
-	result := MigrationResult{}
+func checkSymlink(name string, df config.Dotfile, repoPath string, legacyPaths map[string]string) MigrationResult {
+	result := MigrationResult{Name: name}
 
 	// Expand target path
 	expandedTarget, err := config.ExpandPath(df.Target)
@@ -138,7 +189,7 @@ func checkSymlink(_ string, df config.Dotfile, repoPath string, legacyPaths map[
 	}
 
 	// Check if it's a symlink
-	if info.Mode()&os.ModeSymlink == 0 {
+	if !dotfile.IsLink(info) {
 		result.Status = StatusNotSymlink
 		return result
 	}
@@ -153,7 +204,7 @@ func checkSymlink(_ string, df config.Dotfile, repoPath string, legacyPaths map[
 	result.CurrentSource = linkDest
 
 	// Check if symlink already points to the correct location
-	if linkDest == expectedSource {
+	if config.SamePath(linkDest, expectedSource) {
 		result.Status = StatusAlreadyCorrect
 		return result
 	}
@@ -164,9 +215,9 @@ func checkSymlink(_ string, df config.Dotfile, repoPath string, legacyPaths map[
 		newAbsPath := filepath.Join(repoPath, newPath)
 
 		// Check if current link destination matches or ends with the old path
-		if linkDest == oldAbsPath || strings.HasSuffix(linkDest, "/"+oldPath) {
+		if config.SamePath(linkDest, oldAbsPath) || config.PathHasSuffix(linkDest, oldPath) {
 			// Verify the new path matches our expected source
-			if newAbsPath == expectedSource || strings.HasSuffix(expectedSource, "/"+newPath) {
+			if config.SamePath(newAbsPath, expectedSource) || config.PathHasSuffix(expectedSource, newPath) {
 				result.Status = StatusNeedsUpdate
 				return result
 			}
@@ -186,38 +237,240 @@ func checkSymlink(_ string, df config.Dotfile, repoPath string, legacyPaths map[
 	return result
 }
 
+// Options controls how a migration is executed.
+type Options struct {
+	DryRun bool
+	// Confirm, when set, is asked before each symlink update or target move;
+	// returning false skips that target. It is not called in a dry run.
+	Confirm func(result MigrationResult) (bool, error)
+}
+
 // ExecuteMigration performs the actual symlink updates based on the migration plan.
 // If dryRun is true, it only reports what would be done.
 func ExecuteMigration(plan *MigrationPlan, dryRun bool) error {
 	for _, result := range plan.Results {
-		if result.Status != StatusNeedsUpdate {
-			continue
+		switch result.Status {
+		case StatusNeedsUpdate:
+			if _, err := updateSymlink(result, dryRun); err != nil {
+				return err
+			}
+		case StatusMoveTarget:
+			if _, err := moveTarget(result, dryRun); err != nil {
+				return err
+			}
 		}
+	}
 
-		if dryRun {
-			fmt.Printf("[DRY RUN] Would update symlink:\n")
-			fmt.Printf("  Target:  %s\n", result.Target)
-			fmt.Printf("  From:    %s\n", result.CurrentSource)
-			fmt.Printf("  To:      %s\n", result.NewSource)
-			continue
+	return nil
+}
+
+// RunMigration performs the symlink updates and target moves like
+// ExecuteMigration, but asks opts.Confirm for each one, keeps going past
+// failures and records one step per target in phase: updated or moved
+// (pending in a dry run), declined or failed targets, broken symlinks and
+// non-symlink targets as warnings, and targets apply has yet to create as
+// skipped.
+func RunMigration(plan *MigrationPlan, opts Options, phase *report.Phase) {
+	for _, result := range plan.Results {
+		name := config.ShortenHome(result.Target)
+		switch result.Status {
+		case StatusAlreadyCorrect:
+			phase.AddOK(name, "already correct")
+		case StatusNeedsUpdate, StatusMoveTarget:
+			if !opts.DryRun && opts.Confirm != nil {
+				proceed, err := opts.Confirm(result)
+				if err != nil {
+					phase.AddFail(name, "confirmation failed", err)
+					continue
+				}
+				if !proceed {
+					phase.AddSkip(name, "declined")
+					continue
+				}
+			}
+
+			var backupPath string
+			var err error
+			msg := "updated to " + result.NewSource
+			if result.Status == StatusMoveTarget {
+				backupPath, err = moveTarget(result, opts.DryRun)
+				msg = "moved from " + config.ShortenHome(result.OldTarget)
+			} else {
+				backupPath, err = updateSymlink(result, opts.DryRun)
+			}
+			if backupPath != "" {
+				msg += "; backed up unexpected target to " + config.ShortenHome(backupPath)
+			}
+			switch {
+			case err != nil:
+				phase.AddFail(name, err.Error(), err)
+			case opts.DryRun:
+				phase.AddPending(name, "would be "+msg)
+			default:
+				phase.AddOK(name, msg)
+			}
+		case StatusBroken:
+			phase.AddWarn(name, "broken symlink with no legacy mapping: "+result.CurrentSource)
+		case StatusNotSymlink:
+			phase.AddWarn(name, "not a symlink; manual intervention may be needed")
+		case StatusNotExist:
+			phase.AddSkip(name, "not created yet (run 'ralph apply')")
+		case StatusError:
+			phase.AddFail(name, "check failed", result.Error)
+		}
+	}
+}
+
+// updateSymlink points a symlink that needs updating at its new source. If
+// the target changed since the plan was made (it is no longer a symlink to
+// the legacy path), it is backed up first and the backup path is returned.
+func updateSymlink(result MigrationResult, dryRun bool) (string, error) {
+	backupPath, err := backupUnexpected(result.Target, result.CurrentSource, dryRun)
+	if err != nil {
+		return "", err
+	}
+
+	if dryRun {
+		fmt.Printf("[DRY RUN] Would update symlink:\n")
+		fmt.Printf("  Target:  %s\n", result.Target)
+		fmt.Printf("  From:    %s\n", result.CurrentSource)
+		fmt.Printf("  To:      %s\n", result.NewSource)
+		return backupPath, nil
+	}
+
+	// Remove old symlink (a backed up target is already gone)
+	if backupPath == "" {
+		if err := os.Remove(result.Target); err != nil && !os.IsNotExist(err) {
+			return "", fmt.Errorf("failed to remove old symlink %s: %w", result.Target, err)
 		}
+	}
+
+	// Create new symlink
+	if err := dotfile.Symlink(result.NewSource, result.Target); err != nil {
+		return backupPath, fmt.Errorf("failed to create new symlink %s -> %s: %w", result.Target, result.NewSource, err)
+	}
 
-		// Remove old symlink
-		if err := os.Remove(result.Target); err != nil {
-			return fmt.Errorf("failed to remove old symlink %s: %w", result.Target, err)
+	fmt.Printf("Updated symlink: %s\n", result.Target)
+	fmt.Printf("  From: %s\n", result.CurrentSource)
+	fmt.Printf("  To:   %s\n", result.NewSource)
+	return backupPath, nil
+}
+
+// moveTarget moves a dotfile from its legacy target to its current one. A
+// symlink is recreated at the new target, pointing where the old one did (or
+// at the dotfile source if the old link is broken), and then removed. Anything
+// else (a copied file or directory) is renamed. Whatever already exists at
+// the new target, other than an identical symlink, is backed up first and the
+// backup path is returned. The manifest entry of the dotfile is updated to
+// the new target.
+func moveTarget(result MigrationResult, dryRun bool) (string, error) {
+	info, err := os.Lstat(result.OldTarget)
+	if err != nil {
+		return "", fmt.Errorf("failed to stat old target %s: %w", result.OldTarget, err)
+	}
+	isLink := dotfile.IsLink(info)
+
+	dest := result.NewSource
+	if isLink {
+		if result.CurrentSource != "" {
+			dest = result.CurrentSource
+			if !filepath.IsAbs(dest) {
+				dest = filepath.Join(filepath.Dir(result.OldTarget), dest)
+			}
+		}
+		if _, err := os.Stat(result.OldTarget); err != nil {
+			dest = result.NewSource
 		}
+	}
+
+	expected := ""
+	if isLink {
+		expected = dest
+	}
+	backupPath, err := backupUnexpected(result.Target, expected, dryRun)
+	if err != nil {
+		return "", err
+	}
+
+	if dryRun {
+		fmt.Printf("[DRY RUN] Would move target:\n")
+		fmt.Printf("  From:    %s\n", result.OldTarget)
+		fmt.Printf("  To:      %s\n", result.Target)
+		return backupPath, nil
+	}
 
-		// Create new symlink
-		if err := os.Symlink(result.NewSource, result.Target); err != nil {
-			return fmt.Errorf("failed to create new symlink %s -> %s: %w", result.Target, result.NewSource, err)
+	if err := os.MkdirAll(filepath.Dir(result.Target), 0755); err != nil {
+		return backupPath, fmt.Errorf("failed to create parent directory for %s: %w", result.Target, err)
+	}
+	if isLink {
+		if _, err := os.Lstat(result.Target); os.IsNotExist(err) {
+			if err := dotfile.Symlink(dest, result.Target); err != nil {
+				return backupPath, fmt.Errorf("failed to create new symlink %s -> %s: %w", result.Target, dest, err)
+			}
 		}
+		if err := os.Remove(result.OldTarget); err != nil {
+			return backupPath, fmt.Errorf("failed to remove old symlink %s: %w", result.OldTarget, err)
+		}
+	} else if err := os.Rename(result.OldTarget, result.Target); err != nil {
+		return backupPath, fmt.Errorf("failed to move %s to %s: %w", result.OldTarget, result.Target, err)
+	}
 
-		fmt.Printf("Updated symlink: %s\n", result.Target)
-		fmt.Printf("  From: %s\n", result.CurrentSource)
-		fmt.Printf("  To:   %s\n", result.NewSource)
+	if err := updateManifestTarget(result.Name, result.OldTarget, result.Target); err != nil {
+		return backupPath, err
 	}
 
-	return nil
+	fmt.Printf("Moved target: %s\n", result.Target)
+	fmt.Printf("  From: %s\n", result.OldTarget)
+	return backupPath, nil
+}
+
+// backupUnexpected renames whatever is at target to target.bak, unless
+// target does not exist or is a symlink to expected. It returns the backup
+// path, or "" when nothing needed backing up. An existing backup is never
+// overwritten.
+func backupUnexpected(target, expected string, dryRun bool) (string, error) {
+	info, err := os.Lstat(target)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to stat %s: %w", target, err)
+	}
+	if expected != "" && dotfile.IsLink(info) {
+		if dest, err := os.Readlink(target); err == nil && config.SamePath(dest, expected) {
+			return "", nil
+		}
+	}
+
+	backupPath := target + ".bak"
+	if _, err := os.Lstat(backupPath); err == nil {
+		return "", fmt.Errorf("%s is not what the migration plan expected and %s already exists; move one of them away", target, backupPath)
+	}
+	if dryRun {
+		fmt.Printf("[DRY RUN] Would back up %s to %s\n", target, backupPath)
+		return backupPath, nil
+	}
+	if err := os.Rename(target, backupPath); err != nil {
+		return "", fmt.Errorf("failed to back up %s to %s: %w", target, backupPath, err)
+	}
+	fmt.Printf("Backed up %s to %s\n", target, backupPath)
+	return backupPath, nil
+}
+
+// updateManifestTarget points the manifest entry of a moved dotfile at its
+// new target, so uninstall removes the right file.
+func updateManifestTarget(name, oldTarget, newTarget string) error {
+	manifest, err := dotfile.LoadManifest()
+	if err != nil {
+		return err
+	}
+	entry, ok := manifest.Dotfiles[name]
+	if !ok || !config.SamePath(entry.Target, oldTarget) {
+		return nil
+	}
+	entry.Target = newTarget
+	manifest.Dotfiles[name] = entry
+	return dotfile.SaveManifest(manifest)
 }
 
 // PrintMigrationPlan prints a summary of the migration plan
@@ -226,6 +479,7 @@ func PrintMigrationPlan(plan *MigrationPlan) {
 	fmt.Println("======================")
 	fmt.Printf("Already correct:  %d\n", plan.AlreadyOK)
 	fmt.Printf("Needs update:     %d\n", plan.NeedsUpdate)
+	fmt.Printf("Targets to move:  %d\n", plan.TargetMoves)
 	fmt.Printf("Broken symlinks:  %d\n", plan.Broken)
 	fmt.Printf("Not symlinks:     %d\n", plan.NotSymlinks)
 	fmt.Printf("Not yet created:  %d\n", plan.NotExist)
@@ -244,6 +498,17 @@ func PrintMigrationPlan(plan *MigrationPlan) {
 		fmt.Println()
 	}
 
+	if plan.TargetMoves > 0 {
+		fmt.Println("Targets to move:")
+		for _, result := range plan.Results {
+			if result.Status == StatusMoveTarget {
+				fmt.Printf("  %s\n", result.OldTarget)
+				fmt.Printf("    New:     %s\n", result.Target)
+			}
+		}
+		fmt.Println()
+	}
+
 	if plan.Broken > 0 {
 		fmt.Println("Broken symlinks (no legacy mapping found):")
 		for _, result := range plan.Results {
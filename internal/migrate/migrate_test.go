@@ -3,9 +3,12 @@ package migrate
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/mad01/ralph/internal/config"
+	"github.com/mad01/ralph/internal/dotfile"
+	"github.com/mad01/ralph/internal/report"
 )
 
 func setupTestEnv(t *testing.T) (tempDir string, cleanup func()) {
@@ -468,3 +471,215 @@ func TestCheckMigration_MixedState(t *testing.T) {
 		t.Errorf("Expected 1 not exist, got %d", plan.NotExist)
 	}
 }
+
+func TestRunMigration_RecordsEveryTarget(t *testing.T) {
+	tempDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	repoPath := filepath.Join(tempDir, "dotfiles")
+	os.MkdirAll(filepath.Join(repoPath, "new"), 0755)
+	newFile := filepath.Join(repoPath, "new", "file.txt")
+	os.WriteFile(newFile, []byte("content"), 0644)
+
+	oldFile := filepath.Join(repoPath, "old", "file.txt")
+	targetPath := filepath.Join(tempDir, "target.txt")
+	os.Symlink(oldFile, targetPath)
+
+	plan := &MigrationPlan{
+		RepoPath: repoPath,
+		Results: []MigrationResult{
+			{Target: targetPath, CurrentSource: oldFile, NewSource: newFile, Status: StatusNeedsUpdate},
+			{Target: filepath.Join(tempDir, "missing-parent", "gone.txt"), CurrentSource: oldFile, NewSource: newFile, Status: StatusNeedsUpdate},
+			{Target: filepath.Join(tempDir, "broken.txt"), CurrentSource: oldFile, Status: StatusBroken},
+			{Target: filepath.Join(tempDir, "later.txt"), Status: StatusNotExist},
+		},
+		NeedsUpdate: 2,
+	}
+
+	phase := &report.Phase{Name: "Symlinks"}
+	RunMigration(plan, Options{}, phase)
+
+	ok, warn, fail, skip := phase.Counts()
+	if ok != 1 || warn != 1 || fail != 1 || skip != 1 {
+		t.Errorf("counts = %d ok, %d warn, %d fail, %d skip; want 1 of each", ok, warn, fail, skip)
+	}
+	if link, _ := os.Readlink(targetPath); link != newFile {
+		t.Errorf("symlink = %q, want %q (a later failure must not stop earlier updates)", link, newFile)
+	}
+
+	dryPhase := &report.Phase{Name: "Symlinks"}
+	RunMigration(&MigrationPlan{Results: plan.Results[:1]}, Options{DryRun: true}, dryPhase)
+	if len(dryPhase.Steps) != 1 || !dryPhase.Steps[0].Pending {
+		t.Errorf("dry run steps = %+v, want one pending step", dryPhase.Steps)
+	}
+}
+
+func TestCheckMigration_LegacyTarget(t *testing.T) {
+	tempDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+	t.Setenv(config.StateDirEnv, filepath.Join(tempDir, "state"))
+
+	repoPath := filepath.Join(tempDir, "dotfiles")
+	os.MkdirAll(filepath.Join(repoPath, "vim"), 0755)
+	source := filepath.Join(repoPath, "vim", "vimrc")
+	os.WriteFile(source, []byte("set nocompatible"), 0644)
+
+	oldTarget := filepath.Join(tempDir, ".vimrc")
+	newTarget := filepath.Join(tempDir, ".config", "nvim", "init.vim")
+	os.Symlink(source, oldTarget)
+
+	if err := dotfile.SaveManifest(&dotfile.Manifest{Dotfiles: map[string]dotfile.ManifestEntry{
+		"vim": {Action: "symlink", Source: source, Target: oldTarget},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{
+		DotfilesRepoPath: repoPath,
+		Dotfiles: map[string]config.Dotfile{
+			"vim": {Source: "vim/vimrc", Target: newTarget},
+		},
+		LoadedRecipes: []config.LoadedRecipeInfo{
+			{Dir: "vim", LegacyTargets: map[string]string{oldTarget: newTarget}},
+		},
+	}
+
+	plan, err := CheckMigration(cfg)
+	if err != nil {
+		t.Fatalf("CheckMigration() error: %v", err)
+	}
+	if plan.TargetMoves != 1 || plan.Results[0].Status != StatusMoveTarget {
+		t.Fatalf("plan = %+v, want one target move", plan)
+	}
+
+	if err := ExecuteMigration(plan, true); err != nil {
+		t.Fatalf("dry run error: %v", err)
+	}
+	if _, err := os.Lstat(newTarget); !os.IsNotExist(err) {
+		t.Error("dry run should not create the new target")
+	}
+
+	if err := ExecuteMigration(plan, false); err != nil {
+		t.Fatalf("ExecuteMigration() error: %v", err)
+	}
+	if _, err := os.Lstat(oldTarget); !os.IsNotExist(err) {
+		t.Error("old target symlink should be removed")
+	}
+	if link, err := os.Readlink(newTarget); err != nil || link != source {
+		t.Errorf("new target = %q, %v; want a symlink to %q", link, err, source)
+	}
+
+	manifest, err := dotfile.LoadManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := manifest.Dotfiles["vim"].Target; got != newTarget {
+		t.Errorf("manifest target = %q, want %q", got, newTarget)
+	}
+
+	// Once moved, the old target is gone and nothing is left to do.
+	plan, err = CheckMigration(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.TargetMoves != 0 || plan.AlreadyOK != 1 {
+		t.Errorf("second plan = %d moves, %d ok; want 0, 1", plan.TargetMoves, plan.AlreadyOK)
+	}
+}
+
+func TestExecuteMigration_MovesCopiedTarget(t *testing.T) {
+	tempDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+	t.Setenv(config.StateDirEnv, filepath.Join(tempDir, "state"))
+
+	oldTarget := filepath.Join(tempDir, ".vimrc")
+	newTarget := filepath.Join(tempDir, ".config", "nvim", "init.vim")
+	os.WriteFile(oldTarget, []byte("local edits"), 0644)
+
+	plan := &MigrationPlan{
+		Results: []MigrationResult{
+			{Name: "vim", Target: newTarget, OldTarget: oldTarget, Status: StatusMoveTarget},
+		},
+		TargetMoves: 1,
+	}
+	if err := ExecuteMigration(plan, false); err != nil {
+		t.Fatalf("ExecuteMigration() error: %v", err)
+	}
+	if content, err := os.ReadFile(newTarget); err != nil || string(content) != "local edits" {
+		t.Errorf("new target content = %q, %v; want the moved file", content, err)
+	}
+
+	// Something already at the new target is backed up, never overwritten.
+	os.WriteFile(oldTarget, []byte("older"), 0644)
+	if err := ExecuteMigration(plan, false); err != nil {
+		t.Fatalf("ExecuteMigration() error: %v", err)
+	}
+	if content, _ := os.ReadFile(newTarget + ".bak"); string(content) != "local edits" {
+		t.Errorf("backup content = %q, want the file that was at the new target", content)
+	}
+	if content, _ := os.ReadFile(newTarget); string(content) != "older" {
+		t.Errorf("new target content = %q, want the moved file", content)
+	}
+
+	// With a backup already in place, nothing is touched.
+	os.WriteFile(oldTarget, []byte("oldest"), 0644)
+	if err := ExecuteMigration(plan, false); err == nil {
+		t.Error("expected an error when the backup path is taken")
+	}
+	if content, _ := os.ReadFile(oldTarget); string(content) != "oldest" {
+		t.Error("old target should be left alone when the backup fails")
+	}
+}
+
+func TestRunMigration_ConfirmAndBackup(t *testing.T) {
+	tempDir, cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	repoPath := filepath.Join(tempDir, "dotfiles")
+	os.MkdirAll(filepath.Join(repoPath, "new"), 0755)
+	newFile := filepath.Join(repoPath, "new", "file.txt")
+	os.WriteFile(newFile, []byte("content"), 0644)
+	oldFile := filepath.Join(repoPath, "old", "file.txt")
+
+	declined := filepath.Join(tempDir, "declined.txt")
+	os.Symlink(oldFile, declined)
+	// Replaced by a real file after the plan was made.
+	replaced := filepath.Join(tempDir, "replaced.txt")
+	os.WriteFile(replaced, []byte("user file"), 0644)
+
+	plan := &MigrationPlan{
+		Results: []MigrationResult{
+			{Target: declined, CurrentSource: oldFile, NewSource: newFile, Status: StatusNeedsUpdate},
+			{Target: replaced, CurrentSource: oldFile, NewSource: newFile, Status: StatusNeedsUpdate},
+		},
+		NeedsUpdate: 2,
+	}
+
+	var asked []string
+	opts := Options{Confirm: func(result MigrationResult) (bool, error) {
+		asked = append(asked, result.Target)
+		return result.Target != declined, nil
+	}}
+	phase := &report.Phase{Name: "Symlinks"}
+	RunMigration(plan, opts, phase)
+
+	if len(asked) != 2 {
+		t.Errorf("Confirm called for %v, want both targets", asked)
+	}
+	if link, _ := os.Readlink(declined); link != oldFile {
+		t.Errorf("declined symlink = %q, want it unchanged", link)
+	}
+	if content, _ := os.ReadFile(replaced + ".bak"); string(content) != "user file" {
+		t.Errorf("backup content = %q, want the unexpected file", content)
+	}
+	if link, _ := os.Readlink(replaced); link != newFile {
+		t.Errorf("replaced target = %q, want a symlink to %q", link, newFile)
+	}
+	ok, _, fail, skip := phase.Counts()
+	if ok != 1 || skip != 1 || fail != 0 {
+		t.Errorf("counts = %d ok, %d skip, %d fail; want 1, 1, 0", ok, skip, fail)
+	}
+	if !strings.Contains(phase.Steps[1].Message, "backed up") {
+		t.Errorf("message = %q, want it to mention the backup", phase.Steps[1].Message)
+	}
+}
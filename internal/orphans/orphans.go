@@ -0,0 +1,222 @@
+// Package orphans finds files in the dotfiles repo that nothing in the config
+// refers to: leftovers from dotfiles, tools and recipes that were removed.
+package orphans
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/mad01/ralph/internal/config"
+	"github.com/mad01/ralph/internal/inventory"
+)
+
+// Find walks the dotfiles repo and returns the files, relative to the repo
+// and sorted, that no dotfile, tool config file, recipe, bin directory,
+// completion path, sops file or template include refers to. The config
+// files (config.toml and the config.*.toml next to it) count when they live
+// in the repo. A referenced directory covers everything under it. Files matching cfg.Orphans.Ignore are left out, as
+// are git's own files and the machines 'ralph apply' records.
+func Find(cfg *config.Config) ([]string, error) {
+	repo, err := config.ExpandPath(cfg.DotfilesRepoPath)
+	if err != nil {
+		return nil, err
+	}
+	refs := references(cfg, repo)
+
+	var orphans []string
+	err = filepath.WalkDir(repo, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == repo {
+			return nil
+		}
+		rel, err := filepath.Rel(repo, path)
+		if err != nil {
+			return err
+		}
+		if refs[rel] || ignored(rel, cfg.Orphans.Ignore) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.IsDir() {
+			orphans = append(orphans, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(orphans)
+	return orphans, nil
+}
+
+// references returns the repo-relative paths the config refers to.
+func references(cfg *config.Config, repo string) map[string]bool {
+	refs := map[string]bool{
+		".git":           true,
+		".gitignore":     true,
+		".gitattributes": true,
+		".gitmodules":    true,
+		inventory.Dir:    true,
+	}
+	add := func(path string) {
+		if path == "" {
+			return
+		}
+		if filepath.IsAbs(path) || strings.HasPrefix(path, "~") {
+			expanded, err := config.ExpandPath(path)
+			if err != nil {
+				return
+			}
+			rel, err := filepath.Rel(repo, expanded)
+			if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+				return
+			}
+			path = rel
+		}
+		refs[filepath.Clean(path)] = true
+	}
+
+	for _, df := range cfg.Dotfiles {
+		add(df.Source)
+	}
+	for _, t := range cfg.Tools {
+		for _, cf := range t.ConfigFiles {
+			add(cf.Source)
+		}
+	}
+	for _, r := range cfg.LoadedRecipes {
+		add(r.Path)
+	}
+	// Items of a skipped recipe are not loaded on this machine, but they are
+	// in use elsewhere: keep everything next to its recipe.toml.
+	for _, s := range cfg.SkippedRecipes {
+		path := config.ResolveRecipeRefPath(s.Ref, cfg.RecipesConfig.Dir)
+		if path != "" {
+			add(filepath.Dir(path))
+		}
+	}
+	add(cfg.Bin.Source)
+	add(cfg.TemplateVariablesSops)
+	for _, path := range cfg.Shell.Completions.Paths {
+		add(path)
+	}
+	for _, path := range templateIncludes(cfg, repo) {
+		add(path)
+	}
+
+	// The config is usually kept in the repo and linked into
+	// ~/.config/ralph, along with overlays for every host and OS
+	if configPath, err := config.GetDefaultConfigPath(); err == nil {
+		configFiles, _ := filepath.Glob(filepath.Join(filepath.Dir(configPath), "config.*.toml"))
+		for _, path := range append([]string{configPath}, append(configFiles, cfg.Overlays...)...) {
+			if rel, ok := inRepo(repo, path); ok {
+				refs[rel] = true
+			}
+		}
+	}
+	return refs
+}
+
+// inRepo returns path relative to repo, with symlinks resolved in both, if
+// it is a file in the repo.
+func inRepo(repo, path string) (string, bool) {
+	realRepo, err := filepath.EvalSymlinks(repo)
+	if err != nil {
+		return "", false
+	}
+	realPath, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", false
+	}
+	rel, err := filepath.Rel(realRepo, realPath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return rel, true
+}
+
+// includePattern matches the source "<path>" and rendered "<dotfile>" calls
+// in a template action.
+var includePattern = regexp.MustCompile(`\b(source|rendered)\s+"([^"]+)"`)
+
+// actionPattern matches a template action, {{ ... }}.
+var actionPattern = regexp.MustCompile(`(?s)\{\{.*?\}\}`)
+
+// templateIncludes returns the repo paths the templates of cfg pull in with
+// source (a path in the repo) and rendered (the source of the named
+// dotfile). Single-file templates and every file of a template_dir are
+// scanned; files that can't be read are skipped.
+func templateIncludes(cfg *config.Config, repo string) []string {
+	var templates []string
+	addTemplate := func(df config.Dotfile) {
+		if df.Source == "" {
+			return
+		}
+		source := filepath.Join(repo, df.Source)
+		switch {
+		case df.Action == "template_dir":
+			filepath.WalkDir(source, func(path string, d fs.DirEntry, err error) error {
+				if err == nil && d.Type().IsRegular() {
+					templates = append(templates, path)
+				}
+				return nil
+			})
+		case df.IsTemplate:
+			templates = append(templates, source)
+		}
+	}
+	for _, df := range cfg.Dotfiles {
+		addTemplate(df)
+	}
+	for _, t := range cfg.Tools {
+		for _, cf := range t.ConfigFiles {
+			addTemplate(cf)
+		}
+	}
+
+	var paths []string
+	for _, path := range templates {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		for _, action := range actionPattern.FindAll(content, -1) {
+			for _, m := range includePattern.FindAllSubmatch(action, -1) {
+				switch arg := string(m[2]); string(m[1]) {
+				case "source":
+					paths = append(paths, arg)
+				case "rendered":
+					if df, ok := cfg.Dotfiles[arg]; ok {
+						paths = append(paths, df.Source)
+					}
+				}
+			}
+		}
+	}
+	return paths
+}
+
+// ignored reports whether rel matches one of the ignore patterns, as a whole
+// path or by its base name.
+func ignored(rel string, patterns []string) bool {
+	slashed := filepath.ToSlash(rel)
+	base := filepath.Base(rel)
+	for _, pattern := range patterns {
+		pattern = strings.TrimSuffix(pattern, "/")
+		if ok, _ := filepath.Match(pattern, slashed); ok {
+			return true
+		}
+		if ok, _ := filepath.Match(pattern, base); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,151 @@
+package orphans
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/mad01/ralph/internal/config"
+)
+
+func TestFind(t *testing.T) {
+	repo := t.TempDir()
+	for _, f := range []string{
+		".git/config",
+		".gitignore",
+		"README.md",
+		"zshrc",
+		"old/bashrc",
+		"nvim/init.lua",
+		"nvim/lua/plugins.lua",
+		"tools/starship.toml",
+		"bin/hello",
+		"recipes/git/recipe.toml",
+		"recipes/git/gitconfig",
+		"recipes/git/unused.conf",
+		"recipes/work/recipe.toml",
+		"recipes/work/npmrc",
+		"state/machines/laptop.toml",
+		"docs/setup.md",
+	} {
+		path := filepath.Join(repo, f)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		os.WriteFile(path, []byte("x\n"), 0644)
+	}
+
+	cfg := &config.Config{
+		DotfilesRepoPath: repo,
+		Dotfiles: map[string]config.Dotfile{
+			"zsh":       {Source: "zshrc", Target: "~/.zshrc"},
+			"nvim":      {Source: "nvim", Target: "~/.config/nvim", Action: "symlink_dir"},
+			"gitconfig": {Source: "recipes/git/gitconfig", Target: "~/.gitconfig"},
+		},
+		Tools: []config.Tool{{Name: "starship", ConfigFiles: []config.Dotfile{{Source: "tools/starship.toml", Target: "~/.config/starship.toml"}}}},
+		Bin:   config.BinConfig{Source: "bin"},
+		LoadedRecipes: []config.LoadedRecipeInfo{
+			{Path: "recipes/git/recipe.toml", Dir: "recipes/git", Name: "git"},
+		},
+		SkippedRecipes: []config.SkippedRecipe{{Ref: config.RecipeRef{Name: "work"}, Reason: "host filter"}},
+		Orphans:        config.OrphansConfig{Ignore: []string{"README.md", "docs/"}},
+	}
+
+	got, err := Find(cfg)
+	if err != nil {
+		t.Fatalf("Find() error = %v", err)
+	}
+	want := []string{"old/bashrc", "recipes/git/unused.conf"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Find() = %v, want %v", got, want)
+	}
+}
+
+func TestIgnored(t *testing.T) {
+	tests := []struct {
+		rel     string
+		pattern string
+		want    bool
+	}{
+		{"README.md", "README.md", true},
+		{"nvim/README.md", "README.md", true},
+		{"docs/setup.md", "docs/*", true},
+		{"docs", "docs/", true},
+		{"notes/todo.txt", "*.md", false},
+		{"notes/todo.md", "notes/*.md", true},
+	}
+	for _, tt := range tests {
+		if got := ignored(tt.rel, []string{tt.pattern}); got != tt.want {
+			t.Errorf("ignored(%q, %q) = %v, want %v", tt.rel, tt.pattern, got, tt.want)
+		}
+	}
+}
+
+// writeFiles writes a line to each of files under dir.
+func writeFiles(t *testing.T, dir string, files ...string) {
+	t.Helper()
+	for _, f := range files {
+		path := filepath.Join(dir, f)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("x\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestFind_ConfigInRepo(t *testing.T) {
+	repo := t.TempDir()
+	writeFiles(t, repo, "ralph/config.toml", "ralph/config.work-laptop.toml", "ralph/config.darwin.toml", "ralph/config.local.toml", "ralph/notes.txt")
+
+	// ~/.config/ralph is a link to the ralph directory in the repo
+	configDir := filepath.Join(t.TempDir(), "ralph")
+	if err := os.Symlink(filepath.Join(repo, "ralph"), configDir); err != nil {
+		t.Fatal(err)
+	}
+	orig := config.GetDefaultConfigPath
+	config.GetDefaultConfigPath = func() (string, error) { return filepath.Join(configDir, "config.toml"), nil }
+	t.Cleanup(func() { config.GetDefaultConfigPath = orig })
+
+	cfg := &config.Config{
+		DotfilesRepoPath: repo,
+		Overlays:         []string{filepath.Join(configDir, "config.darwin.toml")},
+	}
+	got, err := Find(cfg)
+	if err != nil {
+		t.Fatalf("Find() error = %v", err)
+	}
+	if want := []string{"ralph/notes.txt"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Find() = %v, want %v", got, want)
+	}
+}
+
+func TestFind_TemplateIncludes(t *testing.T) {
+	repo := t.TempDir()
+	writeFiles(t, repo, "snippets/aliases.sh", "snippets/unused.sh", "kitty/theme.conf", "gitconfig")
+	if err := os.WriteFile(filepath.Join(repo, "zshrc.tmpl"), []byte("# {{ .Host }}\n{{ source \"snippets/aliases.sh\" }}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(repo, "config"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(repo, "config", "include.tmpl"), []byte("{{- rendered \"git\" | trim }}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{
+		DotfilesRepoPath: repo,
+		Dotfiles: map[string]config.Dotfile{
+			"zsh":    {Source: "zshrc.tmpl", Target: "~/.zshrc", IsTemplate: true},
+			"config": {Source: "config", Target: "~/.config/app", Action: "template_dir"},
+			"git":    {Source: "gitconfig", Target: "~/.gitconfig"},
+		},
+	}
+	got, err := Find(cfg)
+	if err != nil {
+		t.Fatalf("Find() error = %v", err)
+	}
+	if want := []string{"kitty/theme.conf", "snippets/unused.sh"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Find() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,331 @@
+package plan
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mad01/ralph/internal/config"
+	"github.com/mad01/ralph/internal/dotfile"
+	"github.com/mad01/ralph/internal/hooks"
+	"github.com/mad01/ralph/internal/repo"
+)
+
+// Changes an operation makes.
+const (
+	ChangeNone   = "none"   // Already in sync
+	ChangeCreate = "create" // Target does not exist yet
+	ChangeUpdate = "update" // Target exists but differs
+	ChangeRun    = "run"    // Build runs
+	ChangeSkip   = "skip"   // Disabled or filtered out for this host
+)
+
+// KindHook is the Operation kind of a pre_apply or post_apply hook group.
+// Hooks are not named config items; an unnamed group is called after its
+// position, e.g. "pre_apply #1".
+const KindHook = "hook"
+
+// Operation is what apply intends to do with one config item.
+type Operation struct {
+	Kind   string `json:"kind"` // config.Kind* item kind, or KindHook
+	Name   string `json:"name"`
+	Change string `json:"change"`
+	Action string `json:"action,omitempty"` // Dotfile action, e.g. "symlink" or "copy"; "pre_apply" or "post_apply" for hooks
+	Source string `json:"source,omitempty"`
+	Target string `json:"target,omitempty"`
+	Before string `json:"before,omitempty"` // State of the target now
+	After  string `json:"after,omitempty"`  // State of the target after apply
+	Reason string `json:"reason,omitempty"` // Why the item is skipped
+	Error  string `json:"error,omitempty"`  // Why the state could not be determined
+
+	// Commands are what apply would execute for the item: a build's
+	// commands when it runs, a hook group's commands, and a dotfile's
+	// pre_link and post_link hooks
+	Commands []Command `json:"commands,omitempty"`
+}
+
+// Command is a command line apply would execute, as passed to the OS.
+// Commands inherit ralph's environment; ralph sets no variables of its own.
+type Command struct {
+	Argv []string `json:"argv"`           // Program and arguments, e.g. ["sh", "-c", "make install"]
+	Dir  string   `json:"dir,omitempty"`  // Expanded working directory; empty runs in ralph's
+	Hook string   `json:"hook,omitempty"` // "pre_link" or "post_link" for a dotfile's hooks
+}
+
+// Pending reports whether apply would change something for the operation.
+func (o Operation) Pending() bool {
+	return o.Change != ChangeNone && o.Change != ChangeSkip
+}
+
+// Options are the apply flags that change the plan.
+type Options struct {
+	ForceBuilds   bool   // --force: re-run "once" builds
+	SpecificBuild string // --build: run only this build
+}
+
+// Plan lists the operations apply would perform on this host, for
+// pre_apply hooks, directories, repos, dotfiles, builds and post_apply hooks
+// in the order apply processes them, sorted by name within each kind
+// (hooks keep their configured order).
+type Plan struct {
+	Host       string      `json:"host"`
+	Operations []Operation `json:"operations"`
+}
+
+// Build computes the plan for cfg on currentHost without changing anything.
+// Templates are rendered as in a dry run.
+func Build(cfg *config.Config, currentHost string, opts Options) *Plan {
+	p := &Plan{Host: currentHost, Operations: []Operation{}}
+	p.addHooks(cfg.Hooks.PreApply, hooks.PreApply)
+
+	for _, name := range sortedKeys(cfg.Directories) {
+		dir := cfg.Directories[name]
+		op := Operation{Kind: config.KindDirectory, Name: name, Target: dir.Target, After: "directory"}
+		if dir.Mode != "" {
+			op.After = "directory with mode " + dir.Mode
+		}
+		if p.skip(&op, dir.Enable, dir.Hosts) {
+			continue
+		}
+		op.Before = describeTarget(dir.Target)
+		inSync, err := dotfile.DirectoryInSync(dir)
+		p.add(op, inSync, err)
+	}
+
+	for _, name := range sortedKeys(cfg.Repos) {
+		rp := cfg.Repos[name]
+		op := Operation{Kind: config.KindRepo, Name: name, Source: rp.URL, Target: rp.Target, After: "clone of " + rp.URL}
+		if rp.Commit != "" {
+			op.After += " at " + rp.Commit
+		}
+		if p.skip(&op, rp.Enable, rp.Hosts) {
+			continue
+		}
+		op.Before = describeTarget(rp.Target)
+		pending := repo.PendingRepos(map[string]config.Repo{name: rp}, currentHost)
+		p.add(op, len(pending) == 0, nil)
+	}
+
+	for _, name := range sortedKeys(cfg.Dotfiles) {
+		df := cfg.Dotfiles[name]
+		action := df.Action
+		if action == "" {
+			action = dotfile.DefaultAction
+		}
+		source := df.Source
+		if df.URL != "" {
+			source = df.URL
+		}
+		op := Operation{Kind: config.KindDotfile, Name: name, Action: action, Source: source, Target: df.Target}
+		op.After = fmt.Sprintf("%s of %s", action, source)
+		if df.IsTemplate {
+			op.After = fmt.Sprintf("%s of rendered template %s", action, source)
+		}
+		if p.skip(&op, df.Enable, df.Hosts) {
+			continue
+		}
+		op.Before = describeTarget(df.Target)
+		linkContext := &hooks.HookContext{
+			DotfileName: name,
+			SourcePath:  filepath.Join(cfg.DotfilesRepoPath, df.Source),
+			TargetPath:  df.Target,
+			DryRun:      true,
+		}
+		for _, hook := range []struct {
+			hookType hooks.HookType
+			scripts  []string
+		}{{hooks.PreLink, cfg.Hooks.PreLink[name]}, {hooks.PostLink, cfg.Hooks.PostLink[name]}} {
+			for _, script := range hook.scripts {
+				op.Commands = append(op.Commands, Command{Argv: hooks.CommandLine(script, linkContext), Hook: string(hook.hookType)})
+			}
+		}
+		inSync, err := dotfile.InSync(df, cfg)
+		p.add(op, inSync, err)
+	}
+
+	buildOpts := hooks.BuildOptions{DryRun: true, Force: opts.ForceBuilds, SpecificBuild: opts.SpecificBuild}
+	for _, name := range sortedKeys(cfg.Hooks.Builds) {
+		build := cfg.Hooks.Builds[name]
+		if opts.SpecificBuild != "" && opts.SpecificBuild != name {
+			continue
+		}
+		op := Operation{Kind: config.KindBuild, Name: name, Target: build.WorkingDir, After: strings.Join(build.Commands, "; ")}
+		if p.skip(&op, build.Enable, build.Hosts) {
+			continue
+		}
+		op.Change = ChangeRun
+		if build.Run != "always" {
+			// "once" and "manual" builds run only when due
+			pending, err := hooks.PendingBuilds(map[string]config.Build{name: build}, currentHost, buildOpts)
+			if err != nil {
+				op.Error = err.Error()
+			} else if len(pending) == 0 {
+				op.Change = ChangeNone
+			}
+		}
+		if op.Change == ChangeRun {
+			dir, err := config.ExpandPath(build.WorkingDir)
+			if err != nil {
+				op.Error = err.Error()
+			}
+			for _, command := range build.Commands {
+				op.Commands = append(op.Commands, Command{Argv: hooks.BuildCommandLine(command), Dir: dir})
+			}
+		}
+		p.Operations = append(p.Operations, op)
+	}
+
+	p.addHooks(cfg.Hooks.PostApply, hooks.PostApply)
+	return p
+}
+
+// addHooks records a run operation for each of the pre_apply or post_apply
+// hook groups.
+func (p *Plan) addHooks(groups []config.HookGroup, hookType hooks.HookType) {
+	for i, g := range groups {
+		name := g.Name
+		if name == "" {
+			name = fmt.Sprintf("%s #%d", hookType, i+1)
+		}
+		op := Operation{Kind: KindHook, Name: name, Change: ChangeRun, Action: string(hookType), After: strings.Join(g.Commands, "; ")}
+		if g.Parallel {
+			op.After += " (in parallel)"
+		}
+		for _, script := range g.Commands {
+			op.Commands = append(op.Commands, Command{Argv: hooks.CommandLine(script, &hooks.HookContext{DryRun: true})})
+		}
+		p.Operations = append(p.Operations, op)
+	}
+}
+
+// skip records op as skipped if it is disabled or filtered out for the
+// plan's host, and reports whether it was.
+func (p *Plan) skip(op *Operation, enable *bool, hosts []string) bool {
+	switch {
+	case !config.IsEnabled(enable):
+		op.Reason = "disabled"
+	case !config.ShouldApplyForHost(hosts, p.Host):
+		op.Reason = "host filter"
+	default:
+		return false
+	}
+	op.Change = ChangeSkip
+	op.After = ""
+	p.Operations = append(p.Operations, *op)
+	return true
+}
+
+// add records op with the change that follows from whether it is in sync.
+func (p *Plan) add(op Operation, inSync bool, err error) {
+	if err != nil {
+		op.Error = err.Error()
+	}
+	op.Change = change(op.Before, inSync, err)
+	p.Operations = append(p.Operations, op)
+}
+
+// change returns the change applying an item makes to a target described as
+// before: an item whose state could not be determined is updated.
+func change(before string, inSync bool, err error) string {
+	switch {
+	case err != nil:
+		return ChangeUpdate
+	case inSync:
+		return ChangeNone
+	case before == "missing":
+		return ChangeCreate
+	default:
+		return ChangeUpdate
+	}
+}
+
+// DotfileChange returns the change applying df would make to its target:
+// ChangeCreate, ChangeUpdate or ChangeNone.
+func DotfileChange(df config.Dotfile, cfg *config.Config) string {
+	inSync, err := dotfile.InSync(df, cfg)
+	return change(describeTarget(df.Target), inSync, err)
+}
+
+// DirectoryChange returns the change applying dir would make, as for
+// DotfileChange.
+func DirectoryChange(dir config.Directory) string {
+	inSync, err := dotfile.DirectoryInSync(dir)
+	return change(describeTarget(dir.Target), inSync, err)
+}
+
+// Lookup returns the operation for the named item of kind.
+func (p *Plan) Lookup(kind, name string) (Operation, bool) {
+	for _, op := range p.Operations {
+		if op.Kind == kind && op.Name == name {
+			return op, true
+		}
+	}
+	return Operation{}, false
+}
+
+// Pending returns the operations that would change something.
+func (p *Plan) Pending() []Operation {
+	var pending []Operation
+	for _, op := range p.Operations {
+		if op.Pending() {
+			pending = append(pending, op)
+		}
+	}
+	return pending
+}
+
+// HasErrors reports whether the state of any item could not be determined.
+func (p *Plan) HasErrors() bool {
+	for _, op := range p.Operations {
+		if op.Error != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteJSON writes the plan as indented JSON.
+func (p *Plan) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(p)
+}
+
+// describeTarget describes what is at target now: "missing", "file",
+// "directory" or "symlink → <destination>".
+func describeTarget(target string) string {
+	path, err := config.ExpandPath(target)
+	if err != nil {
+		return "unknown"
+	}
+	info, err := os.Lstat(path)
+	switch {
+	case os.IsNotExist(err):
+		return "missing"
+	case err != nil:
+		return "unknown"
+	case dotfile.IsLink(info):
+		dest, err := os.Readlink(path)
+		if err != nil {
+			return "symlink"
+		}
+		return "symlink → " + filepath.Clean(dest)
+	case info.IsDir():
+		return "directory"
+	default:
+		return "file"
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
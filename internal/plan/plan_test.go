@@ -0,0 +1,178 @@
+package plan
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/mad01/ralph/internal/config"
+)
+
+func TestBuild(t *testing.T) {
+	repoDir := t.TempDir()
+	home := t.TempDir()
+	t.Setenv("RALPH_STATE_DIR", t.TempDir())
+	os.WriteFile(filepath.Join(repoDir, "zshrc"), []byte("# zsh\n"), 0644)
+	os.WriteFile(filepath.Join(repoDir, "vimrc"), []byte("\" vim\n"), 0644)
+	os.WriteFile(filepath.Join(repoDir, "gitconfig"), []byte("[user]\n"), 0644)
+	os.Symlink(filepath.Join(repoDir, "zshrc"), filepath.Join(home, ".zshrc"))
+	os.WriteFile(filepath.Join(home, ".vimrc"), []byte("old\n"), 0644)
+	os.MkdirAll(filepath.Join(home, "src"), 0755)
+	disabled := false
+
+	cfg := &config.Config{
+		DotfilesRepoPath: repoDir,
+		Dotfiles: map[string]config.Dotfile{
+			"zsh": {Source: "zshrc", Target: filepath.Join(home, ".zshrc")},
+			"vim": {Source: "vimrc", Target: filepath.Join(home, ".vimrc"), Action: "copy"},
+			"git": {Source: "gitconfig", Target: filepath.Join(home, ".gitconfig")},
+			"off": {Source: "gitconfig", Target: filepath.Join(home, ".off"), Enable: &disabled},
+		},
+		Directories: map[string]config.Directory{
+			"src":   {Target: filepath.Join(home, "src")},
+			"cache": {Target: filepath.Join(home, ".cache", "x")},
+		},
+		Hooks: config.HooksConfig{Builds: map[string]config.Build{
+			"always": {Commands: []string{"make", "make install"}, Run: "always"},
+			"manual": {Commands: []string{"make"}, Run: "manual"},
+			"remote": {Commands: []string{"make"}, Run: "once", Hosts: []string{"other"}},
+		}},
+	}
+
+	p := Build(cfg, "this-host", Options{})
+	want := []struct{ kind, name, change, before string }{
+		{config.KindDirectory, "cache", ChangeCreate, "missing"},
+		{config.KindDirectory, "src", ChangeNone, "directory"},
+		{config.KindDotfile, "git", ChangeCreate, "missing"},
+		{config.KindDotfile, "off", ChangeSkip, ""},
+		{config.KindDotfile, "vim", ChangeUpdate, "file"},
+		{config.KindDotfile, "zsh", ChangeNone, "symlink → " + filepath.Join(repoDir, "zshrc")},
+		{config.KindBuild, "always", ChangeRun, ""},
+		{config.KindBuild, "manual", ChangeNone, ""},
+		{config.KindBuild, "remote", ChangeSkip, ""},
+	}
+	if len(p.Operations) != len(want) {
+		t.Fatalf("got %d operations, want %d: %+v", len(p.Operations), len(want), p.Operations)
+	}
+	for i, w := range want {
+		op := p.Operations[i]
+		if op.Kind != w.kind || op.Name != w.name || op.Change != w.change || op.Before != w.before {
+			t.Errorf("operation %d = %+v, want %s %s change=%s before=%q", i, op, w.kind, w.name, w.change, w.before)
+		}
+	}
+
+	if op, _ := p.Lookup(config.KindDotfile, "vim"); op.Action != "copy" || op.After != "copy of vimrc" {
+		t.Errorf("vim operation = %+v, want a copy of vimrc", op)
+	}
+	if op, _ := p.Lookup(config.KindDotfile, "off"); op.Reason != "disabled" || op.After != "" {
+		t.Errorf("off operation = %+v, want skipped as disabled", op)
+	}
+	if op, _ := p.Lookup(config.KindBuild, "always"); op.After != "make; make install" {
+		t.Errorf("always build After = %q, want its commands", op.After)
+	}
+	if got := len(p.Pending()); got != 4 {
+		t.Errorf("len(Pending()) = %d, want 4", got)
+	}
+
+	// Requesting a manual build plans only that build, which then runs.
+	p = Build(cfg, "this-host", Options{SpecificBuild: "manual"})
+	if op, ok := p.Lookup(config.KindBuild, "manual"); !ok || op.Change != ChangeRun {
+		t.Errorf("requested manual build = %+v, want it to run", op)
+	}
+	if _, ok := p.Lookup(config.KindBuild, "always"); ok {
+		t.Error("other builds should not be planned when one is requested")
+	}
+}
+
+func TestBuild_Commands(t *testing.T) {
+	repoDir := t.TempDir()
+	home := t.TempDir()
+	t.Setenv("RALPH_STATE_DIR", t.TempDir())
+	os.WriteFile(filepath.Join(repoDir, "zshrc"), []byte("# zsh\n"), 0644)
+
+	cfg := &config.Config{
+		DotfilesRepoPath: repoDir,
+		Dotfiles:         map[string]config.Dotfile{"zsh": {Source: "zshrc", Target: filepath.Join(home, ".zshrc")}},
+		Hooks: config.HooksConfig{
+			PreApply:  []config.HookGroup{{Commands: []string{"echo start"}}},
+			PostApply: []config.HookGroup{{Name: "reload", Commands: []string{"tmux source ~/.tmux.conf"}, Parallel: true}},
+			PostLink:  map[string][]string{"zsh": {"touch {target}.done"}},
+			Builds: map[string]config.Build{
+				"tool": {Commands: []string{"make && make install"}, WorkingDir: filepath.Join(home, "src"), Run: "always"},
+			},
+		},
+	}
+
+	p := Build(cfg, "this-host", Options{})
+	if first := p.Operations[0]; first.Kind != KindHook || first.Name != "pre_apply #1" || first.Action != "pre_apply" {
+		t.Errorf("first operation = %+v, want the pre_apply hook", first)
+	}
+	last := p.Operations[len(p.Operations)-1]
+	if last.Kind != KindHook || last.Name != "reload" || last.After != "tmux source ~/.tmux.conf (in parallel)" {
+		t.Errorf("last operation = %+v, want the post_apply hook", last)
+	}
+	if want := []string{"tmux", "source", "~/.tmux.conf"}; !reflect.DeepEqual(last.Commands[0].Argv, want) {
+		t.Errorf("hook argv = %q, want %q", last.Commands[0].Argv, want)
+	}
+
+	zsh, _ := p.Lookup(config.KindDotfile, "zsh")
+	wantLink := []Command{{Argv: []string{"touch", filepath.Join(home, ".zshrc") + ".done"}, Hook: "post_link"}}
+	if !reflect.DeepEqual(zsh.Commands, wantLink) {
+		t.Errorf("zsh commands = %+v, want %+v", zsh.Commands, wantLink)
+	}
+
+	build, _ := p.Lookup(config.KindBuild, "tool")
+	wantBuild := []Command{{Argv: []string{"sh", "-c", "make && make install"}, Dir: filepath.Join(home, "src")}}
+	if !reflect.DeepEqual(build.Commands, wantBuild) {
+		t.Errorf("build commands = %+v, want %+v", build.Commands, wantBuild)
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	p := &Plan{Host: "h", Operations: []Operation{{Kind: config.KindDotfile, Name: "zsh", Change: ChangeCreate, Before: "missing"}}}
+	var buf bytes.Buffer
+	if err := p.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("invalid JSON %q: %v", buf.String(), err)
+	}
+	ops := decoded["operations"].([]interface{})
+	op := ops[0].(map[string]interface{})
+	if op["change"] != "create" || op["before"] != "missing" {
+		t.Errorf("operation = %v", op)
+	}
+	if _, ok := op["error"]; ok {
+		t.Error("empty fields should be omitted")
+	}
+}
+
+func TestDotfileChange(t *testing.T) {
+	repoDir := t.TempDir()
+	home := t.TempDir()
+	os.WriteFile(filepath.Join(repoDir, "zshrc"), []byte("# zsh\n"), 0644)
+	cfg := &config.Config{DotfilesRepoPath: repoDir}
+	df := config.Dotfile{Source: "zshrc", Target: filepath.Join(home, ".zshrc")}
+
+	if got := DotfileChange(df, cfg); got != ChangeCreate {
+		t.Errorf("missing target: DotfileChange() = %q, want %q", got, ChangeCreate)
+	}
+	os.WriteFile(df.Target, []byte("old\n"), 0644)
+	if got := DotfileChange(df, cfg); got != ChangeUpdate {
+		t.Errorf("file target: DotfileChange() = %q, want %q", got, ChangeUpdate)
+	}
+	os.Remove(df.Target)
+	os.Symlink(filepath.Join(repoDir, "zshrc"), df.Target)
+	if got := DotfileChange(df, cfg); got != ChangeNone {
+		t.Errorf("linked target: DotfileChange() = %q, want %q", got, ChangeNone)
+	}
+
+	dir := config.Directory{Target: filepath.Join(home, "src")}
+	if got := DirectoryChange(dir); got != ChangeCreate {
+		t.Errorf("DirectoryChange() = %q, want %q", got, ChangeCreate)
+	}
+}
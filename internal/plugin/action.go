@@ -0,0 +1,136 @@
+package plugin
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/mad01/ralph/internal/config"
+	"github.com/mad01/ralph/internal/dotfile"
+)
+
+// ActionPrefix marks plugins that provide a dotfile action instead of a
+// subcommand: ralph-action-foo deploys dotfiles with action = "foo".
+const ActionPrefix = "action-"
+
+// ActionName returns the dotfile action p provides, if it is an action plugin.
+func ActionName(p Plugin) (string, bool) {
+	if !strings.HasPrefix(p.Name, ActionPrefix) {
+		return "", false
+	}
+	name := strings.TrimPrefix(p.Name, ActionPrefix)
+	return name, name != ""
+}
+
+// ActionRequest is the JSON document written to an action plugin's stdin.
+type ActionRequest struct {
+	Context
+	Name      string            `json:"name,omitempty"`      // Dotfile name (apply only)
+	Source    string            `json:"source"`              // Absolute source path
+	Target    string            `json:"target"`              // Absolute target path
+	DryRun    bool              `json:"dry_run,omitempty"`   // apply only: print, don't change anything
+	Checksums map[string]string `json:"checksums,omitempty"` // verify only: what apply recorded
+}
+
+// Action is a dotfile.Action implemented by a ralph-action-<name> plugin,
+// which is run with the step as its argument:
+//
+//	plan    exit 0 when the target is in sync, 1 when apply would change it
+//	apply   deploy the dotfile (or, with dry_run, describe it) and exit 0
+//	verify  exit 0 when nothing drifted, or print one drifted path per line
+//	        and exit 1
+//
+// Any other exit code is an error.
+type Action struct {
+	Plugin  Plugin
+	Version string // ralph version, passed on in the context
+}
+
+// Plan implements dotfile.Action.
+func (a Action) Plan(df config.Dotfile, cfg *config.Config) (bool, error) {
+	req, err := a.request(df, cfg.DotfilesRepoPath, cfg)
+	if err != nil {
+		return false, err
+	}
+	code, err := run(a.Plugin, []string{"plan"}, req.Context, req, io.Discard)
+	switch {
+	case err != nil:
+		return false, err
+	case code == 0:
+		return true, nil
+	case code == 1:
+		return false, nil
+	}
+	return false, a.exitError("plan", code)
+}
+
+// Apply implements dotfile.Action.
+func (a Action) Apply(w io.Writer, applyReq dotfile.ApplyRequest) error {
+	req, err := a.request(applyReq.Dotfile, applyReq.RepoPath, applyReq.Config)
+	if err != nil {
+		return err
+	}
+	req.Name = applyReq.Name
+	req.DryRun = applyReq.DryRun
+	code, err := run(a.Plugin, []string{"apply"}, req.Context, req, w)
+	if err != nil {
+		return err
+	}
+	if code != 0 {
+		return a.exitError("apply", code)
+	}
+	return nil
+}
+
+// Verify implements dotfile.Action. Every path the plugin prints is reported
+// as modified.
+func (a Action) Verify(entry dotfile.ManifestEntry) ([]dotfile.Drift, error) {
+	req := ActionRequest{
+		Context:   NewContext(a.Version, nil),
+		Source:    entry.Source,
+		Target:    entry.Target,
+		Checksums: entry.Checksums,
+	}
+	var out bytes.Buffer
+	code, err := run(a.Plugin, []string{"verify"}, req.Context, req, &out)
+	switch {
+	case err != nil:
+		return nil, err
+	case code == 0:
+		return nil, nil
+	case code != 1:
+		return nil, a.exitError("verify", code)
+	}
+	var drifts []dotfile.Drift
+	scanner := bufio.NewScanner(&out)
+	for scanner.Scan() {
+		if path := strings.TrimSpace(scanner.Text()); path != "" {
+			drifts = append(drifts, dotfile.Drift{Path: path, Problem: dotfile.DriftModified})
+		}
+	}
+	if len(drifts) == 0 {
+		drifts = append(drifts, dotfile.Drift{Path: entry.Target, Problem: dotfile.DriftModified})
+	}
+	return drifts, nil
+}
+
+// request builds the ActionRequest for df, whose source is relative to
+// repoPath.
+func (a Action) request(df config.Dotfile, repoPath string, cfg *config.Config) (ActionRequest, error) {
+	source, err := config.ExpandPath(filepath.Join(repoPath, df.Source))
+	if err != nil {
+		return ActionRequest{}, fmt.Errorf("failed to expand source path '%s': %w", df.Source, err)
+	}
+	target, err := config.ExpandPath(df.Target)
+	if err != nil {
+		return ActionRequest{}, fmt.Errorf("failed to expand target path '%s': %w", df.Target, err)
+	}
+	return ActionRequest{Context: NewContext(a.Version, cfg), Source: source, Target: target}, nil
+}
+
+func (a Action) exitError(step string, code int) error {
+	return fmt.Errorf("action plugin '%s' %s exited with status %d", config.ShortenHome(a.Plugin.Path), step, code)
+}
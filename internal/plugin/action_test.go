@@ -0,0 +1,78 @@
+package plugin
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+
+	"github.com/mad01/ralph/internal/config"
+	"github.com/mad01/ralph/internal/dotfile"
+)
+
+func TestActionName(t *testing.T) {
+	if name, ok := ActionName(Plugin{Name: "action-fetch"}); !ok || name != "fetch" {
+		t.Errorf("ActionName(action-fetch) = (%q, %v), want (fetch, true)", name, ok)
+	}
+	for _, p := range []string{"backup", "action-"} {
+		if _, ok := ActionName(Plugin{Name: p}); ok {
+			t.Errorf("%s should not be an action plugin", p)
+		}
+	}
+}
+
+func TestAction(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script plugin")
+	}
+	dir := t.TempDir()
+	stdin := filepath.Join(dir, "stdin")
+	target := filepath.Join(dir, "target")
+	path := filepath.Join(dir, "ralph-action-touch")
+	// plan: in sync once the target exists. apply: create it. verify: report
+	// the target as drifted.
+	writeExecutable(t, path, `#!/bin/sh
+cat > `+stdin+`
+case "$1" in
+plan) test -e `+target+` && exit 0; exit 1 ;;
+apply) touch `+target+`; echo "touched" ;;
+verify) echo `+target+`; exit 1 ;;
+*) exit 7 ;;
+esac
+`)
+	action := Action{Plugin: Plugin{Name: "action-touch", Path: path}, Version: "v1"}
+	cfg := &config.Config{DotfilesRepoPath: dir}
+	df := config.Dotfile{Source: "src", Target: target, Action: "touch"}
+
+	if ok, err := action.Plan(df, cfg); err != nil || ok {
+		t.Fatalf("Plan before apply = (%v, %v), want (false, nil)", ok, err)
+	}
+
+	var out bytes.Buffer
+	if err := action.Apply(&out, dotfile.ApplyRequest{Name: "thing", Dotfile: df, RepoPath: dir, Config: cfg, DryRun: true}); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if out.String() != "touched\n" {
+		t.Errorf("Apply output = %q, want the plugin's output", out.String())
+	}
+	data, _ := os.ReadFile(stdin)
+	var req ActionRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		t.Fatalf("plugin stdin was not JSON: %v\n%s", err, data)
+	}
+	if req.Name != "thing" || req.Source != filepath.Join(dir, "src") || req.Target != target || !req.DryRun || req.Version != "v1" {
+		t.Errorf("request = %+v, want name, absolute paths, dry_run and version", req)
+	}
+
+	if ok, err := action.Plan(df, cfg); err != nil || !ok {
+		t.Errorf("Plan after apply = (%v, %v), want (true, nil)", ok, err)
+	}
+
+	drifts, err := action.Verify(dotfile.ManifestEntry{Action: "touch", Target: target})
+	if want := []dotfile.Drift{{Path: target, Problem: dotfile.DriftModified}}; err != nil || !reflect.DeepEqual(drifts, want) {
+		t.Errorf("Verify = (%v, %v), want (%v, nil)", drifts, err, want)
+	}
+}
@@ -0,0 +1,138 @@
+package plugin
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/mad01/ralph/internal/config"
+)
+
+// Prefix is the executable name prefix that makes a plugin: ralph-foo on
+// PATH becomes 'ralph foo'.
+const Prefix = "ralph-"
+
+// Plugin is an external subcommand found on PATH.
+type Plugin struct {
+	Name string // Subcommand name, e.g. "foo" for ralph-foo
+	Path string // Absolute path of the executable
+}
+
+// Context is the JSON document written to a plugin's stdin.
+type Context struct {
+	Version          string `json:"version"`                      // ralph version
+	ConfigPath       string `json:"config_path"`                  // Path of config.toml (may not exist)
+	DotfilesRepoPath string `json:"dotfiles_repo_path,omitempty"` // Expanded dotfiles_repo_path, if the config loaded
+	StateDir         string `json:"state_dir,omitempty"`          // ralph's state directory
+	Host             string `json:"host"`                         // Current hostname, as used for host filters
+}
+
+// NewContext describes this ralph setup to a plugin. cfg may be nil, as
+// plugins also run without a valid config; fields that need one are left
+// empty then.
+func NewContext(version string, cfg *config.Config) Context {
+	ctx := Context{Version: version, Host: config.GetCurrentHost()}
+	ctx.ConfigPath, _ = config.GetDefaultConfigPath()
+	if cfg != nil {
+		ctx.DotfilesRepoPath, _ = config.ExpandPath(cfg.DotfilesRepoPath)
+	}
+	ctx.StateDir, _ = config.GetStateDir()
+	return ctx
+}
+
+// Discover returns the plugins on pathEnv (a PATH-style list), sorted by
+// name. As with command lookup, the first directory that has a plugin name
+// wins. Files that are not executable are ignored.
+func Discover(pathEnv string) []Plugin {
+	seen := make(map[string]bool)
+	var plugins []Plugin
+	for _, dir := range filepath.SplitList(pathEnv) {
+		if dir == "" {
+			continue
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			name, ok := pluginName(entry.Name())
+			if !ok || seen[name] {
+				continue
+			}
+			path := filepath.Join(dir, entry.Name())
+			if !isExecutable(path) {
+				continue
+			}
+			seen[name] = true
+			plugins = append(plugins, Plugin{Name: name, Path: path})
+		}
+	}
+	sort.Slice(plugins, func(i, j int) bool { return plugins[i].Name < plugins[j].Name })
+	return plugins
+}
+
+// pluginName returns the subcommand name for an executable file name.
+func pluginName(file string) (string, bool) {
+	if !strings.HasPrefix(file, Prefix) {
+		return "", false
+	}
+	name := strings.TrimPrefix(file, Prefix)
+	if runtime.GOOS == "windows" {
+		name = strings.TrimSuffix(name, filepath.Ext(name))
+	}
+	return name, name != ""
+}
+
+// isExecutable reports whether path is a regular file that can be executed.
+func isExecutable(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return false
+	}
+	if runtime.GOOS == "windows" {
+		return strings.EqualFold(filepath.Ext(path), ".exe")
+	}
+	return info.Mode().Perm()&0111 != 0
+}
+
+// Run executes p with args, writing ctx as JSON to its stdin and passing
+// RALPH_CONFIG and RALPH_VERSION in the environment. Its output goes
+// straight to ralph's. It returns the plugin's exit code; the error is only
+// set when the plugin could not be started.
+func Run(p Plugin, args []string, ctx Context) (int, error) {
+	return run(p, args, ctx, ctx, os.Stdout)
+}
+
+// run executes p with args, writing input as JSON to its stdin and its
+// output to stdout. Stderr goes to ralph's. Like Run, it returns the exit
+// code and only fails when the plugin could not be started.
+func run(p Plugin, args []string, ctx Context, input interface{}, stdout io.Writer) (int, error) {
+	data, err := json.Marshal(input)
+	if err != nil {
+		return 1, fmt.Errorf("failed to encode plugin context: %w", err)
+	}
+
+	cmd := exec.Command(p.Path, args...)
+	cmd.Stdin = bytes.NewReader(append(data, '\n'))
+	cmd.Stdout = stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(), "RALPH_CONFIG="+ctx.ConfigPath, "RALPH_VERSION="+ctx.Version)
+
+	err = cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), nil
+	}
+	if err != nil {
+		return 1, fmt.Errorf("failed to run plugin '%s': %w", p.Path, err)
+	}
+	return 0, nil
+}
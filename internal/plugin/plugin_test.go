@@ -0,0 +1,69 @@
+package plugin
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func writeExecutable(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("plugins on Windows are .exe files")
+	}
+	first, second := t.TempDir(), t.TempDir()
+	writeExecutable(t, filepath.Join(first, "ralph-backup"), "#!/bin/sh\n")
+	writeExecutable(t, filepath.Join(second, "ralph-backup"), "#!/bin/sh\n")
+	writeExecutable(t, filepath.Join(second, "ralph-audit"), "#!/bin/sh\n")
+	writeExecutable(t, filepath.Join(second, "other-tool"), "#!/bin/sh\n")
+	os.WriteFile(filepath.Join(second, "ralph-notes"), []byte("not executable"), 0644)
+	os.Mkdir(filepath.Join(second, "ralph-dir"), 0755)
+
+	got := Discover(first + string(os.PathListSeparator) + second + string(os.PathListSeparator) + "/does/not/exist")
+	want := []Plugin{
+		{Name: "audit", Path: filepath.Join(second, "ralph-audit")},
+		{Name: "backup", Path: filepath.Join(first, "ralph-backup")},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Discover = %v, want %v", got, want)
+	}
+}
+
+func TestRun(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script plugin")
+	}
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out")
+	path := filepath.Join(dir, "ralph-echo")
+	writeExecutable(t, path, "#!/bin/sh\ncat > "+out+"\necho \"$RALPH_CONFIG $1\" >> "+out+"\nexit 3\n")
+
+	ctx := Context{Version: "v1", ConfigPath: "/cfg/config.toml", Host: "laptop"}
+	code, err := Run(Plugin{Name: "echo", Path: path}, []string{"arg"}, ctx)
+	if err != nil || code != 3 {
+		t.Fatalf("Run = (%d, %v), want (3, nil)", code, err)
+	}
+
+	data, _ := os.ReadFile(out)
+	lines := strings.SplitN(string(data), "\n", 2)
+	var got Context
+	if err := json.Unmarshal([]byte(lines[0]), &got); err != nil {
+		t.Fatalf("plugin stdin was not JSON: %v\n%s", err, data)
+	}
+	if got != ctx {
+		t.Errorf("context = %+v, want %+v", got, ctx)
+	}
+	if len(lines) != 2 || lines[1] != "/cfg/config.toml arg\n" {
+		t.Errorf("plugin output = %q, want RALPH_CONFIG and the argument", data)
+	}
+}
@@ -0,0 +1,99 @@
+package progress
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// frames are the animation frames drawn by the spinner.
+var frames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+// tickInterval is how often the spinner redraws its line.
+const tickInterval = 100 * time.Millisecond
+
+// Spinner renders a single-line activity indicator while a long phase runs.
+// A disabled spinner is a no-op, so callers can use it unconditionally.
+type Spinner struct {
+	w       io.Writer
+	enabled bool
+
+	mu      sync.Mutex
+	msg     string
+	running bool
+	done    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// New returns a spinner writing to w. If enabled is false, all methods are no-ops.
+func New(w io.Writer, enabled bool) *Spinner {
+	return &Spinner{w: w, enabled: enabled}
+}
+
+// Start begins animating the spinner with the given message.
+// Calling Start on a running spinner only updates the message.
+func (s *Spinner) Start(msg string) {
+	if !s.enabled {
+		return
+	}
+	s.mu.Lock()
+	s.msg = msg
+	if s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = true
+	s.done = make(chan struct{})
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go s.loop()
+}
+
+// Update changes the message shown next to the spinner.
+func (s *Spinner) Update(msg string) {
+	if !s.enabled {
+		return
+	}
+	s.mu.Lock()
+	s.msg = msg
+	s.mu.Unlock()
+}
+
+// Stop halts the animation and clears the spinner line.
+func (s *Spinner) Stop() {
+	if !s.enabled {
+		return
+	}
+	s.mu.Lock()
+	if !s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = false
+	close(s.done)
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	fmt.Fprint(s.w, "\r\033[K")
+}
+
+func (s *Spinner) loop() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(tickInterval)
+	defer ticker.Stop()
+
+	for i := 0; ; i++ {
+		s.mu.Lock()
+		msg := s.msg
+		s.mu.Unlock()
+		fmt.Fprintf(s.w, "\r\033[K%s %s", frames[i%len(frames)], msg)
+
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package progress
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// syncBuffer is a bytes.Buffer safe for concurrent use by the spinner goroutine.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestSpinner_Disabled(t *testing.T) {
+	var buf syncBuffer
+	s := New(&buf, false)
+	s.Start("cloning")
+	s.Update("still cloning")
+	s.Stop()
+
+	if buf.String() != "" {
+		t.Errorf("Disabled spinner wrote output: %q", buf.String())
+	}
+}
+
+func TestSpinner_Enabled(t *testing.T) {
+	var buf syncBuffer
+	s := New(&buf, true)
+	s.Start("Repositories")
+	s.Stop()
+
+	output := buf.String()
+	if !strings.Contains(output, "Repositories") {
+		t.Errorf("Expected spinner output to contain message, got: %q", output)
+	}
+	if !strings.HasSuffix(output, "\r\033[K") {
+		t.Errorf("Expected spinner line to be cleared on Stop, got: %q", output)
+	}
+}
+
+func TestSpinner_StopWithoutStart(t *testing.T) {
+	var buf syncBuffer
+	s := New(&buf, true)
+	s.Stop() // must not panic or block
+
+	if buf.String() != "" {
+		t.Errorf("Stop without Start wrote output: %q", buf.String())
+	}
+}
@@ -0,0 +1,114 @@
+package repo
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/mad01/ralph/internal/config"
+)
+
+// CommitMessageData is the data available to git.message_template.
+type CommitMessageData struct {
+	Host  string   // Current hostname
+	Date  string   // Date of the commit, YYYY-MM-DD
+	Files []string // Changed paths, relative to the repository root
+}
+
+// CommitChanges commits every change in the dotfiles repository at repoPath
+// and, if gitCfg.AutoPush is set, pushes the result. It reports whether a
+// commit was made; a clean working tree is not an error.
+// If dryRun is true, it will only print the actions it would take.
+// Cancelling ctx kills the running git command.
+func CommitChanges(ctx context.Context, w io.Writer, repoPath string, gitCfg config.GitConfig, dryRun bool) (bool, error) {
+	absoluteRepo, err := config.ExpandPath(repoPath)
+	if err != nil {
+		return false, fmt.Errorf("failed to expand repo path '%s': %w", repoPath, err)
+	}
+
+	status, err := gitOutput(ctx, absoluteRepo, "status", "--porcelain")
+	if err != nil {
+		return false, err
+	}
+	var files []string
+	for _, line := range strings.Split(status, "\n") {
+		if len(line) > 3 {
+			files = append(files, line[3:])
+		}
+	}
+	if len(files) == 0 {
+		fmt.Fprintf(w, "  No changes to commit in '%s'.\n", absoluteRepo)
+		return false, nil
+	}
+
+	message, err := commitMessage(gitCfg.MessageTemplate, CommitMessageData{
+		Host:  config.GetCurrentHost(),
+		Date:  time.Now().Format("2006-01-02"),
+		Files: files,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	if dryRun {
+		fmt.Fprintf(w, "[DRY RUN] Would commit %d changed file(s) in '%s': %s\n", len(files), absoluteRepo, message)
+		if gitCfg.AutoPush {
+			fmt.Fprintf(w, "[DRY RUN] Would push '%s'\n", absoluteRepo)
+		}
+		return true, nil
+	}
+
+	if _, err := gitOutput(ctx, absoluteRepo, "add", "-A"); err != nil {
+		return false, err
+	}
+	if _, err := gitOutput(ctx, absoluteRepo, "commit", "-m", message); err != nil {
+		return false, err
+	}
+	fmt.Fprintf(w, "  Committed %d changed file(s): %s\n", len(files), message)
+
+	if gitCfg.AutoPush {
+		if _, err := gitOutput(ctx, absoluteRepo, "push"); err != nil {
+			return true, err
+		}
+		fmt.Fprintf(w, "  Pushed '%s'.\n", absoluteRepo)
+	}
+	return true, nil
+}
+
+// commitMessage renders the commit message template, falling back to
+// config.DefaultCommitMessageTemplate when tmpl is empty.
+func commitMessage(tmpl string, data CommitMessageData) (string, error) {
+	if tmpl == "" {
+		tmpl = config.DefaultCommitMessageTemplate
+	}
+	t, err := template.New("message_template").Parse(tmpl)
+	if err != nil {
+		return "", fmt.Errorf("invalid git.message_template: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render git.message_template: %w", err)
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// gitOutput runs git in dir and returns its trimmed stdout. Failures include
+// git's stderr so the cause (conflicts, missing upstream) is visible.
+func gitOutput(ctx context.Context, dir string, args ...string) (string, error) {
+	cmd := gitCommand(ctx, args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil && ctx.Err() != nil {
+		return "", fmt.Errorf("git %s: %w", strings.Join(args, " "), ctx.Err())
+	}
+	if err != nil {
+		return "", fmt.Errorf("git %s failed: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimRight(string(out), "\n"), nil
+}
@@ -0,0 +1,93 @@
+package repo
+
+import (
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/mad01/ralph/internal/config"
+)
+
+// initTestRepo creates a git repository with one committed file.
+func initTestRepo(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	runGit(t, dir, "init", "-q", "-b", "main")
+	runGit(t, dir, "config", "user.email", "test@example.com")
+	runGit(t, dir, "config", "user.name", "Test")
+	if err := os.WriteFile(filepath.Join(dir, "zshrc"), []byte("one\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, dir, "add", "-A")
+	runGit(t, dir, "commit", "-q", "-m", "initial")
+	return dir
+}
+
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v failed: %v: %s", args, err, out)
+	}
+	return string(out)
+}
+
+func TestCommitChanges(t *testing.T) {
+	dir := initTestRepo(t)
+	gitCfg := config.GitConfig{AutoCommit: true, MessageTemplate: "update {{ len .Files }} file(s): {{ index .Files 0 }}"}
+
+	committed, err := CommitChanges(context.Background(), io.Discard, dir, gitCfg, false)
+	if err != nil || committed {
+		t.Fatalf("clean tree = (%v, %v), want (false, nil)", committed, err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "zshrc"), []byte("two\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if committed, err := CommitChanges(context.Background(), io.Discard, dir, gitCfg, true); err != nil || !committed {
+		t.Fatalf("dry run = (%v, %v), want (true, nil)", committed, err)
+	}
+	if status := runGit(t, dir, "status", "--porcelain"); status == "" {
+		t.Fatal("dry run committed the change")
+	}
+
+	if committed, err := CommitChanges(context.Background(), io.Discard, dir, gitCfg, false); err != nil || !committed {
+		t.Fatalf("CommitChanges = (%v, %v), want (true, nil)", committed, err)
+	}
+	if got := runGit(t, dir, "log", "-1", "--format=%s"); got != "update 1 file(s): zshrc\n" {
+		t.Errorf("commit subject = %q", got)
+	}
+}
+
+func TestCommitChanges_Push(t *testing.T) {
+	dir := initTestRepo(t)
+	remote := t.TempDir()
+	runGit(t, remote, "init", "-q", "--bare")
+	runGit(t, dir, "remote", "add", "origin", remote)
+	runGit(t, dir, "push", "-q", "-u", "origin", "main")
+
+	if err := os.WriteFile(filepath.Join(dir, "gitconfig"), []byte("new\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := CommitChanges(context.Background(), io.Discard, dir, config.GitConfig{AutoCommit: true, AutoPush: true}, false); err != nil {
+		t.Fatalf("CommitChanges failed: %v", err)
+	}
+	if local, pushed := runGit(t, dir, "rev-parse", "HEAD"), runGit(t, remote, "rev-parse", "main"); local != pushed {
+		t.Errorf("remote main = %s, want %s", pushed, local)
+	}
+}
+
+func TestCommitMessage_Default(t *testing.T) {
+	got, err := commitMessage("", CommitMessageData{Host: "laptop"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "ralph: update dotfiles from laptop" {
+		t.Errorf("commitMessage() = %q", got)
+	}
+}
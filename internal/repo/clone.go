@@ -1,10 +1,15 @@
 package repo
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/mad01/ralph/internal/config"
 )
@@ -16,10 +21,18 @@ import (
 // - If target exists and update=true: pull latest
 // - Otherwise: skip
 // If dryRun is true, it will only print the actions it would take.
-func CloneOrUpdateRepo(w io.Writer, name string, repo config.Repo, dryRun bool) error {
+// Cancelling ctx kills the running git command.
+func CloneOrUpdateRepo(ctx context.Context, w io.Writer, name string, repo config.Repo, dryRun bool) error {
+	_, err := cloneOrUpdateRepo(ctx, w, name, repo, dryRun, nil)
+	return err
+}
+
+// cloneOrUpdateRepo is CloneOrUpdateRepo reporting clone progress to
+// progress (if set). It returns whether the repo was cloned.
+func cloneOrUpdateRepo(ctx context.Context, w io.Writer, name string, repo config.Repo, dryRun bool, progress Progress) (bool, error) {
 	absoluteTarget, err := config.ExpandPath(repo.Target)
 	if err != nil {
-		return fmt.Errorf("failed to expand target path '%s': %w", repo.Target, err)
+		return false, fmt.Errorf("failed to expand target path '%s': %w", repo.Target, err)
 	}
 
 	// Check if target directory exists
@@ -28,28 +41,40 @@ func CloneOrUpdateRepo(w io.Writer, name string, repo config.Repo, dryRun bool)
 
 	if !targetExists {
 		// Clone the repository
-		return cloneRepo(w, repo, absoluteTarget, dryRun)
+		if err := cloneRepo(ctx, w, name, repo, absoluteTarget, dryRun, progress); err != nil {
+			return false, err
+		}
+		return !dryRun, nil
 	}
 
 	// Target exists - check what action to take
+	if repo.Commit != "" || repo.Update {
+		if err := syncRemoteURL(ctx, w, repo, absoluteTarget, dryRun); err != nil {
+			return false, err
+		}
+	}
 	if repo.Commit != "" {
 		// Pin to specific commit - fetch and checkout
-		return checkoutCommit(w, repo, absoluteTarget, dryRun)
+		return false, checkoutCommit(ctx, w, repo, absoluteTarget, dryRun)
 	}
 
 	if repo.Update {
 		// Pull latest
-		return pullRepo(w, name, absoluteTarget, dryRun)
+		return false, pullRepo(ctx, w, name, absoluteTarget, dryRun)
 	}
 
 	// No update or commit specified - skip
 	fmt.Fprintf(w, "Repo '%s' already exists at '%s'. Skipping.\n", name, absoluteTarget)
-	return nil
+	return false, nil
 }
 
-// cloneRepo clones a git repository to the target path.
-func cloneRepo(w io.Writer, repo config.Repo, absoluteTarget string, dryRun bool) error {
+// cloneRepo clones a git repository to the target path. With progress set,
+// git's progress goes to it instead of the terminal.
+func cloneRepo(ctx context.Context, w io.Writer, name string, repo config.Repo, absoluteTarget string, dryRun bool, progress Progress) error {
 	args := []string{"clone"}
+	if progress != nil && !dryRun {
+		args = append(args, "--progress")
+	}
 
 	if repo.Branch != "" {
 		args = append(args, "-b", repo.Branch)
@@ -66,21 +91,26 @@ func cloneRepo(w io.Writer, repo config.Repo, absoluteTarget string, dryRun bool
 	}
 
 	fmt.Fprintf(w, "Cloning: git %v\n", args)
-	cmd := exec.Command("git", args...)
+	cmd := gitCommand(ctx, args...)
 	cmd.Stdout = w
 	cmd.Stderr = os.Stderr
-	if err := cmd.Run(); err != nil {
+	if progress != nil {
+		pw := &progressWriter{name: name, report: progress, out: os.Stderr}
+		defer pw.Flush()
+		cmd.Stderr = pw
+	}
+	if err := runCommand(ctx, cmd); err != nil {
 		return fmt.Errorf("failed to clone repository: %w", err)
 	}
 
 	// If commit is specified, checkout that commit after cloning
 	if repo.Commit != "" {
 		fmt.Fprintf(w, "Checking out commit: %s\n", repo.Commit)
-		checkoutCmd := exec.Command("git", "checkout", repo.Commit)
+		checkoutCmd := gitCommand(ctx, "checkout", repo.Commit)
 		checkoutCmd.Dir = absoluteTarget
 		checkoutCmd.Stdout = w
 		checkoutCmd.Stderr = os.Stderr
-		if err := checkoutCmd.Run(); err != nil {
+		if err := runCommand(ctx, checkoutCmd); err != nil {
 			return fmt.Errorf("failed to checkout commit %s: %w", repo.Commit, err)
 		}
 	}
@@ -88,28 +118,55 @@ func cloneRepo(w io.Writer, repo config.Repo, absoluteTarget string, dryRun bool
 	return nil
 }
 
+// syncRemoteURL points origin at repo.URL before an update when it names
+// the same repository in another form, so a change of [git] url_rewrites or
+// prefer reaches existing clones. A remote set to a different repository is
+// left alone.
+func syncRemoteURL(ctx context.Context, w io.Writer, repo config.Repo, absoluteTarget string, dryRun bool) error {
+	out, err := gitCommand(ctx, "-C", absoluteTarget, "remote", "get-url", "origin").Output()
+	if err != nil {
+		return nil // No origin: the fetch or pull will report it
+	}
+	current := strings.TrimSpace(string(out))
+	if current == repo.URL || !config.SameRepoURL(current, repo.URL) {
+		return nil
+	}
+	if dryRun {
+		fmt.Fprintf(w, "[DRY RUN] Would set origin of '%s' to %s (was %s)\n", absoluteTarget, repo.URL, current)
+		return nil
+	}
+	fmt.Fprintf(w, "Setting origin of '%s' to %s (was %s)\n", absoluteTarget, repo.URL, current)
+	cmd := gitCommand(ctx, "-C", absoluteTarget, "remote", "set-url", "origin", repo.URL)
+	cmd.Stdout = w
+	cmd.Stderr = os.Stderr
+	if err := runCommand(ctx, cmd); err != nil {
+		return fmt.Errorf("failed to set origin URL: %w", err)
+	}
+	return nil
+}
+
 // checkoutCommit fetches and checks out a specific commit.
-func checkoutCommit(w io.Writer, repo config.Repo, absoluteTarget string, dryRun bool) error {
+func checkoutCommit(ctx context.Context, w io.Writer, repo config.Repo, absoluteTarget string, dryRun bool) error {
 	if dryRun {
 		fmt.Fprintf(w, "[DRY RUN] Would fetch and checkout commit %s in '%s'\n", repo.Commit, absoluteTarget)
 		return nil
 	}
 
 	fmt.Fprintf(w, "Fetching in '%s'...\n", absoluteTarget)
-	fetchCmd := exec.Command("git", "fetch", "--all")
+	fetchCmd := gitCommand(ctx, "fetch", "--all")
 	fetchCmd.Dir = absoluteTarget
 	fetchCmd.Stdout = w
 	fetchCmd.Stderr = os.Stderr
-	if err := fetchCmd.Run(); err != nil {
+	if err := runCommand(ctx, fetchCmd); err != nil {
 		return fmt.Errorf("failed to fetch: %w", err)
 	}
 
 	fmt.Fprintf(w, "Checking out commit: %s\n", repo.Commit)
-	checkoutCmd := exec.Command("git", "checkout", repo.Commit)
+	checkoutCmd := gitCommand(ctx, "checkout", repo.Commit)
 	checkoutCmd.Dir = absoluteTarget
 	checkoutCmd.Stdout = w
 	checkoutCmd.Stderr = os.Stderr
-	if err := checkoutCmd.Run(); err != nil {
+	if err := runCommand(ctx, checkoutCmd); err != nil {
 		return fmt.Errorf("failed to checkout commit %s: %w", repo.Commit, err)
 	}
 
@@ -117,31 +174,88 @@ func checkoutCommit(w io.Writer, repo config.Repo, absoluteTarget string, dryRun
 }
 
 // pullRepo pulls the latest changes in the repository.
-func pullRepo(w io.Writer, name string, absoluteTarget string, dryRun bool) error {
+func pullRepo(ctx context.Context, w io.Writer, name string, absoluteTarget string, dryRun bool) error {
 	if dryRun {
 		fmt.Fprintf(w, "[DRY RUN] Would pull latest in '%s'\n", absoluteTarget)
 		return nil
 	}
 
 	fmt.Fprintf(w, "Pulling latest for '%s' in '%s'...\n", name, absoluteTarget)
-	pullCmd := exec.Command("git", "pull")
+	pullCmd := gitCommand(ctx, "pull")
 	pullCmd.Dir = absoluteTarget
 	pullCmd.Stdout = w
 	pullCmd.Stderr = os.Stderr
-	if err := pullCmd.Run(); err != nil {
+	if err := runCommand(ctx, pullCmd); err != nil {
 		return fmt.Errorf("failed to pull: %w", err)
 	}
 
 	return nil
 }
 
-// ProcessRepos processes all configured repositories.
-func ProcessRepos(w io.Writer, repos map[string]config.Repo, currentHost string, dryRun bool) error {
+// gitCommand is exec.CommandContext for git. Once ctx is cancelled, Wait
+// stops waiting for the output of processes git started (ssh, credential
+// helpers) a second after git itself was killed.
+func gitCommand(ctx context.Context, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.WaitDelay = time.Second
+	return cmd
+}
+
+// runCommand runs cmd, reporting the cancellation of ctx rather than the kill
+// signal when it was interrupted.
+func runCommand(ctx context.Context, cmd *exec.Cmd) error {
+	err := cmd.Run()
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+// PendingRepos returns the names of the enabled repositories apply would
+// change, sorted: those not cloned yet and those not at their pinned commit.
+// Repositories with update = true are not fetched, so upstream changes are
+// not detected.
+func PendingRepos(repos map[string]config.Repo, currentHost string) []string {
+	var pending []string
+	for name, repo := range repos {
+		if !config.IsEnabled(repo.Enable) || !config.ShouldApplyForHost(repo.Hosts, currentHost) {
+			continue
+		}
+		absoluteTarget, err := config.ExpandPath(repo.Target)
+		if err != nil {
+			pending = append(pending, name)
+			continue
+		}
+		if info, err := os.Stat(absoluteTarget); err != nil || !info.IsDir() {
+			pending = append(pending, name)
+			continue
+		}
+		if repo.Commit != "" {
+			cmd := exec.Command("git", "rev-parse", "HEAD")
+			cmd.Dir = absoluteTarget
+			head, err := cmd.Output()
+			if err != nil || !strings.HasPrefix(strings.TrimSpace(string(head)), repo.Commit) {
+				pending = append(pending, name)
+			}
+		}
+	}
+	sort.Strings(pending)
+	return pending
+}
+
+// ProcessRepos processes all configured repositories and returns those it
+// cloned, with their size. Clone progress is passed to progress, if set.
+// It stops at the first failure unless keepGoing is set, in which case every
+// repository is processed and the failures are returned joined. Once ctx is
+// cancelled no further repositories are processed.
+func ProcessRepos(ctx context.Context, w io.Writer, repos map[string]config.Repo, currentHost string, dryRun, keepGoing bool, progress Progress) ([]Clone, error) {
 	if len(repos) == 0 {
-		return nil
+		return nil, nil
 	}
 
 	fmt.Fprintln(w, "\nProcessing repositories...")
+	var clones []Clone
+	var errs []error
 	for name, repo := range repos {
 		if !config.IsEnabled(repo.Enable) {
 			fmt.Fprintf(w, "  Skipping repo: %s (disabled)\n", name)
@@ -151,10 +265,30 @@ func ProcessRepos(w io.Writer, repos map[string]config.Repo, currentHost string,
 			fmt.Fprintf(w, "  Skipping repo: %s (host filter)\n", name)
 			continue
 		}
+		if ctx.Err() != nil {
+			err := fmt.Errorf("repo '%s' not processed: %w", name, ctx.Err())
+			if !keepGoing {
+				return clones, err
+			}
+			errs = append(errs, err)
+			continue
+		}
 		fmt.Fprintf(w, "  Repo: %s (URL: %s)\n", name, repo.URL)
-		if err := CloneOrUpdateRepo(w, name, repo, dryRun); err != nil {
-			return fmt.Errorf("repo '%s' failed: %w", name, err)
+		cloned, err := cloneOrUpdateRepo(ctx, w, name, repo, dryRun, progress)
+		if err != nil {
+			if !keepGoing {
+				return clones, fmt.Errorf("repo '%s' failed: %w", name, err)
+			}
+			errs = append(errs, fmt.Errorf("repo '%s' failed: %w", name, err))
+			continue
+		}
+		if cloned {
+			target, _ := config.ExpandPath(repo.Target)
+			clone := Clone{Name: name, Size: dirSize(target)}
+			fmt.Fprintf(w, "  Cloned %s (%s)\n", name, FormatSize(clone.Size))
+			clones = append(clones, clone)
 		}
 	}
-	return nil
+	sort.Slice(clones, func(i, j int) bool { return clones[i].Name < clones[j].Name })
+	return clones, errors.Join(errs...)
 }
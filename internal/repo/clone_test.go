@@ -0,0 +1,39 @@
+package repo
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/mad01/ralph/internal/config"
+)
+
+func TestSyncRemoteURL(t *testing.T) {
+	dir := initTestRepo(t)
+	runGit(t, dir, "remote", "add", "origin", "https://github.com/tmux-plugins/tpm")
+	origin := func() string { return strings.TrimSpace(runGit(t, dir, "remote", "get-url", "origin")) }
+
+	sshURL := "git@github.com:tmux-plugins/tpm.git"
+	if err := syncRemoteURL(context.Background(), io.Discard, config.Repo{URL: sshURL}, dir, true); err != nil {
+		t.Fatalf("dry run: %v", err)
+	}
+	if got := origin(); got != "https://github.com/tmux-plugins/tpm" {
+		t.Errorf("dry run changed origin to %q", got)
+	}
+
+	if err := syncRemoteURL(context.Background(), io.Discard, config.Repo{URL: sshURL}, dir, false); err != nil {
+		t.Fatalf("syncRemoteURL: %v", err)
+	}
+	if got := origin(); got != sshURL {
+		t.Errorf("origin = %q, want %q", got, sshURL)
+	}
+
+	// A remote pointing at another repository is left alone
+	if err := syncRemoteURL(context.Background(), io.Discard, config.Repo{URL: "git@github.com:me/tpm-fork.git"}, dir, false); err != nil {
+		t.Fatalf("syncRemoteURL: %v", err)
+	}
+	if got := origin(); got != sshURL {
+		t.Errorf("origin = %q, want it unchanged", got)
+	}
+}
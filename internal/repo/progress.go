@@ -0,0 +1,105 @@
+package repo
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Progress receives git's progress while a repo is cloned, e.g. "Receiving
+// objects:  45% (1234/2742), 12.30 MiB | 5.00 MiB/s".
+type Progress func(name, status string)
+
+// Clone is a repo ProcessRepos cloned, with the size of its checkout.
+type Clone struct {
+	Name string
+	Size int64 // Bytes on disk, .git included
+}
+
+// progressLinePattern matches git's progress lines ("Receiving objects:  45%
+// (1234/2742)", "remote: Counting objects: 100% (10/10), done.").
+var progressLinePattern = regexp.MustCompile(`^(remote: )?[A-Z][a-z ]+:\s+\d+% `)
+
+// progressWriter splits git's stderr into lines, on \r as well as \n since
+// git redraws progress in place. Progress lines go to report; other lines
+// are passed on to out.
+type progressWriter struct {
+	name   string
+	report Progress
+	out    io.Writer
+
+	mu  sync.Mutex
+	buf []byte
+}
+
+func (p *progressWriter) Write(data []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.buf = append(p.buf, data...)
+	for {
+		i := bytes.IndexAny(p.buf, "\r\n")
+		if i < 0 {
+			break
+		}
+		p.line(string(p.buf[:i]))
+		p.buf = p.buf[i+1:]
+	}
+	return len(data), nil
+}
+
+// Flush handles a last line without a line ending.
+func (p *progressWriter) Flush() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.buf) > 0 {
+		p.line(string(p.buf))
+		p.buf = nil
+	}
+}
+
+func (p *progressWriter) line(line string) {
+	line = strings.TrimSpace(line)
+	switch {
+	case line == "":
+	case progressLinePattern.MatchString(line):
+		p.report(p.name, line)
+	default:
+		fmt.Fprintln(p.out, line)
+	}
+}
+
+// dirSize returns the total size of the regular files under dir.
+func dirSize(dir string) int64 {
+	var size int64
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.Type().IsRegular() {
+			if info, err := d.Info(); err == nil {
+				size += info.Size()
+			}
+		}
+		return nil
+	})
+	return size
+}
+
+// FormatSize renders a byte count for humans, e.g. "12.3 MiB".
+func FormatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,74 @@
+package repo
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/mad01/ralph/internal/config"
+)
+
+func TestProgressWriter(t *testing.T) {
+	var statuses []string
+	var out bytes.Buffer
+	pw := &progressWriter{name: "tpm", out: &out, report: func(name, status string) {
+		statuses = append(statuses, name+": "+status)
+	}}
+	pw.Write([]byte("Cloning into '/tmp/tpm'...\nremote: Counting objects:  50% (1/2)\rremote: Counting objects: 100% (2/2), done.\n"))
+	pw.Write([]byte("Receiving objects:  45% (9/20), 1.00 MiB | 2.00 MiB/s\r"))
+	pw.Write([]byte("fatal: early EOF"))
+	pw.Flush()
+
+	want := []string{
+		"tpm: remote: Counting objects:  50% (1/2)",
+		"tpm: remote: Counting objects: 100% (2/2), done.",
+		"tpm: Receiving objects:  45% (9/20), 1.00 MiB | 2.00 MiB/s",
+	}
+	if !reflect.DeepEqual(statuses, want) {
+		t.Errorf("progress = %q, want %q", statuses, want)
+	}
+	if got := out.String(); got != "Cloning into '/tmp/tpm'...\nfatal: early EOF\n" {
+		t.Errorf("passed-through output = %q", got)
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	tests := map[int64]string{
+		0:                "0 B",
+		1023:             "1023 B",
+		1536:             "1.5 KiB",
+		12 * 1024 * 1024: "12.0 MiB",
+		3 << 30:          "3.0 GiB",
+	}
+	for n, want := range tests {
+		if got := FormatSize(n); got != want {
+			t.Errorf("FormatSize(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
+
+func TestProcessRepos_ReportsClones(t *testing.T) {
+	source := initTestRepo(t)
+	target := filepath.Join(t.TempDir(), "clone")
+	repos := map[string]config.Repo{"dots": {URL: source, Target: target}}
+
+	clones, err := ProcessRepos(context.Background(), io.Discard, repos, "host", false, false, func(string, string) {})
+	if err != nil {
+		t.Fatalf("ProcessRepos() error = %v", err)
+	}
+	if len(clones) != 1 || clones[0].Name != "dots" || clones[0].Size == 0 {
+		t.Fatalf("clones = %+v, want dots with a size", clones)
+	}
+	if _, err := os.Stat(filepath.Join(target, "zshrc")); err != nil {
+		t.Errorf("repo was not cloned: %v", err)
+	}
+
+	clones, err = ProcessRepos(context.Background(), io.Discard, repos, "host", false, false, nil)
+	if err != nil || len(clones) != 0 {
+		t.Errorf("second run = (%+v, %v), want no clones", clones, err)
+	}
+}
@@ -0,0 +1,70 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/mad01/ralph/internal/config"
+)
+
+// PullRepo runs git pull in the dotfiles repository. When the pull stops on
+// merge conflicts, the returned error lists the conflicted files.
+// If dryRun is true, it will only print the actions it would take.
+func PullRepo(ctx context.Context, w io.Writer, repoPath string, dryRun bool) error {
+	absoluteRepo, err := config.ExpandPath(repoPath)
+	if err != nil {
+		return fmt.Errorf("failed to expand repo path '%s': %w", repoPath, err)
+	}
+	if dryRun {
+		fmt.Fprintf(w, "[DRY RUN] Would pull latest in '%s'\n", absoluteRepo)
+		return nil
+	}
+
+	out, pullErr := gitOutput(ctx, absoluteRepo, "pull")
+	if pullErr == nil {
+		fmt.Fprintln(w, out)
+		return nil
+	}
+	conflicts, err := gitOutput(ctx, absoluteRepo, "diff", "--name-only", "--diff-filter=U")
+	if err == nil && conflicts != "" {
+		files := strings.Split(conflicts, "\n")
+		return fmt.Errorf("pull stopped with conflicts in %s; resolve them in '%s' and commit", strings.Join(files, ", "), absoluteRepo)
+	}
+	return pullErr
+}
+
+// PushRepo pushes local commits that are not on the upstream branch yet and
+// returns how many there were.
+// If dryRun is true, it will only print the actions it would take.
+func PushRepo(ctx context.Context, w io.Writer, repoPath string, dryRun bool) (int, error) {
+	absoluteRepo, err := config.ExpandPath(repoPath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to expand repo path '%s': %w", repoPath, err)
+	}
+
+	count, err := gitOutput(ctx, absoluteRepo, "rev-list", "--count", "@{upstream}..HEAD")
+	if err != nil {
+		return 0, err
+	}
+	ahead, err := strconv.Atoi(count)
+	if err != nil {
+		return 0, fmt.Errorf("unexpected output from git rev-list: %q", count)
+	}
+	if ahead == 0 {
+		fmt.Fprintf(w, "  Nothing to push in '%s'.\n", absoluteRepo)
+		return 0, nil
+	}
+
+	if dryRun {
+		fmt.Fprintf(w, "[DRY RUN] Would push %d commit(s) in '%s'\n", ahead, absoluteRepo)
+		return ahead, nil
+	}
+	if _, err := gitOutput(ctx, absoluteRepo, "push"); err != nil {
+		return 0, err
+	}
+	fmt.Fprintf(w, "  Pushed %d commit(s) in '%s'.\n", ahead, absoluteRepo)
+	return ahead, nil
+}
@@ -0,0 +1,74 @@
+package repo
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// cloneWithRemote returns a repository pushed to a bare remote and a second
+// clone of that remote.
+func cloneWithRemote(t *testing.T) (string, string) {
+	t.Helper()
+	first := initTestRepo(t)
+	remote := t.TempDir()
+	runGit(t, remote, "init", "-q", "--bare")
+	runGit(t, first, "remote", "add", "origin", remote)
+	runGit(t, first, "push", "-q", "-u", "origin", "main")
+
+	second := filepath.Join(t.TempDir(), "clone")
+	runGit(t, t.TempDir(), "clone", "-q", "-b", "main", remote, second)
+	runGit(t, second, "config", "user.email", "test@example.com")
+	runGit(t, second, "config", "user.name", "Test")
+	runGit(t, second, "config", "pull.rebase", "false")
+	return first, second
+}
+
+func commitFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, dir, "commit", "-q", "-am", "edit "+name)
+}
+
+func TestPushAndPullRepo(t *testing.T) {
+	first, second := cloneWithRemote(t)
+
+	if n, err := PushRepo(context.Background(), io.Discard, first, false); err != nil || n != 0 {
+		t.Fatalf("up-to-date push = (%d, %v), want (0, nil)", n, err)
+	}
+
+	commitFile(t, first, "zshrc", "two\n")
+	if n, err := PushRepo(context.Background(), io.Discard, first, true); err != nil || n != 1 {
+		t.Fatalf("dry-run push = (%d, %v), want (1, nil)", n, err)
+	}
+	if n, err := PushRepo(context.Background(), io.Discard, first, false); err != nil || n != 1 {
+		t.Fatalf("push = (%d, %v), want (1, nil)", n, err)
+	}
+
+	if err := PullRepo(context.Background(), io.Discard, second, false); err != nil {
+		t.Fatalf("PullRepo failed: %v", err)
+	}
+	if content, _ := os.ReadFile(filepath.Join(second, "zshrc")); string(content) != "two\n" {
+		t.Errorf("pulled content = %q, want %q", content, "two\n")
+	}
+}
+
+func TestPullRepo_ReportsConflicts(t *testing.T) {
+	first, second := cloneWithRemote(t)
+	commitFile(t, first, "zshrc", "from first\n")
+	runGit(t, first, "push", "-q")
+	commitFile(t, second, "zshrc", "from second\n")
+
+	err := PullRepo(context.Background(), io.Discard, second, false)
+	if err == nil {
+		t.Fatal("expected a conflict error")
+	}
+	if !strings.Contains(err.Error(), "conflicts in zshrc") {
+		t.Errorf("error = %v, want it to name the conflicted file", err)
+	}
+}
@@ -1,11 +1,15 @@
 package report
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"sort"
 	"strings"
 
 	"github.com/fatih/color"
+	"github.com/mad01/ralph/internal/exitcode"
 )
 
 // Status represents the outcome of a single step.
@@ -33,6 +37,13 @@ func (s Status) String() string {
 	}
 }
 
+// What applying a step changed, for StepResult.Change.
+const (
+	ChangeCreated   = "created"   // The target did not exist
+	ChangeUpdated   = "updated"   // The target existed but differed
+	ChangeUnchanged = "unchanged" // The target was already in sync
+)
+
 // Verbosity controls how much detail PrintSummary shows.
 type Verbosity int
 
@@ -48,6 +59,16 @@ type StepResult struct {
 	Status  Status
 	Message string
 	Err     error
+	Pending bool // A dry run found a change that apply would make
+	// Change says what applying an OK step did to its target: one of the
+	// Change* values, or "" when not known
+	Change string
+	// Interrupted marks a failure caused by cancelling the run (Ctrl-C)
+	// rather than by the step itself
+	Interrupted bool
+	// Recipe is the recipe that contributed the item, set by SetRecipes;
+	// "" for the main config and steps that are not config items
+	Recipe string
 }
 
 // Phase groups related steps (e.g. "Dotfiles", "Directories").
@@ -61,9 +82,23 @@ func (p *Phase) AddOK(name, msg string) {
 	p.Steps = append(p.Steps, StepResult{Name: name, Status: StatusOK, Message: msg})
 }
 
-// AddFail records a failed step.
+// AddPending records a step that is fine but out of date: a dry run found a
+// change that apply would make.
+func (p *Phase) AddPending(name, msg string) {
+	p.Steps = append(p.Steps, StepResult{Name: name, Status: StatusOK, Message: msg, Pending: true})
+}
+
+// AddChanged records a successful step and what it changed, one of the
+// Change* values.
+func (p *Phase) AddChanged(name, change, msg string) {
+	p.Steps = append(p.Steps, StepResult{Name: name, Status: StatusOK, Message: msg, Change: change})
+}
+
+// AddFail records a failed step. A step failing with context.Canceled is
+// recorded as interrupted.
 func (p *Phase) AddFail(name, msg string, err error) {
-	p.Steps = append(p.Steps, StepResult{Name: name, Status: StatusFail, Message: msg, Err: err})
+	p.Steps = append(p.Steps, StepResult{Name: name, Status: StatusFail, Message: msg, Err: err,
+		Interrupted: errors.Is(err, context.Canceled)})
 }
 
 // AddWarn records a warning step.
@@ -93,10 +128,57 @@ func (p *Phase) Counts() (ok, warn, fail, skip int) {
 	return
 }
 
+// Changes returns the number of OK steps that created, updated and left
+// their target unchanged.
+func (p *Phase) Changes() (created, updated, unchanged int) {
+	for _, s := range p.Steps {
+		switch s.Change {
+		case ChangeCreated:
+			created++
+		case ChangeUpdated:
+			updated++
+		case ChangeUnchanged:
+			unchanged++
+		}
+	}
+	return
+}
+
+// tally returns the phase counts for formatCounts.
+func (p *Phase) tally() tally {
+	var t tally
+	t.ok, t.warn, t.fail, t.skip = p.Counts()
+	t.created, t.updated, t.unchanged = p.Changes()
+	// Steps that report their change are counted by it instead
+	t.ok -= t.created + t.updated + t.unchanged
+	return t
+}
+
+// CountsString returns the phase counts in the compact form used by the
+// summary, e.g. "1 updated, 42 unchanged, 1 skip".
+func (p *Phase) CountsString() string {
+	return formatCounts(p.tally())
+}
+
+// Line returns a one-line status for the phase, marked by its worst outcome,
+// e.g. "✓ Dotfiles: 12 ok, 1 skip".
+func (p *Phase) Line() string {
+	mark := color.GreenString("✓")
+	_, warn, fail, _ := p.Counts()
+	switch {
+	case fail > 0:
+		mark = color.RedString("✗")
+	case warn > 0:
+		mark = color.YellowString("!")
+	}
+	return fmt.Sprintf("%s %s: %s", mark, p.Name, p.CountsString())
+}
+
 // Report collects results across all phases for a command run.
 type Report struct {
 	Command string
 	Phases  []Phase
+	Strict  bool // Warnings count as failures in the exit code (--strict)
 }
 
 // AddPhase starts tracking a new phase and returns a pointer to it.
@@ -117,6 +199,18 @@ func (r *Report) HasFailures() bool {
 	return false
 }
 
+// Interrupted reports whether any step was cut short by cancelling the run.
+func (r *Report) Interrupted() bool {
+	for _, p := range r.Phases {
+		for _, s := range p.Steps {
+			if s.Interrupted {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // HasWarnings returns true if any step has StatusWarn.
 func (r *Report) HasWarnings() bool {
 	for i := range r.Phases {
@@ -129,15 +223,47 @@ func (r *Report) HasWarnings() bool {
 	return false
 }
 
-// ExitCode returns 0 for clean, 1 for failures, 2 for warnings-only.
+// HasPending returns true if any step is pending.
+func (r *Report) HasPending() bool {
+	for i := range r.Phases {
+		for _, s := range r.Phases[i].Steps {
+			if s.Pending {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// DryRunExitCode returns the exit code for a dry run: exitcode.OK when
+// nothing needs to change, exitcode.Failure for failures, exitcode.Drift
+// when changes are pending. Warnings do not affect it, so drift can be
+// detected from the exit code alone, unless the report is strict, where they
+// are failures.
+func (r *Report) DryRunExitCode() int {
+	if r.HasFailures() || (r.Strict && r.HasWarnings()) {
+		return exitcode.Failure
+	}
+	if r.HasPending() {
+		return exitcode.Drift
+	}
+	return exitcode.OK
+}
+
+// ExitCode returns exitcode.OK for clean, exitcode.Failure for failures,
+// exitcode.Warnings for warnings-only. A strict report returns
+// exitcode.Failure for warnings too.
 func (r *Report) ExitCode() int {
 	if r.HasFailures() {
-		return 1
+		return exitcode.Failure
+	}
+	if r.Strict && r.HasWarnings() {
+		return exitcode.Failure
 	}
 	if r.HasWarnings() {
-		return 2
+		return exitcode.Warnings
 	}
-	return 0
+	return exitcode.OK
 }
 
 // PrintSummary writes the end-of-run summary to w.
@@ -146,75 +272,207 @@ func (r *Report) PrintSummary(w io.Writer, v Verbosity) {
 	fmt.Fprintln(w, "--- Summary ---")
 	fmt.Fprintln(w)
 
-	totalOK, totalWarn, totalFail, totalSkip := 0, 0, 0, 0
+	var total tally
+	totalPending := 0
 
 	for i := range r.Phases {
 		p := &r.Phases[i]
-		ok, warn, fail, skip := p.Counts()
-		totalOK += ok
-		totalWarn += warn
-		totalFail += fail
-		totalSkip += skip
+		t := p.tally()
+		total.add(t)
+		for _, s := range p.Steps {
+			if s.Pending {
+				totalPending++
+			}
+		}
 
 		// In quiet mode, skip phases with no failures.
-		if v == VerbosityQuiet && fail == 0 {
+		if v == VerbosityQuiet && t.fail == 0 {
 			continue
 		}
 
 		// Print phase count line.
-		fmt.Fprintf(w, "%s: %s\n", p.Name, formatCounts(ok, warn, fail, skip))
+		fmt.Fprintf(w, "%s: %s\n", p.Name, formatCounts(t))
 
 		// Print detail lines based on verbosity.
 		for _, s := range p.Steps {
-			switch {
-			case s.Status == StatusFail:
-				fmt.Fprintf(w, "  %s %s: %s\n", color.RedString("FAIL"), s.Name, s.Message)
-			case s.Status == StatusWarn && v != VerbosityQuiet:
-				fmt.Fprintf(w, "  %s %s: %s\n", color.YellowString("WARN"), s.Name, s.Message)
-			case v == VerbosityVerbose && s.Status == StatusOK:
-				fmt.Fprintf(w, "  %s %s\n", color.GreenString("OK"), s.Name)
-			case v == VerbosityVerbose && s.Status == StatusSkip:
-				fmt.Fprintf(w, "  %s %s: %s\n", color.CyanString("SKIP"), s.Name, s.Message)
+			printStep(w, s, v)
+		}
+	}
+
+	r.printTotals(w, total, totalPending)
+}
+
+// SetRecipes records in each step the recipe that contributed it, as
+// returned by recipeOf for the step's phase and name.
+func (r *Report) SetRecipes(recipeOf func(phase, name string) string) {
+	for i := range r.Phases {
+		p := &r.Phases[i]
+		for j := range p.Steps {
+			p.Steps[j].Recipe = recipeOf(p.Name, p.Steps[j].Name)
+		}
+	}
+}
+
+// PrintByRecipe writes the end-of-run summary to w like PrintSummary, but
+// with the steps grouped by the recipe that contributed them (see
+// SetRecipes) instead of by phase. Steps from the main config, and those
+// that are not config items such as hooks, are grouped under "config.toml",
+// after the recipes.
+func (r *Report) PrintByRecipe(w io.Writer, v Verbosity) {
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "--- Summary by recipe ---")
+	fmt.Fprintln(w)
+
+	groups := make(map[string]*Phase)
+	var recipes []string
+	var total tally
+	totalPending := 0
+	for _, p := range r.Phases {
+		for _, s := range p.Steps {
+			g, ok := groups[s.Recipe]
+			if !ok {
+				g = &Phase{Name: s.Recipe}
+				groups[s.Recipe] = g
+				if s.Recipe != "" {
+					recipes = append(recipes, s.Recipe)
+				}
+			}
+			s.Name = p.Name + "/" + s.Name
+			g.Steps = append(g.Steps, s)
+			if s.Pending {
+				totalPending++
 			}
 		}
 	}
+	sort.Strings(recipes)
+	if _, ok := groups[""]; ok {
+		groups[""].Name = "config.toml"
+		recipes = append(recipes, "")
+	}
 
-	// Totals line.
+	for _, name := range recipes {
+		g := groups[name]
+		t := g.tally()
+		total.add(t)
+		if v == VerbosityQuiet && t.fail == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "%s: %s\n", g.Name, formatCounts(t))
+		for _, s := range g.Steps {
+			printStep(w, s, v)
+		}
+	}
+
+	r.printTotals(w, total, totalPending)
+}
+
+// printStep writes the detail line of a step, if v shows it.
+func printStep(w io.Writer, s StepResult, v Verbosity) {
+	name := s.Name
+	switch {
+	case s.Interrupted:
+		fmt.Fprintf(w, "  %s %s: %s\n", color.RedString("INTERRUPTED"), name, s.Message)
+	case s.Status == StatusFail:
+		fmt.Fprintf(w, "  %s %s: %s\n", color.RedString("FAIL"), name, s.Message)
+	case s.Status == StatusWarn && v != VerbosityQuiet:
+		fmt.Fprintf(w, "  %s %s: %s\n", color.YellowString("WARN"), name, s.Message)
+	case s.Pending && v != VerbosityQuiet:
+		fmt.Fprintf(w, "  %s %s: %s\n", color.CyanString("PENDING"), name, s.Message)
+	case (s.Change == ChangeCreated || s.Change == ChangeUpdated) && v != VerbosityQuiet:
+		label := color.GreenString(strings.ToUpper(s.Change))
+		if s.Message != "" {
+			fmt.Fprintf(w, "  %s %s: %s\n", label, name, s.Message)
+		} else {
+			fmt.Fprintf(w, "  %s %s\n", label, name)
+		}
+	case v == VerbosityVerbose && s.Status == StatusOK:
+		fmt.Fprintf(w, "  %s %s\n", color.GreenString("OK"), name)
+	case v == VerbosityVerbose && s.Status == StatusSkip:
+		fmt.Fprintf(w, "  %s %s: %s\n", color.CyanString("SKIP"), name, s.Message)
+	}
+}
+
+// printTotals writes the totals line and the closing status of the summary.
+func (r *Report) printTotals(w io.Writer, total tally, totalPending int) {
 	fmt.Fprintln(w)
-	parts := []string{color.GreenString("%d ok", totalOK)}
-	if totalWarn > 0 {
-		parts = append(parts, color.YellowString("%d warnings", totalWarn))
+	var parts []string
+	if total.created > 0 {
+		parts = append(parts, color.GreenString("%d created", total.created))
+	}
+	if total.updated > 0 {
+		parts = append(parts, color.GreenString("%d updated", total.updated))
+	}
+	if total.unchanged > 0 {
+		parts = append(parts, fmt.Sprintf("%d unchanged", total.unchanged))
+	}
+	if total.ok > 0 || len(parts) == 0 {
+		parts = append(parts, color.GreenString("%d ok", total.ok))
 	}
-	if totalFail > 0 {
-		parts = append(parts, color.RedString("%d failed", totalFail))
+	if total.warn > 0 {
+		parts = append(parts, color.YellowString("%d warnings", total.warn))
 	}
-	if totalSkip > 0 {
-		parts = append(parts, color.CyanString("%d skipped", totalSkip))
+	if total.fail > 0 {
+		parts = append(parts, color.RedString("%d failed", total.fail))
+	}
+	if total.skip > 0 {
+		parts = append(parts, color.CyanString("%d skipped", total.skip))
+	}
+	if totalPending > 0 {
+		parts = append(parts, color.CyanString("%d pending", totalPending))
 	}
 	fmt.Fprintln(w, strings.Join(parts, "  "))
 
-	if r.HasFailures() {
+	if r.Interrupted() {
+		color.New(color.FgRed).Fprintln(w, "Interrupted: the items marked INTERRUPTED did not finish.")
+	} else if r.HasFailures() {
 		color.New(color.FgRed).Fprintln(w, "Some items failed. Review the details above.")
+	} else if r.HasWarnings() && r.Strict {
+		color.New(color.FgRed).Fprintln(w, "Completed with warnings, which fail the run in strict mode.")
 	} else if r.HasWarnings() {
 		color.New(color.FgYellow).Fprintln(w, "Completed with warnings.")
 	}
 }
 
-// formatCounts builds a compact "N ok, N warn, N fail, N skip" string,
-// omitting zero-value categories.
-func formatCounts(ok, warn, fail, skip int) string {
+// tally counts the steps of a phase or report by outcome. OK steps that
+// report their change are counted by it rather than as ok.
+type tally struct {
+	ok, created, updated, unchanged, warn, fail, skip int
+}
+
+func (t *tally) add(o tally) {
+	t.ok += o.ok
+	t.created += o.created
+	t.updated += o.updated
+	t.unchanged += o.unchanged
+	t.warn += o.warn
+	t.fail += o.fail
+	t.skip += o.skip
+}
+
+// formatCounts builds a compact "N created, N updated, N unchanged, N ok,
+// N warn, N fail, N skip" string, omitting zero-value categories.
+func formatCounts(t tally) string {
 	var parts []string
-	if ok > 0 {
-		parts = append(parts, color.GreenString("%d ok", ok))
+	if t.created > 0 {
+		parts = append(parts, color.GreenString("%d created", t.created))
+	}
+	if t.updated > 0 {
+		parts = append(parts, color.GreenString("%d updated", t.updated))
+	}
+	if t.unchanged > 0 {
+		parts = append(parts, fmt.Sprintf("%d unchanged", t.unchanged))
+	}
+	if t.ok > 0 {
+		parts = append(parts, color.GreenString("%d ok", t.ok))
 	}
-	if warn > 0 {
-		parts = append(parts, color.YellowString("%d warn", warn))
+	if t.warn > 0 {
+		parts = append(parts, color.YellowString("%d warn", t.warn))
 	}
-	if fail > 0 {
-		parts = append(parts, color.RedString("%d fail", fail))
+	if t.fail > 0 {
+		parts = append(parts, color.RedString("%d fail", t.fail))
 	}
-	if skip > 0 {
-		parts = append(parts, color.CyanString("%d skip", skip))
+	if t.skip > 0 {
+		parts = append(parts, color.CyanString("%d skip", t.skip))
 	}
 	if len(parts) == 0 {
 		return "nothing to report"
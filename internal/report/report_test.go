@@ -2,6 +2,8 @@ package report
 
 import (
 	"bytes"
+	"context"
+	"fmt"
 	"strings"
 	"testing"
 
@@ -282,6 +284,24 @@ func TestPrintSummaryFailureMessage(t *testing.T) {
 	assertContains(t, buf.String(), "Some items failed.")
 }
 
+func TestPrintSummaryInterrupted(t *testing.T) {
+	r := &Report{Command: "test"}
+	p := r.AddPhase("Builds")
+	p.AddFail("fzf", "something broke", nil)
+	err := fmt.Errorf("build 'nvim' failed: %w", context.Canceled)
+	p.AddFail("nvim", err.Error(), err)
+
+	if !r.Interrupted() {
+		t.Fatal("Interrupted() = false, want true")
+	}
+	var buf bytes.Buffer
+	r.PrintSummary(&buf, VerbosityNormal)
+	out := buf.String()
+	assertContains(t, out, "FAIL fzf")
+	assertContains(t, out, "INTERRUPTED nvim")
+	assertContains(t, out, "Interrupted: the items marked INTERRUPTED did not finish.")
+}
+
 func TestPrintSummaryWarningMessage(t *testing.T) {
 	r := &Report{Command: "test"}
 	p := r.AddPhase("Items")
@@ -325,6 +345,74 @@ func TestStatusString(t *testing.T) {
 	}
 }
 
+func TestPhaseLine(t *testing.T) {
+	r := &Report{Command: "test"}
+	p := r.AddPhase("Dotfiles")
+	if got, want := p.Line(), "✓ Dotfiles: nothing to report"; got != want {
+		t.Errorf("empty Line() = %q, want %q", got, want)
+	}
+
+	p.AddOK("vimrc", "")
+	p.AddSkip("tmux", "disabled")
+	if got, want := p.Line(), "✓ Dotfiles: 1 ok, 1 skip"; got != want {
+		t.Errorf("Line() = %q, want %q", got, want)
+	}
+
+	p.AddWarn("gitconfig", "post-hook exit status 1")
+	if got := p.Line(); !strings.HasPrefix(got, "! ") {
+		t.Errorf("Line() with warnings = %q, want '!' mark", got)
+	}
+
+	p.AddFail("broken", "missing source", nil)
+	if got, want := p.Line(), "✗ Dotfiles: 1 ok, 1 warn, 1 fail, 1 skip"; got != want {
+		t.Errorf("Line() = %q, want %q", got, want)
+	}
+}
+
+func TestDryRunExitCode(t *testing.T) {
+	r := &Report{Command: "test"}
+	p := r.AddPhase("Dotfiles")
+	p.AddOK("vimrc", "")
+	p.AddWarn("gitconfig", "post-hook exit status 1")
+	if got := r.DryRunExitCode(); got != 0 {
+		t.Errorf("DryRunExitCode() with warnings only = %d, want 0", got)
+	}
+
+	p.AddPending("zshrc", "would link")
+	if got := r.DryRunExitCode(); got != 4 {
+		t.Errorf("DryRunExitCode() with pending changes = %d, want 4", got)
+	}
+	var buf bytes.Buffer
+	r.PrintSummary(&buf, VerbosityNormal)
+	assertContains(t, buf.String(), "PENDING zshrc: would link")
+	assertContains(t, buf.String(), "1 pending")
+
+	p.AddFail("broken", "missing source", nil)
+	if got := r.DryRunExitCode(); got != 1 {
+		t.Errorf("DryRunExitCode() with failures = %d, want 1", got)
+	}
+}
+
+func TestExitCode_Strict(t *testing.T) {
+	r := &Report{Command: "test", Strict: true}
+	p := r.AddPhase("Tools")
+	p.AddOK("git", "installed")
+	if got := r.ExitCode(); got != 0 {
+		t.Errorf("strict ExitCode() when clean = %d, want 0", got)
+	}
+
+	p.AddWarn("fzf", "not installed")
+	if got := r.ExitCode(); got != 1 {
+		t.Errorf("strict ExitCode() with warnings = %d, want 1", got)
+	}
+	if got := r.DryRunExitCode(); got != 1 {
+		t.Errorf("strict DryRunExitCode() with warnings = %d, want 1", got)
+	}
+	var buf bytes.Buffer
+	r.PrintSummary(&buf, VerbosityNormal)
+	assertContains(t, buf.String(), "strict mode")
+}
+
 // buildTestReport creates a report with mixed outcomes for testing PrintSummary.
 func buildTestReport() *Report {
 	r := &Report{Command: "test"}
@@ -349,3 +437,68 @@ func assertContains(t *testing.T, haystack, needle string) {
 		t.Errorf("output does not contain %q\n--- output ---\n%s", needle, haystack)
 	}
 }
+
+func TestPhaseChanges(t *testing.T) {
+	r := &Report{Command: "apply"}
+	p := r.AddPhase("Dotfiles")
+	for i := 0; i < 42; i++ {
+		p.AddChanged(fmt.Sprintf("df%d", i), ChangeUnchanged, "")
+	}
+	p.AddChanged("zshrc", ChangeUpdated, "")
+	p.AddOK("plain", "")
+	p.AddSkip("tmux", "disabled")
+
+	if created, updated, unchanged := p.Changes(); created != 0 || updated != 1 || unchanged != 42 {
+		t.Errorf("Changes() = (%d, %d, %d), want (0, 1, 42)", created, updated, unchanged)
+	}
+	if ok, _, _, _ := p.Counts(); ok != 44 {
+		t.Errorf("Counts() ok = %d, want changed steps counted as ok too", ok)
+	}
+	if got := p.CountsString(); got != "1 updated, 42 unchanged, 1 ok, 1 skip" {
+		t.Errorf("CountsString() = %q", got)
+	}
+
+	var buf bytes.Buffer
+	r.PrintSummary(&buf, VerbosityNormal)
+	out := buf.String()
+	assertContains(t, out, "UPDATED zshrc")
+	assertContains(t, out, "1 updated  42 unchanged  1 ok  1 skipped")
+	if strings.Contains(out, "df0") {
+		t.Error("Normal verbosity should not list unchanged items")
+	}
+
+	buf.Reset()
+	r.PrintSummary(&buf, VerbosityQuiet)
+	if strings.Contains(buf.String(), "UPDATED") {
+		t.Error("Quiet verbosity should not list updated items")
+	}
+}
+
+func TestPrintByRecipe(t *testing.T) {
+	r := &Report{}
+	df := r.AddPhase("Dotfiles")
+	df.AddChanged("nvim", ChangeCreated, "")
+	df.AddChanged("zshrc", ChangeUnchanged, "")
+	df.AddWarn("kitty", "source missing")
+	r.AddPhase("Builds").AddFail("nvim-plugins", "exit 1", nil)
+	r.AddPhase("Post-apply hooks").AddOK("post-apply", "completed")
+
+	recipes := map[string]string{"nvim": "nvim", "nvim-plugins": "nvim", "kitty": "terminal"}
+	r.SetRecipes(func(phase, name string) string { return recipes[name] })
+
+	var buf bytes.Buffer
+	r.PrintByRecipe(&buf, VerbosityNormal)
+	out := buf.String()
+	assertContains(t, out, "nvim: 1 created, 1 fail\n  CREATED Dotfiles/nvim\n  FAIL Builds/nvim-plugins: exit 1")
+	assertContains(t, out, "terminal: 1 warn\n  WARN Dotfiles/kitty: source missing")
+	assertContains(t, out, "config.toml: 1 unchanged, 1 ok")
+	if strings.Index(out, "config.toml:") < strings.Index(out, "terminal:") {
+		t.Error("the main config should be listed after the recipes")
+	}
+
+	buf.Reset()
+	r.PrintByRecipe(&buf, VerbosityQuiet)
+	if strings.Contains(buf.String(), "terminal:") {
+		t.Error("Quiet verbosity should skip recipes without failures")
+	}
+}
@@ -0,0 +1,270 @@
+package schedule
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const (
+	// unitName is the systemd service and timer name (without suffix).
+	unitName = "ralph-sync"
+	// launchdLabel identifies the launchd agent.
+	launchdLabel = "io.github.mad01.ralph.sync"
+	// MinInterval is the shortest interval a schedule may use.
+	MinInterval = time.Minute
+)
+
+// Options describes the scheduled job.
+type Options struct {
+	Binary   string        // Absolute path of the ralph binary to run
+	Args     []string      // Arguments, e.g. ["sync", "--quiet"]
+	Interval time.Duration // Time between runs
+}
+
+// Files returns the paths of the files a schedule is made of on this OS.
+func Files() ([]string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("could not get user home directory: %w", err)
+	}
+	switch runtime.GOOS {
+	case "linux":
+		dir := filepath.Join(homeDir, ".config", "systemd", "user")
+		return []string{filepath.Join(dir, unitName+".service"), filepath.Join(dir, unitName+".timer")}, nil
+	case "darwin":
+		return []string{filepath.Join(homeDir, "Library", "LaunchAgents", launchdLabel+".plist")}, nil
+	default:
+		return nil, fmt.Errorf("scheduling is not supported on %s", runtime.GOOS)
+	}
+}
+
+// Install writes the systemd user timer (Linux) or launchd agent (macOS) for
+// opts and enables it, replacing any existing schedule.
+// If dryRun is true, it will only print the actions it would take.
+func Install(w io.Writer, opts Options, dryRun bool) error {
+	if opts.Interval < MinInterval {
+		return fmt.Errorf("interval %s is shorter than the minimum of %s", opts.Interval, MinInterval)
+	}
+	files, err := Files()
+	if err != nil {
+		return err
+	}
+
+	var contents []string
+	var enable [][]string
+	switch runtime.GOOS {
+	case "linux":
+		service, timer := systemdUnits(opts)
+		contents = []string{service, timer}
+		enable = [][]string{
+			{"systemctl", "--user", "daemon-reload"},
+			{"systemctl", "--user", "enable", "--now", unitName + ".timer"},
+		}
+	case "darwin":
+		contents = []string{launchdPlist(opts)}
+		enable = [][]string{
+			{"launchctl", "unload", files[0]},
+			{"launchctl", "load", "-w", files[0]},
+		}
+	}
+
+	for i, path := range files {
+		if dryRun {
+			fmt.Fprintf(w, "[DRY RUN] Would write %s:\n%s\n", path, contents[i])
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return fmt.Errorf("failed to create directory for '%s': %w", path, err)
+		}
+		if err := os.WriteFile(path, []byte(contents[i]), 0644); err != nil {
+			return fmt.Errorf("failed to write '%s': %w", path, err)
+		}
+		fmt.Fprintf(w, "Wrote %s\n", path)
+	}
+	for i, args := range enable {
+		// Unloading a launchd agent that isn't loaded yet fails harmlessly.
+		ignoreErr := runtime.GOOS == "darwin" && i == 0
+		if err := run(w, args, dryRun); err != nil && !ignoreErr {
+			return err
+		}
+	}
+	return nil
+}
+
+// Remove disables the schedule and deletes its files. A missing schedule is
+// not an error.
+// If dryRun is true, it will only print the actions it would take.
+func Remove(w io.Writer, dryRun bool) error {
+	files, err := Files()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(files[0]); os.IsNotExist(err) {
+		fmt.Fprintln(w, "No schedule installed.")
+		return nil
+	}
+
+	switch runtime.GOOS {
+	case "linux":
+		if err := run(w, []string{"systemctl", "--user", "disable", "--now", unitName + ".timer"}, dryRun); err != nil {
+			return err
+		}
+	case "darwin":
+		if err := run(w, []string{"launchctl", "unload", "-w", files[0]}, dryRun); err != nil {
+			return err
+		}
+	}
+	for _, path := range files {
+		if dryRun {
+			fmt.Fprintf(w, "[DRY RUN] Would remove %s\n", path)
+			continue
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove '%s': %w", path, err)
+		}
+		fmt.Fprintf(w, "Removed %s\n", path)
+	}
+	if runtime.GOOS == "linux" {
+		return run(w, []string{"systemctl", "--user", "daemon-reload"}, dryRun)
+	}
+	return nil
+}
+
+// Status reports whether a schedule is installed and returns the service
+// manager's view of it (systemctl list-timers or launchctl list output).
+func Status() (bool, string, error) {
+	files, err := Files()
+	if err != nil {
+		return false, "", err
+	}
+	if _, err := os.Stat(files[0]); os.IsNotExist(err) {
+		return false, "", nil
+	}
+
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "linux":
+		cmd = exec.Command("systemctl", "--user", "list-timers", "--all", unitName+".timer")
+	case "darwin":
+		cmd = exec.Command("launchctl", "list", launchdLabel)
+	}
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return true, strings.TrimSpace(string(out)), fmt.Errorf("%s failed: %w", strings.Join(cmd.Args, " "), err)
+	}
+	return true, strings.TrimSpace(string(out)), nil
+}
+
+// run executes a service manager command, including its output in errors.
+func run(w io.Writer, args []string, dryRun bool) error {
+	if dryRun {
+		fmt.Fprintf(w, "[DRY RUN] Would run: %s\n", strings.Join(args, " "))
+		return nil
+	}
+	out, err := exec.Command(args[0], args[1:]...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s failed: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+var systemdServiceTemplate = template.Must(template.New("service").Parse(`[Unit]
+Description=ralph dotfiles sync
+
+[Service]
+Type=oneshot
+ExecStart={{ .Command }}
+`))
+
+var systemdTimerTemplate = template.Must(template.New("timer").Parse(`[Unit]
+Description=Run ralph dotfiles sync every {{ .Interval }}
+
+[Timer]
+OnBootSec=5min
+OnUnitActiveSec={{ .Seconds }}s
+Unit=` + unitName + `.service
+
+[Install]
+WantedBy=timers.target
+`))
+
+var launchdPlistTemplate = template.Must(template.New("plist").Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+	<string>` + launchdLabel + `</string>
+	<key>ProgramArguments</key>
+	<array>
+{{- range .Arguments }}
+		<string>{{ . }}</string>
+{{- end }}
+	</array>
+	<key>StartInterval</key>
+	<integer>{{ .Seconds }}</integer>
+	<key>RunAtLoad</key>
+	<true/>
+	<key>StandardOutPath</key>
+	<string>{{ .Log }}</string>
+	<key>StandardErrorPath</key>
+	<string>{{ .Log }}</string>
+</dict>
+</plist>
+`))
+
+// templateData holds the values the unit templates are rendered with.
+type templateData struct {
+	Command   string   // Quoted command line for systemd
+	Arguments []string // XML-escaped program arguments for launchd
+	Interval  time.Duration
+	Seconds   int64
+	Log       string
+}
+
+// newTemplateData quotes and escapes the command of opts for the unit files.
+func newTemplateData(opts Options) templateData {
+	args := append([]string{opts.Binary}, opts.Args...)
+	quoted := make([]string, len(args))
+	escaped := make([]string, len(args))
+	for i, a := range args {
+		var buf bytes.Buffer
+		xml.EscapeText(&buf, []byte(a))
+		escaped[i] = buf.String()
+		if strings.ContainsAny(a, " \t\"'\\") {
+			a = `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(a) + `"`
+		}
+		quoted[i] = a
+	}
+	return templateData{
+		Command:   strings.Join(quoted, " "),
+		Arguments: escaped,
+		Interval:  opts.Interval,
+		Seconds:   int64(opts.Interval / time.Second),
+		Log:       filepath.Join(os.TempDir(), "ralph-sync.log"),
+	}
+}
+
+// systemdUnits renders the systemd user service and timer for opts.
+func systemdUnits(opts Options) (string, string) {
+	data := newTemplateData(opts)
+	var service, timer bytes.Buffer
+	systemdServiceTemplate.Execute(&service, data)
+	systemdTimerTemplate.Execute(&timer, data)
+	return service.String(), timer.String()
+}
+
+// launchdPlist renders the launchd agent for opts.
+func launchdPlist(opts Options) string {
+	var plist bytes.Buffer
+	launchdPlistTemplate.Execute(&plist, newTemplateData(opts))
+	return plist.String()
+}
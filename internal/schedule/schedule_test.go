@@ -0,0 +1,50 @@
+package schedule
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSystemdUnits(t *testing.T) {
+	service, timer := systemdUnits(Options{
+		Binary:   "/home/me/go bin/ralph",
+		Args:     []string{"sync", "--quiet"},
+		Interval: 6 * time.Hour,
+	})
+
+	if !strings.Contains(service, `ExecStart="/home/me/go bin/ralph" sync --quiet`) {
+		t.Errorf("service does not quote the binary path:\n%s", service)
+	}
+	if !strings.Contains(timer, "OnUnitActiveSec=21600s") {
+		t.Errorf("timer has the wrong interval:\n%s", timer)
+	}
+	if !strings.Contains(timer, "Unit=ralph-sync.service") {
+		t.Errorf("timer does not reference the service:\n%s", timer)
+	}
+}
+
+func TestLaunchdPlist(t *testing.T) {
+	plist := launchdPlist(Options{
+		Binary:   "/opt/R&D/ralph",
+		Args:     []string{"sync", "--quiet"},
+		Interval: 30 * time.Minute,
+	})
+
+	for _, want := range []string{
+		"<string>" + launchdLabel + "</string>",
+		"<string>/opt/R&amp;D/ralph</string>",
+		"<string>--quiet</string>",
+		"<integer>1800</integer>",
+	} {
+		if !strings.Contains(plist, want) {
+			t.Errorf("plist does not contain %q:\n%s", want, plist)
+		}
+	}
+}
+
+func TestInstall_RejectsShortInterval(t *testing.T) {
+	if err := Install(nil, Options{Binary: "ralph", Interval: time.Second}, true); err == nil {
+		t.Error("expected an error for an interval below the minimum")
+	}
+}
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"fmt"
+	"io"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// runSystemctl runs `systemctl --user` with args and returns its trimmed
+// output. It is a variable so tests can replace it.
+var runSystemctl = func(args ...string) (string, error) {
+	out, err := exec.Command("systemctl", append([]string{"--user"}, args...)...).CombinedOutput()
+	return strings.TrimSpace(string(out)), err
+}
+
+// Supported reports whether systemd user units can be managed on this system.
+func Supported() bool {
+	if runtime.GOOS != "linux" {
+		return false
+	}
+	_, err := exec.LookPath("systemctl")
+	return err == nil
+}
+
+// UnitState is what systemctl reports for a unit.
+type UnitState struct {
+	Enabled string // is-enabled output, e.g. "enabled", "disabled", "static"
+	Active  string // is-active output, e.g. "active", "inactive", "failed"
+}
+
+// IsEnabled reports whether the unit starts on login. Static units have no
+// [Install] section and count as enabled.
+func (s UnitState) IsEnabled() bool {
+	switch s.Enabled {
+	case "enabled", "enabled-runtime", "static", "alias", "indirect":
+		return true
+	}
+	return false
+}
+
+// IsActive reports whether the unit is running.
+func (s UnitState) IsActive() bool {
+	return s.Active == "active"
+}
+
+// Satisfied reports whether the unit needs no change: it is enabled and, if
+// start is set, running.
+func (s UnitState) Satisfied(start bool) bool {
+	return s.IsEnabled() && (!start || s.IsActive())
+}
+
+// String returns e.g. "enabled, active".
+func (s UnitState) String() string {
+	return s.Enabled + ", " + s.Active
+}
+
+// State returns the enabled and active state of unit. is-enabled and
+// is-active exit non-zero for disabled or inactive units, so only their
+// output is used; an empty output is reported as "unknown".
+func State(unit string) UnitState {
+	enabled, _ := runSystemctl("is-enabled", unit)
+	active, _ := runSystemctl("is-active", unit)
+	if enabled == "" {
+		enabled = "unknown"
+	}
+	if active == "" {
+		active = "unknown"
+	}
+	return UnitState{Enabled: firstLine(enabled), Active: firstLine(active)}
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
+
+// DaemonReload makes systemd pick up new or changed unit files.
+// If dryRun is true, it will only print the command it would run.
+func DaemonReload(w io.Writer, dryRun bool) error {
+	if dryRun {
+		fmt.Fprintf(w, "  %s would run: systemctl --user daemon-reload\n", color.CyanString("[dry run]"))
+		return nil
+	}
+	if out, err := runSystemctl("daemon-reload"); err != nil {
+		return fmt.Errorf("systemctl --user daemon-reload failed: %w: %s", err, out)
+	}
+	return nil
+}
+
+// Enable enables unit, and starts it too when start is set, unless it is
+// already in that state. It returns whether it changed (or would change)
+// anything.
+// If dryRun is true, it will only print the command it would run.
+func Enable(w io.Writer, unit string, start, dryRun bool) (bool, error) {
+	state := State(unit)
+	if state.Satisfied(start) {
+		fmt.Fprintf(w, "    %s (%s)\n", color.GreenString("unchanged"), state)
+		return false, nil
+	}
+
+	args := []string{"enable", unit}
+	if start {
+		args = []string{"enable", "--now", unit}
+	}
+	command := "systemctl --user " + strings.Join(args, " ")
+	if dryRun {
+		fmt.Fprintf(w, "    %s would run: %s (now %s)\n", color.CyanString("[dry run]"), command, state)
+		return true, nil
+	}
+	if out, err := runSystemctl(args...); err != nil {
+		return false, fmt.Errorf("%s failed: %w: %s", command, err, out)
+	}
+	fmt.Fprintf(w, "    %s\n", color.GreenString("enabled"))
+	return true, nil
+}
@@ -0,0 +1,94 @@
+package services
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fakeSystemctl replaces runSystemctl with one that reports units from
+// states and records the enable calls.
+func fakeSystemctl(t *testing.T, states map[string]UnitState) *[]string {
+	t.Helper()
+	var calls []string
+	original := runSystemctl
+	runSystemctl = func(args ...string) (string, error) {
+		unit := args[len(args)-1]
+		switch args[0] {
+		case "is-enabled":
+			if s := states[unit]; s.Enabled != "enabled" {
+				return s.Enabled, errors.New("exit status 1")
+			}
+			return "enabled", nil
+		case "is-active":
+			if s := states[unit]; s.Active != "active" {
+				return s.Active, errors.New("exit status 3")
+			}
+			return "active", nil
+		case "enable":
+			calls = append(calls, strings.Join(args, " "))
+			states[unit] = UnitState{Enabled: "enabled", Active: map[bool]string{true: "active", false: states[unit].Active}[args[1] == "--now"]}
+			return "", nil
+		case "daemon-reload":
+			calls = append(calls, "daemon-reload")
+			return "", nil
+		}
+		t.Fatalf("unexpected systemctl call %v", args)
+		return "", nil
+	}
+	t.Cleanup(func() { runSystemctl = original })
+	return &calls
+}
+
+func TestEnable(t *testing.T) {
+	states := map[string]UnitState{
+		"running.service": {Enabled: "enabled", Active: "active"},
+		"new.service":     {Enabled: "disabled", Active: "inactive"},
+		"timer.timer":     {Enabled: "disabled", Active: "inactive"},
+	}
+	calls := fakeSystemctl(t, states)
+
+	if changed, err := Enable(io.Discard, "running.service", true, false); err != nil || changed {
+		t.Errorf("running unit = (%v, %v), want (false, nil)", changed, err)
+	}
+	if changed, err := Enable(io.Discard, "new.service", true, true); err != nil || !changed || len(*calls) != 0 {
+		t.Errorf("dry run = (%v, %v), calls %v; want (true, nil) and no calls", changed, err, *calls)
+	}
+	if changed, err := Enable(io.Discard, "new.service", true, false); err != nil || !changed {
+		t.Errorf("enable --now = (%v, %v), want (true, nil)", changed, err)
+	}
+	if changed, err := Enable(io.Discard, "timer.timer", false, false); err != nil || !changed {
+		t.Errorf("enable = (%v, %v), want (true, nil)", changed, err)
+	}
+	if changed, err := Enable(io.Discard, "timer.timer", false, false); err != nil || changed {
+		t.Errorf("enabled unit without start = (%v, %v), want (false, nil)", changed, err)
+	}
+
+	want := []string{"enable --now new.service", "enable timer.timer"}
+	if !reflect.DeepEqual(*calls, want) {
+		t.Errorf("calls = %v, want %v", *calls, want)
+	}
+	if s := State("new.service"); !s.Satisfied(true) {
+		t.Errorf("State after enable --now = %s, want enabled and active", s)
+	}
+}
+
+func TestUnitState(t *testing.T) {
+	tests := []struct {
+		state       UnitState
+		start, want bool
+	}{
+		{UnitState{"enabled", "active"}, true, true},
+		{UnitState{"static", "active"}, true, true},
+		{UnitState{"enabled", "failed"}, true, false},
+		{UnitState{"enabled", "inactive"}, false, true},
+		{UnitState{"disabled", "active"}, false, false},
+	}
+	for _, tt := range tests {
+		if got := tt.state.Satisfied(tt.start); got != tt.want {
+			t.Errorf("%s Satisfied(%v) = %v, want %v", tt.state, tt.start, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package shell
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mad01/ralph/internal/config"
+)
+
+// CompletionsDirName is the directory under the generated scripts directory
+// that [shell.completions] files are linked into.
+const CompletionsDirName = "completions"
+
+// GetCompletionsDir returns the directory zsh completion files are linked into.
+func GetCompletionsDir() (string, error) {
+	generatedDir, err := GetRalphGeneratedDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get ralph generated scripts directory: %w", err)
+	}
+	return filepath.Join(generatedDir, CompletionsDirName), nil
+}
+
+// completionFiles returns the configured completion files keyed by file name,
+// with their absolute paths. A directory contributes the regular files at its
+// top level, hidden ones excepted.
+func completionFiles(cfg *config.Config) (map[string]string, error) {
+	files := make(map[string]string)
+	for _, path := range cfg.Shell.Completions.Paths {
+		full := path
+		if !filepath.IsAbs(path) && !strings.HasPrefix(path, "~") {
+			full = filepath.Join(cfg.DotfilesRepoPath, path)
+		}
+		source, err := config.ExpandPath(full)
+		if err != nil {
+			return nil, fmt.Errorf("failed to expand completion path '%s': %w", path, err)
+		}
+		info, err := os.Stat(source)
+		if err != nil {
+			return nil, fmt.Errorf("completion path '%s': %w", path, err)
+		}
+		if !info.IsDir() {
+			files[filepath.Base(source)] = source
+			continue
+		}
+		entries, err := os.ReadDir(source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read completion directory '%s': %w", source, err)
+		}
+		for _, entry := range entries {
+			if strings.HasPrefix(entry.Name(), ".") || !entry.Type().IsRegular() {
+				continue
+			}
+			files[entry.Name()] = filepath.Join(source, entry.Name())
+		}
+	}
+	return files, nil
+}
+
+// completionChanges returns the links LinkCompletions would create or
+// replace (name → source) and the stale ones it would remove from dir.
+func completionChanges(cfg *config.Config, dir string) (map[string]string, []string, error) {
+	files, err := completionFiles(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	link := make(map[string]string)
+	for name, source := range files {
+		dest, err := os.Readlink(filepath.Join(dir, name))
+		if err != nil || !config.SamePath(dest, source) {
+			link[name] = source
+		}
+	}
+	var stale []string
+	entries, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, nil, fmt.Errorf("failed to read completions directory '%s': %w", dir, err)
+	}
+	for _, entry := range entries {
+		if _, wanted := files[entry.Name()]; !wanted && entry.Type()&os.ModeSymlink != 0 {
+			stale = append(stale, entry.Name())
+		}
+	}
+	sort.Strings(stale)
+	return link, stale, nil
+}
+
+// LinkCompletions symlinks the [shell.completions] files into the
+// completions directory and removes links to files that are no longer
+// configured. With compinit enabled, a change also removes the .zcompdump
+// caches so the next compinit picks it up. It returns whether anything
+// changed.
+// If dryRun is true, it prints what it would do instead of changing anything.
+func LinkCompletions(w io.Writer, cfg *config.Config, dryRun bool) (bool, error) {
+	dir, err := GetCompletionsDir()
+	if err != nil {
+		return false, err
+	}
+	link, stale, err := completionChanges(cfg, dir)
+	if err != nil {
+		return false, err
+	}
+	if len(link) == 0 && len(stale) == 0 {
+		return false, nil
+	}
+
+	names := make([]string, 0, len(link))
+	for name := range link {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if dryRun {
+		for _, name := range names {
+			fmt.Fprintf(w, "[DRY RUN] Would link completion %s\n", name)
+		}
+		for _, name := range stale {
+			fmt.Fprintf(w, "[DRY RUN] Would remove completion %s\n", name)
+		}
+		if cfg.Shell.Completions.Compinit {
+			fmt.Fprintln(w, "[DRY RUN] Would remove the compinit cache")
+		}
+		return true, nil
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return false, fmt.Errorf("failed to create completions directory '%s': %w", dir, err)
+	}
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return true, fmt.Errorf("failed to replace '%s': %w", path, err)
+		}
+		if err := os.Symlink(link[name], path); err != nil {
+			return true, fmt.Errorf("failed to link completion '%s': %w", name, err)
+		}
+		fmt.Fprintf(w, "Linked completion %s\n", name)
+	}
+	for _, name := range stale {
+		if err := os.Remove(filepath.Join(dir, name)); err != nil {
+			return true, fmt.Errorf("failed to remove completion '%s': %w", name, err)
+		}
+		fmt.Fprintf(w, "Removed completion %s\n", name)
+	}
+	if cfg.Shell.Completions.Compinit {
+		if err := removeCompdump(w); err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
+
+// CompletionsInSync reports whether LinkCompletions would leave the
+// completions directory unchanged.
+func CompletionsInSync(cfg *config.Config) (bool, error) {
+	dir, err := GetCompletionsDir()
+	if err != nil {
+		return false, err
+	}
+	link, stale, err := completionChanges(cfg, dir)
+	if err != nil {
+		return false, err
+	}
+	return len(link) == 0 && len(stale) == 0, nil
+}
+
+// CompletionLines returns the lines the managed block of a zsh rc file needs
+// for [shell.completions]: dir on fpath and, if enabled, compinit.
+func CompletionLines(cfg *config.Config, dir string) []string {
+	if len(cfg.Shell.Completions.Paths) == 0 {
+		return nil
+	}
+	lines := []string{fmt.Sprintf("fpath=(%s $fpath)", dir)}
+	if cfg.Shell.Completions.Compinit {
+		lines = append(lines, "autoload -Uz compinit && compinit")
+	}
+	return lines
+}
+
+// removeCompdump removes zsh's completion caches (~/.zcompdump*, or under
+// $ZDOTDIR), which compinit otherwise keeps using after completions change.
+func removeCompdump(w io.Writer) error {
+	dir := os.Getenv("ZDOTDIR")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return fmt.Errorf("could not get user home directory: %w", err)
+		}
+		dir = home
+	}
+	dumps, err := filepath.Glob(filepath.Join(dir, ".zcompdump*"))
+	if err != nil {
+		return err
+	}
+	for _, dump := range dumps {
+		if err := os.Remove(dump); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove compinit cache '%s': %w", dump, err)
+		}
+	}
+	if len(dumps) > 0 {
+		fmt.Fprintf(w, "Removed compinit cache in %s\n", dir)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package shell
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/mad01/ralph/internal/config"
+)
+
+func TestLinkCompletions(t *testing.T) {
+	repo := t.TempDir()
+	generated := filepath.Join(t.TempDir(), "generated")
+	zdotdir := t.TempDir()
+	t.Setenv("ZDOTDIR", zdotdir)
+	originalGetRalphGeneratedDir := GetRalphGeneratedDir
+	GetRalphGeneratedDir = func() (string, error) { return generated, nil }
+	defer func() { GetRalphGeneratedDir = originalGetRalphGeneratedDir }()
+
+	for _, name := range []string{"zsh/_ralph", "zsh/completions/_git", "zsh/completions/_kubectl", "zsh/completions/.hidden"} {
+		path := filepath.Join(repo, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		os.WriteFile(path, []byte("#compdef x\n"), 0644)
+	}
+	os.WriteFile(filepath.Join(zdotdir, ".zcompdump"), []byte("cache"), 0644)
+
+	cfg := &config.Config{DotfilesRepoPath: repo, Shell: config.ShellConfig{Completions: config.ShellCompletions{
+		Paths:    []string{"zsh/_ralph", "zsh/completions"},
+		Compinit: true,
+	}}}
+	if ok, err := CompletionsInSync(cfg); err != nil || ok {
+		t.Fatalf("CompletionsInSync before linking = (%v, %v), want (false, nil)", ok, err)
+	}
+	if changed, err := LinkCompletions(io.Discard, cfg, true); err != nil || !changed {
+		t.Fatalf("dry run = (%v, %v), want (true, nil)", changed, err)
+	}
+	if _, err := os.Stat(generated); !os.IsNotExist(err) {
+		t.Fatal("dry run should not create the completions directory")
+	}
+
+	if changed, err := LinkCompletions(io.Discard, cfg, false); err != nil || !changed {
+		t.Fatalf("LinkCompletions = (%v, %v), want (true, nil)", changed, err)
+	}
+	dir := filepath.Join(generated, CompletionsDirName)
+	entries, _ := os.ReadDir(dir)
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if want := []string{"_git", "_kubectl", "_ralph"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("completions = %v, want %v", names, want)
+	}
+	if _, err := os.Stat(filepath.Join(zdotdir, ".zcompdump")); !os.IsNotExist(err) {
+		t.Error("compinit cache should be removed when completions change")
+	}
+	if ok, err := CompletionsInSync(cfg); err != nil || !ok {
+		t.Errorf("CompletionsInSync after linking = (%v, %v), want (true, nil)", ok, err)
+	}
+
+	// Dropping a path removes its links.
+	cfg.Shell.Completions.Paths = []string{"zsh/_ralph"}
+	if _, err := LinkCompletions(io.Discard, cfg, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Lstat(filepath.Join(dir, "_git")); !os.IsNotExist(err) {
+		t.Error("stale completion link should be removed")
+	}
+	if changed, err := LinkCompletions(io.Discard, cfg, false); err != nil || changed {
+		t.Errorf("second LinkCompletions = (%v, %v), want (false, nil)", changed, err)
+	}
+}
+
+func TestCompletionLines(t *testing.T) {
+	cfg := &config.Config{}
+	if lines := CompletionLines(cfg, "/gen/completions"); lines != nil {
+		t.Errorf("CompletionLines without completions = %v, want nil", lines)
+	}
+	cfg.Shell.Completions = config.ShellCompletions{Paths: []string{"_x"}, Compinit: true}
+	want := []string{"fpath=(/gen/completions $fpath)", "autoload -Uz compinit && compinit"}
+	if lines := CompletionLines(cfg, "/gen/completions"); !reflect.DeepEqual(lines, want) {
+		t.Errorf("CompletionLines = %v, want %v", lines, want)
+	}
+}
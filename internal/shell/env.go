@@ -0,0 +1,69 @@
+package shell
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mad01/ralph/internal/config"
+	"github.com/mad01/ralph/internal/dotfile"
+)
+
+// EnvVar is a resolved [shell.env] entry.
+type EnvVar struct {
+	Name  string
+	Value string
+}
+
+// ResolveEnv returns the [shell.env] variables enabled on currentHost, sorted
+// by name, with template syntax in their values rendered and a leading ~
+// expanded to the home directory. When [bin] is active and [shell.env] does
+// not set PATH itself, the bin target is prepended to PATH.
+func ResolveEnv(cfg *config.Config, currentHost string) ([]EnvVar, error) {
+	var vars []EnvVar
+	for name, env := range cfg.Shell.Env {
+		if !config.IsEnabled(env.Enable) || !config.ShouldApplyForHost(env.Hosts, currentHost) {
+			continue
+		}
+		value, err := dotfile.RenderString("shell.env."+name, env.Value, cfg, dotfile.TemplateOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("shell env var '%s': %w", name, err)
+		}
+		if value == "~" || strings.HasPrefix(value, "~/") {
+			homeDir, err := os.UserHomeDir()
+			if err != nil {
+				return nil, fmt.Errorf("could not get user home directory: %w", err)
+			}
+			value = filepath.Join(homeDir, value[1:])
+		}
+		vars = append(vars, EnvVar{Name: name, Value: value})
+	}
+	if _, setPath := cfg.Shell.Env["PATH"]; !setPath && cfg.Bin.Active(currentHost) && config.IsEnabled(cfg.Bin.AddToPath) {
+		binDir, err := config.ExpandPath(cfg.Bin.TargetDir())
+		if err != nil {
+			return nil, fmt.Errorf("bin target: %w", err)
+		}
+		vars = append(vars, EnvVar{Name: "PATH", Value: binDir + string(os.PathListSeparator) + "$PATH"})
+	}
+	sort.Slice(vars, func(i, j int) bool { return vars[i].Name < vars[j].Name })
+	return vars, nil
+}
+
+// EnvExports formats vars as statements shellType can eval: `export NAME="value"`
+// for POSIX shells and `set -gx NAME "value"` for fish. Values are double
+// quoted, so $VAR references are expanded by the shell evaluating them.
+func EnvExports(vars []EnvVar, shellType SupportedShell) string {
+	var b strings.Builder
+	for _, v := range vars {
+		if shellType == Fish {
+			value := strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(v.Value)
+			fmt.Fprintf(&b, "set -gx %s \"%s\"\n", v.Name, value)
+		} else {
+			value := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "`", "\\`").Replace(v.Value)
+			fmt.Fprintf(&b, "export %s=\"%s\"\n", v.Name, value)
+		}
+	}
+	return b.String()
+}
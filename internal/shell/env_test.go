@@ -0,0 +1,102 @@
+package shell
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mad01/ralph/internal/config"
+)
+
+func TestResolveEnv(t *testing.T) {
+	home, _ := os.UserHomeDir()
+	disabled := false
+	cfg := &config.Config{
+		TemplateVariables: map[string]interface{}{"editor": "nvim"},
+		Shell: config.ShellConfig{Env: map[string]config.ShellEnvVar{
+			"EDITOR":   {Value: "{{ .editor }}"},
+			"GOPATH":   {Value: "~/go"},
+			"WORK_DIR": {Value: "/work", Hosts: []string{"work-laptop"}},
+			"OLD":      {Value: "x", Enable: &disabled},
+		}},
+	}
+
+	vars, err := ResolveEnv(cfg, "home-desktop")
+	if err != nil {
+		t.Fatalf("ResolveEnv failed: %v", err)
+	}
+	want := []EnvVar{{"EDITOR", "nvim"}, {"GOPATH", filepath.Join(home, "go")}}
+	if len(vars) != len(want) {
+		t.Fatalf("ResolveEnv = %v, want %v", vars, want)
+	}
+	for i := range want {
+		if vars[i] != want[i] {
+			t.Errorf("vars[%d] = %v, want %v", i, vars[i], want[i])
+		}
+	}
+
+	vars, _ = ResolveEnv(cfg, "work-laptop")
+	if len(vars) != 3 || vars[2].Name != "WORK_DIR" {
+		t.Errorf("host-filtered var missing on its host: %v", vars)
+	}
+}
+
+func TestResolveEnv_BinOnPath(t *testing.T) {
+	home, _ := os.UserHomeDir()
+	cfg := &config.Config{Bin: config.BinConfig{Source: "bin"}}
+
+	vars, err := ResolveEnv(cfg, "host")
+	if err != nil {
+		t.Fatalf("ResolveEnv failed: %v", err)
+	}
+	want := filepath.Join(home, ".local", "bin") + string(os.PathListSeparator) + "$PATH"
+	if len(vars) != 1 || vars[0].Name != "PATH" || vars[0].Value != want {
+		t.Errorf("ResolveEnv = %v, want PATH=%s", vars, want)
+	}
+
+	off := false
+	cfg.Bin.AddToPath = &off
+	if vars, _ := ResolveEnv(cfg, "host"); len(vars) != 0 {
+		t.Errorf("add_to_path = false: ResolveEnv = %v, want nothing", vars)
+	}
+
+	cfg.Bin.AddToPath = nil
+	cfg.Shell.Env = map[string]config.ShellEnvVar{"PATH": {Value: "/custom"}}
+	if vars, _ := ResolveEnv(cfg, "host"); len(vars) != 1 || vars[0].Value != "/custom" {
+		t.Errorf("explicit PATH: ResolveEnv = %v, want it left alone", vars)
+	}
+}
+
+func TestResolveEnv_TemplateError(t *testing.T) {
+	cfg := &config.Config{Shell: config.ShellConfig{Env: map[string]config.ShellEnvVar{
+		"BROKEN": {Value: "{{ .missing"},
+	}}}
+	if _, err := ResolveEnv(cfg, "host"); err == nil || !strings.Contains(err.Error(), "BROKEN") {
+		t.Errorf("error = %v, want it to name the variable", err)
+	}
+}
+
+func TestEnvExports(t *testing.T) {
+	vars := []EnvVar{{"A", `say "hi" $USER`}, {"B", "back`tick\\"}}
+
+	posix := EnvExports(vars, Bash)
+	want := "export A=\"say \\\"hi\\\" $USER\"\nexport B=\"back\\`tick\\\\\"\n"
+	if posix != want {
+		t.Errorf("posix exports = %q, want %q", posix, want)
+	}
+
+	if fish := EnvExports(vars[:1], Fish); fish != "set -gx A \"say \\\"hi\\\" $USER\"\n" {
+		t.Errorf("fish exports = %q", fish)
+	}
+
+	// The POSIX output must round-trip through a real shell.
+	out, err := exec.Command("sh", "-c", EnvExports(vars[1:], Bash)+`printf %s "$B"`).Output()
+	if err != nil {
+		t.Fatalf("sh failed: %v", err)
+	}
+	if string(out) != "back`tick\\" {
+		t.Errorf("sh evaluated B = %q", out)
+	}
+}
@@ -0,0 +1,163 @@
+package shell
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mad01/ralph/internal/config"
+	"github.com/mad01/ralph/internal/dotfile"
+)
+
+// fishRun runs a fish script and returns its output.
+// This is a variable to allow for easier testing.
+var fishRun = fishRunInternal
+
+// errFishUnset is the exit status the script reading a universal variable
+// uses when the variable is not set.
+const errFishUnset = 3
+
+// fishRunInternal runs script with fish -c, without the user's config.fish so
+// globals set there don't hide the universal values.
+func fishRunInternal(script string) (string, error) {
+	if _, err := exec.LookPath("fish"); err != nil {
+		return "", fmt.Errorf("fish is not installed")
+	}
+	var stderr bytes.Buffer
+	cmd := exec.Command("fish", "--no-config", "-c", script)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == errFishUnset {
+			return "", err
+		}
+		return "", fmt.Errorf("fish failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return string(out), nil
+}
+
+// FishUniversal is a resolved [shell.fish.universal] entry.
+type FishUniversal struct {
+	Name   string
+	Values []string
+}
+
+// FishUniversalChange is a universal variable ApplyFishUniversal set, or
+// would set in a dry run.
+type FishUniversalChange struct {
+	Name    string
+	Created bool // The variable was not set before
+}
+
+// ResolveFishUniversal returns the [shell.fish.universal] variables enabled
+// on currentHost, sorted by name, with template syntax in their values
+// rendered and a leading ~ expanded to the home directory.
+func ResolveFishUniversal(cfg *config.Config, currentHost string) ([]FishUniversal, error) {
+	var vars []FishUniversal
+	for name, v := range cfg.Shell.Fish.Universal {
+		if !config.IsEnabled(v.Enable) || !config.ShouldApplyForHost(v.Hosts, currentHost) {
+			continue
+		}
+		values := make([]string, 0, len(v.Values))
+		for _, raw := range v.Values {
+			value, err := dotfile.RenderString("shell.fish.universal."+name, raw, cfg, dotfile.TemplateOptions{})
+			if err != nil {
+				return nil, fmt.Errorf("fish universal variable '%s': %w", name, err)
+			}
+			if value == "~" || strings.HasPrefix(value, "~/") {
+				homeDir, err := os.UserHomeDir()
+				if err != nil {
+					return nil, fmt.Errorf("could not get user home directory: %w", err)
+				}
+				value = filepath.Join(homeDir, value[1:])
+			}
+			values = append(values, value)
+		}
+		vars = append(vars, FishUniversal{Name: name, Values: values})
+	}
+	sort.Slice(vars, func(i, j int) bool { return vars[i].Name < vars[j].Name })
+	return vars, nil
+}
+
+// ApplyFishUniversal sets each of vars with set -U when its universal value
+// differs from the configured one, so fish's universal variable file is only
+// written when something changed. It returns the variables it set and how
+// many were already up to date.
+// If dryRun is true, it prints what it would do instead of changing anything.
+func ApplyFishUniversal(w io.Writer, vars []FishUniversal, dryRun bool) ([]FishUniversalChange, int, error) {
+	var changes []FishUniversalChange
+	unchanged := 0
+	for _, v := range vars {
+		current, set, err := fishUniversalValues(v.Name)
+		if err != nil {
+			return changes, unchanged, fmt.Errorf("reading fish universal variable '%s': %w", v.Name, err)
+		}
+		if set && equalStrings(current, v.Values) {
+			unchanged++
+			continue
+		}
+		if dryRun {
+			fmt.Fprintf(w, "[DRY RUN] Would set -U %s\n", v.Name)
+		} else {
+			if _, err := fishRun(fishSetUniversalScript(v)); err != nil {
+				return changes, unchanged, fmt.Errorf("setting fish universal variable '%s': %w", v.Name, err)
+			}
+			fmt.Fprintf(w, "Set fish universal variable %s\n", v.Name)
+		}
+		changes = append(changes, FishUniversalChange{Name: v.Name, Created: !set})
+	}
+	return changes, unchanged, nil
+}
+
+// fishUniversalValues returns the universal value of the named variable and
+// whether it is set.
+func fishUniversalValues(name string) ([]string, bool, error) {
+	// NUL-separated, so values may contain newlines; an empty list prints nothing
+	script := fmt.Sprintf("set -qU %s; or exit %d; if count $%s >/dev/null; string join0 -- $%s; end", name, errFishUnset, name, name)
+	out, err := fishRun(script)
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == errFishUnset {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	if out == "" {
+		return []string{}, true, nil
+	}
+	return strings.Split(strings.TrimSuffix(out, "\x00"), "\x00"), true, nil
+}
+
+// fishSetUniversalScript returns the set -U command for v.
+func fishSetUniversalScript(v FishUniversal) string {
+	var b strings.Builder
+	b.WriteString("set -U " + v.Name)
+	for _, value := range v.Values {
+		b.WriteString(" " + fishQuote(value))
+	}
+	return b.String()
+}
+
+// fishQuote single-quotes s for fish, where only \ and ' need escaping.
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
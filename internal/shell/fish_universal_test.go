@@ -0,0 +1,116 @@
+package shell
+
+import (
+	"bytes"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mad01/ralph/internal/config"
+)
+
+// fakeFish replaces fishRun with a universal variable store understanding
+// the scripts ApplyFishUniversal runs. It returns the store and the set -U
+// scripts run.
+func fakeFish(t *testing.T, store map[string][]string) *[]string {
+	t.Helper()
+	var sets []string
+	orig := fishRun
+	fishRun = func(script string) (string, error) {
+		fields := strings.Fields(script)
+		switch {
+		case strings.HasPrefix(script, "set -qU "):
+			values, ok := store[strings.TrimSuffix(fields[2], ";")]
+			if !ok {
+				return "", exec.Command("sh", "-c", "exit 3").Run()
+			}
+			if len(values) == 0 {
+				return "", nil
+			}
+			return strings.Join(values, "\x00") + "\x00", nil
+		case strings.HasPrefix(script, "set -U "):
+			sets = append(sets, script)
+			var values []string
+			for _, f := range fields[3:] {
+				values = append(values, strings.Trim(f, "'"))
+			}
+			store[fields[2]] = values
+			return "", nil
+		}
+		t.Fatalf("unexpected fish script %q", script)
+		return "", nil
+	}
+	t.Cleanup(func() { fishRun = orig })
+	return &sets
+}
+
+func TestApplyFishUniversal(t *testing.T) {
+	store := map[string][]string{
+		"fish_greeting":      {},
+		"fish_color_command": {"red"},
+		"fish_user_paths":    {"/opt/bin"},
+	}
+	sets := fakeFish(t, store)
+	vars := []FishUniversal{
+		{Name: "fish_color_command", Values: []string{"blue"}},
+		{Name: "fish_greeting", Values: []string{}},
+		{Name: "fish_key_bindings", Values: []string{"fish_vi_key_bindings"}},
+		{Name: "fish_user_paths", Values: []string{"/opt/bin"}},
+	}
+
+	var out bytes.Buffer
+	changes, unchanged, err := ApplyFishUniversal(&out, vars, true)
+	if err != nil {
+		t.Fatalf("ApplyFishUniversal(dry run) failed: %v", err)
+	}
+	wantChanges := []FishUniversalChange{{Name: "fish_color_command"}, {Name: "fish_key_bindings", Created: true}}
+	if !reflect.DeepEqual(changes, wantChanges) || unchanged != 2 {
+		t.Errorf("changes = %+v, %d unchanged, want %+v, 2 unchanged", changes, unchanged, wantChanges)
+	}
+	if len(*sets) != 0 {
+		t.Errorf("dry run ran %v", *sets)
+	}
+
+	if _, _, err := ApplyFishUniversal(&out, vars, false); err != nil {
+		t.Fatalf("ApplyFishUniversal failed: %v", err)
+	}
+	wantSets := []string{"set -U fish_color_command 'blue'", "set -U fish_key_bindings 'fish_vi_key_bindings'"}
+	if !reflect.DeepEqual(*sets, wantSets) {
+		t.Errorf("ran %v, want %v", *sets, wantSets)
+	}
+
+	changes, unchanged, _ = ApplyFishUniversal(&out, vars, false)
+	if len(changes) != 0 || unchanged != 4 {
+		t.Errorf("second apply: changes = %+v, %d unchanged, want none and 4", changes, unchanged)
+	}
+}
+
+func TestResolveFishUniversal(t *testing.T) {
+	disabled := false
+	cfg := &config.Config{
+		TemplateVariables: map[string]interface{}{"color": "green"},
+		Shell: config.ShellConfig{Fish: config.FishConfig{Universal: map[string]config.FishUniversalVar{
+			"fish_color_command": {Values: []string{"{{ .color }}"}},
+			"fish_user_paths":    {Values: []string{"~/go/bin", "/opt/bin"}},
+			"work_only":          {Values: []string{"x"}, Hosts: []string{"work"}},
+			"off":                {Values: []string{"x"}, Enable: &disabled},
+		}}},
+	}
+	vars, err := ResolveFishUniversal(cfg, "laptop")
+	if err != nil {
+		t.Fatalf("ResolveFishUniversal failed: %v", err)
+	}
+	if len(vars) != 2 || vars[0].Name != "fish_color_command" || vars[0].Values[0] != "green" {
+		t.Fatalf("vars = %+v", vars)
+	}
+	if paths := vars[1].Values; strings.HasPrefix(paths[0], "~") || paths[1] != "/opt/bin" {
+		t.Errorf("fish_user_paths = %v, want ~ expanded", paths)
+	}
+}
+
+func TestFishQuote(t *testing.T) {
+	if got := fishQuote(`it's a \ test`); got != `'it\'s a \\ test'` {
+		t.Errorf("fishQuote() = %s", got)
+	}
+}
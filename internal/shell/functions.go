@@ -15,8 +15,21 @@ import (
 const (
 	GeneratedAliasesFilename   = "generated_aliases.sh"
 	GeneratedFunctionsFilename = "generated_functions.sh"
+	// GeneratedFishFunctionsFilename holds the functions in fish syntax, so
+	// they can be generated next to the POSIX ones when several shells are
+	// configured (shell.names).
+	GeneratedFishFunctionsFilename = "generated_functions.fish"
 )
 
+// functionsFilename returns the name of the generated functions file for
+// shellType.
+func functionsFilename(shellType SupportedShell) string {
+	if shellType == Fish {
+		return GeneratedFishFunctionsFilename
+	}
+	return GeneratedFunctionsFilename
+}
+
 // GenerateShellConfigs generates script files for aliases and functions
 // and returns the paths to the generated files and any errors.
 // If dryRun is true, it prints what it would do and returns the prospective paths,
@@ -38,38 +51,14 @@ func GenerateShellConfigs(w io.Writer, cfg *config.Config, shellType SupportedSh
 	}
 
 	aliasFilePath = filepath.Join(generatedDir, GeneratedAliasesFilename)
-	funcFilePath = filepath.Join(generatedDir, GeneratedFunctionsFilename)
+	funcFilePath = filepath.Join(generatedDir, functionsFilename(shellType))
 
 	// Generate Aliases - filter by enable and host
-	filteredAliases := make(map[string]config.ShellAlias)
-	for name, alias := range cfg.Shell.Aliases {
-		if config.IsEnabled(alias.Enable) && config.ShouldApplyForHost(alias.Hosts, currentHost) {
-			filteredAliases[name] = alias
-		}
-	}
-
-	if len(filteredAliases) > 0 {
-		var aliasContent strings.Builder
-		aliasContent.WriteString("#!/bin/sh\n")
-		aliasContent.WriteString("# Ralph generated aliases - DO NOT EDIT MANUALLY\n\n")
-
-		// Sort alias names for consistent output
-		aliasNames := make([]string, 0, len(filteredAliases))
-		for name := range filteredAliases {
-			aliasNames = append(aliasNames, name)
-		}
-		sort.Strings(aliasNames)
-
-		for _, name := range aliasNames { // Iterate over sorted names
-			alias := filteredAliases[name]
-			// Basic sanitization for alias name and command could be added here if necessary
-			aliasContent.WriteString(fmt.Sprintf("alias %s='%s'\n", name, strings.ReplaceAll(alias.Command, "'", "'\\''")))
-		}
-
+	if aliasContent := aliasScript(cfg, currentHost); aliasContent != "" {
 		if dryRun {
 			fmt.Fprintf(w, "[DRY RUN] Would write generated aliases to: %s\n", aliasFilePath)
 		} else {
-			if err := os.WriteFile(aliasFilePath, []byte(aliasContent.String()), 0644); err != nil {
+			if err := os.WriteFile(aliasFilePath, []byte(aliasContent), 0644); err != nil {
 				return aliasFilePath, "", fmt.Errorf("failed to write generated aliases file '%s': %w", aliasFilePath, err)
 			}
 			fmt.Fprintf(w, "Generated aliases at: %s\n", aliasFilePath)
@@ -86,41 +75,11 @@ func GenerateShellConfigs(w io.Writer, cfg *config.Config, shellType SupportedSh
 	}
 
 	// Generate Functions - filter by enable and host
-	filteredFunctions := make(map[string]config.ShellFunction)
-	for name, function := range cfg.Shell.Functions {
-		if config.IsEnabled(function.Enable) && config.ShouldApplyForHost(function.Hosts, currentHost) {
-			filteredFunctions[name] = function
-		}
-	}
-
-	if len(filteredFunctions) > 0 {
-		var funcContent strings.Builder
-		funcContent.WriteString("#!/bin/sh\n") // Or make this dependent on shellType for more complex functions
-		funcContent.WriteString("# Ralph generated functions - DO NOT EDIT MANUALLY\n\n")
-
-		// Sort function names for consistent output
-		funcNames := make([]string, 0, len(filteredFunctions))
-		for name := range filteredFunctions {
-			funcNames = append(funcNames, name)
-		}
-		sort.Strings(funcNames)
-
-		for _, name := range funcNames { // Iterate over sorted names
-			function := filteredFunctions[name]
-			// For POSIX shells, function syntax is: func_name() { body }
-			// Fish shell syntax is different: function func_name; body; end;
-			// For now, sticking to POSIX sh compatible.
-			if shellType == Fish {
-				funcContent.WriteString(fmt.Sprintf("function %s\n  %s\nend\n\n", name, strings.TrimSpace(function.Body)))
-			} else {
-				funcContent.WriteString(fmt.Sprintf("%s() {\n%s\n}\n\n", name, strings.TrimSpace(function.Body)))
-			}
-		}
-
+	if funcContent := functionScript(cfg, shellType, currentHost); funcContent != "" {
 		if dryRun {
 			fmt.Fprintf(w, "[DRY RUN] Would write generated functions to: %s\n", funcFilePath)
 		} else {
-			if err := os.WriteFile(funcFilePath, []byte(funcContent.String()), 0644); err != nil {
+			if err := os.WriteFile(funcFilePath, []byte(funcContent), 0644); err != nil {
 				return aliasFilePath, funcFilePath, fmt.Errorf("failed to write generated functions file '%s': %w", funcFilePath, err)
 			}
 			fmt.Fprintf(w, "Generated functions at: %s\n", funcFilePath)
@@ -138,3 +97,122 @@ func GenerateShellConfigs(w io.Writer, cfg *config.Config, shellType SupportedSh
 
 	return aliasFilePath, funcFilePath, nil
 }
+
+// aliasScript returns the generated aliases script for the aliases enabled on
+// currentHost, or "" if there are none.
+func aliasScript(cfg *config.Config, currentHost string) string {
+	filteredAliases := make(map[string]config.ShellAlias)
+	for name, alias := range cfg.Shell.Aliases {
+		if config.IsEnabled(alias.Enable) && config.ShouldApplyForHost(alias.Hosts, currentHost) {
+			filteredAliases[name] = alias
+		}
+	}
+	if len(filteredAliases) == 0 {
+		return ""
+	}
+
+	var aliasContent strings.Builder
+	aliasContent.WriteString("#!/bin/sh\n")
+	aliasContent.WriteString("# Ralph generated aliases - DO NOT EDIT MANUALLY\n\n")
+
+	// Sort alias names for consistent output
+	aliasNames := make([]string, 0, len(filteredAliases))
+	for name := range filteredAliases {
+		aliasNames = append(aliasNames, name)
+	}
+	sort.Strings(aliasNames)
+
+	for _, name := range aliasNames { // Iterate over sorted names
+		alias := filteredAliases[name]
+		if alias.Description != "" {
+			aliasContent.WriteString(fmt.Sprintf("# %s\n", oneLine(alias.Description)))
+		}
+		// Basic sanitization for alias name and command could be added here if necessary
+		aliasContent.WriteString(fmt.Sprintf("alias %s='%s'\n", name, strings.ReplaceAll(alias.Command, "'", "'\\''")))
+	}
+	return aliasContent.String()
+}
+
+// functionScript returns the generated functions script for the functions
+// enabled on currentHost, or "" if there are none.
+func functionScript(cfg *config.Config, shellType SupportedShell, currentHost string) string {
+	filteredFunctions := make(map[string]config.ShellFunction)
+	for name, function := range cfg.Shell.Functions {
+		if config.IsEnabled(function.Enable) && config.ShouldApplyForHost(function.Hosts, currentHost) {
+			filteredFunctions[name] = function
+		}
+	}
+	help := helpFunction(cfg, shellType, currentHost)
+	if len(filteredFunctions) == 0 && help == "" {
+		return ""
+	}
+
+	var funcContent strings.Builder
+	funcContent.WriteString("#!/bin/sh\n") // Or make this dependent on shellType for more complex functions
+	funcContent.WriteString("# Ralph generated functions - DO NOT EDIT MANUALLY\n\n")
+
+	// Sort function names for consistent output
+	funcNames := make([]string, 0, len(filteredFunctions))
+	for name := range filteredFunctions {
+		funcNames = append(funcNames, name)
+	}
+	sort.Strings(funcNames)
+
+	for _, name := range funcNames { // Iterate over sorted names
+		function := filteredFunctions[name]
+		if function.Description != "" {
+			funcContent.WriteString(fmt.Sprintf("# %s\n", oneLine(function.Description)))
+		}
+		// For POSIX shells, function syntax is: func_name() { body }
+		// Fish shell syntax is different: function func_name; body; end;
+		// For now, sticking to POSIX sh compatible.
+		if shellType == Fish {
+			funcContent.WriteString(fmt.Sprintf("function %s\n  %s\nend\n\n", name, strings.TrimSpace(function.Body)))
+		} else {
+			funcContent.WriteString(fmt.Sprintf("%s() {\n%s\n}\n\n", name, strings.TrimSpace(function.Body)))
+		}
+	}
+	funcContent.WriteString(help)
+	return funcContent.String()
+}
+
+// GeneratedConfigsInSync reports whether the generated alias and function
+// files already hold what GenerateShellConfigs would write for shellType.
+func GeneratedConfigsInSync(cfg *config.Config, shellType SupportedShell) (bool, error) {
+	stale, err := StaleGeneratedConfigs(cfg, shellType)
+	return err == nil && len(stale) == 0, err
+}
+
+// StaleGeneratedConfigs returns the names of the generated alias and
+// function files that differ from what GenerateShellConfigs would write for
+// shellType, sorted: the config changed since they were last generated.
+func StaleGeneratedConfigs(cfg *config.Config, shellType SupportedShell) ([]string, error) {
+	generatedDir, err := GetRalphGeneratedDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ralph generated scripts directory: %w", err)
+	}
+	currentHost := config.GetCurrentHost()
+	files := map[string]string{
+		GeneratedAliasesFilename:     aliasScript(cfg, currentHost),
+		functionsFilename(shellType): functionScript(cfg, shellType, currentHost),
+	}
+	var stale []string
+	for name, want := range files {
+		current, err := os.ReadFile(filepath.Join(generatedDir, name))
+		if os.IsNotExist(err) {
+			// A missing file is only right when there is nothing to generate.
+			if want != "" {
+				stale = append(stale, name)
+			}
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read generated file '%s': %w", name, err)
+		}
+		if string(current) != want {
+			stale = append(stale, name)
+		}
+	}
+	sort.Strings(stale)
+	return stale, nil
+}
@@ -5,6 +5,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 
@@ -16,7 +17,7 @@ func createTestConfigForShellGen() *config.Config {
 	return &config.Config{
 		Shell: config.ShellConfig{
 			Aliases: map[string]config.ShellAlias{
-				"ll":  {Command: "ls -alh"},
+				"ll":  {Command: "ls -alh", Description: "long listing"},
 				"gcm": {Command: "git checkout master"},
 			},
 			Functions: map[string]config.ShellFunction{
@@ -98,6 +99,7 @@ func TestGenerateShellConfigs_ActualWrite_Bash(t *testing.T) {
 # Ralph generated aliases - DO NOT EDIT MANUALLY
 
 alias gcm='git checkout master'
+# long listing
 alias ll='ls -alh'
 `
 	if string(aliasContent) != expectedAliasContentBash {
@@ -125,6 +127,54 @@ echo "Hello from myfunc $1"
 	}
 }
 
+func TestGeneratedConfigsInSync(t *testing.T) {
+	cfg := createTestConfigForShellGen()
+	originalGetRalphGeneratedDir := GetRalphGeneratedDir
+	generatedDirForTest := filepath.Join(t.TempDir(), "ralph_generated_in_sync")
+	GetRalphGeneratedDir = func() (string, error) { return generatedDirForTest, nil }
+	defer func() { GetRalphGeneratedDir = originalGetRalphGeneratedDir }()
+
+	if ok, err := GeneratedConfigsInSync(cfg, Bash); err != nil || ok {
+		t.Fatalf("before generating = (%v, %v), want (false, nil)", ok, err)
+	}
+	if _, _, err := GenerateShellConfigs(io.Discard, cfg, Bash, false); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := GeneratedConfigsInSync(cfg, Bash); err != nil || !ok {
+		t.Fatalf("after generating = (%v, %v), want (true, nil)", ok, err)
+	}
+	if ok, _ := GeneratedConfigsInSync(cfg, Fish); ok {
+		t.Error("fish functions differ from the generated bash functions")
+	}
+
+	cfg.Shell.Aliases["gs"] = config.ShellAlias{Command: "git status"}
+	if ok, _ := GeneratedConfigsInSync(cfg, Bash); ok {
+		t.Error("expected a new alias to put the generated files out of sync")
+	}
+}
+
+func TestStaleGeneratedConfigs(t *testing.T) {
+	cfg := createTestConfigForShellGen()
+	originalGetRalphGeneratedDir := GetRalphGeneratedDir
+	generatedDirForTest := filepath.Join(t.TempDir(), "ralph_generated_stale")
+	GetRalphGeneratedDir = func() (string, error) { return generatedDirForTest, nil }
+	defer func() { GetRalphGeneratedDir = originalGetRalphGeneratedDir }()
+
+	stale, err := StaleGeneratedConfigs(cfg, Bash)
+	if err != nil || !reflect.DeepEqual(stale, []string{GeneratedAliasesFilename, GeneratedFunctionsFilename}) {
+		t.Fatalf("before generating = (%v, %v), want both files", stale, err)
+	}
+	if _, _, err := GenerateShellConfigs(io.Discard, cfg, Bash, false); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg.Shell.Aliases["gs"] = config.ShellAlias{Command: "git status"}
+	stale, err = StaleGeneratedConfigs(cfg, Bash)
+	if err != nil || !reflect.DeepEqual(stale, []string{GeneratedAliasesFilename}) {
+		t.Errorf("after adding an alias = (%v, %v), want only the aliases file", stale, err)
+	}
+}
+
 func TestGenerateShellConfigs_ActualWrite_Fish(t *testing.T) {
 	cfg := createTestConfigForShellGen()
 	tempDir := t.TempDir()
@@ -146,12 +196,17 @@ func TestGenerateShellConfigs_ActualWrite_Fish(t *testing.T) {
 # Ralph generated aliases - DO NOT EDIT MANUALLY
 
 alias gcm='git checkout master'
+# long listing
 alias ll='ls -alh'
 `
 	if string(aliasContent) != expectedAliasContentFish {
 		t.Errorf("Fish alias file content mismatch.\nGot:\n%s\nWant:\n%s", string(aliasContent), expectedAliasContentFish)
 	}
 
+	if filepath.Base(funcPath) != GeneratedFishFunctionsFilename {
+		t.Errorf("fish functions written to %s, want %s", funcPath, GeneratedFishFunctionsFilename)
+	}
+
 	// Verify function file content (Fish)
 	funcContent, _ := os.ReadFile(funcPath)
 	// Expected functions sorted alphabetically: another, myfunc
@@ -0,0 +1,153 @@
+package shell
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mad01/ralph/internal/config"
+)
+
+// HelpEntry is a managed alias or function as listed by 'ralph shell list'.
+type HelpEntry struct {
+	Kind        string // config.KindAlias or config.KindFunction
+	Name        string
+	Command     string // Aliases only
+	Description string
+}
+
+// HelpGroup holds the entries defined in one place: a recipe, or the main
+// config when Recipe is empty.
+type HelpGroup struct {
+	Recipe  string
+	Path    string // Recipe file relative to the dotfiles repo
+	Entries []HelpEntry
+}
+
+// Help returns the aliases and functions enabled on currentHost, grouped by
+// the recipe that defines them. The main config comes first, then recipes
+// by name; entries are sorted by name, aliases before functions.
+func Help(cfg *config.Config, currentHost string) []HelpGroup {
+	groups := make(map[string]*HelpGroup)
+	add := func(entry HelpEntry) {
+		origin := config.OriginOf(cfg, entry.Kind, entry.Name)
+		g, ok := groups[origin.Recipe]
+		if !ok {
+			g = &HelpGroup{Recipe: origin.Recipe, Path: origin.Path}
+			groups[origin.Recipe] = g
+		}
+		g.Entries = append(g.Entries, entry)
+	}
+	for name, alias := range cfg.Shell.Aliases {
+		if config.IsEnabled(alias.Enable) && config.ShouldApplyForHost(alias.Hosts, currentHost) {
+			add(HelpEntry{Kind: config.KindAlias, Name: name, Command: alias.Command, Description: alias.Description})
+		}
+	}
+	for name, fn := range cfg.Shell.Functions {
+		if config.IsEnabled(fn.Enable) && config.ShouldApplyForHost(fn.Hosts, currentHost) {
+			add(HelpEntry{Kind: config.KindFunction, Name: name, Description: fn.Description})
+		}
+	}
+
+	result := make([]HelpGroup, 0, len(groups))
+	for _, g := range groups {
+		sort.Slice(g.Entries, func(i, j int) bool {
+			a, b := g.Entries[i], g.Entries[j]
+			if a.Kind != b.Kind {
+				return a.Kind == config.KindAlias
+			}
+			return a.Name < b.Name
+		})
+		result = append(result, *g)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Recipe < result[j].Recipe })
+	return result
+}
+
+// Title is the heading of the group, e.g. "recipe 'git'".
+func (g HelpGroup) Title() string {
+	if g.Recipe == "" {
+		return "main config"
+	}
+	return fmt.Sprintf("recipe '%s'", g.Recipe)
+}
+
+// Label is the entry's name as listed: functions get a "()" suffix.
+func (e HelpEntry) Label() string {
+	if e.Kind == config.KindFunction {
+		return e.Name + "()"
+	}
+	return e.Name
+}
+
+// Detail is the text listed after the label: an alias's command and the
+// description as a comment.
+func (e HelpEntry) Detail() string {
+	var parts []string
+	if e.Command != "" {
+		parts = append(parts, oneLine(e.Command))
+	}
+	if e.Description != "" {
+		parts = append(parts, "# "+oneLine(e.Description))
+	}
+	return strings.Join(parts, "  ")
+}
+
+// LabelWidth returns the width of the longest label in groups, for aligning
+// details.
+func LabelWidth(groups []HelpGroup) int {
+	width := 0
+	for _, g := range groups {
+		for _, e := range g.Entries {
+			if n := len(e.Label()); n > width {
+				width = n
+			}
+		}
+	}
+	return width
+}
+
+// FormatHelp renders groups as plain text, one heading per group and one
+// aligned line per entry.
+func FormatHelp(groups []HelpGroup) string {
+	width := LabelWidth(groups)
+	var b strings.Builder
+	for i, g := range groups {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString(g.Title() + "\n")
+		for _, e := range g.Entries {
+			b.WriteString(strings.TrimRight(fmt.Sprintf("  %-*s  %s", width, e.Label(), e.Detail()), " ") + "\n")
+		}
+	}
+	return b.String()
+}
+
+// helpFunction returns the definition of the shell.help_function function,
+// which prints FormatHelp as of the last apply, or "" if there is none.
+func helpFunction(cfg *config.Config, shellType SupportedShell, currentHost string) string {
+	name := cfg.Shell.HelpFunction
+	if name == "" {
+		return ""
+	}
+	text := FormatHelp(Help(cfg, currentHost))
+	if text == "" {
+		text = "No aliases or functions are managed by ralph.\n"
+	}
+	if shellType == Fish {
+		var b strings.Builder
+		fmt.Fprintf(&b, "function %s\n  printf '%%s\\n'", name)
+		for _, line := range strings.Split(strings.TrimSuffix(text, "\n"), "\n") {
+			b.WriteString(" '" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(line) + "'")
+		}
+		b.WriteString("\nend\n\n")
+		return b.String()
+	}
+	return fmt.Sprintf("%s() {\ncat <<'RALPH_HELP'\n%sRALPH_HELP\n}\n\n", name, text)
+}
+
+// oneLine joins the lines of s with spaces.
+func oneLine(s string) string {
+	return strings.TrimSpace(strings.ReplaceAll(s, "\n", " "))
+}
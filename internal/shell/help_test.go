@@ -0,0 +1,68 @@
+package shell
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mad01/ralph/internal/config"
+)
+
+func helpTestConfig() *config.Config {
+	disabled := false
+	return &config.Config{
+		Shell: config.ShellConfig{
+			Aliases: map[string]config.ShellAlias{
+				"ll":  {Command: "ls -alh", Description: "long listing"},
+				"g":   {Command: "git"},
+				"off": {Command: "true", Enable: &disabled},
+				"wk":  {Command: "ssh work", Hosts: []string{"work-laptop"}},
+			},
+			Functions: map[string]config.ShellFunction{
+				"mkcd": {Body: `mkdir -p "$1" && cd "$1"`, Description: "make a directory and enter it"},
+			},
+			HelpFunction: "alias-help",
+		},
+		Origins: map[string]config.ItemOrigin{
+			"alias:g": {Recipe: "git", Path: "git/recipe.toml"},
+		},
+	}
+}
+
+func TestFormatHelp(t *testing.T) {
+	got := FormatHelp(Help(helpTestConfig(), "home"))
+	want := `main config
+  ll      ls -alh  # long listing
+  mkcd()  # make a directory and enter it
+
+recipe 'git'
+  g       git
+`
+	if got != want {
+		t.Errorf("FormatHelp() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestHelpFunction(t *testing.T) {
+	cfg := helpTestConfig()
+	posix := helpFunction(cfg, Zsh, "home")
+	if !strings.HasPrefix(posix, "alias-help() {\ncat <<'RALPH_HELP'\nmain config\n") || !strings.Contains(posix, "\nRALPH_HELP\n}\n") {
+		t.Errorf("POSIX help function =\n%s", posix)
+	}
+	fish := helpFunction(cfg, Fish, "home")
+	if !strings.HasPrefix(fish, "function alias-help\n  printf '%s\\n' 'main config' ") || !strings.HasSuffix(fish, "\nend\n\n") {
+		t.Errorf("fish help function =\n%s", fish)
+	}
+
+	cfg.Shell.HelpFunction = ""
+	if got := helpFunction(cfg, Zsh, "home"); got != "" {
+		t.Errorf("help function without help_function = %q, want empty", got)
+	}
+}
+
+func TestFunctionScript_HelpOnly(t *testing.T) {
+	cfg := &config.Config{Shell: config.ShellConfig{HelpFunction: "alias-help"}}
+	script := functionScript(cfg, Bash, "home")
+	if !strings.Contains(script, "No aliases or functions are managed by ralph.") {
+		t.Errorf("functionScript() =\n%s", script)
+	}
+}
@@ -0,0 +1,138 @@
+package shell
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// runLookup runs a command that looks up the user's account and returns its
+// trimmed output. It is a variable so tests can replace it.
+var runLookup = func(name string, args ...string) (string, error) {
+	out, err := exec.Command(name, args...).Output()
+	return strings.TrimSpace(string(out)), err
+}
+
+// etcShellsPath is the list of valid login shells. It is a variable so tests
+// can replace it.
+var etcShellsPath = "/etc/shells"
+
+// LoginShell returns the login shell of the current user as recorded by the
+// system (getent passwd, or dscl on macOS), which is what new terminals and
+// ssh sessions start regardless of $SHELL.
+func LoginShell() (string, error) {
+	u, err := user.Current()
+	if err != nil {
+		return "", fmt.Errorf("could not get current user: %w", err)
+	}
+	if runtime.GOOS == "darwin" {
+		out, err := runLookup("dscl", ".", "-read", "/Users/"+u.Username, "UserShell")
+		if err != nil {
+			return "", fmt.Errorf("dscl failed to read the login shell of %s: %w", u.Username, err)
+		}
+		return parseDsclShell(out)
+	}
+	out, err := runLookup("getent", "passwd", u.Username)
+	if err != nil {
+		return "", fmt.Errorf("getent failed to read the login shell of %s: %w", u.Username, err)
+	}
+	return parsePasswdShell(out)
+}
+
+// parsePasswdShell returns the shell field of a passwd(5) entry.
+func parsePasswdShell(entry string) (string, error) {
+	fields := strings.Split(strings.TrimSpace(entry), ":")
+	if len(fields) < 7 || fields[6] == "" {
+		return "", fmt.Errorf("no login shell in passwd entry %q", entry)
+	}
+	return fields[6], nil
+}
+
+// parseDsclShell returns the value of dscl's "UserShell: /bin/zsh" output.
+func parseDsclShell(out string) (string, error) {
+	value := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(out), "UserShell:"))
+	if value == "" || value == strings.TrimSpace(out) {
+		return "", fmt.Errorf("no login shell in dscl output %q", out)
+	}
+	return value, nil
+}
+
+// ShellOf returns the supported shell a path such as /bin/zsh runs, or "" if
+// it is not one ralph manages.
+func ShellOf(path string) SupportedShell {
+	s := SupportedShell(filepath.Base(path))
+	if isSupported(s) {
+		return s
+	}
+	return ""
+}
+
+// ShellPath returns the path to use with chsh for s: the first entry of
+// /etc/shells that runs it, since chsh refuses shells not listed there, or
+// else the one on PATH.
+func ShellPath(s SupportedShell) (string, error) {
+	if f, err := os.Open(etcShellsPath); err == nil {
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			if ShellOf(line) == s {
+				return line, nil
+			}
+		}
+	}
+	path, err := exec.LookPath(string(s))
+	if err != nil {
+		return "", fmt.Errorf("%s is not installed: %w", s, err)
+	}
+	return path, nil
+}
+
+// ChangeLoginShell runs chsh to make path the login shell of the current
+// user. chsh may ask for the user's password, so it gets the terminal.
+func ChangeLoginShell(path string) error {
+	cmd := exec.Command("chsh", "-s", path)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("chsh -s %s failed: %w", path, err)
+	}
+	return nil
+}
+
+// InstalledShells returns the supported shells installed on this machine,
+// those listed in /etc/shells or found on PATH, in GetSupportedShells order.
+func InstalledShells() []SupportedShell {
+	listed := make(map[SupportedShell]bool)
+	if f, err := os.Open(etcShellsPath); err == nil {
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			if s := ShellOf(line); s != "" {
+				listed[s] = true
+			}
+		}
+	}
+	var installed []SupportedShell
+	for _, s := range GetSupportedShells() {
+		if listed[s] {
+			installed = append(installed, s)
+		} else if _, err := exec.LookPath(string(s)); err == nil {
+			installed = append(installed, s)
+		}
+	}
+	return installed
+}
@@ -0,0 +1,108 @@
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestParsePasswdShell(t *testing.T) {
+	got, err := parsePasswdShell("alice:x:1000:1000:Alice,,,:/home/alice:/usr/bin/zsh\n")
+	if err != nil || got != "/usr/bin/zsh" {
+		t.Errorf("parsePasswdShell = (%q, %v), want /usr/bin/zsh", got, err)
+	}
+	for _, entry := range []string{"", "alice:x:1000", "alice:x:1000:1000::/home/alice:"} {
+		if _, err := parsePasswdShell(entry); err == nil {
+			t.Errorf("parsePasswdShell(%q) should fail", entry)
+		}
+	}
+}
+
+func TestParseDsclShell(t *testing.T) {
+	got, err := parseDsclShell("UserShell: /bin/zsh\n")
+	if err != nil || got != "/bin/zsh" {
+		t.Errorf("parseDsclShell = (%q, %v), want /bin/zsh", got, err)
+	}
+	for _, out := range []string{"", "No such key: UserShell", "UserShell:"} {
+		if _, err := parseDsclShell(out); err == nil {
+			t.Errorf("parseDsclShell(%q) should fail", out)
+		}
+	}
+}
+
+func TestLoginShell(t *testing.T) {
+	original := runLookup
+	defer func() { runLookup = original }()
+	var called string
+	runLookup = func(name string, args ...string) (string, error) {
+		called = name
+		if name == "dscl" {
+			return "UserShell: /bin/zsh", nil
+		}
+		return "me:x:1000:1000::/home/me:/bin/zsh", nil
+	}
+
+	got, err := LoginShell()
+	if err != nil || got != "/bin/zsh" {
+		t.Fatalf("LoginShell = (%q, %v), want /bin/zsh", got, err)
+	}
+	want := "getent"
+	if runtime.GOOS == "darwin" {
+		want = "dscl"
+	}
+	if called != want {
+		t.Errorf("LoginShell ran %s, want %s", called, want)
+	}
+}
+
+func TestShellOf(t *testing.T) {
+	tests := map[string]SupportedShell{
+		"/bin/zsh":                Zsh,
+		"/opt/homebrew/bin/fish":  Fish,
+		"bash":                    Bash,
+		"/usr/bin/nu":             "",
+		"/usr/local/bin/zsh-5.9 ": "",
+	}
+	for path, want := range tests {
+		if got := ShellOf(path); got != want {
+			t.Errorf("ShellOf(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestShellPath(t *testing.T) {
+	original := etcShellsPath
+	defer func() { etcShellsPath = original }()
+	etcShellsPath = filepath.Join(t.TempDir(), "shells")
+	os.WriteFile(etcShellsPath, []byte("# valid login shells\n/bin/sh\n/usr/local/bin/fish\n/bin/fish\n"), 0644)
+
+	got, err := ShellPath(Fish)
+	if err != nil || got != "/usr/local/bin/fish" {
+		t.Errorf("ShellPath(fish) = (%q, %v), want the first /etc/shells entry", got, err)
+	}
+
+	t.Setenv("PATH", t.TempDir())
+	if _, err := ShellPath(Zsh); err == nil {
+		t.Error("ShellPath should fail for a shell neither in /etc/shells nor on PATH")
+	}
+}
+
+func TestInstalledShells(t *testing.T) {
+	original := etcShellsPath
+	defer func() { etcShellsPath = original }()
+	etcShellsPath = filepath.Join(t.TempDir(), "shells")
+	os.WriteFile(etcShellsPath, []byte("# valid login shells\n/bin/sh\n/usr/local/bin/fish\n"), 0644)
+
+	bin := t.TempDir()
+	os.WriteFile(filepath.Join(bin, "bash"), []byte("#!/bin/sh\n"), 0755)
+	t.Setenv("PATH", bin)
+
+	got := InstalledShells()
+	if len(got) != 2 || got[0] != Bash || got[1] != Fish {
+		t.Errorf("InstalledShells() = %v, want [bash fish]", got)
+	}
+	if got := WithInstalled([]SupportedShell{Fish, Zsh}); len(got) != 3 || got[0] != Fish || got[1] != Zsh || got[2] != Bash {
+		t.Errorf("WithInstalled([fish zsh]) = %v, want [fish zsh bash]", got)
+	}
+}
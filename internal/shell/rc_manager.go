@@ -232,6 +232,84 @@ func ensureRalphBlock(lines []string, contentLines []string) ([]string, bool) {
 	}
 }
 
+// SourceLinesInSync reports whether the ralph managed block of the shell's rc
+// file already contains exactly the given lines, i.e. whether InjectSourceLines
+// would leave the file unchanged.
+func SourceLinesInSync(shell SupportedShell, additionalLines []string) (bool, error) {
+	rcFilePath, err := GetRCFilePath(shell)
+	if err != nil {
+		return false, fmt.Errorf("cannot get RC file path for %s: %w", shell, err)
+	}
+	fileContent, err := os.ReadFile(rcFilePath)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("failed to read rc file %s: %w", rcFilePath, err)
+	}
+	_, modified := ensureRalphBlock(strings.Split(string(fileContent), "\n"), additionalLines)
+	return !modified, nil
+}
+
+// RemoveRalphBlock removes the ralph managed block (including legacy dotter
+// blocks) from the rc file of the given shell. A missing rc file or block is
+// not an error. It reports whether a block was found.
+// If dryRun is true, it prints what it would do instead of modifying the file.
+func RemoveRalphBlock(w io.Writer, shell SupportedShell, dryRun bool) (bool, error) {
+	rcFilePath, err := GetRCFilePath(shell)
+	if err != nil {
+		return false, fmt.Errorf("cannot get RC file path for %s: %w", shell, err)
+	}
+
+	fileContent, err := os.ReadFile(rcFilePath)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("failed to read rc file %s: %w", rcFilePath, err)
+	}
+
+	newLines, found := removeRalphBlock(strings.Split(string(fileContent), "\n"))
+	if !found {
+		return false, nil
+	}
+
+	output := strings.Join(newLines, "\n")
+	if output != "" && !strings.HasSuffix(output, "\n") {
+		output += "\n"
+	}
+	if dryRun {
+		fmt.Fprintf(w, "[DRY RUN] Would remove managed block from rc file: %s\n", rcFilePath)
+		return true, nil
+	}
+	fmt.Fprintf(w, "Removing managed block from rc file: %s\n", rcFilePath)
+	if err := os.WriteFile(rcFilePath, []byte(output), 0644); err != nil {
+		return true, fmt.Errorf("failed to write updated rc file %s: %w", rcFilePath, err)
+	}
+	return true, nil
+}
+
+// removeRalphBlock drops every managed block (current or legacy markers) from
+// lines, along with the blank line that separated it from preceding content.
+// An unterminated block is removed to the end of the file.
+func removeRalphBlock(lines []string) ([]string, bool) {
+	var out []string
+	found, inBlock := false, false
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == RalphBlockBeginMarker || trimmed == legacyBlockBeginMarker:
+			inBlock, found = true, true
+			if len(out) > 0 && strings.TrimSpace(out[len(out)-1]) == "" {
+				out = out[:len(out)-1]
+			}
+		case inBlock && (trimmed == RalphBlockEndMarker || trimmed == legacyBlockEndMarker):
+			inBlock = false
+		case !inBlock:
+			out = append(out, line)
+		}
+	}
+	return out, found
+}
+
 // GetSupportedShells returns a slice of shells ralph explicitly supports for RC file management.
 func GetSupportedShells() []SupportedShell {
 	return []SupportedShell{Bash, Zsh, Fish}
@@ -264,6 +342,45 @@ func ResolveShell(configShellName string) []SupportedShell {
 	return GetSupportedShells()
 }
 
+// ResolveShells determines the shells apply configures: every shell in
+// names (shell.names) if any are listed, else the one ResolveShell finds for
+// name. ok is false when no shell could be determined and ResolveShell fell
+// back to all supported shells.
+func ResolveShells(names []string, name string) (shells []SupportedShell, ok bool) {
+	for _, n := range names {
+		if s := SupportedShell(n); isSupported(s) {
+			shells = append(shells, s)
+		}
+	}
+	if len(shells) > 0 {
+		return shells, true
+	}
+	shells = ResolveShell(name)
+	return shells, len(shells) == 1
+}
+
+// WithInstalled returns shells followed by the installed shells (see
+// InstalledShells) not among them, for shell.detect.
+func WithInstalled(shells []SupportedShell) []SupportedShell {
+	all := append([]SupportedShell(nil), shells...)
+	for _, s := range InstalledShells() {
+		if !ContainsShell(all, s) {
+			all = append(all, s)
+		}
+	}
+	return all
+}
+
+// ContainsShell reports whether shells includes s.
+func ContainsShell(shells []SupportedShell, s SupportedShell) bool {
+	for _, sh := range shells {
+		if sh == s {
+			return true
+		}
+	}
+	return false
+}
+
 // AutoDetectShell attempts to determine the current shell from environment variables.
 // This is a basic detection and might not be exhaustive.
 func AutoDetectShell() SupportedShell {
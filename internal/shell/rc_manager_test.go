@@ -2,6 +2,7 @@ package shell
 
 import (
 	"bytes"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -182,5 +183,92 @@ func TestInjectSourceLines_DryRun_NoFile(t *testing.T) {
 	}
 }
 
+func TestSourceLinesInSync(t *testing.T) {
+	tempDir := t.TempDir()
+	origHome, homeWasSet := os.LookupEnv("HOME")
+	setEnvVar(t, "HOME", tempDir)
+	defer unsetEnvVar(t, "HOME", origHome, homeWasSet)
+
+	lines := []string{"source /path/to/aliases.sh"}
+	if ok, err := SourceLinesInSync(Bash, lines); err != nil || ok {
+		t.Fatalf("missing rc file = (%v, %v), want (false, nil)", ok, err)
+	}
+	if err := InjectSourceLines(io.Discard, Bash, lines, false); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := SourceLinesInSync(Bash, lines); err != nil || !ok {
+		t.Fatalf("after injecting = (%v, %v), want (true, nil)", ok, err)
+	}
+	if ok, _ := SourceLinesInSync(Bash, append(lines, "source /path/to/functions.sh")); ok {
+		t.Error("expected an additional line to put the rc file out of sync")
+	}
+}
+
 // More tests for InjectSourceLines (non-dry run, existing files, existing blocks, etc.)
 // would go here. These require more complex file setup and content verification.
+
+func TestRemoveRalphBlock(t *testing.T) {
+	tempDir := t.TempDir()
+	origHome, homeWasSet := os.LookupEnv("HOME")
+	setEnvVar(t, "HOME", tempDir)
+	defer unsetEnvVar(t, "HOME", origHome, homeWasSet)
+
+	rcFilePath := filepath.Join(tempDir, ".bashrc")
+	content := "export PATH=$HOME/bin:$PATH\n\n" +
+		RalphBlockBeginMarker + "\nsource ~/.config/ralph/generated/aliases.sh\n" + RalphBlockEndMarker + "\n" +
+		"alias ll='ls -l'\n"
+	if err := os.WriteFile(rcFilePath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	found, err := RemoveRalphBlock(&buf, Bash, true)
+	if err != nil || !found {
+		t.Fatalf("RemoveRalphBlock dry run = (%v, %v), want (true, nil)", found, err)
+	}
+	if got, _ := os.ReadFile(rcFilePath); string(got) != content {
+		t.Error("RemoveRalphBlock dry run modified the rc file")
+	}
+
+	found, err = RemoveRalphBlock(&buf, Bash, false)
+	if err != nil || !found {
+		t.Fatalf("RemoveRalphBlock = (%v, %v), want (true, nil)", found, err)
+	}
+	want := "export PATH=$HOME/bin:$PATH\nalias ll='ls -l'\n"
+	if got, _ := os.ReadFile(rcFilePath); string(got) != want {
+		t.Errorf("rc file after removal = %q, want %q", got, want)
+	}
+
+	found, err = RemoveRalphBlock(&buf, Bash, false)
+	if err != nil || found {
+		t.Errorf("second RemoveRalphBlock = (%v, %v), want (false, nil)", found, err)
+	}
+}
+
+func TestRemoveRalphBlock_Legacy(t *testing.T) {
+	lines := []string{"a", legacyBlockBeginMarker, "source x", legacyBlockEndMarker, "b"}
+	got, found := removeRalphBlock(lines)
+	if !found {
+		t.Fatal("expected legacy block to be found")
+	}
+	if strings.Join(got, "\n") != "a\nb" {
+		t.Errorf("removeRalphBlock = %q", got)
+	}
+}
+
+func TestResolveShells(t *testing.T) {
+	t.Setenv("SHELL", "/bin/zsh")
+
+	shells, ok := ResolveShells([]string{"zsh", "fish"}, "bash")
+	if !ok || len(shells) != 2 || shells[0] != Zsh || shells[1] != Fish {
+		t.Errorf("ResolveShells(names) = %v, %v; want [zsh fish], true", shells, ok)
+	}
+	shells, ok = ResolveShells(nil, "bash")
+	if !ok || len(shells) != 1 || shells[0] != Bash {
+		t.Errorf("ResolveShells(name) = %v, %v; want [bash], true", shells, ok)
+	}
+	t.Setenv("SHELL", "")
+	if shells, ok = ResolveShells(nil, ""); ok || len(shells) != len(GetSupportedShells()) {
+		t.Errorf("ResolveShells() without a shell = %v, %v; want all shells, false", shells, ok)
+	}
+}
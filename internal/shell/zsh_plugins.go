@@ -0,0 +1,63 @@
+package shell
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mad01/ralph/internal/config"
+)
+
+// zshPluginPatterns are tried in order to find the file of a plugin without
+// source; {name} is the plugin name. These are the usual plugin and prompt
+// theme layouts (oh-my-zsh, prezto, antidote).
+var zshPluginPatterns = []string{"{name}.plugin.zsh", "*.plugin.zsh", "{name}.zsh-theme", "*.zsh-theme", "init.zsh", "{name}.zsh", "*.zsh"}
+
+// ZshPluginFiles returns the files to source for the [shell.zsh.plugins]
+// enabled on currentHost, in load order. A plugin whose repo is disabled or
+// filtered out is left out as well. For a checkout that is not there yet
+// (e.g. in a dry run before the clone) the source, or <name>.plugin.zsh, is
+// assumed.
+func ZshPluginFiles(cfg *config.Config, currentHost string) ([]string, error) {
+	var files []string
+	for _, name := range config.ZshPluginNames(cfg) {
+		plugin := cfg.Shell.Zsh.Plugins[name]
+		if !config.IsEnabled(plugin.Enable) || !config.ShouldApplyForHost(plugin.Hosts, currentHost) {
+			continue
+		}
+		repoName, rp, ok := config.ZshPluginRepo(cfg, name)
+		if !ok {
+			return nil, fmt.Errorf("plugin '%s': repo '%s' is not defined", name, repoName)
+		}
+		if !config.IsEnabled(rp.Enable) || !config.ShouldApplyForHost(rp.Hosts, currentHost) {
+			continue
+		}
+		dir, err := config.ExpandPath(rp.Target)
+		if err != nil {
+			return nil, fmt.Errorf("plugin '%s': %w", name, err)
+		}
+		file, err := zshPluginFile(dir, name, plugin.Source)
+		if err != nil {
+			return nil, fmt.Errorf("plugin '%s': %w", name, err)
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// zshPluginFile returns the file to source from a plugin checkout in dir.
+func zshPluginFile(dir, name, source string) (string, error) {
+	if source != "" {
+		return filepath.Join(dir, source), nil
+	}
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return filepath.Join(dir, name+".plugin.zsh"), nil
+	}
+	for _, pattern := range zshPluginPatterns {
+		if matches, _ := filepath.Glob(filepath.Join(dir, strings.ReplaceAll(pattern, "{name}", name))); len(matches) > 0 {
+			return matches[0], nil
+		}
+	}
+	return "", fmt.Errorf("no plugin file found in %s; set source", dir)
+}
@@ -0,0 +1,63 @@
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/mad01/ralph/internal/config"
+)
+
+func TestZshPluginFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, f := range []string{
+		"zsh-autosuggestions/zsh-autosuggestions.plugin.zsh",
+		"zsh-autosuggestions/zsh-autosuggestions.zsh",
+		"p10k/powerlevel10k.zsh-theme",
+		"fsh/fast-syntax-highlighting.plugin.zsh",
+		"custom/load.zsh",
+	} {
+		os.MkdirAll(filepath.Join(dir, filepath.Dir(f)), 0755)
+		os.WriteFile(filepath.Join(dir, f), []byte("# plugin\n"), 0644)
+	}
+	disabled := false
+	cfg := &config.Config{
+		Repos: map[string]config.Repo{"p10k": {URL: "https://example.com/p10k", Target: filepath.Join(dir, "p10k")}},
+		Shell: config.ShellConfig{Zsh: config.ZshConfig{
+			PluginsDir: dir,
+			Plugins: map[string]config.ZshPlugin{
+				"zsh-autosuggestions": {URL: "https://example.com/zsh-autosuggestions"},
+				"powerlevel10k":       {Repo: "p10k", Order: -1},
+				"fsh":                 {URL: "https://example.com/fsh", Order: 10},
+				"custom":              {URL: "https://example.com/custom", Source: "load.zsh", Order: 5},
+				"work":                {URL: "https://example.com/work", Hosts: []string{"work"}},
+				"off":                 {URL: "https://example.com/off", Enable: &disabled},
+				"not-cloned":          {URL: "https://example.com/not-cloned", Order: 20},
+			},
+		}},
+	}
+	if err := config.ApplyZshPlugins(cfg); err != nil {
+		t.Fatalf("ApplyZshPlugins failed: %v", err)
+	}
+
+	files, err := ZshPluginFiles(cfg, "laptop")
+	if err != nil {
+		t.Fatalf("ZshPluginFiles failed: %v", err)
+	}
+	want := []string{
+		filepath.Join(dir, "p10k/powerlevel10k.zsh-theme"),
+		filepath.Join(dir, "zsh-autosuggestions/zsh-autosuggestions.plugin.zsh"),
+		filepath.Join(dir, "custom/load.zsh"),
+		filepath.Join(dir, "fsh/fast-syntax-highlighting.plugin.zsh"),
+		filepath.Join(dir, "not-cloned/not-cloned.plugin.zsh"),
+	}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("ZshPluginFiles() =\n%v\nwant\n%v", files, want)
+	}
+
+	os.MkdirAll(filepath.Join(dir, "work"), 0755)
+	if _, err := ZshPluginFiles(cfg, "work"); err == nil {
+		t.Error("expected an error for a checkout without a plugin file")
+	}
+}
@@ -0,0 +1,96 @@
+package tool
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/mad01/ralph/internal/config"
+)
+
+// CacheTTL is how long a successful check_command result is reused.
+const CacheTTL = 5 * time.Minute
+
+// cacheFileName is the name of the tool check cache in the state directory.
+const cacheFileName = "tool_cache"
+
+// Checker runs check_commands, reusing recent successful results from the
+// tool check cache in the state directory. Failed checks are never cached,
+// so a tool shows up as installed as soon as it is.
+type Checker struct {
+	mu      sync.Mutex
+	path    string
+	refresh bool
+	entries map[string]time.Time // check_command -> when it last succeeded
+	dirty   bool
+}
+
+// NewChecker loads the tool check cache. With refresh set, cached results are
+// ignored and every check runs again (the cache is still updated). A missing
+// or unreadable cache starts out empty.
+func NewChecker(refresh bool) *Checker {
+	c := &Checker{refresh: refresh, entries: make(map[string]time.Time)}
+	path, err := config.StateFilePath(cacheFileName)
+	if err != nil {
+		return c
+	}
+	c.path = path
+	if data, err := os.ReadFile(path); err == nil {
+		json.Unmarshal(data, &c.entries)
+	}
+	return c
+}
+
+// Installed reports whether checkCommand succeeds, running it only when there
+// is no fresh cached success.
+func (c *Checker) Installed(checkCommand string) bool {
+	c.mu.Lock()
+	checkedAt, cached := c.entries[checkCommand]
+	c.mu.Unlock()
+	if cached && !c.refresh && time.Since(checkedAt) < CacheTTL {
+		return true
+	}
+
+	installed := CheckStatus(checkCommand)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if installed {
+		c.entries[checkCommand] = time.Now()
+		c.dirty = true
+	} else if cached {
+		delete(c.entries, checkCommand)
+		c.dirty = true
+	}
+	return installed
+}
+
+// Save writes the cache back to the state directory if it changed, dropping
+// expired entries.
+func (c *Checker) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.dirty || c.path == "" {
+		return nil
+	}
+	for command, checkedAt := range c.entries {
+		if time.Since(checkedAt) >= CacheTTL {
+			delete(c.entries, command)
+		}
+	}
+	data, err := json.MarshalIndent(c.entries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal tool cache: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+	if err := os.WriteFile(c.path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write tool cache: %w", err)
+	}
+	c.dirty = false
+	return nil
+}
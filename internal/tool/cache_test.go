@@ -0,0 +1,68 @@
+package tool
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestChecker_CachesSuccessfulChecks(t *testing.T) {
+	t.Setenv("RALPH_STATE_DIR", t.TempDir())
+	marker := filepath.Join(t.TempDir(), "installed")
+	command := "test -e " + marker
+
+	if NewChecker(false).Installed(command) {
+		t.Fatal("check should fail before the marker exists")
+	}
+
+	if err := os.WriteFile(marker, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	c := NewChecker(false)
+	if !c.Installed(command) {
+		t.Fatal("failed checks must not be cached")
+	}
+	if err := c.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	if err := os.Remove(marker); err != nil {
+		t.Fatal(err)
+	}
+	if !NewChecker(false).Installed(command) {
+		t.Error("expected the cached success to be reused")
+	}
+	if NewChecker(true).Installed(command) {
+		t.Error("refresh should bypass the cache")
+	}
+}
+
+func TestChecker_RefreshDropsFailedEntries(t *testing.T) {
+	t.Setenv("RALPH_STATE_DIR", t.TempDir())
+	marker := filepath.Join(t.TempDir(), "installed")
+	command := "test -e " + marker
+	if err := os.WriteFile(marker, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewChecker(false)
+	c.Installed(command)
+	if err := c.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	if err := os.Remove(marker); err != nil {
+		t.Fatal(err)
+	}
+	c = NewChecker(true)
+	if c.Installed(command) {
+		t.Fatal("refreshed check should fail")
+	}
+	if err := c.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	if NewChecker(false).Installed(command) {
+		t.Error("a failed refresh should remove the cached success")
+	}
+}
@@ -0,0 +1,56 @@
+package tool
+
+import (
+	"context"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/mad01/ralph/internal/config"
+)
+
+// VersionTimeout bounds how long a version_command may run.
+const VersionTimeout = 10 * time.Second
+
+// versionPattern matches a dotted version number such as "1.17.1".
+var versionPattern = regexp.MustCompile(`\d+(\.\d+)+`)
+
+// Version runs versionCommand and returns the first dotted version number in
+// its output, e.g. "1.17.1" from "starship 1.17.1", or else its first line.
+// It returns "" if the command is empty or fails.
+func Version(versionCommand string) string {
+	if strings.TrimSpace(versionCommand) == "" {
+		return ""
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), VersionTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, "sh", "-c", versionCommand).Output()
+	if err != nil {
+		return ""
+	}
+	return parseVersion(string(out))
+}
+
+func parseVersion(out string) string {
+	if v := versionPattern.FindString(out); v != "" {
+		return v
+	}
+	line, _, _ := strings.Cut(strings.TrimSpace(out), "\n")
+	return strings.TrimSpace(line)
+}
+
+// TemplateData returns the variables a config file template of t can use:
+// .ToolName, .ToolInstalled and .ToolVersion (from version_command, "" when
+// the tool is not installed).
+func TemplateData(t config.Tool, installed bool) map[string]interface{} {
+	version := ""
+	if installed {
+		version = Version(t.VersionCommand)
+	}
+	return map[string]interface{}{
+		"ToolName":      t.Name,
+		"ToolInstalled": installed,
+		"ToolVersion":   version,
+	}
+}
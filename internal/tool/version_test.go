@@ -0,0 +1,36 @@
+package tool
+
+import (
+	"testing"
+
+	"github.com/mad01/ralph/internal/config"
+)
+
+func TestParseVersion(t *testing.T) {
+	tests := map[string]string{
+		"starship 1.17.1\ntokio-rt 1.0\n":  "1.17.1",
+		"NVIM v0.9.5\nBuild type: Release": "0.9.5",
+		"fzf devel\n":                      "fzf devel",
+		"":                                 "",
+	}
+	for out, want := range tests {
+		if got := parseVersion(out); got != want {
+			t.Errorf("parseVersion(%q) = %q, want %q", out, got, want)
+		}
+	}
+}
+
+func TestTemplateData(t *testing.T) {
+	tl := config.Tool{Name: "starship", VersionCommand: "echo starship 1.17.1"}
+	data := TemplateData(tl, true)
+	if data["ToolInstalled"] != true || data["ToolVersion"] != "1.17.1" || data["ToolName"] != "starship" {
+		t.Errorf("TemplateData(installed) = %v", data)
+	}
+	data = TemplateData(tl, false)
+	if data["ToolInstalled"] != false || data["ToolVersion"] != "" {
+		t.Errorf("TemplateData(not installed) = %v, want no version", data)
+	}
+	if got := Version("exit 1"); got != "" {
+		t.Errorf("Version(failing command) = %q, want empty", got)
+	}
+}
@@ -0,0 +1,100 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/mad01/ralph/internal/config"
+	"github.com/mad01/ralph/internal/hooks"
+	"github.com/mad01/ralph/internal/repo"
+)
+
+// ApplyFunc deploys the named dotfile the way apply does, writing its
+// output to w. The dashboard is given one so it shares apply's code path.
+type ApplyFunc func(w io.Writer, cfg *config.Config, name string) error
+
+// UnlinkDotfile removes the target of a dotfile if it is a symlink.
+// Regular files and directories are never removed.
+func UnlinkDotfile(w io.Writer, cfg *config.Config, name string) error {
+	df, ok := cfg.Dotfiles[name]
+	if !ok {
+		return fmt.Errorf("dotfile '%s' not found in configuration", name)
+	}
+	absoluteTarget, err := config.ExpandPath(df.Target)
+	if err != nil {
+		return fmt.Errorf("failed to expand target path '%s': %w", df.Target, err)
+	}
+	info, err := os.Lstat(absoluteTarget)
+	if os.IsNotExist(err) {
+		fmt.Fprintf(w, "%s is not linked\n", config.ShortenHome(absoluteTarget))
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to stat target '%s': %w", absoluteTarget, err)
+	}
+	if info.Mode()&os.ModeSymlink == 0 {
+		return fmt.Errorf("target '%s' is not a symlink, refusing to remove it", absoluteTarget)
+	}
+	if err := os.Remove(absoluteTarget); err != nil {
+		return fmt.Errorf("failed to remove symlink '%s': %w", absoluteTarget, err)
+	}
+	fmt.Fprintf(w, "unlinked %s\n", config.ShortenHome(absoluteTarget))
+	return nil
+}
+
+// DiffDotfile writes a unified diff between the target on disk and the source
+// in the dotfiles repository.
+func DiffDotfile(w io.Writer, cfg *config.Config, name string) error {
+	df, ok := cfg.Dotfiles[name]
+	if !ok {
+		return fmt.Errorf("dotfile '%s' not found in configuration", name)
+	}
+	absoluteSource, err := config.ExpandPath(filepath.Join(cfg.DotfilesRepoPath, df.Source))
+	if err != nil {
+		return fmt.Errorf("failed to expand source path '%s': %w", df.Source, err)
+	}
+	absoluteTarget, err := config.ExpandPath(df.Target)
+	if err != nil {
+		return fmt.Errorf("failed to expand target path '%s': %w", df.Target, err)
+	}
+	if _, err := os.Stat(absoluteTarget); os.IsNotExist(err) {
+		fmt.Fprintf(w, "%s does not exist\n", config.ShortenHome(absoluteTarget))
+		return nil
+	}
+
+	cmd := exec.Command("diff", "-ru", absoluteTarget, absoluteSource)
+	cmd.Stdout = w
+	cmd.Stderr = w
+	if err := cmd.Run(); err != nil {
+		// diff exits 1 when the files differ, which is not an error here.
+		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
+			return nil
+		}
+		return fmt.Errorf("diff failed: %w", err)
+	}
+	fmt.Fprintln(w, "no differences")
+	return nil
+}
+
+// RunBuild forces a single build to run, streaming its output to w.
+func RunBuild(w io.Writer, cfg *config.Config, name, currentHost string) error {
+	build, ok := cfg.Hooks.Builds[name]
+	if !ok {
+		return fmt.Errorf("build '%s' not found in configuration", name)
+	}
+	opts := hooks.BuildOptions{Force: true, SpecificBuild: name, Stream: true}
+	return hooks.RunBuild(context.Background(), w, name, build, currentHost, opts)
+}
+
+// SyncRepo clones or updates a single repository.
+func SyncRepo(w io.Writer, cfg *config.Config, name string) error {
+	rp, ok := cfg.Repos[name]
+	if !ok {
+		return fmt.Errorf("repo '%s' not found in configuration", name)
+	}
+	return repo.CloneOrUpdateRepo(context.Background(), w, name, rp, false)
+}
@@ -0,0 +1,242 @@
+package ui
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/fatih/color"
+	"github.com/mad01/ralph/internal/config"
+)
+
+// logRefreshInterval is how often the log pane is redrawn while an action runs.
+const logRefreshInterval = 200 * time.Millisecond
+
+// logBuffer collects action output so the dashboard can tail it while the
+// action is still running.
+type logBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *logBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *logBuffer) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf.Reset()
+}
+
+// Tail returns at most n trailing lines of the buffer.
+func (b *logBuffer) Tail(n int) []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	text := strings.TrimRight(strings.ReplaceAll(b.buf.String(), "\r", ""), "\n")
+	if text == "" {
+		return nil
+	}
+	lines := strings.Split(text, "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return lines
+}
+
+type itemsMsg []Item
+type actionDoneMsg struct{ err error }
+type tickMsg struct{}
+
+// Model is the bubbletea model for the dashboard.
+type Model struct {
+	cfg          *config.Config
+	currentHost  string
+	applyDotfile ApplyFunc
+	items        []Item
+	cursor       int
+	log          *logBuffer
+	logTitle     string
+	running      bool
+	height       int
+}
+
+// New creates a dashboard model for cfg that applies dotfiles with
+// applyDotfile.
+func New(cfg *config.Config, currentHost string, applyDotfile ApplyFunc) Model {
+	return Model{
+		cfg:          cfg,
+		currentHost:  currentHost,
+		applyDotfile: applyDotfile,
+		items:        CollectItems(cfg, currentHost),
+		log:          &logBuffer{},
+		height:       24,
+	}
+}
+
+// Run starts the dashboard on the terminal's alternate screen.
+func Run(cfg *config.Config, currentHost string, applyDotfile ApplyFunc) error {
+	_, err := tea.NewProgram(New(cfg, currentHost, applyDotfile), tea.WithAltScreen()).Run()
+	return err
+}
+
+func (m Model) Init() tea.Cmd {
+	return nil
+}
+
+func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.height = msg.Height
+	case itemsMsg:
+		m.items = msg
+		if m.cursor >= len(m.items) {
+			m.cursor = len(m.items) - 1
+		}
+		if m.cursor < 0 {
+			m.cursor = 0
+		}
+	case tickMsg:
+		if m.running {
+			return m, tick()
+		}
+	case actionDoneMsg:
+		m.running = false
+		if msg.err != nil {
+			fmt.Fprintln(m.log, color.RedString("error: %v", msg.err))
+		}
+		return m, m.refresh()
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "q", "ctrl+c":
+			return m, tea.Quit
+		case "up", "k":
+			if m.cursor > 0 {
+				m.cursor--
+			}
+		case "down", "j":
+			if m.cursor < len(m.items)-1 {
+				m.cursor++
+			}
+		case "r":
+			return m, m.refresh()
+		case "a", "u", "d":
+			return m.startAction(msg.String())
+		}
+	}
+	return m, nil
+}
+
+// startAction launches the action bound to key for the selected item.
+func (m Model) startAction(key string) (tea.Model, tea.Cmd) {
+	if m.running || len(m.items) == 0 {
+		return m, nil
+	}
+	item := m.items[m.cursor]
+
+	var action func(io.Writer) error
+	var verb string
+	switch {
+	case item.Kind == KindDotfile && key == "a":
+		verb, action = "apply", func(w io.Writer) error { return m.applyDotfile(w, m.cfg, item.Name) }
+	case item.Kind == KindDotfile && key == "u":
+		verb, action = "unlink", func(w io.Writer) error { return UnlinkDotfile(w, m.cfg, item.Name) }
+	case item.Kind == KindDotfile && key == "d":
+		verb, action = "diff", func(w io.Writer) error { return DiffDotfile(w, m.cfg, item.Name) }
+	case item.Kind == KindBuild && key == "a":
+		verb, action = "build", func(w io.Writer) error { return RunBuild(w, m.cfg, item.Name, m.currentHost) }
+	case item.Kind == KindRepo && key == "a":
+		verb, action = "sync", func(w io.Writer) error { return SyncRepo(w, m.cfg, item.Name) }
+	default:
+		return m, nil
+	}
+
+	m.running = true
+	m.logTitle = fmt.Sprintf("%s %s", verb, item.Name)
+	m.log.Reset()
+	w := m.log
+	return m, tea.Batch(func() tea.Msg {
+		return actionDoneMsg{err: action(w)}
+	}, tick())
+}
+
+func (m Model) refresh() tea.Cmd {
+	cfg, host := m.cfg, m.currentHost
+	return func() tea.Msg {
+		return itemsMsg(CollectItems(cfg, host))
+	}
+}
+
+func tick() tea.Cmd {
+	return tea.Tick(logRefreshInterval, func(time.Time) tea.Msg { return tickMsg{} })
+}
+
+func (m Model) View() string {
+	var b strings.Builder
+	bold := color.New(color.Bold).SprintFunc()
+	dim := color.New(color.Faint).SprintFunc()
+
+	fmt.Fprintf(&b, "%s %s\n\n", bold("ralph ui"), dim("host: "+m.currentHost))
+
+	var lastKind ItemKind
+	for i, item := range m.items {
+		if item.Kind != lastKind {
+			if lastKind != "" {
+				b.WriteString("\n")
+			}
+			fmt.Fprintf(&b, "%s\n", bold(strings.ToUpper(string(item.Kind))+"S"))
+			lastKind = item.Kind
+		}
+		cursor := "  "
+		if i == m.cursor {
+			cursor = color.CyanString("> ")
+		}
+		fmt.Fprintf(&b, "%s%-24s %s %s\n", cursor, item.Name, stateColor(item.State)("%s", item.Status), dim(item.Detail))
+	}
+	if len(m.items) == 0 {
+		b.WriteString(color.YellowString("No managed items configured.\n"))
+	}
+
+	if m.logTitle != "" {
+		status := "done"
+		if m.running {
+			status = "running"
+		}
+		fmt.Fprintf(&b, "\n%s %s\n", bold(m.logTitle), dim("("+status+")"))
+		for _, line := range m.log.Tail(m.logLines()) {
+			fmt.Fprintf(&b, "  %s\n", line)
+		}
+	}
+
+	fmt.Fprintf(&b, "\n%s\n", dim("↑/↓ move • a apply/build/sync • u unlink • d diff • r refresh • q quit"))
+	return b.String()
+}
+
+// logLines returns how many log lines fit below the item list.
+func (m Model) logLines() int {
+	n := m.height - len(m.items) - 12
+	if n < 5 {
+		n = 5
+	}
+	return n
+}
+
+// stateColor returns the color function used to render a status.
+func stateColor(s ItemState) func(format string, a ...interface{}) string {
+	switch s {
+	case StateOK:
+		return color.GreenString
+	case StatePending:
+		return color.YellowString
+	case StateProblem:
+		return color.RedString
+	default:
+		return color.CyanString
+	}
+}
@@ -0,0 +1,208 @@
+package ui
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/mad01/ralph/internal/config"
+	"github.com/mad01/ralph/internal/dotfile"
+	"github.com/mad01/ralph/internal/hooks"
+	"github.com/mad01/ralph/internal/tool"
+)
+
+// ItemKind identifies the category of a dashboard item.
+type ItemKind string
+
+const (
+	KindDotfile ItemKind = "dotfile"
+	KindRepo    ItemKind = "repo"
+	KindTool    ItemKind = "tool"
+	KindBuild   ItemKind = "build"
+	KindRecipe  ItemKind = "recipe"
+)
+
+// ItemState is a coarse health classification used for coloring.
+type ItemState int
+
+const (
+	StateOK ItemState = iota
+	StatePending
+	StateProblem
+	StateInactive
+)
+
+// Item is a single row in the dashboard.
+type Item struct {
+	Kind   ItemKind
+	Name   string
+	Detail string // Secondary information (target path, URL, run mode, ...)
+	Status string // Human-readable status
+	State  ItemState
+}
+
+// CollectItems gathers the current status of every managed item in cfg.
+// Items are grouped by kind and sorted by name within each group.
+func CollectItems(cfg *config.Config, currentHost string) []Item {
+	var items []Item
+
+	for _, name := range sortedKeys(cfg.Dotfiles) {
+		df := cfg.Dotfiles[name]
+		item := Item{Kind: KindDotfile, Name: name, Detail: df.Target}
+		if s, ok := filterStatus(df.Enable, df.Hosts, currentHost); !ok {
+			item.Status, item.State = s, StateInactive
+		} else {
+			item.Status, item.State = dotfileStatus(cfg, df)
+		}
+		items = append(items, item)
+	}
+
+	for _, name := range sortedKeys(cfg.Repos) {
+		rp := cfg.Repos[name]
+		item := Item{Kind: KindRepo, Name: name, Detail: rp.URL}
+		if s, ok := filterStatus(rp.Enable, rp.Hosts, currentHost); !ok {
+			item.Status, item.State = s, StateInactive
+		} else {
+			item.Status, item.State = repoStatus(rp)
+		}
+		items = append(items, item)
+	}
+
+	for _, t := range cfg.Tools {
+		item := Item{Kind: KindTool, Name: t.Name, Detail: t.InstallHint}
+		if s, ok := filterStatus(t.Enable, t.Hosts, currentHost); !ok {
+			item.Status, item.State = s, StateInactive
+		} else if tool.CheckStatus(t.CheckCommand) {
+			item.Status, item.State = "installed", StateOK
+		} else {
+			item.Status, item.State = "not installed", StatePending
+		}
+		items = append(items, item)
+	}
+
+	buildState, stateErr := hooks.LoadBuildState()
+	for _, name := range sortedKeys(cfg.Hooks.Builds) {
+		build := cfg.Hooks.Builds[name]
+		item := Item{Kind: KindBuild, Name: name, Detail: "run: " + build.Run}
+		if s, ok := filterStatus(build.Enable, build.Hosts, currentHost); !ok {
+			item.Status, item.State = s, StateInactive
+		} else if stateErr != nil {
+			item.Status, item.State = fmt.Sprintf("error loading build state: %v", stateErr), StateProblem
+		} else if record, exists := buildState.Builds[name]; exists {
+			item.Status, item.State = "completed at "+record.CompletedAt.Format("2006-01-02 15:04:05"), StateOK
+		} else {
+			switch build.Run {
+			case "once":
+				item.Status, item.State = "not yet run", StatePending
+			case "manual":
+				item.Status, item.State = "manual", StateInactive
+			default:
+				item.Status, item.State = "runs every apply", StateOK
+			}
+		}
+		items = append(items, item)
+	}
+
+	for _, r := range cfg.LoadedRecipes {
+		items = append(items, Item{Kind: KindRecipe, Name: r.Name, Detail: r.Path, Status: "loaded", State: StateOK})
+	}
+
+	return items
+}
+
+// filterStatus reports whether an item passes its enable and host filters,
+// returning the reason when it does not.
+func filterStatus(enable *bool, hosts []string, currentHost string) (string, bool) {
+	if !config.IsEnabled(enable) {
+		return "disabled", false
+	}
+	if !config.ShouldApplyForHost(hosts, currentHost) {
+		return "host filter", false
+	}
+	return "", true
+}
+
+// dotfileStatus inspects the target of a dotfile and describes its link state.
+func dotfileStatus(cfg *config.Config, df config.Dotfile) (string, ItemState) {
+	absoluteTarget, err := config.ExpandPath(df.Target)
+	if err != nil {
+		return fmt.Sprintf("error expanding target: %v", err), StateProblem
+	}
+	info, err := os.Lstat(absoluteTarget)
+	if os.IsNotExist(err) {
+		return "not linked", StatePending
+	}
+	if err != nil {
+		return fmt.Sprintf("error checking target: %v", err), StateProblem
+	}
+	if df.Action == "copy" {
+		return "copied", StateOK
+	}
+	if df.Action == "append_block" {
+		inSync, err := dotfile.InSync(df, cfg)
+		switch {
+		case err != nil:
+			return fmt.Sprintf("error checking block: %v", err), StateProblem
+		case !inSync:
+			return "block out of date", StatePending
+		}
+		return "block in place", StateOK
+	}
+	if df.Action == "template_dir" {
+		return "rendered", StateOK
+	}
+	if df.Merge && info.IsDir() {
+		return "merged", StateOK
+	}
+	if info.Mode()&os.ModeSymlink == 0 {
+		return "exists but is not a symlink", StateProblem
+	}
+	linkDest, err := os.Readlink(absoluteTarget)
+	if err != nil {
+		return fmt.Sprintf("error reading symlink: %v", err), StateProblem
+	}
+	if _, err := os.Stat(linkDest); err != nil {
+		return "broken symlink", StateProblem
+	}
+	if df.IsTemplate {
+		return "linked (template)", StateOK
+	}
+	expectedSource, _ := config.ExpandPath(filepath.Join(cfg.DotfilesRepoPath, df.Source))
+	if linkDest != expectedSource {
+		return "linked to wrong source", StateProblem
+	}
+	return "linked", StateOK
+}
+
+// repoStatus reports whether a repository has been cloned.
+func repoStatus(rp config.Repo) (string, ItemState) {
+	absoluteTarget, err := config.ExpandPath(rp.Target)
+	if err != nil {
+		return fmt.Sprintf("error expanding target: %v", err), StateProblem
+	}
+	info, err := os.Stat(absoluteTarget)
+	if os.IsNotExist(err) {
+		return "not cloned", StatePending
+	}
+	if err != nil {
+		return fmt.Sprintf("error checking: %v", err), StateProblem
+	}
+	if !info.IsDir() {
+		return "target is not a directory", StateProblem
+	}
+	if _, err := os.Stat(filepath.Join(absoluteTarget, ".git")); os.IsNotExist(err) {
+		return "not a git repository", StateProblem
+	}
+	return "cloned", StateOK
+}
+
+// sortedKeys returns the keys of a string-keyed map in sorted order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
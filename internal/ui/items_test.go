@@ -0,0 +1,93 @@
+package ui
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mad01/ralph/internal/config"
+)
+
+func TestCollectItems_DotfileStatus(t *testing.T) {
+	repoDir := t.TempDir()
+	targetDir := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(repoDir, "bashrc"), []byte("# bashrc"), 0644); err != nil {
+		t.Fatalf("Failed to write source file: %v", err)
+	}
+	linkedTarget := filepath.Join(targetDir, ".bashrc")
+	if err := os.Symlink(filepath.Join(repoDir, "bashrc"), linkedTarget); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+	plainTarget := filepath.Join(targetDir, ".vimrc")
+	if err := os.WriteFile(plainTarget, []byte("set nu"), 0644); err != nil {
+		t.Fatalf("Failed to write plain target: %v", err)
+	}
+
+	disabled := false
+	cfg := &config.Config{
+		DotfilesRepoPath: repoDir,
+		Dotfiles: map[string]config.Dotfile{
+			"bashrc":   {Source: "bashrc", Target: linkedTarget},
+			"vimrc":    {Source: "vimrc", Target: plainTarget},
+			"zshrc":    {Source: "zshrc", Target: filepath.Join(targetDir, ".zshrc")},
+			"disabled": {Source: "x", Target: filepath.Join(targetDir, ".x"), Enable: &disabled},
+			"otherbox": {Source: "y", Target: filepath.Join(targetDir, ".y"), Hosts: []string{"otherbox"}},
+		},
+	}
+
+	items := CollectItems(cfg, "thisbox")
+
+	want := map[string]struct {
+		status string
+		state  ItemState
+	}{
+		"bashrc":   {"linked", StateOK},
+		"vimrc":    {"exists but is not a symlink", StateProblem},
+		"zshrc":    {"not linked", StatePending},
+		"disabled": {"disabled", StateInactive},
+		"otherbox": {"host filter", StateInactive},
+	}
+
+	if len(items) != len(want) {
+		t.Fatalf("CollectItems returned %d items, want %d", len(items), len(want))
+	}
+	for _, item := range items {
+		w, ok := want[item.Name]
+		if !ok {
+			t.Errorf("Unexpected item %q", item.Name)
+			continue
+		}
+		if item.Status != w.status || item.State != w.state {
+			t.Errorf("Item %q: got (%q, %d), want (%q, %d)", item.Name, item.Status, item.State, w.status, w.state)
+		}
+	}
+}
+
+func TestUnlinkDotfile_RefusesRegularFile(t *testing.T) {
+	targetDir := t.TempDir()
+	target := filepath.Join(targetDir, ".vimrc")
+	if err := os.WriteFile(target, []byte("set nu"), 0644); err != nil {
+		t.Fatalf("Failed to write target: %v", err)
+	}
+	cfg := &config.Config{
+		Dotfiles: map[string]config.Dotfile{"vimrc": {Source: "vimrc", Target: target}},
+	}
+
+	if err := UnlinkDotfile(os.Stderr, cfg, "vimrc"); err == nil {
+		t.Error("Expected UnlinkDotfile to refuse removing a regular file")
+	}
+	if _, err := os.Stat(target); err != nil {
+		t.Errorf("Regular file was removed: %v", err)
+	}
+}
+
+func TestLogBufferTail(t *testing.T) {
+	b := &logBuffer{}
+	b.Write([]byte("one\ntwo\r\nthree\n"))
+
+	lines := b.Tail(2)
+	if len(lines) != 2 || lines[0] != "two" || lines[1] != "three" {
+		t.Errorf("Tail(2) = %q, want [two three]", lines)
+	}
+}
@@ -0,0 +1,94 @@
+package vscode
+
+import (
+	"fmt"
+	"io"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// runCode runs the editor CLI and returns its trimmed output. It is a
+// variable so tests can replace it.
+var runCode = func(command string, args ...string) (string, error) {
+	out, err := exec.Command(command, args...).CombinedOutput()
+	return strings.TrimSpace(string(out)), err
+}
+
+// Available reports whether the editor CLI is on PATH.
+func Available(command string) bool {
+	_, err := exec.LookPath(command)
+	return err == nil
+}
+
+// Installed returns the sorted IDs of the installed extensions, lowercased
+// since extension IDs are case-insensitive.
+func Installed(command string) ([]string, error) {
+	out, err := runCode(command, "--list-extensions")
+	if err != nil {
+		return nil, fmt.Errorf("%s --list-extensions failed: %w: %s", command, err, out)
+	}
+	var ids []string
+	for _, line := range strings.Split(out, "\n") {
+		if id := strings.TrimSpace(line); id != "" {
+			ids = append(ids, strings.ToLower(id))
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// Diff compares the configured extensions against the installed ones and
+// returns the configured ones that are missing and the installed ones that
+// are not configured. IDs are compared case-insensitively; missing keeps the
+// configured spelling.
+func Diff(wanted, installed []string) (missing, extra []string) {
+	have := make(map[string]bool, len(installed))
+	for _, id := range installed {
+		have[strings.ToLower(id)] = true
+	}
+	want := make(map[string]bool, len(wanted))
+	for _, id := range wanted {
+		want[strings.ToLower(id)] = true
+		if !have[strings.ToLower(id)] {
+			missing = append(missing, id)
+		}
+	}
+	for _, id := range installed {
+		if !want[strings.ToLower(id)] {
+			extra = append(extra, id)
+		}
+	}
+	sort.Strings(missing)
+	sort.Strings(extra)
+	return missing, extra
+}
+
+// Install installs one extension with `<command> --install-extension`.
+// If dryRun is true, it will only print the command it would run.
+func Install(w io.Writer, command, id string, dryRun bool) error {
+	if dryRun {
+		fmt.Fprintf(w, "    %s would run: %s --install-extension %s\n", color.CyanString("[dry run]"), command, id)
+		return nil
+	}
+	if out, err := runCode(command, "--install-extension", id); err != nil {
+		return fmt.Errorf("%s --install-extension %s failed: %w: %s", command, id, err, out)
+	}
+	fmt.Fprintf(w, "    %s\n", color.GreenString("installed"))
+	return nil
+}
+
+// ExportTOML returns a [vscode] section listing ids, ready to paste into
+// config.toml.
+func ExportTOML(ids []string) string {
+	var b strings.Builder
+	b.WriteString("[vscode]\nextensions = [\n")
+	for _, id := range ids {
+		fmt.Fprintf(&b, "  %s,\n", strconv.Quote(id))
+	}
+	b.WriteString("]\n")
+	return b.String()
+}
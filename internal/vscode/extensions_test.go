@@ -0,0 +1,84 @@
+package vscode
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/BurntSushi/toml"
+)
+
+// fakeCode replaces runCode with one that lists installed and records the
+// extensions it is asked to install.
+func fakeCode(t *testing.T, installed string) *[]string {
+	t.Helper()
+	var installs []string
+	original := runCode
+	runCode = func(command string, args ...string) (string, error) {
+		switch args[0] {
+		case "--list-extensions":
+			return installed, nil
+		case "--install-extension":
+			if args[1] == "bad.ext" {
+				return "Extension 'bad.ext' not found.", errors.New("exit status 1")
+			}
+			installs = append(installs, args[1])
+			return "", nil
+		}
+		t.Fatalf("unexpected call %s %v", command, args)
+		return "", nil
+	}
+	t.Cleanup(func() { runCode = original })
+	return &installs
+}
+
+func TestInstalledAndDiff(t *testing.T) {
+	fakeCode(t, "golang.Go\nms-python.python\n\n")
+	installed, err := Installed("code")
+	if err != nil {
+		t.Fatalf("Installed failed: %v", err)
+	}
+	if want := []string{"golang.go", "ms-python.python"}; !reflect.DeepEqual(installed, want) {
+		t.Errorf("Installed = %v, want %v", installed, want)
+	}
+
+	missing, extra := Diff([]string{"golang.Go", "rust-lang.rust-analyzer"}, installed)
+	if want := []string{"rust-lang.rust-analyzer"}; !reflect.DeepEqual(missing, want) {
+		t.Errorf("missing = %v, want %v", missing, want)
+	}
+	if want := []string{"ms-python.python"}; !reflect.DeepEqual(extra, want) {
+		t.Errorf("extra = %v, want %v", extra, want)
+	}
+}
+
+func TestInstall(t *testing.T) {
+	installs := fakeCode(t, "")
+	if err := Install(io.Discard, "code", "golang.go", true); err != nil || len(*installs) != 0 {
+		t.Fatalf("dry run = %v, installed %v; want nothing installed", err, *installs)
+	}
+	if err := Install(io.Discard, "code", "golang.go", false); err != nil {
+		t.Fatalf("Install failed: %v", err)
+	}
+	if want := []string{"golang.go"}; !reflect.DeepEqual(*installs, want) {
+		t.Errorf("installs = %v, want %v", *installs, want)
+	}
+	if err := Install(io.Discard, "code", "bad.ext", false); err == nil {
+		t.Error("expected an error for a failed install")
+	}
+}
+
+func TestExportTOML(t *testing.T) {
+	var decoded struct {
+		VSCode struct {
+			Extensions []string `toml:"extensions"`
+		} `toml:"vscode"`
+	}
+	ids := []string{"golang.go", "ms-python.python"}
+	if _, err := toml.Decode(ExportTOML(ids), &decoded); err != nil {
+		t.Fatalf("exported TOML does not parse: %v", err)
+	}
+	if !reflect.DeepEqual(decoded.VSCode.Extensions, ids) {
+		t.Errorf("round trip = %v, want %v", decoded.VSCode.Extensions, ids)
+	}
+}
@@ -16,6 +16,8 @@ import (
 // 2. go build -o uppercaser
 // 3. echo "hello world" | ./uppercaser
 func main() {
+	pipeutil.RequirePipedInput("usage: echo \"hello world\" | uppercaser")
+
 	binput, err := pipeutil.ReadAll()
 	if err != nil {
 		pipeutil.Errorf("failed to read from stdin: %v", err)
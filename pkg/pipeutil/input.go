@@ -0,0 +1,97 @@
+package pipeutil
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// StdinName is the input name reported for standard input.
+const StdinName = "<stdin>"
+
+// InputSource reads from the files named on the command line, falling back to
+// os.Stdin when none are given (like cat or grep). The argument "-" also
+// denotes stdin.
+type InputSource struct {
+	Args []string
+}
+
+// Inputs returns an InputSource for the given command-line arguments.
+func Inputs(args []string) InputSource {
+	return InputSource{Args: args}
+}
+
+// Names returns the name of every input in order, using StdinName for stdin.
+func (s InputSource) Names() []string {
+	if len(s.Args) == 0 {
+		return []string{StdinName}
+	}
+	names := make([]string, len(s.Args))
+	for i, arg := range s.Args {
+		if arg == "-" {
+			names[i] = StdinName
+		} else {
+			names[i] = arg
+		}
+	}
+	return names
+}
+
+// ForEach opens each input in turn and calls fn with its name and reader.
+// Files are closed after fn returns. Processing stops at the first error.
+func (s InputSource) ForEach(fn func(name string, r io.Reader) error) error {
+	for _, name := range s.Names() {
+		if name == StdinName {
+			if err := fn(name, os.Stdin); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := forEachFile(name, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Reader returns a single reader over all inputs concatenated, along with a
+// function that closes any opened files. All files are opened up front so
+// a missing file is reported before any input is consumed.
+func (s InputSource) Reader() (io.Reader, func() error, error) {
+	var readers []io.Reader
+	var files []*os.File
+	closeAll := func() error {
+		var firstErr error
+		for _, f := range files {
+			if err := f.Close(); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+		return firstErr
+	}
+
+	for _, name := range s.Names() {
+		if name == StdinName {
+			readers = append(readers, os.Stdin)
+			continue
+		}
+		f, err := os.Open(name)
+		if err != nil {
+			closeAll()
+			return nil, nil, fmt.Errorf("%s: %w", name, err)
+		}
+		files = append(files, f)
+		readers = append(readers, f)
+	}
+	return io.MultiReader(readers...), closeAll, nil
+}
+
+// forEachFile opens a single named file and passes it to fn.
+func forEachFile(name string, fn func(name string, r io.Reader) error) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	defer f.Close()
+	return fn(name, f)
+}
@@ -0,0 +1,91 @@
+package pipeutil
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeTempInput(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write temp input %s: %v", path, err)
+	}
+	return path
+}
+
+func TestInputSource_FallsBackToStdin(t *testing.T) {
+	restore := mockStdin(t, "from stdin")
+	defer restore()
+
+	var names []string
+	var contents []string
+	err := Inputs(nil).ForEach(func(name string, r io.Reader) error {
+		data, err := io.ReadAll(r)
+		names = append(names, name)
+		contents = append(contents, string(data))
+		return err
+	})
+	if err != nil {
+		t.Fatalf("ForEach returned an error: %v", err)
+	}
+	if len(names) != 1 || names[0] != StdinName || contents[0] != "from stdin" {
+		t.Errorf("Expected single stdin input, got names=%v contents=%v", names, contents)
+	}
+}
+
+func TestInputSource_Files(t *testing.T) {
+	a := writeTempInput(t, "a.txt", "aaa")
+	b := writeTempInput(t, "b.txt", "bbb")
+
+	var got []string
+	err := Inputs([]string{a, b}).ForEach(func(name string, r io.Reader) error {
+		data, err := io.ReadAll(r)
+		got = append(got, filepath.Base(name)+"="+string(data))
+		return err
+	})
+	if err != nil {
+		t.Fatalf("ForEach returned an error: %v", err)
+	}
+	if strings.Join(got, ",") != "a.txt=aaa,b.txt=bbb" {
+		t.Errorf("ForEach inputs mismatch: got %v", got)
+	}
+}
+
+func TestInputSource_MissingFile(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing.txt")
+	err := Inputs([]string{missing}).ForEach(func(name string, r io.Reader) error { return nil })
+	if err == nil || !strings.Contains(err.Error(), missing) {
+		t.Errorf("Expected error naming the missing file, got %v", err)
+	}
+
+	if _, _, err := Inputs([]string{missing}).Reader(); err == nil {
+		t.Error("Expected Reader to fail for a missing file")
+	}
+}
+
+func TestInputSource_Reader(t *testing.T) {
+	a := writeTempInput(t, "a.txt", "one\n")
+	b := writeTempInput(t, "b.txt", "two\n")
+
+	r, closeFn, err := Inputs([]string{a, b}).Reader()
+	if err != nil {
+		t.Fatalf("Reader returned an error: %v", err)
+	}
+	defer closeFn()
+
+	data, _ := io.ReadAll(r)
+	if string(data) != "one\ntwo\n" {
+		t.Errorf("Reader content mismatch: got %q", string(data))
+	}
+}
+
+func TestInputSource_DashIsStdin(t *testing.T) {
+	names := Inputs([]string{"-", "file.txt"}).Names()
+	if names[0] != StdinName || names[1] != "file.txt" {
+		t.Errorf("Names mismatch: got %v", names)
+	}
+}
@@ -0,0 +1,75 @@
+package pipeutil
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// ReadJSON decodes a single JSON document from os.Stdin into a value of type T.
+func ReadJSON[T any]() (T, error) {
+	return DecodeJSON[T](os.Stdin)
+}
+
+// DecodeJSON decodes a single JSON document from r into a value of type T.
+func DecodeJSON[T any](r io.Reader) (T, error) {
+	var v T
+	if err := json.NewDecoder(r).Decode(&v); err != nil {
+		return v, fmt.Errorf("failed to decode JSON: %w", err)
+	}
+	return v, nil
+}
+
+// WriteJSON writes v to os.Stdout as indented JSON followed by a newline.
+func WriteJSON(v any) error {
+	return EncodeJSON(os.Stdout, v)
+}
+
+// EncodeJSON writes v to w as indented JSON followed by a newline.
+func EncodeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return fmt.Errorf("failed to encode JSON: %w", err)
+	}
+	return nil
+}
+
+// WriteJSONLine writes v to os.Stdout as a single compact JSON line (JSONL).
+func WriteJSONLine(v any) error {
+	return EncodeJSONLine(os.Stdout, v)
+}
+
+// EncodeJSONLine writes v to w as a single compact JSON line (JSONL).
+func EncodeJSONLine(w io.Writer, v any) error {
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		return fmt.Errorf("failed to encode JSON line: %w", err)
+	}
+	return nil
+}
+
+// ForEachJSONLine decodes each line of os.Stdin as a JSON value of type T and
+// calls fn with it. Blank lines are skipped.
+func ForEachJSONLine[T any](ctx context.Context, fn func(v T) error) error {
+	return ForEachJSONLineFrom(ctx, os.Stdin, fn)
+}
+
+// ForEachJSONLineFrom decodes each line of r as a JSON value of type T and
+// calls fn with it. Blank lines are skipped. Decode errors report the line number.
+func ForEachJSONLineFrom[T any](ctx context.Context, r io.Reader, fn func(v T) error) error {
+	lineNo := 0
+	return ForEachLineFrom(ctx, r, DefaultMaxLineSize, func(line string) error {
+		lineNo++
+		if strings.TrimSpace(line) == "" {
+			return nil
+		}
+		var v T
+		if err := json.Unmarshal([]byte(line), &v); err != nil {
+			return fmt.Errorf("failed to decode JSON on line %d: %w", lineNo, err)
+		}
+		return fn(v)
+	})
+}
@@ -0,0 +1,84 @@
+package pipeutil
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+type testRecord struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+func TestDecodeJSON(t *testing.T) {
+	rec, err := DecodeJSON[testRecord](strings.NewReader(`{"name": "a", "count": 2}`))
+	if err != nil {
+		t.Fatalf("DecodeJSON returned an error: %v", err)
+	}
+	if rec.Name != "a" || rec.Count != 2 {
+		t.Errorf("DecodeJSON mismatch: got %+v", rec)
+	}
+
+	if _, err := DecodeJSON[testRecord](strings.NewReader(`{not json`)); err == nil {
+		t.Error("Expected DecodeJSON to fail on invalid input")
+	}
+}
+
+func TestReadJSON(t *testing.T) {
+	restore := mockStdin(t, `{"name": "stdin", "count": 1}`)
+	defer restore()
+
+	rec, err := ReadJSON[testRecord]()
+	if err != nil {
+		t.Fatalf("ReadJSON returned an error: %v", err)
+	}
+	if rec.Name != "stdin" {
+		t.Errorf("ReadJSON mismatch: got %+v", rec)
+	}
+}
+
+func TestEncodeJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := EncodeJSON(&buf, testRecord{Name: "a", Count: 1}); err != nil {
+		t.Fatalf("EncodeJSON returned an error: %v", err)
+	}
+	want := "{\n  \"name\": \"a\",\n  \"count\": 1\n}\n"
+	if buf.String() != want {
+		t.Errorf("EncodeJSON output mismatch:\nGot:  %q\nWant: %q", buf.String(), want)
+	}
+}
+
+func TestEncodeJSONLine(t *testing.T) {
+	var buf bytes.Buffer
+	EncodeJSONLine(&buf, testRecord{Name: "a", Count: 1})
+	EncodeJSONLine(&buf, testRecord{Name: "b", Count: 2})
+	want := "{\"name\":\"a\",\"count\":1}\n{\"name\":\"b\",\"count\":2}\n"
+	if buf.String() != want {
+		t.Errorf("EncodeJSONLine output mismatch:\nGot:  %q\nWant: %q", buf.String(), want)
+	}
+}
+
+func TestForEachJSONLineFrom(t *testing.T) {
+	input := "{\"name\":\"a\",\"count\":1}\n\n{\"name\":\"b\",\"count\":2}\n"
+	var got []testRecord
+	err := ForEachJSONLineFrom(context.Background(), strings.NewReader(input), func(rec testRecord) error {
+		got = append(got, rec)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ForEachJSONLineFrom returned an error: %v", err)
+	}
+	if len(got) != 2 || got[0].Name != "a" || got[1].Count != 2 {
+		t.Errorf("ForEachJSONLineFrom records mismatch: got %+v", got)
+	}
+}
+
+func TestForEachJSONLineFrom_ReportsLineNumber(t *testing.T) {
+	input := "{\"name\":\"a\"}\n{broken\n"
+	err := ForEachJSONLineFrom(context.Background(), strings.NewReader(input), func(rec testRecord) error { return nil })
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("Expected error mentioning line 2, got %v", err)
+	}
+}
@@ -0,0 +1,77 @@
+package pipeutil
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"iter"
+	"os"
+)
+
+// DefaultMaxLineSize is the longest line Lines and ForEachLine accept by default.
+// Longer lines cause a bufio.ErrTooLong error.
+const DefaultMaxLineSize = 1024 * 1024
+
+// Lines returns an iterator over the lines of os.Stdin.
+// Iteration stops when stdin is exhausted or ctx is cancelled.
+// See LinesFrom for details.
+func Lines(ctx context.Context) iter.Seq2[string, error] {
+	return LinesFrom(ctx, os.Stdin, DefaultMaxLineSize)
+}
+
+// LinesFrom returns an iterator over the lines of r without buffering the whole input.
+// Line endings are stripped. maxLineSize bounds the length of a single line; values
+// <= 0 use DefaultMaxLineSize.
+//
+// Cancellation is checked between lines: if ctx is cancelled, the iterator yields
+// ctx.Err() once and stops. A read already blocked on r is not interrupted.
+// A read error is yielded once as the final element.
+func LinesFrom(ctx context.Context, r io.Reader, maxLineSize int) iter.Seq2[string, error] {
+	if maxLineSize <= 0 {
+		maxLineSize = DefaultMaxLineSize
+	}
+	return func(yield func(string, error) bool) {
+		scanner := bufio.NewScanner(r)
+		initial := 64 * 1024
+		if initial > maxLineSize {
+			initial = maxLineSize
+		}
+		scanner.Buffer(make([]byte, 0, initial), maxLineSize)
+
+		for {
+			if err := ctx.Err(); err != nil {
+				yield("", err)
+				return
+			}
+			if !scanner.Scan() {
+				break
+			}
+			if !yield(scanner.Text(), nil) {
+				return
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			yield("", err)
+		}
+	}
+}
+
+// ForEachLine calls fn for every line read from os.Stdin.
+// It stops at the first error returned by fn, a read error, or ctx cancellation.
+func ForEachLine(ctx context.Context, fn func(line string) error) error {
+	return ForEachLineFrom(ctx, os.Stdin, DefaultMaxLineSize, fn)
+}
+
+// ForEachLineFrom calls fn for every line read from r. See LinesFrom for how
+// maxLineSize and cancellation are handled.
+func ForEachLineFrom(ctx context.Context, r io.Reader, maxLineSize int, fn func(line string) error) error {
+	for line, err := range LinesFrom(ctx, r, maxLineSize) {
+		if err != nil {
+			return err
+		}
+		if err := fn(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
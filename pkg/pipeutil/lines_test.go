@@ -0,0 +1,98 @@
+package pipeutil
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestLinesFrom(t *testing.T) {
+	input := "line one\nline two\r\nline three"
+	var lines []string
+	for line, err := range LinesFrom(context.Background(), strings.NewReader(input), 0) {
+		if err != nil {
+			t.Fatalf("LinesFrom returned an error: %v", err)
+		}
+		lines = append(lines, line)
+	}
+
+	expectedLines := []string{"line one", "line two", "line three"}
+	if len(lines) != len(expectedLines) {
+		t.Fatalf("LinesFrom read %d lines, want %d. Got: %v", len(lines), len(expectedLines), lines)
+	}
+	for i, line := range lines {
+		if line != expectedLines[i] {
+			t.Errorf("Line %d mismatch: got '%s', want '%s'", i, line, expectedLines[i])
+		}
+	}
+}
+
+func TestLinesFrom_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	count := 0
+	var gotErr error
+	for _, err := range LinesFrom(ctx, strings.NewReader("a\nb\nc\n"), 0) {
+		if err != nil {
+			gotErr = err
+			break
+		}
+		count++
+		cancel()
+	}
+	if count != 1 {
+		t.Errorf("Expected iteration to stop after cancel, read %d lines", count)
+	}
+	if !errors.Is(gotErr, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", gotErr)
+	}
+}
+
+func TestLinesFrom_MaxLineSize(t *testing.T) {
+	var gotErr error
+	for _, err := range LinesFrom(context.Background(), strings.NewReader(strings.Repeat("x", 100)+"\n"), 16) {
+		if err != nil {
+			gotErr = err
+		}
+	}
+	if !errors.Is(gotErr, bufio.ErrTooLong) {
+		t.Errorf("Expected bufio.ErrTooLong for oversized line, got %v", gotErr)
+	}
+}
+
+func TestForEachLineFrom_StopsOnCallbackError(t *testing.T) {
+	stop := errors.New("stop")
+	var seen []string
+	err := ForEachLineFrom(context.Background(), strings.NewReader("a\nb\nc\n"), 0, func(line string) error {
+		seen = append(seen, line)
+		if line == "b" {
+			return stop
+		}
+		return nil
+	})
+	if !errors.Is(err, stop) {
+		t.Errorf("Expected callback error to be returned, got %v", err)
+	}
+	if len(seen) != 2 {
+		t.Errorf("Expected 2 lines before stopping, got %v", seen)
+	}
+}
+
+func TestForEachLine(t *testing.T) {
+	restore := mockStdin(t, "first\nsecond\n")
+	defer restore()
+
+	var lines []string
+	err := ForEachLine(context.Background(), func(line string) error {
+		lines = append(lines, line)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ForEachLine returned an error: %v", err)
+	}
+	if strings.Join(lines, ",") != "first,second" {
+		t.Errorf("ForEachLine lines mismatch: got %v", lines)
+	}
+}
@@ -0,0 +1,67 @@
+package pipeutil
+
+import (
+	"fmt"
+	"os"
+	"sync/atomic"
+)
+
+// ANSI escape sequences used for colored status output.
+const (
+	ansiReset  = "\033[0m"
+	ansiRed    = "\033[31m"
+	ansiGreen  = "\033[32m"
+	ansiYellow = "\033[33m"
+)
+
+var quiet atomic.Bool
+
+// SetQuiet enables or disables quiet mode. In quiet mode Successf, Warnf and
+// Infof print nothing; errors are always printed.
+func SetQuiet(q bool) {
+	quiet.Store(q)
+}
+
+// IsQuiet reports whether quiet mode is enabled.
+func IsQuiet() bool {
+	return quiet.Load()
+}
+
+// ColorEnabled reports whether output written to f should be colorized:
+// f must be a terminal and the NO_COLOR environment variable must be unset.
+func ColorEnabled(f *os.File) bool {
+	return os.Getenv("NO_COLOR") == "" && IsTerminal(f)
+}
+
+// Successf prints a formatted success message to os.Stderr.
+func Successf(format string, a ...interface{}) {
+	if IsQuiet() {
+		return
+	}
+	printStatus(ansiGreen, "", format, a...)
+}
+
+// Warnf prints a formatted warning message to os.Stderr.
+func Warnf(format string, a ...interface{}) {
+	if IsQuiet() {
+		return
+	}
+	printStatus(ansiYellow, "Warning: ", format, a...)
+}
+
+// Infof prints a formatted informational message to os.Stderr.
+func Infof(format string, a ...interface{}) {
+	if IsQuiet() {
+		return
+	}
+	fmt.Fprintf(os.Stderr, format+"\n", a...)
+}
+
+// printStatus writes a prefixed message to os.Stderr, colorized when stderr is a terminal.
+func printStatus(ansiColor, prefix, format string, a ...interface{}) {
+	msg := prefix + fmt.Sprintf(format, a...)
+	if ColorEnabled(os.Stderr) {
+		msg = ansiColor + msg + ansiReset
+	}
+	fmt.Fprintln(os.Stderr, msg)
+}
@@ -0,0 +1,47 @@
+package pipeutil
+
+import (
+	"os"
+	"testing"
+)
+
+func TestSuccessfAndWarnf(t *testing.T) {
+	restoreStderr, getStderrOutput := captureStderr(t)
+
+	Successf("linked %d files", 3)
+	Warnf("skipped %s", "foo")
+
+	restoreStderr()
+	output := getStderrOutput()
+	// stderr is a pipe here, so no color codes are expected.
+	expectedOutput := "linked 3 files\nWarning: skipped foo\n"
+	if output != expectedOutput {
+		t.Errorf("Status output mismatch:\nGot:  %q\nWant: %q", output, expectedOutput)
+	}
+}
+
+func TestQuietMode(t *testing.T) {
+	SetQuiet(true)
+	defer SetQuiet(false)
+
+	restoreStderr, getStderrOutput := captureStderr(t)
+
+	Successf("hidden")
+	Warnf("hidden")
+	Infof("hidden")
+	Errorf("shown")
+
+	restoreStderr()
+	output := getStderrOutput()
+	expectedOutput := "Error: shown\n"
+	if output != expectedOutput {
+		t.Errorf("Quiet mode output mismatch:\nGot:  %q\nWant: %q", output, expectedOutput)
+	}
+}
+
+func TestColorEnabled_NoColor(t *testing.T) {
+	t.Setenv("NO_COLOR", "1")
+	if ColorEnabled(os.Stderr) {
+		t.Error("ColorEnabled returned true with NO_COLOR set")
+	}
+}
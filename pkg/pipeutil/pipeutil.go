@@ -29,15 +29,17 @@ func Println(s string) (int, error) {
 }
 
 // Error prints an error message to os.Stderr.
+// The message is colorized when stderr is a terminal and NO_COLOR is unset.
 func Error(err error) {
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "Error:", err)
+		printStatus(ansiRed, "Error: ", "%v", err)
 	}
 }
 
 // Errorf prints a formatted error message to os.Stderr.
+// The message is colorized when stderr is a terminal and NO_COLOR is unset.
 func Errorf(format string, a ...interface{}) {
-	fmt.Fprintf(os.Stderr, "Error: "+format+"\n", a...)
+	printStatus(ansiRed, "Error: ", format, a...)
 }
 
 // Constants for common exit codes
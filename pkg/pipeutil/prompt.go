@@ -0,0 +1,87 @@
+package pipeutil
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// openTTY opens the controlling terminal for interactive prompts.
+// This is a variable to allow for easier testing.
+var openTTY = func() (io.ReadWriteCloser, error) {
+	return os.OpenFile("/dev/tty", os.O_RDWR, 0)
+}
+
+// Confirm asks a yes/no question on the controlling terminal and returns true
+// if the user answers "y" or "yes". The default answer is no.
+// Because it reads from /dev/tty, it works even when stdin is a pipe.
+func Confirm(msg string) bool {
+	ok, err := withTTY(func(r io.Reader, w io.Writer) (bool, error) {
+		return ConfirmFrom(r, w, msg)
+	})
+	return err == nil && ok
+}
+
+// Prompt asks for a line of input on the controlling terminal, returning def
+// if the user enters nothing. Because it reads from /dev/tty, it works even
+// when stdin is a pipe.
+func Prompt(msg, def string) (string, error) {
+	return withTTY(func(r io.Reader, w io.Writer) (string, error) {
+		return PromptFrom(r, w, msg, def)
+	})
+}
+
+// ConfirmFrom writes a yes/no question to w and reads the answer from r.
+func ConfirmFrom(r io.Reader, w io.Writer, msg string) (bool, error) {
+	fmt.Fprintf(w, "%s [y/N]: ", msg)
+	answer, err := readLine(r)
+	if err != nil {
+		return false, err
+	}
+	switch strings.ToLower(answer) {
+	case "y", "yes":
+		return true, nil
+	default:
+		return false, nil
+	}
+}
+
+// PromptFrom writes msg to w and reads a line from r, returning def if the
+// line is empty.
+func PromptFrom(r io.Reader, w io.Writer, msg, def string) (string, error) {
+	if def != "" {
+		fmt.Fprintf(w, "%s [%s]: ", msg, def)
+	} else {
+		fmt.Fprintf(w, "%s: ", msg)
+	}
+	answer, err := readLine(r)
+	if err != nil {
+		return "", err
+	}
+	if answer == "" {
+		return def, nil
+	}
+	return answer, nil
+}
+
+// withTTY runs fn against the controlling terminal, falling back to
+// os.Stdin/os.Stderr when no terminal can be opened.
+func withTTY[T any](fn func(r io.Reader, w io.Writer) (T, error)) (T, error) {
+	tty, err := openTTY()
+	if err != nil {
+		return fn(os.Stdin, os.Stderr)
+	}
+	defer tty.Close()
+	return fn(tty, tty)
+}
+
+// readLine reads a single trimmed line from r. EOF after a partial line is not an error.
+func readLine(r io.Reader) (string, error) {
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", fmt.Errorf("failed to read answer: %w", err)
+	}
+	return strings.TrimSpace(line), nil
+}
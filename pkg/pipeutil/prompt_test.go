@@ -0,0 +1,108 @@
+package pipeutil
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// fakeTTY is an in-memory stand-in for /dev/tty.
+type fakeTTY struct {
+	io.Reader
+	bytes.Buffer
+}
+
+func (f *fakeTTY) Read(p []byte) (int, error) { return f.Reader.Read(p) }
+func (f *fakeTTY) Close() error               { return nil }
+
+func useFakeTTY(t *testing.T, input string) *fakeTTY {
+	t.Helper()
+	tty := &fakeTTY{Reader: strings.NewReader(input)}
+	original := openTTY
+	openTTY = func() (io.ReadWriteCloser, error) { return tty, nil }
+	t.Cleanup(func() { openTTY = original })
+	return tty
+}
+
+func TestConfirmFrom(t *testing.T) {
+	tests := []struct {
+		input string
+		want  bool
+	}{
+		{"y\n", true},
+		{"YES\n", true},
+		{"n\n", false},
+		{"\n", false},
+		{"maybe\n", false},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		got, err := ConfirmFrom(strings.NewReader(tt.input), &out, "Proceed?")
+		if err != nil {
+			t.Fatalf("ConfirmFrom(%q) returned an error: %v", tt.input, err)
+		}
+		if got != tt.want {
+			t.Errorf("ConfirmFrom(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+		if out.String() != "Proceed? [y/N]: " {
+			t.Errorf("ConfirmFrom prompt mismatch: got %q", out.String())
+		}
+	}
+}
+
+func TestPromptFrom_Default(t *testing.T) {
+	var out bytes.Buffer
+	got, err := PromptFrom(strings.NewReader("\n"), &out, "Name", "ralph")
+	if err != nil {
+		t.Fatalf("PromptFrom returned an error: %v", err)
+	}
+	if got != "ralph" {
+		t.Errorf("PromptFrom = %q, want default %q", got, "ralph")
+	}
+	if out.String() != "Name [ralph]: " {
+		t.Errorf("PromptFrom prompt mismatch: got %q", out.String())
+	}
+}
+
+func TestPromptFrom_EOFWithoutAnswer(t *testing.T) {
+	if _, err := PromptFrom(strings.NewReader(""), io.Discard, "Name", ""); err == nil {
+		t.Error("Expected PromptFrom to fail on empty input")
+	}
+}
+
+func TestConfirm_UsesTTY(t *testing.T) {
+	tty := useFakeTTY(t, "yes\n")
+	// Piped stdin must not be consumed by the prompt.
+	restore := mockStdin(t, "n\n")
+	defer restore()
+
+	if !Confirm("Overwrite?") {
+		t.Error("Confirm returned false, want true from tty input")
+	}
+	if !strings.Contains(tty.String(), "Overwrite? [y/N]") {
+		t.Errorf("Expected prompt on tty, got %q", tty.String())
+	}
+}
+
+func TestPrompt_FallsBackToStdin(t *testing.T) {
+	original := openTTY
+	openTTY = func() (io.ReadWriteCloser, error) { return nil, errors.New("no tty") }
+	defer func() { openTTY = original }()
+
+	restore := mockStdin(t, "answer\n")
+	defer restore()
+	restoreStderr, getStderrOutput := captureStderr(t)
+
+	got, err := Prompt("Question", "")
+	restoreStderr()
+	getStderrOutput()
+
+	if err != nil {
+		t.Fatalf("Prompt returned an error: %v", err)
+	}
+	if got != "answer" {
+		t.Errorf("Prompt = %q, want %q", got, "answer")
+	}
+}
@@ -0,0 +1,46 @@
+package pipeutil
+
+import (
+	"fmt"
+	"os"
+)
+
+// IsTerminal reports whether f refers to an interactive terminal.
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// StdinIsPipe reports whether os.Stdin is connected to a pipe or a redirected
+// file rather than a terminal, i.e. whether there is input to read.
+func StdinIsPipe() bool {
+	return !IsTerminal(os.Stdin)
+}
+
+// StdinIsTTY reports whether os.Stdin is an interactive terminal.
+func StdinIsTTY() bool {
+	return IsTerminal(os.Stdin)
+}
+
+// StdoutIsTTY reports whether os.Stdout is an interactive terminal.
+func StdoutIsTTY() bool {
+	return IsTerminal(os.Stdout)
+}
+
+// StderrIsTTY reports whether os.Stderr is an interactive terminal.
+func StderrIsTTY() bool {
+	return IsTerminal(os.Stderr)
+}
+
+// RequirePipedInput prints usage to os.Stderr and exits with ExitFailure
+// when the program is run without piped input on stdin.
+func RequirePipedInput(usage string) {
+	if StdinIsPipe() {
+		return
+	}
+	fmt.Fprintln(os.Stderr, usage)
+	os.Exit(ExitFailure)
+}
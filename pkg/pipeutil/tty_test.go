@@ -0,0 +1,44 @@
+package pipeutil
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIsTerminal_Pipe(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Failed to create pipe: %v", err)
+	}
+	defer r.Close()
+	defer w.Close()
+
+	if IsTerminal(r) {
+		t.Error("IsTerminal reported a pipe as a terminal")
+	}
+}
+
+func TestIsTerminal_File(t *testing.T) {
+	f, err := os.Create(filepath.Join(t.TempDir(), "out.txt"))
+	if err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	defer f.Close()
+
+	if IsTerminal(f) {
+		t.Error("IsTerminal reported a regular file as a terminal")
+	}
+}
+
+func TestStdinIsPipe(t *testing.T) {
+	restore := mockStdin(t, "input")
+	defer restore()
+
+	if !StdinIsPipe() {
+		t.Error("StdinIsPipe returned false for piped stdin")
+	}
+	if StdinIsTTY() {
+		t.Error("StdinIsTTY returned true for piped stdin")
+	}
+}